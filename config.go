@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/guildconfig"
+)
+
+const configUsage = "usage: reddit-spy config export -guild ID | config apply -f FILE [-dry-run]"
+
+// runConfig implements the config subcommand, the CLI side of
+// /export_config and /import_config. export writes a guild's config as
+// YAML; apply prints what a file would change and, unless -dry-run, applies
+// it in one transaction. A running bot only polls subreddits new to it
+// after a restart.
+func runConfig(ctx ctxpkg.Ctx, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	guild := fs.String("guild", "", "guild (server) id to export")
+	file := fs.String("f", "", "config file to apply, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "show the changes without applying them")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 {
+		return errors.New(configUsage)
+	}
+
+	switch args[0] {
+	case "export":
+		if *guild == "" {
+			return errors.New(configUsage)
+		}
+		store, err := dbstore.Open(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create db: %w", err)
+		}
+		defer store.Close()
+		channels, err := store.GetGuildConfig(ctx, *guild)
+		if err != nil {
+			return err
+		}
+		raw, err := guildconfig.Marshal(guildconfig.Export(*guild, channels))
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(raw)
+		return err
+	case "apply":
+		if *file == "" {
+			return errors.New(configUsage)
+		}
+		raw, err := readConfigFile(*file)
+		if err != nil {
+			return err
+		}
+		cfg, err := guildconfig.Parse(raw)
+		if err != nil {
+			return err
+		}
+		store, err := dbstore.Open(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create db: %w", err)
+		}
+		defer store.Close()
+		plan, err := guildconfig.Diff(ctx, store, cfg)
+		if err != nil {
+			return err
+		}
+		if len(plan.Conflicts) > 0 {
+			for _, line := range plan.Conflicts {
+				fmt.Fprintln(out, line)
+			}
+			return fmt.Errorf("%d changes touch rules managed by the rules file; edit RULES_FILE instead", len(plan.Conflicts))
+		}
+		if len(plan.Changes) == 0 {
+			fmt.Fprintln(out, "nothing to apply")
+			return nil
+		}
+		for _, line := range plan.Changes {
+			fmt.Fprintln(out, line)
+		}
+		if *dryRun {
+			return nil
+		}
+		if err := plan.Apply(ctx, store); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d changes to guild %s\n", len(plan.Changes), plan.Guild)
+		return nil
+	}
+	return errors.New(configUsage)
+}
+
+func readConfigFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,234 @@
+// Package article fetches the page a Reddit link post points at and pulls
+// out what a narrative digest needs from it: the readable body text plus
+// the OpenGraph title, description and image.
+//
+// Body extraction is readability-style: paragraphs vote for the container
+// that holds them, containers are penalized for link-heavy text and for
+// class/id names that smell like chrome (comments, sidebars, promos), and
+// the best-scoring container's paragraphs become the text. Pages where no
+// container wins fall back to <article>, <main> or <body>.
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+
+	"github.com/meriley/reddit-spy/internal/safehttp"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+const (
+	// maxBodyBytes caps how much of a page is read. News pages with inline
+	// scripts run a few hundred KiB; the prompt only uses the first
+	// thousand tokens of text anyway.
+	maxBodyBytes = 2 << 20
+	// maxTextRunes caps the extracted text so cached rows stay small.
+	maxTextRunes = 20000
+)
+
+// ErrNotHTML is returned when the URL serves something other than an HTML
+// page (an image, a PDF, a video). Cacheable — the URL won't start serving
+// HTML later.
+var ErrNotHTML = errors.New("article: not an HTML page")
+
+// Article is what a page yields. Any field may be empty.
+type Article struct {
+	// URL is the page's final URL after redirects.
+	URL string
+	// Title, Description and Image come from OpenGraph tags, falling back
+	// to <title> and <meta name="description">. Image is absolute.
+	Title       string
+	Description string
+	Image       string
+	// Text is the readable body, paragraphs separated by blank lines.
+	Text string
+}
+
+type Client struct {
+	http *http.Client
+	ua   string
+}
+
+func New(timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{
+		// Link targets come from Reddit users; don't let them reach the
+		// cluster, directly or through a redirect.
+		http: safehttp.NewClient(timeout, nil),
+		// Plenty of news sites serve a bot wall to unknown UAs.
+		ua: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/127.0.0.0 Safari/537.36",
+	}
+}
+
+// skipHosts are link targets that never carry article text: Reddit's own
+// media and cross-post links, image hosts and video sites.
+var skipHosts = []string{
+	"reddit.com", "redd.it", "imgur.com", "gfycat.com", "redgifs.com",
+	"youtube.com", "youtu.be", "twitch.tv", "streamable.com",
+}
+
+// skipExts are file extensions that mark a direct media or document link.
+var skipExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".gifv": true,
+	".webp": true, ".mp4": true, ".webm": true, ".mp3": true, ".pdf": true,
+}
+
+// Fetchable reports whether rawURL is worth fetching for article text: an
+// http(s) URL that isn't a Reddit, image or video link.
+func Fetchable(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range skipHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return false
+		}
+	}
+	return !skipExts[strings.ToLower(path.Ext(u.Path))]
+}
+
+// Fetch downloads rawURL (at most maxBodyBytes of it), decodes it from the
+// charset the headers or <meta> tags declare, and extracts the article.
+// Returns ErrNotHTML for non-HTML responses. Pages on, or redirecting to,
+// a non-public address fail with safehttp.ErrBlocked.
+func (c *Client) Fetch(ctx context.Context, rawURL string) (*Article, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.ua)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("article: HTTP %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil &&
+		mt != "text/html" && mt != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+	return Parse(io.LimitReader(resp.Body, maxBodyBytes), contentType, resp.Request.URL)
+}
+
+// Parse extracts an article from an HTML document. contentType is the
+// response's Content-Type header (may be empty) and base the page URL,
+// used to resolve a relative og:image.
+func Parse(r io.Reader, contentType string, base *url.URL) (*Article, error) {
+	utf8Reader, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("article: decode charset: %w", err)
+	}
+	doc, err := html.Parse(utf8Reader)
+	if err != nil {
+		return nil, fmt.Errorf("article: parse html: %w", err)
+	}
+
+	a := &Article{Text: clipRunes(extractText(doc), maxTextRunes)}
+	if base != nil {
+		a.URL = base.String()
+	}
+	meta := readMeta(doc)
+	a.Title = firstNonEmpty(meta["og:title"], meta["twitter:title"], meta["title"])
+	a.Description = firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"])
+	a.Image = absoluteURL(base, firstNonEmpty(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"]))
+	return a, nil
+}
+
+// readMeta collects <meta property|name=… content=…> values, first one
+// wins, plus the document <title> under "title".
+func readMeta(doc *html.Node) map[string]string {
+	out := map[string]string{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				key := strings.ToLower(firstNonEmpty(attr(n, "property"), attr(n, "name")))
+				if val := strings.TrimSpace(attr(n, "content")); key != "" && val != "" {
+					if _, seen := out[key]; !seen {
+						out[key] = val
+					}
+				}
+			case "title":
+				if _, seen := out["title"]; !seen {
+					out["title"] = collapseSpace(nodeText(n))
+				}
+			case "body":
+				// OpenGraph lives in <head>; don't walk the whole page.
+				return
+			}
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// absoluteURL resolves ref against base and keeps it only if it's http(s).
+func absoluteURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func clipRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,165 @@
+package article
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/meriley/reddit-spy/internal/safehttp"
+)
+
+func TestFetchable(t *testing.T) {
+	cases := map[string]bool{
+		"https://www.theguardian.com/music/2026/apr/17/story": true,
+		"http://blog.example.org/post":                        true,
+		"https://www.reddit.com/r/Metalcore/comments/abc123/": false,
+		"https://i.redd.it/xyz.jpg":                           false,
+		"https://i.imgur.com/abc.gifv":                        false,
+		"https://youtu.be/dQw4w9WgXcQ":                        false,
+		"https://example.com/cover.PNG":                       false,
+		"https://example.com/report.pdf":                      false,
+		"ftp://example.com/file":                              false,
+		"":                                                    false,
+	}
+	for in, want := range cases {
+		if got := Fetchable(in); got != want {
+			t.Errorf("Fetchable(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+const longPara = "The band confirmed the dates on Thursday, adding that tickets go on sale Friday, with presale codes mailed to the fan club."
+
+func TestParse_PicksContentOverChrome(t *testing.T) {
+	page := `<html><head>
+		<title>Fallback title</title>
+		<meta property="og:title" content="Band announces tour">
+		<meta name="description" content="Meta description">
+		<meta property="og:image" content="/img/cover.jpg">
+		<script>var a = 1;</script>
+	</head><body>
+		<nav><p>Home · News · Sport · Music · Culture · Opinion</p></nav>
+		<div class="layout">
+			<div class="story-body">
+				<h1>Band announces  tour</h1>
+				<p>` + longPara + `</p>
+				<p>The run opens in <a href="/x">London</a> and closes in Paris,<br>six weeks later.</p>
+				<figure><p>Photo: someone took this photo of the band on stage</p></figure>
+			</div>
+			<div class="related-links">
+				<p><a href="/a">Another story about a completely different band, with commas</a></p>
+				<p><a href="/b">Yet another story about a completely different band, with commas</a></p>
+			</div>
+			<section id="comments"><p>` + longPara + `</p></section>
+		</div>
+		<footer><p>© 2026 Example News, all rights reserved, everywhere</p></footer>
+	</body></html>`
+	base, _ := url.Parse("https://news.example.com/music/story")
+	a, err := Parse(strings.NewReader(page), "text/html", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Band announces tour\n\n" + longPara + "\n\nThe run opens in London and closes in Paris, six weeks later."
+	if a.Text != want {
+		t.Errorf("Text =\n%q\nwant\n%q", a.Text, want)
+	}
+	if a.Title != "Band announces tour" || a.Description != "Meta description" {
+		t.Errorf("title/description = %q / %q", a.Title, a.Description)
+	}
+	if a.Image != "https://news.example.com/img/cover.jpg" {
+		t.Errorf("Image = %q, want it resolved against the page URL", a.Image)
+	}
+}
+
+func TestParse_FallsBackToBody(t *testing.T) {
+	a, err := Parse(strings.NewReader(`<title> Just a  title </title><body><header><p>menu</p></header><p>Only paragraph.</p></body>`), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Text != "Only paragraph." || a.Title != "Just a title" {
+		t.Errorf("got %+v", a)
+	}
+}
+
+func TestParse_DecodesDeclaredCharset(t *testing.T) {
+	// "Motörhead" in ISO-8859-1, declared only in a <meta> tag.
+	page := append([]byte(`<html><head><meta charset="iso-8859-1"></head><body><p>Mot`), 0xf6)
+	page = append(page, []byte(`rhead</p></body></html>`)...)
+	a, err := Parse(bytes.NewReader(page), "text/html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Text != "Motörhead" {
+		t.Errorf("Text = %q", a.Text)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/img":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+		case "/old":
+			http.Redirect(w, r, "/page", http.StatusMovedPermanently)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<main><p>Hello.</p></main>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(0)
+	c.http = safehttp.NewClient(0, func(netip.AddrPort) bool { return true })
+	if _, err := c.Fetch(context.Background(), srv.URL+"/img"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("err = %v, want ErrNotHTML", err)
+	}
+	if _, err := c.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("404 should error")
+	}
+	a, err := c.Fetch(context.Background(), srv.URL+"/old")
+	if err != nil || a.Text != "Hello." || a.URL != srv.URL+"/page" {
+		t.Errorf("Fetch = %+v, %v", a, err)
+	}
+}
+
+func TestFetch_BlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<main><p>Internal.</p></main>`))
+	}))
+	defer srv.Close()
+
+	if _, err := New(0).Fetch(context.Background(), srv.URL); !errors.Is(err, safehttp.ErrBlocked) {
+		t.Errorf("err = %v, want safehttp.ErrBlocked", err)
+	}
+}
+
+func TestFetch_BlocksRedirectToLoopback(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<main><p>Internal.</p></main>`))
+	}))
+	defer internal.Close()
+	// The redirecting server stands in for a public host: its port is the
+	// only one the client may dial.
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+	publicPort := netip.MustParseAddrPort(public.Listener.Addr().String()).Port()
+
+	c := New(0)
+	c.http = safehttp.NewClient(0, func(ap netip.AddrPort) bool { return ap.Port() == publicPort })
+	if _, err := c.Fetch(context.Background(), public.URL); !errors.Is(err, safehttp.ErrBlocked) {
+		t.Errorf("err = %v, want safehttp.ErrBlocked", err)
+	}
+}
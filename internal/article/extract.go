@@ -0,0 +1,198 @@
+package article
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipElems are subtrees that hold page chrome rather than content.
+var skipElems = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Svg: true, atom.Figure: true,
+	atom.Iframe: true, atom.Select: true,
+}
+
+// blockElems are the elements whose text becomes one paragraph.
+var blockElems = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.Li: true, atom.Blockquote: true, atom.Pre: true,
+}
+
+var (
+	// positiveHint and negativeHint match class/id names that suggest a
+	// container is (or isn't) the main content.
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text`)
+	negativeHint = regexp.MustCompile(`(?i)comment|footer|sidebar|sponsor|share|social|related|promo|newsletter|nav|menu|banner|cookie|subscribe|widget|\bad\b|ad-`)
+)
+
+const (
+	// minParagraphChars ignores captions, bylines and button labels when
+	// scoring.
+	minParagraphChars = 25
+	// minScoredChars is how much text the winning container must hold
+	// before it's trusted over the <article>/<main>/<body> fallback.
+	minScoredChars = 200
+)
+
+// extractText returns the readable text of doc: the paragraphs of the
+// best-scoring container, or of the first <article>, <main> or <body>
+// when scoring finds nothing substantial.
+func extractText(doc *html.Node) string {
+	if best := bestCandidate(doc); best != nil {
+		if text := paragraphs(best); utf8.RuneCountInString(text) >= minScoredChars {
+			return text
+		}
+	}
+	for _, a := range []atom.Atom{atom.Article, atom.Main, atom.Body} {
+		if root := findFirst(doc, a); root != nil {
+			return paragraphs(root)
+		}
+	}
+	return ""
+}
+
+// bestCandidate scores every container that directly holds a substantial
+// paragraph. Each paragraph adds 1 + its comma count + a length bonus to
+// its parent and half that to its grandparent; the totals are then scaled
+// by class/id hints and by the share of text that isn't link text.
+func bestCandidate(doc *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	var order []*html.Node
+	add := func(n *html.Node, v float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, seen := scores[n]; !seen {
+			order = append(order, n)
+		}
+		scores[n] += v
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if skipElems[n.DataAtom] {
+				return
+			}
+			if n.DataAtom != atom.Body && negativeHint.MatchString(attr(n, "class")+" "+attr(n, "id")) {
+				return
+			}
+			if n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote {
+				text := collapseSpace(nodeText(n))
+				if chars := utf8.RuneCountInString(text); chars >= minParagraphChars {
+					v := 1 + float64(strings.Count(text, ",")) + min(float64(chars)/100, 3)
+					add(n.Parent, v)
+					if n.Parent != nil {
+						add(n.Parent.Parent, v/2)
+					}
+				}
+				return
+			}
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range order {
+		s := scores[n]
+		hints := attr(n, "class") + " " + attr(n, "id")
+		if positiveHint.MatchString(hints) || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+			s *= 1.25
+		}
+		s *= 1 - linkDensity(n)
+		if s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	return best
+}
+
+// linkDensity is the fraction of n's text that sits inside <a> elements.
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(collapseSpace(nodeText(n)))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(collapseSpace(nodeText(n)))
+			return
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+// paragraphs joins the text of root's block elements with blank lines,
+// skipping chrome subtrees.
+func paragraphs(root *html.Node) string {
+	var paras []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && skipElems[n.DataAtom] {
+			return
+		}
+		if n.Type == html.ElementNode && blockElems[n.DataAtom] {
+			if text := collapseSpace(nodeText(n)); text != "" {
+				paras = append(paras, text)
+			}
+			return
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(root)
+	return strings.Join(paras, "\n\n")
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if found := findFirst(ch, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// nodeText concatenates the text under n, skipping chrome subtrees.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && skipElems[n.DataAtom]:
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			b.WriteByte(' ')
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -37,6 +37,16 @@ func New(ctx context.Context) RedditSpyCtx {
 	}
 }
 
+// WithContext returns a Ctx that carries inner's values, deadline and
+// cancellation with parent's logger — for layering a context.WithValue (or
+// WithTimeout) on top of an app Ctx without losing the logger.
+func WithContext(parent Ctx, inner context.Context) Ctx {
+	return RedditSpyCtx{
+		Context: inner,
+		log:     parent.Log(),
+	}
+}
+
 // levelOptionFromEnv reads LOG_LEVEL and maps it to a go-kit level filter.
 // Unknown or empty values fall through to "info" — production default.
 func levelOptionFromEnv() level.Option {
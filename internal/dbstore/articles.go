@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CachedArticle is one article_cache row: what the article extractor got
+// from a linked page. All-empty fields are a cached "nothing readable here"
+// outcome (the URL served an image, a PDF, …).
+type CachedArticle struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	Body        string
+	FetchedAt   time.Time
+}
+
+// GetCachedArticle returns the cached extraction for url, or (nil, nil) on
+// a cache miss. Callers apply their own TTL to FetchedAt.
+func (db *PGXStore) GetCachedArticle(parent context.Context, url string) (*CachedArticle, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var a CachedArticle
+	err := db.QueryRow(qctx,
+		`SELECT url, title, description, image_url, body, fetched_at FROM article_cache WHERE url = $1`,
+		url,
+	).Scan(&a.URL, &a.Title, &a.Description, &a.ImageURL, &a.Body, &a.FetchedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read article cache: %w", err)
+	}
+	return &a, nil
+}
+
+// UpsertCachedArticle writes an extraction keyed by a.URL, refreshing
+// fetched_at.
+func (db *PGXStore) UpsertCachedArticle(parent context.Context, a CachedArticle) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO article_cache (url, title, description, image_url, body, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT (url) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description,
+		    image_url = EXCLUDED.image_url, body = EXCLUDED.body, fetched_at = now()
+	`
+	if _, err := db.Exec(qctx, query, a.URL, a.Title, a.Description, a.ImageURL, a.Body); err != nil {
+		return fmt.Errorf("failed to upsert article cache: %w", err)
+	}
+	return nil
+}
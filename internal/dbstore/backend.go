@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	ctx "github.com/meriley/reddit-spy/internal/context"
+)
+
+const (
+	// EnvDBDriver selects the store backend: "postgres" (the default) or
+	// "sqlite".
+	EnvDBDriver = "DB_DRIVER"
+	// EnvSQLitePath is the SQLite database file when DB_DRIVER=sqlite.
+	EnvSQLitePath = "SQLITE_PATH"
+
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	DefaultSQLitePath = "reddit-spy.db"
+)
+
+// Backend is a Store plus the lifecycle the server and the migrate
+// subcommand need. PGXStore and SQLiteStore both implement it.
+type Backend interface {
+	Store
+
+	// Bootstrap brings the database to a runnable state: it applies pending
+	// migrations and any backend-specific setup. Safe on every start.
+	Bootstrap(ctx context.Context) error
+	MigrationStatus(ctx context.Context) ([]MigrationState, error)
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	MigrateDown(ctx context.Context, steps int) ([]Migration, error)
+	// EmbeddingStorage names how rule embeddings are stored, for logs.
+	EmbeddingStorage(ctx context.Context) (string, error)
+	Close()
+}
+
+var (
+	_ Backend = (*PGXStore)(nil)
+	_ Backend = (*SQLiteStore)(nil)
+)
+
+// Open connects to the backend DB_DRIVER selects, configured from the
+// environment.
+func Open(c ctx.Ctx) (Backend, error) {
+	switch driver := strings.ToLower(strings.TrimSpace(os.Getenv(EnvDBDriver))); driver {
+	case "", DriverPostgres:
+		store, err := New(c)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case DriverSQLite:
+		path := strings.TrimSpace(os.Getenv(EnvSQLitePath))
+		if path == "" {
+			path = DefaultSQLitePath
+		}
+		store, err := NewSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q: want %s or %s", EnvDBDriver, driver, DriverPostgres, DriverSQLite)
+	}
+}
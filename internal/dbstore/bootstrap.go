@@ -2,14 +2,12 @@ package database
 
 import (
 	"context"
-	_ "embed"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // EnvPostgresAdminURL, if set, points at a connection string with superuser
@@ -19,23 +17,23 @@ import (
 // + database already exist.
 const EnvPostgresAdminURL = "POSTGRES_ADMIN_URL"
 
-//go:embed sql/schema.sql
-var schemaSQL string
-
 // Bootstrap brings the target database to a runnable state. It's idempotent
 // and safe to call on every process start. Sequence:
 //
 //  1. If POSTGRES_ADMIN_URL is set, connect as the admin and ensure the app
-//     role + database exist (ensureRoleAndDatabase).
-//  2. Apply the embedded schema.sql (all statements are IF NOT EXISTS).
-func Bootstrap(ctx context.Context, pool *pgxpool.Pool, role, password, database string) error {
+//     role + database (POSTGRES_USER, POSTGRES_PASSWORD, POSTGRES_DATABASE)
+//     exist (ensureRoleAndDatabase).
+//  2. Apply pending migrations (MigrateUp) under the migration advisory
+//     lock.
+func (db *PGXStore) Bootstrap(ctx context.Context) error {
 	if adminURL := strings.TrimSpace(os.Getenv(EnvPostgresAdminURL)); adminURL != "" {
+		role, password, database := os.Getenv(EnvPostgresUser), os.Getenv(EnvPostgresPassword), os.Getenv(EnvPostgresDatabase)
 		if err := ensureRoleAndDatabase(ctx, adminURL, role, password, database); err != nil {
 			return fmt.Errorf("admin bootstrap: %w", err)
 		}
 	}
-	if _, err := pool.Exec(ctx, schemaSQL); err != nil {
-		return fmt.Errorf("apply schema: %w", err)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		return fmt.Errorf("migrate: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PostClassification is one post_classifications row: the LLM's labels for
+// a Reddit post, matched by classification rules.
+type PostClassification struct {
+	// PostID is the Reddit post id (e.g. "1abcde"), not posts.id, so a post
+	// can be classified before it's first stored.
+	PostID       string
+	Sentiment    string
+	Topics       []string
+	Toxic        bool
+	ClassifiedAt time.Time
+}
+
+// GetPostClassification returns the stored labels for postID, or (nil, nil)
+// when the post hasn't been classified.
+func (db *PGXStore) GetPostClassification(parent context.Context, postID string) (*PostClassification, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var pc PostClassification
+	err := db.QueryRow(qctx,
+		`SELECT post_id, sentiment, topics, toxic, classified_at FROM post_classifications WHERE post_id = $1`,
+		postID,
+	).Scan(&pc.PostID, &pc.Sentiment, &pc.Topics, &pc.Toxic, &pc.ClassifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read post classification: %w", err)
+	}
+	return &pc, nil
+}
+
+// UpsertPostClassification stores pc keyed by pc.PostID, refreshing
+// classified_at.
+func (db *PGXStore) UpsertPostClassification(parent context.Context, pc PostClassification) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	topics := pc.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	query := `
+		INSERT INTO post_classifications (post_id, sentiment, topics, toxic, classified_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (post_id) DO UPDATE
+		SET sentiment = EXCLUDED.sentiment, topics = EXCLUDED.topics,
+		    toxic = EXCLUDED.toxic, classified_at = now()
+	`
+	if _, err := db.Exec(qctx, query, pc.PostID, pc.Sentiment, topics, pc.Toxic); err != nil {
+		return fmt.Errorf("failed to upsert post classification: %w", err)
+	}
+	return nil
+}
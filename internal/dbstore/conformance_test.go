@@ -0,0 +1,69 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/dbstore/storetest"
+)
+
+// TestConformance runs the storetest suite against the backend DB_DRIVER
+// selects: SQLite in a temp file by default, or with DB_DRIVER=postgres the
+// database the POSTGRES_* variables point at. Every table in that database
+// is truncated between subtests.
+func TestConformance(t *testing.T) {
+	switch driver := os.Getenv(dbstore.EnvDBDriver); driver {
+	case "", dbstore.DriverSQLite:
+		storetest.Run(t, func(t *testing.T) dbstore.Store {
+			store, err := dbstore.NewSQLite(filepath.Join(t.TempDir(), "store.db"))
+			if err != nil {
+				t.Fatalf("NewSQLite: %v", err)
+			}
+			t.Cleanup(store.Close)
+			if err := store.Bootstrap(context.Background()); err != nil {
+				t.Fatalf("Bootstrap: %v", err)
+			}
+			return store
+		})
+	case dbstore.DriverPostgres:
+		if os.Getenv(dbstore.EnvPostgresURI) == "" {
+			t.Skipf("%s=postgres needs %s and friends", dbstore.EnvDBDriver, dbstore.EnvPostgresURI)
+		}
+		ctx := ctxpkg.New(context.Background())
+		store, err := dbstore.New(ctx)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		t.Cleanup(store.Close)
+		if err := store.Bootstrap(ctx); err != nil {
+			t.Fatalf("Bootstrap: %v", err)
+		}
+		storetest.Run(t, func(t *testing.T) dbstore.Store {
+			var tables string
+			if err := store.QueryRow(ctx, `
+				SELECT string_agg(quote_ident(tablename), ', ') FROM pg_tables
+				WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`,
+			).Scan(&tables); err != nil {
+				t.Fatalf("list tables: %v", err)
+			}
+			if _, err := store.Exec(ctx, `TRUNCATE `+tables+` RESTART IDENTITY CASCADE`); err != nil {
+				t.Fatalf("truncate: %v", err)
+			}
+			return store
+		})
+	default:
+		t.Fatalf("unknown %s %q", dbstore.EnvDBDriver, driver)
+	}
+}
+
+// TestConformance_MemStore holds the in-memory store to the same suite, so
+// tests built on it see what a real database would do.
+func TestConformance_MemStore(t *testing.T) {
+	storetest.Run(t, func(*testing.T) dbstore.Store {
+		return dbstore.NewMemStore()
+	})
+}
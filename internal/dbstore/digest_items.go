@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DigestItem is one digest_items row: a post folded into a narrative
+// digest, with what it's compared on to recognise later copies. Copies of
+// an item share its CanonicalPostID.
+type DigestItem struct {
+	RollingPostID   int
+	PostID          string
+	CanonicalPostID string
+	Subreddit       string
+	Title           string
+	CrosspostParent string
+	URL             string
+	TitleHash       uint64
+	Score           int
+	Comments        int
+	SeenAt          time.Time
+}
+
+// GetDigestItems returns rollingPostID's items, oldest first.
+func (db *PGXStore) GetDigestItems(parent context.Context, rollingPostID int) ([]DigestItem, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, `
+		SELECT rolling_post_id, post_id, canonical_post_id, subreddit, title,
+		       crosspost_parent, url, title_hash, score, comments, seen_at
+		FROM digest_items
+		WHERE rolling_post_id = $1
+		ORDER BY seen_at, post_id`,
+		rollingPostID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest items: %w", err)
+	}
+	defer rows.Close()
+
+	var out []DigestItem
+	for rows.Next() {
+		var it DigestItem
+		var hash int64
+		if err := rows.Scan(&it.RollingPostID, &it.PostID, &it.CanonicalPostID, &it.Subreddit, &it.Title,
+			&it.CrosspostParent, &it.URL, &hash, &it.Score, &it.Comments, &it.SeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan digest item: %w", err)
+		}
+		it.TitleHash = uint64(hash)
+		out = append(out, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read digest items: %w", err)
+	}
+	return out, nil
+}
+
+// InsertDigestItem records it, keeping the first row when the post is
+// already part of the digest.
+func (db *PGXStore) InsertDigestItem(parent context.Context, it DigestItem) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	canonical := it.CanonicalPostID
+	if canonical == "" {
+		canonical = it.PostID
+	}
+	// title_hash is BIGINT; the SimHash's top bit round-trips through the
+	// sign.
+	query := `
+		INSERT INTO digest_items (
+			rolling_post_id, post_id, canonical_post_id, subreddit, title,
+			crosspost_parent, url, title_hash, score, comments, seen_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())
+		ON CONFLICT (rolling_post_id, post_id) DO NOTHING
+	`
+	if _, err := db.Exec(qctx, query, it.RollingPostID, it.PostID, canonical, it.Subreddit, it.Title,
+		it.CrosspostParent, it.URL, int64(it.TitleHash), it.Score, it.Comments); err != nil {
+		return fmt.Errorf("failed to insert digest item: %w", err)
+	}
+	return nil
+}
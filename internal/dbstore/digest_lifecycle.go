@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CloseRollingPost ends a digest's window now, so the next match in its
+// channel and mode opens a fresh digest.
+func (db *PGXStore) CloseRollingPost(parent context.Context, id int) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(qctx, `UPDATE rolling_posts SET window_end = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to close rolling post %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rolling post %d not found", id)
+	}
+	return nil
+}
+
+// ReopenRollingPost keeps a digest open until until, whatever its rules'
+// window_hours say. While it is open it takes matches ahead of any digest
+// of its channel and mode that opened after it.
+func (db *PGXStore) ReopenRollingPost(parent context.Context, id int, until time.Time) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(qctx, `UPDATE rolling_posts SET window_end = $2 WHERE id = $1`, id, until)
+	if err != nil {
+		return fmt.Errorf("failed to reopen rolling post %d: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rolling post %d not found", id)
+	}
+	return nil
+}
+
+// MergeRollingPosts folds the digest fromID into into in one transaction:
+// into is written as given (the caller merges the content), fromID's
+// digest items move to into unless into already has the post, and fromID
+// is deleted. Both digests must be in the same channel. Returns into as
+// stored.
+func (db *PGXStore) MergeRollingPosts(parent context.Context, into RollingPost, fromID int) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(qctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin digest merge: %w", err)
+	}
+	defer func() { _ = tx.Rollback(qctx) }()
+
+	if _, err := tx.Exec(qctx, `
+		UPDATE digest_items SET rolling_post_id = $1
+		WHERE rolling_post_id = $2
+		  AND post_id NOT IN (SELECT post_id FROM digest_items WHERE rolling_post_id = $1)
+	`, into.ID, fromID); err != nil {
+		return nil, fmt.Errorf("failed to move digest items: %w", err)
+	}
+	tag, err := tx.Exec(qctx, `
+		DELETE FROM rolling_posts
+		WHERE id = $1 AND channel_id = (SELECT channel_id FROM rolling_posts WHERE id = $2)
+	`, fromID, into.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete merged rolling post: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("rolling post %d not found in the channel of %d", fromID, into.ID)
+	}
+	out, err := scanRollingPost(tx.QueryRow(qctx, updateRollingPostSQL, rollingPostUpdateArgs(into)...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("rolling post %d not found", into.ID)
+		}
+		return nil, fmt.Errorf("failed to update merged rolling post: %w", err)
+	}
+	if err := tx.Commit(qctx); err != nil {
+		return nil, fmt.Errorf("failed to commit digest merge: %w", err)
+	}
+	return out, nil
+}
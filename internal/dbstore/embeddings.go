@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RuleEmbedding is one rule_embeddings row: the vector for a semantic
+// rule's description.
+type RuleEmbedding struct {
+	RuleID    int
+	Model     string
+	Source    string
+	Embedding []float32
+	CreatedAt time.Time
+}
+
+// GetRuleEmbedding returns the stored embedding for ruleID, or (nil, nil)
+// when there is none. Callers compare Model and Source to decide whether
+// it's still current.
+func (db *PGXStore) GetRuleEmbedding(parent context.Context, ruleID int) (*RuleEmbedding, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var re RuleEmbedding
+	err := db.QueryRow(qctx,
+		`SELECT rule_id, model, source, embedding::real[], created_at FROM rule_embeddings WHERE rule_id = $1`,
+		ruleID,
+	).Scan(&re.RuleID, &re.Model, &re.Source, &re.Embedding, &re.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read rule embedding: %w", err)
+	}
+	return &re, nil
+}
+
+// UpsertRuleEmbedding stores re keyed by re.RuleID, replacing any previous
+// vector.
+func (db *PGXStore) UpsertRuleEmbedding(parent context.Context, re RuleEmbedding) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	// The REAL[] parameter is assignment-cast when the column is a pgvector
+	// vector.
+	query := `
+		INSERT INTO rule_embeddings (rule_id, model, source, embedding, created_at)
+		VALUES ($1, $2, $3, $4::real[], now())
+		ON CONFLICT (rule_id) DO UPDATE
+		SET model = EXCLUDED.model, source = EXCLUDED.source,
+		    embedding = EXCLUDED.embedding, created_at = now()
+	`
+	if _, err := db.Exec(qctx, query, re.RuleID, re.Model, re.Source, re.Embedding); err != nil {
+		return fmt.Errorf("failed to upsert rule embedding: %w", err)
+	}
+	return nil
+}
+
+// EmbeddingStorage reports how rule_embeddings.embedding is stored:
+// "vector" with pgvector, "real[]" without.
+func (db *PGXStore) EmbeddingStorage(parent context.Context) (string, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+	return embeddingColumnType(qctx, db.Pool)
+}
+
+func embeddingColumnType(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	var typ string
+	err := pool.QueryRow(ctx, `
+		SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+		WHERE attrelid = 'rule_embeddings'::regclass AND attname = 'embedding'`,
+	).Scan(&typ)
+	if err != nil {
+		return "", fmt.Errorf("failed to read embedding column type: %w", err)
+	}
+	return typ, nil
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Votes the digest feedback buttons record. Down and irrelevant both count
+// against a rule; irrelevant says the rule shouldn't have matched at all.
+const (
+	VoteUp         = "up"
+	VoteDown       = "down"
+	VoteIrrelevant = "irrelevant"
+)
+
+// IsNegativeVote reports whether vote counts against a rule.
+func IsNegativeVote(vote string) bool {
+	return vote == VoteDown || vote == VoteIrrelevant
+}
+
+// Feedback is one rule_feedback row: a user's vote on a post a rule matched.
+// PostID is the Reddit id. Title is the post's title as the vote recorded it.
+type Feedback struct {
+	PostID    string
+	RuleID    int
+	UserID    string
+	Vote      string
+	Title     string
+	CreatedAt time.Time
+}
+
+// RuleStats is one rule's matches and votes for /rule_stats. Matches counts
+// the notifications retention hasn't pruned yet; votes last as long as the
+// rule, since retention keeps every post that has one.
+type RuleStats struct {
+	RuleID     int
+	Matches    int
+	Up         int
+	Down       int
+	Irrelevant int
+}
+
+// normalizeTerms trims, lowercases and deduplicates exclusion terms,
+// dropping empty ones, in their first-seen order. Never nil, so the
+// NOT NULL array column gets '{}'.
+func normalizeTerms(terms []string) []string {
+	out := []string{}
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+const (
+	notifiedRulesSQL = `
+		SELECT n.rule_id
+		FROM notifications n
+			JOIN posts p ON p.id = n.post_id
+		WHERE n.channel_id = $1 AND p.post_id = lower($2)
+		ORDER BY n.rule_id
+	`
+	ruleStatsSQL = `
+		SELECT r.id,
+		       (SELECT COUNT(*) FROM notifications n WHERE n.rule_id = r.id),
+		       COALESCE(SUM(CASE WHEN f.vote = 'up' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN f.vote = 'down' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN f.vote = 'irrelevant' THEN 1 ELSE 0 END), 0)
+		FROM rules r
+			LEFT JOIN rule_feedback f ON f.rule_id = r.id
+		WHERE r.channel_id = $1
+		GROUP BY r.id
+		ORDER BY r.id
+	`
+	ruleFeedbackCols = `p.post_id, f.rule_id, f.user_id, f.vote, f.title, f.created_at`
+	ruleFeedbackFrom = `
+		FROM rule_feedback f
+			JOIN posts p ON p.id = f.post_id
+		WHERE f.rule_id = $1
+		ORDER BY f.created_at, f.id
+	`
+)
+
+func validVote(vote string) error {
+	switch vote {
+	case VoteUp, VoteDown, VoteIrrelevant:
+		return nil
+	}
+	return fmt.Errorf("unknown vote %q", vote)
+}
+
+// RecordFeedback stores fb, replacing the user's earlier vote on the same
+// post and rule. The post must already be in posts, as every matched one is.
+func (db *PGXStore) RecordFeedback(parent context.Context, fb Feedback) error {
+	if err := validVote(fb.Vote); err != nil {
+		return err
+	}
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(qctx, `
+		INSERT INTO rule_feedback (post_id, rule_id, user_id, vote, title)
+		SELECT p.id, $2, $3, $4, $5 FROM posts p WHERE p.post_id = lower($1)
+		ON CONFLICT (post_id, rule_id, user_id)
+		DO UPDATE SET vote = EXCLUDED.vote, title = EXCLUDED.title, created_at = now()
+	`, fb.PostID, fb.RuleID, fb.UserID, fb.Vote, fb.Title)
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("post %s not found", fb.PostID)
+	}
+	return nil
+}
+
+// GetNotifiedRules returns the ids of the rules that notified channelID of
+// the Reddit post postID, ascending.
+func (db *PGXStore) GetNotifiedRules(parent context.Context, channelID int, postID string) ([]int, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, notifiedRulesSQL, channelID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notified rules: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan notified rule: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating notified rules: %w", err)
+	}
+	return ids, nil
+}
+
+// GetRuleStats returns the stats of every rule in channelID, by rule id.
+func (db *PGXStore) GetRuleStats(parent context.Context, channelID int) ([]RuleStats, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, ruleStatsSQL, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule stats: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RuleStats
+	for rows.Next() {
+		var st RuleStats
+		if err := rows.Scan(&st.RuleID, &st.Matches, &st.Up, &st.Down, &st.Irrelevant); err != nil {
+			return nil, fmt.Errorf("failed to scan rule stats: %w", err)
+		}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule stats: %w", err)
+	}
+	return out, nil
+}
+
+// ListRuleFeedback returns ruleID's votes, oldest first.
+func (db *PGXStore) ListRuleFeedback(parent context.Context, ruleID int) ([]Feedback, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, `SELECT `+ruleFeedbackCols+ruleFeedbackFrom, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Feedback
+	for rows.Next() {
+		var fb Feedback
+		if err := rows.Scan(&fb.PostID, &fb.RuleID, &fb.UserID, &fb.Vote, &fb.Title, &fb.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rule feedback: %w", err)
+		}
+		out = append(out, fb)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule feedback: %w", err)
+	}
+	return out, nil
+}
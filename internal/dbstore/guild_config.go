@@ -0,0 +1,314 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GuildChannel is one of a guild's channels as a config export carries it:
+// its own prompt settings, unresolved, and its rules.
+type GuildChannel struct {
+	ExternalID     string
+	PromptTemplate string
+	Tone           string
+	Language       string
+	Rules          []GuildRule
+}
+
+// GuildRule is a rule as a config export carries it: the subreddit by name
+// and the rule's own prompt overrides. ApplyGuildConfig creates a rule whose
+// ID is 0 and updates the rule with that ID otherwise. Managed is read-only:
+// only ReconcileManagedRules sets it.
+type GuildRule struct {
+	ID             int
+	Subreddit      string
+	TargetID       string
+	Target         string
+	Exact          bool
+	Mode           string
+	WindowHours    int
+	Threshold      float64
+	ExcludeTerms   []string
+	QuietHours     string
+	PromptTemplate string
+	Tone           string
+	Managed        bool
+}
+
+// ManagedGuild is one guild's part of the rules file.
+type ManagedGuild struct {
+	ServerID string
+	Channels []GuildChannel
+}
+
+const (
+	guildChannelsSQL = `
+		SELECT dc.id, dc.channel_id, dc.prompt_template, dc.tone, dc.language
+		FROM discord_channels dc
+			JOIN discord_servers ds ON dc.server_id = ds.id
+		WHERE ds.server_id = lower($1)
+		ORDER BY dc.channel_id
+	`
+	guildRulesSQL = `
+		SELECT r.id, r.channel_id, sr.subreddit_id, r.target_id, r.target, r.exact,
+		       r.mode, r.window_hours, r.threshold, r.exclude_terms, r.prompt_template, r.tone, r.managed,
+		       r.quiet_hours
+		FROM rules r
+			JOIN subreddits sr ON r.subreddit_id = sr.id
+			JOIN discord_channels dc ON r.channel_id = dc.id
+			JOIN discord_servers ds ON dc.server_id = ds.id
+		WHERE ds.server_id = lower($1)
+		ORDER BY r.id
+	`
+	// Rule updates are scoped to the channel so a config can't reach a
+	// rule of another guild by id. A rule once managed stays managed until
+	// the reconciler deletes it.
+	updateGuildRuleSQL = `
+		UPDATE rules SET exact = $3, mode = $4, window_hours = $5, threshold = $6,
+		                 prompt_template = $7, tone = $8, managed = (managed OR $9), exclude_terms = $10,
+		                 quiet_hours = $11
+		WHERE id = $1 AND channel_id = $2
+	`
+	updateGuildChannelSQL = `
+		UPDATE discord_channels SET prompt_template = $2, tone = $3, language = $4 WHERE id = $1
+	`
+)
+
+// guildRuleRow is one guildRulesSQL row: the rule and its channel's id.
+type guildRuleRow struct {
+	channelID int
+	rule      GuildRule
+}
+
+// scanTargets are the row's scan destinations; terms is the one for
+// exclude_terms, whose column type differs between the backends.
+func (r *guildRuleRow) scanTargets(terms any) []any {
+	return []any{
+		&r.rule.ID, &r.channelID, &r.rule.Subreddit, &r.rule.TargetID, &r.rule.Target, &r.rule.Exact,
+		&r.rule.Mode, &r.rule.WindowHours, &r.rule.Threshold, terms, &r.rule.PromptTemplate, &r.rule.Tone,
+		&r.rule.Managed, &r.rule.QuietHours,
+	}
+}
+
+// groupGuildRules attaches rules to their channels, which are keyed by id.
+func groupGuildRules(channels []GuildChannel, ids []int, rules []guildRuleRow) []GuildChannel {
+	index := make(map[int]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+	for _, r := range rules {
+		// An empty array column reads back as a non-nil slice on some
+		// backends; an export shouldn't depend on which.
+		if len(r.rule.ExcludeTerms) == 0 {
+			r.rule.ExcludeTerms = nil
+		}
+		if i, ok := index[r.channelID]; ok {
+			channels[i].Rules = append(channels[i].Rules, r.rule)
+		}
+	}
+	return channels
+}
+
+// prepareGuildRule fills in r's schema defaults, as InsertRule does, and
+// rejects a new rule missing what identifies it.
+func prepareGuildRule(channelID string, r GuildRule) (GuildRule, error) {
+	if r.ID == 0 && (r.Subreddit == "" || r.TargetID == "" || r.Target == "") {
+		return r, fmt.Errorf("new rule in channel %s needs a subreddit, target and value", channelID)
+	}
+	if r.Mode == "" {
+		r.Mode = ModeNarrative
+	}
+	if r.WindowHours <= 0 {
+		r.WindowHours = 72
+	}
+	r.ExcludeTerms = normalizeTerms(r.ExcludeTerms)
+	return r, nil
+}
+
+// GetGuildConfig returns every channel serverID has registered, ordered by
+// channel id, with its rules ordered by id. An unknown server has none.
+func (db *PGXStore) GetGuildConfig(parent context.Context, serverID string) ([]GuildChannel, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, guildChannelsSQL, serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query guild channels: %w", err)
+	}
+	var (
+		channels []GuildChannel
+		ids      []int
+	)
+	for rows.Next() {
+		var (
+			id int
+			ch GuildChannel
+		)
+		if err := rows.Scan(&id, &ch.ExternalID, &ch.PromptTemplate, &ch.Tone, &ch.Language); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan guild channel row: %w", err)
+		}
+		channels = append(channels, ch)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating guild channel rows: %w", err)
+	}
+
+	rows, err = db.Query(qctx, guildRulesSQL, serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query guild rules: %w", err)
+	}
+	defer rows.Close()
+	var rules []guildRuleRow
+	for rows.Next() {
+		var r guildRuleRow
+		if err := rows.Scan(r.scanTargets(&r.rule.ExcludeTerms)...); err != nil {
+			return nil, fmt.Errorf("failed to scan guild rule row: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating guild rule rows: %w", err)
+	}
+	return groupGuildRules(channels, ids, rules), nil
+}
+
+// ApplyGuildConfig makes serverID's channels and rules match channels in
+// one transaction: channels are registered and their prompt settings
+// replaced, rules with an ID updated, rules without one created, and every
+// other rule in any of the guild's channels deleted. Channels the guild
+// has but channels leaves out keep their settings and lose their rules. A
+// channel registered to another guild, or a rule ID that isn't in its
+// channel, fails the whole call.
+func (db *PGXStore) ApplyGuildConfig(parent context.Context, serverID string, channels []GuildChannel) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(qctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin guild config: %w", err)
+	}
+	defer func() { _ = tx.Rollback(qctx) }()
+
+	guildID, keep, err := applyGuildPGX(qctx, tx, serverID, channels, false)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(qctx, `
+		DELETE FROM rules
+		WHERE channel_id IN (SELECT id FROM discord_channels WHERE server_id = $1)
+		  AND NOT (id = ANY($2))
+	`, guildID, keep); err != nil {
+		return fmt.Errorf("failed to delete rules: %w", err)
+	}
+	if err := tx.Commit(qctx); err != nil {
+		return fmt.Errorf("failed to commit guild config: %w", err)
+	}
+	return nil
+}
+
+// ReconcileManagedRules applies every guild as ApplyGuildConfig would, in
+// one transaction, except that the rules it writes are marked managed and
+// the rules it deletes are the managed ones, in any guild, that guilds no
+// longer lists. Rules made by hand are left alone unless guilds lists
+// them, which makes them managed. Returns how many rules were deleted.
+func (db *PGXStore) ReconcileManagedRules(parent context.Context, guilds []ManagedGuild) (int64, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(qctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin rules reconcile: %w", err)
+	}
+	defer func() { _ = tx.Rollback(qctx) }()
+
+	keep := []int{}
+	for _, g := range guilds {
+		_, kept, err := applyGuildPGX(qctx, tx, g.ServerID, g.Channels, true)
+		if err != nil {
+			return 0, fmt.Errorf("guild %s: %w", g.ServerID, err)
+		}
+		keep = append(keep, kept...)
+	}
+	tag, err := tx.Exec(qctx, `DELETE FROM rules WHERE managed AND NOT (id = ANY($1))`, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete managed rules: %w", err)
+	}
+	if err := tx.Commit(qctx); err != nil {
+		return 0, fmt.Errorf("failed to commit rules reconcile: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// applyGuildPGX registers serverID and channels and writes their rules in
+// tx, returning the guild's id and the ids of the rules written.
+func applyGuildPGX(qctx context.Context, tx pgx.Tx, serverID string, channels []GuildChannel, managed bool) (int, []int, error) {
+	var guildID int
+	if err := tx.QueryRow(qctx, `
+		INSERT INTO discord_servers (server_id) VALUES (lower($1))
+		ON CONFLICT (server_id) DO UPDATE SET server_id = EXCLUDED.server_id
+		RETURNING id
+	`, serverID).Scan(&guildID); err != nil {
+		return 0, nil, fmt.Errorf("failed to insert server: %w", err)
+	}
+
+	keep := []int{}
+	for _, ch := range channels {
+		var channelID, owner int
+		if err := tx.QueryRow(qctx, `
+			INSERT INTO discord_channels (channel_id, server_id) VALUES (lower($1), $2)
+			ON CONFLICT (channel_id) DO UPDATE SET channel_id = EXCLUDED.channel_id
+			RETURNING id, server_id
+		`, ch.ExternalID, guildID).Scan(&channelID, &owner); err != nil {
+			return 0, nil, fmt.Errorf("failed to insert channel %s: %w", ch.ExternalID, err)
+		}
+		if owner != guildID {
+			return 0, nil, fmt.Errorf("channel %s belongs to another server", ch.ExternalID)
+		}
+		if _, err := tx.Exec(qctx, updateGuildChannelSQL, channelID, ch.PromptTemplate, ch.Tone, ch.Language); err != nil {
+			return 0, nil, fmt.Errorf("failed to update channel %s: %w", ch.ExternalID, err)
+		}
+
+		for _, r := range ch.Rules {
+			r, err := prepareGuildRule(ch.ExternalID, r)
+			if err != nil {
+				return 0, nil, err
+			}
+			if r.ID != 0 {
+				tag, err := tx.Exec(qctx, updateGuildRuleSQL,
+					r.ID, channelID, r.Exact, r.Mode, r.WindowHours, r.Threshold, r.PromptTemplate, r.Tone, managed, r.ExcludeTerms,
+					r.QuietHours)
+				if err != nil {
+					return 0, nil, fmt.Errorf("failed to update rule %d: %w", r.ID, err)
+				}
+				if tag.RowsAffected() == 0 {
+					return 0, nil, fmt.Errorf("rule %d not found in channel %s", r.ID, ch.ExternalID)
+				}
+				keep = append(keep, r.ID)
+				continue
+			}
+			var subredditID, ruleID int
+			if err := tx.QueryRow(qctx, `
+				INSERT INTO subreddits (subreddit_id) VALUES (lower($1))
+				ON CONFLICT (subreddit_id) DO UPDATE SET subreddit_id = EXCLUDED.subreddit_id
+				RETURNING id
+			`, r.Subreddit).Scan(&subredditID); err != nil {
+				return 0, nil, fmt.Errorf("failed to insert subreddit %s: %w", r.Subreddit, err)
+			}
+			if err := tx.QueryRow(qctx, `
+				INSERT INTO rules (target, target_id, exact, channel_id, subreddit_id, mode, window_hours,
+				                   threshold, prompt_template, tone, managed, exclude_terms, quiet_hours)
+				VALUES (lower($1), lower($2), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id
+			`, r.Target, r.TargetID, r.Exact, channelID, subredditID, r.Mode, r.WindowHours,
+				r.Threshold, r.PromptTemplate, r.Tone, managed, r.ExcludeTerms, r.QuietHours).Scan(&ruleID); err != nil {
+				return 0, nil, fmt.Errorf("failed to insert rule: %w", err)
+			}
+			keep = append(keep, ruleID)
+		}
+	}
+	return guildID, keep, nil
+}
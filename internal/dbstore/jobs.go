@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Job kinds and statuses for llm_jobs.
+const (
+	JobKindMusicExtract = "music_extract"
+	// JobKindDeliverMatch holds a match that arrived in its rule's quiet
+	// hours until they end.
+	JobKindDeliverMatch = "deliver_match"
+
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// jobLease is how long a claimed job may stay "running" before another
+// claim treats its worker as dead (pod restart mid-shape) and takes it over.
+const jobLease = 10 * time.Minute
+
+// LLMJob is one deferred shaping task. Payload is kind-specific JSON; for
+// music_extract and deliver_match it's the Reddit post snapshot so a retry
+// doesn't depend on the post still being in the listing.
+type LLMJob struct {
+	ID            int
+	Kind          string
+	ChannelID     int
+	RuleID        int
+	PostID        int
+	Payload       []byte
+	Status        string
+	Attempts      int
+	MaxAttempts   int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const llmJobCols = `
+	id, kind, channel_id, rule_id, post_id, payload,
+	status, attempts, max_attempts, next_attempt_at,
+	last_error, created_at, updated_at`
+
+func scanLLMJob(row pgx.Row) (*LLMJob, error) {
+	var j LLMJob
+	if err := row.Scan(
+		&j.ID, &j.Kind, &j.ChannelID, &j.RuleID, &j.PostID, &j.Payload,
+		&j.Status, &j.Attempts, &j.MaxAttempts, &j.NextAttemptAt,
+		&j.LastError, &j.CreatedAt, &j.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// EnqueueLLMJob inserts a pending job due at nextAttemptAt. A job for the
+// same (kind, channel, post) that already exists is left as-is and returned,
+// so a duplicate enqueue is harmless. deliver_match jobs are keyed by rule
+// too, since each holds one rule's match.
+func (db *PGXStore) EnqueueLLMJob(parent context.Context, job LLMJob) (*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = time.Now().UTC()
+	}
+	query := `
+		INSERT INTO llm_jobs (kind, channel_id, rule_id, post_id, payload, max_attempts, next_attempt_at, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (kind, channel_id, post_id, rule_key) DO UPDATE SET kind = EXCLUDED.kind
+		RETURNING ` + llmJobCols
+	j, err := scanLLMJob(db.QueryRow(qctx, query,
+		job.Kind, job.ChannelID, job.RuleID, job.PostID, job.Payload,
+		job.MaxAttempts, job.NextAttemptAt, job.LastError,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue llm job: %w", err)
+	}
+	return j, nil
+}
+
+// ClaimDueLLMJobs marks up to limit due jobs as running, bumps their attempt
+// count, and returns them. Jobs whose previous claim outlived jobLease are
+// reclaimed. SKIP LOCKED keeps two replicas from claiming the same row.
+func (db *PGXStore) ClaimDueLLMJobs(parent context.Context, limit int) ([]*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE llm_jobs SET
+			status     = 'running',
+			attempts   = attempts + 1,
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM llm_jobs
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+			   OR (status = 'running' AND updated_at < now() - make_interval(secs => $2))
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + llmJobCols
+	rows, err := db.Query(qctx, query, limit, jobLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim llm jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*LLMJob
+	for rows.Next() {
+		j, err := scanLLMJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan llm job row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating llm job rows: %w", err)
+	}
+	return jobs, nil
+}
+
+// CompleteLLMJob marks a job done.
+func (db *PGXStore) CompleteLLMJob(parent context.Context, jobID int) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `UPDATE llm_jobs SET status = 'done', last_error = '', updated_at = now() WHERE id = $1`
+	if _, err := db.Exec(qctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to complete llm job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+// FailLLMJob records a failed attempt. A zero retryAt marks the job failed
+// for good (attempts exhausted); otherwise it goes back to pending, due at
+// retryAt.
+func (db *PGXStore) FailLLMJob(parent context.Context, jobID int, lastError string, retryAt time.Time) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var err error
+	if retryAt.IsZero() {
+		query := `UPDATE llm_jobs SET status = 'failed', last_error = $2, updated_at = now() WHERE id = $1`
+		_, err = db.Exec(qctx, query, jobID, lastError)
+	} else {
+		query := `UPDATE llm_jobs SET status = 'pending', last_error = $2, next_attempt_at = $3, updated_at = now() WHERE id = $1`
+		_, err = db.Exec(qctx, query, jobID, lastError, retryAt)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record llm job %d failure: %w", jobID, err)
+	}
+	return nil
+}
+
+// ListFailedLLMJobs returns a channel's jobs that exhausted their attempts,
+// newest first.
+func (db *PGXStore) ListFailedLLMJobs(parent context.Context, channelID int, limit int) ([]*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + llmJobCols + `
+		FROM llm_jobs
+		WHERE channel_id = $1 AND status = 'failed'
+		ORDER BY updated_at DESC
+		LIMIT $2
+	`
+	rows, err := db.Query(qctx, query, channelID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed llm jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*LLMJob
+	for rows.Next() {
+		j, err := scanLLMJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan llm job row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating llm job rows: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetLLMJob returns a job by id, or (nil, nil) if it doesn't exist.
+func (db *PGXStore) GetLLMJob(parent context.Context, jobID int) (*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	j, err := scanLLMJob(db.QueryRow(qctx, `SELECT `+llmJobCols+` FROM llm_jobs WHERE id = $1`, jobID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get llm job %d: %w", jobID, err)
+	}
+	return j, nil
+}
+
+// RequeueLLMJob resets a failed job to pending with a fresh attempt budget,
+// due immediately. Returns false if the job isn't in the failed state.
+func (db *PGXStore) RequeueLLMJob(parent context.Context, jobID int) (bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE llm_jobs SET
+			status          = 'pending',
+			attempts        = 0,
+			next_attempt_at = now(),
+			updated_at      = now()
+		WHERE id = $1 AND status = 'failed'
+	`
+	tag, err := db.Exec(qctx, query, jobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue llm job %d: %w", jobID, err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,1500 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemStore is a Store held in memory, for tests. It keeps the SQL stores'
+// semantics — lowercased external ids, idempotent inserts, rolling-post
+// window expiry, one notification per (post, channel, rule) — and passes
+// the same storetest conformance suite. It doesn't check foreign keys, so
+// fixtures may reference ids that were never inserted; deleting a rule still
+// removes the rows that reference it, as ON DELETE CASCADE does.
+//
+// Every method returns copies; callers can't reach the stored rows.
+type MemStore struct {
+	// Now stands in for time.Now when set, so window expiry and job
+	// schedules follow a test's clock. Set it before sharing the store.
+	Now func() time.Time
+
+	mu sync.Mutex
+
+	ids           map[string]int // last id handed out, per table
+	servers       map[int]DiscordServer
+	channels      map[int]*memChannel
+	subreddits    map[int]Subreddit
+	posts         map[int]memPost
+	rules         map[int]*memRule
+	notifications map[[3]int]memNotification // post, channel, rule
+	rollingPosts  map[int]*RollingPost
+	digestItems   map[int][]DigestItem // by rolling post
+	feedback      map[memFeedbackKey]memFeedback
+
+	lastfm          map[string]memLastfm
+	piped           map[string]memCached
+	qobuz           map[string]memCached
+	articles        map[string]CachedArticle
+	completions     map[string]memCached
+	classifications map[string]PostClassification
+	embeddings      map[int]RuleEmbedding
+
+	jobs      map[int]*LLMJob
+	templates map[[2]string]PromptTemplate // name, kind
+}
+
+type memChannel struct {
+	DiscordChannel
+	serverID                 int
+	template, tone, language string
+}
+
+type memRule struct {
+	Rule
+	template, tone string
+	managed        bool
+}
+
+type memFeedbackKey struct {
+	post, rule int
+	user       string
+}
+
+// memFeedback keeps its insert order so ties on CreatedAt sort like id.
+type memFeedback struct {
+	Feedback
+	seq int
+}
+
+type memPost struct {
+	Post
+	createdAt time.Time
+}
+
+type memNotification struct {
+	Notification
+	createdAt time.Time
+}
+
+type memLastfm struct {
+	listeners int
+	tags      []string
+	fetchedAt time.Time
+}
+
+type memCached struct {
+	value     string
+	fetchedAt time.Time
+}
+
+var _ Store = (*MemStore)(nil)
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		ids:             map[string]int{},
+		servers:         map[int]DiscordServer{},
+		channels:        map[int]*memChannel{},
+		subreddits:      map[int]Subreddit{},
+		posts:           map[int]memPost{},
+		rules:           map[int]*memRule{},
+		notifications:   map[[3]int]memNotification{},
+		rollingPosts:    map[int]*RollingPost{},
+		digestItems:     map[int][]DigestItem{},
+		feedback:        map[memFeedbackKey]memFeedback{},
+		lastfm:          map[string]memLastfm{},
+		piped:           map[string]memCached{},
+		qobuz:           map[string]memCached{},
+		articles:        map[string]CachedArticle{},
+		completions:     map[string]memCached{},
+		classifications: map[string]PostClassification{},
+		embeddings:      map[int]RuleEmbedding{},
+		jobs:            map[int]*LLMJob{},
+		templates:       map[[2]string]PromptTemplate{},
+	}
+}
+
+func (m *MemStore) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// nextID is table's serial id column.
+func (m *MemStore) nextID(table string) int {
+	m.ids[table]++
+	return m.ids[table]
+}
+
+// sortedKeys returns a map's keys in ascending order, the order the SQL
+// stores return rows that have no ORDER BY.
+func sortedKeys[K cmp.Ordered, V any](rows map[K]V) []K {
+	keys := make([]K, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (m *MemStore) InsertDiscordServer(_ context.Context, serverID string) (*DiscordServer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	extID := strings.ToLower(serverID)
+	for _, s := range m.servers {
+		if s.ExternalID == extID {
+			return &s, nil
+		}
+	}
+	s := DiscordServer{ID: m.nextID("discord_servers"), ExternalID: extID}
+	m.servers[s.ID] = s
+	return &s, nil
+}
+
+func (m *MemStore) GetDiscordServerByExternalID(_ context.Context, serverID string) (*DiscordServer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	extID := strings.ToLower(serverID)
+	for _, s := range m.servers {
+		if s.ExternalID == extID {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get discord server %q: %w", serverID, sql.ErrNoRows)
+}
+
+func (m *MemStore) InsertDiscordChannel(_ context.Context, channelID string, serverID int) (*DiscordChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ch := m.channelByExternalID(channelID); ch != nil {
+		c := ch.DiscordChannel
+		return &c, nil
+	}
+	ch := &memChannel{DiscordChannel: DiscordChannel{ID: m.nextID("discord_channels"), ExternalID: strings.ToLower(channelID)}, serverID: serverID}
+	m.channels[ch.ID] = ch
+	c := ch.DiscordChannel
+	return &c, nil
+}
+
+func (m *MemStore) channelByExternalID(channelID string) *memChannel {
+	extID := strings.ToLower(channelID)
+	for _, ch := range m.channels {
+		if ch.ExternalID == extID {
+			return ch
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) GetDiscordChannel(_ context.Context, channelID int) (*DiscordChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, ok := m.channels[channelID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get discord channel %d: %w", channelID, sql.ErrNoRows)
+	}
+	c := ch.DiscordChannel
+	return &c, nil
+}
+
+func (m *MemStore) GetDiscordChannelByExternalID(_ context.Context, channelID string) (*DiscordChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := m.channelByExternalID(channelID)
+	if ch == nil {
+		return nil, fmt.Errorf("failed to get discord channel %q: %w", channelID, sql.ErrNoRows)
+	}
+	c := ch.DiscordChannel
+	return &c, nil
+}
+
+func (m *MemStore) InsertNotification(_ context.Context, postID, channelID, ruleID int) (*Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [3]int{postID, channelID, ruleID}
+	if n, ok := m.notifications[key]; ok {
+		return &n.Notification, nil
+	}
+	n := Notification{ID: m.nextID("notifications"), PostID: postID, ChannelID: channelID, RuleID: ruleID}
+	m.notifications[key] = memNotification{Notification: n, createdAt: m.now()}
+	return &n, nil
+}
+
+func (m *MemStore) GetNotificationCount(_ context.Context, postID, channelID, ruleID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.notifications[[3]int{postID, channelID, ruleID}]; ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *MemStore) InsertPost(_ context.Context, postID string) (*Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	extID := strings.ToLower(postID)
+	for _, p := range m.posts {
+		if p.ExternalID == extID {
+			return &p.Post, nil
+		}
+	}
+	p := Post{ID: m.nextID("posts"), ExternalID: extID}
+	m.posts[p.ID] = memPost{Post: p, createdAt: m.now()}
+	return &p, nil
+}
+
+func (m *MemStore) InsertSubreddit(_ context.Context, subredditID string) (*Subreddit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sr, ok := m.subredditByExternalID(subredditID); ok {
+		return &sr, nil
+	}
+	sr := Subreddit{ID: m.nextID("subreddits"), ExternalID: strings.ToLower(subredditID)}
+	m.subreddits[sr.ID] = sr
+	return &sr, nil
+}
+
+func (m *MemStore) subredditByExternalID(subredditID string) (Subreddit, bool) {
+	extID := strings.ToLower(subredditID)
+	for _, sr := range m.subreddits {
+		if sr.ExternalID == extID {
+			return sr, true
+		}
+	}
+	return Subreddit{}, false
+}
+
+func (m *MemStore) GetSubredditByExternalID(_ context.Context, subredditID string) (*Subreddit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sr, ok := m.subredditByExternalID(subredditID)
+	if !ok {
+		return nil, fmt.Errorf("failed to get subreddit %q: %w", subredditID, sql.ErrNoRows)
+	}
+	return &sr, nil
+}
+
+func (m *MemStore) GetSubreddits(_ context.Context) ([]*Subreddit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*Subreddit
+	for _, id := range sortedKeys(m.subreddits) {
+		sr := m.subreddits[id]
+		out = append(out, &sr)
+	}
+	return out, nil
+}
+
+func (m *MemStore) GetActiveSubreddits(_ context.Context) ([]*Subreddit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active := make(map[int]bool)
+	now := m.now()
+	for _, r := range m.rules {
+		if !r.Paused(now) {
+			active[r.SubredditID] = true
+		}
+	}
+	var out []*Subreddit
+	for _, id := range sortedKeys(m.subreddits) {
+		if active[id] {
+			sr := m.subreddits[id]
+			out = append(out, &sr)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemStore) InsertRule(_ context.Context, rule Rule) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rule.Mode == "" {
+		rule.Mode = "narrative"
+	}
+	if rule.WindowHours <= 0 {
+		rule.WindowHours = 72
+	}
+	rule.ID = m.nextID("rules")
+
+	// Like the SQL stores, the returned rule is the input with its id; only
+	// the stored row is lowercased.
+	stored := rule
+	stored.Target = strings.ToLower(rule.Target)
+	stored.TargetID = strings.ToLower(rule.TargetID)
+	stored.ExcludeTerms = normalizeTerms(rule.ExcludeTerms)
+	// New rules start enabled with no quiet hours, as the columns default.
+	stored.Disabled, stored.PausedUntil, stored.QuietHours = false, time.Time{}, ""
+	m.rules[rule.ID] = &memRule{Rule: stored}
+	return &rule, nil
+}
+
+// ruleRow is a rule joined to its channel's server, as GetRules selects it;
+// the server comes from the channel, not the inserted rule.
+func (m *MemStore) ruleRow(r *memRule) Rule {
+	out := r.Rule
+	out.ExcludeTerms = slices.Clone(r.ExcludeTerms)
+	out.DiscordServerID = 0
+	if ch, ok := m.channels[r.DiscordChannelID]; ok {
+		out.DiscordServerID = ch.serverID
+	}
+	return out
+}
+
+func (m *MemStore) ruleDetail(r *memRule) *RuleDetail {
+	row := m.ruleRow(r)
+	return &RuleDetail{
+		ID:           r.ID,
+		Target:       r.Target,
+		Exact:        r.Exact,
+		TargetID:     r.TargetID,
+		Mode:         r.Mode,
+		WindowHours:  r.WindowHours,
+		Threshold:    r.Threshold,
+		ExcludeTerms: row.ExcludeTerms,
+		Disabled:     r.Disabled,
+		PausedUntil:  r.PausedUntil,
+		QuietHours:   r.QuietHours,
+		Subreddit:    m.subreddits[r.SubredditID].ExternalID,
+		ServerID:     row.DiscordServerID,
+		Managed:      r.managed,
+	}
+}
+
+func (m *MemStore) GetRules(_ context.Context, subreddit int) ([]*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*Rule
+	for _, id := range sortedKeys(m.rules) {
+		if r := m.rules[id]; r.SubredditID == subreddit {
+			row := m.ruleRow(r)
+			out = append(out, &row)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemStore) GetRulesByChannel(_ context.Context, channelExternalID string) ([]*RuleDetail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := m.channelByExternalID(channelExternalID)
+	if ch == nil {
+		return nil, nil
+	}
+	var out []*RuleDetail
+	for _, id := range sortedKeys(m.rules) {
+		if r := m.rules[id]; r.DiscordChannelID == ch.ID {
+			out = append(out, m.ruleDetail(r))
+		}
+	}
+	return out, nil
+}
+
+func (m *MemStore) GetRuleByID(_ context.Context, ruleID int) (*RuleDetail, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.rules[ruleID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get rule %d: %w", ruleID, sql.ErrNoRows)
+	}
+	return m.ruleDetail(r), nil
+}
+
+// updateRule applies fn to a stored rule, reporting a missing rule the way
+// the SQL stores do.
+func (m *MemStore) updateRule(ruleID int, fn func(r *memRule)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.rules[ruleID]
+	if !ok {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	fn(r)
+	return nil
+}
+
+func (m *MemStore) UpdateRuleMode(_ context.Context, ruleID int, mode string) error {
+	if err := m.updateRule(ruleID, func(r *memRule) { r.Mode = mode }); err != nil {
+		return fmt.Errorf("failed to update rule mode: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRuleWindowHours(_ context.Context, ruleID int, windowHours int) error {
+	if windowHours <= 0 {
+		return fmt.Errorf("rule window_hours must be > 0, got %d", windowHours)
+	}
+	if err := m.updateRule(ruleID, func(r *memRule) { r.WindowHours = windowHours }); err != nil {
+		return fmt.Errorf("failed to update rule window_hours: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRuleThreshold(_ context.Context, ruleID int, threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("rule threshold must be within 0–1, got %g", threshold)
+	}
+	if err := m.updateRule(ruleID, func(r *memRule) { r.Threshold = threshold }); err != nil {
+		return fmt.Errorf("failed to update rule threshold: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRuleExcludeTerms(_ context.Context, ruleID int, terms []string) error {
+	if err := m.updateRule(ruleID, func(r *memRule) { r.ExcludeTerms = normalizeTerms(terms) }); err != nil {
+		return fmt.Errorf("failed to update rule exclude_terms: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRulePause(_ context.Context, ruleID int, disabled bool, pausedUntil time.Time) error {
+	if !pausedUntil.IsZero() {
+		pausedUntil = pausedUntil.UTC()
+	}
+	err := m.updateRule(ruleID, func(r *memRule) {
+		r.Disabled = disabled
+		r.PausedUntil = pausedUntil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rule pause: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRuleQuietHours(_ context.Context, ruleID int, quietHours string) error {
+	if err := m.updateRule(ruleID, func(r *memRule) { r.QuietHours = quietHours }); err != nil {
+		return fmt.Errorf("failed to update rule quiet_hours: %w", err)
+	}
+	return nil
+}
+
+func (m *MemStore) UpdateRule(_ context.Context, ruleID int, target string, exact bool) error {
+	err := m.updateRule(ruleID, func(r *memRule) {
+		r.Target = strings.ToLower(target)
+		r.Exact = exact
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+// DeleteRule also drops the rule's notifications, embedding, jobs and
+// feedback, which reference it ON DELETE CASCADE.
+func (m *MemStore) DeleteRule(_ context.Context, ruleID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rules[ruleID]; !ok {
+		return fmt.Errorf("failed to delete rule %d: rule %d not found", ruleID, ruleID)
+	}
+	m.deleteRule(ruleID)
+	return nil
+}
+
+func (m *MemStore) deleteRule(ruleID int) {
+	delete(m.rules, ruleID)
+	delete(m.embeddings, ruleID)
+	for key := range m.notifications {
+		if key[2] == ruleID {
+			delete(m.notifications, key)
+		}
+	}
+	for id, j := range m.jobs {
+		if j.RuleID == ruleID {
+			delete(m.jobs, id)
+		}
+	}
+	for key := range m.feedback {
+		if key.rule == ruleID {
+			delete(m.feedback, key)
+		}
+	}
+}
+
+func (m *MemStore) GetGuildConfig(_ context.Context, serverID string) ([]GuildChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	guild := m.guildByExternalID(serverID)
+	if guild == 0 {
+		return nil, nil
+	}
+	var ids []int
+	for id, ch := range m.channels {
+		if ch.serverID == guild {
+			ids = append(ids, id)
+		}
+	}
+	slices.SortFunc(ids, func(a, b int) int { return strings.Compare(m.channels[a].ExternalID, m.channels[b].ExternalID) })
+	channels := make([]GuildChannel, 0, len(ids))
+	for _, id := range ids {
+		ch := m.channels[id]
+		channels = append(channels, GuildChannel{
+			ExternalID: ch.ExternalID, PromptTemplate: ch.template, Tone: ch.tone, Language: ch.language,
+		})
+	}
+	var rules []guildRuleRow
+	for _, id := range sortedKeys(m.rules) {
+		r := m.rules[id]
+		rules = append(rules, guildRuleRow{channelID: r.DiscordChannelID, rule: GuildRule{
+			ID: r.ID, Subreddit: m.subreddits[r.SubredditID].ExternalID, TargetID: r.TargetID, Target: r.Target,
+			Exact: r.Exact, Mode: r.Mode, WindowHours: r.WindowHours, Threshold: r.Threshold,
+			ExcludeTerms: slices.Clone(r.ExcludeTerms), QuietHours: r.QuietHours,
+			PromptTemplate: r.template, Tone: r.tone, Managed: r.managed,
+		}})
+	}
+	return groupGuildRules(channels, ids, rules), nil
+}
+
+// ApplyGuildConfig checks every channel and rule before changing anything,
+// so a rejected config leaves the store as it was.
+func (m *MemStore) ApplyGuildConfig(_ context.Context, serverID string, channels []GuildChannel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkGuild(serverID, channels); err != nil {
+		return err
+	}
+	guild, keep := m.applyGuild(serverID, channels, false)
+	for id, r := range m.rules {
+		if ch, ok := m.channels[r.DiscordChannelID]; ok && ch.serverID == guild && !keep[id] {
+			m.deleteRule(id)
+		}
+	}
+	return nil
+}
+
+func (m *MemStore) ReconcileManagedRules(_ context.Context, guilds []ManagedGuild) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, g := range guilds {
+		if err := m.checkGuild(g.ServerID, g.Channels); err != nil {
+			return 0, fmt.Errorf("guild %s: %w", g.ServerID, err)
+		}
+	}
+	keep := map[int]bool{}
+	for _, g := range guilds {
+		_, kept := m.applyGuild(g.ServerID, g.Channels, true)
+		for id := range kept {
+			keep[id] = true
+		}
+	}
+	var deleted int64
+	for id, r := range m.rules {
+		if r.managed && !keep[id] {
+			m.deleteRule(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// guildByExternalID returns the id of the server extID names, or 0.
+func (m *MemStore) guildByExternalID(extID string) int {
+	for _, s := range m.servers {
+		if s.ExternalID == strings.ToLower(extID) {
+			return s.ID
+		}
+	}
+	return 0
+}
+
+// checkGuild rejects what the SQL stores would fail on partway through.
+func (m *MemStore) checkGuild(serverID string, channels []GuildChannel) error {
+	guild := m.guildByExternalID(serverID)
+	for _, ch := range channels {
+		existing := m.channelByExternalID(ch.ExternalID)
+		if existing != nil && (guild == 0 || existing.serverID != guild) {
+			return fmt.Errorf("channel %s belongs to another server", ch.ExternalID)
+		}
+		for _, r := range ch.Rules {
+			if _, err := prepareGuildRule(ch.ExternalID, r); err != nil {
+				return err
+			}
+			if r.ID == 0 {
+				continue
+			}
+			if rule, ok := m.rules[r.ID]; !ok || existing == nil || rule.DiscordChannelID != existing.ID {
+				return fmt.Errorf("rule %d not found in channel %s", r.ID, ch.ExternalID)
+			}
+		}
+	}
+	return nil
+}
+
+// applyGuild writes a checked guild config and returns the guild's id and
+// the rules it wrote.
+func (m *MemStore) applyGuild(serverID string, channels []GuildChannel, managed bool) (int, map[int]bool) {
+	guild := m.guildByExternalID(serverID)
+	if guild == 0 {
+		guild = m.nextID("discord_servers")
+		m.servers[guild] = DiscordServer{ID: guild, ExternalID: strings.ToLower(serverID)}
+	}
+	keep := map[int]bool{}
+	for _, ch := range channels {
+		stored := m.channelByExternalID(ch.ExternalID)
+		if stored == nil {
+			stored = &memChannel{DiscordChannel: DiscordChannel{ID: m.nextID("discord_channels"), ExternalID: strings.ToLower(ch.ExternalID)}, serverID: guild}
+			m.channels[stored.ID] = stored
+		}
+		stored.template, stored.tone, stored.language = ch.PromptTemplate, ch.Tone, ch.Language
+		for _, r := range ch.Rules {
+			r, _ := prepareGuildRule(ch.ExternalID, r)
+			if r.ID == 0 {
+				sr, ok := m.subredditByExternalID(r.Subreddit)
+				if !ok {
+					sr = Subreddit{ID: m.nextID("subreddits"), ExternalID: strings.ToLower(r.Subreddit)}
+					m.subreddits[sr.ID] = sr
+				}
+				r.ID = m.nextID("rules")
+				m.rules[r.ID] = &memRule{Rule: Rule{
+					ID: r.ID, Target: strings.ToLower(r.Target), TargetID: strings.ToLower(r.TargetID),
+					DiscordChannelID: stored.ID, SubredditID: sr.ID,
+				}}
+			}
+			rule := m.rules[r.ID]
+			rule.Exact, rule.Mode, rule.WindowHours, rule.Threshold = r.Exact, r.Mode, r.WindowHours, r.Threshold
+			rule.ExcludeTerms, rule.QuietHours = r.ExcludeTerms, r.QuietHours
+			rule.template, rule.tone = r.PromptTemplate, r.Tone
+			rule.managed = rule.managed || managed
+			keep[r.ID] = true
+		}
+	}
+	return guild, keep
+}
+
+func cloneRollingPost(rp *RollingPost) *RollingPost {
+	out := *rp
+	out.SubredditIDs = slices.Clone(rp.SubredditIDs)
+	out.DiscordMessageIDs = slices.Clone(rp.DiscordMessageIDs)
+	out.ThreadMessageIDs = slices.Clone(rp.ThreadMessageIDs)
+	out.Entries = slices.Clone(rp.Entries)
+	out.IncludedPostIDs = slices.Clone(rp.IncludedPostIDs)
+	out.IncludedRuleIDs = slices.Clone(rp.IncludedRuleIDs)
+	return &out
+}
+
+// latestRollingPost is the match with the latest window_start, or nil.
+func (m *MemStore) latestRollingPost(match func(rp *RollingPost) bool) *RollingPost {
+	var latest *RollingPost
+	for _, rp := range m.rollingPosts {
+		if match(rp) && (latest == nil || rp.WindowStart.After(latest.WindowStart)) {
+			latest = rp
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return cloneRollingPost(latest)
+}
+
+func (m *MemStore) GetActiveRollingPost(_ context.Context, channelID int, mode string, windowHours int) (*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mode == "" {
+		mode = ModeNarrative
+	}
+
+	now := m.now()
+	open := func(rp *RollingPost) bool {
+		if !rp.WindowEnd.IsZero() {
+			return rp.WindowEnd.After(now)
+		}
+		return rp.WindowStart.Add(time.Duration(windowHours) * time.Hour).After(now)
+	}
+	// A digest reopened by hand wins over one that opened after it.
+	if rp := m.latestRollingPost(func(rp *RollingPost) bool {
+		return rp.ChannelID == channelID && rp.Mode == mode && !rp.WindowEnd.IsZero() && open(rp)
+	}); rp != nil {
+		return rp, nil
+	}
+	return m.latestRollingPost(func(rp *RollingPost) bool {
+		return rp.ChannelID == channelID && rp.Mode == mode && open(rp)
+	}), nil
+}
+
+func (m *MemStore) GetRollingPostByMessageID(_ context.Context, messageID string) (*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.latestRollingPost(func(rp *RollingPost) bool {
+		return slices.Contains(rp.DiscordMessageIDs, messageID)
+	}), nil
+}
+
+func (m *MemStore) GetRollingPost(_ context.Context, id int) (*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rp, ok := m.rollingPosts[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneRollingPost(rp), nil
+}
+
+func (m *MemStore) ListRollingPosts(_ context.Context, channelID int, mode string, since time.Time, limit int) ([]*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*RollingPost
+	for _, rp := range m.rollingPosts {
+		if rp.ChannelID == channelID && (mode == "" || rp.Mode == mode) && !rp.WindowStart.Before(since) {
+			out = append(out, cloneRollingPost(rp))
+		}
+	}
+	slices.SortFunc(out, func(a, b *RollingPost) int {
+		if c := b.WindowStart.Compare(a.WindowStart); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// UpsertRollingPost is id-aware like the SQL stores': ID 0 inserts,
+// otherwise the row is updated with its window, day and opening subreddit
+// kept.
+func (m *MemStore) UpsertRollingPost(_ context.Context, rp RollingPost) (*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.upsertRollingPost(rp)
+}
+
+func (m *MemStore) upsertRollingPost(rp RollingPost) (*RollingPost, error) {
+	if len(rp.Entries) == 0 {
+		rp.Entries = []byte("[]")
+	}
+	if rp.Mode == "" {
+		rp.Mode = "narrative"
+	}
+	// The array columns are NOT NULL; a nil slice reads back empty.
+	if rp.SubredditIDs == nil {
+		rp.SubredditIDs = []int{}
+	}
+	if rp.DiscordMessageIDs == nil {
+		rp.DiscordMessageIDs = []string{}
+	}
+	if rp.ThreadMessageIDs == nil {
+		rp.ThreadMessageIDs = []string{}
+	}
+	if rp.IncludedPostIDs == nil {
+		rp.IncludedPostIDs = []string{}
+	}
+	if rp.IncludedRuleIDs == nil {
+		rp.IncludedRuleIDs = []int{}
+	}
+	rp.UpdatedAt = m.now()
+
+	if rp.ID == 0 {
+		rp.ID = m.nextID("rolling_posts")
+		rp.WindowEnd = time.Time{}
+		if rp.WindowStart.IsZero() {
+			rp.WindowStart = m.now().UTC()
+		}
+		// day_local is a DATE.
+		y, mo, d := rp.DayLocal.Date()
+		rp.DayLocal = time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+	} else {
+		existing, ok := m.rollingPosts[rp.ID]
+		if !ok {
+			return nil, fmt.Errorf("failed to upsert rolling post: %w", sql.ErrNoRows)
+		}
+		rp.ChannelID = existing.ChannelID
+		rp.SubredditID = existing.SubredditID
+		rp.DayLocal = existing.DayLocal
+		rp.WindowStart = existing.WindowStart
+		rp.WindowEnd = existing.WindowEnd
+	}
+	m.rollingPosts[rp.ID] = cloneRollingPost(&rp)
+	return cloneRollingPost(&rp), nil
+}
+
+func (m *MemStore) CloseRollingPost(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setWindowEnd(id, m.now())
+}
+
+func (m *MemStore) ReopenRollingPost(_ context.Context, id int, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setWindowEnd(id, until)
+}
+
+func (m *MemStore) setWindowEnd(id int, end time.Time) error {
+	rp, ok := m.rollingPosts[id]
+	if !ok {
+		return fmt.Errorf("rolling post %d not found", id)
+	}
+	rp.WindowEnd = end.UTC()
+	return nil
+}
+
+func (m *MemStore) MergeRollingPosts(_ context.Context, into RollingPost, fromID int) (*RollingPost, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.rollingPosts[into.ID]
+	if !ok {
+		return nil, fmt.Errorf("rolling post %d not found", into.ID)
+	}
+	from, ok := m.rollingPosts[fromID]
+	if !ok || from.ChannelID != target.ChannelID {
+		return nil, fmt.Errorf("rolling post %d not found in the channel of %d", fromID, into.ID)
+	}
+	for _, it := range m.digestItems[fromID] {
+		if !slices.ContainsFunc(m.digestItems[into.ID], func(x DigestItem) bool { return x.PostID == it.PostID }) {
+			it.RollingPostID = into.ID
+			m.digestItems[into.ID] = append(m.digestItems[into.ID], it)
+		}
+	}
+	delete(m.digestItems, fromID)
+	delete(m.rollingPosts, fromID)
+	return m.upsertRollingPost(into)
+}
+
+func (m *MemStore) GetLastfmListeners(_ context.Context, artistKey string) (int, time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.lastfm[artistKey]
+	return row.listeners, row.fetchedAt, ok, nil
+}
+
+func (m *MemStore) UpsertLastfmListeners(_ context.Context, artistKey string, listeners int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row := m.lastfm[artistKey]
+	row.listeners, row.fetchedAt = listeners, m.now()
+	m.lastfm[artistKey] = row
+	return nil
+}
+
+func (m *MemStore) GetLastfmArtist(_ context.Context, artistKey string) (int, []string, time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.lastfm[artistKey]
+	if !ok {
+		return 0, nil, time.Time{}, false, nil
+	}
+	tags := slices.Clone(row.tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	return row.listeners, tags, row.fetchedAt, true, nil
+}
+
+func (m *MemStore) UpsertLastfmArtist(_ context.Context, artistKey string, listeners int, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastfm[artistKey] = memLastfm{listeners: listeners, tags: slices.Clone(tags), fetchedAt: m.now()}
+	return nil
+}
+
+func (m *MemStore) GetPipedVideo(_ context.Context, queryKey string) (string, time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.piped[queryKey]
+	return row.value, row.fetchedAt, ok, nil
+}
+
+func (m *MemStore) UpsertPipedVideo(_ context.Context, queryKey, youtubeURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.piped[queryKey] = memCached{value: youtubeURL, fetchedAt: m.now()}
+	return nil
+}
+
+func (m *MemStore) GetQobuzAlbum(_ context.Context, queryKey string) (string, time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.qobuz[queryKey]
+	return row.value, row.fetchedAt, ok, nil
+}
+
+func (m *MemStore) UpsertQobuzAlbum(_ context.Context, queryKey, qobuzURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.qobuz[queryKey] = memCached{value: qobuzURL, fetchedAt: m.now()}
+	return nil
+}
+
+func (m *MemStore) GetCachedArticle(_ context.Context, url string) (*CachedArticle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.articles[url]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (m *MemStore) UpsertCachedArticle(_ context.Context, a CachedArticle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a.FetchedAt = m.now()
+	m.articles[a.URL] = a
+	return nil
+}
+
+func (m *MemStore) GetLLMCompletion(_ context.Context, key string) (string, time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.completions[key]
+	return row.value, row.fetchedAt, ok, nil
+}
+
+// UpsertLLMCompletion keeps the content only; the model column is never
+// read back.
+func (m *MemStore) UpsertLLMCompletion(_ context.Context, key, _, content string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.completions[key] = memCached{value: content, fetchedAt: m.now()}
+	return nil
+}
+
+func (m *MemStore) PruneLLMCompletions(_ context.Context, maxAge time.Duration, maxRows int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	cutoff := m.now().Add(-maxAge)
+	for key, row := range m.completions {
+		if row.fetchedAt.Before(cutoff) {
+			delete(m.completions, key)
+			deleted++
+		}
+	}
+	if maxRows > 0 && len(m.completions) > maxRows {
+		keys := sortedKeys(m.completions)
+		slices.SortStableFunc(keys, func(a, b string) int {
+			return m.completions[b].fetchedAt.Compare(m.completions[a].fetchedAt)
+		})
+		for _, key := range keys[maxRows:] {
+			delete(m.completions, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// PruneRows deletes up to limit of table's rows older than cutoff, oldest
+// first, with the same rules as the SQL stores: posts go only once no
+// notification, job or vote references them.
+func (m *MemStore) PruneRows(_ context.Context, table string, cutoff time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Each candidate is a row's age and the func that deletes it.
+	type candidate struct {
+		at  time.Time
+		del func()
+	}
+	var rows []candidate
+	switch table {
+	case PruneDigests:
+		for id, rp := range m.rollingPosts {
+			if !rp.WindowEnd.IsZero() && !rp.WindowEnd.Before(cutoff) {
+				continue
+			}
+			rows = append(rows, candidate{rp.WindowStart, func() {
+				delete(m.rollingPosts, id)
+				delete(m.digestItems, id)
+			}})
+		}
+	case PruneNotifications:
+		for key, n := range m.notifications {
+			rows = append(rows, candidate{n.createdAt, func() { delete(m.notifications, key) }})
+		}
+	case PruneJobs:
+		for id, j := range m.jobs {
+			if j.Status == JobStatusDone {
+				rows = append(rows, candidate{j.UpdatedAt, func() { delete(m.jobs, id) }})
+			}
+		}
+	case PrunePosts:
+		referenced := map[int]bool{}
+		for key := range m.notifications {
+			referenced[key[0]] = true
+		}
+		for _, j := range m.jobs {
+			referenced[j.PostID] = true
+		}
+		for key := range m.feedback {
+			referenced[key.post] = true
+		}
+		for id, p := range m.posts {
+			if !referenced[id] {
+				rows = append(rows, candidate{p.createdAt, func() { delete(m.posts, id) }})
+			}
+		}
+	case PruneLastfmCache:
+		for key, row := range m.lastfm {
+			rows = append(rows, candidate{row.fetchedAt, func() { delete(m.lastfm, key) }})
+		}
+	case PrunePipedCache:
+		for key, row := range m.piped {
+			rows = append(rows, candidate{row.fetchedAt, func() { delete(m.piped, key) }})
+		}
+	case PruneQobuzCache:
+		for key, row := range m.qobuz {
+			rows = append(rows, candidate{row.fetchedAt, func() { delete(m.qobuz, key) }})
+		}
+	case PruneArticleCache:
+		for key, a := range m.articles {
+			rows = append(rows, candidate{a.FetchedAt, func() { delete(m.articles, key) }})
+		}
+	default:
+		return 0, fmt.Errorf("unknown prune table %q", table)
+	}
+
+	rows = slices.DeleteFunc(rows, func(c candidate) bool { return !c.at.Before(cutoff) })
+	slices.SortFunc(rows, func(a, b candidate) int { return a.at.Compare(b.at) })
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	for _, c := range rows {
+		c.del()
+	}
+	return int64(len(rows)), nil
+}
+
+func (m *MemStore) GetPostClassification(_ context.Context, postID string) (*PostClassification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pc, ok := m.classifications[postID]
+	if !ok {
+		return nil, nil
+	}
+	pc.Topics = slices.Clone(pc.Topics)
+	if pc.Topics == nil {
+		pc.Topics = []string{}
+	}
+	return &pc, nil
+}
+
+func (m *MemStore) UpsertPostClassification(_ context.Context, pc PostClassification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pc.Topics = slices.Clone(pc.Topics)
+	pc.ClassifiedAt = m.now()
+	m.classifications[pc.PostID] = pc
+	return nil
+}
+
+func (m *MemStore) GetRuleEmbedding(_ context.Context, ruleID int) (*RuleEmbedding, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	re, ok := m.embeddings[ruleID]
+	if !ok {
+		return nil, nil
+	}
+	re.Embedding = slices.Clone(re.Embedding)
+	return &re, nil
+}
+
+func (m *MemStore) UpsertRuleEmbedding(_ context.Context, re RuleEmbedding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	re.Embedding = slices.Clone(re.Embedding)
+	re.CreatedAt = m.now()
+	m.embeddings[re.RuleID] = re
+	return nil
+}
+
+// GetDigestItems returns the items in (seen_at, post_id) order, as the SQL
+// stores do.
+func (m *MemStore) GetDigestItems(_ context.Context, rollingPostID int) ([]DigestItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := slices.Clone(m.digestItems[rollingPostID])
+	slices.SortStableFunc(out, func(a, b DigestItem) int {
+		if c := a.SeenAt.Compare(b.SeenAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.PostID, b.PostID)
+	})
+	return out, nil
+}
+
+// InsertDigestItem leaves an existing (rolling post, post) item untouched.
+func (m *MemStore) InsertDigestItem(_ context.Context, it DigestItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.digestItems[it.RollingPostID] {
+		if existing.PostID == it.PostID {
+			return nil
+		}
+	}
+	if it.CanonicalPostID == "" {
+		it.CanonicalPostID = it.PostID
+	}
+	it.SeenAt = m.now()
+	m.digestItems[it.RollingPostID] = append(m.digestItems[it.RollingPostID], it)
+	return nil
+}
+
+func cloneLLMJob(j *LLMJob) *LLMJob {
+	out := *j
+	out.Payload = slices.Clone(j.Payload)
+	return &out
+}
+
+// EnqueueLLMJob returns the existing job for the same (kind, channel, post)
+// unchanged, as the SQL stores' ON CONFLICT does.
+func (m *MemStore) EnqueueLLMJob(_ context.Context, job LLMJob) (*LLMJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range sortedKeys(m.jobs) {
+		if j := m.jobs[id]; j.Kind == job.Kind && j.ChannelID == job.ChannelID && j.PostID == job.PostID &&
+			(j.Kind != JobKindDeliverMatch || j.RuleID == job.RuleID) {
+			return cloneLLMJob(j), nil
+		}
+	}
+	now := m.now()
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = now
+	}
+	job.ID = m.nextID("llm_jobs")
+	job.Status = JobStatusPending
+	job.Attempts = 0
+	job.CreatedAt, job.UpdatedAt = now, now
+	m.jobs[job.ID] = cloneLLMJob(&job)
+	return &job, nil
+}
+
+// ClaimDueLLMJobs claims like the SQL stores: due pending jobs and running
+// jobs whose lease ran out, earliest next_attempt_at first.
+func (m *MemStore) ClaimDueLLMJobs(_ context.Context, limit int) ([]*LLMJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var due []*LLMJob
+	for _, id := range sortedKeys(m.jobs) {
+		j := m.jobs[id]
+		if (j.Status == JobStatusPending && !j.NextAttemptAt.After(now)) ||
+			(j.Status == JobStatusRunning && j.UpdatedAt.Before(now.Add(-jobLease))) {
+			due = append(due, j)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *LLMJob) int { return a.NextAttemptAt.Compare(b.NextAttemptAt) })
+
+	var out []*LLMJob
+	for _, j := range due[:min(limit, len(due))] {
+		j.Status = JobStatusRunning
+		j.Attempts++
+		j.UpdatedAt = now
+		out = append(out, cloneLLMJob(j))
+	}
+	return out, nil
+}
+
+func (m *MemStore) CompleteLLMJob(_ context.Context, jobID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if j, ok := m.jobs[jobID]; ok {
+		j.Status, j.LastError, j.UpdatedAt = JobStatusDone, "", m.now()
+	}
+	return nil
+}
+
+func (m *MemStore) FailLLMJob(_ context.Context, jobID int, lastError string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	j.LastError, j.UpdatedAt = lastError, m.now()
+	if retryAt.IsZero() {
+		j.Status = JobStatusFailed
+	} else {
+		j.Status, j.NextAttemptAt = JobStatusPending, retryAt
+	}
+	return nil
+}
+
+func (m *MemStore) ListFailedLLMJobs(_ context.Context, channelID int, limit int) ([]*LLMJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*LLMJob
+	for _, id := range sortedKeys(m.jobs) {
+		if j := m.jobs[id]; j.ChannelID == channelID && j.Status == JobStatusFailed {
+			out = append(out, cloneLLMJob(j))
+		}
+	}
+	slices.SortStableFunc(out, func(a, b *LLMJob) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *MemStore) GetLLMJob(_ context.Context, jobID int) (*LLMJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return cloneLLMJob(j), nil
+}
+
+func (m *MemStore) RequeueLLMJob(_ context.Context, jobID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[jobID]
+	if !ok || j.Status != JobStatusFailed {
+		return false, nil
+	}
+	now := m.now()
+	j.Status, j.Attempts, j.NextAttemptAt, j.UpdatedAt = JobStatusPending, 0, now, now
+	return true, nil
+}
+
+func (m *MemStore) UpsertPromptTemplate(_ context.Context, name, kind, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.templates[[2]string{name, kind}] = PromptTemplate{Name: name, Kind: kind, Body: body, UpdatedAt: m.now()}
+	return nil
+}
+
+func (m *MemStore) GetPromptTemplate(_ context.Context, name, kind string) (*PromptTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.templates[[2]string{name, kind}]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (m *MemStore) ListPromptTemplates(_ context.Context) ([]*PromptTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []*PromptTemplate
+	for _, t := range m.templates {
+		out = append(out, &t)
+	}
+	slices.SortFunc(out, func(a, b *PromptTemplate) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Kind, b.Kind))
+	})
+	return out, nil
+}
+
+func (m *MemStore) SetRulePrompt(_ context.Context, ruleID int, template, tone *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.rules[ruleID]; ok {
+		setIfNotNil(&r.template, template)
+		setIfNotNil(&r.tone, tone)
+	}
+	return nil
+}
+
+func (m *MemStore) SetChannelPrompt(_ context.Context, channelID int, template, tone *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ch, ok := m.channels[channelID]; ok {
+		setIfNotNil(&ch.template, template)
+		setIfNotNil(&ch.tone, tone)
+	}
+	return nil
+}
+
+// setIfNotNil is COALESCE($n, column): nil leaves the column alone.
+func setIfNotNil(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func (m *MemStore) SetChannelLanguage(_ context.Context, channelID int, language string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ch, ok := m.channels[channelID]; ok {
+		ch.language = language
+	}
+	return nil
+}
+
+func (m *MemStore) GetPromptSettings(_ context.Context, channelID, ruleID int) (PromptSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, ok := m.channels[channelID]
+	if !ok {
+		return PromptSettings{}, nil
+	}
+	s := PromptSettings{Template: ch.template, Tone: ch.tone, Language: ch.language}
+	if r, ok := m.rules[ruleID]; ok && r.DiscordChannelID == channelID {
+		s.Template = cmp.Or(r.template, s.Template)
+		s.Tone = cmp.Or(r.tone, s.Tone)
+	}
+	return s, nil
+}
+
+func (m *MemStore) postByExternalID(postID string) (memPost, bool) {
+	extID := strings.ToLower(postID)
+	for _, p := range m.posts {
+		if p.ExternalID == extID {
+			return p, true
+		}
+	}
+	return memPost{}, false
+}
+
+func (m *MemStore) RecordFeedback(_ context.Context, fb Feedback) error {
+	if err := validVote(fb.Vote); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.postByExternalID(fb.PostID)
+	if !ok {
+		return fmt.Errorf("post %s not found", fb.PostID)
+	}
+	if _, ok := m.rules[fb.RuleID]; !ok {
+		return fmt.Errorf("failed to record feedback: rule %d not found", fb.RuleID)
+	}
+	key := memFeedbackKey{post: p.ID, rule: fb.RuleID, user: fb.UserID}
+	row, ok := m.feedback[key]
+	if !ok {
+		row.seq = m.nextID("rule_feedback")
+	}
+	fb.PostID = p.ExternalID
+	fb.CreatedAt = m.now()
+	row.Feedback = fb
+	m.feedback[key] = row
+	return nil
+}
+
+func (m *MemStore) GetNotifiedRules(_ context.Context, channelID int, postID string) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.postByExternalID(postID)
+	if !ok {
+		return nil, nil
+	}
+	var ids []int
+	for key := range m.notifications {
+		if key[0] == p.ID && key[1] == channelID {
+			ids = append(ids, key[2])
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (m *MemStore) GetRuleStats(_ context.Context, channelID int) ([]RuleStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []RuleStats
+	for _, id := range sortedKeys(m.rules) {
+		if m.rules[id].DiscordChannelID != channelID {
+			continue
+		}
+		st := RuleStats{RuleID: id}
+		for key := range m.notifications {
+			if key[2] == id {
+				st.Matches++
+			}
+		}
+		for key, fb := range m.feedback {
+			if key.rule != id {
+				continue
+			}
+			switch fb.Vote {
+			case VoteUp:
+				st.Up++
+			case VoteDown:
+				st.Down++
+			case VoteIrrelevant:
+				st.Irrelevant++
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (m *MemStore) ListRuleFeedback(_ context.Context, ruleID int) ([]Feedback, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []memFeedback
+	for key, fb := range m.feedback {
+		if key.rule == ruleID {
+			rows = append(rows, fb)
+		}
+	}
+	slices.SortFunc(rows, func(a, b memFeedback) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return a.seq - b.seq
+	})
+	out := make([]Feedback, 0, len(rows))
+	for _, fb := range rows {
+		out = append(out, fb.Feedback)
+	}
+	return out, nil
+}
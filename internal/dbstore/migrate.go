@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockKey is the pg_advisory_lock key held while migrating, so
+// pods starting together apply each migration once. Arbitrary but fixed.
+const migrationLockKey int64 = 0x7265646469747379 // "redditsy"
+
+// Migration is one numbered schema change, embedded from
+// sql/migrations/NNNN_name.up.sql and its optional NNNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down reverts Up. Empty when the migration can't be reverted.
+	Down string
+}
+
+// MigrationState is a migration and, when it has run, when.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	return parseMigrations(migrationFS, "sql/migrations")
+}
+
+func parseMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		file := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 || name == "" {
+			return nil, fmt.Errorf("migration %s: want NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", file, err)
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up migration", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// MigrationStatus lists every embedded migration with when it was applied,
+// if it has been. It only reads: a database without a ledger yet reports
+// everything pending.
+func (db *PGXStore) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var hasLedger bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&hasLedger); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	applied := map[int]time.Time{}
+	if hasLedger {
+		if applied, err = appliedMigrations(ctx, db.Pool); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// MigrateUp applies every pending migration in version order and returns
+// the ones it ran. Each migration and its ledger row commit together.
+func (db *PGXStore) MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	err = withMigrationLock(ctx, db.Pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name,
+			); err != nil {
+				return fmt.Errorf("apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first,
+// and returns the ones it reverted. A migration without a down migration
+// stops it with an error; the ones reverted before it stay reverted.
+func (db *PGXStore) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var reverted []Migration
+	err = withMigrationLock(ctx, db.Pool, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down migration", m.Version, m.Name)
+			}
+			if err := runMigration(ctx, conn, m.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, m.Version,
+			); err != nil {
+				return fmt.Errorf("revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+const createLedgerSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INT         PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+
+// withMigrationLock runs fn on one connection holding the migration
+// advisory lock, creating the ledger first. A second pod blocks on the
+// lock until the first is done, then finds nothing pending.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(*pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock on a fresh context so a cancelled ctx doesn't leave the
+		// lock held on a pooled connection.
+		uctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeout)
+		defer cancel()
+		_, _ = conn.Exec(uctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}()
+
+	if _, err := conn.Exec(ctx, createLedgerSQL); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// runMigration executes body and the ledger statement in one transaction.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, body, ledgerSQL string, ledgerArgs ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, body); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, ledgerSQL, ledgerArgs...); err != nil {
+		return fmt.Errorf("update schema_migrations: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// querier is what appliedMigrations needs from a pool or a connection.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// appliedMigrations reads the ledger: version → applied_at.
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	out := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		out[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrations_Embedded(t *testing.T) {
+	ms, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(ms) == 0 || ms[0].Version != 1 || ms[0].Name != "baseline" {
+		t.Fatalf("first migration = %+v, want 0001_baseline", ms)
+	}
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d; versions must be contiguous from 1", i, m.Version)
+		}
+		if strings.TrimSpace(m.Up) == "" {
+			t.Errorf("migration %04d_%s has an empty up migration", m.Version, m.Name)
+		}
+	}
+	if strings.Contains(ms[0].Up, "now() - INTERVAL") {
+		t.Error("baseline must not carry time-relative backfills")
+	}
+	// A database upgraded straight from a pre-window release still needs
+	// its rows' window_start backfilled, once.
+	if !strings.Contains(ms[0].Up, "SET    window_start = day_local::timestamptz + INTERVAL '7 hours'") {
+		t.Error("baseline lost the window_start backfill for pre-window rows")
+	}
+}
+
+func TestSQLiteMigrations_MirrorPostgres(t *testing.T) {
+	pg, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	lite, err := SQLiteMigrations()
+	if err != nil {
+		t.Fatalf("SQLiteMigrations: %v", err)
+	}
+	if len(pg) != len(lite) {
+		t.Fatalf("%d postgres migrations, %d sqlite; every schema change needs both", len(pg), len(lite))
+	}
+	for i := range pg {
+		if pg[i].Version != lite[i].Version || pg[i].Name != lite[i].Name || (pg[i].Down == "") != (lite[i].Down == "") {
+			t.Errorf("postgres %04d_%s (down %t) vs sqlite %04d_%s (down %t)",
+				pg[i].Version, pg[i].Name, pg[i].Down != "", lite[i].Version, lite[i].Name, lite[i].Down != "")
+		}
+	}
+}
+
+func TestSQLiteStore_MigrateDownAndUp(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewSQLite(filepath.Join(t.TempDir(), "m.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+
+	ran, err := store.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	all, _ := SQLiteMigrations()
+	if len(ran) != len(all) {
+		t.Fatalf("MigrateUp ran %d migrations, want %d", len(ran), len(all))
+	}
+	if ran, err := store.MigrateUp(ctx); err != nil || len(ran) != 0 {
+		t.Fatalf("second MigrateUp = %v, %v; want nothing", ran, err)
+	}
+
+	reverted, err := store.MigrateDown(ctx, len(all)-1)
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(reverted) != len(all)-1 || reverted[0].Version != all[len(all)-1].Version {
+		t.Fatalf("MigrateDown reverted %+v", reverted)
+	}
+	states, err := store.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, st := range states {
+		if applied := st.AppliedAt != nil; applied != (st.Version == 1) {
+			t.Errorf("after down: %04d_%s applied = %t", st.Version, st.Name, applied)
+		}
+	}
+	if _, err := store.MigrateDown(ctx, 1); err == nil {
+		t.Error("reverting the baseline succeeded; it has no down migration")
+	}
+
+	if ran, err := store.MigrateUp(ctx); err != nil || len(ran) != len(all)-1 {
+		t.Fatalf("MigrateUp after down = %d migrations, %v", len(ran), err)
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"m/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"m/0010_tenth.up.sql":    {Data: []byte("SELECT 1;")},
+		"m/0010_tenth.down.sql":  {Data: []byte("SELECT 2;")},
+		"m/0002_second.down.sql": {Data: []byte("")},
+	}
+	ms, err := parseMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("parseMigrations: %v", err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, m.Name)
+	}
+	if strings.Join(got, ",") != "first,second,tenth" {
+		t.Errorf("order = %v", got)
+	}
+	if ms[0].Down != "DROP TABLE a;" || ms[1].Down != "" {
+		t.Errorf("down migrations = %q, %q", ms[0].Down, ms[1].Down)
+	}
+
+	bad := []fstest.MapFS{
+		{"m/first.up.sql": {Data: []byte("x")}},
+		{"m/0001_first.sideways.sql": {Data: []byte("x")}},
+		{"m/0001_first.down.sql": {Data: []byte("x")}},
+		{"m/0001_first.up.sql": {Data: []byte("x")}, "m/0001_other.down.sql": {Data: []byte("y")}},
+	}
+	for _, fsys := range bad {
+		if _, err := parseMigrations(fsys, "m"); err == nil {
+			t.Errorf("parseMigrations(%v) succeeded, want an error", fsys)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PromptTemplate is one operator-saved prompt body. Name groups the bodies
+// for each kind ("fresh", "update", "music"); a name needn't define all
+// three.
+type PromptTemplate struct {
+	Name      string
+	Kind      string
+	Body      string
+	UpdatedAt time.Time
+}
+
+// PromptSettings is the template name, tone preset and target language in
+// effect for a rule. Empty fields mean "use the global default".
+type PromptSettings struct {
+	Template string
+	Tone     string
+	// Language is per channel only; rules don't override it.
+	Language string
+}
+
+// UpsertPromptTemplate saves body as the (name, kind) template, replacing
+// any previous version.
+func (db *PGXStore) UpsertPromptTemplate(parent context.Context, name, kind, body string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO prompt_templates (name, kind, body, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (name, kind) DO UPDATE
+		SET body = EXCLUDED.body, updated_at = now()
+	`
+	if _, err := db.Exec(qctx, query, name, kind, body); err != nil {
+		return fmt.Errorf("failed to save prompt template %s/%s: %w", name, kind, err)
+	}
+	return nil
+}
+
+// GetPromptTemplate returns the (name, kind) template, or (nil, nil) if
+// the name doesn't define that kind.
+func (db *PGXStore) GetPromptTemplate(parent context.Context, name, kind string) (*PromptTemplate, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var t PromptTemplate
+	err := db.QueryRow(qctx,
+		`SELECT name, kind, body, updated_at FROM prompt_templates WHERE name = $1 AND kind = $2`,
+		name, kind,
+	).Scan(&t.Name, &t.Kind, &t.Body, &t.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get prompt template %s/%s: %w", name, kind, err)
+	}
+	return &t, nil
+}
+
+// ListPromptTemplates returns every saved template ordered by name and kind.
+func (db *PGXStore) ListPromptTemplates(parent context.Context) ([]*PromptTemplate, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.Query(qctx, `SELECT name, kind, body, updated_at FROM prompt_templates ORDER BY name, kind`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*PromptTemplate
+	for rows.Next() {
+		var t PromptTemplate
+		if err := rows.Scan(&t.Name, &t.Kind, &t.Body, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt template row: %w", err)
+		}
+		out = append(out, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating prompt template rows: %w", err)
+	}
+	return out, nil
+}
+
+// SetRulePrompt sets a rule's template name and tone. A nil argument leaves
+// that column unchanged; an empty string clears the override.
+func (db *PGXStore) SetRulePrompt(parent context.Context, ruleID int, template, tone *string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE rules SET
+			prompt_template = COALESCE($2, prompt_template),
+			tone            = COALESCE($3, tone)
+		WHERE id = $1
+	`
+	if _, err := db.Exec(qctx, query, ruleID, template, tone); err != nil {
+		return fmt.Errorf("failed to set prompt for rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+// SetChannelPrompt sets the channel-wide template name and tone that rules
+// without their own override inherit. nil leaves a column unchanged.
+func (db *PGXStore) SetChannelPrompt(parent context.Context, channelID int, template, tone *string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE discord_channels SET
+			prompt_template = COALESCE($2, prompt_template),
+			tone            = COALESCE($3, tone)
+		WHERE id = $1
+	`
+	if _, err := db.Exec(qctx, query, channelID, template, tone); err != nil {
+		return fmt.Errorf("failed to set prompt for channel %d: %w", channelID, err)
+	}
+	return nil
+}
+
+// SetChannelLanguage sets the language the channel's digests are written
+// in; "" clears it.
+func (db *PGXStore) SetChannelLanguage(parent context.Context, channelID int, language string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	if _, err := db.Exec(qctx, `UPDATE discord_channels SET language = $2 WHERE id = $1`, channelID, language); err != nil {
+		return fmt.Errorf("failed to set language for channel %d: %w", channelID, err)
+	}
+	return nil
+}
+
+// GetPromptSettings resolves the template and tone for a rule: the rule's
+// own override wins, then the channel's, field by field. ruleID 0 returns
+// the channel's settings alone. Language always comes from the channel.
+func (db *PGXStore) GetPromptSettings(parent context.Context, channelID, ruleID int) (PromptSettings, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(NULLIF(r.prompt_template, ''), dc.prompt_template),
+		       COALESCE(NULLIF(r.tone, ''), dc.tone),
+		       dc.language
+		FROM discord_channels dc
+			LEFT JOIN rules r ON r.id = $2 AND r.channel_id = dc.id
+		WHERE dc.id = $1
+	`
+	var s PromptSettings
+	if err := db.QueryRow(qctx, query, channelID, ruleID).Scan(&s.Template, &s.Tone, &s.Language); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PromptSettings{}, nil
+		}
+		return PromptSettings{}, fmt.Errorf("failed to get prompt settings for channel %d rule %d: %w", channelID, ruleID, err)
+	}
+	return s, nil
+}
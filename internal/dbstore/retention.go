@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Tables PruneRows can trim. Each is aged by one timestamp column: digests
+// by window_start, notifications and posts by created_at, finished jobs by
+// updated_at, the lookup caches by fetched_at.
+const (
+	PruneDigests       = "rolling_posts"
+	PruneNotifications = "notifications"
+	PruneJobs          = "llm_jobs"
+	PrunePosts         = "posts"
+	PruneLastfmCache   = "lastfm_cache"
+	PrunePipedCache    = "piped_cache"
+	PruneQobuzCache    = "qobuz_cache"
+	PruneArticleCache  = "article_cache"
+)
+
+// pruneSpec is how PruneRows picks a table's expired rows: key identifies a
+// row, age is compared with the cutoff, and keep (if set) is an extra
+// condition a row must meet to go.
+type pruneSpec struct {
+	key, age, keep string
+}
+
+var pruneSpecs = map[string]pruneSpec{
+	// A digest reopened by hand stays until its window_end passes too.
+	PruneDigests: {key: "id", age: "window_start", keep: `
+		AND (window_end IS NULL OR window_end < $1)`},
+	PruneNotifications: {key: "id", age: "created_at"},
+	// Only jobs that are done; a failed one stays for /retry_failed and a
+	// pending one is still owed its work.
+	PruneJobs: {key: "id", age: "updated_at", keep: `
+		AND status = 'done'`},
+	// A post is pruned only once nothing points at it; deleting it would
+	// otherwise cascade to a notification or job that's still wanted, or to
+	// the votes /rule_stats and exclusion suggestions are built from.
+	PrunePosts: {key: "id", age: "created_at", keep: `
+		AND NOT EXISTS (SELECT 1 FROM notifications n WHERE n.post_id = posts.id)
+		AND NOT EXISTS (SELECT 1 FROM llm_jobs j WHERE j.post_id = posts.id)
+		AND NOT EXISTS (SELECT 1 FROM rule_feedback f WHERE f.post_id = posts.id)`},
+	PruneLastfmCache:  {key: "artist_key", age: "fetched_at"},
+	PrunePipedCache:   {key: "query_key", age: "fetched_at"},
+	PruneQobuzCache:   {key: "query_key", age: "fetched_at"},
+	PruneArticleCache: {key: "url", age: "fetched_at"},
+}
+
+// PruneTables lists every table PruneRows accepts, in the order the janitor
+// trims them: notifications and jobs before the posts they reference.
+func PruneTables() []string {
+	return []string{
+		PruneDigests, PruneNotifications, PruneJobs, PrunePosts,
+		PruneLastfmCache, PrunePipedCache, PruneQobuzCache, PruneArticleCache,
+	}
+}
+
+// pruneQuery is the batched delete for table; $1 is the cutoff, $2 the
+// batch size. Only the oldest batch is locked at a time.
+func pruneQuery(table, cutoff string) (string, error) {
+	spec, ok := pruneSpecs[table]
+	if !ok {
+		return "", fmt.Errorf("unknown prune table %q", table)
+	}
+	return fmt.Sprintf(`
+		DELETE FROM %[1]s WHERE %[2]s IN (
+			SELECT %[2]s FROM %[1]s WHERE %[3]s < %[4]s %[5]s
+			ORDER BY %[3]s LIMIT $2
+		)`, table, spec.key, spec.age, cutoff, spec.keep), nil
+}
+
+// PruneRows deletes up to limit of table's rows older than cutoff, oldest
+// first, and returns how many went. Callers repeat it until it returns
+// fewer than limit. Digest items go with their rolling post.
+func (db *PGXStore) PruneRows(parent context.Context, table string, cutoff time.Time, limit int) (int64, error) {
+	// posts and notifications.created_at are plain TIMESTAMPs in the
+	// session's zone; comparing against a timestamptz converts them.
+	query, err := pruneQuery(table, "$1::timestamptz")
+	if err != nil {
+		return 0, err
+	}
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(qctx, query, cutoff, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune %s: %w", table, err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (db *SQLiteStore) PruneRows(parent context.Context, table string, cutoff time.Time, limit int) (int64, error) {
+	query, err := pruneQuery(table, "$1")
+	if err != nil {
+		return 0, err
+	}
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(qctx, query, unixMicros(cutoff), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune %s: %w", table, err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
@@ -1,8 +1,11 @@
--- App-scope schema bootstrap for reddit-spy.
---
--- Runs on every pod startup against the application database with the app
--- user's credentials. All statements are idempotent (IF NOT EXISTS) so this
--- is safe to re-run after every deploy.
+-- Baseline: exactly the schema the startup bootstrap (schema.sql, applied
+-- on every start) created in its last version, before numbered migrations
+-- replaced it. That is the cut line: everything schema.sql ever held is
+-- here, and every change made since is a numbered migration. Statements
+-- stay idempotent (IF NOT EXISTS) so databases that already ran that
+-- bootstrap adopt the ledger without changes. The one thing schema.sql
+-- didn't hold is the pgvector column switch, which ran from Go; it is
+-- 0008_rule_embeddings_vector.
 --
 -- The six original tables (discord_servers, discord_channels, subreddits,
 -- rules, posts, notifications) mirror the source schema from the v2.0.x
@@ -21,6 +24,13 @@ CREATE TABLE IF NOT EXISTS discord_channels (
     server_id  INT  NOT NULL REFERENCES discord_servers(id) ON DELETE CASCADE,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+-- Channel-wide prompt template name and tone preset; rules inherit them
+-- unless they set their own. Empty means the built-in prompt / LLM_TONE.
+ALTER TABLE discord_channels ADD COLUMN IF NOT EXISTS prompt_template TEXT NOT NULL DEFAULT '';
+ALTER TABLE discord_channels ADD COLUMN IF NOT EXISTS tone            TEXT NOT NULL DEFAULT '';
+-- Language narrative digests in this channel are written in (an English
+-- name such as 'German'). Empty leaves it to the model.
+ALTER TABLE discord_channels ADD COLUMN IF NOT EXISTS language        TEXT NOT NULL DEFAULT '';
 
 CREATE TABLE IF NOT EXISTS subreddits (
     id           SERIAL PRIMARY KEY,
@@ -41,6 +51,10 @@ CREATE TABLE IF NOT EXISTS rules (
 );
 ALTER TABLE rules ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'narrative';
 ALTER TABLE rules ADD COLUMN IF NOT EXISTS window_hours INT NOT NULL DEFAULT 72;
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS prompt_template TEXT NOT NULL DEFAULT '';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS tone            TEXT NOT NULL DEFAULT '';
+-- Per-rule similarity threshold for "semantic" rules; 0 uses the default.
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS threshold REAL NOT NULL DEFAULT 0;
 CREATE INDEX IF NOT EXISTS rules_subreddit_id_idx ON rules(subreddit_id);
 CREATE INDEX IF NOT EXISTS rules_channel_id_idx   ON rules(channel_id);
 
@@ -90,7 +104,25 @@ CREATE TABLE IF NOT EXISTS rolling_posts (
 ALTER TABLE rolling_posts ADD COLUMN IF NOT EXISTS mode                TEXT        NOT NULL DEFAULT 'narrative';
 ALTER TABLE rolling_posts ADD COLUMN IF NOT EXISTS discord_message_ids TEXT[]      NOT NULL DEFAULT '{}';
 ALTER TABLE rolling_posts ADD COLUMN IF NOT EXISTS entries             JSONB       NOT NULL DEFAULT '[]';
-ALTER TABLE rolling_posts ADD COLUMN IF NOT EXISTS window_start        TIMESTAMPTZ NOT NULL DEFAULT now();
+-- Pre-window rows get window_start backfilled to approximate their original
+-- Phoenix-midnight start (day_local 00:00 MST ≈ 07:00 UTC). Keyed on the
+-- column being added here rather than on how recent window_start is, so a
+-- database that already has the column, including one whose newest digests
+-- opened minutes ago, is left alone.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE  table_schema = current_schema()
+          AND  table_name = 'rolling_posts'
+          AND  column_name = 'window_start'
+    ) THEN
+        ALTER TABLE rolling_posts ADD COLUMN window_start TIMESTAMPTZ NOT NULL DEFAULT now();
+        UPDATE rolling_posts
+        SET    window_start = day_local::timestamptz + INTERVAL '7 hours';
+    END IF;
+END
+$$;
 -- subreddit_ids: tracks every subreddit that has contributed to this digest.
 -- Backfilled from the (legacy) scalar subreddit_id so existing open digests
 -- keep their provenance. Lookup no longer keys on subreddit — see
@@ -105,13 +137,6 @@ WHERE  subreddit_id IS NOT NULL
 -- so new rows can omit it — we still populate it with the first contributing
 -- sub for back-compat, but the digest key is (channel, mode, window_start).
 ALTER TABLE rolling_posts ALTER COLUMN subreddit_id DROP NOT NULL;
--- Backfill window_start for pre-window rows to approximate their original
--- Phoenix-midnight start (day_local 00:00 MST ≈ 07:00 UTC). Only touches rows
--- whose window_start was just defaulted by the ALTER (i.e. within the last
--- 10 minutes) — a second run of schema.sql after startup is a no-op.
-UPDATE rolling_posts
-SET    window_start = day_local::timestamptz + INTERVAL '7 hours'
-WHERE  window_start > now() - INTERVAL '10 minutes';
 -- The day-based uniqueness no longer matches the new key shape — drop it so
 -- a second same-day match can open a new window-bounded row if needed.
 ALTER TABLE rolling_posts DROP CONSTRAINT IF EXISTS rolling_posts_channel_id_subreddit_id_day_local_key;
@@ -139,6 +164,10 @@ CREATE INDEX IF NOT EXISTS rolling_posts_day_idx ON rolling_posts(day_local);
 DROP INDEX IF EXISTS rolling_posts_active_idx;
 CREATE INDEX IF NOT EXISTS rolling_posts_active_by_mode_idx
   ON rolling_posts (channel_id, mode, window_start DESC);
+-- Component interactions (e.g. the music card's "Build playlist" button)
+-- resolve their digest from the clicked message id.
+CREATE INDEX IF NOT EXISTS rolling_posts_message_ids_idx
+  ON rolling_posts USING GIN (discord_message_ids);
 
 -- Last.fm listener-count + tags cache. artist_key is the normalized artist
 -- name (case-folded, single-spaced, trimmed). Stale rows (> 30 days) get
@@ -172,3 +201,110 @@ CREATE TABLE IF NOT EXISTS qobuz_cache (
     qobuz_url  TEXT        NOT NULL DEFAULT '',
     fetched_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+-- Linked-article cache for narrative digests of link posts. url is the
+-- post's link as Reddit reports it; body is the readable text the extractor
+-- pulled out, title/description/image_url its OpenGraph metadata. A row
+-- with everything empty is a cached "not an HTML page" outcome. Rows are
+-- refetched lazily once older than a week.
+CREATE TABLE IF NOT EXISTS article_cache (
+    url         TEXT        PRIMARY KEY,
+    title       TEXT        NOT NULL DEFAULT '',
+    description TEXT        NOT NULL DEFAULT '',
+    image_url   TEXT        NOT NULL DEFAULT '',
+    body        TEXT        NOT NULL DEFAULT '',
+    fetched_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- LLM completion cache. cache_key is the sha256 of the canonical request
+-- (model, messages, sampling params), so identical prompts from previews,
+-- re-deploys or sibling rules on one subreddit reuse the answer. Rows past
+-- LLM_CACHE_TTL or beyond LLM_CACHE_MAX_ROWS are pruned by the bot.
+CREATE TABLE IF NOT EXISTS llm_cache (
+    cache_key  TEXT        PRIMARY KEY,
+    model      TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS llm_cache_created_idx ON llm_cache (created_at);
+
+-- Deferred LLM work. A music-mode match whose extraction fails is queued
+-- here instead of being dropped; the retry worker re-runs it with
+-- exponential backoff until it succeeds or exhausts max_attempts
+-- (status 'failed'), at which point /retry_failed can requeue it. payload
+-- holds the Reddit post snapshot. One job per (kind, channel, post).
+CREATE TABLE IF NOT EXISTS llm_jobs (
+    id              SERIAL PRIMARY KEY,
+    kind            TEXT        NOT NULL,
+    channel_id      INT         NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    rule_id         INT         NOT NULL REFERENCES rules(id)            ON DELETE CASCADE,
+    post_id         INT         NOT NULL REFERENCES posts(id)            ON DELETE CASCADE,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending',
+    attempts        INT         NOT NULL DEFAULT 0,
+    max_attempts    INT         NOT NULL DEFAULT 6,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (kind, channel_id, post_id)
+);
+CREATE INDEX IF NOT EXISTS llm_jobs_due_idx ON llm_jobs (status, next_attempt_at);
+
+-- Operator-edited prompt templates (Go text/template), saved and validated
+-- by /set_prompt. A name holds at most one body per kind ('fresh',
+-- 'update', 'music'); kinds a name doesn't define use the built-in prompt.
+-- Rules and channels reference templates by name (prompt_template above).
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    name       TEXT        NOT NULL,
+    kind       TEXT        NOT NULL,
+    body       TEXT        NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (name, kind)
+);
+
+-- LLM classification of posts watched by "classification" rules. post_id
+-- is the Reddit post id; topics are drawn from CLASSIFY_TOPICS. A post is
+-- classified once, the first time a subreddit with classification rules
+-- sees it.
+CREATE TABLE IF NOT EXISTS post_classifications (
+    post_id       TEXT        PRIMARY KEY,
+    sentiment     TEXT        NOT NULL DEFAULT '',
+    topics        TEXT[]      NOT NULL DEFAULT '{}',
+    toxic         BOOLEAN     NOT NULL DEFAULT false,
+    classified_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Embedding of each "semantic" rule's description. source is the text that
+-- was embedded and model the embedding model, so editing the rule or
+-- switching LLM_MODEL_EMBEDDING re-embeds. embedding starts as REAL[];
+-- 0008_rule_embeddings_vector converts it to a pgvector column when the
+-- extension can be enabled. Queries read it back as REAL[] either way.
+CREATE TABLE IF NOT EXISTS rule_embeddings (
+    rule_id    INT         PRIMARY KEY REFERENCES rules(id) ON DELETE CASCADE,
+    model      TEXT        NOT NULL,
+    source     TEXT        NOT NULL,
+    embedding  REAL[]      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Posts folded into each narrative digest, with what later posts are
+-- compared on to spot copies: crosspost_parent, the normalized url and the
+-- title's 64-bit SimHash (title_hash, 0 for titles too short to compare).
+-- A copy gets its own row pointing at the first post's id through
+-- canonical_post_id, so the digest can list every source subreddit and sum
+-- score and comments per item.
+CREATE TABLE IF NOT EXISTS digest_items (
+    rolling_post_id   INT         NOT NULL REFERENCES rolling_posts(id) ON DELETE CASCADE,
+    post_id           TEXT        NOT NULL,
+    canonical_post_id TEXT        NOT NULL,
+    subreddit         TEXT        NOT NULL,
+    title             TEXT        NOT NULL DEFAULT '',
+    crosspost_parent  TEXT        NOT NULL DEFAULT '',
+    url               TEXT        NOT NULL DEFAULT '',
+    title_hash        BIGINT      NOT NULL DEFAULT 0,
+    score             INT         NOT NULL DEFAULT 0,
+    comments          INT         NOT NULL DEFAULT 0,
+    seen_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (rolling_post_id, post_id)
+);
@@ -0,0 +1,10 @@
+-- Indexes for the retention janitor: each prune batch picks the oldest rows
+-- by these columns, and posts are only pruned once nothing references them.
+CREATE INDEX IF NOT EXISTS notifications_created_idx  ON notifications (created_at);
+CREATE INDEX IF NOT EXISTS posts_created_idx          ON posts (created_at);
+CREATE INDEX IF NOT EXISTS rolling_posts_window_idx   ON rolling_posts (window_start);
+CREATE INDEX IF NOT EXISTS llm_jobs_post_idx          ON llm_jobs (post_id);
+CREATE INDEX IF NOT EXISTS lastfm_cache_fetched_idx   ON lastfm_cache (fetched_at);
+CREATE INDEX IF NOT EXISTS piped_cache_fetched_idx    ON piped_cache (fetched_at);
+CREATE INDEX IF NOT EXISTS qobuz_cache_fetched_idx    ON qobuz_cache (fetched_at);
+CREATE INDEX IF NOT EXISTS article_cache_fetched_idx  ON article_cache (fetched_at);
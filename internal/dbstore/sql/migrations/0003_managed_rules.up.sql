@@ -0,0 +1,3 @@
+-- Rules the RULES_FILE reconciler owns. Slash commands refuse to edit them,
+-- and the reconciler deletes the ones the file stops listing.
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS managed BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE rolling_posts DROP COLUMN window_end;
@@ -0,0 +1,3 @@
+-- A digest closed or reopened by hand. When set, the digest is open until
+-- window_end instead of window_start + the rule's window_hours.
+ALTER TABLE rolling_posts ADD COLUMN IF NOT EXISTS window_end TIMESTAMPTZ;
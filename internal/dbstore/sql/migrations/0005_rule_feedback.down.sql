@@ -0,0 +1,2 @@
+ALTER TABLE rules DROP COLUMN exclude_terms;
+DROP TABLE IF EXISTS rule_feedback;
@@ -0,0 +1,18 @@
+-- Votes on matched posts from the digest feedback buttons, one per user for
+-- each (post, rule). title is the post's title when the vote was cast; the
+-- posts table doesn't keep it, and exclusion suggestions are drawn from it.
+CREATE TABLE IF NOT EXISTS rule_feedback (
+    id         SERIAL PRIMARY KEY,
+    post_id    INT         NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    rule_id    INT         NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    user_id    TEXT        NOT NULL,
+    vote       TEXT        NOT NULL,
+    title      TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (post_id, rule_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS rule_feedback_rule_idx ON rule_feedback (rule_id);
+
+-- Case-insensitive terms that stop a rule from matching a post whose title
+-- contains any of them.
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS exclude_terms TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+ALTER TABLE rules DROP COLUMN quiet_hours;
+ALTER TABLE rules DROP COLUMN paused_until;
+ALTER TABLE rules DROP COLUMN enabled;
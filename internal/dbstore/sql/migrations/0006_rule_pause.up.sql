@@ -0,0 +1,8 @@
+-- Per-rule pause state. enabled is cleared by /pause_rule; paused_until is
+-- set by /snooze_rule. Neither deletes the rule or its digests.
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS paused_until TIMESTAMPTZ NULL;
+
+-- 'HH:MM-HH:MM' in the bot's timezone, or '' for none. Matches during quiet
+-- hours are queued as deliver_match jobs due when the quiet hours end.
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS quiet_hours TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Keeps the oldest deliver_match job per (channel, post); the others' held
+-- matches are dropped.
+DELETE FROM llm_jobs j
+USING llm_jobs k
+WHERE j.kind = k.kind AND j.channel_id = k.channel_id AND j.post_id = k.post_id AND j.id > k.id;
+DROP INDEX IF EXISTS llm_jobs_key;
+ALTER TABLE llm_jobs DROP COLUMN IF EXISTS rule_key;
+ALTER TABLE llm_jobs ADD CONSTRAINT llm_jobs_kind_channel_id_post_id_key UNIQUE (kind, channel_id, post_id);
@@ -0,0 +1,8 @@
+-- A deliver_match job holds one rule's match, so two rules in a channel
+-- matching the same post during quiet hours each need their own row. Other
+-- kinds stay one job per (kind, channel, post). rule_key is rule_id for
+-- deliver_match and 0 otherwise.
+ALTER TABLE llm_jobs DROP CONSTRAINT IF EXISTS llm_jobs_kind_channel_id_post_id_key;
+ALTER TABLE llm_jobs ADD COLUMN IF NOT EXISTS rule_key INT
+    GENERATED ALWAYS AS (CASE WHEN kind = 'deliver_match' THEN rule_id ELSE 0 END) STORED;
+CREATE UNIQUE INDEX IF NOT EXISTS llm_jobs_key ON llm_jobs (kind, channel_id, post_id, rule_key);
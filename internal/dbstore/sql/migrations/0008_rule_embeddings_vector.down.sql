@@ -0,0 +1,20 @@
+-- Back to REAL[]. The extension is dropped too unless something else in
+-- the database still uses it.
+DO $$
+BEGIN
+    IF (
+        SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+        WHERE  attrelid = 'rule_embeddings'::regclass AND attname = 'embedding'
+    ) = 'vector' THEN
+        EXECUTE 'ALTER TABLE rule_embeddings ALTER COLUMN embedding TYPE REAL[] USING embedding::real[]';
+    END IF;
+END
+$$;
+
+DO $$
+BEGIN
+    DROP EXTENSION IF EXISTS vector;
+EXCEPTION WHEN dependent_objects_still_exist OR insufficient_privilege THEN
+    RAISE NOTICE 'keeping the vector extension: %', SQLERRM;
+END
+$$;
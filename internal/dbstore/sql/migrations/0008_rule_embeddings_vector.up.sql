@@ -0,0 +1,23 @@
+-- Stores rule embeddings in a pgvector column when the extension is
+-- installed or can be created. Without it (not available, or the role may
+-- not create it) the REAL[] column stays and similarity is computed in Go
+-- either way, so the migration still succeeds. A database that gains
+-- pgvector later can revert and re-apply this migration with `migrate`.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS vector;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'pgvector unavailable, rule_embeddings.embedding stays REAL[]: %', SQLERRM;
+END
+$$;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector') AND (
+        SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+        WHERE  attrelid = 'rule_embeddings'::regclass AND attname = 'embedding'
+    ) <> 'vector' THEN
+        EXECUTE 'ALTER TABLE rule_embeddings ALTER COLUMN embedding TYPE vector USING embedding::vector';
+    END IF;
+END
+$$;
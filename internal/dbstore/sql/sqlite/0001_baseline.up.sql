@@ -0,0 +1,180 @@
+-- SQLite baseline: the same tables as the Postgres 0001_baseline, with the
+-- same cut line. Postgres arrays and JSONB are TEXT holding JSON arrays;
+-- timestamps are INTEGER unix microseconds (UTC) and DATE columns TEXT
+-- 'YYYY-MM-DD'. Times are written by the store, not by column defaults, so
+-- every comparison uses the store's clock.
+
+CREATE TABLE IF NOT EXISTS discord_servers (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id  TEXT    NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS discord_channels (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id      TEXT    NOT NULL UNIQUE,
+    server_id       INTEGER NOT NULL REFERENCES discord_servers(id) ON DELETE CASCADE,
+    prompt_template TEXT    NOT NULL DEFAULT '',
+    tone            TEXT    NOT NULL DEFAULT '',
+    language        TEXT    NOT NULL DEFAULT '',
+    created_at      INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS subreddits (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    subreddit_id TEXT    NOT NULL UNIQUE,
+    created_at   INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS rules (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    target          TEXT    NOT NULL,
+    target_id       TEXT    NOT NULL,
+    exact           INTEGER NOT NULL DEFAULT 0,
+    channel_id      INTEGER NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    subreddit_id    INTEGER NOT NULL REFERENCES subreddits(id)       ON DELETE CASCADE,
+    mode            TEXT    NOT NULL DEFAULT 'narrative',
+    window_hours    INTEGER NOT NULL DEFAULT 72,
+    prompt_template TEXT    NOT NULL DEFAULT '',
+    tone            TEXT    NOT NULL DEFAULT '',
+    threshold       REAL    NOT NULL DEFAULT 0,
+    created_at      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS rules_subreddit_id_idx ON rules(subreddit_id);
+CREATE INDEX IF NOT EXISTS rules_channel_id_idx   ON rules(channel_id);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id    TEXT    NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id    INTEGER NOT NULL REFERENCES posts(id)            ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    rule_id    INTEGER NOT NULL REFERENCES rules(id)            ON DELETE CASCADE,
+    created_at INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (post_id, channel_id, rule_id)
+);
+
+-- Rolling digest: one row per active (channel, mode) window. See the
+-- Postgres baseline for the column history.
+CREATE TABLE IF NOT EXISTS rolling_posts (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id          INTEGER NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    subreddit_id        INTEGER REFERENCES subreddits(id) ON DELETE CASCADE,
+    subreddit_ids       TEXT    NOT NULL DEFAULT '[]',
+    day_local           TEXT    NOT NULL,
+    window_start        INTEGER NOT NULL,
+    mode                TEXT    NOT NULL DEFAULT 'narrative',
+    discord_message_ids TEXT    NOT NULL DEFAULT '[]',
+    thread_id           TEXT    NOT NULL DEFAULT '',
+    thread_message_ids  TEXT    NOT NULL DEFAULT '[]',
+    narrative_title     TEXT    NOT NULL DEFAULT '',
+    narrative_summary   TEXT    NOT NULL DEFAULT '',
+    entries             TEXT    NOT NULL DEFAULT '[]',
+    included_post_ids   TEXT    NOT NULL DEFAULT '[]',
+    included_rule_ids   TEXT    NOT NULL DEFAULT '[]',
+    latest_score        INTEGER NOT NULL DEFAULT 0,
+    latest_comments     INTEGER NOT NULL DEFAULT 0,
+    latest_url          TEXT    NOT NULL DEFAULT '',
+    latest_thumbnail    TEXT    NOT NULL DEFAULT '',
+    updated_at          INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS rolling_posts_active_by_mode_idx
+  ON rolling_posts (channel_id, mode, window_start DESC);
+
+CREATE TABLE IF NOT EXISTS lastfm_cache (
+    artist_key TEXT    PRIMARY KEY,
+    listeners  INTEGER NOT NULL DEFAULT 0,
+    tags       TEXT    NOT NULL DEFAULT '[]',
+    fetched_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS piped_cache (
+    query_key   TEXT    PRIMARY KEY,
+    youtube_url TEXT    NOT NULL DEFAULT '',
+    fetched_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS qobuz_cache (
+    query_key  TEXT    PRIMARY KEY,
+    qobuz_url  TEXT    NOT NULL DEFAULT '',
+    fetched_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS article_cache (
+    url         TEXT    PRIMARY KEY,
+    title       TEXT    NOT NULL DEFAULT '',
+    description TEXT    NOT NULL DEFAULT '',
+    image_url   TEXT    NOT NULL DEFAULT '',
+    body        TEXT    NOT NULL DEFAULT '',
+    fetched_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS llm_cache (
+    cache_key  TEXT    PRIMARY KEY,
+    model      TEXT    NOT NULL,
+    content    TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS llm_cache_created_idx ON llm_cache (created_at);
+
+CREATE TABLE IF NOT EXISTS llm_jobs (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind            TEXT    NOT NULL,
+    channel_id      INTEGER NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    rule_id         INTEGER NOT NULL REFERENCES rules(id)            ON DELETE CASCADE,
+    post_id         INTEGER NOT NULL REFERENCES posts(id)            ON DELETE CASCADE,
+    payload         TEXT    NOT NULL,
+    status          TEXT    NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL DEFAULT 6,
+    next_attempt_at INTEGER NOT NULL,
+    last_error      TEXT    NOT NULL DEFAULT '',
+    created_at      INTEGER NOT NULL,
+    updated_at      INTEGER NOT NULL,
+    UNIQUE (kind, channel_id, post_id)
+);
+CREATE INDEX IF NOT EXISTS llm_jobs_due_idx ON llm_jobs (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    name       TEXT    NOT NULL,
+    kind       TEXT    NOT NULL,
+    body       TEXT    NOT NULL,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (name, kind)
+);
+
+CREATE TABLE IF NOT EXISTS post_classifications (
+    post_id       TEXT    PRIMARY KEY,
+    sentiment     TEXT    NOT NULL DEFAULT '',
+    topics        TEXT    NOT NULL DEFAULT '[]',
+    toxic         INTEGER NOT NULL DEFAULT 0,
+    classified_at INTEGER NOT NULL
+);
+
+-- embedding is a JSON array of floats; similarity is computed in Go.
+CREATE TABLE IF NOT EXISTS rule_embeddings (
+    rule_id    INTEGER PRIMARY KEY REFERENCES rules(id) ON DELETE CASCADE,
+    model      TEXT    NOT NULL,
+    source     TEXT    NOT NULL,
+    embedding  TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    rolling_post_id   INTEGER NOT NULL REFERENCES rolling_posts(id) ON DELETE CASCADE,
+    post_id           TEXT    NOT NULL,
+    canonical_post_id TEXT    NOT NULL,
+    subreddit         TEXT    NOT NULL,
+    title             TEXT    NOT NULL DEFAULT '',
+    crosspost_parent  TEXT    NOT NULL DEFAULT '',
+    url               TEXT    NOT NULL DEFAULT '',
+    title_hash        INTEGER NOT NULL DEFAULT 0,
+    score             INTEGER NOT NULL DEFAULT 0,
+    comments          INTEGER NOT NULL DEFAULT 0,
+    seen_at           INTEGER NOT NULL,
+    PRIMARY KEY (rolling_post_id, post_id)
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS article_cache_fetched_idx;
+DROP INDEX IF EXISTS qobuz_cache_fetched_idx;
+DROP INDEX IF EXISTS piped_cache_fetched_idx;
+DROP INDEX IF EXISTS lastfm_cache_fetched_idx;
+DROP INDEX IF EXISTS llm_jobs_post_idx;
+DROP INDEX IF EXISTS rolling_posts_window_idx;
+DROP INDEX IF EXISTS posts_created_idx;
+DROP INDEX IF EXISTS notifications_created_idx;
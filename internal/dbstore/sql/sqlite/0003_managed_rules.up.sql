@@ -0,0 +1,2 @@
+-- Rules the RULES_FILE reconciler owns; see the Postgres migration.
+ALTER TABLE rules ADD COLUMN managed INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- A digest closed or reopened by hand; see the Postgres migration.
+ALTER TABLE rolling_posts ADD COLUMN window_end INTEGER;
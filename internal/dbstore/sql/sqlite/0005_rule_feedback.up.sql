@@ -0,0 +1,15 @@
+-- Digest feedback votes and rule exclusion terms; see the Postgres
+-- migration. exclude_terms is a JSON array.
+CREATE TABLE IF NOT EXISTS rule_feedback (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id    INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    rule_id    INTEGER NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    user_id    TEXT    NOT NULL,
+    vote       TEXT    NOT NULL,
+    title      TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    UNIQUE (post_id, rule_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS rule_feedback_rule_idx ON rule_feedback (rule_id);
+
+ALTER TABLE rules ADD COLUMN exclude_terms TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,5 @@
+-- Per-rule pause state and quiet hours; see the Postgres migration.
+-- paused_until is unix microseconds.
+ALTER TABLE rules ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE rules ADD COLUMN paused_until INTEGER NULL;
+ALTER TABLE rules ADD COLUMN quiet_hours TEXT NOT NULL DEFAULT '';
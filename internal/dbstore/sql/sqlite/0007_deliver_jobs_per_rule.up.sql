@@ -0,0 +1,28 @@
+-- One deliver_match job per rule; see the Postgres migration. SQLite can't
+-- drop a table constraint, so llm_jobs is rebuilt.
+CREATE TABLE llm_jobs_new (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind            TEXT    NOT NULL,
+    channel_id      INTEGER NOT NULL REFERENCES discord_channels(id) ON DELETE CASCADE,
+    rule_id         INTEGER NOT NULL REFERENCES rules(id)            ON DELETE CASCADE,
+    post_id         INTEGER NOT NULL REFERENCES posts(id)            ON DELETE CASCADE,
+    payload         TEXT    NOT NULL,
+    status          TEXT    NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL DEFAULT 6,
+    next_attempt_at INTEGER NOT NULL,
+    last_error      TEXT    NOT NULL DEFAULT '',
+    created_at      INTEGER NOT NULL,
+    updated_at      INTEGER NOT NULL,
+    rule_key        INTEGER GENERATED ALWAYS AS (CASE WHEN kind = 'deliver_match' THEN rule_id ELSE 0 END) VIRTUAL,
+    UNIQUE (kind, channel_id, post_id, rule_key)
+);
+INSERT INTO llm_jobs_new (id, kind, channel_id, rule_id, post_id, payload, status, attempts, max_attempts,
+                          next_attempt_at, last_error, created_at, updated_at)
+SELECT id, kind, channel_id, rule_id, post_id, payload, status, attempts, max_attempts,
+       next_attempt_at, last_error, created_at, updated_at
+FROM llm_jobs;
+DROP TABLE llm_jobs;
+ALTER TABLE llm_jobs_new RENAME TO llm_jobs;
+CREATE INDEX IF NOT EXISTS llm_jobs_due_idx  ON llm_jobs (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS llm_jobs_post_idx ON llm_jobs (post_id);
@@ -0,0 +1,3 @@
+-- Nothing to do: SQLite stores rule embeddings as JSON and has no pgvector.
+-- The migration exists so the numbering mirrors Postgres.
+SELECT 1;
@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	// Registers the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sql/sqlite/*.sql
+var sqliteMigrationFS embed.FS
+
+// SQLiteStore is the Store for single-node deployments: one database file,
+// no server. It keeps the Postgres store's semantics with SQLite types —
+// arrays and JSONB are JSON text, timestamps unix microseconds — and is
+// checked against PGXStore by the storetest conformance suite.
+type SQLiteStore struct {
+	*sql.DB
+}
+
+// NewSQLite opens (creating if needed) the database file at path. It holds
+// a single connection: SQLite allows one writer at a time anyway, and one
+// connection keeps every statement on the same pragmas.
+func NewSQLite(path string) (*SQLiteStore, error) {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database %q: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+
+	pctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeout)
+	defer cancel()
+	if err := db.PingContext(pctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to open sqlite database %q: %w", path, err)
+	}
+	return &SQLiteStore{DB: db}, nil
+}
+
+// Close closes the database. It shadows sql.DB's Close so SQLiteStore
+// satisfies Backend like pgxpool.Pool's.
+func (db *SQLiteStore) Close() {
+	_ = db.DB.Close()
+}
+
+// Bootstrap applies pending migrations. There's no role, database or
+// extension to set up.
+func (db *SQLiteStore) Bootstrap(ctx context.Context) error {
+	if _, err := db.MigrateUp(ctx); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	return nil
+}
+
+// EmbeddingStorage reports "json": embeddings are JSON arrays compared in
+// Go.
+func (db *SQLiteStore) EmbeddingStorage(context.Context) (string, error) {
+	return "json", nil
+}
+
+// SQLiteMigrations returns the embedded SQLite migrations in version order.
+// They mirror Migrations version for version.
+func SQLiteMigrations() ([]Migration, error) {
+	return parseMigrations(sqliteMigrationFS, "sql/sqlite")
+}
+
+const createSQLiteLedgerSQL = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT    NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`
+
+// MigrationStatus lists every embedded migration with when it was applied,
+// if it has been. Like PGXStore's, it only reads.
+func (db *SQLiteStore) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := SQLiteMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var hasLedger bool
+	if err := db.QueryRowContext(ctx,
+		`SELECT count(1) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+	).Scan(&hasLedger); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	applied := map[int]time.Time{}
+	if hasLedger {
+		if applied, err = sqliteAppliedMigrations(ctx, db.DB); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// MigrateUp applies every pending migration in version order and returns
+// the ones it ran. Each runs in a BEGIN IMMEDIATE transaction with its
+// ledger row, which also keeps a second process from applying it twice.
+func (db *SQLiteStore) MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrations, err := SQLiteMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	err = db.withMigrationConn(ctx, func(conn *sql.Conn) error {
+		for _, m := range migrations {
+			ok, err := runSQLiteMigration(ctx, conn, m, true)
+			if err != nil {
+				return fmt.Errorf("apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if ok {
+				ran = append(ran, m)
+			}
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first,
+// with the same stopping rule as PGXStore's.
+func (db *SQLiteStore) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := SQLiteMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var reverted []Migration
+	err = db.withMigrationConn(ctx, func(conn *sql.Conn) error {
+		applied, err := sqliteAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down migration", m.Version, m.Name)
+			}
+			ok, err := runSQLiteMigration(ctx, conn, m, false)
+			if err != nil {
+				return fmt.Errorf("revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if ok {
+				reverted = append(reverted, m)
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// withMigrationConn runs fn on a dedicated connection after creating the
+// ledger.
+func (db *SQLiteStore) withMigrationConn(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, createSQLiteLedgerSQL); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// runSQLiteMigration applies (up) or reverts m in one write transaction,
+// unless the ledger shows another process already did. It reports whether
+// it ran.
+func runSQLiteMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) (ran bool, err error) {
+	// database/sql's BeginTx can't ask for IMMEDIATE, which takes the write
+	// lock before the ledger is read.
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_, _ = conn.ExecContext(context.Background(), `ROLLBACK`)
+		}
+	}()
+
+	var applied bool
+	if err := conn.QueryRowContext(ctx,
+		`SELECT count(1) > 0 FROM schema_migrations WHERE version = $1`, m.Version,
+	).Scan(&applied); err != nil {
+		return false, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	if applied == up {
+		_, err = conn.ExecContext(ctx, `COMMIT`)
+		return false, err
+	}
+	if up {
+		if _, err := conn.ExecContext(ctx, m.Up); err != nil {
+			return false, err
+		}
+		if _, err := conn.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+			m.Version, m.Name, unixMicros(time.Now()),
+		); err != nil {
+			return false, fmt.Errorf("update schema_migrations: %w", err)
+		}
+	} else {
+		if _, err := conn.ExecContext(ctx, m.Down); err != nil {
+			return false, err
+		}
+		if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return false, fmt.Errorf("update schema_migrations: %w", err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sqlQuerier is what sqliteAppliedMigrations needs from a DB or a Conn.
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// sqliteAppliedMigrations reads the ledger: version → applied_at.
+func sqliteAppliedMigrations(ctx context.Context, q sqlQuerier) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	out := map[int]time.Time{}
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, microsColumn{&at}); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		out[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	return out, nil
+}
+
+// unixMicros is how SQLiteStore stores a timestamp.
+func unixMicros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+// microsColumn scans a unix-microsecond INTEGER column into a UTC time.
+type microsColumn struct{ t *time.Time }
+
+func (c microsColumn) Scan(src any) error {
+	n, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("timestamp column: want INTEGER, got %T", src)
+	}
+	*c.t = time.UnixMicro(n).UTC()
+	return nil
+}
+
+// sqliteDateLayout is how DATE columns (rolling_posts.day_local) are
+// stored. Like pgx reading a DATE, they scan back as UTC midnight.
+const sqliteDateLayout = "2006-01-02"
+
+// dateColumn scans a 'YYYY-MM-DD' TEXT column.
+type dateColumn struct{ t *time.Time }
+
+func (c dateColumn) Scan(src any) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("date column: want TEXT, got %T", src)
+	}
+	t, err := time.Parse(sqliteDateLayout, s)
+	if err != nil {
+		return fmt.Errorf("date column: %w", err)
+	}
+	*c.t = t
+	return nil
+}
+
+// jsonColumn scans a JSON TEXT column (the SQLite form of an array) into
+// dst.
+type jsonColumn struct{ dst any }
+
+func (c jsonColumn) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), c.dst)
+	case []byte:
+		return json.Unmarshal(v, c.dst)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("json column: want TEXT, got %T", src)
+}
+
+// jsonArray encodes v for a JSON array column; nil encodes as [] so the
+// column never holds null. Marshalling ints and strings can't fail.
+func jsonArray[T ~int | ~string](v []T) string {
+	if v == nil {
+		return "[]"
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// rowScanner is *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
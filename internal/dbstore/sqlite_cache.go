@@ -0,0 +1,373 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLiteStore's cache tables: Last.fm, Piped, Qobuz, articles, LLM
+// completions, post classifications, rule embeddings and digest items.
+
+func (db *SQLiteStore) GetLastfmListeners(parent context.Context, artistKey string) (int, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var listeners int
+	var fetchedAt time.Time
+	err := db.QueryRowContext(qctx, `SELECT listeners, fetched_at FROM lastfm_cache WHERE artist_key = $1`, artistKey).
+		Scan(&listeners, microsColumn{&fetchedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, time.Time{}, false, nil
+		}
+		return 0, time.Time{}, false, fmt.Errorf("failed to read lastfm cache: %w", err)
+	}
+	return listeners, fetchedAt, true, nil
+}
+
+func (db *SQLiteStore) UpsertLastfmListeners(parent context.Context, artistKey string, listeners int) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(qctx, `
+		INSERT INTO lastfm_cache (artist_key, listeners, fetched_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (artist_key) DO UPDATE
+		SET listeners = excluded.listeners, fetched_at = excluded.fetched_at
+	`, artistKey, listeners, unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to upsert lastfm cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetLastfmArtist(parent context.Context, artistKey string) (int, []string, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var listeners int
+	var tags []string
+	var fetchedAt time.Time
+	err := db.QueryRowContext(qctx, `SELECT listeners, tags, fetched_at FROM lastfm_cache WHERE artist_key = $1`, artistKey).
+		Scan(&listeners, jsonColumn{&tags}, microsColumn{&fetchedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, time.Time{}, false, nil
+		}
+		return 0, nil, time.Time{}, false, fmt.Errorf("failed to read lastfm cache: %w", err)
+	}
+	return listeners, tags, fetchedAt, true, nil
+}
+
+func (db *SQLiteStore) UpsertLastfmArtist(parent context.Context, artistKey string, listeners int, tags []string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(qctx, `
+		INSERT INTO lastfm_cache (artist_key, listeners, tags, fetched_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (artist_key) DO UPDATE
+		SET listeners  = excluded.listeners,
+		    tags       = excluded.tags,
+		    fetched_at = excluded.fetched_at
+	`, artistKey, listeners, jsonArray(tags), unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to upsert lastfm artist cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetPipedVideo(parent context.Context, queryKey string) (string, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var youtubeURL string
+	var fetchedAt time.Time
+	err := db.QueryRowContext(qctx, `SELECT youtube_url, fetched_at FROM piped_cache WHERE query_key = $1`, queryKey).
+		Scan(&youtubeURL, microsColumn{&fetchedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, false, nil
+		}
+		return "", time.Time{}, false, fmt.Errorf("failed to read piped cache: %w", err)
+	}
+	return youtubeURL, fetchedAt, true, nil
+}
+
+func (db *SQLiteStore) UpsertPipedVideo(parent context.Context, queryKey, youtubeURL string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(qctx, `
+		INSERT INTO piped_cache (query_key, youtube_url, fetched_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (query_key) DO UPDATE
+		SET youtube_url = excluded.youtube_url, fetched_at = excluded.fetched_at
+	`, queryKey, youtubeURL, unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to upsert piped cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetQobuzAlbum(parent context.Context, queryKey string) (string, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var qobuzURL string
+	var fetchedAt time.Time
+	err := db.QueryRowContext(qctx, `SELECT qobuz_url, fetched_at FROM qobuz_cache WHERE query_key = $1`, queryKey).
+		Scan(&qobuzURL, microsColumn{&fetchedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, false, nil
+		}
+		return "", time.Time{}, false, fmt.Errorf("failed to read qobuz cache: %w", err)
+	}
+	return qobuzURL, fetchedAt, true, nil
+}
+
+func (db *SQLiteStore) UpsertQobuzAlbum(parent context.Context, queryKey, qobuzURL string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(qctx, `
+		INSERT INTO qobuz_cache (query_key, qobuz_url, fetched_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (query_key) DO UPDATE
+		SET qobuz_url = excluded.qobuz_url, fetched_at = excluded.fetched_at
+	`, queryKey, qobuzURL, unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to upsert qobuz cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetCachedArticle(parent context.Context, url string) (*CachedArticle, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var a CachedArticle
+	err := db.QueryRowContext(qctx,
+		`SELECT url, title, description, image_url, body, fetched_at FROM article_cache WHERE url = $1`,
+		url,
+	).Scan(&a.URL, &a.Title, &a.Description, &a.ImageURL, &a.Body, microsColumn{&a.FetchedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read article cache: %w", err)
+	}
+	return &a, nil
+}
+
+func (db *SQLiteStore) UpsertCachedArticle(parent context.Context, a CachedArticle) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO article_cache (url, title, description, image_url, body, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (url) DO UPDATE
+		SET title = excluded.title, description = excluded.description,
+		    image_url = excluded.image_url, body = excluded.body, fetched_at = excluded.fetched_at
+	`
+	if _, err := db.ExecContext(qctx, query, a.URL, a.Title, a.Description, a.ImageURL, a.Body, unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to upsert article cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetLLMCompletion(parent context.Context, key string) (string, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var content string
+	var createdAt time.Time
+	err := db.QueryRowContext(qctx, `SELECT content, created_at FROM llm_cache WHERE cache_key = $1`, key).
+		Scan(&content, microsColumn{&createdAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, false, nil
+		}
+		return "", time.Time{}, false, fmt.Errorf("failed to read llm cache: %w", err)
+	}
+	return content, createdAt, true, nil
+}
+
+func (db *SQLiteStore) UpsertLLMCompletion(parent context.Context, key, model, content string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(qctx, `
+		INSERT INTO llm_cache (cache_key, model, content, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (cache_key) DO UPDATE
+		SET model = excluded.model, content = excluded.content, created_at = excluded.created_at
+	`, key, model, content, unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to upsert llm cache: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) PruneLLMCompletions(parent context.Context, maxAge time.Duration, maxRows int) (int64, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(qctx, `DELETE FROM llm_cache WHERE created_at < $1`, unixMicros(time.Now().Add(-maxAge)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune expired llm cache rows: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+	if maxRows > 0 {
+		// SQLite only takes OFFSET after a LIMIT; -1 is "no limit".
+		res, err = db.ExecContext(qctx, `
+			DELETE FROM llm_cache WHERE cache_key IN (
+				SELECT cache_key FROM llm_cache ORDER BY created_at DESC LIMIT -1 OFFSET $1
+			)
+		`, maxRows)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune llm cache to size: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
+func (db *SQLiteStore) GetPostClassification(parent context.Context, postID string) (*PostClassification, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var pc PostClassification
+	err := db.QueryRowContext(qctx,
+		`SELECT post_id, sentiment, topics, toxic, classified_at FROM post_classifications WHERE post_id = $1`,
+		postID,
+	).Scan(&pc.PostID, &pc.Sentiment, jsonColumn{&pc.Topics}, &pc.Toxic, microsColumn{&pc.ClassifiedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read post classification: %w", err)
+	}
+	return &pc, nil
+}
+
+func (db *SQLiteStore) UpsertPostClassification(parent context.Context, pc PostClassification) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO post_classifications (post_id, sentiment, topics, toxic, classified_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (post_id) DO UPDATE
+		SET sentiment = excluded.sentiment, topics = excluded.topics,
+		    toxic = excluded.toxic, classified_at = excluded.classified_at
+	`
+	if _, err := db.ExecContext(qctx, query, pc.PostID, pc.Sentiment, jsonArray(pc.Topics), pc.Toxic, unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to upsert post classification: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetRuleEmbedding(parent context.Context, ruleID int) (*RuleEmbedding, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var re RuleEmbedding
+	err := db.QueryRowContext(qctx,
+		`SELECT rule_id, model, source, embedding, created_at FROM rule_embeddings WHERE rule_id = $1`,
+		ruleID,
+	).Scan(&re.RuleID, &re.Model, &re.Source, jsonColumn{&re.Embedding}, microsColumn{&re.CreatedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read rule embedding: %w", err)
+	}
+	return &re, nil
+}
+
+func (db *SQLiteStore) UpsertRuleEmbedding(parent context.Context, re RuleEmbedding) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	// Unlike jsonArray's ints and strings, floats can fail to encode (NaN).
+	embedding, err := json.Marshal(re.Embedding)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule embedding: %w", err)
+	}
+	query := `
+		INSERT INTO rule_embeddings (rule_id, model, source, embedding, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (rule_id) DO UPDATE
+		SET model = excluded.model, source = excluded.source,
+		    embedding = excluded.embedding, created_at = excluded.created_at
+	`
+	if _, err := db.ExecContext(qctx, query, re.RuleID, re.Model, re.Source, string(embedding), unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to upsert rule embedding: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetDigestItems(parent context.Context, rollingPostID int) ([]DigestItem, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, `
+		SELECT rolling_post_id, post_id, canonical_post_id, subreddit, title,
+		       crosspost_parent, url, title_hash, score, comments, seen_at
+		FROM digest_items
+		WHERE rolling_post_id = $1
+		ORDER BY seen_at, post_id`,
+		rollingPostID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest items: %w", err)
+	}
+	defer rows.Close()
+
+	var out []DigestItem
+	for rows.Next() {
+		var it DigestItem
+		var hash int64
+		if err := rows.Scan(&it.RollingPostID, &it.PostID, &it.CanonicalPostID, &it.Subreddit, &it.Title,
+			&it.CrosspostParent, &it.URL, &hash, &it.Score, &it.Comments, microsColumn{&it.SeenAt}); err != nil {
+			return nil, fmt.Errorf("failed to scan digest item: %w", err)
+		}
+		it.TitleHash = uint64(hash)
+		out = append(out, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read digest items: %w", err)
+	}
+	return out, nil
+}
+
+func (db *SQLiteStore) InsertDigestItem(parent context.Context, it DigestItem) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	canonical := it.CanonicalPostID
+	if canonical == "" {
+		canonical = it.PostID
+	}
+	// title_hash is a signed 64-bit INTEGER, as in Postgres.
+	query := `
+		INSERT INTO digest_items (
+			rolling_post_id, post_id, canonical_post_id, subreddit, title,
+			crosspost_parent, url, title_hash, score, comments, seen_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (rolling_post_id, post_id) DO NOTHING
+	`
+	if _, err := db.ExecContext(qctx, query, it.RollingPostID, it.PostID, canonical, it.Subreddit, it.Title,
+		it.CrosspostParent, it.URL, int64(it.TitleHash), it.Score, it.Comments, unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to insert digest item: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *SQLiteStore) CloseRollingPost(parent context.Context, id int) error {
+	return db.setWindowEnd(parent, "close", id, time.Now())
+}
+
+func (db *SQLiteStore) ReopenRollingPost(parent context.Context, id int, until time.Time) error {
+	return db.setWindowEnd(parent, "reopen", id, until)
+}
+
+func (db *SQLiteStore) setWindowEnd(parent context.Context, verb string, id int, end time.Time) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(qctx, `UPDATE rolling_posts SET window_end = $2 WHERE id = $1`, id, unixMicros(end))
+	if err != nil {
+		return fmt.Errorf("failed to %s rolling post %d: %w", verb, id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("rolling post %d not found", id)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) MergeRollingPosts(parent context.Context, into RollingPost, fromID int) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(qctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin digest merge: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(qctx, `
+		UPDATE digest_items SET rolling_post_id = $1
+		WHERE rolling_post_id = $2
+		  AND post_id NOT IN (SELECT post_id FROM digest_items WHERE rolling_post_id = $1)
+	`, into.ID, fromID); err != nil {
+		return nil, fmt.Errorf("failed to move digest items: %w", err)
+	}
+	res, err := tx.ExecContext(qctx, `
+		DELETE FROM rolling_posts
+		WHERE id = $1 AND channel_id = (SELECT channel_id FROM rolling_posts WHERE id = $2)
+	`, fromID, into.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete merged rolling post: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("rolling post %d not found in the channel of %d", fromID, into.ID)
+	}
+	out, err := scanSQLiteRollingPost(tx.QueryRowContext(qctx, sqliteUpdateRollingPostSQL, sqliteRollingPostUpdateArgs(into)...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("rolling post %d not found", into.ID)
+		}
+		return nil, fmt.Errorf("failed to update merged rolling post: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit digest merge: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (db *SQLiteStore) RecordFeedback(parent context.Context, fb Feedback) error {
+	if err := validVote(fb.Vote); err != nil {
+		return err
+	}
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	// The WHERE true keeps SQLite from reading ON CONFLICT as a join
+	// constraint of the SELECT.
+	res, err := db.ExecContext(qctx, `
+		INSERT INTO rule_feedback (post_id, rule_id, user_id, vote, title, created_at)
+		SELECT p.id, $2, $3, $4, $5, $6 FROM posts p WHERE p.post_id = $1 AND true
+		ON CONFLICT (post_id, rule_id, user_id)
+		DO UPDATE SET vote = excluded.vote, title = excluded.title, created_at = excluded.created_at
+	`, strings.ToLower(fb.PostID), fb.RuleID, fb.UserID, fb.Vote, fb.Title, unixMicros(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("post %s not found", fb.PostID)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetNotifiedRules(parent context.Context, channelID int, postID string) ([]int, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, notifiedRulesSQL, channelID, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notified rules: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan notified rule: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating notified rules: %w", err)
+	}
+	return ids, nil
+}
+
+func (db *SQLiteStore) GetRuleStats(parent context.Context, channelID int) ([]RuleStats, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, ruleStatsSQL, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule stats: %w", err)
+	}
+	defer rows.Close()
+
+	var out []RuleStats
+	for rows.Next() {
+		var st RuleStats
+		if err := rows.Scan(&st.RuleID, &st.Matches, &st.Up, &st.Down, &st.Irrelevant); err != nil {
+			return nil, fmt.Errorf("failed to scan rule stats: %w", err)
+		}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule stats: %w", err)
+	}
+	return out, nil
+}
+
+func (db *SQLiteStore) ListRuleFeedback(parent context.Context, ruleID int) ([]Feedback, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, `SELECT `+ruleFeedbackCols+ruleFeedbackFrom, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Feedback
+	for rows.Next() {
+		var fb Feedback
+		if err := rows.Scan(&fb.PostID, &fb.RuleID, &fb.UserID, &fb.Vote, &fb.Title, microsColumn{&fb.CreatedAt}); err != nil {
+			return nil, fmt.Errorf("failed to scan rule feedback: %w", err)
+		}
+		out = append(out, fb)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule feedback: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (db *SQLiteStore) GetGuildConfig(parent context.Context, serverID string) ([]GuildChannel, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, guildChannelsSQL, serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query guild channels: %w", err)
+	}
+	var (
+		channels []GuildChannel
+		ids      []int
+	)
+	for rows.Next() {
+		var (
+			id int
+			ch GuildChannel
+		)
+		if err := rows.Scan(&id, &ch.ExternalID, &ch.PromptTemplate, &ch.Tone, &ch.Language); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to scan guild channel row: %w", err)
+		}
+		channels = append(channels, ch)
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating guild channel rows: %w", err)
+	}
+
+	rows, err = db.QueryContext(qctx, guildRulesSQL, serverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query guild rules: %w", err)
+	}
+	defer rows.Close()
+	var rules []guildRuleRow
+	for rows.Next() {
+		var r guildRuleRow
+		if err := rows.Scan(r.scanTargets(jsonColumn{&r.rule.ExcludeTerms})...); err != nil {
+			return nil, fmt.Errorf("failed to scan guild rule row: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating guild rule rows: %w", err)
+	}
+	return groupGuildRules(channels, ids, rules), nil
+}
+
+func (db *SQLiteStore) ApplyGuildConfig(parent context.Context, serverID string, channels []GuildChannel) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(qctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin guild config: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	guildID, keep, err := applyGuildSQLite(qctx, tx, serverID, channels, false)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(qctx, `
+		DELETE FROM rules
+		WHERE channel_id IN (SELECT id FROM discord_channels WHERE server_id = $1)
+		  AND id NOT IN (SELECT value FROM json_each($2))
+	`, guildID, jsonArray(keep)); err != nil {
+		return fmt.Errorf("failed to delete rules: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit guild config: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) ReconcileManagedRules(parent context.Context, guilds []ManagedGuild) (int64, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(qctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin rules reconcile: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	keep := []int{}
+	for _, g := range guilds {
+		_, kept, err := applyGuildSQLite(qctx, tx, g.ServerID, g.Channels, true)
+		if err != nil {
+			return 0, fmt.Errorf("guild %s: %w", g.ServerID, err)
+		}
+		keep = append(keep, kept...)
+	}
+	res, err := tx.ExecContext(qctx, `
+		DELETE FROM rules WHERE managed AND id NOT IN (SELECT value FROM json_each($1))
+	`, jsonArray(keep))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete managed rules: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit rules reconcile: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+func applyGuildSQLite(qctx context.Context, tx *sql.Tx, serverID string, channels []GuildChannel, managed bool) (int, []int, error) {
+	now := unixMicros(time.Now())
+	var guildID int
+	if err := tx.QueryRowContext(qctx, `
+		INSERT INTO discord_servers (server_id, created_at) VALUES ($1, $2)
+		ON CONFLICT (server_id) DO UPDATE SET server_id = excluded.server_id
+		RETURNING id
+	`, strings.ToLower(serverID), now).Scan(&guildID); err != nil {
+		return 0, nil, fmt.Errorf("failed to insert server: %w", err)
+	}
+
+	keep := []int{}
+	for _, ch := range channels {
+		var channelID, owner int
+		if err := tx.QueryRowContext(qctx, `
+			INSERT INTO discord_channels (channel_id, server_id, created_at) VALUES ($1, $2, $3)
+			ON CONFLICT (channel_id) DO UPDATE SET channel_id = excluded.channel_id
+			RETURNING id, server_id
+		`, strings.ToLower(ch.ExternalID), guildID, now).Scan(&channelID, &owner); err != nil {
+			return 0, nil, fmt.Errorf("failed to insert channel %s: %w", ch.ExternalID, err)
+		}
+		if owner != guildID {
+			return 0, nil, fmt.Errorf("channel %s belongs to another server", ch.ExternalID)
+		}
+		if _, err := tx.ExecContext(qctx, updateGuildChannelSQL, channelID, ch.PromptTemplate, ch.Tone, ch.Language); err != nil {
+			return 0, nil, fmt.Errorf("failed to update channel %s: %w", ch.ExternalID, err)
+		}
+
+		for _, r := range ch.Rules {
+			r, err := prepareGuildRule(ch.ExternalID, r)
+			if err != nil {
+				return 0, nil, err
+			}
+			if r.ID != 0 {
+				res, err := tx.ExecContext(qctx, updateGuildRuleSQL,
+					r.ID, channelID, r.Exact, r.Mode, r.WindowHours, r.Threshold, r.PromptTemplate, r.Tone, managed,
+					jsonArray(r.ExcludeTerms), r.QuietHours)
+				if err != nil {
+					return 0, nil, fmt.Errorf("failed to update rule %d: %w", r.ID, err)
+				}
+				if n, _ := res.RowsAffected(); n == 0 {
+					return 0, nil, fmt.Errorf("rule %d not found in channel %s", r.ID, ch.ExternalID)
+				}
+				keep = append(keep, r.ID)
+				continue
+			}
+			var subredditID, ruleID int
+			if err := tx.QueryRowContext(qctx, `
+				INSERT INTO subreddits (subreddit_id, created_at) VALUES ($1, $2)
+				ON CONFLICT (subreddit_id) DO UPDATE SET subreddit_id = excluded.subreddit_id
+				RETURNING id
+			`, strings.ToLower(r.Subreddit), now).Scan(&subredditID); err != nil {
+				return 0, nil, fmt.Errorf("failed to insert subreddit %s: %w", r.Subreddit, err)
+			}
+			if err := tx.QueryRowContext(qctx, `
+				INSERT INTO rules (target, target_id, exact, channel_id, subreddit_id, mode, window_hours,
+				                   threshold, prompt_template, tone, managed, created_at, exclude_terms, quiet_hours)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id
+			`, strings.ToLower(r.Target), strings.ToLower(r.TargetID), r.Exact, channelID, subredditID, r.Mode,
+				r.WindowHours, r.Threshold, r.PromptTemplate, r.Tone, managed, now, jsonArray(r.ExcludeTerms), r.QuietHours).Scan(&ruleID); err != nil {
+				return 0, nil, fmt.Errorf("failed to insert rule: %w", err)
+			}
+			keep = append(keep, ruleID)
+		}
+	}
+	return guildID, keep, nil
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SQLiteStore's llm_jobs queue and prompt settings.
+
+func scanSQLiteLLMJob(row rowScanner) (*LLMJob, error) {
+	var j LLMJob
+	var payload string
+	if err := row.Scan(
+		&j.ID, &j.Kind, &j.ChannelID, &j.RuleID, &j.PostID, &payload,
+		&j.Status, &j.Attempts, &j.MaxAttempts, microsColumn{&j.NextAttemptAt},
+		&j.LastError, microsColumn{&j.CreatedAt}, microsColumn{&j.UpdatedAt},
+	); err != nil {
+		return nil, err
+	}
+	j.Payload = []byte(payload)
+	return &j, nil
+}
+
+func scanSQLiteLLMJobs(rows *sql.Rows) ([]*LLMJob, error) {
+	defer rows.Close()
+	var jobs []*LLMJob
+	for rows.Next() {
+		j, err := scanSQLiteLLMJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan llm job row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating llm job rows: %w", err)
+	}
+	return jobs, nil
+}
+
+func (db *SQLiteStore) EnqueueLLMJob(parent context.Context, job LLMJob) (*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	if job.NextAttemptAt.IsZero() {
+		job.NextAttemptAt = now
+	}
+	query := `
+		INSERT INTO llm_jobs (kind, channel_id, rule_id, post_id, payload, max_attempts, next_attempt_at, last_error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		ON CONFLICT (kind, channel_id, post_id, rule_key) DO UPDATE SET kind = excluded.kind
+		RETURNING ` + llmJobCols
+	j, err := scanSQLiteLLMJob(db.QueryRowContext(qctx, query,
+		job.Kind, job.ChannelID, job.RuleID, job.PostID, string(job.Payload),
+		job.MaxAttempts, unixMicros(job.NextAttemptAt), job.LastError, unixMicros(now),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue llm job: %w", err)
+	}
+	return j, nil
+}
+
+// ClaimDueLLMJobs claims like PGXStore's. SQLite runs one writer at a time,
+// so the single UPDATE needs no SKIP LOCKED to keep claims apart.
+func (db *SQLiteStore) ClaimDueLLMJobs(parent context.Context, limit int) ([]*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	query := `
+		UPDATE llm_jobs SET
+			status     = 'running',
+			attempts   = attempts + 1,
+			updated_at = $2
+		WHERE id IN (
+			SELECT id FROM llm_jobs
+			WHERE (status = 'pending' AND next_attempt_at <= $2)
+			   OR (status = 'running' AND updated_at < $3)
+			ORDER BY next_attempt_at
+			LIMIT $1
+		)
+		RETURNING ` + llmJobCols
+	rows, err := db.QueryContext(qctx, query, limit, unixMicros(now), unixMicros(now.Add(-jobLease)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim llm jobs: %w", err)
+	}
+	return scanSQLiteLLMJobs(rows)
+}
+
+func (db *SQLiteStore) CompleteLLMJob(parent context.Context, jobID int) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `UPDATE llm_jobs SET status = 'done', last_error = '', updated_at = $2 WHERE id = $1`
+	if _, err := db.ExecContext(qctx, query, jobID, unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to complete llm job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) FailLLMJob(parent context.Context, jobID int, lastError string, retryAt time.Time) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	now := unixMicros(time.Now())
+	var err error
+	if retryAt.IsZero() {
+		query := `UPDATE llm_jobs SET status = 'failed', last_error = $2, updated_at = $3 WHERE id = $1`
+		_, err = db.ExecContext(qctx, query, jobID, lastError, now)
+	} else {
+		query := `UPDATE llm_jobs SET status = 'pending', last_error = $2, next_attempt_at = $3, updated_at = $4 WHERE id = $1`
+		_, err = db.ExecContext(qctx, query, jobID, lastError, unixMicros(retryAt), now)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record llm job %d failure: %w", jobID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) ListFailedLLMJobs(parent context.Context, channelID int, limit int) ([]*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + llmJobCols + `
+		FROM llm_jobs
+		WHERE channel_id = $1 AND status = 'failed'
+		ORDER BY updated_at DESC
+		LIMIT $2
+	`
+	rows, err := db.QueryContext(qctx, query, channelID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed llm jobs: %w", err)
+	}
+	return scanSQLiteLLMJobs(rows)
+}
+
+func (db *SQLiteStore) GetLLMJob(parent context.Context, jobID int) (*LLMJob, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	j, err := scanSQLiteLLMJob(db.QueryRowContext(qctx, `SELECT `+llmJobCols+` FROM llm_jobs WHERE id = $1`, jobID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get llm job %d: %w", jobID, err)
+	}
+	return j, nil
+}
+
+func (db *SQLiteStore) RequeueLLMJob(parent context.Context, jobID int) (bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE llm_jobs SET
+			status          = 'pending',
+			attempts        = 0,
+			next_attempt_at = $2,
+			updated_at      = $2
+		WHERE id = $1 AND status = 'failed'
+	`
+	res, err := db.ExecContext(qctx, query, jobID, unixMicros(time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue llm job %d: %w", jobID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue llm job %d: %w", jobID, err)
+	}
+	return n > 0, nil
+}
+
+func (db *SQLiteStore) UpsertPromptTemplate(parent context.Context, name, kind, body string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO prompt_templates (name, kind, body, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name, kind) DO UPDATE
+		SET body = excluded.body, updated_at = excluded.updated_at
+	`
+	if _, err := db.ExecContext(qctx, query, name, kind, body, unixMicros(time.Now())); err != nil {
+		return fmt.Errorf("failed to save prompt template %s/%s: %w", name, kind, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetPromptTemplate(parent context.Context, name, kind string) (*PromptTemplate, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var t PromptTemplate
+	err := db.QueryRowContext(qctx,
+		`SELECT name, kind, body, updated_at FROM prompt_templates WHERE name = $1 AND kind = $2`,
+		name, kind,
+	).Scan(&t.Name, &t.Kind, &t.Body, microsColumn{&t.UpdatedAt})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get prompt template %s/%s: %w", name, kind, err)
+	}
+	return &t, nil
+}
+
+func (db *SQLiteStore) ListPromptTemplates(parent context.Context) ([]*PromptTemplate, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(qctx, `SELECT name, kind, body, updated_at FROM prompt_templates ORDER BY name, kind`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*PromptTemplate
+	for rows.Next() {
+		var t PromptTemplate
+		if err := rows.Scan(&t.Name, &t.Kind, &t.Body, microsColumn{&t.UpdatedAt}); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt template row: %w", err)
+		}
+		out = append(out, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating prompt template rows: %w", err)
+	}
+	return out, nil
+}
+
+func (db *SQLiteStore) SetRulePrompt(parent context.Context, ruleID int, template, tone *string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE rules SET
+			prompt_template = COALESCE($2, prompt_template),
+			tone            = COALESCE($3, tone)
+		WHERE id = $1
+	`
+	if _, err := db.ExecContext(qctx, query, ruleID, template, tone); err != nil {
+		return fmt.Errorf("failed to set prompt for rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) SetChannelPrompt(parent context.Context, channelID int, template, tone *string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE discord_channels SET
+			prompt_template = COALESCE($2, prompt_template),
+			tone            = COALESCE($3, tone)
+		WHERE id = $1
+	`
+	if _, err := db.ExecContext(qctx, query, channelID, template, tone); err != nil {
+		return fmt.Errorf("failed to set prompt for channel %d: %w", channelID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) SetChannelLanguage(parent context.Context, channelID int, language string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	if _, err := db.ExecContext(qctx, `UPDATE discord_channels SET language = $2 WHERE id = $1`, channelID, language); err != nil {
+		return fmt.Errorf("failed to set language for channel %d: %w", channelID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) GetPromptSettings(parent context.Context, channelID, ruleID int) (PromptSettings, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(NULLIF(r.prompt_template, ''), dc.prompt_template),
+		       COALESCE(NULLIF(r.tone, ''), dc.tone),
+		       dc.language
+		FROM discord_channels dc
+			LEFT JOIN rules r ON r.id = $2 AND r.channel_id = dc.id
+		WHERE dc.id = $1
+	`
+	var s PromptSettings
+	if err := db.QueryRowContext(qctx, query, channelID, ruleID).Scan(&s.Template, &s.Tone, &s.Language); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PromptSettings{}, nil
+		}
+		return PromptSettings{}, fmt.Errorf("failed to get prompt settings for channel %d rule %d: %w", channelID, ruleID, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,690 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLiteStore's Store methods, in store.go's order. They mirror PGXStore's
+// queries; where Postgres lowercases with lower() this lowercases in Go
+// (SQLite's lower() only folds ASCII), and times come from time.Now rather
+// than now().
+
+func (db *SQLiteStore) InsertDiscordServer(parentCtx context.Context, serverID string) (*DiscordServer, error) {
+	queryCtx, cancel := context.WithTimeout(parentCtx, DefaultQueryTimeout)
+	defer cancel()
+
+	var s DiscordServer
+	query := `INSERT INTO discord_servers (server_id, created_at) VALUES ($1, $2) ON CONFLICT (server_id) DO NOTHING RETURNING id, server_id`
+	if err := db.QueryRowContext(queryCtx, query, strings.ToLower(serverID), unixMicros(time.Now())).Scan(&s.ID, &s.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.GetDiscordServerByExternalID(parentCtx, serverID)
+		}
+		return nil, fmt.Errorf("failed to insert server: %w", err)
+	}
+
+	return &s, nil
+}
+
+func (db *SQLiteStore) GetDiscordServerByExternalID(ctx context.Context, serverID string) (*DiscordServer, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var s DiscordServer
+	query := `SELECT id, server_id FROM discord_servers WHERE server_id = $1`
+	if err := db.QueryRowContext(ctx, query, strings.ToLower(serverID)).Scan(&s.ID, &s.ExternalID); err != nil {
+		return nil, fmt.Errorf("failed to get discord server %q: %w", serverID, err)
+	}
+
+	return &s, nil
+}
+
+func (db *SQLiteStore) InsertDiscordChannel(parentCtx context.Context, channelID string, serverID int) (*DiscordChannel, error) {
+	queryCtx, cancel := context.WithTimeout(parentCtx, DefaultQueryTimeout)
+	defer cancel()
+
+	var c DiscordChannel
+	query := `INSERT INTO discord_channels (channel_id, server_id, created_at) VALUES ($1, $2, $3) ON CONFLICT (channel_id) DO NOTHING RETURNING id, channel_id`
+	if err := db.QueryRowContext(queryCtx, query, strings.ToLower(channelID), serverID, unixMicros(time.Now())).Scan(&c.ID, &c.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.GetDiscordChannelByExternalID(parentCtx, channelID)
+		}
+		return nil, fmt.Errorf("failed to insert channel: %w", err)
+	}
+
+	return &c, nil
+}
+
+func (db *SQLiteStore) GetDiscordChannel(ctx context.Context, channelID int) (*DiscordChannel, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var ch DiscordChannel
+	query := `SELECT id, channel_id FROM discord_channels WHERE id = $1`
+	if err := db.QueryRowContext(ctx, query, channelID).Scan(&ch.ID, &ch.ExternalID); err != nil {
+		return nil, fmt.Errorf("failed to get discord channel %d: %w", channelID, err)
+	}
+
+	return &ch, nil
+}
+
+func (db *SQLiteStore) GetDiscordChannelByExternalID(ctx context.Context, channelID string) (*DiscordChannel, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var ch DiscordChannel
+	query := `SELECT id, channel_id FROM discord_channels WHERE channel_id = $1`
+	if err := db.QueryRowContext(ctx, query, strings.ToLower(channelID)).Scan(&ch.ID, &ch.ExternalID); err != nil {
+		return nil, fmt.Errorf("failed to get discord channel %q: %w", channelID, err)
+	}
+
+	return &ch, nil
+}
+
+func (db *SQLiteStore) InsertNotification(parentCtx context.Context, postID, channelID, ruleID int) (*Notification, error) {
+	queryCtx, cancel := context.WithTimeout(parentCtx, DefaultQueryTimeout)
+	defer cancel()
+
+	var n Notification
+	query := `INSERT INTO notifications (post_id, channel_id, rule_id, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING RETURNING id, post_id, channel_id, rule_id`
+	if err := db.QueryRowContext(queryCtx, query, postID, channelID, ruleID, unixMicros(time.Now())).Scan(&n.ID, &n.PostID, &n.ChannelID, &n.RuleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.GetNotification(parentCtx, postID, channelID, ruleID)
+		}
+		return nil, fmt.Errorf("failed to insert notification: %w", err)
+	}
+
+	return &n, nil
+}
+
+func (db *SQLiteStore) GetNotification(ctx context.Context, postID, channelID, ruleID int) (*Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var n Notification
+	query := `SELECT id, post_id, channel_id, rule_id FROM notifications WHERE post_id = $1 AND channel_id = $2 AND rule_id = $3`
+	if err := db.QueryRowContext(ctx, query, postID, channelID, ruleID).Scan(&n.ID, &n.PostID, &n.ChannelID, &n.RuleID); err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+	return &n, nil
+}
+
+func (db *SQLiteStore) GetNotificationCount(ctx context.Context, postID, channelID, ruleID int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var count int
+	query := `SELECT count(1) FROM notifications WHERE post_id = $1 AND channel_id = $2 AND rule_id = $3`
+	if err := db.QueryRowContext(ctx, query, postID, channelID, ruleID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get notification count: %w", err)
+	}
+
+	return count, nil
+}
+
+func (db *SQLiteStore) InsertPost(parentCtx context.Context, postID string) (*Post, error) {
+	queryCtx, cancel := context.WithTimeout(parentCtx, DefaultQueryTimeout)
+	defer cancel()
+
+	var p Post
+	query := `INSERT INTO posts (post_id, created_at) VALUES ($1, $2) ON CONFLICT (post_id) DO NOTHING RETURNING id, post_id`
+	if err := db.QueryRowContext(queryCtx, query, strings.ToLower(postID), unixMicros(time.Now())).Scan(&p.ID, &p.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.GetPostByExternalID(parentCtx, postID)
+		}
+		return nil, fmt.Errorf("failed to insert post: %w", err)
+	}
+
+	return &p, nil
+}
+
+func (db *SQLiteStore) GetPostByExternalID(ctx context.Context, postID string) (*Post, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var p Post
+	query := `SELECT id, post_id FROM posts WHERE post_id = $1`
+	if err := db.QueryRowContext(ctx, query, strings.ToLower(postID)).Scan(&p.ID, &p.ExternalID); err != nil {
+		return nil, fmt.Errorf("failed to get post %q: %w", postID, err)
+	}
+
+	return &p, nil
+}
+
+func (db *SQLiteStore) InsertSubreddit(parentCtx context.Context, subredditID string) (*Subreddit, error) {
+	queryCtx, cancel := context.WithTimeout(parentCtx, DefaultQueryTimeout)
+	defer cancel()
+
+	var s Subreddit
+	query := `INSERT INTO subreddits (subreddit_id, created_at) VALUES ($1, $2) ON CONFLICT (subreddit_id) DO NOTHING RETURNING id, subreddit_id`
+	if err := db.QueryRowContext(queryCtx, query, strings.ToLower(subredditID), unixMicros(time.Now())).Scan(&s.ID, &s.ExternalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.GetSubredditByExternalID(parentCtx, subredditID)
+		}
+		return nil, fmt.Errorf("failed to insert subreddit: %w", err)
+	}
+
+	return &s, nil
+}
+
+func (db *SQLiteStore) GetSubredditByExternalID(ctx context.Context, subredditID string) (*Subreddit, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var sr Subreddit
+	query := `SELECT id, subreddit_id FROM subreddits WHERE subreddit_id = $1`
+	if err := db.QueryRowContext(ctx, query, strings.ToLower(subredditID)).Scan(&sr.ID, &sr.ExternalID); err != nil {
+		return nil, fmt.Errorf("failed to get subreddit %q: %w", subredditID, err)
+	}
+
+	return &sr, nil
+}
+
+func (db *SQLiteStore) GetSubreddits(ctx context.Context) ([]*Subreddit, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT id, subreddit_id FROM subreddits`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	var subreddits []*Subreddit
+	for rows.Next() {
+		var sr Subreddit
+		if err := rows.Scan(&sr.ID, &sr.ExternalID); err != nil {
+			return nil, fmt.Errorf("failed to scan subreddit row: %w", err)
+		}
+		subreddits = append(subreddits, &sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating subreddit rows: %w", err)
+	}
+
+	return subreddits, nil
+}
+
+func (db *SQLiteStore) GetActiveSubreddits(ctx context.Context) ([]*Subreddit, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT sr.id, sr.subreddit_id FROM subreddits sr
+		WHERE EXISTS (
+			SELECT 1 FROM rules r
+			WHERE r.subreddit_id = sr.id AND r.enabled
+			  AND (r.paused_until IS NULL OR r.paused_until <= $1)
+		)
+		ORDER BY sr.id`
+
+	rows, err := db.QueryContext(ctx, query, unixMicros(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch active subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	var subreddits []*Subreddit
+	for rows.Next() {
+		var sr Subreddit
+		if err := rows.Scan(&sr.ID, &sr.ExternalID); err != nil {
+			return nil, fmt.Errorf("failed to scan subreddit row: %w", err)
+		}
+		subreddits = append(subreddits, &sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating subreddit rows: %w", err)
+	}
+
+	return subreddits, nil
+}
+
+func (db *SQLiteStore) InsertRule(ctx context.Context, rule Rule) (*Rule, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	if rule.Mode == "" {
+		rule.Mode = "narrative"
+	}
+	if rule.WindowHours <= 0 {
+		rule.WindowHours = 72
+	}
+
+	query := `INSERT INTO
+		rules (
+		   target,
+		   target_id,
+		   exact,
+		   channel_id,
+		   subreddit_id,
+		   mode,
+		   window_hours,
+		   threshold,
+		   exclude_terms,
+		   created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+
+	if err := db.QueryRowContext(ctx, query,
+		strings.ToLower(rule.Target), strings.ToLower(rule.TargetID), rule.Exact, rule.DiscordChannelID, rule.SubredditID,
+		rule.Mode, rule.WindowHours, rule.Threshold, jsonArray(normalizeTerms(rule.ExcludeTerms)), unixMicros(time.Now()),
+	).Scan(&rule.ID); err != nil {
+		return nil, fmt.Errorf("failed to insert rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+func (db *SQLiteStore) GetRules(ctx context.Context, subreddit int) ([]*Rule, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT r.id, r.target, r.target_id, r.exact, r.mode, r.window_hours, r.threshold, r.exclude_terms,
+		       NOT r.enabled, COALESCE(r.paused_until, 0), r.quiet_hours,
+		       ds.id, dc.id, sr.id
+		FROM rules r
+			JOIN subreddits sr ON r.subreddit_id = sr.id
+			JOIN discord_channels dc ON r.channel_id = dc.id
+			JOIN discord_servers ds ON dc.server_id = ds.id
+		WHERE sr.id = $1
+	`
+	rows, err := db.QueryContext(ctx, query, subreddit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*Rule
+	for rows.Next() {
+		var r Rule
+		var pausedUntil int64
+		if err := rows.Scan(
+			&r.ID, &r.Target, &r.TargetID, &r.Exact, &r.Mode, &r.WindowHours, &r.Threshold, jsonColumn{&r.ExcludeTerms},
+			&r.Disabled, &pausedUntil, &r.QuietHours,
+			&r.DiscordServerID, &r.DiscordChannelID, &r.SubredditID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rule row: %w", err)
+		}
+		if pausedUntil != 0 {
+			r.PausedUntil = time.UnixMicro(pausedUntil).UTC()
+		}
+		rules = append(rules, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+const sqliteRuleDetailQuery = `
+	SELECT r.id, r.target, r.exact, r.target_id, r.mode, r.window_hours, r.threshold, r.exclude_terms,
+	       NOT r.enabled, COALESCE(r.paused_until, 0), r.quiet_hours,
+	       sr.subreddit_id, ds.id, r.managed
+	FROM rules r
+		JOIN subreddits sr ON r.subreddit_id = sr.id
+		JOIN discord_channels dc ON r.channel_id = dc.id
+		JOIN discord_servers ds ON dc.server_id = ds.id`
+
+func scanSQLiteRuleDetail(row rowScanner) (*RuleDetail, error) {
+	var r RuleDetail
+	var pausedUntil int64
+	if err := row.Scan(&r.ID, &r.Target, &r.Exact, &r.TargetID, &r.Mode, &r.WindowHours, &r.Threshold, jsonColumn{&r.ExcludeTerms},
+		&r.Disabled, &pausedUntil, &r.QuietHours, &r.Subreddit, &r.ServerID, &r.Managed); err != nil {
+		return nil, err
+	}
+	if pausedUntil != 0 {
+		r.PausedUntil = time.UnixMicro(pausedUntil).UTC()
+	}
+	return &r, nil
+}
+
+func (db *SQLiteStore) GetRulesByChannel(ctx context.Context, channelExternalID string) ([]*RuleDetail, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, sqliteRuleDetailQuery+` WHERE dc.channel_id = $1 ORDER BY r.id`, strings.ToLower(channelExternalID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules by channel: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*RuleDetail
+	for rows.Next() {
+		r, err := scanSQLiteRuleDetail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule detail row: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating rule detail rows: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (db *SQLiteStore) GetRuleByID(ctx context.Context, ruleID int) (*RuleDetail, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	r, err := scanSQLiteRuleDetail(db.QueryRowContext(ctx, sqliteRuleDetailQuery+` WHERE r.id = $1`, ruleID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule %d: %w", ruleID, err)
+	}
+	return r, nil
+}
+
+// execRule runs an UPDATE or DELETE of one rule, reporting a missing rule
+// the way PGXStore does.
+func (db *SQLiteStore) execRule(ctx context.Context, ruleID int, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRuleMode(ctx context.Context, ruleID int, mode string) error {
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET mode = $1 WHERE id = $2`, mode, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule mode: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRuleWindowHours(ctx context.Context, ruleID int, windowHours int) error {
+	if windowHours <= 0 {
+		return fmt.Errorf("rule window_hours must be > 0, got %d", windowHours)
+	}
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET window_hours = $1 WHERE id = $2`, windowHours, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule window_hours: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRuleThreshold(ctx context.Context, ruleID int, threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("rule threshold must be within 0–1, got %g", threshold)
+	}
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET threshold = $1 WHERE id = $2`, threshold, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule threshold: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRuleExcludeTerms(ctx context.Context, ruleID int, terms []string) error {
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET exclude_terms = $1 WHERE id = $2`, jsonArray(normalizeTerms(terms)), ruleID); err != nil {
+		return fmt.Errorf("failed to update rule exclude_terms: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRulePause(ctx context.Context, ruleID int, disabled bool, pausedUntil time.Time) error {
+	var until any
+	if !pausedUntil.IsZero() {
+		until = unixMicros(pausedUntil)
+	}
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET enabled = $1, paused_until = $2 WHERE id = $3`, !disabled, until, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule pause: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRuleQuietHours(ctx context.Context, ruleID int, quietHours string) error {
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET quiet_hours = $1 WHERE id = $2`, quietHours, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule quiet_hours: %w", err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) DeleteRule(ctx context.Context, ruleID int) error {
+	if err := db.execRule(ctx, ruleID, `DELETE FROM rules WHERE id = $1`, ruleID); err != nil {
+		return fmt.Errorf("failed to delete rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+func (db *SQLiteStore) UpdateRule(ctx context.Context, ruleID int, target string, exact bool) error {
+	if err := db.execRule(ctx, ruleID, `UPDATE rules SET target = $1, exact = $2 WHERE id = $3`, strings.ToLower(target), exact, ruleID); err != nil {
+		return fmt.Errorf("failed to update rule %d: %w", ruleID, err)
+	}
+	return nil
+}
+
+// sqliteRollingPostCols is rollingPostCols for SQLite; scanSQLiteRollingPost
+// consumes it in the same order.
+const sqliteRollingPostCols = `
+	id, channel_id, COALESCE(subreddit_id, 0), subreddit_ids,
+	day_local, window_start, COALESCE(window_end, 0), mode, discord_message_ids,
+	thread_id, thread_message_ids,
+	narrative_title, narrative_summary, entries,
+	included_post_ids, included_rule_ids,
+	latest_score, latest_comments, latest_url,
+	latest_thumbnail, updated_at`
+
+func scanSQLiteRollingPost(row rowScanner) (*RollingPost, error) {
+	var rp RollingPost
+	var entries string
+	var windowEnd int64
+	if err := row.Scan(
+		&rp.ID, &rp.ChannelID, &rp.SubredditID, jsonColumn{&rp.SubredditIDs},
+		dateColumn{&rp.DayLocal}, microsColumn{&rp.WindowStart}, &windowEnd,
+		&rp.Mode, jsonColumn{&rp.DiscordMessageIDs},
+		&rp.ThreadID, jsonColumn{&rp.ThreadMessageIDs},
+		&rp.NarrativeTitle, &rp.NarrativeSummary, &entries,
+		jsonColumn{&rp.IncludedPostIDs}, jsonColumn{&rp.IncludedRuleIDs},
+		&rp.LatestScore, &rp.LatestComments, &rp.LatestURL,
+		&rp.LatestThumbnail, microsColumn{&rp.UpdatedAt},
+	); err != nil {
+		return nil, err
+	}
+	rp.Entries = []byte(entries)
+	if windowEnd != 0 {
+		rp.WindowEnd = time.UnixMicro(windowEnd).UTC()
+	}
+	return &rp, nil
+}
+
+func (db *SQLiteStore) GetActiveRollingPost(parent context.Context, channelID int, mode string, windowHours int) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+	if mode == "" {
+		mode = ModeNarrative
+	}
+
+	// window_start + windowHours > now, with the interval moved to the
+	// right-hand side, unless window_end overrides it.
+	now := time.Now()
+	opened := now.Add(-time.Duration(windowHours) * time.Hour)
+	query := `
+		SELECT ` + sqliteRollingPostCols + `
+		FROM rolling_posts
+		WHERE channel_id = $1 AND mode = $2
+		  AND CASE WHEN window_end IS NULL THEN window_start > $3 ELSE window_end > $4 END
+		ORDER BY window_end IS NOT NULL DESC, window_start DESC
+		LIMIT 1
+	`
+	rp, err := scanSQLiteRollingPost(db.QueryRowContext(qctx, query, channelID, mode, unixMicros(opened), unixMicros(now)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get active rolling post: %w", err)
+	}
+	return rp, nil
+}
+
+func (db *SQLiteStore) GetRollingPostByMessageID(parent context.Context, messageID string) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + sqliteRollingPostCols + `
+		FROM rolling_posts
+		WHERE EXISTS (SELECT 1 FROM json_each(discord_message_ids) WHERE value = $1)
+		ORDER BY window_start DESC
+		LIMIT 1
+	`
+	rp, err := scanSQLiteRollingPost(db.QueryRowContext(qctx, query, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rolling post by message %q: %w", messageID, err)
+	}
+	return rp, nil
+}
+
+func (db *SQLiteStore) GetRollingPost(parent context.Context, id int) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + sqliteRollingPostCols + ` FROM rolling_posts WHERE id = $1`
+	rp, err := scanSQLiteRollingPost(db.QueryRowContext(qctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rolling post %d: %w", id, err)
+	}
+	return rp, nil
+}
+
+func (db *SQLiteStore) ListRollingPosts(parent context.Context, channelID int, mode string, since time.Time, limit int) ([]*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + sqliteRollingPostCols + `
+		FROM rolling_posts
+		WHERE channel_id = $1 AND ($2 = '' OR mode = $2) AND window_start >= $3
+		ORDER BY window_start DESC, id DESC
+		LIMIT $4
+	`
+	rows, err := db.QueryContext(qctx, query, channelID, mode, unixMicros(since), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rolling posts: %w", err)
+	}
+	defer rows.Close()
+	var out []*RollingPost
+	for rows.Next() {
+		rp, err := scanSQLiteRollingPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rolling post: %w", err)
+		}
+		out = append(out, rp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list rolling posts: %w", err)
+	}
+	return out, nil
+}
+
+// sqliteUpdateRollingPostSQL is updateRollingPostSQL for SQLite.
+const sqliteUpdateRollingPostSQL = `
+	UPDATE rolling_posts SET
+		subreddit_ids       = $2,
+		mode                = $3,
+		discord_message_ids = $4,
+		thread_id           = $5,
+		thread_message_ids  = $6,
+		narrative_title     = $7,
+		narrative_summary   = $8,
+		entries             = $9,
+		included_post_ids   = $10,
+		included_rule_ids   = $11,
+		latest_score        = $12,
+		latest_comments     = $13,
+		latest_url          = $14,
+		latest_thumbnail    = $15,
+		updated_at          = $16
+	WHERE id = $1
+	RETURNING ` + sqliteRollingPostCols
+
+func sqliteRollingPostUpdateArgs(rp RollingPost) []any {
+	entries := rp.Entries
+	if len(entries) == 0 {
+		entries = []byte("[]")
+	}
+	if rp.Mode == "" {
+		rp.Mode = ModeNarrative
+	}
+	return []any{
+		rp.ID,
+		jsonArray(rp.SubredditIDs),
+		rp.Mode, jsonArray(rp.DiscordMessageIDs),
+		rp.ThreadID, jsonArray(rp.ThreadMessageIDs),
+		rp.NarrativeTitle, rp.NarrativeSummary, string(entries),
+		jsonArray(rp.IncludedPostIDs), jsonArray(rp.IncludedRuleIDs),
+		rp.LatestScore, rp.LatestComments, rp.LatestURL,
+		rp.LatestThumbnail, unixMicros(time.Now()),
+	}
+}
+
+// UpsertRollingPost is id-aware like PGXStore's: ID 0 inserts, otherwise
+// the row is updated with its window, day and opening subreddit kept.
+func (db *SQLiteStore) UpsertRollingPost(parent context.Context, rp RollingPost) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	entries := rp.Entries
+	if len(entries) == 0 {
+		entries = []byte("[]")
+	}
+	if rp.Mode == "" {
+		rp.Mode = "narrative"
+	}
+	subredditIDs, messageIDs, threadMessageIDs := jsonArray(rp.SubredditIDs), jsonArray(rp.DiscordMessageIDs), jsonArray(rp.ThreadMessageIDs)
+	postIDs, ruleIDs := jsonArray(rp.IncludedPostIDs), jsonArray(rp.IncludedRuleIDs)
+	now := unixMicros(time.Now())
+
+	var row *sql.Row
+	if rp.ID == 0 {
+		windowStart := rp.WindowStart
+		if windowStart.IsZero() {
+			windowStart = time.Now().UTC()
+		}
+		query := `
+			INSERT INTO rolling_posts (
+				channel_id, subreddit_id, subreddit_ids,
+				day_local, window_start,
+				mode, discord_message_ids,
+				thread_id, thread_message_ids,
+				narrative_title, narrative_summary, entries,
+				included_post_ids, included_rule_ids,
+				latest_score, latest_comments, latest_url,
+				latest_thumbnail, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+			RETURNING ` + sqliteRollingPostCols
+		row = db.QueryRowContext(qctx, query,
+			rp.ChannelID, rp.SubredditID, subredditIDs,
+			rp.DayLocal.Format(sqliteDateLayout), unixMicros(windowStart),
+			rp.Mode, messageIDs,
+			rp.ThreadID, threadMessageIDs,
+			rp.NarrativeTitle, rp.NarrativeSummary, string(entries),
+			postIDs, ruleIDs,
+			rp.LatestScore, rp.LatestComments, rp.LatestURL,
+			rp.LatestThumbnail, now,
+		)
+	} else {
+		row = db.QueryRowContext(qctx, sqliteUpdateRollingPostSQL, sqliteRollingPostUpdateArgs(rp)...)
+	}
+
+	out, err := scanSQLiteRollingPost(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert rolling post: %w", err)
+	}
+	return out, nil
+}
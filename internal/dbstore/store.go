@@ -42,11 +42,22 @@ type Store interface {
 	UpdateRule(ctx context.Context, ruleID int, target string, exact bool) error
 	UpdateRuleMode(ctx context.Context, ruleID int, mode string) error
 	UpdateRuleWindowHours(ctx context.Context, ruleID int, windowHours int) error
+	UpdateRuleThreshold(ctx context.Context, ruleID int, threshold float64) error
+	UpdateRuleExcludeTerms(ctx context.Context, ruleID int, terms []string) error
+	UpdateRulePause(ctx context.Context, ruleID int, disabled bool, pausedUntil time.Time) error
+	UpdateRuleQuietHours(ctx context.Context, ruleID int, quietHours string) error
 	GetSubreddits(ctx context.Context) ([]*Subreddit, error)
+	GetActiveSubreddits(ctx context.Context) ([]*Subreddit, error)
 	GetNotificationCount(ctx context.Context, postID, channelID, ruleID int) (int, error)
 
 	GetActiveRollingPost(ctx context.Context, channelID int, mode string, windowHours int) (*RollingPost, error)
 	UpsertRollingPost(ctx context.Context, rp RollingPost) (*RollingPost, error)
+	GetRollingPostByMessageID(ctx context.Context, messageID string) (*RollingPost, error)
+	GetRollingPost(ctx context.Context, id int) (*RollingPost, error)
+	ListRollingPosts(ctx context.Context, channelID int, mode string, since time.Time, limit int) ([]*RollingPost, error)
+	CloseRollingPost(ctx context.Context, id int) error
+	ReopenRollingPost(ctx context.Context, id int, until time.Time) error
+	MergeRollingPosts(ctx context.Context, into RollingPost, fromID int) (*RollingPost, error)
 
 	GetLastfmListeners(ctx context.Context, artistKey string) (listeners int, fetchedAt time.Time, ok bool, err error)
 	UpsertLastfmListeners(ctx context.Context, artistKey string, listeners int) error
@@ -58,6 +69,48 @@ type Store interface {
 
 	GetQobuzAlbum(ctx context.Context, queryKey string) (qobuzURL string, fetchedAt time.Time, ok bool, err error)
 	UpsertQobuzAlbum(ctx context.Context, queryKey, qobuzURL string) error
+
+	GetCachedArticle(ctx context.Context, url string) (*CachedArticle, error)
+	UpsertCachedArticle(ctx context.Context, a CachedArticle) error
+
+	GetPostClassification(ctx context.Context, postID string) (*PostClassification, error)
+	UpsertPostClassification(ctx context.Context, pc PostClassification) error
+
+	GetRuleEmbedding(ctx context.Context, ruleID int) (*RuleEmbedding, error)
+	UpsertRuleEmbedding(ctx context.Context, re RuleEmbedding) error
+
+	GetDigestItems(ctx context.Context, rollingPostID int) ([]DigestItem, error)
+	InsertDigestItem(ctx context.Context, it DigestItem) error
+
+	RecordFeedback(ctx context.Context, fb Feedback) error
+	GetNotifiedRules(ctx context.Context, channelID int, postID string) ([]int, error)
+	GetRuleStats(ctx context.Context, channelID int) ([]RuleStats, error)
+	ListRuleFeedback(ctx context.Context, ruleID int) ([]Feedback, error)
+
+	GetLLMCompletion(ctx context.Context, key string) (content string, createdAt time.Time, ok bool, err error)
+	UpsertLLMCompletion(ctx context.Context, key, model, content string) error
+	PruneLLMCompletions(ctx context.Context, maxAge time.Duration, maxRows int) (int64, error)
+	PruneRows(ctx context.Context, table string, cutoff time.Time, limit int) (int64, error)
+
+	EnqueueLLMJob(ctx context.Context, job LLMJob) (*LLMJob, error)
+	ClaimDueLLMJobs(ctx context.Context, limit int) ([]*LLMJob, error)
+	CompleteLLMJob(ctx context.Context, jobID int) error
+	FailLLMJob(ctx context.Context, jobID int, lastError string, retryAt time.Time) error
+	ListFailedLLMJobs(ctx context.Context, channelID int, limit int) ([]*LLMJob, error)
+	GetLLMJob(ctx context.Context, jobID int) (*LLMJob, error)
+	RequeueLLMJob(ctx context.Context, jobID int) (bool, error)
+
+	UpsertPromptTemplate(ctx context.Context, name, kind, body string) error
+	GetPromptTemplate(ctx context.Context, name, kind string) (*PromptTemplate, error)
+	ListPromptTemplates(ctx context.Context) ([]*PromptTemplate, error)
+	SetRulePrompt(ctx context.Context, ruleID int, template, tone *string) error
+	SetChannelPrompt(ctx context.Context, channelID int, template, tone *string) error
+	SetChannelLanguage(ctx context.Context, channelID int, language string) error
+	GetPromptSettings(ctx context.Context, channelID, ruleID int) (PromptSettings, error)
+
+	GetGuildConfig(ctx context.Context, serverID string) ([]GuildChannel, error)
+	ApplyGuildConfig(ctx context.Context, serverID string, channels []GuildChannel) error
+	ReconcileManagedRules(ctx context.Context, guilds []ManagedGuild) (int64, error)
 }
 
 type PGXStore struct {
@@ -334,18 +387,64 @@ func (db *PGXStore) GetSubreddits(ctx context.Context) ([]*Subreddit, error) {
 	return subreddits, nil
 }
 
+// GetActiveSubreddits returns the subreddits with at least one rule that is
+// enabled and not snoozed: the ones worth polling.
+func (db *PGXStore) GetActiveSubreddits(ctx context.Context) ([]*Subreddit, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT sr.id, sr.subreddit_id FROM subreddits sr
+		WHERE EXISTS (
+			SELECT 1 FROM rules r
+			WHERE r.subreddit_id = sr.id AND r.enabled
+			  AND (r.paused_until IS NULL OR r.paused_until <= now())
+		)
+		ORDER BY sr.id`
+
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch active subreddits: %w", err)
+	}
+	defer rows.Close()
+
+	var subreddits []*Subreddit
+	for rows.Next() {
+		var sr Subreddit
+		if err := rows.Scan(&sr.ID, &sr.ExternalID); err != nil {
+			return nil, fmt.Errorf("failed to scan subreddit row: %w", err)
+		}
+		subreddits = append(subreddits, &sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed iterating subreddit rows: %w", err)
+	}
+
+	return subreddits, nil
+}
+
 type Rule struct {
 	ID               int
 	Target           string
 	Exact            bool
 	TargetID         string
-	Mode             string // "narrative" | "music" | "summary" | "media"
-	WindowHours      int    // rolling-digest window from first match; 0 → schema default (72h)
+	Mode             string    // "narrative" | "music" | "summary" | "media"
+	WindowHours      int       // rolling-digest window from first match; 0 → schema default (72h)
+	Threshold        float64   // semantic rules' minimum cosine similarity; 0 → evaluator default
+	ExcludeTerms     []string  // lowercase; a post whose title contains one isn't matched
+	Disabled         bool      // paused by /pause_rule until /resume_rule
+	PausedUntil      time.Time // snoozed until then; zero when not snoozed
+	QuietHours       string    // "HH:MM-HH:MM" in the bot's timezone; matches wait until it ends
 	DiscordServerID  int
 	SubredditID      int
 	DiscordChannelID int
 }
 
+// Paused reports whether the rule is disabled or still snoozed at now.
+func (r *Rule) Paused(now time.Time) bool {
+	return r.Disabled || now.Before(r.PausedUntil)
+}
+
 func (db *PGXStore) InsertRule(ctx context.Context, rule Rule) (*Rule, error) {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
@@ -365,10 +464,12 @@ func (db *PGXStore) InsertRule(ctx context.Context, rule Rule) (*Rule, error) {
 		   channel_id,
 		   subreddit_id,
 		   mode,
-		   window_hours
-		) VALUES (lower($1), lower($2), $3, $4, $5, $6, $7) RETURNING id`
+		   window_hours,
+		   threshold,
+		   exclude_terms
+		) VALUES (lower($1), lower($2), $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 
-	if err := db.QueryRow(ctx, query, rule.Target, rule.TargetID, rule.Exact, rule.DiscordChannelID, rule.SubredditID, rule.Mode, rule.WindowHours).Scan(&rule.ID); err != nil {
+	if err := db.QueryRow(ctx, query, rule.Target, rule.TargetID, rule.Exact, rule.DiscordChannelID, rule.SubredditID, rule.Mode, rule.WindowHours, rule.Threshold, normalizeTerms(rule.ExcludeTerms)).Scan(&rule.ID); err != nil {
 		return nil, fmt.Errorf("failed to insert rule: %w", err)
 	}
 
@@ -387,6 +488,11 @@ func (db *PGXStore) GetRules(ctx context.Context, subreddit int) ([]*Rule, error
 		    exact,
 		    COALESCE(r.mode, 'narrative'),
 		    COALESCE(r.window_hours, 72),
+		    r.threshold,
+		    r.exclude_terms,
+		    NOT r.enabled,
+		    r.paused_until,
+		    r.quiet_hours,
 		    ds.id,
 		    dc.id,
 		    sr.id
@@ -406,6 +512,7 @@ func (db *PGXStore) GetRules(ctx context.Context, subreddit int) ([]*Rule, error
 	var rules []*Rule
 	for rows.Next() {
 		var r Rule
+		var pausedUntil *time.Time
 		if err := rows.Scan(
 			&r.ID,
 			&r.Target,
@@ -413,12 +520,20 @@ func (db *PGXStore) GetRules(ctx context.Context, subreddit int) ([]*Rule, error
 			&r.Exact,
 			&r.Mode,
 			&r.WindowHours,
+			&r.Threshold,
+			&r.ExcludeTerms,
+			&r.Disabled,
+			&pausedUntil,
+			&r.QuietHours,
 			&r.DiscordServerID,
 			&r.DiscordChannelID,
 			&r.SubredditID,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan rule row: %w", err)
 		}
+		if pausedUntil != nil {
+			r.PausedUntil = *pausedUntil
+		}
 		rules = append(rules, &r)
 	}
 	if err := rows.Err(); err != nil {
@@ -429,29 +544,58 @@ func (db *PGXStore) GetRules(ctx context.Context, subreddit int) ([]*Rule, error
 }
 
 type RuleDetail struct {
-	ID          int
-	Target      string
-	Exact       bool
-	TargetID    string
-	Mode        string
-	WindowHours int
-	Subreddit   string
-	ServerID    int
+	ID           int
+	Target       string
+	Exact        bool
+	TargetID     string
+	Mode         string
+	WindowHours  int
+	Threshold    float64
+	ExcludeTerms []string
+	Disabled     bool
+	PausedUntil  time.Time
+	QuietHours   string
+	Subreddit    string
+	ServerID     int
+	// Managed rules belong to the RULES_FILE reconciler; slash commands
+	// leave them alone.
+	Managed bool
+}
+
+// Paused reports whether the rule is disabled or still snoozed at now.
+func (r *RuleDetail) Paused(now time.Time) bool {
+	return r.Disabled || now.Before(r.PausedUntil)
+}
+
+const pgxRuleDetailQuery = `
+	SELECT r.id, r.target, r.exact, r.target_id,
+	       COALESCE(r.mode, 'narrative'),
+	       COALESCE(r.window_hours, 72), r.threshold, r.exclude_terms,
+	       NOT r.enabled, r.paused_until, r.quiet_hours,
+	       sr.subreddit_id, ds.id, r.managed
+	FROM rules r
+		JOIN subreddits sr ON r.subreddit_id = sr.id
+		JOIN discord_channels dc ON r.channel_id = dc.id
+		JOIN discord_servers ds ON dc.server_id = ds.id`
+
+func scanPGXRuleDetail(row pgx.Row) (*RuleDetail, error) {
+	var r RuleDetail
+	var pausedUntil *time.Time
+	if err := row.Scan(&r.ID, &r.Target, &r.Exact, &r.TargetID, &r.Mode, &r.WindowHours, &r.Threshold, &r.ExcludeTerms,
+		&r.Disabled, &pausedUntil, &r.QuietHours, &r.Subreddit, &r.ServerID, &r.Managed); err != nil {
+		return nil, err
+	}
+	if pausedUntil != nil {
+		r.PausedUntil = *pausedUntil
+	}
+	return &r, nil
 }
 
 func (db *PGXStore) GetRulesByChannel(ctx context.Context, channelExternalID string) ([]*RuleDetail, error) {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	query := `
-		SELECT r.id, r.target, r.exact, r.target_id,
-		       COALESCE(r.mode, 'narrative'),
-		       COALESCE(r.window_hours, 72),
-		       sr.subreddit_id, ds.id
-		FROM rules r
-			JOIN subreddits sr ON r.subreddit_id = sr.id
-			JOIN discord_channels dc ON r.channel_id = dc.id
-			JOIN discord_servers ds ON dc.server_id = ds.id
+	query := pgxRuleDetailQuery + `
 		WHERE dc.channel_id = lower($1)
 		ORDER BY r.id
 	`
@@ -464,11 +608,11 @@ func (db *PGXStore) GetRulesByChannel(ctx context.Context, channelExternalID str
 
 	var rules []*RuleDetail
 	for rows.Next() {
-		var r RuleDetail
-		if err := rows.Scan(&r.ID, &r.Target, &r.Exact, &r.TargetID, &r.Mode, &r.WindowHours, &r.Subreddit, &r.ServerID); err != nil {
+		r, err := scanPGXRuleDetail(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan rule detail row: %w", err)
 		}
-		rules = append(rules, &r)
+		rules = append(rules, r)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed iterating rule detail rows: %w", err)
@@ -481,24 +625,12 @@ func (db *PGXStore) GetRuleByID(ctx context.Context, ruleID int) (*RuleDetail, e
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
 
-	query := `
-		SELECT r.id, r.target, r.exact, r.target_id,
-		       COALESCE(r.mode, 'narrative'),
-		       COALESCE(r.window_hours, 72),
-		       sr.subreddit_id, ds.id
-		FROM rules r
-			JOIN subreddits sr ON r.subreddit_id = sr.id
-			JOIN discord_channels dc ON r.channel_id = dc.id
-			JOIN discord_servers ds ON dc.server_id = ds.id
-		WHERE r.id = $1
-	`
-
-	var r RuleDetail
-	if err := db.QueryRow(ctx, query, ruleID).Scan(&r.ID, &r.Target, &r.Exact, &r.TargetID, &r.Mode, &r.WindowHours, &r.Subreddit, &r.ServerID); err != nil {
+	r, err := scanPGXRuleDetail(db.QueryRow(ctx, pgxRuleDetailQuery+` WHERE r.id = $1`, ruleID))
+	if err != nil {
 		return nil, fmt.Errorf("failed to get rule %d: %w", ruleID, err)
 	}
 
-	return &r, nil
+	return r, nil
 }
 
 // UpdateRuleMode changes a rule's digest mode. Accepted values mirror the
@@ -538,6 +670,77 @@ func (db *PGXStore) UpdateRuleWindowHours(ctx context.Context, ruleID int, windo
 	return nil
 }
 
+// UpdateRuleThreshold changes a semantic rule's minimum similarity. 0
+// restores the default.
+func (db *PGXStore) UpdateRuleThreshold(ctx context.Context, ruleID int, threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("rule threshold must be within 0–1, got %g", threshold)
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `UPDATE rules SET threshold = $1 WHERE id = $2`, threshold, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule threshold: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	return nil
+}
+
+// UpdateRuleExcludeTerms replaces a rule's exclusion terms. They're stored
+// trimmed, lowercased and deduplicated; an empty list clears them.
+func (db *PGXStore) UpdateRuleExcludeTerms(ctx context.Context, ruleID int, terms []string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `UPDATE rules SET exclude_terms = $1 WHERE id = $2`, normalizeTerms(terms), ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule exclude_terms: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	return nil
+}
+
+// UpdateRulePause disables or re-enables a rule and sets when its snooze
+// ends; a zero pausedUntil clears the snooze.
+func (db *PGXStore) UpdateRulePause(ctx context.Context, ruleID int, disabled bool, pausedUntil time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var until *time.Time
+	if !pausedUntil.IsZero() {
+		until = &pausedUntil
+	}
+	tag, err := db.Exec(ctx, `UPDATE rules SET enabled = $1, paused_until = $2 WHERE id = $3`, !disabled, until, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule pause: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	return nil
+}
+
+// UpdateRuleQuietHours sets a rule's quiet hours ("HH:MM-HH:MM"); an empty
+// string clears them. Callers validate the format.
+func (db *PGXStore) UpdateRuleQuietHours(ctx context.Context, ruleID int, quietHours string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, `UPDATE rules SET quiet_hours = $1 WHERE id = $2`, quietHours, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to update rule quiet_hours: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("rule %d not found", ruleID)
+	}
+	return nil
+}
+
 func (db *PGXStore) DeleteRule(ctx context.Context, ruleID int) error {
 	ctx, cancel := context.WithTimeout(ctx, DefaultQueryTimeout)
 	defer cancel()
@@ -581,6 +784,7 @@ type RollingPost struct {
 	SubredditIDs      []int     // every subreddit that has contributed to this digest
 	DayLocal          time.Time // display only; rendered in the footer
 	WindowStart       time.Time // when the digest opened; bucket key with rules.window_hours
+	WindowEnd         time.Time // set when closed or reopened by hand, and then overrides window_hours; zero otherwise
 	Mode              string    // narrative | music | summary | media — bucket dimension
 	DiscordMessageIDs []string  // parent message(s) in the channel (card in music mode, embeds in narrative)
 	ThreadID          string    // thread attached to DiscordMessageIDs[0] — holds the full spill in music mode; empty until opened
@@ -603,6 +807,10 @@ type RollingPost struct {
 // same channel share one digest, regardless of which subreddit the match
 // came from. Returns (nil, nil) when no such row exists.
 //
+// A digest with window_end set was closed or reopened by hand: it is open
+// until window_end whatever windowHours says, and a reopened digest wins
+// over one that opened after it.
+//
 // windowHours must be > 0; callers guard against 0 before calling.
 func (db *PGXStore) GetActiveRollingPost(parent context.Context, channelID int, mode string, windowHours int) (*RollingPost, error) {
 	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
@@ -612,43 +820,135 @@ func (db *PGXStore) GetActiveRollingPost(parent context.Context, channelID int,
 	}
 
 	query := `
-		SELECT id, channel_id,
-		       COALESCE(subreddit_id, 0),
-		       COALESCE(subreddit_ids, '{}'::int[]),
-		       day_local, window_start,
-		       COALESCE(mode, 'narrative'),
-		       COALESCE(discord_message_ids, '{}'::text[]),
-		       COALESCE(thread_id, ''),
-		       COALESCE(thread_message_ids, '{}'::text[]),
-		       narrative_title, narrative_summary,
-		       COALESCE(entries, '[]'::jsonb),
-		       included_post_ids, included_rule_ids,
-		       latest_score, latest_comments, latest_url,
-		       latest_thumbnail, updated_at
+		SELECT ` + rollingPostCols + `
 		FROM rolling_posts
 		WHERE channel_id = $1
 		  AND COALESCE(mode, 'narrative') = $2
-		  AND window_start + make_interval(hours => $3) > now()
+		  AND CASE WHEN window_end IS NULL
+		           THEN window_start + make_interval(hours => $3) > now()
+		           ELSE window_end > now() END
+		ORDER BY window_end IS NOT NULL DESC, window_start DESC
+		LIMIT 1
+	`
+	rp, err := scanRollingPost(db.QueryRow(qctx, query, channelID, mode, windowHours))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get active rolling post: %w", err)
+	}
+	return rp, nil
+}
+
+// GetRollingPostByMessageID finds the digest whose parent message (card in
+// music mode, first embed in narrative) is messageID, regardless of whether
+// its window is still open. Returns (nil, nil) when no row matches.
+func (db *PGXStore) GetRollingPostByMessageID(parent context.Context, messageID string) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + rollingPostCols + `
+		FROM rolling_posts
+		WHERE $1 = ANY(discord_message_ids)
 		ORDER BY window_start DESC
 		LIMIT 1
 	`
+	rp, err := scanRollingPost(db.QueryRow(qctx, query, messageID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rolling post by message %q: %w", messageID, err)
+	}
+	return rp, nil
+}
+
+// GetRollingPost returns the digest with the given id, open or not.
+// Returns (nil, nil) when no row matches.
+func (db *PGXStore) GetRollingPost(parent context.Context, id int) (*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + rollingPostCols + ` FROM rolling_posts WHERE id = $1`
+	rp, err := scanRollingPost(db.QueryRow(qctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rolling post %d: %w", id, err)
+	}
+	return rp, nil
+}
+
+// ListRollingPosts returns a channel's digests opened at or after since,
+// newest first, open and closed alike. An empty mode lists every mode.
+func (db *PGXStore) ListRollingPosts(parent context.Context, channelID int, mode string, since time.Time, limit int) ([]*RollingPost, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + rollingPostCols + `
+		FROM rolling_posts
+		WHERE channel_id = $1
+		  AND ($2 = '' OR COALESCE(mode, 'narrative') = $2)
+		  AND window_start >= $3
+		ORDER BY window_start DESC, id DESC
+		LIMIT $4
+	`
+	rows, err := db.Query(qctx, query, channelID, mode, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rolling posts: %w", err)
+	}
+	defer rows.Close()
+	var out []*RollingPost
+	for rows.Next() {
+		rp, err := scanRollingPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rolling post: %w", err)
+		}
+		out = append(out, rp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list rolling posts: %w", err)
+	}
+	return out, nil
+}
+
+// rollingPostCols is the canonical SELECT/RETURNING list for rolling_posts;
+// scanRollingPost consumes it in the same order.
+const rollingPostCols = `
+	id, channel_id,
+	COALESCE(subreddit_id, 0),
+	COALESCE(subreddit_ids, '{}'::int[]),
+	day_local, window_start, window_end,
+	COALESCE(mode, 'narrative'),
+	COALESCE(discord_message_ids, '{}'::text[]),
+	COALESCE(thread_id, ''),
+	COALESCE(thread_message_ids, '{}'::text[]),
+	narrative_title, narrative_summary,
+	COALESCE(entries, '[]'::jsonb),
+	included_post_ids, included_rule_ids,
+	latest_score, latest_comments, latest_url,
+	latest_thumbnail, updated_at`
+
+func scanRollingPost(row pgx.Row) (*RollingPost, error) {
 	var rp RollingPost
-	err := db.QueryRow(qctx, query, channelID, mode, windowHours).Scan(
-		&rp.ID, &rp.ChannelID,
-		&rp.SubredditID, &rp.SubredditIDs,
-		&rp.DayLocal, &rp.WindowStart,
+	var windowEnd *time.Time
+	if err := row.Scan(
+		&rp.ID, &rp.ChannelID, &rp.SubredditID, &rp.SubredditIDs,
+		&rp.DayLocal, &rp.WindowStart, &windowEnd,
 		&rp.Mode, &rp.DiscordMessageIDs,
 		&rp.ThreadID, &rp.ThreadMessageIDs,
 		&rp.NarrativeTitle, &rp.NarrativeSummary, &rp.Entries,
 		&rp.IncludedPostIDs, &rp.IncludedRuleIDs,
 		&rp.LatestScore, &rp.LatestComments, &rp.LatestURL,
 		&rp.LatestThumbnail, &rp.UpdatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("failed to get active rolling post: %w", err)
+	); err != nil {
+		return nil, err
+	}
+	if windowEnd != nil {
+		rp.WindowEnd = *windowEnd
 	}
 	return &rp, nil
 }
@@ -784,6 +1084,67 @@ func (db *PGXStore) UpsertQobuzAlbum(parent context.Context, queryKey, qobuzURL
 	return nil
 }
 
+// GetLLMCompletion returns a cached chat completion by request hash. ok is
+// false on cache miss; callers apply their own TTL to createdAt.
+func (db *PGXStore) GetLLMCompletion(parent context.Context, key string) (string, time.Time, bool, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	var content string
+	var createdAt time.Time
+	err := db.QueryRow(qctx, `SELECT content, created_at FROM llm_cache WHERE cache_key = $1`, key).
+		Scan(&content, &createdAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", time.Time{}, false, nil
+		}
+		return "", time.Time{}, false, fmt.Errorf("failed to read llm cache: %w", err)
+	}
+	return content, createdAt, true, nil
+}
+
+// UpsertLLMCompletion stores a completion under its request hash, refreshing
+// created_at.
+func (db *PGXStore) UpsertLLMCompletion(parent context.Context, key, model, content string) error {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+	_, err := db.Exec(qctx, `
+		INSERT INTO llm_cache (cache_key, model, content, created_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (cache_key) DO UPDATE
+		SET model = EXCLUDED.model, content = EXCLUDED.content, created_at = now()
+	`, key, model, content)
+	if err != nil {
+		return fmt.Errorf("failed to upsert llm cache: %w", err)
+	}
+	return nil
+}
+
+// PruneLLMCompletions deletes cache rows older than maxAge, then the oldest
+// rows beyond maxRows (0 = no row cap). Returns the number deleted.
+func (db *PGXStore) PruneLLMCompletions(parent context.Context, maxAge time.Duration, maxRows int) (int64, error) {
+	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(qctx, `DELETE FROM llm_cache WHERE created_at < now() - make_interval(secs => $1)`, maxAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune expired llm cache rows: %w", err)
+	}
+	deleted := tag.RowsAffected()
+	if maxRows > 0 {
+		tag, err = db.Exec(qctx, `
+			DELETE FROM llm_cache WHERE cache_key IN (
+				SELECT cache_key FROM llm_cache ORDER BY created_at DESC OFFSET $1
+			)
+		`, maxRows)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune llm cache to size: %w", err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	return deleted, nil
+}
+
 // UpsertLastfmArtist writes listeners + tags together, refreshing fetched_at.
 func (db *PGXStore) UpsertLastfmArtist(parent context.Context, artistKey string, listeners int, tags []string) error {
 	qctx, cancel := context.WithTimeout(parent, DefaultQueryTimeout)
@@ -805,6 +1166,63 @@ func (db *PGXStore) UpsertLastfmArtist(parent context.Context, artistKey string,
 	return nil
 }
 
+// updateRollingPostSQL updates a digest by id. window_start + day_local +
+// subreddit_id (opening sub) stay as they were — the digest's identity is
+// fixed at opening time. subreddit_ids accumulates contributing subs.
+const updateRollingPostSQL = `
+	UPDATE rolling_posts SET
+		subreddit_ids       = $2,
+		mode                = $3,
+		discord_message_ids = $4,
+		thread_id           = $5,
+		thread_message_ids  = $6,
+		narrative_title     = $7,
+		narrative_summary   = $8,
+		entries             = $9,
+		included_post_ids   = $10,
+		included_rule_ids   = $11,
+		latest_score        = $12,
+		latest_comments     = $13,
+		latest_url          = $14,
+		latest_thumbnail    = $15,
+		updated_at          = now()
+	WHERE id = $1
+	RETURNING ` + rollingPostCols
+
+// rollingPostUpdateArgs binds rp to updateRollingPostSQL. The array
+// columns are NOT NULL, so nil slices are written empty.
+func rollingPostUpdateArgs(rp RollingPost) []any {
+	entries := rp.Entries
+	if len(entries) == 0 {
+		entries = []byte("[]")
+	}
+	if rp.Mode == "" {
+		rp.Mode = ModeNarrative
+	}
+	orEmpty := func(s []string) []string {
+		if s == nil {
+			return []string{}
+		}
+		return s
+	}
+	orEmptyInt := func(s []int) []int {
+		if s == nil {
+			return []int{}
+		}
+		return s
+	}
+	return []any{
+		rp.ID,
+		orEmptyInt(rp.SubredditIDs),
+		rp.Mode, orEmpty(rp.DiscordMessageIDs),
+		rp.ThreadID, orEmpty(rp.ThreadMessageIDs),
+		rp.NarrativeTitle, rp.NarrativeSummary, entries,
+		orEmpty(rp.IncludedPostIDs), orEmptyInt(rp.IncludedRuleIDs),
+		rp.LatestScore, rp.LatestComments, rp.LatestURL,
+		rp.LatestThumbnail,
+	}
+}
+
 // UpsertRollingPost is id-aware:
 //   - rp.ID == 0 → INSERT a fresh window-bounded row. window_start is stamped
 //     at now() (or rp.WindowStart if set by the caller).
@@ -825,27 +1243,7 @@ func (db *PGXStore) UpsertRollingPost(parent context.Context, rp RollingPost) (*
 		rp.Mode = "narrative"
 	}
 
-	const selectCols = `
-		id, channel_id,
-		COALESCE(subreddit_id, 0),
-		COALESCE(subreddit_ids, '{}'::int[]),
-		day_local, window_start,
-		COALESCE(mode, 'narrative'),
-		COALESCE(discord_message_ids, '{}'::text[]),
-		COALESCE(thread_id, ''),
-		COALESCE(thread_message_ids, '{}'::text[]),
-		narrative_title, narrative_summary,
-		COALESCE(entries, '[]'::jsonb),
-		included_post_ids, included_rule_ids,
-		latest_score, latest_comments, latest_url,
-		latest_thumbnail, updated_at`
-
-	var (
-		out RollingPost
-		row interface {
-			Scan(dest ...any) error
-		}
-	)
+	var row pgx.Row
 
 	subredditIDs := rp.SubredditIDs
 	if subredditIDs == nil {
@@ -855,6 +1253,15 @@ func (db *PGXStore) UpsertRollingPost(parent context.Context, rp RollingPost) (*
 	if threadMessageIDs == nil {
 		threadMessageIDs = []string{}
 	}
+	if rp.DiscordMessageIDs == nil {
+		rp.DiscordMessageIDs = []string{}
+	}
+	if rp.IncludedPostIDs == nil {
+		rp.IncludedPostIDs = []string{}
+	}
+	if rp.IncludedRuleIDs == nil {
+		rp.IncludedRuleIDs = []int{}
+	}
 
 	if rp.ID == 0 {
 		// Fresh insert — stamp window_start if caller left it zero.
@@ -873,7 +1280,7 @@ func (db *PGXStore) UpsertRollingPost(parent context.Context, rp RollingPost) (*
 				latest_score, latest_comments, latest_url,
 				latest_thumbnail, updated_at
 			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, now())
-			RETURNING ` + selectCols
+			RETURNING ` + rollingPostCols
 		row = db.QueryRow(qctx, query,
 			rp.ChannelID, rp.SubredditID, subredditIDs,
 			rp.DayLocal, windowStart,
@@ -885,51 +1292,12 @@ func (db *PGXStore) UpsertRollingPost(parent context.Context, rp RollingPost) (*
 			rp.LatestThumbnail,
 		)
 	} else {
-		// Update by id. window_start + day_local + subreddit_id (opening sub)
-		// stay as they were — the digest's identity is fixed at opening time.
-		// subreddit_ids accumulates contributing subs.
-		query := `
-			UPDATE rolling_posts SET
-				subreddit_ids       = $2,
-				mode                = $3,
-				discord_message_ids = $4,
-				thread_id           = $5,
-				thread_message_ids  = $6,
-				narrative_title     = $7,
-				narrative_summary   = $8,
-				entries             = $9,
-				included_post_ids   = $10,
-				included_rule_ids   = $11,
-				latest_score        = $12,
-				latest_comments     = $13,
-				latest_url          = $14,
-				latest_thumbnail    = $15,
-				updated_at          = now()
-			WHERE id = $1
-			RETURNING ` + selectCols
-		row = db.QueryRow(qctx, query,
-			rp.ID,
-			subredditIDs,
-			rp.Mode, rp.DiscordMessageIDs,
-			rp.ThreadID, threadMessageIDs,
-			rp.NarrativeTitle, rp.NarrativeSummary, entries,
-			rp.IncludedPostIDs, rp.IncludedRuleIDs,
-			rp.LatestScore, rp.LatestComments, rp.LatestURL,
-			rp.LatestThumbnail,
-		)
+		row = db.QueryRow(qctx, updateRollingPostSQL, rollingPostUpdateArgs(rp)...)
 	}
 
-	if err := row.Scan(
-		&out.ID, &out.ChannelID, &out.SubredditID, &out.SubredditIDs,
-		&out.DayLocal, &out.WindowStart,
-		&out.Mode, &out.DiscordMessageIDs,
-		&out.ThreadID, &out.ThreadMessageIDs,
-		&out.NarrativeTitle, &out.NarrativeSummary, &out.Entries,
-		&out.IncludedPostIDs, &out.IncludedRuleIDs,
-		&out.LatestScore, &out.LatestComments, &out.LatestURL,
-		&out.LatestThumbnail, &out.UpdatedAt,
-	); err != nil {
+	out, err := scanRollingPost(row)
+	if err != nil {
 		return nil, fmt.Errorf("failed to upsert rolling post: %w", err)
 	}
-	return &out, nil
+	return out, nil
 }
@@ -0,0 +1,1469 @@
+// Package storetest is the conformance suite every Store backend passes,
+// so PGXStore, SQLiteStore and MemStore stay interchangeable. A backend's
+// test calls Run with a function that returns a freshly migrated, empty
+// store.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// Run runs the suite. open is called once per subtest and must return an
+// empty store; it registers its own cleanup.
+func Run(t *testing.T, open func(t *testing.T) dbstore.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s dbstore.Store)
+	}{
+		{"Identities", testIdentities},
+		{"Notifications", testNotifications},
+		{"ConcurrentInserts", testConcurrentInserts},
+		{"Rules", testRules},
+		{"RulePause", testRulePause},
+		{"RollingPosts", testRollingPosts},
+		{"DigestLifecycle", testDigestLifecycle},
+		{"Caches", testCaches},
+		{"LLMCompletions", testLLMCompletions},
+		{"Classifications", testClassifications},
+		{"Embeddings", testEmbeddings},
+		{"DigestItems", testDigestItems},
+		{"LLMJobs", testLLMJobs},
+		{"Prompts", testPrompts},
+		{"Retention", testRetention},
+		{"GuildConfig", testGuildConfig},
+		{"ManagedRules", testManagedRules},
+		{"Feedback", testFeedback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// fixture is one server, channel, subreddit, post and rule.
+type fixture struct {
+	server    *dbstore.DiscordServer
+	channel   *dbstore.DiscordChannel
+	subreddit *dbstore.Subreddit
+	post      *dbstore.Post
+	rule      *dbstore.Rule
+}
+
+func newFixture(t *testing.T, s dbstore.Store) fixture {
+	t.Helper()
+	ctx := context.Background()
+	var f fixture
+	var err error
+	if f.server, err = s.InsertDiscordServer(ctx, "Server1"); err != nil {
+		t.Fatalf("InsertDiscordServer: %v", err)
+	}
+	if f.channel, err = s.InsertDiscordChannel(ctx, "Channel1", f.server.ID); err != nil {
+		t.Fatalf("InsertDiscordChannel: %v", err)
+	}
+	if f.subreddit, err = s.InsertSubreddit(ctx, "Metalcore"); err != nil {
+		t.Fatalf("InsertSubreddit: %v", err)
+	}
+	if f.post, err = s.InsertPost(ctx, "AbC123"); err != nil {
+		t.Fatalf("InsertPost: %v", err)
+	}
+	if f.rule, err = s.InsertRule(ctx, dbstore.Rule{
+		Target: "Title", TargetID: "Tour", DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID,
+	}); err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+	return f
+}
+
+func testIdentities(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if f.server.ExternalID != "server1" || f.channel.ExternalID != "channel1" ||
+		f.subreddit.ExternalID != "metalcore" || f.post.ExternalID != "abc123" {
+		t.Errorf("external ids not lowercased: %q %q %q %q",
+			f.server.ExternalID, f.channel.ExternalID, f.subreddit.ExternalID, f.post.ExternalID)
+	}
+
+	server, err := s.InsertDiscordServer(ctx, "SERVER1")
+	if err != nil || server.ID != f.server.ID {
+		t.Errorf("re-insert server = %+v, %v; want id %d", server, err, f.server.ID)
+	}
+	channel, err := s.InsertDiscordChannel(ctx, "channel1", f.server.ID)
+	if err != nil || channel.ID != f.channel.ID {
+		t.Errorf("re-insert channel = %+v, %v; want id %d", channel, err, f.channel.ID)
+	}
+	sub, err := s.InsertSubreddit(ctx, "METALCORE")
+	if err != nil || sub.ID != f.subreddit.ID {
+		t.Errorf("re-insert subreddit = %+v, %v; want id %d", sub, err, f.subreddit.ID)
+	}
+	post, err := s.InsertPost(ctx, "abc123")
+	if err != nil || post.ID != f.post.ID {
+		t.Errorf("re-insert post = %+v, %v; want id %d", post, err, f.post.ID)
+	}
+
+	if got, err := s.GetDiscordServerByExternalID(ctx, "Server1"); err != nil || got.ID != f.server.ID {
+		t.Errorf("GetDiscordServerByExternalID = %+v, %v", got, err)
+	}
+	if got, err := s.GetDiscordChannel(ctx, f.channel.ID); err != nil || got.ExternalID != "channel1" {
+		t.Errorf("GetDiscordChannel = %+v, %v", got, err)
+	}
+	if got, err := s.GetDiscordChannelByExternalID(ctx, "CHANNEL1"); err != nil || got.ID != f.channel.ID {
+		t.Errorf("GetDiscordChannelByExternalID = %+v, %v", got, err)
+	}
+	if got, err := s.GetSubredditByExternalID(ctx, "Metalcore"); err != nil || got.ID != f.subreddit.ID {
+		t.Errorf("GetSubredditByExternalID = %+v, %v", got, err)
+	}
+	if _, err := s.GetSubredditByExternalID(ctx, "nope"); err == nil {
+		t.Error("GetSubredditByExternalID(missing): want error")
+	}
+
+	if _, err := s.InsertSubreddit(ctx, "poppunkers"); err != nil {
+		t.Fatalf("InsertSubreddit: %v", err)
+	}
+	subs, err := s.GetSubreddits(ctx)
+	if err != nil {
+		t.Fatalf("GetSubreddits: %v", err)
+	}
+	var names []string
+	for _, sr := range subs {
+		names = append(names, sr.ExternalID)
+	}
+	if len(names) != 2 || !contains(names, "metalcore") || !contains(names, "poppunkers") {
+		t.Errorf("GetSubreddits = %v", names)
+	}
+}
+
+func testNotifications(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if n, err := s.GetNotificationCount(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil || n != 0 {
+		t.Fatalf("count before = %d, %v", n, err)
+	}
+	first, err := s.InsertNotification(ctx, f.post.ID, f.channel.ID, f.rule.ID)
+	if err != nil {
+		t.Fatalf("InsertNotification: %v", err)
+	}
+	again, err := s.InsertNotification(ctx, f.post.ID, f.channel.ID, f.rule.ID)
+	if err != nil || again.ID != first.ID {
+		t.Errorf("re-insert notification = %+v, %v; want id %d", again, err, first.ID)
+	}
+	if n, err := s.GetNotificationCount(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil || n != 1 {
+		t.Errorf("count after = %d, %v; want 1", n, err)
+	}
+}
+
+// testConcurrentInserts races inserts of the same rows; every caller must
+// get the one row back.
+func testConcurrentInserts(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	const n = 8
+	var wg sync.WaitGroup
+	subs := make([]*dbstore.Subreddit, n)
+	notes := make([]*dbstore.Notification, n)
+	errs := make([]error, 2*n)
+	for i := range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			subs[i], errs[i] = s.InsertSubreddit(ctx, "PopPunkers")
+		}()
+		go func() {
+			defer wg.Done()
+			notes[i], errs[n+i] = s.InsertNotification(ctx, f.post.ID, f.channel.ID, f.rule.ID)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent insert: %v", err)
+		}
+	}
+	for i := 1; i < n; i++ {
+		if subs[i].ID != subs[0].ID || notes[i].ID != notes[0].ID {
+			t.Fatalf("concurrent inserts returned different rows: subreddit %d vs %d, notification %d vs %d",
+				subs[i].ID, subs[0].ID, notes[i].ID, notes[0].ID)
+		}
+	}
+	if c, err := s.GetNotificationCount(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil || c != 1 {
+		t.Errorf("count after concurrent inserts = %d, %v; want 1", c, err)
+	}
+}
+
+func testRules(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if f.rule.Mode != dbstore.ModeNarrative || f.rule.WindowHours != 72 {
+		t.Errorf("InsertRule defaults: mode %q window %d", f.rule.Mode, f.rule.WindowHours)
+	}
+	semantic, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "semantic", TargetID: "new album announcements", Mode: dbstore.ModeMusic, WindowHours: 24,
+		Threshold: 0.8, DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+
+	rules, err := s.GetRules(ctx, f.subreddit.ID)
+	if err != nil {
+		t.Fatalf("GetRules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("GetRules returned %d rules, want 2", len(rules))
+	}
+	for _, r := range rules {
+		if r.DiscordServerID != f.server.ID || r.DiscordChannelID != f.channel.ID || r.SubredditID != f.subreddit.ID {
+			t.Errorf("rule %d ids = server %d channel %d subreddit %d", r.ID, r.DiscordServerID, r.DiscordChannelID, r.SubredditID)
+		}
+		if r.ID == semantic.ID && (r.Mode != dbstore.ModeMusic || r.WindowHours != 24 || r.Threshold != 0.8) {
+			t.Errorf("semantic rule = %+v", r)
+		}
+	}
+
+	details, err := s.GetRulesByChannel(ctx, "CHANNEL1")
+	if err != nil {
+		t.Fatalf("GetRulesByChannel: %v", err)
+	}
+	if len(details) != 2 || details[0].ID != f.rule.ID || details[1].ID != semantic.ID {
+		t.Fatalf("GetRulesByChannel = %+v, want rules %d and %d in id order", details, f.rule.ID, semantic.ID)
+	}
+	want := dbstore.RuleDetail{
+		ID: f.rule.ID, Target: "title", TargetID: "tour", Mode: dbstore.ModeNarrative, WindowHours: 72,
+		ExcludeTerms: []string{}, Subreddit: "metalcore", ServerID: f.server.ID,
+	}
+	if !reflect.DeepEqual(*details[0], want) {
+		t.Errorf("rule detail = %+v, want %+v", *details[0], want)
+	}
+
+	if err := s.UpdateRule(ctx, f.rule.ID, "Author", true); err != nil {
+		t.Fatalf("UpdateRule: %v", err)
+	}
+	if err := s.UpdateRuleMode(ctx, f.rule.ID, dbstore.ModeSummary); err != nil {
+		t.Fatalf("UpdateRuleMode: %v", err)
+	}
+	if err := s.UpdateRuleWindowHours(ctx, f.rule.ID, 12); err != nil {
+		t.Fatalf("UpdateRuleWindowHours: %v", err)
+	}
+	if err := s.UpdateRuleThreshold(ctx, f.rule.ID, 0.5); err != nil {
+		t.Fatalf("UpdateRuleThreshold: %v", err)
+	}
+	got, err := s.GetRuleByID(ctx, f.rule.ID)
+	if err != nil {
+		t.Fatalf("GetRuleByID: %v", err)
+	}
+	if got.Target != "author" || !got.Exact || got.Mode != dbstore.ModeSummary || got.WindowHours != 12 || got.Threshold != 0.5 {
+		t.Errorf("updated rule = %+v", got)
+	}
+
+	if err := s.UpdateRuleWindowHours(ctx, f.rule.ID, 0); err == nil {
+		t.Error("UpdateRuleWindowHours(0): want error")
+	}
+	if err := s.UpdateRuleThreshold(ctx, f.rule.ID, 1.5); err == nil {
+		t.Error("UpdateRuleThreshold(1.5): want error")
+	}
+	const missing = 1 << 30
+	for name, err := range map[string]error{
+		"UpdateRule":            s.UpdateRule(ctx, missing, "title", false),
+		"UpdateRuleMode":        s.UpdateRuleMode(ctx, missing, dbstore.ModeMusic),
+		"UpdateRuleWindowHours": s.UpdateRuleWindowHours(ctx, missing, 1),
+		"UpdateRuleThreshold":   s.UpdateRuleThreshold(ctx, missing, 0.1),
+		"DeleteRule":            s.DeleteRule(ctx, missing),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("%s(missing) = %v, want a not found error", name, err)
+		}
+	}
+
+	if err := s.DeleteRule(ctx, semantic.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if _, err := s.GetRuleByID(ctx, semantic.ID); err == nil {
+		t.Error("GetRuleByID after delete: want error")
+	}
+}
+
+func testRulePause(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+	other, err := s.InsertSubreddit(ctx, "poppunkers")
+	if err != nil {
+		t.Fatalf("InsertSubreddit: %v", err)
+	}
+	otherRule, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "title", TargetID: "split", DiscordChannelID: f.channel.ID, SubredditID: other.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+	if _, err := s.InsertSubreddit(ctx, "norules"); err != nil {
+		t.Fatalf("InsertSubreddit: %v", err)
+	}
+
+	active := func() []string {
+		t.Helper()
+		subs, err := s.GetActiveSubreddits(ctx)
+		if err != nil {
+			t.Fatalf("GetActiveSubreddits: %v", err)
+		}
+		var names []string
+		for _, sr := range subs {
+			names = append(names, sr.ExternalID)
+		}
+		return names
+	}
+	if got := active(); !reflect.DeepEqual(got, []string{"metalcore", "poppunkers"}) {
+		t.Errorf("active subreddits = %v, want both subreddits with rules", got)
+	}
+
+	if err := s.UpdateRulePause(ctx, f.rule.ID, true, time.Time{}); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	until := time.Now().Add(2 * time.Hour).Truncate(time.Microsecond)
+	if err := s.UpdateRulePause(ctx, otherRule.ID, false, until); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	if err := s.UpdateRuleQuietHours(ctx, otherRule.ID, "22:00-07:00"); err != nil {
+		t.Fatalf("UpdateRuleQuietHours: %v", err)
+	}
+	if got := active(); len(got) != 0 {
+		t.Errorf("active subreddits = %v, want none while paused and snoozed", got)
+	}
+
+	rules, err := s.GetRules(ctx, other.ID)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("GetRules = %v, %v", rules, err)
+	}
+	if r := rules[0]; r.Disabled || !r.PausedUntil.Equal(until) || r.QuietHours != "22:00-07:00" || !r.Paused(time.Now()) {
+		t.Errorf("snoozed rule = %+v", r)
+	}
+	paused, err := s.GetRuleByID(ctx, f.rule.ID)
+	if err != nil {
+		t.Fatalf("GetRuleByID: %v", err)
+	}
+	if !paused.Disabled || !paused.PausedUntil.IsZero() || !paused.Paused(time.Now()) {
+		t.Errorf("paused rule = %+v", paused)
+	}
+
+	// A snooze that has run out no longer counts.
+	if err := s.UpdateRulePause(ctx, otherRule.ID, false, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	if got := active(); !reflect.DeepEqual(got, []string{"poppunkers"}) {
+		t.Errorf("active subreddits = %v, want poppunkers once its snooze expired", got)
+	}
+
+	if err := s.UpdateRulePause(ctx, f.rule.ID, false, time.Time{}); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	if err := s.UpdateRuleQuietHours(ctx, otherRule.ID, ""); err != nil {
+		t.Fatalf("UpdateRuleQuietHours: %v", err)
+	}
+	details, err := s.GetRulesByChannel(ctx, "channel1")
+	if err != nil {
+		t.Fatalf("GetRulesByChannel: %v", err)
+	}
+	for _, d := range details {
+		if d.Disabled || d.QuietHours != "" || d.Paused(time.Now()) {
+			t.Errorf("resumed rule = %+v", d)
+		}
+	}
+
+	const missing = 1 << 30
+	for name, err := range map[string]error{
+		"UpdateRulePause":      s.UpdateRulePause(ctx, missing, true, time.Time{}),
+		"UpdateRuleQuietHours": s.UpdateRuleQuietHours(ctx, missing, "01:00-02:00"),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("%s(missing) = %v, want a not found error", name, err)
+		}
+	}
+}
+
+func testRollingPosts(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	windowStart := time.Now().Add(-2 * time.Hour).Truncate(time.Microsecond)
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	entries := []byte(`[{"artist":"Architects","score":12}]`)
+	rp, err := s.UpsertRollingPost(ctx, dbstore.RollingPost{
+		ChannelID:         f.channel.ID,
+		SubredditID:       f.subreddit.ID,
+		SubredditIDs:      []int{f.subreddit.ID},
+		DayLocal:          day,
+		WindowStart:       windowStart,
+		Mode:              dbstore.ModeMusic,
+		DiscordMessageIDs: []string{"m1", "m2"},
+		NarrativeTitle:    "Title",
+		Entries:           entries,
+		IncludedPostIDs:   []string{"abc123"},
+		IncludedRuleIDs:   []int{f.rule.ID},
+		LatestScore:       5,
+	})
+	if err != nil {
+		t.Fatalf("UpsertRollingPost insert: %v", err)
+	}
+	if rp.ID == 0 || rp.Mode != dbstore.ModeMusic || rp.SubredditID != f.subreddit.ID {
+		t.Errorf("inserted rolling post = %+v", rp)
+	}
+	if !rp.WindowStart.Equal(windowStart) {
+		t.Errorf("WindowStart = %v, want %v", rp.WindowStart, windowStart)
+	}
+	if rp.DayLocal.Format("2006-01-02") != "2026-03-14" {
+		t.Errorf("DayLocal = %v", rp.DayLocal)
+	}
+	if !reflect.DeepEqual(rp.DiscordMessageIDs, []string{"m1", "m2"}) ||
+		!reflect.DeepEqual(rp.SubredditIDs, []int{f.subreddit.ID}) ||
+		!reflect.DeepEqual(rp.IncludedRuleIDs, []int{f.rule.ID}) ||
+		!reflect.DeepEqual(rp.IncludedPostIDs, []string{"abc123"}) ||
+		len(rp.ThreadMessageIDs) != 0 {
+		t.Errorf("arrays did not round-trip: %+v", rp)
+	}
+	if !sameJSON(t, rp.Entries, entries) {
+		t.Errorf("Entries = %s, want %s", rp.Entries, entries)
+	}
+
+	if got, err := s.GetActiveRollingPost(ctx, f.channel.ID, dbstore.ModeMusic, 1); err != nil || got != nil {
+		t.Errorf("1h window: got %+v, %v; want nil", got, err)
+	}
+	if got, err := s.GetActiveRollingPost(ctx, f.channel.ID, dbstore.ModeNarrative, 3); err != nil || got != nil {
+		t.Errorf("other mode: got %+v, %v; want nil", got, err)
+	}
+	active, err := s.GetActiveRollingPost(ctx, f.channel.ID, dbstore.ModeMusic, 3)
+	if err != nil || active == nil || active.ID != rp.ID {
+		t.Fatalf("3h window: got %+v, %v; want row %d", active, err, rp.ID)
+	}
+
+	active.SubredditIDs = append(active.SubredditIDs, 99)
+	active.ThreadID = "t1"
+	active.ThreadMessageIDs = []string{"r1"}
+	active.LatestScore = 9
+	active.WindowStart = time.Now()
+	updated, err := s.UpsertRollingPost(ctx, *active)
+	if err != nil {
+		t.Fatalf("UpsertRollingPost update: %v", err)
+	}
+	if updated.ID != rp.ID || !updated.WindowStart.Equal(windowStart) {
+		t.Errorf("update moved the window: id %d start %v", updated.ID, updated.WindowStart)
+	}
+	if updated.ThreadID != "t1" || updated.LatestScore != 9 ||
+		!reflect.DeepEqual(updated.ThreadMessageIDs, []string{"r1"}) ||
+		!reflect.DeepEqual(updated.SubredditIDs, []int{f.subreddit.ID, 99}) {
+		t.Errorf("updated rolling post = %+v", updated)
+	}
+
+	if got, err := s.GetRollingPostByMessageID(ctx, "m2"); err != nil || got == nil || got.ID != rp.ID {
+		t.Errorf("GetRollingPostByMessageID(m2) = %+v, %v", got, err)
+	}
+	if got, err := s.GetRollingPostByMessageID(ctx, "m"); err != nil || got != nil {
+		t.Errorf("GetRollingPostByMessageID(m) = %+v, %v; want nil", got, err)
+	}
+
+	narrative, err := s.UpsertRollingPost(ctx, dbstore.RollingPost{
+		ChannelID: f.channel.ID, SubredditID: f.subreddit.ID, DayLocal: day,
+	})
+	if err != nil {
+		t.Fatalf("UpsertRollingPost narrative: %v", err)
+	}
+	if narrative.Mode != dbstore.ModeNarrative || time.Since(narrative.WindowStart) > time.Minute {
+		t.Errorf("defaults: mode %q window %v", narrative.Mode, narrative.WindowStart)
+	}
+	if got, err := s.GetActiveRollingPost(ctx, f.channel.ID, "", 1); err != nil || got == nil || got.ID != narrative.ID {
+		t.Errorf("GetActiveRollingPost(mode \"\") = %+v, %v; want row %d", got, err, narrative.ID)
+	}
+
+	if got, err := s.GetRollingPost(ctx, rp.ID); err != nil || got == nil || got.ThreadID != "t1" {
+		t.Errorf("GetRollingPost(%d) = %+v, %v", rp.ID, got, err)
+	}
+	if got, err := s.GetRollingPost(ctx, rp.ID+1000); err != nil || got != nil {
+		t.Errorf("GetRollingPost(missing) = %+v, %v; want nil", got, err)
+	}
+	listIDs := func(mode string, since time.Time, limit int) []int {
+		t.Helper()
+		rps, err := s.ListRollingPosts(ctx, f.channel.ID, mode, since, limit)
+		if err != nil {
+			t.Fatalf("ListRollingPosts(%q): %v", mode, err)
+		}
+		var ids []int
+		for _, rp := range rps {
+			ids = append(ids, rp.ID)
+		}
+		return ids
+	}
+	weekAgo := time.Now().Add(-7 * 24 * time.Hour)
+	if got := listIDs("", weekAgo, 10); !reflect.DeepEqual(got, []int{narrative.ID, rp.ID}) {
+		t.Errorf("ListRollingPosts(all) = %v, want newest first", got)
+	}
+	if got := listIDs(dbstore.ModeMusic, weekAgo, 10); !reflect.DeepEqual(got, []int{rp.ID}) {
+		t.Errorf("ListRollingPosts(music) = %v", got)
+	}
+	if got := listIDs("", time.Now().Add(-time.Hour), 10); !reflect.DeepEqual(got, []int{narrative.ID}) {
+		t.Errorf("ListRollingPosts(last hour) = %v", got)
+	}
+	if got := listIDs("", weekAgo, 1); !reflect.DeepEqual(got, []int{narrative.ID}) {
+		t.Errorf("ListRollingPosts(limit 1) = %v", got)
+	}
+}
+
+func testDigestLifecycle(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	open := func(start time.Time, posts ...string) *dbstore.RollingPost {
+		t.Helper()
+		rp, err := s.UpsertRollingPost(ctx, dbstore.RollingPost{
+			ChannelID: f.channel.ID, SubredditID: f.subreddit.ID, Mode: dbstore.ModeMusic,
+			WindowStart: start, IncludedPostIDs: posts,
+		})
+		if err != nil {
+			t.Fatalf("UpsertRollingPost: %v", err)
+		}
+		for _, p := range posts {
+			if err := s.InsertDigestItem(ctx, dbstore.DigestItem{
+				RollingPostID: rp.ID, PostID: p, CanonicalPostID: p, Subreddit: "metalcore",
+			}); err != nil {
+				t.Fatalf("InsertDigestItem: %v", err)
+			}
+		}
+		return rp
+	}
+	active := func(windowHours int) int {
+		t.Helper()
+		rp, err := s.GetActiveRollingPost(ctx, f.channel.ID, dbstore.ModeMusic, windowHours)
+		if err != nil {
+			t.Fatalf("GetActiveRollingPost: %v", err)
+		}
+		if rp == nil {
+			return 0
+		}
+		return rp.ID
+	}
+
+	yesterday := open(time.Now().Add(-30*time.Hour), "p1", "p2")
+	today := open(time.Now().Add(-time.Hour), "p2", "p3")
+	if got := active(24); got != today.ID {
+		t.Fatalf("active = %d, want today's %d", got, today.ID)
+	}
+
+	if err := s.CloseRollingPost(ctx, today.ID); err != nil {
+		t.Fatalf("CloseRollingPost: %v", err)
+	}
+	if got := active(24); got != 0 {
+		t.Errorf("active after close = %d, want none", got)
+	}
+	if got, _ := s.GetRollingPost(ctx, today.ID); got == nil || got.WindowEnd.IsZero() || time.Since(got.WindowEnd) > time.Minute {
+		t.Errorf("closed digest = %+v", got)
+	}
+	// An update keeps the window closed.
+	if _, err := s.UpsertRollingPost(ctx, *today); err != nil {
+		t.Fatalf("UpsertRollingPost: %v", err)
+	}
+	if got := active(24); got != 0 {
+		t.Errorf("active after update = %d, want none", got)
+	}
+
+	if err := s.ReopenRollingPost(ctx, yesterday.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ReopenRollingPost: %v", err)
+	}
+	if got := active(24); got != yesterday.ID {
+		t.Errorf("active after reopen = %d, want %d", got, yesterday.ID)
+	}
+	// A reopened digest wins over a newer one still in its window.
+	newer := open(time.Now(), "p4")
+	if got := active(24); got != yesterday.ID {
+		t.Errorf("active with a newer digest = %d, want the reopened %d", got, yesterday.ID)
+	}
+	if err := s.CloseRollingPost(ctx, yesterday.ID); err != nil {
+		t.Fatalf("CloseRollingPost: %v", err)
+	}
+	if got := active(24); got != newer.ID {
+		t.Errorf("active after closing the reopened digest = %d, want %d", got, newer.ID)
+	}
+	if err := s.CloseRollingPost(ctx, newer.ID+1000); err == nil {
+		t.Error("CloseRollingPost(missing) succeeded")
+	}
+
+	into := *yesterday
+	into.IncludedPostIDs = []string{"p1", "p2", "p3"}
+	into.Entries = []byte(`[{"artist":"A"}]`)
+	merged, err := s.MergeRollingPosts(ctx, into, today.ID)
+	if err != nil {
+		t.Fatalf("MergeRollingPosts: %v", err)
+	}
+	if merged.ID != yesterday.ID || !reflect.DeepEqual(merged.IncludedPostIDs, []string{"p1", "p2", "p3"}) ||
+		!merged.WindowStart.Equal(yesterday.WindowStart) || merged.WindowEnd.IsZero() {
+		t.Errorf("merged = %+v", merged)
+	}
+	if got, err := s.GetRollingPost(ctx, today.ID); err != nil || got != nil {
+		t.Errorf("merged-away digest = %+v, %v; want deleted", got, err)
+	}
+	items, err := s.GetDigestItems(ctx, yesterday.ID)
+	if err != nil {
+		t.Fatalf("GetDigestItems: %v", err)
+	}
+	var posts []string
+	for _, it := range items {
+		posts = append(posts, it.PostID)
+	}
+	slices.Sort(posts)
+	if !reflect.DeepEqual(posts, []string{"p1", "p2", "p3"}) {
+		t.Errorf("items after merge = %v", posts)
+	}
+	if _, err := s.MergeRollingPosts(ctx, into, today.ID); err == nil {
+		t.Error("merging a deleted digest succeeded")
+	}
+	if got, _ := s.GetRollingPost(ctx, yesterday.ID); got == nil {
+		t.Error("failed merge deleted the target")
+	}
+}
+
+func testCaches(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+
+	if _, _, ok, err := s.GetLastfmListeners(ctx, "architects"); err != nil || ok {
+		t.Errorf("lastfm miss: ok %v, %v", ok, err)
+	}
+	if err := s.UpsertLastfmListeners(ctx, "architects", 100); err != nil {
+		t.Fatalf("UpsertLastfmListeners: %v", err)
+	}
+	if n, at, ok, err := s.GetLastfmListeners(ctx, "architects"); err != nil || !ok || n != 100 || time.Since(at) > time.Minute {
+		t.Errorf("GetLastfmListeners = %d %v %v %v", n, at, ok, err)
+	}
+	if err := s.UpsertLastfmArtist(ctx, "architects", 200, []string{"metalcore", "uk"}); err != nil {
+		t.Fatalf("UpsertLastfmArtist: %v", err)
+	}
+	if n, tags, _, ok, err := s.GetLastfmArtist(ctx, "architects"); err != nil || !ok || n != 200 || !reflect.DeepEqual(tags, []string{"metalcore", "uk"}) {
+		t.Errorf("GetLastfmArtist = %d %v %v %v", n, tags, ok, err)
+	}
+	if err := s.UpsertLastfmListeners(ctx, "sleep token", 1); err != nil {
+		t.Fatalf("UpsertLastfmListeners: %v", err)
+	}
+	if _, tags, _, ok, err := s.GetLastfmArtist(ctx, "sleep token"); err != nil || !ok || len(tags) != 0 {
+		t.Errorf("GetLastfmArtist without tags = %v %v %v", tags, ok, err)
+	}
+
+	if _, _, ok, err := s.GetPipedVideo(ctx, "q"); err != nil || ok {
+		t.Errorf("piped miss: ok %v, %v", ok, err)
+	}
+	if err := s.UpsertPipedVideo(ctx, "q", ""); err != nil {
+		t.Fatalf("UpsertPipedVideo: %v", err)
+	}
+	if u, _, ok, err := s.GetPipedVideo(ctx, "q"); err != nil || !ok || u != "" {
+		t.Errorf("cached piped miss = %q %v %v", u, ok, err)
+	}
+	if err := s.UpsertPipedVideo(ctx, "q", "https://music.youtube.com/watch?v=x"); err != nil {
+		t.Fatalf("UpsertPipedVideo: %v", err)
+	}
+	if u, _, _, _ := s.GetPipedVideo(ctx, "q"); u != "https://music.youtube.com/watch?v=x" {
+		t.Errorf("piped hit = %q", u)
+	}
+
+	if _, _, ok, err := s.GetQobuzAlbum(ctx, "q"); err != nil || ok {
+		t.Errorf("qobuz miss: ok %v, %v", ok, err)
+	}
+	if err := s.UpsertQobuzAlbum(ctx, "q", "https://open.qobuz.com/album/1"); err != nil {
+		t.Fatalf("UpsertQobuzAlbum: %v", err)
+	}
+	if u, _, ok, err := s.GetQobuzAlbum(ctx, "q"); err != nil || !ok || u != "https://open.qobuz.com/album/1" {
+		t.Errorf("GetQobuzAlbum = %q %v %v", u, ok, err)
+	}
+
+	if a, err := s.GetCachedArticle(ctx, "https://example.com/a"); err != nil || a != nil {
+		t.Errorf("article miss = %+v, %v", a, err)
+	}
+	article := dbstore.CachedArticle{URL: "https://example.com/a", Title: "T", Description: "D", ImageURL: "I", Body: "B"}
+	if err := s.UpsertCachedArticle(ctx, article); err != nil {
+		t.Fatalf("UpsertCachedArticle: %v", err)
+	}
+	a, err := s.GetCachedArticle(ctx, article.URL)
+	if err != nil || a == nil {
+		t.Fatalf("GetCachedArticle = %+v, %v", a, err)
+	}
+	if time.Since(a.FetchedAt) > time.Minute {
+		t.Errorf("FetchedAt = %v", a.FetchedAt)
+	}
+	a.FetchedAt = time.Time{}
+	if *a != article {
+		t.Errorf("article = %+v, want %+v", *a, article)
+	}
+}
+
+func testLLMCompletions(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+
+	if _, _, ok, err := s.GetLLMCompletion(ctx, "k1"); err != nil || ok {
+		t.Errorf("llm miss: ok %v, %v", ok, err)
+	}
+	for _, k := range []string{"k1", "k2", "k3"} {
+		if err := s.UpsertLLMCompletion(ctx, k, "model", "content "+k); err != nil {
+			t.Fatalf("UpsertLLMCompletion: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := s.UpsertLLMCompletion(ctx, "k1", "model", "fresh"); err != nil {
+		t.Fatalf("UpsertLLMCompletion: %v", err)
+	}
+	if c, at, ok, err := s.GetLLMCompletion(ctx, "k1"); err != nil || !ok || c != "fresh" || time.Since(at) > time.Minute {
+		t.Errorf("GetLLMCompletion = %q %v %v %v", c, at, ok, err)
+	}
+
+	if n, err := s.PruneLLMCompletions(ctx, time.Hour, 0); err != nil || n != 0 {
+		t.Errorf("prune nothing expired = %d, %v", n, err)
+	}
+	// k2 is now the oldest row; capping at two drops it.
+	if n, err := s.PruneLLMCompletions(ctx, time.Hour, 2); err != nil || n != 1 {
+		t.Errorf("prune to 2 rows = %d, %v; want 1", n, err)
+	}
+	if _, _, ok, _ := s.GetLLMCompletion(ctx, "k2"); ok {
+		t.Error("k2 survived the row cap")
+	}
+	for _, k := range []string{"k1", "k3"} {
+		if _, _, ok, _ := s.GetLLMCompletion(ctx, k); !ok {
+			t.Errorf("%s was pruned", k)
+		}
+	}
+}
+
+func testClassifications(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+
+	if pc, err := s.GetPostClassification(ctx, "abc"); err != nil || pc != nil {
+		t.Errorf("classification miss = %+v, %v", pc, err)
+	}
+	if err := s.UpsertPostClassification(ctx, dbstore.PostClassification{
+		PostID: "abc", Sentiment: "positive", Topics: []string{"tour", "album"}, Toxic: true,
+	}); err != nil {
+		t.Fatalf("UpsertPostClassification: %v", err)
+	}
+	pc, err := s.GetPostClassification(ctx, "abc")
+	if err != nil || pc == nil {
+		t.Fatalf("GetPostClassification = %+v, %v", pc, err)
+	}
+	if pc.Sentiment != "positive" || !pc.Toxic || !reflect.DeepEqual(pc.Topics, []string{"tour", "album"}) || time.Since(pc.ClassifiedAt) > time.Minute {
+		t.Errorf("classification = %+v", pc)
+	}
+	if err := s.UpsertPostClassification(ctx, dbstore.PostClassification{PostID: "abc", Sentiment: "negative"}); err != nil {
+		t.Fatalf("UpsertPostClassification: %v", err)
+	}
+	if pc, _ := s.GetPostClassification(ctx, "abc"); pc.Sentiment != "negative" || pc.Toxic || len(pc.Topics) != 0 {
+		t.Errorf("reclassified = %+v", pc)
+	}
+}
+
+func testEmbeddings(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if re, err := s.GetRuleEmbedding(ctx, f.rule.ID); err != nil || re != nil {
+		t.Errorf("embedding miss = %+v, %v", re, err)
+	}
+	vec := []float32{0.25, -1.5, 3.125e-5}
+	if err := s.UpsertRuleEmbedding(ctx, dbstore.RuleEmbedding{RuleID: f.rule.ID, Model: "m1", Source: "tour", Embedding: vec}); err != nil {
+		t.Fatalf("UpsertRuleEmbedding: %v", err)
+	}
+	if err := s.UpsertRuleEmbedding(ctx, dbstore.RuleEmbedding{RuleID: f.rule.ID, Model: "m2", Source: "tour dates", Embedding: vec}); err != nil {
+		t.Fatalf("UpsertRuleEmbedding replace: %v", err)
+	}
+	re, err := s.GetRuleEmbedding(ctx, f.rule.ID)
+	if err != nil || re == nil {
+		t.Fatalf("GetRuleEmbedding = %+v, %v", re, err)
+	}
+	if re.Model != "m2" || re.Source != "tour dates" || !reflect.DeepEqual(re.Embedding, vec) {
+		t.Errorf("embedding = %+v", re)
+	}
+
+	if err := s.DeleteRule(ctx, f.rule.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if re, err := s.GetRuleEmbedding(ctx, f.rule.ID); err != nil || re != nil {
+		t.Errorf("embedding outlived its rule: %+v, %v", re, err)
+	}
+}
+
+func testDigestItems(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	rp, err := s.UpsertRollingPost(ctx, dbstore.RollingPost{ChannelID: f.channel.ID, SubredditID: f.subreddit.ID, DayLocal: time.Now()})
+	if err != nil {
+		t.Fatalf("UpsertRollingPost: %v", err)
+	}
+	if items, err := s.GetDigestItems(ctx, rp.ID); err != nil || len(items) != 0 {
+		t.Errorf("no items = %+v, %v", items, err)
+	}
+	first := dbstore.DigestItem{
+		RollingPostID: rp.ID, PostID: "p1", Subreddit: "metalcore", Title: "Tour announced",
+		URL: "example.com/tour", TitleHash: 1<<63 | 5, Score: 10, Comments: 2,
+	}
+	if err := s.InsertDigestItem(ctx, first); err != nil {
+		t.Fatalf("InsertDigestItem: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	copyItem := dbstore.DigestItem{
+		RollingPostID: rp.ID, PostID: "p2", CanonicalPostID: "p1", Subreddit: "poppunkers",
+		CrosspostParent: "t3_p1", Score: 3,
+	}
+	if err := s.InsertDigestItem(ctx, copyItem); err != nil {
+		t.Fatalf("InsertDigestItem: %v", err)
+	}
+	dup := first
+	dup.Title = "changed"
+	if err := s.InsertDigestItem(ctx, dup); err != nil {
+		t.Fatalf("InsertDigestItem duplicate: %v", err)
+	}
+
+	items, err := s.GetDigestItems(ctx, rp.ID)
+	if err != nil {
+		t.Fatalf("GetDigestItems: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("GetDigestItems returned %d items, want 2", len(items))
+	}
+	if items[0].PostID != "p1" || items[0].CanonicalPostID != "p1" || items[0].Title != "Tour announced" ||
+		items[0].TitleHash != 1<<63|5 || items[0].Score != 10 || time.Since(items[0].SeenAt) > time.Minute {
+		t.Errorf("first item = %+v", items[0])
+	}
+	if items[1].PostID != "p2" || items[1].CanonicalPostID != "p1" || items[1].CrosspostParent != "t3_p1" {
+		t.Errorf("second item = %+v", items[1])
+	}
+}
+
+func testLLMJobs(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	job := dbstore.LLMJob{
+		Kind: dbstore.JobKindMusicExtract, ChannelID: f.channel.ID, RuleID: f.rule.ID, PostID: f.post.ID,
+		Payload: []byte(`{"id":"abc123"}`), MaxAttempts: 3, NextAttemptAt: time.Now().Add(-time.Second),
+	}
+	queued, err := s.EnqueueLLMJob(ctx, job)
+	if err != nil {
+		t.Fatalf("EnqueueLLMJob: %v", err)
+	}
+	if queued.ID == 0 || queued.Status != dbstore.JobStatusPending || queued.Attempts != 0 || queued.MaxAttempts != 3 {
+		t.Errorf("queued job = %+v", queued)
+	}
+	if !sameJSON(t, queued.Payload, job.Payload) {
+		t.Errorf("payload = %s", queued.Payload)
+	}
+	job.Payload = []byte(`{"id":"other"}`)
+	if again, err := s.EnqueueLLMJob(ctx, job); err != nil || again.ID != queued.ID || !sameJSON(t, again.Payload, queued.Payload) {
+		t.Errorf("re-enqueue = %+v, %v; want the existing job", again, err)
+	}
+
+	claimed, err := s.ClaimDueLLMJobs(ctx, 10)
+	if err != nil {
+		t.Fatalf("ClaimDueLLMJobs: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != queued.ID || claimed[0].Status != dbstore.JobStatusRunning || claimed[0].Attempts != 1 {
+		t.Fatalf("claimed = %+v", claimed)
+	}
+	if again, err := s.ClaimDueLLMJobs(ctx, 10); err != nil || len(again) != 0 {
+		t.Errorf("second claim = %+v, %v; want none", again, err)
+	}
+
+	if err := s.FailLLMJob(ctx, queued.ID, "rate limited", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("FailLLMJob retry: %v", err)
+	}
+	if j, err := s.GetLLMJob(ctx, queued.ID); err != nil || j.Status != dbstore.JobStatusPending || j.LastError != "rate limited" {
+		t.Errorf("retrying job = %+v, %v", j, err)
+	}
+	if again, err := s.ClaimDueLLMJobs(ctx, 10); err != nil || len(again) != 0 {
+		t.Errorf("claim before retryAt = %+v, %v; want none", again, err)
+	}
+	if ok, err := s.RequeueLLMJob(ctx, queued.ID); err != nil || ok {
+		t.Errorf("requeue pending job = %v, %v; want false", ok, err)
+	}
+
+	if err := s.FailLLMJob(ctx, queued.ID, "gave up", time.Time{}); err != nil {
+		t.Fatalf("FailLLMJob: %v", err)
+	}
+	failed, err := s.ListFailedLLMJobs(ctx, f.channel.ID, 10)
+	if err != nil || len(failed) != 1 || failed[0].ID != queued.ID || failed[0].LastError != "gave up" {
+		t.Errorf("ListFailedLLMJobs = %+v, %v", failed, err)
+	}
+	if ok, err := s.RequeueLLMJob(ctx, queued.ID); err != nil || !ok {
+		t.Fatalf("RequeueLLMJob = %v, %v", ok, err)
+	}
+	claimed, err = s.ClaimDueLLMJobs(ctx, 10)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("claim after requeue = %+v, %v", claimed, err)
+	}
+	if err := s.CompleteLLMJob(ctx, queued.ID); err != nil {
+		t.Fatalf("CompleteLLMJob: %v", err)
+	}
+	if j, err := s.GetLLMJob(ctx, queued.ID); err != nil || j.Status != dbstore.JobStatusDone || j.LastError != "" {
+		t.Errorf("done job = %+v, %v", j, err)
+	}
+	if j, err := s.GetLLMJob(ctx, queued.ID+1000); err != nil || j != nil {
+		t.Errorf("GetLLMJob(missing) = %+v, %v", j, err)
+	}
+
+	// A held match is one rule's, so two rules matching the same post each
+	// get a deliver_match job; a repeat for either is still deduplicated.
+	other, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "title", TargetID: "merch", DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID, Mode: "music",
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+	deliver := dbstore.LLMJob{
+		Kind: dbstore.JobKindDeliverMatch, ChannelID: f.channel.ID, RuleID: f.rule.ID, PostID: f.post.ID,
+		Payload: []byte(`{}`), MaxAttempts: 3,
+	}
+	first, err := s.EnqueueLLMJob(ctx, deliver)
+	if err != nil {
+		t.Fatalf("EnqueueLLMJob deliver: %v", err)
+	}
+	deliver.RuleID = other.ID
+	second, err := s.EnqueueLLMJob(ctx, deliver)
+	if err != nil || second.ID == first.ID || second.RuleID != other.ID {
+		t.Errorf("second rule's deliver job = %+v, %v; want its own job", second, err)
+	}
+	if again, err := s.EnqueueLLMJob(ctx, deliver); err != nil || again.ID != second.ID {
+		t.Errorf("re-enqueue deliver = %+v, %v; want job %d", again, err, second.ID)
+	}
+}
+
+func testPrompts(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if tpl, err := s.GetPromptTemplate(ctx, "terse", "fresh"); err != nil || tpl != nil {
+		t.Errorf("template miss = %+v, %v", tpl, err)
+	}
+	for _, kt := range [][2]string{{"terse", "update"}, {"terse", "fresh"}, {"chatty", "fresh"}} {
+		if err := s.UpsertPromptTemplate(ctx, kt[0], kt[1], "v1"); err != nil {
+			t.Fatalf("UpsertPromptTemplate: %v", err)
+		}
+	}
+	if err := s.UpsertPromptTemplate(ctx, "terse", "fresh", "v2"); err != nil {
+		t.Fatalf("UpsertPromptTemplate: %v", err)
+	}
+	if tpl, err := s.GetPromptTemplate(ctx, "terse", "fresh"); err != nil || tpl == nil || tpl.Body != "v2" {
+		t.Errorf("GetPromptTemplate = %+v, %v", tpl, err)
+	}
+	list, err := s.ListPromptTemplates(ctx)
+	if err != nil {
+		t.Fatalf("ListPromptTemplates: %v", err)
+	}
+	var keys []string
+	for _, tpl := range list {
+		keys = append(keys, tpl.Name+"/"+tpl.Kind)
+	}
+	if want := []string{"chatty/fresh", "terse/fresh", "terse/update"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ListPromptTemplates = %v, want %v", keys, want)
+	}
+
+	str := func(s string) *string { return &s }
+	if err := s.SetChannelPrompt(ctx, f.channel.ID, str("chatty"), str("warm")); err != nil {
+		t.Fatalf("SetChannelPrompt: %v", err)
+	}
+	if err := s.SetChannelLanguage(ctx, f.channel.ID, "de"); err != nil {
+		t.Fatalf("SetChannelLanguage: %v", err)
+	}
+	if err := s.SetRulePrompt(ctx, f.rule.ID, str("terse"), nil); err != nil {
+		t.Fatalf("SetRulePrompt: %v", err)
+	}
+	want := dbstore.PromptSettings{Template: "terse", Tone: "warm", Language: "de"}
+	if got, err := s.GetPromptSettings(ctx, f.channel.ID, f.rule.ID); err != nil || got != want {
+		t.Errorf("rule settings = %+v, %v; want %+v", got, err, want)
+	}
+	want = dbstore.PromptSettings{Template: "chatty", Tone: "warm", Language: "de"}
+	if got, err := s.GetPromptSettings(ctx, f.channel.ID, 0); err != nil || got != want {
+		t.Errorf("channel settings = %+v, %v; want %+v", got, err, want)
+	}
+
+	// nil leaves a column alone; "" clears the override.
+	if err := s.SetRulePrompt(ctx, f.rule.ID, nil, str("dry")); err != nil {
+		t.Fatalf("SetRulePrompt: %v", err)
+	}
+	if err := s.SetRulePrompt(ctx, f.rule.ID, str(""), nil); err != nil {
+		t.Fatalf("SetRulePrompt: %v", err)
+	}
+	want = dbstore.PromptSettings{Template: "chatty", Tone: "dry", Language: "de"}
+	if got, err := s.GetPromptSettings(ctx, f.channel.ID, f.rule.ID); err != nil || got != want {
+		t.Errorf("after partial update = %+v, %v; want %+v", got, err, want)
+	}
+	if got, err := s.GetPromptSettings(ctx, f.channel.ID+1000, 0); err != nil || got != (dbstore.PromptSettings{}) {
+		t.Errorf("missing channel = %+v, %v", got, err)
+	}
+}
+
+// sameJSON compares JSON documents by value; Postgres' JSONB doesn't keep
+// the input's spacing.
+func testRetention(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	if _, err := s.PruneRows(ctx, "rules", future, 10); err == nil {
+		t.Error("PruneRows on an unknown table succeeded")
+	}
+
+	// f.post is notified; the orphan isn't referenced by anything.
+	if _, err := s.InsertNotification(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil {
+		t.Fatalf("InsertNotification: %v", err)
+	}
+	if _, err := s.InsertPost(ctx, "orphan"); err != nil {
+		t.Fatalf("InsertPost: %v", err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneNotifications, past, 10); err != nil || n != 0 {
+		t.Errorf("prune fresh notifications = %d, %v", n, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PrunePosts, future, 10); err != nil || n != 1 {
+		t.Errorf("prune posts = %d, %v; want only the orphan", n, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneNotifications, future, 10); err != nil || n != 1 {
+		t.Errorf("prune notifications = %d, %v; want 1", n, err)
+	}
+	if c, err := s.GetNotificationCount(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil || c != 0 {
+		t.Errorf("notification count after prune = %d, %v", c, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PrunePosts, future, 10); err != nil || n != 1 {
+		t.Errorf("prune posts once unreferenced = %d, %v; want 1", n, err)
+	}
+
+	// A done job goes and frees its post; a pending one holds on to its post.
+	var jobs []*dbstore.LLMJob
+	for _, id := range []string{"done", "pending"} {
+		p, err := s.InsertPost(ctx, id)
+		if err != nil {
+			t.Fatalf("InsertPost: %v", err)
+		}
+		j, err := s.EnqueueLLMJob(ctx, dbstore.LLMJob{
+			Kind: dbstore.JobKindMusicExtract, ChannelID: f.channel.ID, RuleID: f.rule.ID, PostID: p.ID,
+			Payload: []byte(`{}`), MaxAttempts: 3,
+		})
+		if err != nil {
+			t.Fatalf("EnqueueLLMJob: %v", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := s.CompleteLLMJob(ctx, jobs[0].ID); err != nil {
+		t.Fatalf("CompleteLLMJob: %v", err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneJobs, past, 10); err != nil || n != 0 {
+		t.Errorf("prune fresh jobs = %d, %v", n, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneJobs, future, 10); err != nil || n != 1 {
+		t.Errorf("prune jobs = %d, %v; want only the done one", n, err)
+	}
+	if j, err := s.GetLLMJob(ctx, jobs[1].ID); err != nil || j == nil {
+		t.Errorf("pending job was pruned: %v", err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PrunePosts, future, 10); err != nil || n != 1 {
+		t.Errorf("prune posts after jobs = %d, %v; want the done job's post", n, err)
+	}
+
+	// Digests age by window_start and take their items with them; one
+	// reopened past the cutoff stays.
+	var digests []*dbstore.RollingPost
+	for i, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour, 96 * time.Hour} {
+		rp, err := s.UpsertRollingPost(ctx, dbstore.RollingPost{
+			ChannelID: f.channel.ID, SubredditID: f.subreddit.ID, DayLocal: time.Now(),
+			WindowStart: time.Now().Add(-age), DiscordMessageIDs: []string{fmt.Sprintf("m%d", i)},
+		})
+		if err != nil {
+			t.Fatalf("UpsertRollingPost: %v", err)
+		}
+		digests = append(digests, rp)
+	}
+	if err := s.InsertDigestItem(ctx, dbstore.DigestItem{RollingPostID: digests[0].ID, PostID: "p1"}); err != nil {
+		t.Fatalf("InsertDigestItem: %v", err)
+	}
+	if err := s.ReopenRollingPost(ctx, digests[3].ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ReopenRollingPost: %v", err)
+	}
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	// One row per batch, oldest first.
+	if n, err := s.PruneRows(ctx, dbstore.PruneDigests, dayAgo, 1); err != nil || n != 1 {
+		t.Errorf("first digest batch = %d, %v; want 1", n, err)
+	}
+	if rp, err := s.GetRollingPostByMessageID(ctx, "m0"); err == nil && rp != nil {
+		t.Errorf("oldest digest survived its batch: %+v", rp)
+	}
+	if items, err := s.GetDigestItems(ctx, digests[0].ID); err != nil || len(items) != 0 {
+		t.Errorf("pruned digest's items = %+v, %v", items, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneDigests, dayAgo, 1); err != nil || n != 1 {
+		t.Errorf("second digest batch = %d, %v; want 1", n, err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PruneDigests, dayAgo, 1); err != nil || n != 0 {
+		t.Errorf("third digest batch = %d, %v; want 0", n, err)
+	}
+	if rp, err := s.GetRollingPostByMessageID(ctx, "m2"); err != nil || rp == nil || rp.ID != digests[2].ID {
+		t.Errorf("open digest was pruned: %+v, %v", rp, err)
+	}
+	if rp, err := s.GetRollingPostByMessageID(ctx, "m3"); err != nil || rp == nil || rp.ID != digests[3].ID {
+		t.Errorf("reopened digest was pruned: %+v, %v", rp, err)
+	}
+
+	// Caches age by fetched_at.
+	if err := s.UpsertLastfmListeners(ctx, "architects", 10); err != nil {
+		t.Fatalf("UpsertLastfmListeners: %v", err)
+	}
+	if err := s.UpsertPipedVideo(ctx, "song|a b", "https://music.youtube.com/watch?v=x"); err != nil {
+		t.Fatalf("UpsertPipedVideo: %v", err)
+	}
+	if err := s.UpsertQobuzAlbum(ctx, "a b", ""); err != nil {
+		t.Fatalf("UpsertQobuzAlbum: %v", err)
+	}
+	if err := s.UpsertCachedArticle(ctx, dbstore.CachedArticle{URL: "https://example.com/a"}); err != nil {
+		t.Fatalf("UpsertCachedArticle: %v", err)
+	}
+	for _, table := range []string{dbstore.PruneLastfmCache, dbstore.PrunePipedCache, dbstore.PruneQobuzCache, dbstore.PruneArticleCache} {
+		if n, err := s.PruneRows(ctx, table, past, 10); err != nil || n != 0 {
+			t.Errorf("prune fresh %s = %d, %v", table, n, err)
+		}
+		if n, err := s.PruneRows(ctx, table, future, 10); err != nil || n != 1 {
+			t.Errorf("prune %s = %d, %v; want 1", table, n, err)
+		}
+	}
+	if _, _, ok, err := s.GetLastfmListeners(ctx, "architects"); err != nil || ok {
+		t.Errorf("lastfm row survived: ok %v, %v", ok, err)
+	}
+	if a, err := s.GetCachedArticle(ctx, "https://example.com/a"); err != nil || a != nil {
+		t.Errorf("article survived: %+v, %v", a, err)
+	}
+}
+
+func testGuildConfig(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+
+	if got, err := s.GetGuildConfig(ctx, "nobody"); err != nil || len(got) != 0 {
+		t.Errorf("unknown guild = %+v, %v", got, err)
+	}
+
+	tmpl, tone := "terse", "dry"
+	if err := s.SetChannelPrompt(ctx, f.channel.ID, &tmpl, nil); err != nil {
+		t.Fatalf("SetChannelPrompt: %v", err)
+	}
+	if err := s.SetChannelLanguage(ctx, f.channel.ID, "German"); err != nil {
+		t.Fatalf("SetChannelLanguage: %v", err)
+	}
+	if err := s.SetRulePrompt(ctx, f.rule.ID, nil, &tone); err != nil {
+		t.Fatalf("SetRulePrompt: %v", err)
+	}
+	extra, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "author", TargetID: "someone", DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+
+	got, err := s.GetGuildConfig(ctx, "SERVER1")
+	if err != nil {
+		t.Fatalf("GetGuildConfig: %v", err)
+	}
+	want := []dbstore.GuildChannel{{
+		ExternalID: "channel1", PromptTemplate: "terse", Language: "German",
+		Rules: []dbstore.GuildRule{
+			{ID: f.rule.ID, Subreddit: "metalcore", TargetID: "tour", Target: "title", Mode: dbstore.ModeNarrative, WindowHours: 72, Tone: "dry"},
+			{ID: extra.ID, Subreddit: "metalcore", TargetID: "someone", Target: "author", Mode: dbstore.ModeNarrative, WindowHours: 72},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetGuildConfig =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Rejected configs change nothing.
+	other, _ := s.InsertDiscordServer(ctx, "Server2")
+	if _, err := s.InsertDiscordChannel(ctx, "OtherChannel", other.ID); err != nil {
+		t.Fatalf("InsertDiscordChannel: %v", err)
+	}
+	for name, channels := range map[string][]dbstore.GuildChannel{
+		"foreign channel": {{ExternalID: "OtherChannel"}},
+		"unknown rule":    {{ExternalID: "Channel1", Rules: []dbstore.GuildRule{{ID: extra.ID + 100}}}},
+		"incomplete rule": {{ExternalID: "Channel1", Rules: []dbstore.GuildRule{{Subreddit: "metalcore"}}}},
+		// A valid first channel must roll back with the bad second one.
+		"partial": {
+			{ExternalID: "Channel1", Language: "French"},
+			{ExternalID: "Channel9", Rules: []dbstore.GuildRule{{ID: f.rule.ID}}},
+		},
+	} {
+		if err := s.ApplyGuildConfig(ctx, "Server1", channels); err == nil {
+			t.Errorf("%s: ApplyGuildConfig succeeded", name)
+		}
+	}
+	if after, err := s.GetGuildConfig(ctx, "server1"); err != nil || !reflect.DeepEqual(after, want) {
+		t.Fatalf("rejected configs changed the guild: %+v, %v", after, err)
+	}
+
+	// Update the first rule, drop the second, add one in a new channel.
+	if err := s.ApplyGuildConfig(ctx, "Server1", []dbstore.GuildChannel{
+		{ExternalID: "Channel1", Tone: "hype", Rules: []dbstore.GuildRule{
+			{ID: f.rule.ID, Exact: true, Mode: dbstore.ModeMusic, WindowHours: 24, Threshold: 0.5, QuietHours: "22:00-07:00", PromptTemplate: "terse"},
+		}},
+		{ExternalID: "Channel2", Rules: []dbstore.GuildRule{
+			{Subreddit: "PopPunkers", Target: "Title", TargetID: "Reunion", QuietHours: "01:00-02:00"},
+		}},
+	}); err != nil {
+		t.Fatalf("ApplyGuildConfig: %v", err)
+	}
+	got, err = s.GetGuildConfig(ctx, "server1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("GetGuildConfig after apply = %+v, %v", got, err)
+	}
+	if ch := got[0]; ch.ExternalID != "channel1" || ch.PromptTemplate != "" || ch.Tone != "hype" || ch.Language != "" ||
+		!reflect.DeepEqual(ch.Rules, []dbstore.GuildRule{{
+			ID: f.rule.ID, Subreddit: "metalcore", TargetID: "tour", Target: "title", Exact: true,
+			Mode: dbstore.ModeMusic, WindowHours: 24, Threshold: 0.5, QuietHours: "22:00-07:00", PromptTemplate: "terse",
+		}}) {
+		t.Errorf("channel1 after apply = %+v", ch)
+	}
+	if ch := got[1]; ch.ExternalID != "channel2" || len(ch.Rules) != 1 || ch.Rules[0].ID == 0 ||
+		ch.Rules[0].Subreddit != "poppunkers" || ch.Rules[0].Target != "title" || ch.Rules[0].TargetID != "reunion" ||
+		ch.Rules[0].Mode != dbstore.ModeNarrative || ch.Rules[0].WindowHours != 72 || ch.Rules[0].QuietHours != "01:00-02:00" {
+		t.Errorf("channel2 after apply = %+v", ch)
+	}
+	if r, err := s.GetRuleByID(ctx, extra.ID); err == nil && r != nil {
+		t.Errorf("rule left out of the config survived: %+v", r)
+	}
+	if sr, err := s.GetSubredditByExternalID(ctx, "poppunkers"); err != nil || sr == nil {
+		t.Errorf("new rule's subreddit = %+v, %v", sr, err)
+	}
+
+	// Applying the exported config again is a no-op.
+	if err := s.ApplyGuildConfig(ctx, "server1", got); err != nil {
+		t.Fatalf("re-apply: %v", err)
+	}
+	if again, err := s.GetGuildConfig(ctx, "server1"); err != nil || !reflect.DeepEqual(again, got) {
+		t.Errorf("re-apply changed the guild: %+v, %v", again, err)
+	}
+}
+
+func testManagedRules(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+	managed := func(id int) bool {
+		t.Helper()
+		r, err := s.GetRuleByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetRuleByID(%d): %v", id, err)
+		}
+		return r.Managed
+	}
+	if managed(f.rule.ID) {
+		t.Fatal("a rule made by hand is managed")
+	}
+
+	// The file adopts the hand-made rule and adds one here and one in a
+	// guild the store hasn't seen.
+	n, err := s.ReconcileManagedRules(ctx, []dbstore.ManagedGuild{
+		{ServerID: "Server1", Channels: []dbstore.GuildChannel{{ExternalID: "Channel1", Rules: []dbstore.GuildRule{
+			{ID: f.rule.ID, Mode: dbstore.ModeMusic},
+			{Subreddit: "metalcore", Target: "author", TargetID: "someone"},
+		}}}},
+		{ServerID: "Server3", Channels: []dbstore.GuildChannel{{ExternalID: "Channel3", Rules: []dbstore.GuildRule{
+			{Subreddit: "poppunkers", Target: "title", TargetID: "reunion"},
+		}}}},
+	})
+	if err != nil || n != 0 {
+		t.Fatalf("ReconcileManagedRules = %d, %v", n, err)
+	}
+	if !managed(f.rule.ID) {
+		t.Error("adopted rule isn't managed")
+	}
+	if r, _ := s.GetRuleByID(ctx, f.rule.ID); r.Mode != dbstore.ModeMusic {
+		t.Errorf("adopted rule mode = %q", r.Mode)
+	}
+	third, err := s.GetGuildConfig(ctx, "server3")
+	if err != nil || len(third) != 1 || len(third[0].Rules) != 1 || !third[0].Rules[0].Managed {
+		t.Fatalf("new guild = %+v, %v", third, err)
+	}
+
+	// Importing a config keeps the flag; a rule made by hand stays unmanaged.
+	current, err := s.GetGuildConfig(ctx, "server1")
+	if err != nil {
+		t.Fatalf("GetGuildConfig: %v", err)
+	}
+	if err := s.ApplyGuildConfig(ctx, "server1", current); err != nil {
+		t.Fatalf("ApplyGuildConfig: %v", err)
+	}
+	if !managed(f.rule.ID) {
+		t.Error("ApplyGuildConfig cleared managed")
+	}
+	hand, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "title", TargetID: "split", DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+
+	// A bad guild rolls the whole reconcile back.
+	if _, err := s.ReconcileManagedRules(ctx, []dbstore.ManagedGuild{
+		{ServerID: "server1", Channels: []dbstore.GuildChannel{{ExternalID: "channel1"}}},
+		{ServerID: "server3", Channels: []dbstore.GuildChannel{{ExternalID: "channel1"}}},
+	}); err == nil {
+		t.Fatal("reconcile with a channel in two guilds succeeded")
+	}
+	if after, _ := s.GetGuildConfig(ctx, "server1"); len(after) != 1 || len(after[0].Rules) != 3 {
+		t.Fatalf("failed reconcile changed the guild: %+v", after)
+	}
+
+	// Dropping rules and a whole guild from the file deletes only what the
+	// file managed.
+	n, err = s.ReconcileManagedRules(ctx, []dbstore.ManagedGuild{
+		{ServerID: "server1", Channels: []dbstore.GuildChannel{{ExternalID: "channel1", Rules: []dbstore.GuildRule{
+			{ID: f.rule.ID, Mode: dbstore.ModeMusic},
+		}}}},
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("ReconcileManagedRules = %d, %v; want 2 deleted", n, err)
+	}
+	if managed(hand.ID) {
+		t.Error("reconcile adopted a rule the file doesn't list")
+	}
+	if third, _ := s.GetGuildConfig(ctx, "server3"); len(third) != 1 || len(third[0].Rules) != 0 {
+		t.Errorf("dropped guild = %+v", third)
+	}
+	if n, err := s.ReconcileManagedRules(ctx, nil); err != nil || n != 1 {
+		t.Errorf("empty reconcile = %d, %v; want 1 deleted", n, err)
+	}
+	if _, err := s.GetRuleByID(ctx, hand.ID); err != nil {
+		t.Errorf("hand-made rule was deleted: %v", err)
+	}
+}
+
+func testFeedback(t *testing.T, s dbstore.Store) {
+	ctx := context.Background()
+	f := newFixture(t, s)
+	other, err := s.InsertRule(ctx, dbstore.Rule{
+		Target: "title", TargetID: "merch", DiscordChannelID: f.channel.ID, SubredditID: f.subreddit.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+	if _, err := s.InsertNotification(ctx, f.post.ID, f.channel.ID, f.rule.ID); err != nil {
+		t.Fatalf("InsertNotification: %v", err)
+	}
+
+	if ids, err := s.GetNotifiedRules(ctx, f.channel.ID, "ABC123"); err != nil || !reflect.DeepEqual(ids, []int{f.rule.ID}) {
+		t.Errorf("GetNotifiedRules = %v, %v; want [%d]", ids, err, f.rule.ID)
+	}
+	if ids, err := s.GetNotifiedRules(ctx, f.channel.ID, "missing"); err != nil || len(ids) != 0 {
+		t.Errorf("GetNotifiedRules(missing) = %v, %v", ids, err)
+	}
+
+	vote := func(rule int, user, v string) error {
+		return s.RecordFeedback(ctx, dbstore.Feedback{PostID: "AbC123", RuleID: rule, UserID: user, Vote: v, Title: "Merch giveaway"})
+	}
+	if err := vote(f.rule.ID, "u1", dbstore.VoteUp); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	// A second vote by the same user replaces the first.
+	if err := vote(f.rule.ID, "u1", dbstore.VoteDown); err != nil {
+		t.Fatalf("RecordFeedback again: %v", err)
+	}
+	if err := vote(f.rule.ID, "u2", dbstore.VoteIrrelevant); err != nil {
+		t.Fatalf("RecordFeedback u2: %v", err)
+	}
+	if err := vote(other.ID, "u1", dbstore.VoteUp); err != nil {
+		t.Fatalf("RecordFeedback other: %v", err)
+	}
+	if err := vote(f.rule.ID, "u3", "meh"); err == nil {
+		t.Error("RecordFeedback accepted an unknown vote")
+	}
+	if err := s.RecordFeedback(ctx, dbstore.Feedback{PostID: "missing", RuleID: f.rule.ID, UserID: "u1", Vote: dbstore.VoteUp}); err == nil {
+		t.Error("RecordFeedback accepted an unknown post")
+	}
+
+	fb, err := s.ListRuleFeedback(ctx, f.rule.ID)
+	if err != nil || len(fb) != 2 {
+		t.Fatalf("ListRuleFeedback = %+v, %v; want 2", fb, err)
+	}
+	if fb[0].PostID != "abc123" || fb[0].UserID != "u1" || fb[0].Vote != dbstore.VoteDown || fb[0].Title != "Merch giveaway" ||
+		time.Since(fb[0].CreatedAt) > time.Minute || fb[1].UserID != "u2" {
+		t.Errorf("ListRuleFeedback = %+v", fb)
+	}
+
+	stats, err := s.GetRuleStats(ctx, f.channel.ID)
+	want := []dbstore.RuleStats{
+		{RuleID: f.rule.ID, Matches: 1, Down: 1, Irrelevant: 1},
+		{RuleID: other.ID, Up: 1},
+	}
+	if err != nil || !reflect.DeepEqual(stats, want) {
+		t.Errorf("GetRuleStats = %+v, %v; want %+v", stats, err, want)
+	}
+
+	// Retention keeps a voted post after its notification ages out, so the
+	// votes survive.
+	future := time.Now().Add(time.Hour)
+	if _, err := s.PruneRows(ctx, dbstore.PruneNotifications, future, 10); err != nil {
+		t.Fatalf("prune notifications: %v", err)
+	}
+	if n, err := s.PruneRows(ctx, dbstore.PrunePosts, future, 10); err != nil || n != 0 {
+		t.Errorf("prune posts = %d, %v; want the voted post kept", n, err)
+	}
+	stats, err = s.GetRuleStats(ctx, f.channel.ID)
+	want[0].Matches = 0
+	if err != nil || !reflect.DeepEqual(stats, want) {
+		t.Errorf("GetRuleStats after prune = %+v, %v; want %+v", stats, err, want)
+	}
+
+	if err := s.UpdateRuleExcludeTerms(ctx, f.rule.ID, []string{" Merch ", "merch", "", "Giveaway"}); err != nil {
+		t.Fatalf("UpdateRuleExcludeTerms: %v", err)
+	}
+	if r, err := s.GetRuleByID(ctx, f.rule.ID); err != nil || !reflect.DeepEqual(r.ExcludeTerms, []string{"merch", "giveaway"}) {
+		t.Errorf("exclude terms = %v, %v", r.ExcludeTerms, err)
+	}
+	rules, err := s.GetRules(ctx, f.subreddit.ID)
+	if err != nil {
+		t.Fatalf("GetRules: %v", err)
+	}
+	for _, r := range rules {
+		if r.ID == f.rule.ID && !reflect.DeepEqual(r.ExcludeTerms, []string{"merch", "giveaway"}) {
+			t.Errorf("GetRules exclude terms = %v", r.ExcludeTerms)
+		}
+	}
+
+	// Votes go with their rule.
+	if err := s.DeleteRule(ctx, f.rule.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if fb, err := s.ListRuleFeedback(ctx, f.rule.ID); err != nil || len(fb) != 0 {
+		t.Errorf("feedback after DeleteRule = %+v, %v", fb, err)
+	}
+	if fb, err := s.ListRuleFeedback(ctx, other.ID); err != nil || len(fb) != 1 {
+		t.Errorf("other rule's feedback = %+v, %v", fb, err)
+	}
+}
+
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Errorf("invalid JSON %s: %v", a, err)
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Errorf("invalid JSON %s: %v", b, err)
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
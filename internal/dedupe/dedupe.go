@@ -0,0 +1,182 @@
+// Package dedupe recognises the same story posted more than once: a
+// crosspost of a post already seen, a link to the same URL, or a title
+// that differs only in a few words.
+package dedupe
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTitleDistance is the largest Hamming distance between two title
+	// SimHashes that still counts as the same title. Titles that differ in
+	// a word or two of punctuation, casing or a "[crosspost]" tag land
+	// well under it; unrelated titles land near 32.
+	MaxTitleDistance = 3
+
+	// minTitleTokens is the shortest title compared by SimHash. A couple of
+	// words ("Weekly thread") says too little to call two posts the same.
+	minTitleTokens = 4
+)
+
+// Fingerprint is what a post is compared on.
+type Fingerprint struct {
+	// PostID is the Reddit post id without its "t3_" prefix.
+	PostID string
+	// CrosspostParent is the fullname ("t3_…") of the post this one was
+	// crossposted from, if any.
+	CrosspostParent string
+	// URL is the normalized link (see NormalizeURL); empty for self posts.
+	URL string
+	// TitleHash is the title's SimHash; 0 when the title is too short to
+	// compare.
+	TitleHash uint64
+}
+
+// New fingerprints a post.
+func New(postID, crosspostParent, link, permalink, title string) Fingerprint {
+	return Fingerprint{
+		PostID:          postID,
+		CrosspostParent: crosspostParent,
+		URL:             linkKey(link, permalink),
+		TitleHash:       TitleHash(title),
+	}
+}
+
+// Reason says why Match found two posts to be the same, for logs.
+type Reason string
+
+const (
+	ReasonCrosspost Reason = "crosspost"
+	ReasonURL       Reason = "url"
+	ReasonTitle     Reason = "title"
+)
+
+// Match reports whether a and b are the same story, and why.
+func Match(a, b Fingerprint) (Reason, bool) {
+	switch {
+	case a.CrosspostParent != "" && (a.CrosspostParent == b.CrosspostParent || a.CrosspostParent == "t3_"+b.PostID):
+		return ReasonCrosspost, true
+	case b.CrosspostParent != "" && b.CrosspostParent == "t3_"+a.PostID:
+		return ReasonCrosspost, true
+	case a.URL != "" && a.URL == b.URL:
+		return ReasonURL, true
+	case a.TitleHash != 0 && b.TitleHash != 0 && Distance(a.TitleHash, b.TitleHash) <= MaxTitleDistance:
+		return ReasonTitle, true
+	}
+	return "", false
+}
+
+// Distance is the number of bits in which a and b differ.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// TitleHash returns the 64-bit SimHash of title's words and word pairs, or
+// 0 when the title has fewer than minTitleTokens words. Similar titles get
+// hashes a few bits apart.
+func TitleHash(title string) uint64 {
+	tokens := titleTokens(title)
+	if len(tokens) < minTitleTokens {
+		return 0
+	}
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	for i, t := range tokens {
+		add(t)
+		if i > 0 {
+			add(tokens[i-1] + " " + t)
+		}
+	}
+	var out uint64
+	for i, w := range weights {
+		if w > 0 {
+			out |= 1 << uint(i)
+		}
+	}
+	return out
+}
+
+// titleTokens lowercases title and splits it into words, dropping
+// punctuation and bracketed tags such as "[Crosspost]" or "(x-post)".
+func titleTokens(title string) []string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '[' || r == '(':
+			depth++
+			b.WriteRune(' ')
+		case r == ']' || r == ')':
+			if depth > 0 {
+				depth--
+			}
+			b.WriteRune(' ')
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// linkKey normalizes a post's link, or returns "" when the post links to
+// itself (a self post, whose URL is its own permalink).
+func linkKey(link, permalink string) string {
+	key := NormalizeURL(link)
+	if key == "" || key == NormalizeURL("https://www.reddit.com"+permalink) {
+		return ""
+	}
+	return key
+}
+
+// NormalizeURL reduces u to a comparison key: scheme, "www." and "m."
+// prefixes, fragments, tracking parameters and trailing slashes are
+// dropped, and reddit hosts share one name. It returns "" for anything
+// that isn't an absolute http(s) URL.
+func NormalizeURL(u string) string {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	host = strings.TrimPrefix(host, "m.")
+	switch host {
+	case "old.reddit.com", "new.reddit.com", "np.reddit.com":
+		host = "reddit.com"
+	case "youtu.be":
+		// youtu.be/<id> and youtube.com/watch?v=<id> are the same video.
+		host, parsed.RawQuery = "youtube.com", "v="+strings.Trim(parsed.Path, "/")
+		parsed.Path = "/watch"
+	}
+	q := parsed.Query()
+	for k := range q {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "utm_") || lk == "ref" || lk == "ref_src" || lk == "fbclid" || lk == "gclid" || lk == "si" {
+			q.Del(k)
+		}
+	}
+	key := host + strings.TrimRight(parsed.EscapedPath(), "/")
+	if enc := q.Encode(); enc != "" {
+		key += "?" + enc
+	}
+	return key
+}
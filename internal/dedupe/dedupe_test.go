@@ -0,0 +1,65 @@
+package dedupe
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	cases := map[string]string{
+		"https://www.example.com/news/story/?utm_source=reddit#top": "example.com/news/story",
+		"http://example.com/news/story":                             "example.com/news/story",
+		"https://m.example.com/a?id=3&fbclid=xyz":                   "example.com/a?id=3",
+		"https://old.reddit.com/r/metal/comments/abc/x/":            "reddit.com/r/metal/comments/abc/x",
+		"https://youtu.be/dQw4w9WgXcQ?si=share":                     "youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ&utm_medium=x":  "youtube.com/watch?v=dQw4w9WgXcQ",
+		"/r/metal/comments/abc/x/":                                  "",
+		"":                                                          "",
+	}
+	for in, want := range cases {
+		if got := NormalizeURL(in); got != want {
+			t.Errorf("NormalizeURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTitleHash(t *testing.T) {
+	a := TitleHash("Architects announce new album 'The Sky, the Earth & All Between' out in March")
+	b := TitleHash("[Crosspost] Architects announce new album “The Sky, The Earth & All Between” out in March!")
+	if d := Distance(a, b); d > MaxTitleDistance {
+		t.Errorf("same title, different dressing: distance %d > %d", d, MaxTitleDistance)
+	}
+	c := TitleHash("Sleep Token share tour dates for Europe and the UK this autumn")
+	if d := Distance(a, c); d <= MaxTitleDistance {
+		t.Errorf("unrelated titles: distance %d <= %d", d, MaxTitleDistance)
+	}
+	if TitleHash("Weekly thread") != 0 {
+		t.Error("short titles should not be hashed")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	orig := New("abc", "", "https://www.reddit.com/r/metalcore/comments/abc/tour/", "/r/metalcore/comments/abc/tour/", "Tour announced")
+	if orig.URL != "" {
+		t.Errorf("self post URL = %q, want empty", orig.URL)
+	}
+	xpost := New("def", "t3_abc", "/r/metalcore/comments/abc/tour/", "/r/poppunkers/comments/def/tour/", "Tour announced")
+	if reason, ok := Match(xpost, orig); !ok || reason != ReasonCrosspost {
+		t.Errorf("crosspost: got %q %v", reason, ok)
+	}
+	if reason, ok := Match(orig, xpost); !ok || reason != ReasonCrosspost {
+		t.Errorf("crosspost reversed: got %q %v", reason, ok)
+	}
+	sibling := New("ghi", "t3_abc", "", "/r/emo/comments/ghi/tour/", "Tour announced")
+	if _, ok := Match(sibling, xpost); !ok {
+		t.Error("two crossposts of one parent should match")
+	}
+
+	link1 := New("l1", "", "https://example.com/story?utm_source=a", "/r/a/comments/l1/x/", "Band does a thing")
+	link2 := New("l2", "", "https://www.example.com/story", "/r/b/comments/l2/y/", "Totally different headline here")
+	if reason, ok := Match(link1, link2); !ok || reason != ReasonURL {
+		t.Errorf("same link: got %q %v", reason, ok)
+	}
+
+	other := New("o1", "", "https://example.com/other", "/r/a/comments/o1/z/", "Tour announced")
+	if _, ok := Match(other, link1); ok {
+		t.Error("different links and titles should not match")
+	}
+}
@@ -0,0 +1,364 @@
+package discord
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+
+	"github.com/meriley/reddit-spy/internal/guildconfig"
+)
+
+const (
+	// importConfigCustomIDPrefix prefixes the /import_config buttons; the
+	// suffix is the pending import's token.
+	importConfigCustomIDPrefix = "import_config:"
+	cancelImportCustomIDPrefix = "cancel_import:"
+
+	// maxConfigBytes caps an uploaded config. Templates are the bulk of one
+	// and are capped at maxTemplateBytes each.
+	maxConfigBytes = 512 << 10
+
+	// importConfirmWindow is how long an /import_config preview can be
+	// confirmed; the interaction token it answers on lasts 15 minutes too.
+	importConfirmWindow = 15 * time.Minute
+
+	// importPreviewLines caps the change lines quoted in the preview.
+	importPreviewLines = 30
+)
+
+// pendingImport is an /import_config preview waiting for its button.
+type pendingImport struct {
+	guildID string
+	userID  string
+	cfg     *guildconfig.Config
+	plan    *guildconfig.Plan
+	expires time.Time
+}
+
+// pendingImports holds previews by token. The zero value is ready to use.
+type pendingImports struct {
+	mu      sync.Mutex
+	pending map[string]pendingImport
+}
+
+func (p *pendingImports) add(imp pendingImport) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = map[string]pendingImport{}
+	}
+	for token, old := range p.pending {
+		if time.Now().After(old.expires) {
+			delete(p.pending, token)
+		}
+	}
+	token := uuid.NewString()
+	p.pending[token] = imp
+	return token
+}
+
+var (
+	errImportExpired  = errors.New("import expired or already handled")
+	errImportNotYours = errors.New("import belongs to another member")
+)
+
+// take removes and returns the import under token. Only the member who
+// previewed it, in the same guild, can take it; anyone else gets
+// errImportNotYours and the import stays pending.
+func (p *pendingImports) take(token, guildID, userID string) (pendingImport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	imp, ok := p.pending[token]
+	if !ok {
+		return pendingImport{}, errImportExpired
+	}
+	if imp.guildID != guildID || imp.userID != userID {
+		return pendingImport{}, errImportNotYours
+	}
+	delete(p.pending, token)
+	if time.Now().After(imp.expires) {
+		return pendingImport{}, errImportExpired
+	}
+	return imp, nil
+}
+
+func (c *Client) exportConfigCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "export_config",
+			Description: "Download this server's channels, rules and prompt settings as YAML",
+		},
+		Handler: c.exportConfigHandler,
+	}
+}
+
+func (c *Client) exportConfigHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to export the configuration.")
+		return
+	}
+	channels, err := c.Bot.Store.GetGuildConfig(c.Ctx, i.GuildID)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "export_config: failed to load config", "error", err)
+		c.respondWithError(s, i, "Failed to load this server's configuration.")
+		return
+	}
+	cfg := guildconfig.Export(i.GuildID, channels)
+	raw, err := guildconfig.Marshal(cfg)
+	if err != nil {
+		c.respondWithError(s, i, fmt.Sprintf("Failed to encode the configuration: %s", err))
+		return
+	}
+	rules := 0
+	for _, ch := range cfg.Channels {
+		rules += len(ch.Rules)
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("%d channels, %d rules. Edit the file and load it back with `/import_config`.",
+				len(cfg.Channels), rules),
+			Files: []*discordgo.File{{
+				Name:        fmt.Sprintf("reddit-spy-%s.yaml", cfg.Guild),
+				ContentType: "application/yaml",
+				Reader:      bytes.NewReader(raw),
+			}},
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "export_config: respond failed", "error", err)
+	}
+}
+
+func (c *Client) importConfigCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "import_config",
+			Description: "Replace this server's channels, rules and prompt settings with a YAML or JSON file",
+			Options: []*discordgo.ApplicationCommandOption{{
+				Name:        "file",
+				Description: "A file from /export_config, edited as needed",
+				Required:    true,
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+			}},
+		},
+		Handler: c.importConfigHandler,
+	}
+}
+
+// importConfigHandler previews what the attached config would change and
+// holds it for the Apply button. Nothing is written until it's pressed.
+func (c *Client) importConfigHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to import a configuration.")
+		return
+	}
+	data := i.ApplicationCommandData()
+	var attachmentID string
+	for _, o := range data.Options {
+		if o.Name == "file" {
+			attachmentID, _ = o.Value.(string)
+		}
+	}
+	att, ok := data.Resolved.Attachments[attachmentID]
+	if !ok {
+		c.respondWithError(s, i, "Couldn't read the attached file.")
+		return
+	}
+
+	// Checking new subreddits against Reddit can outlast the 3-second ack.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "import_config: defer failed", "error", err)
+		return
+	}
+	reply := func(params *discordgo.WebhookParams) {
+		params.Flags = discordgo.MessageFlagsEphemeral
+		if _, err := s.FollowupMessageCreate(i.Interaction, true, params); err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("msg", "import_config: followup failed", "error", err)
+		}
+	}
+
+	raw, err := fetchAttachment(c.Ctx, att.URL, maxConfigBytes)
+	if err != nil {
+		reply(&discordgo.WebhookParams{Content: fmt.Sprintf("Couldn't read the attached file: %s", err)})
+		return
+	}
+	cfg, err := guildconfig.Parse(raw)
+	if err != nil {
+		reply(&discordgo.WebhookParams{Content: fmt.Sprintf("Invalid config: %s", err)})
+		return
+	}
+	if cfg.Guild != strings.ToLower(i.GuildID) {
+		reply(&discordgo.WebhookParams{Content: fmt.Sprintf(
+			"This config is for server `%s`, not this one. Change its `guild` to `%s` to copy it here.",
+			cfg.Guild, i.GuildID)})
+		return
+	}
+	plan, err := guildconfig.Diff(c.Ctx, c.Bot.Store, cfg)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "import_config: diff failed", "error", err)
+		reply(&discordgo.WebhookParams{Content: "Failed to load this server's configuration."})
+		return
+	}
+	if len(plan.Changes) == 0 {
+		reply(&discordgo.WebhookParams{Content: "The config matches this server already; nothing to apply."})
+		return
+	}
+	if len(plan.Conflicts) > 0 {
+		reply(&discordgo.WebhookParams{Content: formatManagedConflicts(plan.Conflicts)})
+		return
+	}
+	if problem := c.vetCreatedRules(plan); problem != "" {
+		reply(&discordgo.WebhookParams{Content: problem})
+		return
+	}
+
+	token := c.imports.add(pendingImport{
+		guildID: i.GuildID,
+		userID:  interactionUserID(i),
+		cfg:     cfg,
+		plan:    plan,
+		expires: time.Now().Add(importConfirmWindow),
+	})
+	reply(&discordgo.WebhookParams{
+		Content: formatImportPreview(plan.Changes),
+		Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Apply", Style: discordgo.DangerButton, CustomID: importConfigCustomIDPrefix + token},
+			discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: cancelImportCustomIDPrefix + token},
+		}}},
+	})
+}
+
+// vetCreatedRules runs the checks /add_subreddit_listener makes on every
+// rule the plan would create, and describes the first one that fails.
+func (c *Client) vetCreatedRules(plan *guildconfig.Plan) string {
+	checked := map[string]bool{}
+	for _, r := range plan.Created {
+		if err := c.validateRuleTarget(r.MatchOn, r.Value); err != nil {
+			return fmt.Sprintf("Invalid %s rule %s: %s", r.MatchOn, r, err)
+		}
+		if checked[r.Subreddit] {
+			continue
+		}
+		checked[r.Subreddit] = true
+		if !c.Bot.ValidateSubredditExists(c.Ctx, r.Subreddit) {
+			return fmt.Sprintf("Subreddit r/%s does not exist or is not accessible.", r.Subreddit)
+		}
+	}
+	return ""
+}
+
+// formatImportPreview lists changes as a diff block that fits one message.
+func formatImportPreview(changes []string) string {
+	shown := changes
+	if len(shown) > importPreviewLines {
+		shown = shown[:importPreviewLines]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Importing this config makes %d changes:\n```diff\n", len(changes))
+	for _, line := range shown {
+		b.WriteString(truncateUTF8(line, 120))
+		b.WriteByte('\n')
+	}
+	if more := len(changes) - len(shown); more > 0 {
+		fmt.Fprintf(&b, "… and %d more\n", more)
+	}
+	b.WriteString("```\nRules left out of the file are deleted along with their history. Apply?")
+	return truncateUTF8(b.String(), 2000)
+}
+
+// formatManagedConflicts explains why a config that changes managed rules
+// can't be imported.
+func formatManagedConflicts(conflicts []string) string {
+	var b strings.Builder
+	b.WriteString("This config changes rules managed by this deployment's rules file, which only the file can change:\n```diff\n")
+	for idx, line := range conflicts {
+		if idx == importPreviewLines {
+			fmt.Fprintf(&b, "… and %d more\n", len(conflicts)-idx)
+			break
+		}
+		b.WriteString(truncateUTF8(line, 120))
+		b.WriteByte('\n')
+	}
+	b.WriteString("```\nKeep those rules as `/export_config` shows them.")
+	return truncateUTF8(b.String(), 2000)
+}
+
+func (c *Client) handleImportConfigButton(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	if !c.hasManageChannels(s, i) {
+		c.respondComponentError(s, i, "You need the **Manage Channels** permission to import a configuration.")
+		return
+	}
+	imp, err := c.imports.take(strings.TrimPrefix(customID, importConfigCustomIDPrefix), i.GuildID, interactionUserID(i))
+	if errors.Is(err, errImportNotYours) {
+		c.respondComponentError(s, i, "Only the member who ran `/import_config` can apply it.")
+		return
+	}
+	if err != nil {
+		c.respondComponentError(s, i, "This import has expired or was already handled. Run `/import_config` again.")
+		return
+	}
+
+	// Someone may have edited rules since the preview; apply only what
+	// was shown.
+	plan, err := guildconfig.Diff(c.Ctx, c.Bot.Store, imp.cfg)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "import_config: diff failed", "error", err)
+		c.respondComponentError(s, i, "Failed to load this server's configuration.")
+		return
+	}
+	if !plan.Equal(imp.plan) {
+		c.respondComponentError(s, i, "This server's configuration changed since the preview. Run `/import_config` again.")
+		return
+	}
+	if err := plan.Apply(c.Ctx, c.Bot.Store); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "import_config: apply failed", "guild", i.GuildID, "error", err)
+		c.respondComponentError(s, i, fmt.Sprintf("Import failed and nothing was changed: %s", err))
+		return
+	}
+	c.syncPollers()
+
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("Imported: %d changes applied.", len(plan.Changes)),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+func (c *Client) handleCancelImportButton(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	if _, err := c.imports.take(strings.TrimPrefix(customID, cancelImportCustomIDPrefix), i.GuildID, interactionUserID(i)); errors.Is(err, errImportNotYours) {
+		c.respondComponentError(s, i, "Only the member who ran `/import_config` can cancel it.")
+		return
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    "Import cancelled; nothing was changed.",
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+// interactionUserID is the id of the member or user behind i.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
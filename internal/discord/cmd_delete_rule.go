@@ -55,12 +55,17 @@ func (c *Client) deleteRuleHandler(s *discordgo.Session, i *discordgo.Interactio
 		c.respondWithError(s, i, "You can only delete rules from this server.")
 		return
 	}
+	if rule.Managed {
+		c.respondWithError(s, i, managedRuleMessage(ruleID))
+		return
+	}
 
 	if err := c.Bot.Store.DeleteRule(c.Ctx, ruleID); err != nil {
 		_ = level.Error(c.Ctx.Log()).Log("error", "failed to delete rule", "ruleID", ruleID, "err", err)
 		c.respondWithError(s, i, "Failed to delete rule.")
 		return
 	}
+	c.syncPollers()
 
 	matchType := "partial"
 	if rule.Exact {
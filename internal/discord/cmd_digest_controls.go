@@ -0,0 +1,308 @@
+package discord
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+)
+
+// defaultReopenHours is how long /digest_reopen keeps a digest open
+// without an hours option.
+const defaultReopenHours = 24
+
+func (c *Client) digestCloseCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "digest_close",
+			Description: "Close a digest now so the next match starts a fresh one",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "id",
+					Description: "Digest ID from /digest_history",
+					Required:    true,
+				},
+			},
+		},
+		Handler: c.digestCloseHandler,
+	}
+}
+
+func (c *Client) digestReopenCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "digest_reopen",
+			Description: "Reopen a past digest so new matches join it",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "id",
+					Description: "Digest ID from /digest_history",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "hours",
+					Description: fmt.Sprintf("How long to keep it open (default %d)", defaultReopenHours),
+					Required:    false,
+					MinValue:    ptrFloat(1),
+					MaxValue:    720,
+				},
+			},
+		},
+		Handler: c.digestReopenHandler,
+	}
+}
+
+func (c *Client) digestMergeCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "digest_merge",
+			Description: "Merge one music digest into another and delete its messages",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "into",
+					Description: "Digest ID to keep",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "from",
+					Description: "Digest ID to fold into it and delete",
+					Required:    true,
+				},
+			},
+		},
+		Handler: c.digestMergeHandler,
+	}
+}
+
+// channelDigest loads digest id and the interaction's channel, responding
+// with an error and returning a nil digest when it can't be fetched or
+// belongs to another channel.
+func (c *Client) channelDigest(s *discordgo.Session, i *discordgo.InteractionCreate, id int) (*dbstore.DiscordChannel, *dbstore.RollingPost) {
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, i.ChannelID)
+	if err != nil {
+		c.respondWithError(s, i, "This channel has no rules.")
+		return nil, nil
+	}
+	rp, err := c.Bot.Store.GetRollingPost(c.Ctx, id)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to get digest", "id", id, "err", err)
+		c.respondWithError(s, i, "Failed to fetch the digest.")
+		return nil, nil
+	}
+	if rp == nil || rp.ChannelID != ch.ID {
+		c.respondWithError(s, i, fmt.Sprintf("Digest #%d not found in this channel.", id))
+		return nil, nil
+	}
+	return ch, rp
+}
+
+func (c *Client) respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: msg,
+		},
+	})
+}
+
+func (c *Client) digestCloseHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to close digests.")
+		return
+	}
+	_, rp := c.channelDigest(s, i, int(i.ApplicationCommandData().Options[0].IntValue()))
+	if rp == nil {
+		return
+	}
+	if !rp.WindowEnd.IsZero() && !rp.WindowEnd.After(c.now()) {
+		c.respondWithError(s, i, fmt.Sprintf("Digest #%d is already closed.", rp.ID))
+		return
+	}
+	if err := c.Bot.Store.CloseRollingPost(c.Ctx, rp.ID); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to close digest", "id", rp.ID, "err", err)
+		c.respondWithError(s, i, "Failed to close the digest.")
+		return
+	}
+	c.respondEphemeral(s, i, fmt.Sprintf("Closed digest #%d. The next `%s` match in this channel starts a new digest.", rp.ID, rp.Mode))
+}
+
+func (c *Client) digestReopenHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to reopen digests.")
+		return
+	}
+	id, hours := 0, defaultReopenHours
+	for _, o := range i.ApplicationCommandData().Options {
+		switch o.Name {
+		case "id":
+			id = int(o.IntValue())
+		case "hours":
+			hours = int(o.IntValue())
+		}
+	}
+	_, rp := c.channelDigest(s, i, id)
+	if rp == nil {
+		return
+	}
+	until := c.now().Add(time.Duration(hours) * time.Hour)
+	if err := c.Bot.Store.ReopenRollingPost(c.Ctx, rp.ID, until); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to reopen digest", "id", rp.ID, "err", err)
+		c.respondWithError(s, i, "Failed to reopen the digest.")
+		return
+	}
+	c.respondEphemeral(s, i, fmt.Sprintf("Reopened digest #%d until <t:%d:f>. New `%s` matches in this channel join it, even if a newer digest is open.",
+		rp.ID, until.Unix(), rp.Mode))
+}
+
+func (c *Client) digestMergeHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to merge digests.")
+		return
+	}
+	intoID, fromID := 0, 0
+	for _, o := range i.ApplicationCommandData().Options {
+		switch o.Name {
+		case "into":
+			intoID = int(o.IntValue())
+		case "from":
+			fromID = int(o.IntValue())
+		}
+	}
+	if intoID == fromID {
+		c.respondWithError(s, i, "Pick two different digests.")
+		return
+	}
+	ch, into := c.channelDigest(s, i, intoID)
+	if into == nil {
+		return
+	}
+	_, from := c.channelDigest(s, i, fromID)
+	if from == nil {
+		return
+	}
+	if into.Mode != dbstore.ModeMusic || from.Mode != dbstore.ModeMusic {
+		c.respondWithError(s, i, "Only music digests can be merged.")
+		return
+	}
+
+	// Re-rendering the card and thread, then deleting the other digest's
+	// messages, can exceed the 3-second ack budget.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_merge: defer failed", "error", err)
+		return
+	}
+
+	msg := ""
+	merged, err := c.mergeMusicDigests(c.Ctx, ch, into, from)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_merge failed", "into", intoID, "from", fromID, "error", err)
+		msg = fmt.Sprintf(":warning: Merging digest #%d into #%d failed: %s", fromID, intoID, err)
+	} else {
+		n := 0
+		if entries, err := decodeMusicEntries(merged.Entries); err == nil {
+			n = len(entries)
+		}
+		msg = fmt.Sprintf("Merged digest #%d into #%d: %d releases from %d posts.", fromID, intoID, n, len(merged.IncludedPostIDs))
+	}
+	if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: msg,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_merge: followup failed", "error", err)
+	}
+}
+
+// mergeMusicDigests folds from into into: entries are combined in order,
+// into's first, dropping any whose MusicDedupeKey into already has; the
+// card and thread are re-rendered; the store merge deletes from; and
+// from's card and thread are deleted last. into keeps its window.
+func (c *Client) mergeMusicDigests(ctx ctxpkg.Ctx, ch *dbstore.DiscordChannel, into, from *dbstore.RollingPost) (*dbstore.RollingPost, error) {
+	intoEntries, err := decodeMusicEntries(into.Entries)
+	if err != nil {
+		return nil, err
+	}
+	fromEntries, err := decodeMusicEntries(from.Entries)
+	if err != nil {
+		return nil, err
+	}
+	entries := mergeMusicEntries(intoEntries, fromEntries)
+	encoded, err := encodeMusicEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := *into
+	rp.Entries = encoded
+	for _, id := range from.SubredditIDs {
+		rp.SubredditIDs = appendUniqueInt(rp.SubredditIDs, id)
+	}
+	for _, id := range from.IncludedPostIDs {
+		rp.IncludedPostIDs = appendUnique(rp.IncludedPostIDs, id)
+	}
+	for _, id := range from.IncludedRuleIDs {
+		rp.IncludedRuleIDs = appendUniqueInt(rp.IncludedRuleIDs, id)
+	}
+	if from.UpdatedAt.After(into.UpdatedAt) {
+		rp.LatestScore, rp.LatestComments = from.LatestScore, from.LatestComments
+		rp.LatestURL, rp.LatestThumbnail = from.LatestURL, from.LatestThumbnail
+	}
+
+	if err := c.rerenderMusic(ctx, ch, &rp); err != nil {
+		return nil, err
+	}
+	merged, err := c.Bot.Store.MergeRollingPosts(ctx, rp, from.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge digests: %w", err)
+	}
+
+	// The thread goes with its replies; the card is a channel message.
+	if from.ThreadID != "" {
+		if _, err := c.sender.ChannelDelete(from.ThreadID); err != nil && !isMessageGone(err) {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to delete merged digest thread (non-fatal)",
+				"thread_id", from.ThreadID, "error", err)
+		}
+	}
+	for _, id := range from.DiscordMessageIDs {
+		if id == "" {
+			continue
+		}
+		if err := c.sender.ChannelMessageDelete(ch.ExternalID, id); err != nil && !isMessageGone(err) {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to delete merged digest card (non-fatal)",
+				"message_id", id, "error", err)
+		}
+	}
+	return merged, nil
+}
+
+// mergeMusicEntries appends extra's entries to base, skipping any whose
+// MusicDedupeKey is already present.
+func mergeMusicEntries(base, extra []llm.MusicEntry) []llm.MusicEntry {
+	out := append([]llm.MusicEntry(nil), base...)
+	seen := make(map[string]struct{}, len(out))
+	for _, e := range out {
+		seen[llm.MusicDedupeKey(e)] = struct{}{}
+	}
+	for _, e := range extra {
+		k := llm.MusicDedupeKey(e)
+		if _, dup := seen[k]; dup {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,306 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// defaultHistoryDays is how far back /digest_history looks without a
+	// days option; maxHistoryDays bounds the option.
+	defaultHistoryDays = 7
+	maxHistoryDays     = 365
+
+	// digestHistoryLimit keeps one reply's description under Discord's
+	// embed budget.
+	digestHistoryLimit = 25
+)
+
+func (c *Client) digestHistoryCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "digest_history",
+			Description: "List this channel's past digests with links to their messages",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "Only digests of this mode",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "narrative", Value: dbstore.ModeNarrative},
+						{Name: "music", Value: dbstore.ModeMusic},
+						{Name: "summary", Value: dbstore.ModeSummary},
+						{Name: "media", Value: dbstore.ModeMedia},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: fmt.Sprintf("How many days back to look (default %d)", defaultHistoryDays),
+					Required:    false,
+					MinValue:    ptrFloat(1),
+					MaxValue:    maxHistoryDays,
+				},
+			},
+		},
+		Handler: c.digestHistoryHandler,
+	}
+}
+
+func (c *Client) digestRerenderCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "digest_rerender",
+			Description: "Rebuild a digest's messages from its stored content",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "id",
+					Description: "Digest ID from /digest_history",
+					Required:    true,
+				},
+			},
+		},
+		Handler: c.digestRerenderHandler,
+	}
+}
+
+func (c *Client) digestHistoryHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	mode := ""
+	days := defaultHistoryDays
+	for _, o := range i.ApplicationCommandData().Options {
+		switch o.Name {
+		case "mode":
+			mode = o.StringValue()
+		case "days":
+			days = int(o.IntValue())
+		}
+	}
+
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, i.ChannelID)
+	if err != nil {
+		c.respondWithError(s, i, "This channel has no rules.")
+		return
+	}
+	since := c.now().Add(-time.Duration(days) * 24 * time.Hour)
+	digests, err := c.Bot.Store.ListRollingPosts(c.Ctx, ch.ID, mode, since, digestHistoryLimit)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to list digests", "err", err)
+		c.respondWithError(s, i, "Failed to fetch digests for this channel.")
+		return
+	}
+
+	if len(digests) == 0 {
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: fmt.Sprintf("No digests in this channel in the last %d days.", days),
+			},
+		})
+		return
+	}
+
+	var lines []string
+	for _, rp := range digests {
+		lines = append(lines, formatHistoryLine(i.GuildID, i.ChannelID, rp))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Digests, last %d days (%d)", days, len(digests)),
+		Description: truncateUTF8(strings.Join(lines, "\n"), maxDescRunes),
+		Color:       embedColorReddit,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Use /digest_rerender id:<ID> to rebuild a digest's messages",
+		},
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:  discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to send digest_history response", "err", err)
+	}
+}
+
+// formatHistoryLine is one /digest_history row: id, mode, opening day, what
+// the digest holds and a link to its message.
+func formatHistoryLine(guildID, channelID string, rp *dbstore.RollingPost) string {
+	what := truncateUTF8(rp.NarrativeTitle, 80)
+	if rp.Mode == dbstore.ModeMusic {
+		n := 0
+		if entries, err := decodeMusicEntries(rp.Entries); err == nil {
+			n = len(entries)
+		}
+		what = fmt.Sprintf("%d releases", n)
+	}
+	if what == "" {
+		what = "(untitled)"
+	}
+	link := "no message"
+	if len(rp.DiscordMessageIDs) > 0 && rp.DiscordMessageIDs[0] != "" {
+		link = fmt.Sprintf("[message](%s)", messageURL(guildID, channelID, rp.DiscordMessageIDs[0]))
+	}
+	return fmt.Sprintf("**#%d** — %s · `%s` · %d matches · %s · %s",
+		rp.ID, rp.DayLocal.Format("2006-01-02"), rp.Mode, len(rp.IncludedPostIDs), what, link)
+}
+
+func messageURL(guildID, channelID, messageID string) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildID, channelID, messageID)
+}
+
+func (c *Client) digestRerenderHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to re-render digests.")
+		return
+	}
+	var id int
+	for _, o := range i.ApplicationCommandData().Options {
+		if o.Name == "id" {
+			id = int(o.IntValue())
+		}
+	}
+
+	ch, rp := c.channelDigest(s, i, id)
+	if rp == nil {
+		return
+	}
+
+	// A music digest is a card plus a thread of replies; editing them all
+	// can exceed the 3-second ack budget.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_rerender: defer failed", "error", err)
+		return
+	}
+
+	msg := ""
+	saved, err := c.rerenderDigest(c.Ctx, ch, rp)
+	switch {
+	case err != nil:
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_rerender failed", "id", id, "error", err)
+		msg = fmt.Sprintf(":warning: Re-rendering digest #%d failed: %s", id, err)
+	case len(saved.DiscordMessageIDs) > 0 && saved.DiscordMessageIDs[0] != firstID(rp.DiscordMessageIDs):
+		msg = fmt.Sprintf("Digest #%d's message was gone, so it was posted again: %s",
+			id, messageURL(i.GuildID, i.ChannelID, saved.DiscordMessageIDs[0]))
+	default:
+		msg = fmt.Sprintf("Re-rendered digest #%d.", id)
+	}
+	if _, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: msg,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "digest_rerender: followup failed", "error", err)
+	}
+}
+
+func firstID(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+// rerenderDigest rebuilds rp's messages from its stored narrative or
+// entries with the current renderers, editing them in place or reposting
+// the ones that were deleted, and saves the resulting message ids. Nothing
+// is sent to the LLM or the enrichment services.
+func (c *Client) rerenderDigest(ctx ctxpkg.Ctx, ch *dbstore.DiscordChannel, rp *dbstore.RollingPost) (*dbstore.RollingPost, error) {
+	out := *rp
+	if rp.Mode == dbstore.ModeMusic {
+		if err := c.rerenderMusic(ctx, ch, &out); err != nil {
+			return nil, err
+		}
+	} else {
+		embed := c.rerenderNarrativeEmbed(ctx, rp)
+		ids, err := c.publishDigestEmbed(ctx, ch.ExternalID, firstID(rp.DiscordMessageIDs), embed)
+		if err != nil {
+			return nil, err
+		}
+		out.DiscordMessageIDs = ids
+	}
+
+	// Re-read so a match that landed on an open digest meanwhile isn't
+	// rolled back; only the message ids are this function's to write.
+	current, err := c.Bot.Store.GetRollingPost(ctx, rp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload digest: %w", err)
+	}
+	if current == nil {
+		return nil, fmt.Errorf("digest #%d was deleted", rp.ID)
+	}
+	current.DiscordMessageIDs = out.DiscordMessageIDs
+	current.ThreadID = out.ThreadID
+	current.ThreadMessageIDs = out.ThreadMessageIDs
+	saved, err := c.Bot.Store.UpsertRollingPost(ctx, *current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save digest messages: %w", err)
+	}
+	return saved, nil
+}
+
+// rerenderNarrativeEmbed is buildDigestEmbed for a stored digest: the
+// sources field comes from its digest_items and the timestamp from its
+// latest item. The original-title field isn't stored, so it is dropped.
+func (c *Client) rerenderNarrativeEmbed(ctx ctxpkg.Ctx, rp *dbstore.RollingPost) *discordgo.MessageEmbed {
+	subreddit := ""
+	if names := c.resolveSubredditNames(ctx, []int{rp.SubredditID}); len(names) > 0 {
+		subreddit = names[0]
+	}
+	items := c.digestItems(ctx, rp)
+	result := &evaluator.MatchingEvaluationResult{Post: &redditJSON.RedditPost{}}
+	if len(items) > 0 {
+		result.Post.CreatedUTC = float64(items[len(items)-1].SeenAt.Unix())
+	}
+	embed := buildDigestEmbed(*rp, result, subreddit)
+	addSourcesField(embed, groupDigestItems(items))
+	return embed
+}
+
+// rerenderMusic re-renders a music digest's card and thread into rp. When
+// the card has to be reposted, the old thread hangs off the deleted
+// message, so a new thread is opened for the new one.
+func (c *Client) rerenderMusic(ctx ctxpkg.Ctx, ch *dbstore.DiscordChannel, rp *dbstore.RollingPost) error {
+	entries, err := decodeMusicEntries(rp.Entries)
+	if err != nil {
+		return err
+	}
+	subNames := c.resolveSubredditNames(ctx, rp.SubredditIDs)
+	card := renderMusicCard(*rp, entries, subNames)
+	threadEmbeds := renderMusicThreadEmbeds(*rp, entries)
+
+	parentIDs, err := c.syncParentCard(ctx, ch.ExternalID, rp.DiscordMessageIDs, card)
+	if err != nil {
+		return fmt.Errorf("sync parent card: %w", err)
+	}
+	threadID, replyIDs := rp.ThreadID, rp.ThreadMessageIDs
+	if firstID(parentIDs) != firstID(rp.DiscordMessageIDs) {
+		threadID, replyIDs = "", nil
+	}
+	rp.DiscordMessageIDs = parentIDs
+
+	threadID, err = c.ensureThread(ctx, ch.ExternalID, firstID(parentIDs), threadID, subNames)
+	if err != nil {
+		return fmt.Errorf("ensure digest thread: %w", err)
+	}
+	rp.ThreadID = threadID
+	replyIDs, err = c.syncThreadReplies(ctx, threadID, replyIDs, threadEmbeds)
+	if err != nil {
+		return fmt.Errorf("sync thread replies: %w", err)
+	}
+	rp.ThreadMessageIDs = replyIDs
+	return nil
+}
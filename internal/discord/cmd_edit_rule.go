@@ -2,11 +2,15 @@ package discord
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-kit/log/level"
 
 	database "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/quiethours"
 )
 
 func (c *Client) editRuleCommandConfig() CommandConfig {
@@ -53,6 +57,26 @@ func (c *Client) editRuleCommandConfig() CommandConfig {
 					MinValue:    ptrFloat(1),
 					MaxValue:    720,
 				},
+				{
+					Name:        "threshold",
+					Description: "Semantic rules: new minimum similarity, 0–1 (0 = default)",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					MinValue:    ptrFloat(0),
+					MaxValue:    1,
+				},
+				{
+					Name:        "exclude",
+					Description: "Comma-separated title terms that stop a match; - clears (leave empty to keep current)",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+				{
+					Name:        "quiet_hours",
+					Description: "Hold matches during HH:MM-HH:MM (Phoenix time) and deliver them after; - clears",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
 			},
 		},
 		Handler: c.editRuleHandler,
@@ -89,6 +113,10 @@ func (c *Client) editRuleHandler(s *discordgo.Session, i *discordgo.InteractionC
 		c.respondWithError(s, i, "You can only edit rules from this server.")
 		return
 	}
+	if rule.Managed {
+		c.respondWithError(s, i, managedRuleMessage(ruleID))
+		return
+	}
 
 	newTarget := rule.Target
 	newExact := rule.Exact
@@ -100,6 +128,9 @@ func (c *Client) editRuleHandler(s *discordgo.Session, i *discordgo.InteractionC
 	if newWindow <= 0 {
 		newWindow = 72
 	}
+	newThreshold := rule.Threshold
+	newExclude := rule.ExcludeTerms
+	newQuiet := rule.QuietHours
 
 	for _, opt := range data.Options[1:] {
 		switch opt.Name {
@@ -123,15 +154,42 @@ func (c *Client) editRuleHandler(s *discordgo.Session, i *discordgo.InteractionC
 			if v, ok := opt.Value.(float64); ok && v > 0 {
 				newWindow = int(v)
 			}
+		case "threshold":
+			if v, ok := opt.Value.(float64); ok {
+				newThreshold = v
+			}
+		case "exclude":
+			if v, ok := opt.Value.(string); ok && v != "" {
+				newExclude = parseExcludeTerms(v)
+			}
+		case "quiet_hours":
+			if v, ok := opt.Value.(string); ok && v != "" {
+				q, err := parseQuietHours(v)
+				if err != nil {
+					c.respondWithError(s, i, fmt.Sprintf("Invalid quiet_hours %q: use HH:MM-HH:MM, e.g. 22:00-07:00.", v))
+					return
+				}
+				newQuiet = q
+			}
+		}
+	}
+
+	if newTarget != rule.Target {
+		if err := c.validateRuleTarget(rule.TargetID, newTarget); err != nil {
+			c.respondWithError(s, i, fmt.Sprintf("Invalid %s rule: %s", rule.TargetID, err))
+			return
 		}
 	}
 
 	unchanged := newTarget == rule.Target &&
 		newExact == rule.Exact &&
 		newMode == rule.Mode &&
-		newWindow == rule.WindowHours
+		newWindow == rule.WindowHours &&
+		newThreshold == rule.Threshold &&
+		slices.Equal(newExclude, rule.ExcludeTerms) &&
+		newQuiet == rule.QuietHours
 	if unchanged {
-		c.respondWithError(s, i, "No changes specified. Provide a new value, exact flag, digest mode, or combine_hits_hours.")
+		c.respondWithError(s, i, "No changes specified. Provide a new value, exact flag, digest mode, combine_hits_hours, threshold, exclude, or quiet_hours.")
 		return
 	}
 
@@ -156,18 +214,77 @@ func (c *Client) editRuleHandler(s *discordgo.Session, i *discordgo.InteractionC
 			return
 		}
 	}
+	if newThreshold != rule.Threshold {
+		if err := c.Bot.Store.UpdateRuleThreshold(c.Ctx, ruleID, newThreshold); err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("error", "failed to update rule threshold", "ruleID", ruleID, "err", err)
+			c.respondWithError(s, i, "Failed to update rule threshold.")
+			return
+		}
+	}
+	if !slices.Equal(newExclude, rule.ExcludeTerms) {
+		if err := c.Bot.Store.UpdateRuleExcludeTerms(c.Ctx, ruleID, newExclude); err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("error", "failed to update rule exclude terms", "ruleID", ruleID, "err", err)
+			c.respondWithError(s, i, "Failed to update rule exclusions.")
+			return
+		}
+	}
+	if newQuiet != rule.QuietHours {
+		if err := c.Bot.Store.UpdateRuleQuietHours(c.Ctx, ruleID, newQuiet); err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("error", "failed to update rule quiet hours", "ruleID", ruleID, "err", err)
+			c.respondWithError(s, i, "Failed to update rule quiet hours.")
+			return
+		}
+	}
 
 	matchType := "partial"
 	if newExact {
 		matchType = "exact"
 	}
+	if rule.TargetID == evaluator.TargetSemantic {
+		matchType = fmt.Sprintf("≥%.2f similarity", evaluator.SemanticThreshold(newThreshold))
+	}
+
+	content := fmt.Sprintf("Updated rule #%d: r/%s — %s %s match on `%s` · mode=%s · window=%dh",
+		ruleID, rule.Subreddit, rule.TargetID, matchType, newTarget, newMode, newWindow)
+	if len(newExclude) > 0 {
+		content += " · excludes " + codeList(newExclude)
+	}
+	if newQuiet != "" {
+		content += fmt.Sprintf(" · quiet `%s`", newQuiet)
+	}
 
 	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Flags: discordgo.MessageFlagsEphemeral,
-			Content: fmt.Sprintf("Updated rule #%d: r/%s — %s %s match on `%s` · mode=%s · window=%dh",
-				ruleID, rule.Subreddit, rule.TargetID, matchType, newTarget, newMode, newWindow),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: content,
 		},
 	})
 }
+
+// parseExcludeTerms splits /edit_rule's comma-separated exclude option the
+// way the store normalizes terms: trimmed, lowercase, no repeats. "-"
+// clears them.
+func parseExcludeTerms(v string) []string {
+	var terms []string
+	for _, t := range strings.Split(v, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && t != "-" && !slices.Contains(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// parseQuietHours reads /edit_rule's quiet_hours option into the form rules
+// store. "-" clears them.
+func parseQuietHours(v string) (string, error) {
+	if v = strings.TrimSpace(v); v == "-" {
+		return "", nil
+	}
+	w, err := quiethours.Parse(v)
+	if err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
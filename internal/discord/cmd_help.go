@@ -31,12 +31,64 @@ func (c *Client) helpHandler(s *discordgo.Session, i *discordgo.InteractionCreat
 			},
 			{
 				Name:  "/edit_rule",
-				Value: "Edit a rule's match value or exact/partial mode. Requires **Manage Channels**.",
+				Value: "Edit a rule's match value, exact/partial mode, digest settings, `exclude` terms (comma-separated; `-` clears) or `quiet_hours` (e.g. `22:00-07:00`; `-` clears). Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/pause_rule",
+				Value: "Stop a rule matching without deleting it, until `/resume_rule`. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/resume_rule",
+				Value: "Turn a paused or snoozed rule back on. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/snooze_rule",
+				Value: "Pause a rule for a `duration` (e.g. `8h` or `3d`, up to 30 days); it resumes on its own. Requires **Manage Channels**.",
 			},
 			{
 				Name:  "/delete_rule",
 				Value: "Delete a rule by its ID (use /list_rules to find IDs). Requires **Manage Channels**.",
 			},
+			{
+				Name:  "/rule_stats",
+				Value: "Show each rule's matches and the 👍 / 👎 / not relevant votes from the buttons and reactions under digests, with exclusion terms suggested from disliked posts and a button to add them.",
+			},
+			{
+				Name:  "/retry_failed",
+				Value: "List music extractions that gave up after repeated LLM failures and queue them again. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/digest_history",
+				Value: "List this channel's digests from the last `days` (default 7), optionally only one `mode`, with links to their messages.",
+			},
+			{
+				Name:  "/digest_rerender",
+				Value: "Rebuild a digest's messages (`id` from `/digest_history`) from its stored content after a renderer change, reposting any that were deleted. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/digest_close",
+				Value: "End a digest's window now so the next match starts a new one. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/digest_reopen",
+				Value: "Reopen a past digest for `hours` (default 24); new matches join it ahead of newer digests. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/digest_merge",
+				Value: "Fold music digest `from` into `into`, deduping releases, and delete `from`'s card and thread. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/set_prompt",
+				Value: "Save an LLM prompt template (`name`, `kind`, `body` or `file`) and assign it and a tone preset to a rule (`rule_id`) or this channel (`channel`). Run it with no options to list templates. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/export_config",
+				Value: "Download this server's channels, rules and prompt settings as a YAML file. Requires **Manage Channels**.",
+			},
+			{
+				Name:  "/import_config",
+				Value: "Load an edited export back: shows what would change and applies it all at once when you confirm. Rules left out of the file are deleted. Requires **Manage Channels**.",
+			},
 			{
 				Name:  "/ping",
 				Value: "Check bot latency.",
@@ -3,9 +3,12 @@ package discord
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-kit/log/level"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 )
 
 func (c *Client) listRulesCommandConfig() CommandConfig {
@@ -51,8 +54,16 @@ func (c *Client) listRulesHandler(s *discordgo.Session, i *discordgo.Interaction
 		if window <= 0 {
 			window = 72
 		}
-		lines = append(lines, fmt.Sprintf("**#%d** — r/%s | %s %s match on `%s` · `%s` · window=`%dh`",
-			r.ID, r.Subreddit, r.TargetID, matchType, r.Target, mode, window))
+		line := fmt.Sprintf("**#%d** — r/%s | %s %s match on `%s` · `%s` · window=`%dh`",
+			r.ID, r.Subreddit, r.TargetID, matchType, r.Target, mode, window)
+		if len(r.ExcludeTerms) > 0 {
+			line += " · excludes " + codeList(r.ExcludeTerms)
+		}
+		if r.Managed {
+			line += " · managed by rules file"
+		}
+		line += ruleState(r, c.now())
+		lines = append(lines, line)
 	}
 
 	embed := &discordgo.MessageEmbed{
@@ -75,6 +86,8 @@ func (c *Client) listRulesHandler(s *discordgo.Session, i *discordgo.Interaction
 			Label:    fmt.Sprintf("Delete #%d", r.ID),
 			Style:    discordgo.DangerButton,
 			CustomID: fmt.Sprintf("delete_rule:%d", r.ID),
+			// Managed rules are deleted by editing the rules file.
+			Disabled: r.Managed,
 		})
 		if len(buttons) == 5 || idx == len(rules)-1 || idx == 24 {
 			components = append(components, discordgo.ActionsRow{Components: buttons})
@@ -93,3 +106,19 @@ func (c *Client) listRulesHandler(s *discordgo.Session, i *discordgo.Interaction
 		_ = level.Error(c.Ctx.Log()).Log("error", "failed to send list rules response", "err", err)
 	}
 }
+
+// ruleState is /list_rules' note on whether a rule is paused, snoozed or
+// has quiet hours; empty for a rule that always delivers.
+func ruleState(r *dbstore.RuleDetail, now time.Time) string {
+	var out string
+	switch {
+	case r.Disabled:
+		out = " · ⏸️ paused"
+	case now.Before(r.PausedUntil):
+		out = fmt.Sprintf(" · 💤 snoozed until <t:%d:f>", r.PausedUntil.Unix())
+	}
+	if r.QuietHours != "" {
+		out += fmt.Sprintf(" · 🌙 quiet `%s`", r.QuietHours)
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// minSnooze and maxSnooze bound /snooze_rule's duration; /pause_rule is
+// for longer.
+const (
+	minSnooze = time.Minute
+	maxSnooze = 30 * 24 * time.Hour
+)
+
+func ruleIDOption(verb string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:        "rule_id",
+		Description: fmt.Sprintf("The ID of the rule to %s (use /list_rules to find IDs)", verb),
+		Required:    true,
+		Type:        discordgo.ApplicationCommandOptionInteger,
+	}
+}
+
+func (c *Client) pauseRuleCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "pause_rule",
+			Description: "Stop a rule matching until /resume_rule, without deleting it",
+			Options:     []*discordgo.ApplicationCommandOption{ruleIDOption("pause")},
+		},
+		Handler: c.pauseRuleHandler,
+	}
+}
+
+func (c *Client) resumeRuleCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "resume_rule",
+			Description: "Resume a paused or snoozed rule",
+			Options:     []*discordgo.ApplicationCommandOption{ruleIDOption("resume")},
+		},
+		Handler: c.resumeRuleHandler,
+	}
+}
+
+func (c *Client) snoozeRuleCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "snooze_rule",
+			Description: "Pause a rule for a while; it resumes on its own",
+			Options: []*discordgo.ApplicationCommandOption{
+				ruleIDOption("snooze"),
+				{
+					Name:        "duration",
+					Description: "How long, e.g. 90m, 8h or 3d (up to 30d)",
+					Required:    true,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		Handler: c.snoozeRuleHandler,
+	}
+}
+
+// guildRule loads the rule named by the interaction's rule_id option,
+// responding with an error and returning nil when it doesn't exist or
+// belongs to another server. Managed rules are allowed: pause state isn't
+// part of the rules file.
+func (c *Client) guildRule(s *discordgo.Session, i *discordgo.InteractionCreate) *dbstore.RuleDetail {
+	ruleID := 0
+	for _, o := range i.ApplicationCommandData().Options {
+		if o.Name == "rule_id" {
+			ruleID = int(o.IntValue())
+		}
+	}
+	rule, err := c.Bot.Store.GetRuleByID(c.Ctx, ruleID)
+	if err != nil {
+		c.respondWithError(s, i, fmt.Sprintf("Rule #%d not found.", ruleID))
+		return nil
+	}
+	guild, err := c.Bot.Store.GetDiscordServerByExternalID(c.Ctx, i.GuildID)
+	if err != nil || rule.ServerID != guild.ID {
+		c.respondWithError(s, i, "You can only change rules from this server.")
+		return nil
+	}
+	return rule
+}
+
+func (c *Client) pauseRuleHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to pause rules.")
+		return
+	}
+	rule := c.guildRule(s, i)
+	if rule == nil {
+		return
+	}
+	if !c.setRulePause(s, i, rule, true, time.Time{}) {
+		return
+	}
+	c.respondEphemeral(s, i, fmt.Sprintf("Paused rule #%d (r/%s — %s `%s`). It won't match until `/resume_rule`.",
+		rule.ID, rule.Subreddit, rule.TargetID, rule.Target))
+}
+
+func (c *Client) resumeRuleHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to resume rules.")
+		return
+	}
+	rule := c.guildRule(s, i)
+	if rule == nil {
+		return
+	}
+	if !rule.Paused(c.now()) {
+		c.respondWithError(s, i, fmt.Sprintf("Rule #%d isn't paused.", rule.ID))
+		return
+	}
+	if !c.setRulePause(s, i, rule, false, time.Time{}) {
+		return
+	}
+	c.respondEphemeral(s, i, fmt.Sprintf("Resumed rule #%d (r/%s — %s `%s`).",
+		rule.ID, rule.Subreddit, rule.TargetID, rule.Target))
+}
+
+func (c *Client) snoozeRuleHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to snooze rules.")
+		return
+	}
+	var raw string
+	for _, o := range i.ApplicationCommandData().Options {
+		if o.Name == "duration" {
+			raw = o.StringValue()
+		}
+	}
+	d, err := parseSnooze(raw)
+	if err != nil {
+		c.respondWithError(s, i, err.Error())
+		return
+	}
+	rule := c.guildRule(s, i)
+	if rule == nil {
+		return
+	}
+	until := c.now().Add(d)
+	// Snoozing a paused rule resumes it once the snooze is over.
+	if !c.setRulePause(s, i, rule, false, until) {
+		return
+	}
+	c.respondEphemeral(s, i, fmt.Sprintf("Snoozed rule #%d (r/%s — %s `%s`) until <t:%d:f>.",
+		rule.ID, rule.Subreddit, rule.TargetID, rule.Target, until.Unix()))
+}
+
+// setRulePause stores a rule's pause state and brings the pollers in line,
+// responding with an error and returning false when the store fails.
+func (c *Client) setRulePause(s *discordgo.Session, i *discordgo.InteractionCreate, rule *dbstore.RuleDetail, disabled bool, until time.Time) bool {
+	if err := c.Bot.Store.UpdateRulePause(c.Ctx, rule.ID, disabled, until); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to update rule pause", "ruleID", rule.ID, "err", err)
+		c.respondWithError(s, i, "Failed to update the rule.")
+		return false
+	}
+	c.syncPollers()
+	return true
+}
+
+// syncPollers starts and stops subreddit pollers after a rule change.
+func (c *Client) syncPollers() {
+	if err := c.Bot.SyncPollers(c.Ctx); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to sync pollers", "err", err)
+	}
+}
+
+// parseSnooze reads /snooze_rule's duration: a Go duration ("90m", "8h")
+// or a whole number of days ("3d").
+func parseSnooze(raw string) (time.Duration, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	var d time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: use e.g. 90m, 8h or 3d", raw)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(raw); err != nil {
+			return 0, fmt.Errorf("invalid duration %q: use e.g. 90m, 8h or 3d", raw)
+		}
+	}
+	if d < minSnooze || d > maxSnooze {
+		return 0, fmt.Errorf("duration must be between 1 minute and 30 days, got %q", raw)
+	}
+	return d, nil
+}
@@ -41,6 +41,18 @@ func (c *Client) previewCommandConfig() CommandConfig {
 					Description: "Post the preview visibly to the channel (default: only you see it).",
 					Required:    false,
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "no_cache",
+					Description: "Ask the model again instead of reusing a cached answer for the same prompt.",
+					Required:    false,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "template",
+					Description: `Try a saved prompt template instead of the rule's ("default" = built-in prompts).`,
+					Required:    false,
+				},
 			},
 		},
 		Handler: c.previewHandler,
@@ -48,14 +60,18 @@ func (c *Client) previewCommandConfig() CommandConfig {
 }
 
 func (c *Client) previewHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	var urlArg string
-	public := false
+	var urlArg, templateName string
+	public, noCache := false, false
 	for _, o := range i.ApplicationCommandData().Options {
 		switch o.Name {
 		case "url":
 			urlArg = o.StringValue()
 		case "public":
 			public = o.BoolValue()
+		case "no_cache":
+			noCache = o.BoolValue()
+		case "template":
+			templateName = strings.TrimSpace(o.StringValue())
 		}
 	}
 
@@ -74,7 +90,17 @@ func (c *Client) previewHandler(s *discordgo.Session, i *discordgo.InteractionCr
 		return
 	}
 
-	embeds, notice, err := c.buildPreview(c.Ctx, i.ChannelID, urlArg)
+	ctx := c.Ctx
+	if noCache {
+		ctx = ctxpkg.WithContext(c.Ctx, llm.WithoutCache(c.Ctx))
+	}
+	if templateName != "" {
+		ctx = withTemplateOverride(ctx, templateName)
+	}
+	embeds, notice, err := c.buildPreview(ctx, i.ChannelID, urlArg)
+	if err == nil && templateName != "" {
+		notice += fmt.Sprintf("\nTemplate: `%s` (kinds it doesn't define use the built-in prompt).", templateName)
+	}
 	if err != nil {
 		_, ferr := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
 			Content: fmt.Sprintf(":warning: preview failed: %s", err),
@@ -170,13 +196,7 @@ func (c *Client) buildPreview(ctx ctxpkg.Ctx, channelExternalID, urlOrID string)
 		return nil, "", fmt.Errorf("active rolling-post lookup: %w", err)
 	}
 
-	var dayLocal time.Time
-	if existing != nil {
-		dayLocal = existing.DayLocal
-	} else {
-		phoenix := c.now().In(c.loc)
-		dayLocal = time.Date(phoenix.Year(), phoenix.Month(), phoenix.Day(), 0, 0, 0, 0, time.UTC)
-	}
+	dayLocal := c.digestDayLocal(existing)
 
 	fakeResult := &evaluator.MatchingEvaluationResult{
 		ChannelID: ch.ID,
@@ -189,15 +209,46 @@ func (c *Client) buildPreview(ctx ctxpkg.Ctx, channelExternalID, urlOrID string)
 			Exact:       rule.Exact,
 			Mode:        mode,
 			WindowHours: windowHours,
+			Threshold:   rule.Threshold,
 		},
 	}
 
+	var (
+		embeds []*discordgo.MessageEmbed
+		notice string
+	)
 	switch mode {
 	case dbstore.ModeMusic:
-		return c.previewMusic(ctx, existing, fakeResult, subreddit, dayLocal, rule, post)
+		embeds, notice, err = c.previewMusic(ctx, existing, fakeResult, subreddit, dayLocal, rule, post)
 	default:
-		return c.previewNarrative(ctx, existing, fakeResult, ch, subreddit, dayLocal, rule, post)
+		embeds, notice, err = c.previewNarrative(ctx, existing, fakeResult, ch, subreddit, dayLocal, rule, post)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return embeds, notice + c.similarityNote(ctx, rule, post), nil
+}
+
+// similarityNote reports how similar post is to a semantic rule's
+// description, so thresholds can be tuned against real posts. Empty for
+// other rules.
+func (c *Client) similarityNote(ctx ctxpkg.Ctx, rule *dbstore.RuleDetail, post *redditJSONPost) string {
+	if rule.TargetID != evaluator.TargetSemantic {
+		return ""
+	}
+	if c.embedder == nil {
+		return "\nSimilarity: unavailable — no embedding model configured (LLM_MODEL_EMBEDDING)."
+	}
+	score, err := evaluator.SemanticScore(ctx, c.Bot.Store, c.embedder, post, rule.ID, rule.Target)
+	if err != nil {
+		return fmt.Sprintf("\nSimilarity: unavailable — %s", err)
+	}
+	threshold := evaluator.SemanticThreshold(rule.Threshold)
+	verdict := "would not match"
+	if score >= threshold {
+		verdict = "would match"
 	}
+	return fmt.Sprintf("\nSimilarity to \"%s\": **%.3f** (threshold %.2f — %s)", rule.Target, score, threshold, verdict)
 }
 
 func (c *Client) previewNarrative(
@@ -211,15 +262,18 @@ func (c *Client) previewNarrative(
 	post *redditJSONPost,
 ) ([]*discordgo.MessageEmbed, string, error) {
 	pathLabel := "Fresh (first match of the Phoenix day)"
-	var title, summary string
+	var out llm.Output
+	d := c.discussion(ctx, post)
 	if existing == nil {
-		title, summary = c.freshNarrative(ctx, fakeResult)
+		out = c.freshNarrative(ctx, fakeResult, d, nil)
 	} else {
 		pathLabel = fmt.Sprintf("Update (today's digest already has %d post(s))", len(existing.IncludedPostIDs))
-		title, summary = c.updateNarrative(ctx, existing, fakeResult)
+		out = c.updateNarrative(ctx, existing, fakeResult, d, nil)
 	}
-	rp := buildRollingPostRow(existing, fakeResult, ch, subreddit, dayLocal, title, summary)
+	rp := buildRollingPostRow(existing, fakeResult, ch, subreddit, dayLocal, out.Title, out.Summary)
+	rp.LatestThumbnail = d.thumbnail(post)
 	embed := buildDigestEmbed(rp, fakeResult, subreddit.ExternalID)
+	addOriginalTitle(embed, out)
 	notice := fmt.Sprintf(
 		":microscope: **Preview (narrative)** — nothing was sent to the channel and no DB rows changed.\n"+
 			"Path: **%s** · Rule `#%d` matched on `%s` (%s) · r/%s",
@@ -254,6 +308,7 @@ func (c *Client) previewMusic(
 		RuleID:       rule.ID,
 		RuleTargetID: rule.TargetID,
 		RuleExact:    rule.Exact,
+		Prompt:       c.promptOptions(ctx, fakeResult, llm.PromptMusic),
 	})
 	if err != nil {
 		return nil, "", fmt.Errorf("music extraction failed: %w", err)
@@ -0,0 +1,168 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+)
+
+// retryJobCustomIDPrefix prefixes the /retry_failed buttons; the suffix is a
+// job id or "all".
+const retryJobCustomIDPrefix = "retry_job:"
+
+// retryFailedListLimit matches the 25-button ceiling of one message
+// (5 rows × 5), less one slot for "Retry all".
+const retryFailedListLimit = 24
+
+func (c *Client) retryFailedCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "retry_failed",
+			Description: "List LLM extractions that gave up in this channel and queue them again",
+		},
+		Handler: c.retryFailedHandler,
+	}
+}
+
+func (c *Client) retryFailedHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to retry failed jobs.")
+		return
+	}
+
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, i.ChannelID)
+	if err != nil {
+		c.respondWithError(s, i, "This channel has no rules.")
+		return
+	}
+	jobs, err := c.Bot.Store.ListFailedLLMJobs(c.Ctx, ch.ID, retryFailedListLimit)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to list failed llm jobs", "err", err)
+		c.respondWithError(s, i, "Failed to fetch failed jobs for this channel.")
+		return
+	}
+
+	if len(jobs) == 0 {
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: "No failed jobs in this channel.",
+			},
+		})
+		return
+	}
+
+	var lines []string
+	for _, j := range jobs {
+		title := fmt.Sprintf("post #%d", j.PostID)
+		var payload musicJobPayload
+		if json.Unmarshal(j.Payload, &payload) == nil && payload.Post != nil {
+			title = fmt.Sprintf("r/%s — %s", payload.Post.Subreddit, truncateUTF8(payload.Post.Title, 80))
+		}
+		lines = append(lines, fmt.Sprintf("**#%d** — %s · rule #%d · %d attempts\n`%s`",
+			j.ID, title, j.RuleID, j.Attempts, truncateUTF8(j.LastError, 120)))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Failed LLM Jobs (%d)", len(jobs)),
+		Description: truncateUTF8(strings.Join(lines, "\n"), maxDescRunes),
+		Color:       embedColorReddit,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Click a button below to queue a job again",
+		},
+	}
+
+	buttons := []discordgo.MessageComponent{discordgo.Button{
+		Label:    "Retry all",
+		Style:    discordgo.PrimaryButton,
+		CustomID: retryJobCustomIDPrefix + "all",
+	}}
+	for _, j := range jobs {
+		buttons = append(buttons, discordgo.Button{
+			Label:    fmt.Sprintf("Retry #%d", j.ID),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%s%d", retryJobCustomIDPrefix, j.ID),
+		})
+	}
+	var components []discordgo.MessageComponent
+	for start := 0; start < len(buttons); start += 5 {
+		components = append(components, discordgo.ActionsRow{Components: buttons[start:min(start+5, len(buttons))]})
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to send retry_failed response", "err", err)
+	}
+}
+
+func (c *Client) handleRetryJobButton(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	if !c.hasManageChannels(s, i) {
+		c.respondComponentError(s, i, "You need the **Manage Channels** permission to retry failed jobs.")
+		return
+	}
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, i.ChannelID)
+	if err != nil {
+		c.respondComponentError(s, i, "This channel has no rules.")
+		return
+	}
+
+	var ids []int
+	arg := strings.TrimPrefix(customID, retryJobCustomIDPrefix)
+	if arg == "all" {
+		jobs, err := c.Bot.Store.ListFailedLLMJobs(c.Ctx, ch.ID, retryFailedListLimit)
+		if err != nil {
+			c.respondComponentError(s, i, "Failed to fetch failed jobs for this channel.")
+			return
+		}
+		for _, j := range jobs {
+			ids = append(ids, j.ID)
+		}
+	} else {
+		jobID, err := strconv.Atoi(arg)
+		if err != nil {
+			c.respondComponentError(s, i, "Invalid job ID.")
+			return
+		}
+		job, err := c.Bot.Store.GetLLMJob(c.Ctx, jobID)
+		if err != nil || job == nil || job.ChannelID != ch.ID {
+			c.respondComponentError(s, i, fmt.Sprintf("Job #%d not found in this channel.", jobID))
+			return
+		}
+		ids = []int{jobID}
+	}
+
+	var requeued []string
+	for _, id := range ids {
+		ok, err := c.Bot.Store.RequeueLLMJob(c.Ctx, id)
+		if err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("error", "failed to requeue llm job", "job", id, "err", err)
+			continue
+		}
+		if ok {
+			requeued = append(requeued, fmt.Sprintf("#%d", id))
+		}
+	}
+
+	msg := "Nothing to retry — the job(s) already left the failed state."
+	if len(requeued) > 0 {
+		msg = fmt.Sprintf("Queued %s for another attempt.", strings.Join(requeued, ", "))
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: msg,
+		},
+	})
+}
@@ -0,0 +1,180 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// addExclusionsCustomIDPrefix prefixes the /rule_stats buttons; the suffix
+// is a rule id. Suggestions are worked out again on click, so votes cast
+// since the listing count.
+const addExclusionsCustomIDPrefix = "add_exclusions:"
+
+// maxEmbedDescription is Discord's embed description limit.
+const maxEmbedDescription = 4096
+
+func (c *Client) ruleStatsCommandConfig() CommandConfig {
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "rule_stats",
+			Description: "Show each rule's matches and digest votes, with suggested exclusion terms",
+		},
+		Handler: c.ruleStatsHandler,
+	}
+}
+
+func (c *Client) ruleStatsHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	embed, components, err := c.ruleStats(c.Ctx, i.ChannelID)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to build rule stats", "err", err)
+		c.respondWithError(s, i, "Failed to fetch rule stats for this channel.")
+		return
+	}
+	if embed == nil {
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: "No rules found in this channel. Use `/add_subreddit_listener` to create one.",
+			},
+		})
+		return
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to send rule stats response", "err", err)
+	}
+}
+
+// ruleStats renders the stats of the rules in channelID, with an "Add
+// exclusions" button per rule that has suggestions. A nil embed means the
+// channel has no rules.
+func (c *Client) ruleStats(ctx ctxpkg.Ctx, channelID string) (*discordgo.MessageEmbed, []discordgo.MessageComponent, error) {
+	rules, err := c.Bot.Store.GetRulesByChannel(ctx, channelID)
+	if err != nil || len(rules) == 0 {
+		return nil, nil, err
+	}
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(ctx, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := c.Bot.Store.GetRuleStats(ctx, ch.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats := make(map[int]dbstore.RuleStats, len(all))
+	for _, st := range all {
+		stats[st.RuleID] = st
+	}
+
+	var lines []string
+	var buttons []discordgo.MessageComponent
+	for _, r := range rules {
+		st := stats[r.ID]
+		line := fmt.Sprintf("**#%d** — r/%s | %s `%s` · %d matches · 👍 %d · 👎 %d · not relevant %d · precision %s",
+			r.ID, r.Subreddit, r.TargetID, r.Target, st.Matches, st.Up, st.Down, st.Irrelevant, precision(st))
+		if len(r.ExcludeTerms) > 0 {
+			line += "\n  excludes " + codeList(r.ExcludeTerms)
+		}
+		if st.Down+st.Irrelevant > 0 {
+			terms, err := c.suggestedExclusions(ctx, r)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(terms) > 0 {
+				line += "\n  suggested exclusions: " + codeList(terms)
+				if r.Managed {
+					line += " (edit the rules file)"
+				} else if len(buttons) < 25 {
+					buttons = append(buttons, discordgo.Button{
+						Label:    fmt.Sprintf("Add exclusions to #%d", r.ID),
+						Style:    discordgo.PrimaryButton,
+						CustomID: fmt.Sprintf("%s%d", addExclusionsCustomIDPrefix, r.ID),
+					})
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Rule Stats (%d)", len(rules)),
+		Description: truncateUTF8(strings.Join(lines, "\n"), maxEmbedDescription),
+		Color:       embedColorReddit,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Precision is the share of votes that were 👍. Rate posts with the buttons under each digest.",
+		},
+	}
+	var components []discordgo.MessageComponent
+	for start := 0; start < len(buttons); start += 5 {
+		components = append(components, discordgo.ActionsRow{Components: buttons[start:min(start+5, len(buttons))]})
+	}
+	return embed, components, nil
+}
+
+// precision is the share of st's votes that were up, or a dash before
+// anyone has voted.
+func precision(st dbstore.RuleStats) string {
+	votes := st.Up + st.Down + st.Irrelevant
+	if votes == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%d%%", st.Up*100/votes)
+}
+
+func (c *Client) handleAddExclusionsButton(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	if !c.hasManageChannels(s, i) {
+		c.respondComponentError(s, i, "You need the **Manage Channels** permission to edit rules.")
+		return
+	}
+	ruleID, err := strconv.Atoi(strings.TrimPrefix(customID, addExclusionsCustomIDPrefix))
+	if err != nil {
+		c.respondComponentError(s, i, "Invalid rule ID.")
+		return
+	}
+	rule, err := c.Bot.Store.GetRuleByID(c.Ctx, ruleID)
+	if err != nil {
+		c.respondComponentError(s, i, fmt.Sprintf("Rule #%d not found.", ruleID))
+		return
+	}
+	guild, err := c.Bot.Store.GetDiscordServerByExternalID(c.Ctx, i.GuildID)
+	if err != nil || rule.ServerID != guild.ID {
+		c.respondComponentError(s, i, "You can only edit rules from this server.")
+		return
+	}
+	if rule.Managed {
+		c.respondComponentError(s, i, managedRuleMessage(ruleID))
+		return
+	}
+
+	terms, err := c.addSuggestedExclusions(c.Ctx, rule)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to add exclusions", "ruleID", ruleID, "err", err)
+		c.respondComponentError(s, i, "Failed to update the rule's exclusions.")
+		return
+	}
+	msg := fmt.Sprintf("Rule #%d has no exclusions to suggest any more.", ruleID)
+	if len(terms) > 0 {
+		msg = fmt.Sprintf("Rule #%d now skips titles containing %s.", ruleID, codeList(terms))
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: msg,
+		},
+	})
+}
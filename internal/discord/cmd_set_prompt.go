@@ -0,0 +1,374 @@
+package discord
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	"github.com/meriley/reddit-spy/internal/llm"
+)
+
+// maxTemplateBytes caps an uploaded template file. The built-in prompts are
+// under 2 KiB; anything near this is a mistake, not a prompt.
+const maxTemplateBytes = 16 << 10
+
+func (c *Client) setPromptCommandConfig() CommandConfig {
+	kindChoices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(llm.PromptKinds))
+	for _, k := range llm.PromptKinds {
+		kindChoices = append(kindChoices, &discordgo.ApplicationCommandOptionChoice{Name: string(k), Value: string(k)})
+	}
+	toneChoices := []*discordgo.ApplicationCommandOptionChoice{{Name: defaultTemplateName, Value: defaultTemplateName}}
+	for _, t := range llm.ToneNames() {
+		toneChoices = append(toneChoices, &discordgo.ApplicationCommandOptionChoice{Name: t, Value: t})
+	}
+
+	return CommandConfig{
+		Command: &discordgo.ApplicationCommand{
+			Name:        "set_prompt",
+			Description: "Save an LLM prompt template, or assign a template, tone or language to a rule or this channel",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "name",
+					Description: `Template name ("default" = built-in prompts). Omit everything to list saved templates.`,
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+				{
+					Name:        "kind",
+					Description: "Which prompt the body replaces (required with body or file)",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices:     kindChoices,
+				},
+				{
+					Name:        "body",
+					Description: `Go text/template body; write \n for a line break`,
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+				{
+					Name:        "file",
+					Description: "Template body as a text file (instead of body)",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+				},
+				{
+					Name:        "tone",
+					Description: "Tone preset to assign along with the template",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices:     toneChoices,
+				},
+				{
+					Name:        "rule_id",
+					Description: "Assign name/tone to this rule",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionInteger,
+				},
+				{
+					Name:        "channel",
+					Description: "Assign name/tone as this channel's default",
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+				},
+				{
+					Name:        "language",
+					Description: `Write this channel's digests in this language (e.g. German or de); "default" clears it`,
+					Required:    false,
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		Handler: c.setPromptHandler,
+	}
+}
+
+func (c *Client) setPromptHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !c.hasManageChannels(s, i) {
+		c.respondWithError(s, i, "You need the **Manage Channels** permission to edit prompts.")
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	var name, kindArg, body, attachmentID, tone, lang string
+	var ruleID int
+	var forChannel bool
+	for _, o := range data.Options {
+		switch o.Name {
+		case "name":
+			name = strings.TrimSpace(o.StringValue())
+		case "kind":
+			kindArg = o.StringValue()
+		case "body":
+			body = strings.ReplaceAll(o.StringValue(), `\n`, "\n")
+		case "file":
+			attachmentID, _ = o.Value.(string)
+		case "tone":
+			tone = o.StringValue()
+		case "rule_id":
+			ruleID = int(o.IntValue())
+		case "channel":
+			forChannel = o.BoolValue()
+		case "language":
+			lang = strings.TrimSpace(o.StringValue())
+		}
+	}
+
+	if attachmentID != "" {
+		att, ok := data.Resolved.Attachments[attachmentID]
+		if !ok {
+			c.respondWithError(s, i, "Couldn't read the attached file.")
+			return
+		}
+		text, err := fetchTemplateFile(c.Ctx, att.URL)
+		if err != nil {
+			c.respondWithError(s, i, fmt.Sprintf("Couldn't read the attached file: %s", err))
+			return
+		}
+		body = text
+	}
+
+	saving := body != ""
+	if !saving && ruleID == 0 && !forChannel && lang == "" {
+		c.listPromptTemplates(s, i)
+		return
+	}
+
+	var done []string
+	if saving {
+		if name == "" || name == defaultTemplateName {
+			c.respondWithError(s, i, fmt.Sprintf("Give the template a name other than %q.", defaultTemplateName))
+			return
+		}
+		kind, err := llm.ParsePromptKind(kindArg)
+		if err != nil {
+			c.respondWithError(s, i, "Pick which prompt this body replaces with `kind:` (fresh, update or music).")
+			return
+		}
+		if err := llm.ValidateTemplate(kind, body); err != nil {
+			c.respondWithError(s, i, fmt.Sprintf("Template rejected: %s", err))
+			return
+		}
+		if err := c.Bot.Store.UpsertPromptTemplate(c.Ctx, name, string(kind), body); err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("error", "failed to save prompt template", "name", name, "kind", kind, "err", err)
+			c.respondWithError(s, i, "Failed to save the template.")
+			return
+		}
+		done = append(done, fmt.Sprintf("Saved the **%s** prompt of template `%s`.", kind, name))
+	}
+
+	if lang != "" {
+		msg, err := c.setChannelLanguage(i.ChannelID, lang)
+		if err != nil {
+			c.respondWithError(s, i, err.Error())
+			return
+		}
+		done = append(done, msg)
+	}
+
+	if ruleID != 0 || forChannel {
+		templatePtr, tonePtr, err := c.promptAssignment(name, tone)
+		if err != nil {
+			c.respondWithError(s, i, err.Error())
+			return
+		}
+		if templatePtr == nil && tonePtr == nil {
+			c.respondWithError(s, i, "Provide a `name` and/or `tone` to assign.")
+			return
+		}
+		what := describeAssignment(templatePtr, tonePtr)
+
+		if ruleID != 0 {
+			rule, err := c.Bot.Store.GetRuleByID(c.Ctx, ruleID)
+			if err != nil || rule == nil {
+				c.respondWithError(s, i, fmt.Sprintf("Rule #%d not found.", ruleID))
+				return
+			}
+			guild, err := c.Bot.Store.GetDiscordServerByExternalID(c.Ctx, i.GuildID)
+			if err != nil || rule.ServerID != guild.ID {
+				c.respondWithError(s, i, "You can only edit rules from this server.")
+				return
+			}
+			if rule.Managed {
+				c.respondWithError(s, i, managedRuleMessage(ruleID))
+				return
+			}
+			if err := c.Bot.Store.SetRulePrompt(c.Ctx, ruleID, templatePtr, tonePtr); err != nil {
+				_ = level.Error(c.Ctx.Log()).Log("error", "failed to set rule prompt", "ruleID", ruleID, "err", err)
+				c.respondWithError(s, i, "Failed to update the rule.")
+				return
+			}
+			done = append(done, fmt.Sprintf("Rule #%d now uses %s.", ruleID, what))
+		}
+		if forChannel {
+			ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, i.ChannelID)
+			if err != nil {
+				c.respondWithError(s, i, "This channel has no rules.")
+				return
+			}
+			if err := c.Bot.Store.SetChannelPrompt(c.Ctx, ch.ID, templatePtr, tonePtr); err != nil {
+				_ = level.Error(c.Ctx.Log()).Log("error", "failed to set channel prompt", "channelID", ch.ID, "err", err)
+				c.respondWithError(s, i, "Failed to update the channel.")
+				return
+			}
+			done = append(done, fmt.Sprintf("This channel now defaults to %s.", what))
+		}
+	}
+
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: strings.Join(done, "\n") + "\nTry it with `/preview_digest template:<name>`.",
+		},
+	})
+}
+
+// setChannelLanguage validates lang and stores it as the channel's digest
+// language, returning the confirmation line.
+func (c *Client) setChannelLanguage(channelID, lang string) (string, error) {
+	normalized := ""
+	if lang != defaultTemplateName {
+		var err error
+		if normalized, err = llm.ParseLanguage(lang); err != nil {
+			return "", err
+		}
+	}
+	ch, err := c.Bot.Store.GetDiscordChannelByExternalID(c.Ctx, channelID)
+	if err != nil {
+		return "", fmt.Errorf("this channel has no rules")
+	}
+	if err := c.Bot.Store.SetChannelLanguage(c.Ctx, ch.ID, normalized); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to set channel language", "channelID", ch.ID, "err", err)
+		return "", fmt.Errorf("failed to update the channel")
+	}
+	if normalized == "" {
+		return "This channel's digests no longer have a set language.", nil
+	}
+	return fmt.Sprintf("This channel's digests are now written in %s.", normalized), nil
+}
+
+// promptAssignment turns the name/tone options into SetRulePrompt /
+// SetChannelPrompt arguments: nil leaves a column alone, "" clears it.
+func (c *Client) promptAssignment(name, tone string) (template, toneOut *string, err error) {
+	switch name {
+	case "":
+	case defaultTemplateName:
+		template = new(string)
+	default:
+		templates, err := c.Bot.Store.ListPromptTemplates(c.Ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to look up template %q", name)
+		}
+		found := false
+		for _, t := range templates {
+			found = found || t.Name == name
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("no template named %q — save one with `body:` or `file:` first", name)
+		}
+		template = &name
+	}
+	switch tone {
+	case "":
+	case defaultTemplateName:
+		toneOut = new(string)
+	default:
+		if !llm.IsTonePreset(tone) {
+			return nil, nil, fmt.Errorf("unknown tone %q", tone)
+		}
+		toneOut = &tone
+	}
+	return template, toneOut, nil
+}
+
+func describeAssignment(template, tone *string) string {
+	var parts []string
+	if template != nil {
+		name := *template
+		if name == "" {
+			name = defaultTemplateName
+		}
+		parts = append(parts, fmt.Sprintf("template `%s`", name))
+	}
+	if tone != nil {
+		name := *tone
+		if name == "" {
+			name = "LLM_TONE"
+		}
+		parts = append(parts, fmt.Sprintf("tone `%s`", name))
+	}
+	return strings.Join(parts, " and ")
+}
+
+func (c *Client) listPromptTemplates(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	templates, err := c.Bot.Store.ListPromptTemplates(c.Ctx)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("error", "failed to list prompt templates", "err", err)
+		c.respondWithError(s, i, "Failed to list prompt templates.")
+		return
+	}
+	content := "No prompt templates saved yet. Save one with `/set_prompt name:<name> kind:<kind> body:<template>`."
+	if len(templates) > 0 {
+		kinds := map[string][]string{}
+		var names []string
+		for _, t := range templates {
+			if _, seen := kinds[t.Name]; !seen {
+				names = append(names, t.Name)
+			}
+			kinds[t.Name] = append(kinds[t.Name], t.Kind)
+		}
+		lines := []string{"**Prompt templates**"}
+		for _, n := range names {
+			lines = append(lines, fmt.Sprintf("`%s` — %s", n, strings.Join(kinds[n], ", ")))
+		}
+		lines = append(lines, "Tones: "+strings.Join(llm.ToneNames(), ", "))
+		content = truncateUTF8(strings.Join(lines, "\n"), 2000)
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: content,
+		},
+	})
+}
+
+// fetchTemplateFile downloads an attachment from Discord's CDN, refusing
+// anything over maxTemplateBytes.
+func fetchTemplateFile(parent ctxpkg.Ctx, url string) (string, error) {
+	raw, err := fetchAttachment(parent, url, maxTemplateBytes)
+	return string(raw), err
+}
+
+// fetchAttachment downloads an interaction attachment, refusing one larger
+// than limit bytes.
+func fetchAttachment(parent ctxpkg.Ctx, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(parent, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, fmt.Errorf("file is larger than %d KiB", limit>>10)
+	}
+	return raw, nil
+}
@@ -2,10 +2,15 @@ package discord
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-kit/log/level"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/janitor"
+	"github.com/meriley/reddit-spy/internal/llm"
 )
 
 func (c *Client) statusCommandConfig() CommandConfig {
@@ -48,6 +53,34 @@ func (c *Client) statusHandler(s *discordgo.Session, i *discordgo.InteractionCre
 		},
 	}
 
+	if c.llmRouter != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "LLM Backends",
+			Value: formatBackendStatus(c.llmRouter.Status(), c.now()),
+		})
+	}
+
+	if c.llmCache != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "LLM Cache",
+			Value: formatCacheStats(c.llmCache.Stats()),
+		})
+	}
+
+	if c.janitor != nil {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Pruned Rows",
+			Value: formatPruneStats(c.janitor.Stats(), c.now()),
+		})
+	}
+
+	if a, ok := c.shaper.(shaperAdapter); ok {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "LLM Output",
+			Value: formatStructuredStats(a.inner.StructuredStats()),
+		})
+	}
+
 	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -59,6 +92,72 @@ func (c *Client) statusHandler(s *discordgo.Session, i *discordgo.InteractionCre
 	}
 }
 
+// formatBackendStatus renders one line per LLM backend: a health marker, the
+// base URL, and for a tripped breaker the failure count and time until the
+// next probe.
+func formatBackendStatus(backends []llm.BackendStatus, now time.Time) string {
+	var b strings.Builder
+	for _, st := range backends {
+		if st.Healthy {
+			fmt.Fprintf(&b, "🟢 `%s`", st.BaseURL)
+			if st.ConsecutiveFailures > 0 {
+				fmt.Fprintf(&b, " (%d recent failures)", st.ConsecutiveFailures)
+			}
+		} else {
+			fmt.Fprintf(&b, "🔴 `%s` — %d failures, retry in %s",
+				st.BaseURL, st.ConsecutiveFailures, st.OpenUntil.Sub(now).Round(time.Second))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatCacheStats renders the completion-cache counters with a hit rate
+// over lookups that actually consulted the cache (bypasses excluded).
+func formatCacheStats(st llm.CacheStats) string {
+	lookups := st.Hits + st.Misses
+	rate := 0.0
+	if lookups > 0 {
+		rate = float64(st.Hits) / float64(lookups) * 100
+	}
+	out := fmt.Sprintf("%d hits · %d misses (%.0f%% hit rate)", st.Hits, st.Misses, rate)
+	if st.Bypassed > 0 {
+		out += fmt.Sprintf(" · %d bypassed", st.Bypassed)
+	}
+	if st.Errors > 0 {
+		out += fmt.Sprintf(" · %d store errors", st.Errors)
+	}
+	return out
+}
+
+// formatPruneStats renders the janitor's per-table delete counts, in the
+// order it sweeps them, and when it last ran.
+func formatPruneStats(st janitor.Stats, now time.Time) string {
+	if st.Sweeps == 0 {
+		return "no sweep yet"
+	}
+	var b strings.Builder
+	for _, table := range dbstore.PruneTables() {
+		fmt.Fprintf(&b, "%s: %d\n", table, st.Pruned[table])
+	}
+	fmt.Fprintf(&b, "%d sweeps · last %s ago", st.Sweeps, formatDuration(now.Sub(st.LastRun)))
+	if st.LastErr != "" {
+		fmt.Fprintf(&b, " · failed: %s", st.LastErr)
+	}
+	return b.String()
+}
+
+// formatStructuredStats renders repair and validation-failure counts per
+// prompt kind, in StructuredKinds order.
+func formatStructuredStats(stats map[llm.PromptKind]llm.StructuredStats) string {
+	var b strings.Builder
+	for _, k := range llm.StructuredKinds {
+		st := stats[k]
+		fmt.Fprintf(&b, "%s: %d repairs (%d fixed) · %d failures\n", k, st.Repairs, st.Repaired, st.Failures)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
 func formatDuration(d time.Duration) string {
 	days := int(d.Hours()) / 24
 	hours := int(d.Hours()) % 24
@@ -1,6 +1,7 @@
 package discord
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-kit/log/level"
 
 	database "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
 )
 
 var subredditPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
@@ -40,11 +42,13 @@ func (c *Client) subredditListenerOptions() []*discordgo.ApplicationCommandOptio
 			Choices: []*discordgo.ApplicationCommandOptionChoice{
 				{Name: "author", Value: "author"},
 				{Name: "title", Value: "title"},
+				{Name: "classification (LLM sentiment, topics, toxicity)", Value: evaluator.TargetClassification},
+				{Name: "semantic (value is a description, matched by meaning)", Value: evaluator.TargetSemantic},
 			},
 		},
 		{
 			Name:        "value",
-			Description: "What value are you looking to match?",
+			Description: "What value are you looking to match? For classification: e.g. sentiment:negative topic:bug",
 			Required:    true,
 			Type:        discordgo.ApplicationCommandOptionString,
 		},
@@ -74,7 +78,39 @@ func (c *Client) subredditListenerOptions() []*discordgo.ApplicationCommandOptio
 			MinValue:    ptrFloat(1),
 			MaxValue:    720,
 		},
+		{
+			Name:        "threshold",
+			Description: "Semantic rules only: minimum similarity to match, 0–1. Default 0.6.",
+			Required:    false,
+			Type:        discordgo.ApplicationCommandOptionNumber,
+			MinValue:    ptrFloat(0),
+			MaxValue:    1,
+		},
+	}
+}
+
+// validateRuleTarget checks the value of a rule matching on targetID.
+// Classification values are parsed against the configured taxonomy.
+// Classification and semantic rules need the LLM (and semantic ones an
+// embedding model); without it such a rule could never match, so it's
+// refused.
+func (c *Client) validateRuleTarget(targetID, target string) error {
+	switch targetID {
+	case evaluator.TargetClassification:
+		if c.shaper == nil {
+			return errors.New("classification rules need the LLM, which isn't configured on this bot")
+		}
+		_, err := evaluator.ParseClassificationQuery(target, c.classifyTopics)
+		return err
+	case evaluator.TargetSemantic:
+		if c.embedder == nil {
+			return errors.New("semantic rules need an embedding model (LLM_MODEL_EMBEDDING), which isn't configured on this bot")
+		}
+		if len(strings.Fields(target)) < 2 {
+			return errors.New("describe the posts you want in a few words, e.g. \"posts announcing a new tour\"")
+		}
 	}
+	return nil
 }
 
 // ptrFloat returns a pointer to the supplied float64. discordgo's
@@ -146,6 +182,13 @@ func (c *Client) subredditListenerHandler(s *discordgo.Session, i *discordgo.Int
 				return
 			}
 			rule.WindowHours = int(v)
+		case "threshold":
+			v, ok := option.Value.(float64)
+			if !ok {
+				c.respondWithError(s, i, "invalid threshold value")
+				return
+			}
+			rule.Threshold = v
 		default:
 			_ = level.Error(c.Ctx.Log()).Log("error", "unexpected key",
 				"key", option.Name,
@@ -164,6 +207,10 @@ func (c *Client) subredditListenerHandler(s *discordgo.Session, i *discordgo.Int
 		c.respondWithError(s, i, "Match value cannot be empty.")
 		return
 	}
+	if err := c.validateRuleTarget(rule.TargetID, rule.Target); err != nil {
+		c.respondWithError(s, i, fmt.Sprintf("Invalid %s rule: %s", rule.TargetID, err))
+		return
+	}
 
 	if !c.Bot.ValidateSubredditExists(c.Ctx, subredditID) {
 		c.respondWithError(s, i, fmt.Sprintf("Subreddit r/%s does not exist or is not accessible.", subredditID))
@@ -220,6 +267,12 @@ func (c *Client) hasManageChannels(s *discordgo.Session, i *discordgo.Interactio
 	return perms&discordgo.PermissionManageChannels != 0 || perms&discordgo.PermissionAdministrator != 0
 }
 
+// managedRuleMessage is the reply to a command that would change a rule the
+// rules file owns.
+func managedRuleMessage(ruleID int) string {
+	return fmt.Sprintf("Rule #%d is managed by this deployment's rules file; change it there instead.", ruleID)
+}
+
 func (c *Client) respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
 	_ = level.Error(c.Ctx.Log()).Log("error", msg)
 	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -16,6 +16,34 @@ func (c *Client) componentHandler(s *discordgo.Session, i *discordgo.Interaction
 		c.handleDeleteRuleButton(s, i, customID)
 		return
 	}
+	if strings.HasPrefix(customID, retryJobCustomIDPrefix) {
+		c.handleRetryJobButton(s, i, customID)
+		return
+	}
+	if strings.HasPrefix(customID, importConfigCustomIDPrefix) {
+		c.handleImportConfigButton(s, i, customID)
+		return
+	}
+	if strings.HasPrefix(customID, cancelImportCustomIDPrefix) {
+		c.handleCancelImportButton(s, i, customID)
+		return
+	}
+	if strings.HasPrefix(customID, feedbackCustomIDPrefix) {
+		c.handleFeedbackButton(s, i, customID)
+		return
+	}
+	if strings.HasPrefix(customID, feedbackPostCustomIDPrefix) {
+		c.handleFeedbackPostSelect(s, i, customID)
+		return
+	}
+	if strings.HasPrefix(customID, addExclusionsCustomIDPrefix) {
+		c.handleAddExclusionsButton(s, i, customID)
+		return
+	}
+	if customID == buildPlaylistCustomID {
+		c.handleBuildPlaylistButton(s, i)
+		return
+	}
 
 	_ = level.Warn(c.Ctx.Log()).Log("msg", "unknown component interaction", "customID", customID)
 }
@@ -44,12 +72,17 @@ func (c *Client) handleDeleteRuleButton(s *discordgo.Session, i *discordgo.Inter
 		c.respondComponentError(s, i, "You can only delete rules from this server.")
 		return
 	}
+	if rule.Managed {
+		c.respondComponentError(s, i, managedRuleMessage(ruleID))
+		return
+	}
 
 	if err := c.Bot.Store.DeleteRule(c.Ctx, ruleID); err != nil {
 		_ = level.Error(c.Ctx.Log()).Log("error", "failed to delete rule via button", "ruleID", ruleID, "err", err)
 		c.respondComponentError(s, i, "Failed to delete rule.")
 		return
 	}
+	c.syncPollers()
 
 	matchType := "partial"
 	if rule.Exact {
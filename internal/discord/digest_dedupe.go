@@ -0,0 +1,237 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/dedupe"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// defaultDuplicateWindow is how far apart two copies of a post can arrive
+// and still fold into one digest item, when DUPLICATE_WINDOW isn't set.
+const defaultDuplicateWindow = 48 * time.Hour
+
+// maxSourcesLines caps the "Also posted in" field; Discord allows 1024
+// characters per field.
+const maxSourcesLines = 5
+
+// WithDuplicateWindow sets how far apart copies of a post (crossposts,
+// same link, near-identical title) can arrive and still collapse into one
+// narrative digest item. 0 disables duplicate detection.
+func WithDuplicateWindow(d time.Duration) Option {
+	return func(c *Client) {
+		if d >= 0 {
+			c.duplicateWindow = d
+		}
+	}
+}
+
+// digestItem fingerprints post as an item of the digest rollingPostID.
+func digestItem(rollingPostID int, post *redditJSON.RedditPost) dbstore.DigestItem {
+	fp := dedupe.New(post.ID, post.CrosspostParent, post.URL, post.Permalink, post.Title)
+	return dbstore.DigestItem{
+		RollingPostID:   rollingPostID,
+		PostID:          post.ID,
+		CanonicalPostID: post.ID,
+		Subreddit:       post.Subreddit,
+		Title:           post.Title,
+		CrosspostParent: fp.CrosspostParent,
+		URL:             fp.URL,
+		TitleHash:       fp.TitleHash,
+		Score:           post.Score,
+		Comments:        post.NumComments,
+	}
+}
+
+func itemFingerprint(it dbstore.DigestItem) dedupe.Fingerprint {
+	return dedupe.Fingerprint{PostID: it.PostID, CrosspostParent: it.CrosspostParent, URL: it.URL, TitleHash: it.TitleHash}
+}
+
+// findDuplicate returns the item among items that post copies, or nil.
+// Items seen longer than the duplicate window ago don't count.
+func (c *Client) findDuplicate(items []dbstore.DigestItem, post *redditJSON.RedditPost) (*dbstore.DigestItem, dedupe.Reason) {
+	if c.duplicateWindow <= 0 {
+		return nil, ""
+	}
+	fp := itemFingerprint(digestItem(0, post))
+	cutoff := c.now().Add(-c.duplicateWindow)
+	for i := range items {
+		if items[i].PostID == post.ID || items[i].SeenAt.Before(cutoff) {
+			continue
+		}
+		if reason, ok := dedupe.Match(fp, itemFingerprint(items[i])); ok {
+			return &items[i], reason
+		}
+	}
+	return nil, ""
+}
+
+// digestItems loads existing's items for duplicate detection. A read
+// failure only costs deduplication, so it is logged rather than returned.
+func (c *Client) digestItems(ctx ctxpkg.Ctx, existing *dbstore.RollingPost) []dbstore.DigestItem {
+	if existing == nil || c.duplicateWindow <= 0 {
+		return nil
+	}
+	items, err := c.Bot.Store.GetDigestItems(ctx, existing.ID)
+	if err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "read digest items failed; skipping duplicate check", "rolling_post_id", existing.ID, "error", err)
+		return nil
+	}
+	return items
+}
+
+// recordDigestItem stores it. Like digestItems, a failure only costs
+// deduplication of later copies.
+func (c *Client) recordDigestItem(ctx ctxpkg.Ctx, it dbstore.DigestItem) {
+	if c.duplicateWindow <= 0 {
+		return
+	}
+	if err := c.Bot.Store.InsertDigestItem(ctx, it); err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "record digest item failed", "post_id", it.PostID, "rolling_post_id", it.RollingPostID, "error", err)
+	}
+}
+
+// foldDuplicate adds a copy of dup's post to the existing digest without
+// re-running the narrative: the copy's subreddit joins the item's sources
+// and its score and comments are added to the item's totals.
+func (c *Client) foldDuplicate(
+	ctx ctxpkg.Ctx,
+	existing *dbstore.RollingPost,
+	items []dbstore.DigestItem,
+	dup *dbstore.DigestItem,
+	reason dedupe.Reason,
+	result *evaluator.MatchingEvaluationResult,
+	ch *dbstore.DiscordChannel,
+	subreddit *dbstore.Subreddit,
+) error {
+	_ = level.Info(ctx.Log()).Log(
+		"msg", "folding duplicate post into digest item",
+		"post_id", result.Post.ID, "duplicate_of", dup.CanonicalPostID, "reason", reason,
+	)
+
+	it := digestItem(existing.ID, result.Post)
+	it.CanonicalPostID = dup.CanonicalPostID
+	it.SeenAt = c.now()
+	items = append(items, it)
+
+	rp := *existing
+	rp.SubredditIDs = appendUniqueInt(existing.SubredditIDs, subreddit.ID)
+	rp.IncludedRuleIDs = appendUniqueInt(existing.IncludedRuleIDs, result.RuleID)
+	groups := groupDigestItems(items)
+
+	// The embed describes the digest's latest post (LatestURL), which isn't
+	// necessarily the story this copy belongs to. Its score and comments
+	// take in the copy only when the copy is of that post; a copy of an
+	// older story shows up in "Also posted in" instead.
+	latest := latestStoryItem(items, existing.IncludedPostIDs)
+	var embedSubreddit string
+	embedResult := &evaluator.MatchingEvaluationResult{Post: &redditJSON.RedditPost{}}
+	if latest != nil {
+		embedSubreddit = latest.Subreddit
+		embedResult.Post.CreatedUTC = float64(latest.SeenAt.Unix())
+		if latest.CanonicalPostID == dup.CanonicalPostID {
+			for _, g := range groups {
+				if g.canonical == dup.CanonicalPostID {
+					rp.LatestScore, rp.LatestComments = g.score, g.comments
+				}
+			}
+		}
+	} else if names := c.resolveSubredditNames(ctx, []int{existing.SubredditID}); len(names) > 0 {
+		embedSubreddit = names[0]
+	}
+
+	embed := buildDigestEmbed(rp, embedResult, embedSubreddit)
+	addSourcesField(embed, groups)
+
+	primary := ""
+	if len(existing.DiscordMessageIDs) > 0 {
+		primary = existing.DiscordMessageIDs[0]
+	}
+	ids, err := c.publishDigestEmbed(ctx, ch.ExternalID, primary, embed)
+	if err != nil {
+		return err
+	}
+	rp.DiscordMessageIDs = ids
+
+	if _, err := c.Bot.Store.UpsertRollingPost(ctx, rp); err != nil {
+		return fmt.Errorf("failed to upsert rolling post: %w", err)
+	}
+	c.recordDigestItem(ctx, it)
+	if _, err := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); err != nil {
+		return fmt.Errorf("failed to insert notification into database: %w", err)
+	}
+	return nil
+}
+
+// latestStoryItem returns the item of the digest's newest post, the last of
+// includedPostIDs, or nil when it has no item (recording items is best
+// effort).
+func latestStoryItem(items []dbstore.DigestItem, includedPostIDs []string) *dbstore.DigestItem {
+	if len(includedPostIDs) == 0 {
+		return nil
+	}
+	id := includedPostIDs[len(includedPostIDs)-1]
+	for i := range items {
+		if items[i].PostID == id {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+// itemGroup is one digest item with its copies folded in.
+type itemGroup struct {
+	canonical  string
+	title      string
+	subreddits []string
+	score      int
+	comments   int
+}
+
+// groupDigestItems folds items by CanonicalPostID, in the order each item
+// first appeared.
+func groupDigestItems(items []dbstore.DigestItem) []*itemGroup {
+	var groups []*itemGroup
+	byID := map[string]*itemGroup{}
+	for _, it := range items {
+		g, ok := byID[it.CanonicalPostID]
+		if !ok {
+			g = &itemGroup{canonical: it.CanonicalPostID, title: it.Title}
+			byID[it.CanonicalPostID] = g
+			groups = append(groups, g)
+		}
+		g.subreddits = appendUnique(g.subreddits, it.Subreddit)
+		g.score += it.Score
+		g.comments += it.Comments
+	}
+	return groups
+}
+
+// addSourcesField lists the digest's items that were posted to more than
+// one subreddit, newest first, with their summed score and comments.
+func addSourcesField(embed *discordgo.MessageEmbed, groups []*itemGroup) {
+	var lines []string
+	for i := len(groups) - 1; i >= 0 && len(lines) < maxSourcesLines; i-- {
+		g := groups[i]
+		if len(g.subreddits) < 2 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s — r/%s · %d points · %d comments",
+			truncateUTF8(g.title, 80), strings.Join(g.subreddits, ", r/"), g.score, g.comments))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Also posted in",
+		Value: truncateUTF8(strings.Join(lines, "\n"), 1024),
+	})
+}
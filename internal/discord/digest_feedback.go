@@ -0,0 +1,411 @@
+package discord
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+)
+
+// feedbackCustomIDPrefix prefixes the vote buttons on every digest message;
+// the suffix is the vote. Like buildPlaylistCustomID they carry no digest:
+// it's resolved from the clicked message.
+const feedbackCustomIDPrefix = "feedback:"
+
+// feedbackPostCustomIDPrefix prefixes the post picker a vote on a digest of
+// several posts answers with; the suffix is "<vote>:<rolling post id>",
+// since the picker lives on its own ephemeral message.
+const feedbackPostCustomIDPrefix = "feedback_post:"
+
+// maxFeedbackPostOptions is Discord's option limit for one select menu.
+const maxFeedbackPostOptions = 25
+
+// reactionVotes are the reactions on a digest message counted as votes.
+var reactionVotes = map[string]string{
+	"👍": dbstore.VoteUp,
+	"👎": dbstore.VoteDown,
+}
+
+// WithAutoExclude has a negative vote add a rule's suggested exclusion
+// terms straight away once a word turns up in minPosts disliked posts.
+// 0, the default, only suggests them in /rule_stats.
+func WithAutoExclude(minPosts int) Option {
+	return func(c *Client) {
+		if minPosts >= 0 {
+			c.autoExcludeMinPosts = minPosts
+		}
+	}
+}
+
+// feedbackButtons are the vote buttons of a digest message. Music cards
+// share their row with "Build playlist".
+func feedbackButtons() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.Button{
+			Style:    discordgo.SecondaryButton,
+			CustomID: feedbackCustomIDPrefix + dbstore.VoteUp,
+			Emoji:    &discordgo.ComponentEmoji{Name: "👍"},
+		},
+		discordgo.Button{
+			Style:    discordgo.SecondaryButton,
+			CustomID: feedbackCustomIDPrefix + dbstore.VoteDown,
+			Emoji:    &discordgo.ComponentEmoji{Name: "👎"},
+		},
+		discordgo.Button{
+			Label:    "Not relevant",
+			Style:    discordgo.SecondaryButton,
+			CustomID: feedbackCustomIDPrefix + dbstore.VoteIrrelevant,
+		},
+	}
+}
+
+// narrativeDigestComponents is the action row of a narrative digest message.
+func narrativeDigestComponents() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: feedbackButtons()}}
+}
+
+// voteLabel is how a reply names vote.
+func voteLabel(vote string) string {
+	switch vote {
+	case dbstore.VoteUp:
+		return "👍"
+	case dbstore.VoteDown:
+		return "👎"
+	default:
+		return "not relevant"
+	}
+}
+
+// feedbackPost is one post of a digest that can be voted on.
+type feedbackPost struct {
+	ID    string
+	Title string
+}
+
+func (p feedbackPost) label() string {
+	if p.Title == "" {
+		return "post " + p.ID
+	}
+	return p.Title
+}
+
+// feedbackPosts lists rp's posts, oldest first. Narrative titles come from
+// the digest's items; a music post is titled by the releases extracted from
+// it, since its Reddit title isn't kept.
+func (c *Client) feedbackPosts(ctx ctxpkg.Ctx, rp *dbstore.RollingPost) []feedbackPost {
+	titles := map[string]string{}
+	if rp.Mode == dbstore.ModeMusic {
+		entries, err := decodeMusicEntries(rp.Entries)
+		if err != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to decode digest entries for feedback", "rolling_post_id", rp.ID, "error", err)
+		}
+		for _, e := range entries {
+			if e.SourcePostID == "" {
+				continue
+			}
+			release := e.Artist + " – " + e.Title
+			if t := titles[e.SourcePostID]; t != "" {
+				release = t + "; " + release
+			}
+			titles[e.SourcePostID] = release
+		}
+	} else {
+		items, err := c.Bot.Store.GetDigestItems(ctx, rp.ID)
+		if err != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to read digest items for feedback", "rolling_post_id", rp.ID, "error", err)
+		}
+		for _, it := range items {
+			titles[it.PostID] = it.Title
+		}
+	}
+	posts := make([]feedbackPost, 0, len(rp.IncludedPostIDs))
+	for _, id := range rp.IncludedPostIDs {
+		posts = append(posts, feedbackPost{ID: id, Title: titles[id]})
+	}
+	return posts
+}
+
+// recordFeedback stores userID's vote on post against every rule that
+// notified ch of it, returning those rules and, when auto-exclusion is on
+// and the vote is negative, the terms it added to each.
+func (c *Client) recordFeedback(ctx ctxpkg.Ctx, ch *dbstore.DiscordChannel, post feedbackPost, userID, vote string) ([]int, map[int][]string, error) {
+	ruleIDs, err := c.Bot.Store.GetNotifiedRules(ctx, ch.ID, post.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range ruleIDs {
+		if err := c.Bot.Store.RecordFeedback(ctx, dbstore.Feedback{
+			PostID: post.ID, RuleID: id, UserID: userID, Vote: vote, Title: post.Title,
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.autoExcludeMinPosts <= 0 || !dbstore.IsNegativeVote(vote) {
+		return ruleIDs, nil, nil
+	}
+	added := map[int][]string{}
+	for _, id := range ruleIDs {
+		rule, err := c.Bot.Store.GetRuleByID(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		// A managed rule's terms come from the rules file.
+		if rule.Managed {
+			continue
+		}
+		terms, err := c.addSuggestedExclusions(ctx, rule)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(terms) > 0 {
+			added[id] = terms
+		}
+	}
+	return ruleIDs, added, nil
+}
+
+// suggestedExclusions are the terms /rule_stats offers for rule.
+func (c *Client) suggestedExclusions(ctx ctxpkg.Ctx, rule *dbstore.RuleDetail) ([]string, error) {
+	fb, err := c.Bot.Store.ListRuleFeedback(ctx, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+	return evaluator.SuggestExclusions(rule.Target, rule.ExcludeTerms, fb, c.autoExcludeMinPosts), nil
+}
+
+// addSuggestedExclusions appends rule's suggested terms to its exclusions
+// and returns them.
+func (c *Client) addSuggestedExclusions(ctx ctxpkg.Ctx, rule *dbstore.RuleDetail) ([]string, error) {
+	terms, err := c.suggestedExclusions(ctx, rule)
+	if err != nil || len(terms) == 0 {
+		return nil, err
+	}
+	if err := c.Bot.Store.UpdateRuleExcludeTerms(ctx, rule.ID, append(slices.Clone(rule.ExcludeTerms), terms...)); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// feedbackReply tells the voter what their vote on post did.
+func feedbackReply(post feedbackPost, vote string, ruleIDs []int, added map[int][]string) string {
+	if len(ruleIDs) == 0 {
+		return fmt.Sprintf("No rule's match of %s is on record any more, so there's nothing to rate.", quoteTitle(post.label()))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Recorded %s on %s for %s.", voteLabel(vote), quoteTitle(post.label()), ruleList(ruleIDs))
+	for _, id := range ruleIDs {
+		if terms := added[id]; len(terms) > 0 {
+			fmt.Fprintf(&b, "\nRule #%d now skips titles containing %s.", id, codeList(terms))
+		}
+	}
+	return b.String()
+}
+
+func quoteTitle(s string) string {
+	return "“" + truncateUTF8(s, 100) + "”"
+}
+
+func ruleList(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("#%d", id)
+	}
+	if len(ids) == 1 {
+		return "rule " + parts[0]
+	}
+	return "rules " + strings.Join(parts, ", ")
+}
+
+// codeList renders terms as inline code, comma-separated.
+func codeList(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = "`" + t + "`"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// feedbackDigest resolves the digest rpID, or the one message belongs to
+// when rpID is 0, and checks it's in the channel the click came from.
+func (c *Client) feedbackDigest(s *discordgo.Session, i *discordgo.InteractionCreate, rpID int) (*dbstore.RollingPost, *dbstore.DiscordChannel, bool) {
+	var rp *dbstore.RollingPost
+	var err error
+	switch {
+	case rpID > 0:
+		rp, err = c.Bot.Store.GetRollingPost(c.Ctx, rpID)
+	case i.Message != nil:
+		rp, err = c.Bot.Store.GetRollingPostByMessageID(c.Ctx, i.Message.ID)
+	default:
+		c.respondComponentError(s, i, "Couldn't tell which digest this button belongs to.")
+		return nil, nil, false
+	}
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to load digest for feedback", "error", err)
+		c.respondComponentError(s, i, "Failed to load this digest.")
+		return nil, nil, false
+	}
+	if rp == nil {
+		c.respondComponentError(s, i, "This digest is no longer available.")
+		return nil, nil, false
+	}
+	ch, err := c.Bot.Store.GetDiscordChannel(c.Ctx, rp.ChannelID)
+	if err != nil || ch.ExternalID != i.ChannelID {
+		c.respondComponentError(s, i, "This digest belongs to another channel.")
+		return nil, nil, false
+	}
+	return rp, ch, true
+}
+
+// handleFeedbackButton records a vote on a digest of one post, or asks
+// which posts it's about when the digest has several.
+func (c *Client) handleFeedbackButton(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	vote := strings.TrimPrefix(customID, feedbackCustomIDPrefix)
+	rp, ch, ok := c.feedbackDigest(s, i, 0)
+	if !ok {
+		return
+	}
+	posts := c.feedbackPosts(c.Ctx, rp)
+	if len(posts) == 0 {
+		c.respondComponentError(s, i, "This digest has no posts to rate yet.")
+		return
+	}
+	if len(posts) == 1 {
+		ruleIDs, added, err := c.recordFeedback(c.Ctx, ch, posts[0], interactionUserID(i), vote)
+		if err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("msg", "failed to record feedback", "rolling_post_id", rp.ID, "error", err)
+			c.respondComponentError(s, i, "Failed to record your feedback.")
+			return
+		}
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: feedbackReply(posts[0], vote, ruleIDs, added),
+			},
+		})
+		return
+	}
+
+	// The newest posts are the likeliest to be rated.
+	posts = posts[max(0, len(posts)-maxFeedbackPostOptions):]
+	options := make([]discordgo.SelectMenuOption, 0, len(posts))
+	for _, p := range posts {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncateUTF8(p.label(), 100),
+			Value:       p.ID,
+			Description: "reddit.com/comments/" + p.ID,
+		})
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("Which posts does your %s go to?", voteLabel(vote)),
+			Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:    discordgo.StringSelectMenu,
+					CustomID:    fmt.Sprintf("%s%s:%d", feedbackPostCustomIDPrefix, vote, rp.ID),
+					Placeholder: "Pick one or more posts",
+					MaxValues:   len(options),
+					Options:     options,
+				},
+			}}},
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to respond with feedback picker", "error", err)
+	}
+}
+
+// handleFeedbackPostSelect records a vote on the posts picked from the
+// menu handleFeedbackButton sent, replacing the menu with the outcome.
+func (c *Client) handleFeedbackPostSelect(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	vote, idStr, _ := strings.Cut(strings.TrimPrefix(customID, feedbackPostCustomIDPrefix), ":")
+	rpID, err := strconv.Atoi(idStr)
+	if err != nil || rpID <= 0 {
+		c.respondComponentError(s, i, "Invalid digest ID.")
+		return
+	}
+	rp, ch, ok := c.feedbackDigest(s, i, rpID)
+	if !ok {
+		return
+	}
+
+	picked := i.MessageComponentData().Values
+	var replies []string
+	for _, p := range c.feedbackPosts(c.Ctx, rp) {
+		if !slices.Contains(picked, p.ID) {
+			continue
+		}
+		ruleIDs, added, err := c.recordFeedback(c.Ctx, ch, p, interactionUserID(i), vote)
+		if err != nil {
+			_ = level.Error(c.Ctx.Log()).Log("msg", "failed to record feedback", "rolling_post_id", rp.ID, "post", p.ID, "error", err)
+			c.respondComponentError(s, i, "Failed to record your feedback.")
+			return
+		}
+		replies = append(replies, feedbackReply(p, vote, ruleIDs, added))
+	}
+	if len(replies) == 0 {
+		replies = []string{"Those posts are no longer in this digest."}
+	}
+
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    truncateUTF8(strings.Join(replies, "\n"), 2000),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+// handleFeedbackReaction records a 👍 or 👎 added to a digest message as a
+// vote, as the buttons would.
+func (c *Client) handleFeedbackReaction(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.Member != nil && r.Member.User != nil && r.Member.User.Bot {
+		return
+	}
+	if s.State != nil && s.State.User != nil && r.UserID == s.State.User.ID {
+		return
+	}
+	if err := c.recordReaction(c.Ctx, r.ChannelID, r.MessageID, r.UserID, r.Emoji.Name); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to record reaction feedback", "message_id", r.MessageID, "error", err)
+	}
+}
+
+// recordReaction records userID's emoji on messageID as a vote when the
+// message is a digest of one post in channelID. A reaction can't say which
+// post of a larger digest it means, so those are left to the buttons.
+func (c *Client) recordReaction(ctx ctxpkg.Ctx, channelID, messageID, userID, emoji string) error {
+	vote, ok := reactionVotes[emoji]
+	if !ok {
+		return nil
+	}
+	rp, err := c.Bot.Store.GetRollingPostByMessageID(ctx, messageID)
+	if err != nil || rp == nil {
+		return err
+	}
+	ch, err := c.Bot.Store.GetDiscordChannel(ctx, rp.ChannelID)
+	if err != nil {
+		return err
+	}
+	if ch.ExternalID != channelID {
+		return nil
+	}
+	posts := c.feedbackPosts(ctx, rp)
+	if len(posts) != 1 {
+		return nil
+	}
+	ruleIDs, added, err := c.recordFeedback(ctx, ch, posts[0], userID, vote)
+	if err != nil {
+		return err
+	}
+	_ = level.Info(ctx.Log()).Log("msg", "recorded reaction feedback", "rolling_post_id", rp.ID, "post", posts[0].ID,
+		"vote", vote, "rules", fmt.Sprint(ruleIDs), "excluded", fmt.Sprint(added))
+	return nil
+}
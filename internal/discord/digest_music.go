@@ -2,6 +2,7 @@ package discord
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -426,10 +427,15 @@ func runeCount(s string) int {
 	return n
 }
 
+// errMusicShape marks an applyMusicMatch failure in the LLM extraction step,
+// which is worth retrying later, as opposed to a Discord or store error.
+var errMusicShape = errors.New("music shape failed")
+
 // handleMusicMatch is the SendMessage branch for music-mode rules. Output
 // shape: parent card in the channel + a thread attached to that card that
 // holds the full per-release spill. Same-window matches edit the card in
-// place and sync thread-reply messages by index.
+// place and sync thread-reply messages by index. A failed extraction is
+// queued in llm_jobs for the retry worker rather than dropped.
 func (c *Client) handleMusicMatch(
 	ctx ctxpkg.Ctx,
 	existing *dbstore.RollingPost,
@@ -443,6 +449,34 @@ func (c *Client) handleMusicMatch(
 		return nil
 	}
 
+	err := c.applyMusicMatch(ctx, existing, result, ch, subreddit, dayLocal)
+	if !errors.Is(err, errMusicShape) {
+		return err
+	}
+	_ = level.Warn(ctx.Log()).Log("msg", "music shape failed; queued for retry", "post", result.Post.ID, "error", err)
+	if qerr := c.enqueueMusicJob(ctx, result, err); qerr != nil {
+		_ = level.Error(ctx.Log()).Log("msg", "failed to queue music retry; post's releases are lost", "post", result.Post.ID, "error", qerr)
+	}
+	// Record the notification so the poll loop doesn't re-shape the same
+	// post every tick; the job queue owns retries from here.
+	if _, nerr := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); nerr != nil {
+		return fmt.Errorf("insert notification after music shape failure: %w", nerr)
+	}
+	return nil
+}
+
+// applyMusicMatch extracts entries from result.Post, folds them into
+// existing (or opens a new digest), renders, and persists. Shared by the live
+// path and the retry worker. Extraction failures wrap errMusicShape and
+// leave the on-screen digest as it was.
+func (c *Client) applyMusicMatch(
+	ctx ctxpkg.Ctx,
+	existing *dbstore.RollingPost,
+	result *evaluator.MatchingEvaluationResult,
+	ch *dbstore.DiscordChannel,
+	subreddit *dbstore.Subreddit,
+	dayLocal time.Time,
+) error {
 	var known []llm.MusicEntry
 	if existing != nil {
 		decoded, err := decodeMusicEntries(existing.Entries)
@@ -452,20 +486,21 @@ func (c *Client) handleMusicMatch(
 		known = decoded
 	}
 
+	// stream tracks any messages progressive renders create so the final
+	// sync below edits them rather than posting duplicates.
+	stream := c.newMusicStream(ctx, existing, result, ch, subreddit, dayLocal)
 	newEntries, err := c.shaper.ShapeMusic(ctx, llm.MusicInput{
 		Post:         result.Post,
 		KnownEntries: known,
 		RuleID:       result.RuleID,
 		RuleTargetID: result.Rule.TargetID,
 		RuleExact:    result.Rule.Exact,
+		Prompt:       c.promptOptions(ctx, result, llm.PromptMusic),
+		Progress:     stream.progress,
 	})
 	if err != nil {
-		_ = level.Warn(ctx.Log()).Log("msg", "music shape failed; leaving digest unchanged", "error", err)
-		// Record the notification so we don't retry the same post every poll tick.
-		if _, nerr := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); nerr != nil {
-			return fmt.Errorf("insert notification after music shape failure: %w", nerr)
-		}
-		return nil
+		stream.rollback()
+		return fmt.Errorf("%w: %w", errMusicShape, err)
 	}
 
 	rp, merged, err := buildMusicRollingPost(existing, result, subreddit, dayLocal, newEntries)
@@ -473,7 +508,7 @@ func (c *Client) handleMusicMatch(
 		return fmt.Errorf("build music rolling post: %w", err)
 	}
 
-	if len(merged) == 0 && existing == nil {
+	if len(merged) == 0 && existing == nil && !stream.touched {
 		// Nothing to show, and no prior message — just record the notification
 		// so we don't reprocess this post on the next poll.
 		if _, nerr := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); nerr != nil {
@@ -497,12 +532,9 @@ func (c *Client) handleMusicMatch(
 	card := renderMusicCard(rp, merged, subNames)
 	threadEmbeds := renderMusicThreadEmbeds(rp, merged)
 
-	// 1. Parent card → channel. Edit prior id if we have one, else send fresh.
-	var priorParentIDs []string
-	if existing != nil {
-		priorParentIDs = existing.DiscordMessageIDs
-	}
-	parentIDs, err := c.syncParentCard(ctx, ch.ExternalID, priorParentIDs, card)
+	// 1. Parent card → channel. Edit prior id if we have one (persisted or
+	//    created by a progressive frame), else send fresh.
+	parentIDs, err := c.syncParentCard(ctx, ch.ExternalID, stream.parentIDs, card)
 	if err != nil {
 		return fmt.Errorf("sync parent card: %w", err)
 	}
@@ -514,21 +546,13 @@ func (c *Client) handleMusicMatch(
 
 	// 2. Thread → attached to the parent message. Create on first open;
 	//    reuse (un-archive if needed) on subsequent window matches.
-	threadID := ""
-	if existing != nil {
-		threadID = existing.ThreadID
-	}
-	threadID, err = c.ensureThread(ctx, ch.ExternalID, parentMsgID, threadID, subNames)
+	threadID, err := c.ensureThread(ctx, ch.ExternalID, parentMsgID, stream.threadID, subNames)
 	if err != nil {
 		return fmt.Errorf("ensure digest thread: %w", err)
 	}
 	rp.ThreadID = threadID
 
-	var priorThreadReplies []string
-	if existing != nil {
-		priorThreadReplies = existing.ThreadMessageIDs
-	}
-	threadReplyIDs, err := c.syncThreadReplies(ctx, threadID, priorThreadReplies, threadEmbeds)
+	threadReplyIDs, err := c.syncThreadReplies(ctx, threadID, stream.threadReplyIDs, threadEmbeds)
 	if err != nil {
 		return fmt.Errorf("sync thread replies: %w", err)
 	}
@@ -556,9 +580,11 @@ func (c *Client) syncParentCard(
 		prior = priorIDs[0]
 	}
 
+	components := musicCardComponents()
 	if prior == "" {
 		msg, err := c.sender.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-			Embeds: []*discordgo.MessageEmbed{card},
+			Embeds:     []*discordgo.MessageEmbed{card},
+			Components: components,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("send parent card: %w", err)
@@ -567,9 +593,10 @@ func (c *Client) syncParentCard(
 	}
 
 	edited, err := c.sender.ChannelMessageEditComplex(&discordgo.MessageEdit{
-		Channel: channelID,
-		ID:      prior,
-		Embeds:  &[]*discordgo.MessageEmbed{card},
+		Channel:    channelID,
+		ID:         prior,
+		Embeds:     &[]*discordgo.MessageEmbed{card},
+		Components: &components,
 	})
 	if isMessageGone(err) {
 		_ = level.Warn(ctx.Log()).Log(
@@ -577,7 +604,8 @@ func (c *Client) syncParentCard(
 			"channel", channelID, "message_id", prior,
 		)
 		msg, sendErr := c.sender.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-			Embeds: []*discordgo.MessageEmbed{card},
+			Embeds:     []*discordgo.MessageEmbed{card},
+			Components: components,
 		})
 		if sendErr != nil {
 			return nil, fmt.Errorf("fallback send after parent-card 404: %w", sendErr)
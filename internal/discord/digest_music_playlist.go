@@ -0,0 +1,134 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+	"github.com/meriley/reddit-spy/internal/playlist"
+)
+
+// buildPlaylistCustomID is the component id on the music parent card. The
+// digest is resolved from the clicked message id, so the id carries no
+// payload — it's stable from the very first send, before the rolling_posts
+// row (and its id) exists.
+const buildPlaylistCustomID = "build_playlist"
+
+// musicCardComponents is the action row attached to every music parent
+// card: "Build playlist" and the feedback buttons.
+func musicCardComponents() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: append([]discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Build playlist",
+				Style:    discordgo.SecondaryButton,
+				CustomID: buildPlaylistCustomID,
+				Emoji:    &discordgo.ComponentEmoji{Name: "🎧"},
+			},
+		}, feedbackButtons()...)},
+	}
+}
+
+// buildDigestPlaylist converts a digest's stored entries into the playlist
+// package's format-neutral shape. YouTube Music is listed before Qobuz so
+// players that only honour the first location get the free stream.
+func buildDigestPlaylist(rp dbstore.RollingPost, entries []llm.MusicEntry, subredditNames []string) playlist.Playlist {
+	title := "music digest"
+	if joined := joinSubNames(subredditNames); joined != "" {
+		title = joined + " — music digest"
+	}
+	p := playlist.Playlist{
+		Title:      fmt.Sprintf("%s (%s)", title, rp.DayLocal.Format("2006-01-02")),
+		Annotation: "Exported from reddit-spy",
+		Created:    rp.UpdatedAt,
+		Tracks:     make([]playlist.Track, 0, len(entries)),
+	}
+	for _, e := range entries {
+		t := playlist.Track{Artist: e.Artist, Title: e.Title}
+		if e.YoutubeURL != "" {
+			t.Locations = append(t.Locations, e.YoutubeURL)
+		}
+		if e.QobuzURL != "" {
+			t.Locations = append(t.Locations, e.QobuzURL)
+		}
+		p.Tracks = append(p.Tracks, t)
+	}
+	return p
+}
+
+// handleBuildPlaylistButton answers a "Build playlist" click with an
+// ephemeral message carrying M3U, XSPF and JSPF attachments for the digest
+// the button is attached to.
+func (c *Client) handleBuildPlaylistButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Message == nil {
+		c.respondComponentError(s, i, "Couldn't tell which digest this button belongs to.")
+		return
+	}
+
+	rp, err := c.Bot.Store.GetRollingPostByMessageID(c.Ctx, i.Message.ID)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to load digest for playlist", "message_id", i.Message.ID, "error", err)
+		c.respondComponentError(s, i, "Failed to load this digest.")
+		return
+	}
+	if rp == nil || rp.Mode != dbstore.ModeMusic {
+		c.respondComponentError(s, i, "This digest is no longer available.")
+		return
+	}
+	ch, err := c.Bot.Store.GetDiscordChannel(c.Ctx, rp.ChannelID)
+	if err != nil || ch.ExternalID != i.ChannelID {
+		c.respondComponentError(s, i, "This digest belongs to another channel.")
+		return
+	}
+
+	entries, err := decodeMusicEntries(rp.Entries)
+	if err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to decode digest entries for playlist", "rolling_post_id", rp.ID, "error", err)
+		c.respondComponentError(s, i, "Failed to read this digest's releases.")
+		return
+	}
+	if len(entries) == 0 {
+		c.respondComponentError(s, i, "This digest has no releases yet.")
+		return
+	}
+
+	pl := buildDigestPlaylist(*rp, entries, c.resolveSubredditNames(c.Ctx, rp.SubredditIDs))
+	xspf, err := playlist.XSPF(pl)
+	if err != nil {
+		c.respondComponentError(s, i, "Failed to build the XSPF playlist.")
+		return
+	}
+	jspf, err := playlist.JSPF(pl)
+	if err != nil {
+		c.respondComponentError(s, i, "Failed to build the JSPF playlist.")
+		return
+	}
+
+	linked := 0
+	for _, t := range pl.Tracks {
+		if len(t.Locations) > 0 {
+			linked++
+		}
+	}
+	base := fmt.Sprintf("music-digest-%s", rp.DayLocal.Format("2006-01-02"))
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("Playlist for %d releases (%d with a playable link). "+
+				"The `.jspf` file imports into ListenBrainz.", len(pl.Tracks), linked),
+			Files: []*discordgo.File{
+				{Name: base + ".m3u", ContentType: "audio/x-mpegurl", Reader: bytes.NewReader(playlist.M3U(pl))},
+				{Name: base + ".xspf", ContentType: "application/xspf+xml", Reader: bytes.NewReader(xspf)},
+				{Name: base + ".jspf", ContentType: "application/json", Reader: bytes.NewReader(jspf)},
+			},
+		},
+	}); err != nil {
+		_ = level.Error(c.Ctx.Log()).Log("msg", "failed to respond with playlist", "error", err)
+	}
+}
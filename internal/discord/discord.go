@@ -14,6 +14,7 @@ import (
 	ctxpkg "github.com/meriley/reddit-spy/internal/context"
 	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/janitor"
 	"github.com/meriley/reddit-spy/internal/lastfm"
 	"github.com/meriley/reddit-spy/internal/llm"
 	"github.com/meriley/reddit-spy/internal/piped"
@@ -38,15 +39,16 @@ type Shaper interface {
 
 // MessageSender isolates the discordgo.Session methods SendMessage needs,
 // so tests can stub them without a live Discord connection. The thread-aware
-// methods (MessageThreadStart, ChannelEdit, ChannelMessageDelete) are used
-// by the music-mode B+C renderer — parent card in the channel, spill in a
-// per-digest thread.
+// methods (MessageThreadStart, ChannelEdit, ChannelMessageDelete,
+// ChannelDelete) are used by the music-mode B+C renderer — parent card in the
+// channel, spill in a per-digest thread.
 type MessageSender interface {
 	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
 	MessageThreadStart(channelID, messageID string, name string, archiveDuration int, options ...discordgo.RequestOption) (*discordgo.Channel, error)
 	ChannelEdit(channelID string, data *discordgo.ChannelEdit, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelDelete(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
 }
 
 // threadAutoArchiveMinutes is the 7-day auto-archive window Discord exposes.
@@ -95,10 +97,37 @@ type Client struct {
 	// so the digest can link titles to music.youtube.com. Optional.
 	piped *piped.Client
 
+	// llmRouter reports per-backend health for /status. Optional — nil when
+	// the LLM is disabled.
+	llmRouter *llm.Router
+
+	// llmCache reports completion-cache hit/miss counters for /status.
+	// Optional — nil when the cache is disabled.
+	llmCache *llm.CachedCompleter
+
+	// janitor reports retention prune counts for /status. Optional — nil
+	// when JANITOR_INTERVAL=0.
+	janitor *janitor.Janitor
+
 	// qobuz scrapes qobuz.com's public search page so a second link can be
 	// rendered alongside the YouTube one for Qobuz subscribers. Optional.
 	qobuz *qobuz.Client
 
+	// comments and articles feed a post's top comments and linked-article
+	// text into narrative prompts. Optional; commentLimit 0 disables
+	// comments.
+	comments     CommentFetcher
+	commentLimit int
+	articles     ArticleFetcher
+
+	// classifyTopics is the taxonomy classification rules are validated
+	// against. Empty accepts any topic.
+	classifyTopics []string
+
+	// embedder scores posts against semantic rules in /preview_digest and
+	// gates creating them. Optional.
+	embedder evaluator.Embedder
+
 	// loc is the tz used to compute dayLocal. Loaded once at New() time.
 	loc *time.Location
 
@@ -109,6 +138,27 @@ type Client struct {
 	// own window_hours column is 0 (shouldn't happen post-migration, but
 	// belt-and-braces). Configured from DIGEST_DEFAULT_WINDOW_HOURS env.
 	defaultWindowHours int
+
+	// streamEditInterval throttles progressive edits while a streaming LLM
+	// completion is in flight (LLM_STREAM). Only used when the shaper
+	// actually streams.
+	streamEditInterval time.Duration
+
+	// duplicateWindow bounds how far apart copies of a post can arrive and
+	// still fold into one narrative digest item. 0 disables it.
+	duplicateWindow time.Duration
+
+	// autoExcludeMinPosts, when positive, has a negative digest vote add
+	// the rule's suggested exclusion terms once a word shows up in that many
+	// disliked posts. 0 leaves suggestions to /rule_stats.
+	autoExcludeMinPosts int
+
+	// imports holds /import_config previews until they're applied.
+	imports pendingImports
+
+	// digestLocks keeps live matches and llm_jobs from updating a channel's
+	// digest at the same time.
+	digestLocks channelLocks
 }
 
 // effectiveWindowHours returns the rolling-digest window for a matched rule,
@@ -140,6 +190,24 @@ func WithShaperInterface(s Shaper) Option {
 	return func(c *Client) { c.shaper = s }
 }
 
+// WithLLMRouter exposes the shaper's backend router so /status can report
+// which LLM backends are healthy.
+func WithLLMRouter(r *llm.Router) Option {
+	return func(c *Client) { c.llmRouter = r }
+}
+
+// WithLLMCache exposes the completion cache so /status can report its
+// hit/miss counters.
+func WithLLMCache(cc *llm.CachedCompleter) Option {
+	return func(c *Client) { c.llmCache = cc }
+}
+
+// WithJanitor exposes the retention janitor so /status can report how many
+// rows it has pruned.
+func WithJanitor(j *janitor.Janitor) Option {
+	return func(c *Client) { c.janitor = j }
+}
+
 // WithSender overrides the default MessageSender (used by tests).
 func WithSender(m MessageSender) Option {
 	return func(c *Client) { c.sender = m }
@@ -168,6 +236,38 @@ func WithQobuz(q *qobuz.Client) Option {
 	return func(c *Client) { c.qobuz = q }
 }
 
+// WithComments makes narrative digests include up to limit top comments
+// per post. limit <= 0 leaves comments off.
+func WithComments(f CommentFetcher, limit int) Option {
+	return func(c *Client) {
+		if limit > 0 {
+			c.comments, c.commentLimit = f, limit
+		}
+	}
+}
+
+// WithArticles makes narrative digests of link posts include the linked
+// page's readable text.
+func WithArticles(a ArticleFetcher) Option {
+	return func(c *Client) { c.articles = a }
+}
+
+// WithClassifyTopics sets the topic taxonomy /add_rule and /edit_rule
+// accept in classification rules.
+func WithClassifyTopics(topics []string) Option {
+	return func(c *Client) { c.classifyTopics = topics }
+}
+
+// WithEmbedder enables semantic rules in /add_subreddit_listener and their
+// similarity score in /preview_digest.
+func WithEmbedder(em *llm.Embedder) Option {
+	return func(c *Client) {
+		if em != nil {
+			c.embedder = em
+		}
+	}
+}
+
 // WithDefaultWindowHours sets the fallback rolling-digest window length
 // applied when a rule's own window_hours column is 0. Defaults to 72h.
 func WithDefaultWindowHours(h int) Option {
@@ -178,6 +278,16 @@ func WithDefaultWindowHours(h int) Option {
 	}
 }
 
+// WithStreamEditInterval sets the minimum gap between progressive Discord
+// edits while an LLM completion streams in. Defaults to 3s.
+func WithStreamEditInterval(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.streamEditInterval = d
+		}
+	}
+}
+
 func New(ctx ctxpkg.Ctx, bot *redditDiscordBot.RedditDiscordBot, opts ...Option) (*Client, error) {
 	token := os.Getenv("DISCORD_TOKEN")
 	if token == "" {
@@ -188,7 +298,7 @@ func New(ctx ctxpkg.Ctx, bot *redditDiscordBot.RedditDiscordBot, opts ...Option)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create discord session: %w", err)
 	}
-	dg.Identify.Intents = discordgo.IntentsGuilds
+	dg.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessageReactions
 	dg.ShouldReconnectOnError = true
 	dg.ShouldRetryOnRateLimit = true
 	dg.StateEnabled = true
@@ -211,6 +321,8 @@ func New(ctx ctxpkg.Ctx, bot *redditDiscordBot.RedditDiscordBot, opts ...Option)
 		loc:                loc,
 		now:                time.Now,
 		defaultWindowHours: 72,
+		streamEditInterval: defaultStreamEditInterval,
+		duplicateWindow:    defaultDuplicateWindow,
 	}
 	for _, opt := range opts {
 		opt(client)
@@ -233,6 +345,8 @@ func NewForTest(ctx ctxpkg.Ctx, bot *redditDiscordBot.RedditDiscordBot, opts ...
 		loc:                loc,
 		now:                time.Now,
 		defaultWindowHours: 72,
+		streamEditInterval: defaultStreamEditInterval,
+		duplicateWindow:    defaultDuplicateWindow,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -258,10 +372,23 @@ func (c *Client) RegisterCommands() error {
 		c.listRulesCommandConfig(),
 		c.deleteRuleCommandConfig(),
 		c.editRuleCommandConfig(),
+		c.pauseRuleCommandConfig(),
+		c.resumeRuleCommandConfig(),
+		c.snoozeRuleCommandConfig(),
 		c.pingCommandConfig(),
 		c.statusCommandConfig(),
 		c.helpCommandConfig(),
 		c.previewCommandConfig(),
+		c.retryFailedCommandConfig(),
+		c.digestHistoryCommandConfig(),
+		c.digestRerenderCommandConfig(),
+		c.digestCloseCommandConfig(),
+		c.digestReopenCommandConfig(),
+		c.digestMergeCommandConfig(),
+		c.ruleStatsCommandConfig(),
+		c.setPromptCommandConfig(),
+		c.exportConfigCommandConfig(),
+		c.importConfigCommandConfig(),
 	}
 
 	commandHandlers := make(map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate))
@@ -283,15 +410,30 @@ func (c *Client) RegisterCommands() error {
 			c.componentHandler(s, i)
 		}
 	})
+	c.Client.AddHandler(c.handleFeedbackReaction)
 
 	return nil
 }
 
+// digestDayLocal is used only for new-digest footer rendering. For an
+// existing window we carry the original opening-day value so the footer
+// date stays stable across same-window updates.
+func (c *Client) digestDayLocal(existing *dbstore.RollingPost) time.Time {
+	if existing != nil {
+		return existing.DayLocal
+	}
+	phoenix := c.now().In(c.loc)
+	return time.Date(phoenix.Year(), phoenix.Month(), phoenix.Day(), 0, 0, 0, 0, time.UTC)
+}
+
 // SendMessage handles a rule-match event by either sending a fresh rolling
 // digest for the (channel, subreddit, day) triple or editing the existing one
 // in place. Dedupe on (post, channel, rule) still applies — a given Reddit
-// post can only contribute to the digest once.
+// post can only contribute to the digest once. A match in its rule's quiet
+// hours is queued and delivered when they end.
 func (c *Client) SendMessage(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult) error {
+	defer c.digestLocks.lock(result.ChannelID)()
+
 	count, err := c.Bot.Store.GetNotificationCount(ctx, result.PostID, result.ChannelID, result.RuleID)
 	if err != nil {
 		return fmt.Errorf("unable to get notification count: %w", err)
@@ -299,7 +441,15 @@ func (c *Client) SendMessage(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluatio
 	if count > 0 {
 		return nil
 	}
+	if held, err := c.holdForQuietHours(ctx, result); held || err != nil {
+		return err
+	}
+	return c.deliverMatch(ctx, result)
+}
 
+// deliverMatch folds a match into its channel's digest. It's SendMessage
+// past the dedupe check, shared with the deliver_match job.
+func (c *Client) deliverMatch(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult) error {
 	ch, err := c.Bot.Store.GetDiscordChannel(ctx, result.ChannelID)
 	if err != nil {
 		return fmt.Errorf("failed to get discord channel for id %d: %w", result.ChannelID, err)
@@ -323,16 +473,7 @@ func (c *Client) SendMessage(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluatio
 		return fmt.Errorf("failed to fetch active rolling post: %w", err)
 	}
 
-	// dayLocal is now used only for new-digest footer rendering. For an
-	// existing window we carry the original opening-day value so the footer
-	// date stays stable across same-window updates.
-	var dayLocal time.Time
-	if existing != nil {
-		dayLocal = existing.DayLocal
-	} else {
-		phoenix := c.now().In(c.loc)
-		dayLocal = time.Date(phoenix.Year(), phoenix.Month(), phoenix.Day(), 0, 0, 0, 0, time.UTC)
-	}
+	dayLocal := c.digestDayLocal(existing)
 
 	// Dispatch by rule.Mode (already resolved above for the active-row lookup).
 	switch mode {
@@ -340,97 +481,155 @@ func (c *Client) SendMessage(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluatio
 		return c.handleMusicMatch(ctx, existing, result, ch, subreddit, dayLocal)
 	}
 
-	var (
-		title   string
-		summary string
-	)
-
-	if existing == nil {
-		title, summary = c.freshNarrative(ctx, result)
-	} else {
-		title, summary = c.updateNarrative(ctx, existing, result)
+	// A crosspost or copy of a post already in the digest joins that
+	// item instead of being narrated again.
+	items := c.digestItems(ctx, existing)
+	if dup, reason := c.findDuplicate(items, result.Post); dup != nil {
+		return c.foldDuplicate(ctx, existing, items, dup, reason, result, ch, subreddit)
 	}
-
-	rp := buildRollingPostRow(existing, result, ch, subreddit, dayLocal, title, summary)
-	embed := buildDigestEmbed(rp, result, subreddit.ExternalID)
+	groups := groupDigestItems(items)
 
 	primaryExisting := ""
 	if existing != nil && len(existing.DiscordMessageIDs) > 0 {
 		primaryExisting = existing.DiscordMessageIDs[0]
 	}
 
-	if primaryExisting == "" {
-		msg, sendErr := c.sender.ChannelMessageSendComplex(ch.ExternalID, &discordgo.MessageSend{
-			Embeds: []*discordgo.MessageEmbed{embed},
-		})
-		if sendErr != nil {
-			return fmt.Errorf("failed to send message: %w", sendErr)
-		}
-		rp.DiscordMessageIDs = []string{msg.ID}
-	} else {
-		edited, editErr := c.sender.ChannelMessageEditComplex(&discordgo.MessageEdit{
-			Channel: ch.ExternalID,
-			ID:      primaryExisting,
-			Embeds:  &[]*discordgo.MessageEmbed{embed},
-		})
-		if isMessageGone(editErr) {
-			_ = level.Warn(ctx.Log()).Log(
-				"msg", "rolling digest message missing, sending a fresh one",
-				"channel", ch.ExternalID, "message_id", primaryExisting,
-			)
-			msg, sendErr := c.sender.ChannelMessageSendComplex(ch.ExternalID, &discordgo.MessageSend{
-				Embeds: []*discordgo.MessageEmbed{embed},
-			})
-			if sendErr != nil {
-				return fmt.Errorf("fallback send after edit-404 failed: %w", sendErr)
+	d := c.discussion(ctx, result.Post)
+
+	stream := &narrativeStream{
+		c:         c,
+		ctx:       ctx,
+		channelID: ch.ExternalID,
+		messageID: primaryExisting,
+		throttle:  c.newProgressThrottle(),
+		render: func(out llm.Output) *discordgo.MessageEmbed {
+			title := out.Title
+			if title == "" && existing != nil {
+				title = existing.NarrativeTitle
 			}
-			rp.DiscordMessageIDs = []string{msg.ID}
-		} else if editErr != nil {
-			return fmt.Errorf("failed to edit rolling digest: %w", editErr)
-		} else {
-			rp.DiscordMessageIDs = []string{edited.ID}
-		}
+			if title == "" {
+				title = result.Post.Title
+			}
+			partial := buildRollingPostRow(existing, result, ch, subreddit, dayLocal, title, out.Summary)
+			partial.LatestThumbnail = d.thumbnail(result.Post)
+			embed := buildDigestEmbed(partial, result, subreddit.ExternalID)
+			addSourcesField(embed, groups)
+			return embed
+		},
+	}
+
+	var out llm.Output
+	if existing == nil {
+		out = c.freshNarrative(ctx, result, d, stream.progress)
+	} else {
+		out = c.updateNarrative(ctx, existing, result, d, stream.progress)
+	}
+	// A progressive frame may have created the digest message already; the
+	// final render edits it rather than posting a second one.
+	primaryExisting = stream.messageID
+
+	rp := buildRollingPostRow(existing, result, ch, subreddit, dayLocal, out.Title, out.Summary)
+	rp.LatestThumbnail = d.thumbnail(result.Post)
+	embed := buildDigestEmbed(rp, result, subreddit.ExternalID)
+	addOriginalTitle(embed, out)
+	addSourcesField(embed, groups)
+
+	ids, err := c.publishDigestEmbed(ctx, ch.ExternalID, primaryExisting, embed)
+	if err != nil {
+		return err
 	}
+	rp.DiscordMessageIDs = ids
 
 	if len(rp.DiscordMessageIDs) == 0 || rp.DiscordMessageIDs[0] == "" {
 		// Defensive: every reachable branch above populates DiscordMessageIDs[0].
 		return fmt.Errorf("refusing to upsert rolling_posts with empty discord_message_ids (channel=%d, sub=%d, day=%s)",
 			rp.ChannelID, rp.SubredditID, rp.DayLocal.Format("2006-01-02"))
 	}
-	if _, err := c.Bot.Store.UpsertRollingPost(ctx, rp); err != nil {
+	saved, err := c.Bot.Store.UpsertRollingPost(ctx, rp)
+	if err != nil {
 		return fmt.Errorf("failed to upsert rolling post: %w", err)
 	}
+	c.recordDigestItem(ctx, digestItem(saved.ID, result.Post))
 	if _, err := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); err != nil {
 		return fmt.Errorf("failed to insert notification into database: %w", err)
 	}
 	return nil
 }
 
+// publishDigestEmbed edits the digest message primary, or sends a new one
+// when there is none yet or it was deleted, and returns the digest's
+// message ids.
+func (c *Client) publishDigestEmbed(ctx ctxpkg.Ctx, channelID, primary string, embed *discordgo.MessageEmbed) ([]string, error) {
+	components := narrativeDigestComponents()
+	if primary == "" {
+		msg, sendErr := c.sender.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		})
+		if sendErr != nil {
+			return nil, fmt.Errorf("failed to send message: %w", sendErr)
+		}
+		return []string{msg.ID}, nil
+	}
+	edited, editErr := c.sender.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    channelID,
+		ID:         primary,
+		Embeds:     &[]*discordgo.MessageEmbed{embed},
+		Components: &components,
+	})
+	if isMessageGone(editErr) {
+		_ = level.Warn(ctx.Log()).Log(
+			"msg", "rolling digest message missing, sending a fresh one",
+			"channel", channelID, "message_id", primary,
+		)
+		msg, sendErr := c.sender.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		})
+		if sendErr != nil {
+			return nil, fmt.Errorf("fallback send after edit-404 failed: %w", sendErr)
+		}
+		return []string{msg.ID}, nil
+	}
+	if editErr != nil {
+		return nil, fmt.Errorf("failed to edit rolling digest: %w", editErr)
+	}
+	return []string{edited.ID}, nil
+}
+
 // freshNarrative tries the LLM; on any failure it falls back to the raw
-// truncated selftext so matches are never silently dropped.
-func (c *Client) freshNarrative(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult) (string, string) {
+// truncated selftext so matches are never silently dropped. progress
+// receives partial output when the shaper streams.
+func (c *Client) freshNarrative(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult, d discussion, progress func(llm.Output)) llm.Output {
+	raw := llm.Output{Title: result.Post.Title, Summary: rawSelftext(d.rawBody(result.Post))}
 	if c.shaper == nil {
-		return result.Post.Title, rawSelftext(result.Post.Selftext)
+		return raw
 	}
 	out, err := c.shaper.ShapeFresh(ctx, llm.FreshInput{
 		Post:         result.Post,
 		RuleID:       result.RuleID,
 		RuleTargetID: result.Rule.TargetID,
 		RuleExact:    result.Rule.Exact,
+		Prompt:       c.promptOptions(ctx, result, llm.PromptFresh),
+		Comments:     d.Comments,
+		Article:      d.articleText(),
+		Image:        postImage(result.Post),
+		Progress:     progress,
 	})
 	if err != nil {
 		_ = level.Warn(ctx.Log()).Log("msg", "llm fresh shape failed; falling back to raw", "error", err)
-		return result.Post.Title, rawSelftext(result.Post.Selftext)
+		return raw
 	}
-	return out.Title, out.Summary
+	return out
 }
 
 // updateNarrative tries the LLM Update mode; on failure it preserves the
 // prior narrative unchanged so a transient vLLM hiccup doesn't clobber the
 // existing digest body.
-func (c *Client) updateNarrative(ctx ctxpkg.Ctx, existing *dbstore.RollingPost, result *evaluator.MatchingEvaluationResult) (string, string) {
+func (c *Client) updateNarrative(ctx ctxpkg.Ctx, existing *dbstore.RollingPost, result *evaluator.MatchingEvaluationResult, d discussion, progress func(llm.Output)) llm.Output {
+	prior := llm.Output{Title: existing.NarrativeTitle, Summary: existing.NarrativeSummary}
 	if c.shaper == nil {
-		return existing.NarrativeTitle, existing.NarrativeSummary
+		return prior
 	}
 	out, err := c.shaper.ShapeUpdate(ctx, llm.UpdateInput{
 		PriorTitle:      existing.NarrativeTitle,
@@ -440,12 +639,17 @@ func (c *Client) updateNarrative(ctx ctxpkg.Ctx, existing *dbstore.RollingPost,
 		NewRuleID:       result.RuleID,
 		NewRuleTargetID: result.Rule.TargetID,
 		NewRuleExact:    result.Rule.Exact,
+		Prompt:          c.promptOptions(ctx, result, llm.PromptUpdate),
+		NewComments:     d.Comments,
+		NewArticle:      d.articleText(),
+		NewImage:        postImage(result.Post),
+		Progress:        progress,
 	})
 	if err != nil {
 		_ = level.Warn(ctx.Log()).Log("msg", "llm update shape failed; keeping prior narrative", "error", err)
-		return existing.NarrativeTitle, existing.NarrativeSummary
+		return prior
 	}
-	return out.Title, out.Summary
+	return out
 }
 
 // buildRollingPostRow constructs the next rolling_posts row from the existing
@@ -524,6 +728,19 @@ func buildDigestEmbed(rp dbstore.RollingPost, result *evaluator.MatchingEvaluati
 	return embed
 }
 
+// addOriginalTitle shows the latest post's own title under a digest that
+// was written in another language.
+func addOriginalTitle(embed *discordgo.MessageEmbed, out llm.Output) {
+	if out.OriginalTitle == "" {
+		return
+	}
+	name := "Original title"
+	if out.SourceLanguage != "" {
+		name = fmt.Sprintf("Original title (%s)", truncateUTF8(out.SourceLanguage, 40))
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: truncateUTF8(out.OriginalTitle, 1024)})
+}
+
 func buildFooter(rp dbstore.RollingPost) string {
 	return fmt.Sprintf("%d matches • rules: %s • %s",
 		len(rp.IncludedPostIDs),
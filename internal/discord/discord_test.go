@@ -1,6 +1,13 @@
 package discord
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+)
 
 func TestTruncateUTF8(t *testing.T) {
 	tests := []struct {
@@ -28,3 +35,43 @@ func TestTruncateUTF8(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildDigestPlaylist(t *testing.T) {
+	rp := dbstore.RollingPost{DayLocal: time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC)}
+	entries := []llm.MusicEntry{
+		{Artist: "Wage War", Title: "Stigma", YoutubeURL: "https://music.youtube.com/a", QobuzURL: "https://www.qobuz.com/b"},
+		{Artist: "Thrown", Title: "Split", QobuzURL: "https://www.qobuz.com/c"},
+		{Artist: "Nobody", Title: "Unlinked"},
+	}
+	p := buildDigestPlaylist(rp, entries, []string{"Metalcore"})
+	if p.Title != "r/Metalcore — music digest (2026-04-16)" {
+		t.Errorf("title = %q", p.Title)
+	}
+	if len(p.Tracks) != 3 {
+		t.Fatalf("tracks = %d, want every entry", len(p.Tracks))
+	}
+	if got := p.Tracks[0].Locations; len(got) != 2 || got[0] != "https://music.youtube.com/a" {
+		t.Errorf("YouTube should lead the locations: %v", got)
+	}
+	if got := p.Tracks[1].Locations; len(got) != 1 || got[0] != "https://www.qobuz.com/c" {
+		t.Errorf("Qobuz-only locations = %v", got)
+	}
+	if len(p.Tracks[2].Locations) != 0 {
+		t.Errorf("unlinked entry should have no locations")
+	}
+}
+
+func TestPendingImports_TakeChecksOwnerFirst(t *testing.T) {
+	var p pendingImports
+	token := p.add(pendingImport{guildID: "g1", userID: "u1", expires: time.Now().Add(time.Minute)})
+
+	if _, err := p.take(token, "g1", "u2"); !errors.Is(err, errImportNotYours) {
+		t.Fatalf("take by another member: err = %v, want errImportNotYours", err)
+	}
+	if _, err := p.take(token, "g1", "u1"); err != nil {
+		t.Fatalf("take by the owner after someone else tried: %v", err)
+	}
+	if _, err := p.take(token, "g1", "u1"); !errors.Is(err, errImportExpired) {
+		t.Errorf("second take: err = %v, want errImportExpired", err)
+	}
+}
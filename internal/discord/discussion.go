@@ -0,0 +1,161 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/meriley/reddit-spy/internal/article"
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+	"github.com/meriley/reddit-spy/internal/reddit"
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// discussionTimeout bounds the comment and article fetches for one
+	// narrative, so a slow news site can't hold up the digest.
+	discussionTimeout = 10 * time.Second
+	// articleCacheTTL is how long an article_cache row is served before the
+	// page is fetched again.
+	articleCacheTTL = 7 * 24 * time.Hour
+)
+
+// CommentFetcher is the slice of reddit.SpoofClient narrative digests use
+// to read a post's top comments.
+type CommentFetcher interface {
+	GetPostComments(ctx context.Context, postID string, limit int) ([]*reddit.Comment, error)
+}
+
+// ArticleFetcher extracts a linked page. *article.Client satisfies it.
+type ArticleFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*article.Article, error)
+}
+
+// discussion is the material a narrative digest gets beyond the post
+// itself.
+type discussion struct {
+	Comments []llm.Comment
+	// Article is the linked page of a link post; nil for self posts, media
+	// links and failed fetches.
+	Article *dbstore.CachedArticle
+}
+
+// articleText is the linked page's body, or its description when the
+// extractor found no body (Bandcamp and other app-like pages).
+func (d discussion) articleText() string {
+	if d.Article == nil {
+		return ""
+	}
+	if d.Article.Body != "" {
+		return d.Article.Body
+	}
+	return d.Article.Description
+}
+
+// thumbnail returns the embed thumbnail for post: Reddit's own when it's a
+// real image URL, else the linked page's og:image. Reddit reports
+// "default", "self", "nsfw" and the like for posts without one.
+func (d discussion) thumbnail(post *redditJSON.RedditPost) string {
+	if _, err := url.ParseRequestURI(post.Thumbnail); err == nil {
+		return post.Thumbnail
+	}
+	if d.Article != nil && d.Article.ImageURL != "" {
+		return d.Article.ImageURL
+	}
+	return post.Thumbnail
+}
+
+// discussion gathers the post's top comments (only when there's a shaper
+// to read them) and, for link posts, the linked article. Either fetch
+// failing is logged and skipped — the narrative just has less to go on.
+func (c *Client) discussion(ctx ctxpkg.Ctx, post *redditJSON.RedditPost) discussion {
+	fctx, cancel := context.WithTimeout(ctx, discussionTimeout)
+	defer cancel()
+
+	var d discussion
+	if c.shaper != nil && c.comments != nil && c.commentLimit > 0 && post.ID != "" {
+		got, err := c.comments.GetPostComments(fctx, post.ID, c.commentLimit)
+		if err != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to fetch post comments", "post", post.ID, "error", err)
+		}
+		for _, cm := range got {
+			d.Comments = append(d.Comments, llm.Comment{Author: cm.Author, Body: cm.Body, Score: cm.Score})
+		}
+	}
+	if c.articles != nil && post.Selftext == "" && article.Fetchable(post.URL) {
+		d.Article = c.linkedArticle(ctx, fctx, post.URL)
+	}
+	return d
+}
+
+// linkedArticle serves rawURL from article_cache, fetching and caching it
+// when missing or stale. Non-HTML links are cached as an empty row so they
+// aren't refetched; other failures aren't cached.
+func (c *Client) linkedArticle(ctx ctxpkg.Ctx, fctx context.Context, rawURL string) *dbstore.CachedArticle {
+	cached, err := c.Bot.Store.GetCachedArticle(fctx, rawURL)
+	if err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "article cache lookup failed", "url", rawURL, "error", err)
+	}
+	if cached != nil && c.now().Sub(cached.FetchedAt) < articleCacheTTL {
+		return cached
+	}
+
+	row := dbstore.CachedArticle{URL: rawURL}
+	art, err := c.articles.Fetch(fctx, rawURL)
+	switch {
+	case errors.Is(err, article.ErrNotHTML):
+	case err != nil:
+		_ = level.Warn(ctx.Log()).Log("msg", "failed to fetch linked article", "url", rawURL, "error", err)
+		return cached
+	default:
+		row.Title, row.Description, row.ImageURL, row.Body = art.Title, art.Description, art.Image, art.Text
+	}
+	if err := c.Bot.Store.UpsertCachedArticle(fctx, row); err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "article cache upsert failed", "url", rawURL, "error", err)
+	}
+	return &row
+}
+
+// rawBody is the body of the raw (unshaped) digest: the post's selftext,
+// or for a link post the linked article's text.
+func (d discussion) rawBody(post *redditJSON.RedditPost) string {
+	if post.Selftext != "" {
+		return post.Selftext
+	}
+	return d.articleText()
+}
+
+// postImage returns the image to show a vision model for an image post:
+// Reddit's preview rendition, or the post URL itself when it's a direct
+// image link without one. "" for every other kind of post.
+func postImage(post *redditJSON.RedditPost) string {
+	if post.PostHint != "image" && !isImageURL(post.URL) {
+		return ""
+	}
+	if u := post.Preview.ImageURL(); u != "" {
+		return u
+	}
+	if isImageURL(post.URL) {
+		return post.URL
+	}
+	return ""
+}
+
+func isImageURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/llm"
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// jobMaxAttempts caps retries of one queued extraction. With
+	// jobBackoffBase doubling up to jobBackoffMax, six attempts span roughly
+	// half an hour — long enough to ride out a vLLM pod restart or rollout.
+	jobMaxAttempts = 6
+	jobBackoffBase = time.Minute
+	jobBackoffMax  = 30 * time.Minute
+	// jobClaimBatch bounds how many jobs one RetryDueJobs tick runs, so a
+	// backlog after an outage doesn't hold a channel's lock for long.
+	jobClaimBatch = 3
+)
+
+// musicJobPayload is llm_jobs.payload for music_extract jobs. The post is
+// snapshotted because it'll have scrolled out of the poller's listing by the
+// time a retry runs.
+type musicJobPayload struct {
+	Post *redditJSON.RedditPost `json:"post"`
+}
+
+// jobBackoff is the delay before the next attempt after the attempts-th
+// failure: base, 2×base, 4×base, … capped at jobBackoffMax.
+func jobBackoff(attempts int) time.Duration {
+	d := jobBackoffBase
+	for i := 1; i < attempts && d < jobBackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, jobBackoffMax)
+}
+
+// enqueueMusicJob queues result's post for another extraction attempt after
+// the first one (cause) failed inline.
+func (c *Client) enqueueMusicJob(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult, cause error) error {
+	payload, err := json.Marshal(musicJobPayload{Post: result.Post})
+	if err != nil {
+		return fmt.Errorf("encode music job payload: %w", err)
+	}
+	_, err = c.Bot.Store.EnqueueLLMJob(ctx, dbstore.LLMJob{
+		Kind:          dbstore.JobKindMusicExtract,
+		ChannelID:     result.ChannelID,
+		RuleID:        result.RuleID,
+		PostID:        result.PostID,
+		Payload:       payload,
+		MaxAttempts:   jobMaxAttempts,
+		NextAttemptAt: c.now().Add(jobBackoff(1)),
+		LastError:     cause.Error(),
+	})
+	return err
+}
+
+// channelLocks serializes digest updates per channel, so a job running on
+// its own goroutine never races a live match for the same digest.
+type channelLocks struct {
+	mu    sync.Mutex
+	locks map[int]*sync.Mutex
+}
+
+// lock blocks until channelID is free and returns the unlock func.
+func (l *channelLocks) lock(channelID int) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[int]*sync.Mutex{}
+	}
+	m, ok := l.locks[channelID]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[channelID] = m
+	}
+	l.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+// RetryDueJobs claims due llm_jobs and runs them. main calls it from a
+// worker goroutine rather than the live match loop, since one job can make
+// several LLM calls; each job holds its channel's lock, as SendMessage does.
+func (c *Client) RetryDueJobs(ctx ctxpkg.Ctx) {
+	jobs, err := c.Bot.Store.ClaimDueLLMJobs(ctx, jobClaimBatch)
+	if err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "failed to claim llm jobs", "error", err)
+		return
+	}
+	// A retry must reach the model: a cached answer is what failed last time.
+	jobCtx := ctxpkg.WithContext(ctx, llm.WithoutCache(ctx))
+	for _, job := range jobs {
+		c.runJob(jobCtx, job)
+	}
+}
+
+func (c *Client) runJob(ctx ctxpkg.Ctx, job *dbstore.LLMJob) {
+	defer c.digestLocks.lock(job.ChannelID)()
+
+	var err error
+	switch job.Kind {
+	case dbstore.JobKindMusicExtract:
+		err = c.runMusicJob(ctx, job)
+	case dbstore.JobKindDeliverMatch:
+		err = c.runDeliverJob(ctx, job)
+	default:
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	if err == nil {
+		if cerr := c.Bot.Store.CompleteLLMJob(ctx, job.ID); cerr != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "failed to mark llm job done", "job", job.ID, "error", cerr)
+		}
+		_ = level.Info(ctx.Log()).Log("msg", "llm job succeeded", "job", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+		return
+	}
+
+	var retryAt time.Time
+	if job.Attempts < job.MaxAttempts {
+		retryAt = c.now().Add(jobBackoff(job.Attempts))
+	}
+	_ = level.Warn(ctx.Log()).Log("msg", "llm job failed", "job", job.ID, "kind", job.Kind,
+		"attempt", job.Attempts, "max_attempts", job.MaxAttempts, "retry_at", retryAt, "error", err)
+	if ferr := c.Bot.Store.FailLLMJob(ctx, job.ID, err.Error(), retryAt); ferr != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "failed to record llm job failure", "job", job.ID, "error", ferr)
+	}
+}
+
+// runMusicJob re-runs extraction for a queued post and folds the entries
+// into the channel's current music digest — which may be a newer window than
+// the one open when the post first matched.
+func (c *Client) runMusicJob(ctx ctxpkg.Ctx, job *dbstore.LLMJob) error {
+	if c.shaper == nil {
+		return fmt.Errorf("music extraction needs an LLM shaper")
+	}
+	var payload musicJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.Post == nil {
+		return fmt.Errorf("decode music job payload: %v", err)
+	}
+	rule, err := c.jobRule(ctx, job)
+	if err != nil {
+		return err
+	}
+	ch, err := c.Bot.Store.GetDiscordChannel(ctx, job.ChannelID)
+	if err != nil {
+		return err
+	}
+	subreddit, err := c.Bot.Store.GetSubredditByExternalID(ctx, payload.Post.Subreddit)
+	if err != nil {
+		return err
+	}
+	existing, err := c.Bot.Store.GetActiveRollingPost(ctx, job.ChannelID, dbstore.ModeMusic, effectiveWindowHours(rule, c.defaultWindowHours))
+	if err != nil {
+		return fmt.Errorf("fetch active rolling post: %w", err)
+	}
+	result := &evaluator.MatchingEvaluationResult{
+		ChannelID: job.ChannelID,
+		RuleID:    job.RuleID,
+		PostID:    job.PostID,
+		Post:      payload.Post,
+		Rule:      rule,
+	}
+	return c.applyMusicMatch(ctx, existing, result, ch, subreddit, c.digestDayLocal(existing))
+}
+
+// jobRule loads job's rule as the evaluator would have handed it over.
+func (c *Client) jobRule(ctx ctxpkg.Ctx, job *dbstore.LLMJob) (*dbstore.Rule, error) {
+	detail, err := c.Bot.Store.GetRuleByID(ctx, job.RuleID)
+	if err != nil {
+		return nil, err
+	}
+	if detail == nil {
+		return nil, fmt.Errorf("rule %d not found", job.RuleID)
+	}
+	return &dbstore.Rule{
+		ID:               detail.ID,
+		Target:           detail.Target,
+		Exact:            detail.Exact,
+		TargetID:         detail.TargetID,
+		Mode:             detail.Mode,
+		WindowHours:      detail.WindowHours,
+		Threshold:        detail.Threshold,
+		ExcludeTerms:     detail.ExcludeTerms,
+		Disabled:         detail.Disabled,
+		PausedUntil:      detail.PausedUntil,
+		QuietHours:       detail.QuietHours,
+		DiscordServerID:  detail.ServerID,
+		DiscordChannelID: job.ChannelID,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package discord
+
+import (
+	"context"
+
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/llm"
+)
+
+// defaultTemplateName selects the built-in prompts; assigning it clears a
+// rule's or channel's template override.
+const defaultTemplateName = "default"
+
+type templateOverrideKey struct{}
+
+// withTemplateOverride makes shaping under ctx use the named template
+// instead of the rule's or channel's. /preview_digest template:<name> uses
+// it to try a template before assigning it.
+func withTemplateOverride(ctx ctxpkg.Ctx, name string) ctxpkg.Ctx {
+	return ctxpkg.WithContext(ctx, context.WithValue(ctx, templateOverrideKey{}, name))
+}
+
+// promptOptions resolves the template body and tone preset for result's
+// rule: rule override, then channel override, then the built-ins and
+// LLM_TONE. The target language is the channel's. Store errors and names that don't define kind fall back to the
+// built-in prompt, so a template problem never blocks a digest.
+func (c *Client) promptOptions(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult, kind llm.PromptKind) llm.PromptOptions {
+	settings, err := c.Bot.Store.GetPromptSettings(ctx, result.ChannelID, result.RuleID)
+	if err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "failed to load prompt settings; using defaults", "rule", result.RuleID, "error", err)
+	}
+	opts := llm.PromptOptions{Tone: settings.Tone, Language: settings.Language}
+
+	name := settings.Template
+	if override, ok := ctx.Value(templateOverrideKey{}).(string); ok {
+		name = override
+	}
+	if name == "" || name == defaultTemplateName {
+		return opts
+	}
+	tmpl, err := c.Bot.Store.GetPromptTemplate(ctx, name, string(kind))
+	if err != nil {
+		_ = level.Warn(ctx.Log()).Log("msg", "failed to load prompt template; using built-in", "template", name, "kind", kind, "error", err)
+		return opts
+	}
+	if tmpl != nil {
+		opts.Template = tmpl.Body
+	}
+	return opts
+}
@@ -0,0 +1,88 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/quiethours"
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// deliverJobPayload is llm_jobs.payload for deliver_match jobs: the post as
+// it matched, since it may be gone from the listing when quiet hours end.
+type deliverJobPayload struct {
+	Post *redditJSON.RedditPost `json:"post"`
+}
+
+// holdForQuietHours queues result for delivery at the end of its rule's
+// quiet hours when they're in effect, reporting whether it did. Quiet hours
+// are read in the bot's timezone.
+func (c *Client) holdForQuietHours(ctx ctxpkg.Ctx, result *evaluator.MatchingEvaluationResult) (bool, error) {
+	if result.Rule == nil || result.Rule.QuietHours == "" {
+		return false, nil
+	}
+	w, err := quiethours.Parse(result.Rule.QuietHours)
+	if err != nil {
+		// Commands validate quiet hours, so this is a hand-edited row;
+		// delivering beats holding matches forever.
+		_ = level.Warn(ctx.Log()).Log("msg", "ignoring invalid quiet hours", "rule_id", result.RuleID, "error", err)
+		return false, nil
+	}
+	now := c.now().In(c.loc)
+	if !w.Contains(now) {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(deliverJobPayload{Post: result.Post})
+	if err != nil {
+		return false, fmt.Errorf("encode deliver job payload: %w", err)
+	}
+	until := w.End(now)
+	if _, err := c.Bot.Store.EnqueueLLMJob(ctx, dbstore.LLMJob{
+		Kind:          dbstore.JobKindDeliverMatch,
+		ChannelID:     result.ChannelID,
+		RuleID:        result.RuleID,
+		PostID:        result.PostID,
+		Payload:       payload,
+		MaxAttempts:   jobMaxAttempts,
+		NextAttemptAt: until,
+	}); err != nil {
+		return false, fmt.Errorf("failed to queue match for after quiet hours: %w", err)
+	}
+	// As with a queued music retry, the notification stops the poll loop
+	// from queueing the post again; the job owns delivery from here.
+	if _, err := c.Bot.Store.InsertNotification(ctx, result.PostID, result.ChannelID, result.RuleID); err != nil {
+		return false, fmt.Errorf("insert notification for held match: %w", err)
+	}
+	_ = level.Info(ctx.Log()).Log("msg", "match held for quiet hours", "rule_id", result.RuleID, "post", result.Post.ID, "until", until)
+	return true, nil
+}
+
+// runDeliverJob delivers a match held for quiet hours. A rule paused or
+// snoozed since then drops it, as the evaluator would have.
+func (c *Client) runDeliverJob(ctx ctxpkg.Ctx, job *dbstore.LLMJob) error {
+	var payload deliverJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil || payload.Post == nil {
+		return fmt.Errorf("decode deliver job payload: %v", err)
+	}
+	rule, err := c.jobRule(ctx, job)
+	if err != nil {
+		return err
+	}
+	if rule.Paused(c.now()) {
+		_ = level.Info(ctx.Log()).Log("msg", "dropping held match for paused rule", "rule_id", rule.ID, "post", payload.Post.ID)
+		return nil
+	}
+	return c.deliverMatch(ctx, &evaluator.MatchingEvaluationResult{
+		ChannelID: job.ChannelID,
+		RuleID:    job.RuleID,
+		PostID:    job.PostID,
+		Post:      payload.Post,
+		Rule:      rule,
+	})
+}
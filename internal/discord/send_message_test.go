@@ -5,200 +5,108 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/meriley/reddit-spy/internal/article"
 
 	ctxpkg "github.com/meriley/reddit-spy/internal/context"
 	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	"github.com/meriley/reddit-spy/internal/evaluator"
 	"github.com/meriley/reddit-spy/internal/llm"
+	"github.com/meriley/reddit-spy/internal/reddit"
 	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
 	"github.com/meriley/reddit-spy/redditDiscordBot"
 )
 
 // ---------- fakes ----------
 
+// fakeStore is a dbstore.MemStore seeded with channel 1 and the Metalcore
+// subreddit, counting the writes SendMessage makes.
 type fakeStore struct {
-	mu                sync.Mutex
-	notificationCount map[string]int
-	rolling           []*dbstore.RollingPost
-	nextRollingID     int
-	nowFn             func() time.Time // matches the Client's injected clock when set
-	channels          map[int]*dbstore.DiscordChannel
-	subreddits        map[string]*dbstore.Subreddit
-	notifyCalls       int
-	upsertCalls       int
+	*dbstore.MemStore
+	t           *testing.T
+	mu          sync.Mutex
+	notifyCalls int
+	upsertCalls int
 }
 
-func newFakeStore() *fakeStore {
-	return &fakeStore{
-		notificationCount: map[string]int{},
-		channels:          map[int]*dbstore.DiscordChannel{1: {ID: 1, ExternalID: "ext-chan-1"}},
-		subreddits:        map[string]*dbstore.Subreddit{"Metalcore": {ID: 10, ExternalID: "metalcore"}},
-		nowFn:             time.Now,
+func newFakeStore(t *testing.T) *fakeStore {
+	t.Helper()
+	s := &fakeStore{MemStore: dbstore.NewMemStore(), t: t}
+	ctx := context.Background()
+	server, err := s.InsertDiscordServer(ctx, "ext-server-1")
+	if err != nil {
+		t.Fatalf("seed server: %v", err)
 	}
-}
-
-func (s *fakeStore) now() time.Time {
-	if s.nowFn != nil {
-		return s.nowFn()
+	if ch, err := s.InsertDiscordChannel(ctx, "ext-chan-1", server.ID); err != nil || ch.ID != 1 {
+		t.Fatalf("seed channel = %+v, %v; want id 1", ch, err)
 	}
-	return time.Now()
-}
-
-func notifKey(postID, channelID, ruleID int) string {
-	return fmt.Sprintf("n|%d|%d|%d", postID, channelID, ruleID)
+	if _, err := s.InsertSubreddit(ctx, "Metalcore"); err != nil {
+		t.Fatalf("seed subreddit: %v", err)
+	}
+	return s
 }
 
-func (s *fakeStore) GetNotificationCount(_ context.Context, postID, channelID, ruleID int) (int, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.notificationCount[notifKey(postID, channelID, ruleID)], nil
-}
-func (s *fakeStore) InsertNotification(_ context.Context, postID, channelID, ruleID int) (*dbstore.Notification, error) {
+func (s *fakeStore) InsertNotification(ctx context.Context, postID, channelID, ruleID int) (*dbstore.Notification, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.notificationCount[notifKey(postID, channelID, ruleID)]++
 	s.notifyCalls++
-	return &dbstore.Notification{ID: 1, PostID: postID, ChannelID: channelID, RuleID: ruleID}, nil
+	s.mu.Unlock()
+	return s.MemStore.InsertNotification(ctx, postID, channelID, ruleID)
 }
-func (s *fakeStore) GetDiscordChannel(_ context.Context, id int) (*dbstore.DiscordChannel, error) {
-	ch, ok := s.channels[id]
-	if !ok {
-		return nil, errors.New("no channel")
-	}
-	return ch, nil
+
+func (s *fakeStore) UpsertRollingPost(ctx context.Context, rp dbstore.RollingPost) (*dbstore.RollingPost, error) {
+	s.mu.Lock()
+	s.upsertCalls++
+	s.mu.Unlock()
+	return s.MemStore.UpsertRollingPost(ctx, rp)
 }
-func (s *fakeStore) GetSubredditByExternalID(_ context.Context, name string) (*dbstore.Subreddit, error) {
-	sr, ok := s.subreddits[name]
-	if !ok {
-		return nil, errors.New("no subreddit")
+
+// subreddit returns the seeded or inserted subreddit's row.
+func (s *fakeStore) subreddit(name string) *dbstore.Subreddit {
+	s.t.Helper()
+	sr, err := s.InsertSubreddit(context.Background(), name)
+	if err != nil {
+		s.t.Fatalf("subreddit %s: %v", name, err)
 	}
-	return sr, nil
+	return sr
 }
-func (s *fakeStore) GetActiveRollingPost(_ context.Context, channelID int, mode string, windowHours int) (*dbstore.RollingPost, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if mode == "" {
-		mode = dbstore.ModeNarrative
-	}
-	now := s.now()
-	var latest *dbstore.RollingPost
-	for _, rp := range s.rolling {
-		if rp.ChannelID != channelID {
-			continue
-		}
-		rpMode := rp.Mode
-		if rpMode == "" {
-			rpMode = dbstore.ModeNarrative
-		}
-		if rpMode != mode {
-			continue
-		}
-		closesAt := rp.WindowStart.Add(time.Duration(windowHours) * time.Hour)
-		if !closesAt.After(now) {
-			continue
-		}
-		if latest == nil || rp.WindowStart.After(latest.WindowStart) {
-			latest = rp
-		}
-	}
-	if latest == nil {
-		return nil, nil
+
+// addRule stores a Metalcore rule on channel 1.
+func (s *fakeStore) addRule(rule dbstore.Rule) *dbstore.Rule {
+	s.t.Helper()
+	rule.DiscordChannelID, rule.SubredditID = 1, s.subreddit("Metalcore").ID
+	r, err := s.InsertRule(context.Background(), rule)
+	if err != nil {
+		s.t.Fatalf("add rule: %v", err)
 	}
-	// Return a copy so callers can't mutate the store's backing slice.
-	cp := *latest
-	return &cp, nil
+	return r
 }
 
-func (s *fakeStore) UpsertRollingPost(_ context.Context, rp dbstore.RollingPost) (*dbstore.RollingPost, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.upsertCalls++
-	if rp.ID == 0 {
-		s.nextRollingID++
-		rp.ID = s.nextRollingID
-		if rp.WindowStart.IsZero() {
-			rp.WindowStart = s.now()
-		}
-		stored := rp
-		s.rolling = append(s.rolling, &stored)
-		return &stored, nil
-	}
-	for i, existing := range s.rolling {
-		if existing.ID != rp.ID {
-			continue
-		}
-		// Preserve identity fields the real UPDATE doesn't touch.
-		rp.WindowStart = existing.WindowStart
-		rp.DayLocal = existing.DayLocal
-		stored := rp
-		s.rolling[i] = &stored
-		return &stored, nil
+// activeDigest returns channel 1's open digest in mode.
+func (s *fakeStore) activeDigest(mode string) *dbstore.RollingPost {
+	s.t.Helper()
+	rp, err := s.GetActiveRollingPost(context.Background(), 1, mode, 72)
+	if err != nil || rp == nil {
+		s.t.Fatalf("no active %s digest: %v", mode, err)
 	}
-	return nil, fmt.Errorf("fakeStore: rolling_post id=%d not found", rp.ID)
+	return rp
 }
 
-// --- Store interface no-ops (not exercised by SendMessage) ---
-func (s *fakeStore) InsertDiscordServer(_ context.Context, _ string) (*dbstore.DiscordServer, error) {
-	return nil, nil
-}
-func (s *fakeStore) InsertDiscordChannel(_ context.Context, _ string, _ int) (*dbstore.DiscordChannel, error) {
-	return nil, nil
-}
-func (s *fakeStore) InsertSubreddit(_ context.Context, _ string) (*dbstore.Subreddit, error) {
-	return nil, nil
-}
-func (s *fakeStore) InsertRule(_ context.Context, _ dbstore.Rule) (*dbstore.Rule, error) {
-	return nil, nil
-}
-func (s *fakeStore) InsertPost(_ context.Context, _ string) (*dbstore.Post, error) {
-	return nil, nil
-}
-func (s *fakeStore) GetDiscordServerByExternalID(_ context.Context, _ string) (*dbstore.DiscordServer, error) {
-	return nil, nil
-}
-func (s *fakeStore) GetDiscordChannelByExternalID(_ context.Context, extID string) (*dbstore.DiscordChannel, error) {
-	for _, ch := range s.channels {
-		if ch.ExternalID == extID {
-			return ch, nil
-		}
+// job returns the stored job with the given id.
+func (s *fakeStore) job(id int) *dbstore.LLMJob {
+	s.t.Helper()
+	j, err := s.GetLLMJob(context.Background(), id)
+	if err != nil || j == nil {
+		s.t.Fatalf("no job %d: %v", id, err)
 	}
-	return nil, errors.New("no channel by external id")
-}
-func (s *fakeStore) GetRules(_ context.Context, _ int) ([]*dbstore.Rule, error)    { return nil, nil }
-func (s *fakeStore) GetSubreddits(_ context.Context) ([]*dbstore.Subreddit, error) { return nil, nil }
-func (s *fakeStore) GetRulesByChannel(_ context.Context, _ string) ([]*dbstore.RuleDetail, error) {
-	return nil, nil
-}
-func (s *fakeStore) GetRuleByID(_ context.Context, _ int) (*dbstore.RuleDetail, error) {
-	return nil, nil
+	return j
 }
-func (s *fakeStore) DeleteRule(_ context.Context, _ int) error                   { return nil }
-func (s *fakeStore) UpdateRule(_ context.Context, _ int, _ string, _ bool) error { return nil }
-func (s *fakeStore) UpdateRuleMode(_ context.Context, _ int, _ string) error     { return nil }
-func (s *fakeStore) UpdateRuleWindowHours(_ context.Context, _ int, _ int) error { return nil }
-func (s *fakeStore) GetLastfmListeners(_ context.Context, _ string) (int, time.Time, bool, error) {
-	return 0, time.Time{}, false, nil
-}
-func (s *fakeStore) UpsertLastfmListeners(_ context.Context, _ string, _ int) error { return nil }
-func (s *fakeStore) GetLastfmArtist(_ context.Context, _ string) (int, []string, time.Time, bool, error) {
-	return 0, nil, time.Time{}, false, nil
-}
-func (s *fakeStore) UpsertLastfmArtist(_ context.Context, _ string, _ int, _ []string) error {
-	return nil
-}
-func (s *fakeStore) GetPipedVideo(_ context.Context, _ string) (string, time.Time, bool, error) {
-	return "", time.Time{}, false, nil
-}
-func (s *fakeStore) UpsertPipedVideo(_ context.Context, _, _ string) error { return nil }
-func (s *fakeStore) GetQobuzAlbum(_ context.Context, _ string) (string, time.Time, bool, error) {
-	return "", time.Time{}, false, nil
-}
-func (s *fakeStore) UpsertQobuzAlbum(_ context.Context, _, _ string) error { return nil }
 
 // ---------- fake sender ----------
 
@@ -209,6 +117,7 @@ type fakeSender struct {
 	edits     []*discordgo.MessageEdit
 	nextMsgID string
 	editErr   error
+	deleted   []string
 }
 
 func (f *fakeSender) ChannelMessageSendComplex(_ string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
@@ -235,7 +144,8 @@ func (f *fakeSender) ChannelMessageEditComplex(m *discordgo.MessageEdit, _ ...di
 // Thread-aware MessageSender stubs. Narrative-mode tests don't exercise
 // these paths; they're here to satisfy the interface. Music-mode tests
 // (added separately) drive them.
-func (f *fakeSender) ChannelMessageDelete(_, _ string, _ ...discordgo.RequestOption) error {
+func (f *fakeSender) ChannelMessageDelete(_, id string, _ ...discordgo.RequestOption) error {
+	f.deleted = append(f.deleted, id)
 	return nil
 }
 
@@ -247,6 +157,11 @@ func (f *fakeSender) ChannelEdit(_ string, _ *discordgo.ChannelEdit, _ ...discor
 	return &discordgo.Channel{}, nil
 }
 
+func (f *fakeSender) ChannelDelete(id string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.deleted = append(f.deleted, id)
+	return &discordgo.Channel{ID: id}, nil
+}
+
 // ---------- fake shaper ----------
 
 type fakeShaper struct {
@@ -254,10 +169,27 @@ type fakeShaper struct {
 	updateCalls int
 	freshOut    llm.Output
 	updateOut   llm.Output
+	// freshPartials are fed to FreshInput.Progress before returning, the
+	// way a streaming shaper would.
+	freshPartials []llm.Output
+	// musicOut / musicErr are returned by ShapeMusic.
+	musicOut []llm.MusicEntry
+	musicErr error
+	// freshPrompt records the prompt options of the last ShapeFresh call,
+	// freshIn the whole input.
+	freshPrompt llm.PromptOptions
+	freshIn     llm.FreshInput
 }
 
-func (s *fakeShaper) ShapeFresh(_ ctxpkg.Ctx, _ llm.FreshInput) (llm.Output, error) {
+func (s *fakeShaper) ShapeFresh(_ ctxpkg.Ctx, in llm.FreshInput) (llm.Output, error) {
 	s.freshCalls++
+	s.freshPrompt = in.Prompt
+	s.freshIn = in
+	if in.Progress != nil {
+		for _, p := range s.freshPartials {
+			in.Progress(p)
+		}
+	}
 	return s.freshOut, nil
 }
 func (s *fakeShaper) ShapeUpdate(_ ctxpkg.Ctx, _ llm.UpdateInput) (llm.Output, error) {
@@ -265,7 +197,7 @@ func (s *fakeShaper) ShapeUpdate(_ ctxpkg.Ctx, _ llm.UpdateInput) (llm.Output, e
 	return s.updateOut, nil
 }
 func (s *fakeShaper) ShapeMusic(_ ctxpkg.Ctx, _ llm.MusicInput) ([]llm.MusicEntry, error) {
-	return nil, nil
+	return s.musicOut, s.musicErr
 }
 
 // ---------- helpers ----------
@@ -278,7 +210,7 @@ func appCtx(t *testing.T) ctxpkg.Ctx {
 func buildClient(store *fakeStore, sender MessageSender, shaper Shaper, now func() time.Time) *Client {
 	// Keep the store's clock in sync with the Client's injected clock so
 	// GetActiveRollingPost's window math sees the same "now" SendMessage does.
-	store.nowFn = now
+	store.Now = now
 	bot := &redditDiscordBot.RedditDiscordBot{Store: store}
 	return NewForTest(
 		ctxpkg.New(context.Background()),
@@ -302,7 +234,7 @@ func newMatch(postID int, ruleID int, reddit *redditJSON.RedditPost) *evaluator.
 // ---------- tests ----------
 
 func TestSendMessage_FirstMatchSends(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	shaper := &fakeShaper{freshOut: llm.Output{Title: "Day 1 digest", Summary: "one post so far"}}
 	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
@@ -327,7 +259,7 @@ func TestSendMessage_FirstMatchSends(t *testing.T) {
 }
 
 func TestSendMessage_SameDaySecondMatchEdits(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	shaper := &fakeShaper{
 		freshOut:  llm.Output{Title: "Day 1", Summary: "one"},
@@ -367,7 +299,7 @@ func TestSendMessage_SameDaySecondMatchEdits(t *testing.T) {
 // match's window_start opens a new rolling_post (new Discord send) instead
 // of editing the old one.
 func TestSendMessage_PastWindowSendsNewMessage(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	shaper := &fakeShaper{
 		freshOut:  llm.Output{Title: "T", Summary: "S"},
@@ -401,7 +333,7 @@ func TestSendMessage_PastWindowSendsNewMessage(t *testing.T) {
 }
 
 func TestSendMessage_EditFallsBackWhenMessageDeleted(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	shaper := &fakeShaper{
 		freshOut:  llm.Output{Title: "T", Summary: "S"},
@@ -440,7 +372,7 @@ func TestSendMessage_EditFallsBackWhenMessageDeleted(t *testing.T) {
 }
 
 func TestSendMessage_DedupeShortCircuits(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{}
 	shaper := &fakeShaper{}
 	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
@@ -461,12 +393,160 @@ func TestSendMessage_DedupeShortCircuits(t *testing.T) {
 	}
 }
 
+func TestSendMessage_CrosspostFoldsIntoItem(t *testing.T) {
+	store := newFakeStore(t)
+	metalcore, popPunkers := store.subreddit("Metalcore"), store.subreddit("PopPunkers")
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{
+		freshOut:  llm.Output{Title: "Tour news", Summary: "one"},
+		updateOut: llm.Output{Title: "Tour news v2", Summary: "two"},
+	}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	orig := &redditJSON.RedditPost{
+		ID: "abc", Subreddit: "Metalcore", Title: "Architects announce a European tour for the autumn",
+		URL: "https://www.reddit.com/r/Metalcore/comments/abc/x/", Permalink: "/r/Metalcore/comments/abc/x/",
+		Score: 10, NumComments: 4,
+	}
+	xpost := &redditJSON.RedditPost{
+		ID: "def", Subreddit: "PopPunkers", Title: orig.Title, CrosspostParent: "t3_abc",
+		URL: "/r/Metalcore/comments/abc/x/", Permalink: "/r/PopPunkers/comments/def/x/",
+		Score: 5, NumComments: 1,
+	}
+	if err := c.SendMessage(appCtx(t), newMatch(100, 2, orig)); err != nil {
+		t.Fatalf("first SendMessage: %v", err)
+	}
+	clock = clock.Add(time.Hour)
+	if err := c.SendMessage(appCtx(t), newMatch(101, 3, xpost)); err != nil {
+		t.Fatalf("crosspost SendMessage: %v", err)
+	}
+
+	if shaper.freshCalls != 1 || shaper.updateCalls != 0 {
+		t.Errorf("fresh=%d update=%d, want the crosspost to skip the LLM", shaper.freshCalls, shaper.updateCalls)
+	}
+	if sender.sendCalls != 1 || sender.editCalls != 1 {
+		t.Errorf("send=%d edit=%d, want send=1 edit=1", sender.sendCalls, sender.editCalls)
+	}
+	if store.notifyCalls != 2 {
+		t.Errorf("notifyCalls=%d, want 2", store.notifyCalls)
+	}
+	rp := store.activeDigest(dbstore.ModeNarrative)
+	if !reflect.DeepEqual(rp.IncludedPostIDs, []string{"abc"}) {
+		t.Errorf("IncludedPostIDs = %v, want [abc]", rp.IncludedPostIDs)
+	}
+	if !reflect.DeepEqual(rp.SubredditIDs, []int{metalcore.ID, popPunkers.ID}) || !reflect.DeepEqual(rp.IncludedRuleIDs, []int{2, 3}) {
+		t.Errorf("SubredditIDs=%v IncludedRuleIDs=%v", rp.SubredditIDs, rp.IncludedRuleIDs)
+	}
+	if rp.LatestScore != 15 || rp.LatestComments != 5 {
+		t.Errorf("score=%d comments=%d, want 15 and 5", rp.LatestScore, rp.LatestComments)
+	}
+	if rp.NarrativeTitle != "Tour news" {
+		t.Errorf("NarrativeTitle = %q, want the narrative untouched", rp.NarrativeTitle)
+	}
+
+	embed := (*sender.edits[0].Embeds)[0]
+	var sources string
+	for _, f := range embed.Fields {
+		if f.Name == "Also posted in" {
+			sources = f.Value
+		}
+	}
+	if !strings.Contains(sources, "r/Metalcore, r/PopPunkers") || !strings.Contains(sources, "15 points · 5 comments") {
+		t.Errorf("Also posted in = %q", sources)
+	}
+}
+
+func TestSendMessage_CopyOfOlderItemKeepsLatestPost(t *testing.T) {
+	store := newFakeStore(t)
+	store.subreddit("Metalcore")
+	store.subreddit("Deathcore")
+	store.subreddit("PopPunkers")
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{
+		freshOut:  llm.Output{Title: "T", Summary: "one"},
+		updateOut: llm.Output{Title: "U", Summary: "two"},
+	}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	older := &redditJSON.RedditPost{
+		ID: "abc", Subreddit: "Metalcore", Title: "Architects announce a European tour for the autumn",
+		URL: "https://www.reddit.com/r/Metalcore/comments/abc/x/", Permalink: "/r/Metalcore/comments/abc/x/",
+		Score: 10, NumComments: 4,
+	}
+	newer := &redditJSON.RedditPost{
+		ID: "ghi", Subreddit: "Deathcore", Title: "Lorna Shore share a new single today",
+		URL: "https://example.com/single", Score: 3, NumComments: 2,
+	}
+	xpost := &redditJSON.RedditPost{
+		ID: "def", Subreddit: "PopPunkers", Title: older.Title, CrosspostParent: "t3_abc",
+		URL: "/r/Metalcore/comments/abc/x/", Permalink: "/r/PopPunkers/comments/def/x/",
+		Score: 5, NumComments: 1, CreatedUTC: 1,
+	}
+	for i, p := range []*redditJSON.RedditPost{older, newer, xpost} {
+		if err := c.SendMessage(appCtx(t), newMatch(100+i, 2, p)); err != nil {
+			t.Fatalf("SendMessage %s: %v", p.ID, err)
+		}
+		clock = clock.Add(time.Hour)
+	}
+
+	rp := store.activeDigest(dbstore.ModeNarrative)
+	if rp.LatestScore != 3 || rp.LatestComments != 2 {
+		t.Errorf("score=%d comments=%d, want the latest post's 3 and 2", rp.LatestScore, rp.LatestComments)
+	}
+	embed := (*sender.edits[len(sender.edits)-1].Embeds)[0]
+	if embed.Author.Name != "r/Deathcore" {
+		t.Errorf("author = %q, want the latest post's subreddit", embed.Author.Name)
+	}
+	if embed.Timestamp == time.Unix(1, 0).UTC().Format(time.RFC3339) {
+		t.Error("timestamp should come from the latest post, not the copy")
+	}
+	var sources string
+	for _, f := range embed.Fields {
+		if f.Name == "Also posted in" {
+			sources = f.Value
+		}
+	}
+	if !strings.Contains(sources, "r/Metalcore, r/PopPunkers · 15 points · 5 comments") {
+		t.Errorf("Also posted in = %q", sources)
+	}
+}
+
+func TestSendMessage_DuplicateWindowExpires(t *testing.T) {
+	store := newFakeStore(t)
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{
+		freshOut:  llm.Output{Title: "T", Summary: "S"},
+		updateOut: llm.Output{Title: "U", Summary: "u"},
+	}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+	WithDuplicateWindow(6 * time.Hour)(c)
+
+	first := &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "t1", URL: "https://example.com/story"}
+	again := &redditJSON.RedditPost{ID: "p2", Subreddit: "Metalcore", Title: "t2", URL: "https://example.com/story"}
+	if err := c.SendMessage(appCtx(t), newMatch(100, 2, first)); err != nil {
+		t.Fatalf("first SendMessage: %v", err)
+	}
+	clock = clock.Add(7 * time.Hour)
+	if err := c.SendMessage(appCtx(t), newMatch(101, 2, again)); err != nil {
+		t.Fatalf("second SendMessage: %v", err)
+	}
+	if shaper.updateCalls != 1 {
+		t.Errorf("updateCalls=%d, want the late copy narrated as a new post", shaper.updateCalls)
+	}
+	if got := store.activeDigest(dbstore.ModeNarrative).IncludedPostIDs; len(got) != 2 {
+		t.Errorf("IncludedPostIDs = %v, want both posts", got)
+	}
+}
+
 // TestSendMessage_WindowBoundary_CrossingClockMidnight pins the new window
 // semantics: crossing Phoenix midnight WITHIN the same 72h window should
 // still edit the existing digest (not open a new one). Under the old
 // day_local bucketing this would have been a "new day, new digest" event.
 func TestSendMessage_WindowBoundary_CrossingClockMidnight(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	shaper := &fakeShaper{
 		freshOut:  llm.Output{Title: "T", Summary: "S"},
@@ -503,7 +583,7 @@ func TestSendMessage_WindowBoundary_CrossingClockMidnight(t *testing.T) {
 }
 
 func TestSendMessage_NoShaperFallsBackToRawSelftext(t *testing.T) {
-	store := newFakeStore()
+	store := newFakeStore(t)
 	sender := &fakeSender{nextMsgID: "msg-1"}
 	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
 	c := buildClient(store, sender, nil, now)
@@ -525,3 +605,773 @@ func TestSendMessage_NoShaperFallsBackToRawSelftext(t *testing.T) {
 		t.Errorf("description = %q, want raw selftext", got.Description)
 	}
 }
+
+func TestSendMessage_StreamingProgressReusesMessage(t *testing.T) {
+	store := newFakeStore(t)
+	sender := &fakeSender{nextMsgID: "msg-stream"}
+	shaper := &fakeShaper{
+		freshOut: llm.Output{Title: "Final", Summary: "complete narrative"},
+		freshPartials: []llm.Output{
+			{Title: "Final", Summary: "comp"},
+			{Title: "Final", Summary: "complete narr"}, // throttled: same instant
+		},
+	}
+	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
+	c := buildClient(store, sender, shaper, now)
+
+	err := c.SendMessage(appCtx(t), newMatch(100, 2, &redditJSON.RedditPost{
+		ID: "abc", Author: "u1", Subreddit: "Metalcore", Title: "weekly thread", Selftext: "bands",
+	}))
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	// One progressive send creates the message; the final render edits it.
+	if sender.sendCalls != 1 || sender.editCalls != 1 {
+		t.Fatalf("send=%d edit=%d, want send=1 edit=1", sender.sendCalls, sender.editCalls)
+	}
+	if !strings.Contains(sender.sends[0].Embeds[0].Footer.Text, "writing") {
+		t.Errorf("progressive frame should be marked in-flight: %q", sender.sends[0].Embeds[0].Footer.Text)
+	}
+	final := (*sender.edits[0].Embeds)[0]
+	if final.Description != "complete narrative" || strings.Contains(final.Footer.Text, "writing") {
+		t.Errorf("final edit = %q / %q", final.Description, final.Footer.Text)
+	}
+	if store.upsertCalls != 1 {
+		t.Errorf("upsert=%d, want exactly one persisted write", store.upsertCalls)
+	}
+	if got := store.activeDigest(dbstore.ModeNarrative).DiscordMessageIDs; len(got) != 1 || got[0] != "msg-stream" {
+		t.Errorf("stored message ids = %v", got)
+	}
+}
+
+func TestMusicShapeFailure_QueuesAndRetryFolds(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "weekly", TargetID: "title", Mode: dbstore.ModeMusic})
+	sender := &fakeSender{nextMsgID: "card-1"}
+	shaper := &fakeShaper{musicErr: errors.New("vllm: connection refused")}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	match := newMatch(100, rule.ID, &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "Weekly Release Thread", Selftext: "A - One"})
+	match.Rule.Mode = dbstore.ModeMusic
+	if err := c.SendMessage(appCtx(t), match); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if j := store.job(1); j.Status != dbstore.JobStatusPending {
+		t.Fatalf("job = %+v, want pending", j)
+	}
+	if store.notifyCalls != 1 || sender.sendCalls != 0 {
+		t.Fatalf("notify=%d send=%d, want the notification recorded and nothing sent", store.notifyCalls, sender.sendCalls)
+	}
+
+	// Not yet due.
+	c.RetryDueJobs(appCtx(t))
+	if store.job(1).Attempts != 0 {
+		t.Fatalf("job ran before its backoff elapsed")
+	}
+
+	// Still failing: back to pending with a longer delay.
+	clock = clock.Add(jobBackoff(1))
+	c.RetryDueJobs(appCtx(t))
+	if j := store.job(1); j.Status != dbstore.JobStatusPending || !j.NextAttemptAt.Equal(clock.Add(jobBackoff(1))) {
+		t.Fatalf("after failed retry: %+v", j)
+	}
+
+	// Backend is back: the retry folds the entries into a digest.
+	shaper.musicErr = nil
+	shaper.musicOut = []llm.MusicEntry{{Artist: "A", Title: "One", Kind: "single"}}
+	clock = clock.Add(jobBackoff(1))
+	c.RetryDueJobs(appCtx(t))
+	if j := store.job(1); j.Status != dbstore.JobStatusDone {
+		t.Fatalf("job status = %q, want done", j.Status)
+	}
+	if rp := store.activeDigest(dbstore.ModeMusic); !strings.Contains(string(rp.Entries), `"One"`) {
+		t.Fatalf("rolling post = %+v", rp)
+	}
+}
+
+func TestRetryDueJobs_ExhaustedAttemptsFail(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "weekly", TargetID: "title", Mode: dbstore.ModeMusic})
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, &fakeSender{}, &fakeShaper{musicErr: errors.New("boom")}, func() time.Time { return clock })
+	_, _ = store.EnqueueLLMJob(context.Background(), dbstore.LLMJob{
+		Kind: dbstore.JobKindMusicExtract, ChannelID: 1, RuleID: rule.ID, PostID: 100,
+		Payload: []byte(`{"post":{"id":"p1","subreddit":"Metalcore"}}`), MaxAttempts: 2,
+	})
+
+	c.RetryDueJobs(appCtx(t))
+	clock = clock.Add(time.Hour)
+	c.RetryDueJobs(appCtx(t))
+	if j := store.job(1); j.Status != dbstore.JobStatusFailed || j.Attempts != 2 {
+		t.Fatalf("job = %+v, want failed after 2 attempts", j)
+	}
+	if ok, _ := store.RequeueLLMJob(context.Background(), 1); !ok || store.job(1).Attempts != 0 {
+		t.Errorf("requeue should reset the failed job")
+	}
+}
+
+func TestJobBackoff(t *testing.T) {
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	for i, w := range want {
+		if got := jobBackoff(i + 1); got != w {
+			t.Errorf("jobBackoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestFreshNarrative_UsesAssignedTemplateAndTone(t *testing.T) {
+	store := newFakeStore(t)
+	metal, terse := "metal", "terse"
+	_ = store.SetChannelPrompt(context.Background(), 1, &metal, &terse)
+	_ = store.UpsertPromptTemplate(context.Background(), "metal", string(llm.PromptFresh), "metal fresh body")
+	_ = store.UpsertPromptTemplate(context.Background(), "trial", string(llm.PromptFresh), "trial fresh body")
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "t", Summary: "s"}}
+	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
+	c := buildClient(store, &fakeSender{nextMsgID: "msg-1"}, shaper, now)
+	match := newMatch(100, 2, &redditJSON.RedditPost{ID: "abc", Subreddit: "Metalcore", Title: "weekly"})
+
+	c.freshNarrative(appCtx(t), match, discussion{}, nil)
+	if want := (llm.PromptOptions{Template: "metal fresh body", Tone: "terse"}); shaper.freshPrompt != want {
+		t.Errorf("prompt = %+v, want %+v", shaper.freshPrompt, want)
+	}
+
+	// A preview override swaps the template but keeps the assigned tone.
+	c.freshNarrative(withTemplateOverride(appCtx(t), "trial"), match, discussion{}, nil)
+	if shaper.freshPrompt.Template != "trial fresh body" || shaper.freshPrompt.Tone != "terse" {
+		t.Errorf("override prompt = %+v", shaper.freshPrompt)
+	}
+
+	// "default" and names without a body for the kind use the built-in.
+	for _, name := range []string{defaultTemplateName, "missing"} {
+		c.freshNarrative(withTemplateOverride(appCtx(t), name), match, discussion{}, nil)
+		if shaper.freshPrompt.Template != "" {
+			t.Errorf("%s: template = %q, want built-in", name, shaper.freshPrompt.Template)
+		}
+	}
+}
+
+type fakeComments struct {
+	comments []*reddit.Comment
+	limit    int
+}
+
+func (f *fakeComments) GetPostComments(_ context.Context, _ string, limit int) ([]*reddit.Comment, error) {
+	f.limit = limit
+	return f.comments, nil
+}
+
+type fakeArticles struct {
+	urls []string
+	art  *article.Article
+	err  error
+}
+
+func (f *fakeArticles) Fetch(_ context.Context, rawURL string) (*article.Article, error) {
+	f.urls = append(f.urls, rawURL)
+	return f.art, f.err
+}
+
+func TestFreshNarrative_IncludesCommentsAndLinkedArticle(t *testing.T) {
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "t", Summary: "s"}}
+	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
+	store := newFakeStore(t)
+	c := buildClient(store, &fakeSender{nextMsgID: "msg-1"}, shaper, now)
+	comments := &fakeComments{comments: []*reddit.Comment{{Author: "a", Body: "so true", Score: 9}}}
+	articles := &fakeArticles{art: &article.Article{Title: "Story", Image: "https://news.example.com/og.jpg", Text: "article text"}}
+	WithComments(comments, 3)(c)
+	WithArticles(articles)(c)
+
+	narrate := func(p *redditJSON.RedditPost) discussion {
+		d := c.discussion(appCtx(t), p)
+		c.freshNarrative(appCtx(t), newMatch(100, 2, p), d, nil)
+		return d
+	}
+
+	link := &redditJSON.RedditPost{ID: "abc", Title: "news", URL: "https://news.example.com/story", Thumbnail: "default"}
+	d := narrate(link)
+	if comments.limit != 3 {
+		t.Errorf("comment limit = %d, want 3", comments.limit)
+	}
+	want := []llm.Comment{{Author: "a", Body: "so true", Score: 9}}
+	if !reflect.DeepEqual(shaper.freshIn.Comments, want) || shaper.freshIn.Article != "article text" {
+		t.Errorf("input comments/article = %+v / %q", shaper.freshIn.Comments, shaper.freshIn.Article)
+	}
+	if got := d.thumbnail(link); got != "https://news.example.com/og.jpg" {
+		t.Errorf("thumbnail = %q, want the og:image", got)
+	}
+	if row, _ := store.GetCachedArticle(context.Background(), link.URL); row == nil || row.Title != "Story" || !row.FetchedAt.Equal(now()) {
+		t.Errorf("cached row = %+v", row)
+	}
+
+	// A second digest for the same link is served from the cache.
+	narrate(link)
+	if len(articles.urls) != 1 {
+		t.Errorf("article fetches = %v, want the second served from cache", articles.urls)
+	}
+
+	// Self posts and Reddit-hosted links don't fetch an article.
+	for _, p := range []*redditJSON.RedditPost{
+		{ID: "def", Selftext: "body", URL: "https://news.example.com/other"},
+		{ID: "ghi", URL: "https://i.redd.it/pic.jpg"},
+	} {
+		narrate(p)
+		if shaper.freshIn.Article != "" {
+			t.Errorf("%s: article = %q, want none", p.ID, shaper.freshIn.Article)
+		}
+	}
+	if len(articles.urls) != 1 {
+		t.Errorf("article fetches = %v, want only the link post", articles.urls)
+	}
+
+	// Non-HTML links are cached empty so they aren't fetched again.
+	articles.art, articles.err = nil, article.ErrNotHTML
+	pdf := &redditJSON.RedditPost{ID: "jkl", URL: "https://example.com/report"}
+	narrate(pdf)
+	narrate(pdf)
+	if len(articles.urls) != 2 || shaper.freshIn.Article != "" {
+		t.Errorf("article fetches = %v, article = %q", articles.urls, shaper.freshIn.Article)
+	}
+}
+
+func TestFreshNarrative_RawFallbackUsesArticle(t *testing.T) {
+	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
+	c := buildClient(newFakeStore(t), &fakeSender{nextMsgID: "msg-1"}, nil, now)
+	post := &redditJSON.RedditPost{ID: "abc", Title: "news", URL: "https://news.example.com/story"}
+	d := discussion{Article: &dbstore.CachedArticle{Description: "from og:description"}}
+
+	if out := c.freshNarrative(appCtx(t), newMatch(100, 2, post), d, nil); out.Summary != "from og:description" {
+		t.Errorf("summary = %q, want the article description", out.Summary)
+	}
+}
+
+func TestPostImage(t *testing.T) {
+	preview := &reddit.Preview{Images: []reddit.PreviewSet{{
+		Source: reddit.PreviewImage{URL: "https://preview.redd.it/a.jpg?width=4000&amp;s=x", Width: 4000},
+		Resolutions: []reddit.PreviewImage{
+			{URL: "https://preview.redd.it/a.jpg?width=640&amp;s=y", Width: 640},
+			{URL: "https://preview.redd.it/a.jpg?width=1080&amp;s=z", Width: 1080},
+		},
+	}}}
+	cases := []struct {
+		name string
+		post redditJSON.RedditPost
+		want string
+	}{
+		{"preview rendition", redditJSON.RedditPost{PostHint: "image", URL: "https://i.redd.it/a.jpg", Preview: preview}, "https://preview.redd.it/a.jpg?width=1080&s=z"},
+		{"direct link without preview", redditJSON.RedditPost{URL: "https://i.imgur.com/b.png"}, "https://i.imgur.com/b.png"},
+		{"link post", redditJSON.RedditPost{PostHint: "link", URL: "https://news.example.com/story", Preview: preview}, ""},
+		{"self post", redditJSON.RedditPost{Selftext: "hi"}, ""},
+	}
+	for _, tc := range cases {
+		if got := postImage(&tc.post); got != tc.want {
+			t.Errorf("%s: postImage = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSendMessage_TranslatedDigestKeepsOriginalTitle(t *testing.T) {
+	store := newFakeStore(t)
+	_ = store.SetChannelLanguage(context.Background(), 1, "German")
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{freshOut: llm.Output{
+		Title: "Neues Album", Summary: "s", SourceLanguage: "Japanese", OriginalTitle: "新しいアルバム",
+	}}
+	now := func() time.Time { return time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC) }
+	c := buildClient(store, sender, shaper, now)
+
+	err := c.SendMessage(appCtx(t), newMatch(100, 2, &redditJSON.RedditPost{
+		ID: "abc", Subreddit: "Metalcore", Title: "新しいアルバム", Selftext: "本文",
+	}))
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if shaper.freshPrompt.Language != "German" {
+		t.Errorf("prompt language = %q, want the channel's", shaper.freshPrompt.Language)
+	}
+	fields := sender.sends[0].Embeds[0].Fields
+	last := fields[len(fields)-1]
+	if last.Name != "Original title (Japanese)" || last.Value != "新しいアルバム" {
+		t.Errorf("last field = %+v, want the original title", last)
+	}
+}
+
+// constEmbedder embeds every text as vecs[text], or {0, 1} when unknown.
+type constEmbedder map[string][]float32
+
+func (constEmbedder) Model() string { return "m" }
+
+func (e constEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		if v, ok := e[t]; ok {
+			out[i] = v
+		} else {
+			out[i] = []float32{0, 1}
+		}
+	}
+	return out, nil
+}
+
+func TestSimilarityNote(t *testing.T) {
+	c := buildClient(newFakeStore(t), &fakeSender{}, nil, time.Now)
+	post := &redditJSON.RedditPost{ID: "p1", Title: "Tour announced"}
+	rule := &dbstore.RuleDetail{ID: 2, TargetID: evaluator.TargetSemantic, Target: "new tour"}
+
+	if note := c.similarityNote(c.Ctx, rule, post); !strings.Contains(note, "no embedding model") {
+		t.Errorf("note without embedder = %q", note)
+	}
+	c.embedder = constEmbedder{"new tour": {1, 0}, "Tour announced": {1, 0}}
+	note := c.similarityNote(c.Ctx, rule, post)
+	if !strings.Contains(note, "**1.000**") || !strings.Contains(note, "threshold 0.60 — would match") {
+		t.Errorf("note = %q", note)
+	}
+	if note := c.similarityNote(c.Ctx, &dbstore.RuleDetail{TargetID: "title"}, post); note != "" {
+		t.Errorf("non-semantic rule note = %q, want none", note)
+	}
+}
+
+func TestRerenderDigest_EditsThenReposts(t *testing.T) {
+	store := newFakeStore(t)
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "Tour news", Summary: "dates announced"}}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	if err := c.SendMessage(appCtx(t), newMatch(100, 2, &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "t1"})); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	// Long after the window closed, the digest is still reachable by id.
+	clock = clock.Add(30 * 24 * time.Hour)
+	ch, _ := store.GetDiscordChannel(context.Background(), 1)
+	digests, err := store.ListRollingPosts(context.Background(), 1, "", clock.Add(-60*24*time.Hour), 10)
+	if err != nil || len(digests) != 1 {
+		t.Fatalf("ListRollingPosts = %+v, %v", digests, err)
+	}
+	line := formatHistoryLine("g1", "ext-chan-1", digests[0])
+	if !strings.Contains(line, "Tour news") || !strings.Contains(line, "https://discord.com/channels/g1/ext-chan-1/msg-1") {
+		t.Errorf("history line = %q", line)
+	}
+
+	saved, err := c.rerenderDigest(appCtx(t), ch, digests[0])
+	if err != nil {
+		t.Fatalf("rerenderDigest: %v", err)
+	}
+	if sender.sendCalls != 1 || sender.editCalls != 1 || sender.edits[0].ID != "msg-1" {
+		t.Errorf("send=%d edit=%d, want the original message edited", sender.sendCalls, sender.editCalls)
+	}
+	if embed := (*sender.edits[0].Embeds)[0]; embed.Title != "Tour news" || embed.Description != "dates announced" {
+		t.Errorf("re-rendered embed = %q / %q", embed.Title, embed.Description)
+	}
+
+	sender.nextMsgID = "msg-2"
+	sender.editErr = &discordgo.RESTError{Response: &http.Response{StatusCode: 404}}
+	saved, err = c.rerenderDigest(appCtx(t), ch, saved)
+	if err != nil {
+		t.Fatalf("rerenderDigest after delete: %v", err)
+	}
+	if sender.sendCalls != 2 || !reflect.DeepEqual(saved.DiscordMessageIDs, []string{"msg-2"}) {
+		t.Errorf("send=%d ids=%v, want a repost as msg-2", sender.sendCalls, saved.DiscordMessageIDs)
+	}
+	if stored, _ := store.GetRollingPost(context.Background(), saved.ID); !reflect.DeepEqual(stored.DiscordMessageIDs, []string{"msg-2"}) ||
+		len(stored.IncludedPostIDs) != 1 {
+		t.Errorf("stored digest = %+v", stored)
+	}
+}
+
+func TestRerenderDigest_MusicRepostOpensNewThread(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "weekly", TargetID: "title", Mode: dbstore.ModeMusic})
+	sender := &fakeSender{nextMsgID: "card-1"}
+	shaper := &fakeShaper{musicOut: []llm.MusicEntry{{Artist: "A", Title: "One", Kind: "single"}}}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	match := newMatch(100, rule.ID, &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "Weekly Release Thread"})
+	match.Rule.Mode = dbstore.ModeMusic
+	if err := c.SendMessage(appCtx(t), match); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	rp := store.activeDigest(dbstore.ModeMusic)
+	// Pretend the thread came from an older run so a new one is visible.
+	rp.ThreadID = "thread-old"
+	rp, _ = store.MemStore.UpsertRollingPost(context.Background(), *rp)
+	ch, _ := store.GetDiscordChannel(context.Background(), 1)
+
+	sender.nextMsgID = "card-2"
+	sender.editErr = &discordgo.RESTError{Response: &http.Response{StatusCode: 404}}
+	saved, err := c.rerenderDigest(appCtx(t), ch, rp)
+	if err != nil {
+		t.Fatalf("rerenderDigest: %v", err)
+	}
+	if !reflect.DeepEqual(saved.DiscordMessageIDs, []string{"card-2"}) || saved.ThreadID == "thread-old" || saved.ThreadID == "" {
+		t.Errorf("saved = ids %v thread %q, want the card reposted with a new thread", saved.DiscordMessageIDs, saved.ThreadID)
+	}
+	if len(saved.ThreadMessageIDs) == 0 || !strings.Contains(string(saved.Entries), `"One"`) {
+		t.Errorf("saved = %+v", saved)
+	}
+}
+
+func TestDigestControls_CloseReopenMerge(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "weekly", TargetID: "title", Mode: dbstore.ModeMusic})
+	sender := &fakeSender{nextMsgID: "card-1"}
+	shaper := &fakeShaper{musicOut: []llm.MusicEntry{{Artist: "A", Title: "One", Kind: "single"}}}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+	send := func(id int, postID string) {
+		t.Helper()
+		match := newMatch(id, rule.ID, &redditJSON.RedditPost{ID: postID, Subreddit: "Metalcore", Title: "Weekly Release Thread"})
+		match.Rule.Mode = dbstore.ModeMusic
+		if err := c.SendMessage(appCtx(t), match); err != nil {
+			t.Fatalf("SendMessage(%s): %v", postID, err)
+		}
+	}
+
+	send(100, "p1")
+	first := store.activeDigest(dbstore.ModeMusic)
+	if err := store.CloseRollingPost(context.Background(), first.ID); err != nil {
+		t.Fatalf("CloseRollingPost: %v", err)
+	}
+
+	// Still inside the rule window, but the close splits it.
+	clock = clock.Add(time.Hour)
+	sender.nextMsgID = "card-2"
+	shaper.musicOut = []llm.MusicEntry{{Artist: "A", Title: "One", Kind: "single"}, {Artist: "B", Title: "Two", Kind: "album"}}
+	send(101, "p2")
+	second := store.activeDigest(dbstore.ModeMusic)
+	if second.ID == first.ID {
+		t.Fatalf("match after close joined digest #%d", first.ID)
+	}
+
+	// Reopening the first digest routes the next match back into it.
+	if err := store.ReopenRollingPost(context.Background(), first.ID, clock.Add(24*time.Hour)); err != nil {
+		t.Fatalf("ReopenRollingPost: %v", err)
+	}
+	if got := store.activeDigest(dbstore.ModeMusic); got.ID != first.ID {
+		t.Errorf("active digest after reopen = #%d, want #%d", got.ID, first.ID)
+	}
+
+	ch, _ := store.GetDiscordChannel(context.Background(), 1)
+	into, _ := store.GetRollingPost(context.Background(), first.ID)
+	from, _ := store.GetRollingPost(context.Background(), second.ID)
+	merged, err := c.mergeMusicDigests(appCtx(t), ch, into, from)
+	if err != nil {
+		t.Fatalf("mergeMusicDigests: %v", err)
+	}
+	entries, _ := decodeMusicEntries(merged.Entries)
+	if len(entries) != 2 || entries[0].Title != "One" || entries[1].Title != "Two" {
+		t.Errorf("merged entries = %+v, want One then Two", entries)
+	}
+	if !reflect.DeepEqual(merged.IncludedPostIDs, []string{"p1", "p2"}) || !reflect.DeepEqual(merged.DiscordMessageIDs, into.DiscordMessageIDs) {
+		t.Errorf("merged = posts %v ids %v", merged.IncludedPostIDs, merged.DiscordMessageIDs)
+	}
+	if gone, _ := store.GetRollingPost(context.Background(), second.ID); gone != nil {
+		t.Errorf("digest #%d still exists after merge", second.ID)
+	}
+	if stored, _ := store.GetRollingPost(context.Background(), first.ID); !strings.Contains(string(stored.Entries), `"Two"`) ||
+		!reflect.DeepEqual(stored.IncludedPostIDs, []string{"p1", "p2"}) {
+		t.Errorf("stored digest = %+v", stored)
+	}
+	if !slices.Contains(sender.deleted, from.ThreadID) || !slices.Contains(sender.deleted, "card-2") {
+		t.Errorf("deleted = %v, want %q and card-2", sender.deleted, from.ThreadID)
+	}
+}
+
+func TestDigestFeedback_VotesStatsAndAutoExclude(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "tour", TargetID: "title"})
+	sender := &fakeSender{nextMsgID: "digest-1"}
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "Tours", Summary: "s"}, updateOut: llm.Output{Title: "Tours", Summary: "s"}}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+	WithAutoExclude(2)(c)
+	ctx := appCtx(t)
+
+	for _, p := range []struct{ id, title string }{
+		{"p1", "Tour merch giveaway"},
+		{"p2", "Merch drop for the tour"},
+		{"p3", "Tour dates announced"},
+	} {
+		post, err := store.InsertPost(context.Background(), p.id)
+		if err != nil {
+			t.Fatalf("InsertPost: %v", err)
+		}
+		match := newMatch(post.ID, rule.ID, &redditJSON.RedditPost{ID: p.id, Subreddit: "Metalcore", Title: p.title})
+		if err := c.SendMessage(ctx, match); err != nil {
+			t.Fatalf("SendMessage(%s): %v", p.id, err)
+		}
+	}
+	if row, ok := sender.sends[0].Components[0].(discordgo.ActionsRow); !ok || len(row.Components) != 3 ||
+		row.Components[1].(discordgo.Button).CustomID != "feedback:down" {
+		t.Errorf("digest components = %+v, want the vote buttons", sender.sends[0].Components)
+	}
+
+	rp := store.activeDigest(dbstore.ModeNarrative)
+	posts := c.feedbackPosts(ctx, rp)
+	if len(posts) != 3 || posts[1] != (feedbackPost{ID: "p2", Title: "Merch drop for the tour"}) {
+		t.Fatalf("feedbackPosts = %+v", posts)
+	}
+	ch, _ := store.GetDiscordChannel(context.Background(), 1)
+	vote := func(p feedbackPost, user, v string) map[int][]string {
+		t.Helper()
+		ruleIDs, added, err := c.recordFeedback(ctx, ch, p, user, v)
+		if err != nil || !reflect.DeepEqual(ruleIDs, []int{rule.ID}) {
+			t.Fatalf("recordFeedback(%s) = %v, %v", p.ID, ruleIDs, err)
+		}
+		return added
+	}
+	if added := vote(posts[0], "u1", dbstore.VoteDown); len(added) != 0 {
+		t.Errorf("one disliked post added %v", added)
+	}
+	vote(posts[2], "u1", dbstore.VoteUp)
+	// The second disliked post with "merch" crosses the threshold; "tour"
+	// is the rule's own target and the others appear once.
+	if added := vote(posts[1], "u2", dbstore.VoteIrrelevant); !reflect.DeepEqual(added, map[int][]string{rule.ID: {"merch"}}) {
+		t.Errorf("added = %v, want merch on #%d", added, rule.ID)
+	}
+	if r, _ := store.GetRuleByID(context.Background(), rule.ID); !reflect.DeepEqual(r.ExcludeTerms, []string{"merch"}) {
+		t.Errorf("exclude terms = %v", r.ExcludeTerms)
+	}
+
+	embed, components, err := c.ruleStats(ctx, "ext-chan-1")
+	if err != nil || embed == nil {
+		t.Fatalf("ruleStats = %v, %v", embed, err)
+	}
+	for _, want := range []string{"3 matches", "👍 1", "👎 1", "not relevant 1", "precision 33%", "excludes `merch`"} {
+		if !strings.Contains(embed.Description, want) {
+			t.Errorf("stats lack %q:\n%s", want, embed.Description)
+		}
+	}
+	// Everything suggested has been added already.
+	if len(components) != 0 {
+		t.Errorf("stats components = %+v, want none", components)
+	}
+}
+
+func TestDigestFeedback_Reactions(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "tour", TargetID: "title"})
+	sender := &fakeSender{nextMsgID: "digest-1"}
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "Tours", Summary: "s"}, updateOut: llm.Output{Title: "Tours", Summary: "s"}}
+	clock := time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+	ctx := appCtx(t)
+
+	send := func(id, title string) {
+		t.Helper()
+		post, err := store.InsertPost(context.Background(), id)
+		if err != nil {
+			t.Fatalf("InsertPost: %v", err)
+		}
+		if err := c.SendMessage(ctx, newMatch(post.ID, rule.ID, &redditJSON.RedditPost{ID: id, Subreddit: "Metalcore", Title: title})); err != nil {
+			t.Fatalf("SendMessage(%s): %v", id, err)
+		}
+	}
+	votes := func() []dbstore.Feedback {
+		t.Helper()
+		fb, err := store.ListRuleFeedback(context.Background(), rule.ID)
+		if err != nil {
+			t.Fatalf("ListRuleFeedback: %v", err)
+		}
+		return fb
+	}
+
+	send("p1", "Tour announced")
+	for _, r := range []struct{ channel, message, emoji string }{
+		{"ext-chan-1", "digest-1", "🎸"},  // not a vote
+		{"ext-chan-1", "other-msg", "👍"}, // not a digest
+		{"other-chan", "digest-1", "👍"},  // digest of another channel
+	} {
+		if err := c.recordReaction(ctx, r.channel, r.message, "u1", r.emoji); err != nil {
+			t.Fatalf("recordReaction(%+v): %v", r, err)
+		}
+	}
+	if fb := votes(); len(fb) != 0 {
+		t.Fatalf("votes = %+v, want none", fb)
+	}
+
+	if err := c.recordReaction(ctx, "ext-chan-1", "digest-1", "u1", "👎"); err != nil {
+		t.Fatalf("recordReaction: %v", err)
+	}
+	if fb := votes(); len(fb) != 1 || fb[0].PostID != "p1" || fb[0].UserID != "u1" || fb[0].Vote != dbstore.VoteDown {
+		t.Fatalf("votes = %+v, want u1's 👎 on p1", fb)
+	}
+
+	// Once the digest holds two posts a reaction can't name one.
+	send("p2", "Tour dates")
+	if err := c.recordReaction(ctx, "ext-chan-1", "digest-1", "u2", "👍"); err != nil {
+		t.Fatalf("recordReaction: %v", err)
+	}
+	if fb := votes(); len(fb) != 1 {
+		t.Errorf("votes = %+v, want the reaction on a two-post digest ignored", fb)
+	}
+}
+
+func TestQuietHours_HoldsMatchUntilTheyEnd(t *testing.T) {
+	store := newFakeStore(t)
+	rule := store.addRule(dbstore.Rule{Target: "tour", TargetID: "title"})
+	if err := store.UpdateRuleQuietHours(context.Background(), rule.ID, "22:00-07:00"); err != nil {
+		t.Fatalf("UpdateRuleQuietHours: %v", err)
+	}
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{freshOut: llm.Output{Title: "Tour news", Summary: "A tour was announced."}}
+	// 23:30 in Phoenix (UTC-7), inside the quiet hours.
+	clock := time.Date(2026, 4, 17, 6, 30, 0, 0, time.UTC)
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	match := newMatch(100, rule.ID, &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "Tour announced"})
+	match.Rule.QuietHours = "22:00-07:00"
+	if err := c.SendMessage(appCtx(t), match); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	wakeUp := time.Date(2026, 4, 17, 14, 0, 0, 0, time.UTC) // 07:00 Phoenix
+	if j := store.job(1); j.Kind != dbstore.JobKindDeliverMatch || j.Status != dbstore.JobStatusPending || !j.NextAttemptAt.Equal(wakeUp) {
+		t.Fatalf("job = %+v, want a pending deliver_match due at %s", j, wakeUp)
+	}
+	if sender.sendCalls != 0 || store.notifyCalls != 1 {
+		t.Fatalf("send=%d notify=%d, want nothing sent and the match recorded", sender.sendCalls, store.notifyCalls)
+	}
+	// The poller sees the post again: already recorded, so nothing changes.
+	if err := c.SendMessage(appCtx(t), match); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	clock = wakeUp.Add(-time.Minute)
+	c.RetryDueJobs(appCtx(t))
+	if sender.sendCalls != 0 {
+		t.Fatalf("held match delivered before the quiet hours ended")
+	}
+
+	clock = wakeUp
+	c.RetryDueJobs(appCtx(t))
+	if j := store.job(1); j.Status != dbstore.JobStatusDone {
+		t.Fatalf("job status = %q, want done", j.Status)
+	}
+	if sender.sendCalls != 1 || store.activeDigest(dbstore.ModeNarrative).NarrativeTitle != "Tour news" {
+		t.Fatalf("send=%d, want the held match delivered into a digest", sender.sendCalls)
+	}
+
+	// A match held while the rule gets paused is dropped when it comes due.
+	clock = wakeUp.Add(16 * time.Hour) // 23:00 Phoenix
+	held := newMatch(101, rule.ID, &redditJSON.RedditPost{ID: "p2", Subreddit: "Metalcore", Title: "Second tour"})
+	held.Rule.QuietHours = "22:00-07:00"
+	if err := c.SendMessage(appCtx(t), held); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if err := store.UpdateRulePause(context.Background(), rule.ID, true, time.Time{}); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	clock = wakeUp.Add(24 * time.Hour)
+	c.RetryDueJobs(appCtx(t))
+	if j := store.job(2); j.Status != dbstore.JobStatusDone || sender.sendCalls != 1 || sender.editCalls != 0 {
+		t.Errorf("job = %+v, send=%d edit=%d; want it completed without delivering", j, sender.sendCalls, sender.editCalls)
+	}
+}
+
+// Two rules in a channel matching one post during quiet hours each keep
+// their own held match, and each is delivered in its own mode.
+func TestQuietHours_HoldsEveryRulesMatch(t *testing.T) {
+	store := newFakeStore(t)
+	narrative := store.addRule(dbstore.Rule{Target: "tour", TargetID: "title"})
+	music := store.addRule(dbstore.Rule{Target: "tour", TargetID: "title", Mode: dbstore.ModeMusic})
+	sender := &fakeSender{nextMsgID: "msg-1"}
+	shaper := &fakeShaper{
+		freshOut: llm.Output{Title: "Tour news", Summary: "A tour was announced."},
+		musicOut: []llm.MusicEntry{{Artist: "A", Title: "One", Kind: "single"}},
+	}
+	clock := time.Date(2026, 4, 17, 6, 30, 0, 0, time.UTC) // 23:30 Phoenix
+	c := buildClient(store, sender, shaper, func() time.Time { return clock })
+
+	post := &redditJSON.RedditPost{ID: "p1", Subreddit: "Metalcore", Title: "Tour announced"}
+	for _, rule := range []*dbstore.Rule{narrative, music} {
+		if err := store.UpdateRuleQuietHours(context.Background(), rule.ID, "22:00-07:00"); err != nil {
+			t.Fatalf("UpdateRuleQuietHours: %v", err)
+		}
+		match := newMatch(100, rule.ID, post)
+		match.Rule.Mode, match.Rule.QuietHours = rule.Mode, "22:00-07:00"
+		if err := c.SendMessage(appCtx(t), match); err != nil {
+			t.Fatalf("SendMessage(rule %d): %v", rule.ID, err)
+		}
+	}
+	if j1, j2 := store.job(1), store.job(2); j1.RuleID != narrative.ID || j2.RuleID != music.ID {
+		t.Fatalf("jobs = %+v, %+v; want one per rule", j1, j2)
+	}
+
+	clock = time.Date(2026, 4, 17, 14, 0, 0, 0, time.UTC) // 07:00 Phoenix
+	c.RetryDueJobs(appCtx(t))
+	for _, id := range []int{1, 2} {
+		if j := store.job(id); j.Status != dbstore.JobStatusDone {
+			t.Errorf("job %d status = %q, want done", id, j.Status)
+		}
+	}
+	if store.activeDigest(dbstore.ModeNarrative).NarrativeTitle != "Tour news" {
+		t.Error("narrative rule's held match was not delivered")
+	}
+	if rp := store.activeDigest(dbstore.ModeMusic); !strings.Contains(string(rp.Entries), `"One"`) {
+		t.Errorf("music rule's held match was not delivered: %s", rp.Entries)
+	}
+}
+
+func TestRuleState(t *testing.T) {
+	now := time.Date(2026, 4, 16, 12, 0, 0, 0, time.UTC)
+	until := now.Add(3 * time.Hour)
+	tests := []struct {
+		name string
+		rule dbstore.RuleDetail
+		want string
+	}{
+		{"active", dbstore.RuleDetail{}, ""},
+		{"paused", dbstore.RuleDetail{Disabled: true, PausedUntil: until}, " · ⏸️ paused"},
+		{"snoozed", dbstore.RuleDetail{PausedUntil: until}, fmt.Sprintf(" · 💤 snoozed until <t:%d:f>", until.Unix())},
+		{"snooze over", dbstore.RuleDetail{PausedUntil: now.Add(-time.Minute)}, ""},
+		{"quiet", dbstore.RuleDetail{QuietHours: "22:00-07:00"}, " · 🌙 quiet `22:00-07:00`"},
+	}
+	for _, tt := range tests {
+		if got := ruleState(&tt.rule, now); got != tt.want {
+			t.Errorf("%s: ruleState = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSnooze(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"90m": 90 * time.Minute,
+		"8h":  8 * time.Hour,
+		"3d":  72 * time.Hour,
+		"30D": 30 * 24 * time.Hour,
+	} {
+		if got, err := parseSnooze(in); err != nil || got != want {
+			t.Errorf("parseSnooze(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "soon", "30s", "31d", "-2h", "1.5d"} {
+		if _, err := parseSnooze(in); err == nil {
+			t.Errorf("parseSnooze(%q): want error", in)
+		}
+	}
+}
+
+func TestChannelLocks(t *testing.T) {
+	var l channelLocks
+	unlock := l.lock(1)
+	// Another channel isn't held up.
+	l.lock(2)()
+
+	acquired := make(chan struct{})
+	go func() {
+		defer l.lock(1)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second lock of the same channel should wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("lock was not handed over after unlock")
+	}
+}
@@ -0,0 +1,202 @@
+package discord
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/llm"
+)
+
+// defaultStreamEditInterval is the minimum gap between progressive edits of
+// one digest while an LLM completion streams in. Discord allows roughly five
+// message edits per five seconds per channel; one every 3s leaves headroom
+// for the thread replies and other digests in the same channel.
+const defaultStreamEditInterval = 3 * time.Second
+
+// streamingFooterSuffix marks an embed as an in-flight render. The final
+// (persisted) render never carries it.
+const streamingFooterSuffix = " • writing…"
+
+// progressThrottle gates progressive renders to one per interval. The first
+// call always passes so a new digest shows up as soon as there's something
+// to show.
+type progressThrottle struct {
+	interval time.Duration
+	now      func() time.Time
+	last     time.Time
+}
+
+func (t *progressThrottle) allow() bool {
+	now := t.now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		return false
+	}
+	t.last = now
+	return true
+}
+
+func (c *Client) newProgressThrottle() progressThrottle {
+	return progressThrottle{interval: c.streamEditInterval, now: c.now}
+}
+
+// narrativeStream renders partial narrative output into the digest's parent
+// message while the shaper streams. messageID starts as the existing digest
+// message (empty for a new digest); the first tick of a new digest sends
+// the message and SendMessage then edits that one with the final render.
+// Nothing is persisted here — rolling_posts is only written once the final
+// output is known.
+type narrativeStream struct {
+	c         *Client
+	ctx       ctxpkg.Ctx
+	channelID string
+	messageID string
+	throttle  progressThrottle
+	render    func(llm.Output) *discordgo.MessageEmbed
+}
+
+func (n *narrativeStream) progress(out llm.Output) {
+	if !n.throttle.allow() {
+		return
+	}
+	embed := n.render(out)
+	if embed.Footer != nil {
+		embed.Footer.Text += streamingFooterSuffix
+	}
+	if n.messageID == "" {
+		msg, err := n.c.sender.ChannelMessageSendComplex(n.channelID, &discordgo.MessageSend{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		})
+		if err != nil {
+			_ = level.Warn(n.ctx.Log()).Log("msg", "progressive digest send failed (non-fatal)", "error", err)
+			return
+		}
+		n.messageID = msg.ID
+		return
+	}
+	if _, err := n.c.sender.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel: n.channelID,
+		ID:      n.messageID,
+		Embeds:  &[]*discordgo.MessageEmbed{embed},
+	}); err != nil {
+		// The final edit has the 404 → fresh-send fallback; a missed
+		// progressive frame is cosmetic.
+		_ = level.Warn(n.ctx.Log()).Log("msg", "progressive digest edit failed (non-fatal)", "error", err)
+	}
+}
+
+// musicStream is the music-mode counterpart: partial entries re-render the
+// parent card and thread replies (without enrichment, which only runs on the
+// final entry set). It tracks the message/thread ids it creates so the final
+// sync in handleMusicMatch edits them instead of posting duplicates.
+type musicStream struct {
+	c         *Client
+	ctx       ctxpkg.Ctx
+	existing  *dbstore.RollingPost
+	result    *evaluator.MatchingEvaluationResult
+	ch        *dbstore.DiscordChannel
+	subreddit *dbstore.Subreddit
+	dayLocal  time.Time
+	throttle  progressThrottle
+
+	parentIDs      []string
+	threadID       string
+	threadReplyIDs []string
+	// touched is true once a progressive render has reached Discord, i.e.
+	// there's on-screen state that a failed shape must roll back.
+	touched bool
+}
+
+func (c *Client) newMusicStream(
+	ctx ctxpkg.Ctx,
+	existing *dbstore.RollingPost,
+	result *evaluator.MatchingEvaluationResult,
+	ch *dbstore.DiscordChannel,
+	subreddit *dbstore.Subreddit,
+	dayLocal time.Time,
+) *musicStream {
+	m := &musicStream{
+		c: c, ctx: ctx, existing: existing, result: result,
+		ch: ch, subreddit: subreddit, dayLocal: dayLocal,
+		throttle: c.newProgressThrottle(),
+	}
+	if existing != nil {
+		m.parentIDs = existing.DiscordMessageIDs
+		m.threadID = existing.ThreadID
+		m.threadReplyIDs = existing.ThreadMessageIDs
+	}
+	return m
+}
+
+func (m *musicStream) progress(partial []llm.MusicEntry) {
+	if len(partial) == 0 || !m.throttle.allow() {
+		return
+	}
+	rp, merged, err := buildMusicRollingPost(m.existing, m.result, m.subreddit, m.dayLocal, partial)
+	if err != nil || len(merged) == 0 {
+		return
+	}
+	if err := m.render(rp, merged, true); err != nil {
+		_ = level.Warn(m.ctx.Log()).Log("msg", "progressive music render failed (non-fatal)", "error", err)
+	}
+}
+
+// render syncs card + thread to the given entries, updating the tracked ids.
+func (m *musicStream) render(rp dbstore.RollingPost, entries []llm.MusicEntry, inFlight bool) error {
+	subNames := m.c.resolveSubredditNames(m.ctx, rp.SubredditIDs)
+	card := renderMusicCard(rp, entries, subNames)
+	if inFlight && card.Footer != nil {
+		card.Footer.Text += streamingFooterSuffix
+	}
+	parentIDs, err := m.c.syncParentCard(m.ctx, m.ch.ExternalID, m.parentIDs, card)
+	if err != nil {
+		return err
+	}
+	m.parentIDs = parentIDs
+	m.touched = true
+
+	threadID, err := m.c.ensureThread(m.ctx, m.ch.ExternalID, parentIDs[0], m.threadID, subNames)
+	if err != nil {
+		return err
+	}
+	m.threadID = threadID
+	replies, err := m.c.syncThreadReplies(m.ctx, threadID, m.threadReplyIDs, renderMusicThreadEmbeds(rp, entries))
+	if err != nil {
+		return err
+	}
+	m.threadReplyIDs = replies
+	return nil
+}
+
+// rollback restores on-screen state after a shape failure that happened
+// mid-stream: an existing digest is re-rendered from its persisted entries,
+// and a digest that only ever existed as progressive frames is deleted.
+func (m *musicStream) rollback() {
+	if !m.touched {
+		return
+	}
+	if m.existing != nil {
+		known, err := decodeMusicEntries(m.existing.Entries)
+		if err == nil {
+			err = m.render(*m.existing, known, false)
+		}
+		if err != nil {
+			_ = level.Warn(m.ctx.Log()).Log("msg", "failed to restore music digest after stream failure", "error", err)
+		}
+		return
+	}
+	if m.threadID != "" {
+		if _, err := m.c.sender.ChannelDelete(m.threadID); err != nil && !isMessageGone(err) {
+			_ = level.Warn(m.ctx.Log()).Log("msg", "failed to delete partial digest thread", "thread_id", m.threadID, "error", err)
+		}
+	}
+	for _, id := range m.parentIDs {
+		if err := m.c.sender.ChannelMessageDelete(m.ch.ExternalID, id); err != nil && !isMessageGone(err) {
+			_ = level.Warn(m.ctx.Log()).Log("msg", "failed to delete partial digest card", "message_id", id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	ctx "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// TargetClassification is the rule target matched against a post's LLM
+	// classification instead of one of its fields.
+	TargetClassification = "classification"
+
+	// DefaultClassifyPerMinute caps classifier calls when
+	// CLASSIFY_RATE_PER_MINUTE isn't set.
+	DefaultClassifyPerMinute = 30
+)
+
+// Classifier labels a post. *llm.Shaper implements it.
+type Classifier interface {
+	Classify(ctx context.Context, post *redditJson.RedditPost, topics []string) (llm.Classification, error)
+}
+
+// ClassificationQuery is a parsed classification rule value. Every set
+// field must match.
+type ClassificationQuery struct {
+	Sentiment string
+	// Topics must all be among the post's topics.
+	Topics []string
+	// Toxic, when non-nil, must equal the post's toxicity flag.
+	Toxic *bool
+}
+
+// ParseClassificationQuery parses a rule value of space-separated clauses
+// — "sentiment:negative", "topic:bug" (repeatable) and "toxic:true" — that
+// are ANDed. topics, when non-empty, is the configured taxonomy that topic
+// clauses are checked against.
+func ParseClassificationQuery(s string, topics []string) (ClassificationQuery, error) {
+	var q ClassificationQuery
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return q, fmt.Errorf("empty classification query (use e.g. sentiment:negative topic:bug toxic:true)")
+	}
+	for _, f := range fields {
+		key, val, ok := strings.Cut(f, ":")
+		if !ok || val == "" {
+			return q, fmt.Errorf("clause %q must be key:value", f)
+		}
+		switch key {
+		case "sentiment":
+			if !slices.Contains(llm.Sentiments, val) {
+				return q, fmt.Errorf("unknown sentiment %q (want %s)", val, strings.Join(llm.Sentiments, ", "))
+			}
+			if q.Sentiment != "" && q.Sentiment != val {
+				return q, fmt.Errorf("a post has one sentiment; got both %q and %q", q.Sentiment, val)
+			}
+			q.Sentiment = val
+		case "topic":
+			if len(topics) > 0 && !slices.Contains(topics, val) {
+				return q, fmt.Errorf("unknown topic %q (want one of %s)", val, strings.Join(topics, ", "))
+			}
+			q.Topics = append(q.Topics, val)
+		case "toxic":
+			var b bool
+			switch val {
+			case "true", "yes":
+				b = true
+			case "false", "no":
+			default:
+				return q, fmt.Errorf("toxic must be true or false, got %q", val)
+			}
+			q.Toxic = &b
+		default:
+			return q, fmt.Errorf("unknown key %q (want sentiment, topic or toxic)", key)
+		}
+	}
+	return q, nil
+}
+
+// Matches reports whether pc satisfies every clause of q.
+func (q ClassificationQuery) Matches(pc *dbstore.PostClassification) bool {
+	if pc == nil {
+		return false
+	}
+	if q.Sentiment != "" && q.Sentiment != pc.Sentiment {
+		return false
+	}
+	for _, t := range q.Topics {
+		if !slices.Contains(pc.Topics, t) {
+			return false
+		}
+	}
+	return q.Toxic == nil || *q.Toxic == pc.Toxic
+}
+
+// Option configures a RuleEvaluation.
+type Option func(*RuleEvaluation)
+
+// WithClassifier enables classification rules. topics is the taxonomy
+// passed to cl (empty uses llm.DefaultTopics) and perMinute caps classifier
+// calls across all subreddits; posts beyond it go unclassified.
+func WithClassifier(cl Classifier, topics []string, perMinute int) Option {
+	return func(e *RuleEvaluation) {
+		if perMinute <= 0 {
+			perMinute = DefaultClassifyPerMinute
+		}
+		e.classifier = cl
+		e.topics = topics
+		e.classifyLimit = newTokenBucket(perMinute, time.Minute, time.Now)
+	}
+}
+
+// hasTarget reports whether any rule matches on target. It gates the
+// per-post LLM work — classification, embedding — to subreddits that need
+// it.
+func hasTarget(rules []*dbstore.Rule, target string) bool {
+	for _, r := range rules {
+		if r.TargetID == target {
+			return true
+		}
+	}
+	return false
+}
+
+// classification returns post's labels, classifying and storing them on
+// first sight. It returns nil when no classifier is configured, the rate
+// limit is spent, or classification failed; classification rules then
+// don't match the post.
+func (e *RuleEvaluation) classification(c ctx.Ctx, post *redditJson.RedditPost) *dbstore.PostClassification {
+	if e.classifier == nil {
+		return nil
+	}
+	pc, err := e.store.GetPostClassification(c, post.ID)
+	if err != nil {
+		_ = level.Warn(c.Log()).Log("msg", "read post classification failed", "post_id", post.ID, "error", err)
+	}
+	if pc != nil {
+		return pc
+	}
+	if !e.classifyLimit.take() {
+		_ = level.Warn(c.Log()).Log("msg", "classification rate limit reached; skipping post", "post_id", post.ID)
+		return nil
+	}
+	out, err := e.classifier.Classify(c, post, e.topics)
+	if err != nil {
+		_ = level.Warn(c.Log()).Log("msg", "classify post failed", "post_id", post.ID, "error", err)
+		return nil
+	}
+	pc = &dbstore.PostClassification{PostID: post.ID, Sentiment: out.Sentiment, Topics: out.Topics, Toxic: out.Toxic}
+	if err := e.store.UpsertPostClassification(c, *pc); err != nil {
+		_ = level.Warn(c.Log()).Log("msg", "store post classification failed", "post_id", post.ID, "error", err)
+	}
+	return pc
+}
+
+// tokenBucket allows burst calls at once, refilling at burst per interval.
+type tokenBucket struct {
+	mu       sync.Mutex
+	burst    float64
+	perNanos float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+func newTokenBucket(burst int, interval time.Duration, now func() time.Time) *tokenBucket {
+	return &tokenBucket{
+		burst:    float64(burst),
+		perNanos: float64(burst) / float64(interval),
+		tokens:   float64(burst),
+		last:     now(),
+		now:      now,
+	}
+}
+
+// take spends a token, reporting false when none is left.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+float64(now.Sub(b.last))*b.perNanos)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ctx "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+type fakeClassifier struct {
+	out   llm.Classification
+	err   error
+	calls int
+}
+
+func (f *fakeClassifier) Classify(_ context.Context, _ *redditJson.RedditPost, _ []string) (llm.Classification, error) {
+	f.calls++
+	return f.out, f.err
+}
+
+func TestParseClassificationQuery(t *testing.T) {
+	q, err := ParseClassificationQuery("Sentiment:negative topic:bug topic:crash toxic:false", []string{"bug", "crash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Sentiment != "negative" || len(q.Topics) != 2 || q.Toxic == nil || *q.Toxic {
+		t.Fatalf("query = %+v", q)
+	}
+
+	for _, bad := range []string{"", "negative", "sentiment:angry", "topic:memes", "toxic:maybe", "colour:red", "sentiment:positive sentiment:negative"} {
+		if _, err := ParseClassificationQuery(bad, []string{"bug"}); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestClassificationQuery_Matches(t *testing.T) {
+	pc := &dbstore.PostClassification{Sentiment: "negative", Topics: []string{"bug", "performance"}, Toxic: false}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"sentiment:negative", true},
+		{"sentiment:positive", false},
+		{"topic:bug topic:performance", true},
+		{"topic:bug topic:crash", false},
+		{"toxic:false", true},
+		{"toxic:true", false},
+		{"sentiment:negative topic:bug toxic:false", true},
+	}
+	for _, tt := range tests {
+		q, err := ParseClassificationQuery(tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Matches(pc); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	q, _ := ParseClassificationQuery("toxic:false", nil)
+	if q.Matches(nil) {
+		t.Error("an unclassified post should never match")
+	}
+}
+
+func TestEvaluate_ClassificationRule(t *testing.T) {
+	store := &mockStore{rules: []*dbstore.Rule{
+		{ID: 7, Target: "sentiment:negative topic:bug", TargetID: TargetClassification, DiscordChannelID: 1},
+	}}
+	cl := &fakeClassifier{out: llm.Classification{Sentiment: "negative", Topics: []string{"bug"}}}
+	e := NewRuleEvaluator(store, WithClassifier(cl, nil, 10))
+	c := ctx.New(context.Background())
+
+	posts := []*redditJson.RedditPost{{ID: "p1", Subreddit: "golang"}}
+	results := make(chan *MatchingEvaluationResult, 4)
+	if err := e.Evaluate(c, posts, results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || cl.calls != 1 {
+		t.Fatalf("results = %d, classifier calls = %d; want 1 and 1", len(results), cl.calls)
+	}
+	if got := store.classifications["p1"]; got.Sentiment != "negative" {
+		t.Errorf("stored classification = %+v", got)
+	}
+
+	// A second sighting reuses the stored labels.
+	if err := e.Evaluate(c, posts, results); err != nil {
+		t.Fatal(err)
+	}
+	if cl.calls != 1 || len(results) != 2 {
+		t.Errorf("classifier calls = %d, results = %d; want the cached labels reused", cl.calls, len(results))
+	}
+}
+
+func TestEvaluate_ClassifierOnlyForClassificationRules(t *testing.T) {
+	cl := &fakeClassifier{}
+	e := NewRuleEvaluator(&mockStore{}, WithClassifier(cl, nil, 10))
+	results := make(chan *MatchingEvaluationResult, 4)
+	posts := []*redditJson.RedditPost{{ID: "p1", Subreddit: "golang", Title: "a test"}}
+	if err := e.Evaluate(ctx.New(context.Background()), posts, results); err != nil {
+		t.Fatal(err)
+	}
+	if cl.calls != 0 || len(results) != 1 {
+		t.Errorf("classifier calls = %d, results = %d; want 0 and 1", cl.calls, len(results))
+	}
+}
+
+func TestEvaluate_ClassificationFailureSkipsRule(t *testing.T) {
+	store := &mockStore{rules: []*dbstore.Rule{
+		{ID: 1, Target: "toxic:false", TargetID: TargetClassification, DiscordChannelID: 1},
+		{ID: 2, Target: "test", TargetID: "title", DiscordChannelID: 1},
+	}}
+	cl := &fakeClassifier{err: errors.New("backend down")}
+	e := NewRuleEvaluator(store, WithClassifier(cl, nil, 10))
+	results := make(chan *MatchingEvaluationResult, 4)
+	posts := []*redditJson.RedditPost{{ID: "p1", Subreddit: "golang", Title: "a test"}}
+	if err := e.Evaluate(ctx.New(context.Background()), posts, results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || (<-results).RuleID != 2 {
+		t.Error("only the title rule should match when classification fails")
+	}
+	if len(store.classifications) != 0 {
+		t.Error("a failed classification should not be stored")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, time.Minute, func() time.Time { return now })
+	if !b.take() || !b.take() {
+		t.Fatal("burst of 2 should be available")
+	}
+	if b.take() {
+		t.Fatal("third take should be refused")
+	}
+	now = now.Add(30 * time.Second)
+	if !b.take() {
+		t.Error("half a minute should refill one token")
+	}
+	if b.take() {
+		t.Error("only one token should have refilled")
+	}
+}
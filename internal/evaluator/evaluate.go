@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
 
 	ctx "github.com/meriley/reddit-spy/internal/context"
 
@@ -24,6 +27,12 @@ const (
 type RuleEvaluation struct {
 	store                   dbstore.Store
 	EvaluateResponseChannel chan *MatchingEvaluationResult
+
+	classifier    Classifier
+	topics        []string
+	classifyLimit *tokenBucket
+
+	embedder Embedder
 }
 
 type MatchingEvaluationResult struct {
@@ -42,6 +51,9 @@ func (e *RuleEvaluation) Evaluate(
 	// Deduplicate subreddit lookups: all posts in a batch share the same subreddit
 	subredditCache := make(map[string]*dbstore.Subreddit)
 	rulesCache := make(map[int][]*dbstore.Rule)
+	// Semantic rules' description vectors, embedded at most once per batch.
+	// A nil entry records a failure so it isn't retried for every post.
+	ruleVecs := make(map[int][]float32)
 
 	for _, p := range posts {
 		subredditName := p.Subreddit
@@ -63,25 +75,62 @@ func (e *RuleEvaluation) Evaluate(
 			if err != nil {
 				return fmt.Errorf("failed to fetch rules for %s: %w", subredditName, err)
 			}
+			// Paused and snoozed rules are dropped up front so they don't
+			// spend classifier or embedding calls either.
+			var active []*dbstore.Rule
+			for _, r := range rules {
+				if r.Paused(time.Now()) {
+					_ = level.Debug(ctx.Log()).Log("msg", "skipping paused rule", "rule_id", r.ID)
+					continue
+				}
+				active = append(active, r)
+			}
+			rules = active
 			rulesCache[subreddit.ID] = rules
 		}
 
+		// Only subreddits with a classification rule spend classifier calls.
+		var classification *dbstore.PostClassification
+		if hasTarget(rules, TargetClassification) {
+			classification = e.classification(ctx, p)
+		}
+		// Likewise posts are only embedded where a semantic rule needs them.
+		var postVec []float32
+		if e.embedder != nil && hasTarget(rules, TargetSemantic) {
+			postVec = e.semanticVectors(ctx, p, rules, ruleVecs)
+		}
+
 		eg, egCtx := errgroup.WithContext(ctx)
 		eg.SetLimit(maxConcurrentInserts)
 		for _, r := range rules {
 			p := p
 			r := r
 			eg.Go(func() error {
-				value, err := getValue(p, r)
-				if err != nil {
-					return fmt.Errorf("failed to get value for post %s: %w", p.ID, err)
+				if Excluded(p, r.ExcludeTerms) {
+					_ = level.Debug(ctx.Log()).Log("msg", "post excluded by rule term", "rule_id", r.ID, "post", p.ID)
+					return nil
 				}
-
 				var result bool
-				if r.Exact {
-					result = evaluateExact(value, r.Target)
+				if r.TargetID == TargetClassification {
+					q, err := ParseClassificationQuery(r.Target, nil)
+					if err != nil {
+						// One bad rule shouldn't stop the others.
+						_ = level.Warn(ctx.Log()).Log("msg", "skipping invalid classification rule", "rule_id", r.ID, "error", err)
+						return nil
+					}
+					result = q.Matches(classification)
+				} else if r.TargetID == TargetSemantic {
+					result = semanticMatch(postVec, ruleVecs[r.ID], r.Threshold)
 				} else {
-					result = evaluatePartial(value, r.Target)
+					value, err := getValue(p, r)
+					if err != nil {
+						return fmt.Errorf("failed to get value for post %s: %w", p.ID, err)
+					}
+					if r.Exact {
+						result = evaluateExact(value, r.Target)
+					} else {
+						result = evaluatePartial(value, r.Target)
+					}
 				}
 
 				if result {
@@ -130,9 +179,13 @@ func evaluatePartial(value string, expected string) bool {
 	return strings.Contains(strings.ToLower(value), strings.ToLower(expected))
 }
 
-func NewRuleEvaluator(store dbstore.Store) *RuleEvaluation {
-	return &RuleEvaluation{
+func NewRuleEvaluator(store dbstore.Store, opts ...Option) *RuleEvaluation {
+	e := &RuleEvaluation{
 		store:                   store,
 		EvaluateResponseChannel: make(chan *MatchingEvaluationResult, EvalChannelBuffer),
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
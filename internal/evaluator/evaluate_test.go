@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	ctx "github.com/meriley/reddit-spy/internal/context"
 	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
 )
@@ -107,8 +108,30 @@ func TestNewRuleEvaluator(t *testing.T) {
 	}
 }
 
+func TestEvaluate_SkipsPausedRules(t *testing.T) {
+	store := &mockStore{rules: []*dbstore.Rule{
+		{ID: 1, Target: "test", TargetID: "title", DiscordChannelID: 1, Disabled: true},
+		{ID: 2, Target: "test", TargetID: "title", DiscordChannelID: 1, PausedUntil: time.Now().Add(time.Hour)},
+		{ID: 3, Target: "test", TargetID: "title", DiscordChannelID: 1, PausedUntil: time.Now().Add(-time.Minute)},
+	}}
+	e := NewRuleEvaluator(store)
+	results := make(chan *MatchingEvaluationResult, 4)
+	posts := []*redditJson.RedditPost{{ID: "p1", Subreddit: "golang", Title: "a test"}}
+	if err := e.Evaluate(ctx.New(context.Background()), posts, results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || (<-results).RuleID != 3 {
+		t.Error("only the rule whose snooze has run out should match")
+	}
+}
+
 // mockStore implements dbstore.Store for testing
-type mockStore struct{}
+type mockStore struct {
+	// rules replaces the default single title rule when set.
+	rules           []*dbstore.Rule
+	classifications map[string]dbstore.PostClassification
+	embeddings      map[int]dbstore.RuleEmbedding
+}
 
 func (m *mockStore) InsertDiscordServer(_ context.Context, _ string) (*dbstore.DiscordServer, error) {
 	return &dbstore.DiscordServer{ID: 1}, nil
@@ -142,6 +165,9 @@ func (m *mockStore) GetDiscordChannelByExternalID(_ context.Context, _ string) (
 	return &dbstore.DiscordChannel{ID: 1}, nil
 }
 func (m *mockStore) GetRules(_ context.Context, _ int) ([]*dbstore.Rule, error) {
+	if m.rules != nil {
+		return m.rules, nil
+	}
 	return []*dbstore.Rule{
 		{ID: 1, Target: "test", TargetID: "title", Exact: false, DiscordChannelID: 1},
 	}, nil
@@ -179,9 +205,46 @@ func (m *mockStore) GetQobuzAlbum(_ context.Context, _ string) (string, time.Tim
 	return "", time.Time{}, false, nil
 }
 func (m *mockStore) UpsertQobuzAlbum(_ context.Context, _, _ string) error { return nil }
+func (m *mockStore) GetCachedArticle(_ context.Context, _ string) (*dbstore.CachedArticle, error) {
+	return nil, nil
+}
+func (m *mockStore) UpsertCachedArticle(_ context.Context, _ dbstore.CachedArticle) error { return nil }
+func (m *mockStore) GetPostClassification(_ context.Context, postID string) (*dbstore.PostClassification, error) {
+	if pc, ok := m.classifications[postID]; ok {
+		return &pc, nil
+	}
+	return nil, nil
+}
+func (m *mockStore) GetRuleEmbedding(_ context.Context, ruleID int) (*dbstore.RuleEmbedding, error) {
+	if re, ok := m.embeddings[ruleID]; ok {
+		return &re, nil
+	}
+	return nil, nil
+}
+func (m *mockStore) UpsertRuleEmbedding(_ context.Context, re dbstore.RuleEmbedding) error {
+	if m.embeddings == nil {
+		m.embeddings = map[int]dbstore.RuleEmbedding{}
+	}
+	m.embeddings[re.RuleID] = re
+	return nil
+}
+func (m *mockStore) GetDigestItems(_ context.Context, _ int) ([]dbstore.DigestItem, error) {
+	return nil, nil
+}
+func (m *mockStore) InsertDigestItem(_ context.Context, _ dbstore.DigestItem) error { return nil }
+func (m *mockStore) UpsertPostClassification(_ context.Context, pc dbstore.PostClassification) error {
+	if m.classifications == nil {
+		m.classifications = map[string]dbstore.PostClassification{}
+	}
+	m.classifications[pc.PostID] = pc
+	return nil
+}
 func (m *mockStore) GetSubreddits(_ context.Context) ([]*dbstore.Subreddit, error) {
 	return nil, nil
 }
+func (m *mockStore) GetActiveSubreddits(_ context.Context) ([]*dbstore.Subreddit, error) {
+	return nil, nil
+}
 func (m *mockStore) GetNotificationCount(_ context.Context, _, _, _ int) (int, error) {
 	return 0, nil
 }
@@ -191,6 +254,91 @@ func (m *mockStore) GetActiveRollingPost(_ context.Context, _ int, _ string, _ i
 func (m *mockStore) UpsertRollingPost(_ context.Context, _ dbstore.RollingPost) (*dbstore.RollingPost, error) {
 	return nil, nil
 }
+func (m *mockStore) GetRollingPostByMessageID(_ context.Context, _ string) (*dbstore.RollingPost, error) {
+	return nil, nil
+}
+func (m *mockStore) GetRollingPost(_ context.Context, _ int) (*dbstore.RollingPost, error) {
+	return nil, nil
+}
+func (m *mockStore) ListRollingPosts(_ context.Context, _ int, _ string, _ time.Time, _ int) ([]*dbstore.RollingPost, error) {
+	return nil, nil
+}
+func (m *mockStore) CloseRollingPost(_ context.Context, _ int) error { return nil }
+func (m *mockStore) ReopenRollingPost(_ context.Context, _ int, _ time.Time) error {
+	return nil
+}
+func (m *mockStore) MergeRollingPosts(_ context.Context, _ dbstore.RollingPost, _ int) (*dbstore.RollingPost, error) {
+	return nil, nil
+}
 func (m *mockStore) UpdateRuleWindowHours(_ context.Context, _, _ int) error {
 	return nil
 }
+func (m *mockStore) UpdateRuleThreshold(_ context.Context, _ int, _ float64) error {
+	return nil
+}
+func (m *mockStore) UpdateRuleExcludeTerms(_ context.Context, _ int, _ []string) error {
+	return nil
+}
+func (m *mockStore) UpdateRulePause(_ context.Context, _ int, _ bool, _ time.Time) error {
+	return nil
+}
+func (m *mockStore) UpdateRuleQuietHours(_ context.Context, _ int, _ string) error {
+	return nil
+}
+func (m *mockStore) RecordFeedback(_ context.Context, _ dbstore.Feedback) error { return nil }
+func (m *mockStore) GetNotifiedRules(_ context.Context, _ int, _ string) ([]int, error) {
+	return nil, nil
+}
+func (m *mockStore) GetRuleStats(_ context.Context, _ int) ([]dbstore.RuleStats, error) {
+	return nil, nil
+}
+func (m *mockStore) ListRuleFeedback(_ context.Context, _ int) ([]dbstore.Feedback, error) {
+	return nil, nil
+}
+func (m *mockStore) GetLLMCompletion(_ context.Context, _ string) (string, time.Time, bool, error) {
+	return "", time.Time{}, false, nil
+}
+func (m *mockStore) UpsertLLMCompletion(_ context.Context, _, _, _ string) error { return nil }
+func (m *mockStore) PruneLLMCompletions(_ context.Context, _ time.Duration, _ int) (int64, error) {
+	return 0, nil
+}
+func (m *mockStore) PruneRows(_ context.Context, _ string, _ time.Time, _ int) (int64, error) {
+	return 0, nil
+}
+func (m *mockStore) GetGuildConfig(_ context.Context, _ string) ([]dbstore.GuildChannel, error) {
+	return nil, nil
+}
+func (m *mockStore) ApplyGuildConfig(_ context.Context, _ string, _ []dbstore.GuildChannel) error {
+	return nil
+}
+func (m *mockStore) ReconcileManagedRules(_ context.Context, _ []dbstore.ManagedGuild) (int64, error) {
+	return 0, nil
+}
+func (m *mockStore) EnqueueLLMJob(_ context.Context, _ dbstore.LLMJob) (*dbstore.LLMJob, error) {
+	return nil, nil
+}
+func (m *mockStore) ClaimDueLLMJobs(_ context.Context, _ int) ([]*dbstore.LLMJob, error) {
+	return nil, nil
+}
+func (m *mockStore) CompleteLLMJob(_ context.Context, _ int) error { return nil }
+func (m *mockStore) FailLLMJob(_ context.Context, _ int, _ string, _ time.Time) error {
+	return nil
+}
+func (m *mockStore) ListFailedLLMJobs(_ context.Context, _, _ int) ([]*dbstore.LLMJob, error) {
+	return nil, nil
+}
+func (m *mockStore) GetLLMJob(_ context.Context, _ int) (*dbstore.LLMJob, error)  { return nil, nil }
+func (m *mockStore) RequeueLLMJob(_ context.Context, _ int) (bool, error)         { return false, nil }
+func (m *mockStore) UpsertPromptTemplate(_ context.Context, _, _, _ string) error { return nil }
+func (m *mockStore) GetPromptTemplate(_ context.Context, _, _ string) (*dbstore.PromptTemplate, error) {
+	return nil, nil
+}
+func (m *mockStore) ListPromptTemplates(_ context.Context) ([]*dbstore.PromptTemplate, error) {
+	return nil, nil
+}
+func (m *mockStore) SetRulePrompt(_ context.Context, _ int, _, _ *string) error    { return nil }
+func (m *mockStore) SetChannelPrompt(_ context.Context, _ int, _, _ *string) error { return nil }
+func (m *mockStore) SetChannelLanguage(_ context.Context, _ int, _ string) error   { return nil }
+func (m *mockStore) GetPromptSettings(_ context.Context, _, _ int) (dbstore.PromptSettings, error) {
+	return dbstore.PromptSettings{}, nil
+}
@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// DefaultExcludeMinPosts is how many negatively rated posts a word has
+	// to appear in before SuggestExclusions offers it.
+	DefaultExcludeMinPosts = 3
+
+	// maxExcludeSuggestions caps SuggestExclusions so a rule isn't handed
+	// half a dictionary at once.
+	maxExcludeSuggestions = 5
+
+	// minExcludeWordRunes drops words too short to be a useful term; as a
+	// substring they'd rule out far more than the posts they came from.
+	minExcludeWordRunes = 4
+)
+
+// excludeStopWords are common title words that say nothing about a topic.
+var excludeStopWords = map[string]bool{
+	"about": true, "after": true, "again": true, "also": true, "been": true, "before": true,
+	"being": true, "does": true, "from": true, "have": true, "here": true, "into": true,
+	"just": true, "like": true, "more": true, "most": true, "much": true, "only": true,
+	"other": true, "over": true, "some": true, "than": true, "that": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true, "this": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true, "will": true,
+	"with": true, "would": true, "your": true, "anyone": true, "thoughts": true,
+}
+
+// Excluded reports whether post's title contains one of terms, matched
+// case-insensitively as a substring, the way partial rules match.
+func Excluded(post *redditJson.RedditPost, terms []string) bool {
+	return containsTerm(post.Title, terms)
+}
+
+func containsTerm(text string, terms []string) bool {
+	text = strings.ToLower(text)
+	for _, t := range terms {
+		if t != "" && strings.Contains(text, strings.ToLower(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestExclusions returns words that appear in the titles of at least
+// minPosts posts rated mostly negative and in none of the posts rated
+// mostly positive, most frequent first. Words of the rule's own target, or
+// already caught by one of its exclude terms, aren't offered. Each post
+// counts once however many users voted on it.
+func SuggestExclusions(target string, exclude []string, feedback []dbstore.Feedback, minPosts int) []string {
+	if minPosts <= 0 {
+		minPosts = DefaultExcludeMinPosts
+	}
+
+	type tally struct {
+		title    string
+		up, down int
+	}
+	posts := map[string]*tally{}
+	for _, fb := range feedback {
+		t := posts[fb.PostID]
+		if t == nil {
+			t = &tally{}
+			posts[fb.PostID] = t
+		}
+		if fb.Title != "" {
+			t.title = fb.Title
+		}
+		if fb.Vote == dbstore.VoteUp {
+			t.up++
+		} else if dbstore.IsNegativeVote(fb.Vote) {
+			t.down++
+		}
+	}
+
+	counts := map[string]int{}
+	kept := map[string]bool{}
+	for _, t := range posts {
+		words := titleWords(t.title)
+		switch {
+		case t.down > t.up:
+			for _, w := range words {
+				counts[w]++
+			}
+		case t.up > 0:
+			for _, w := range words {
+				kept[w] = true
+			}
+		}
+	}
+
+	ruleWords := titleWords(target)
+	var out []string
+	for w, n := range counts {
+		if n < minPosts || kept[w] || slices.Contains(ruleWords, w) || containsTerm(w, exclude) {
+			continue
+		}
+		out = append(out, w)
+	}
+	slices.SortFunc(out, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if len(out) > maxExcludeSuggestions {
+		out = out[:maxExcludeSuggestions]
+	}
+	return out
+}
+
+// titleWords splits title into its distinct lowercase words worth
+// suggesting: letters or digits, not all digits, not a stop word.
+func titleWords(title string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) < minExcludeWordRunes || excludeStopWords[w] || slices.Contains(out, w) {
+			continue
+		}
+		if strings.IndexFunc(w, unicode.IsLetter) < 0 {
+			continue
+		}
+		out = append(out, w)
+	}
+	return out
+}
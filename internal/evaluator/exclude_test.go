@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"slices"
+	"testing"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		terms []string
+		want  bool
+	}{
+		{"no terms", "Fresh album drop", nil, false},
+		{"substring match", "Fresh album drop", []string{"album"}, true},
+		{"case insensitive", "Fresh ALBUM drop", []string{"Album"}, true},
+		{"no match", "Fresh album drop", []string{"tour"}, false},
+		{"empty term ignored", "Fresh album drop", []string{""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Excluded(&redditJson.RedditPost{Title: tt.title}, tt.terms)
+			if got != tt.want {
+				t.Errorf("Excluded(%q, %v) = %v, want %v", tt.title, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestExclusions(t *testing.T) {
+	vote := func(post, user, vote, title string) dbstore.Feedback {
+		return dbstore.Feedback{PostID: post, RuleID: 1, UserID: user, Vote: vote, Title: title}
+	}
+	feedback := []dbstore.Feedback{
+		vote("a", "u1", dbstore.VoteDown, "Metalcore merch giveaway"),
+		vote("b", "u1", dbstore.VoteIrrelevant, "Merch giveaway this weekend"),
+		vote("c", "u1", dbstore.VoteDown, "Metalcore merch giveaway, 2024 edition"),
+		// Two users downvoting one post still counts it once.
+		vote("c", "u2", dbstore.VoteDown, "Metalcore merch giveaway, 2024 edition"),
+		// "weekend" also shows up in a post people liked.
+		vote("d", "u1", dbstore.VoteUp, "New single out this weekend"),
+		vote("e", "u1", dbstore.VoteDown, "Weekend merch giveaway"),
+		// Outvoted: up beats down, so this post keeps its words.
+		vote("f", "u1", dbstore.VoteDown, "Tour giveaway"),
+		vote("f", "u2", dbstore.VoteUp, "Tour giveaway"),
+		vote("f", "u3", dbstore.VoteUp, "Tour giveaway"),
+	}
+
+	got := SuggestExclusions("metalcore", nil, feedback, 3)
+	// giveaway is in f, which people liked; weekend is in d; metalcore is
+	// the rule's own target.
+	if want := []string{"merch"}; !slices.Equal(got, want) {
+		t.Errorf("SuggestExclusions = %v, want %v", got, want)
+	}
+
+	if got := SuggestExclusions("metalcore", []string{"merc"}, feedback, 3); len(got) != 0 {
+		t.Errorf("already excluded: SuggestExclusions = %v, want none", got)
+	}
+
+	// 2024 is all digits and edition is in one post only.
+	got = SuggestExclusions("", nil, feedback, 2)
+	if want := []string{"merch", "metalcore"}; !slices.Equal(got, want) {
+		t.Errorf("SuggestExclusions(min 2) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log/level"
+
+	ctx "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/llm"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+const (
+	// TargetSemantic is the rule target whose value is a natural-language
+	// description, matched by embedding similarity.
+	TargetSemantic = "semantic"
+
+	// DefaultSemanticThreshold is the minimum cosine similarity for rules
+	// without their own threshold. Where a paraphrase lands depends on the
+	// embedding model; /preview_digest shows the score to tune against.
+	DefaultSemanticThreshold = 0.6
+
+	// maxEmbedRunes bounds the post text sent for embedding. Embedding
+	// models take a few hundred tokens; the title and opening carry the
+	// topic.
+	maxEmbedRunes = 2000
+)
+
+// Embedder embeds text. *llm.Embedder implements it.
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// WithEmbedder enables semantic rules.
+func WithEmbedder(em Embedder) Option {
+	return func(e *RuleEvaluation) { e.embedder = em }
+}
+
+// SemanticThreshold returns the similarity a semantic rule needs.
+func SemanticThreshold(threshold float64) float64 {
+	if threshold <= 0 {
+		return DefaultSemanticThreshold
+	}
+	return threshold
+}
+
+// PostEmbeddingText is the text of post that semantic rules compare
+// against: the title, then the start of the selftext.
+func PostEmbeddingText(post *redditJson.RedditPost) string {
+	text := strings.TrimSpace(post.Title + "\n\n" + strings.TrimSpace(post.Selftext))
+	if r := []rune(text); len(r) > maxEmbedRunes {
+		text = string(r[:maxEmbedRunes])
+	}
+	return text
+}
+
+// RuleEmbedding returns the vector for a semantic rule's description,
+// reusing the stored one while its text and model still match and
+// embedding (and storing) it otherwise.
+func RuleEmbedding(ctx context.Context, store dbstore.Store, em Embedder, ruleID int, description string) ([]float32, error) {
+	stored, err := store.GetRuleEmbedding(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil && stored.Model == em.Model() && stored.Source == description {
+		return stored.Embedding, nil
+	}
+	vecs, err := em.Embed(ctx, []string{description})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.UpsertRuleEmbedding(ctx, dbstore.RuleEmbedding{
+		RuleID: ruleID, Model: em.Model(), Source: description, Embedding: vecs[0],
+	}); err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+// SemanticScore embeds post and returns its cosine similarity to a semantic
+// rule's description.
+func SemanticScore(ctx context.Context, store dbstore.Store, em Embedder, post *redditJson.RedditPost, ruleID int, description string) (float64, error) {
+	ruleVec, err := RuleEmbedding(ctx, store, em, ruleID, description)
+	if err != nil {
+		return 0, fmt.Errorf("embed rule %d: %w", ruleID, err)
+	}
+	vecs, err := em.Embed(ctx, []string{PostEmbeddingText(post)})
+	if err != nil {
+		return 0, fmt.Errorf("embed post %s: %w", post.ID, err)
+	}
+	return llm.Cosine(vecs[0], ruleVec)
+}
+
+// semanticVectors embeds post and fills ruleVecs for rules' semantic rules
+// not yet embedded this batch. It returns nil when the post couldn't be
+// embedded, or no rule could; semantic rules then don't match it.
+func (e *RuleEvaluation) semanticVectors(c ctx.Ctx, post *redditJson.RedditPost, rules []*dbstore.Rule, ruleVecs map[int][]float32) []float32 {
+	usable := false
+	for _, r := range rules {
+		if r.TargetID != TargetSemantic {
+			continue
+		}
+		if _, ok := ruleVecs[r.ID]; !ok {
+			vec, err := RuleEmbedding(c, e.store, e.embedder, r.ID, r.Target)
+			if err != nil {
+				_ = level.Warn(c.Log()).Log("msg", "embed semantic rule failed", "rule_id", r.ID, "error", err)
+			}
+			ruleVecs[r.ID] = vec
+		}
+		usable = usable || ruleVecs[r.ID] != nil
+	}
+	if !usable {
+		return nil
+	}
+	vecs, err := e.embedder.Embed(c, []string{PostEmbeddingText(post)})
+	if err != nil {
+		_ = level.Warn(c.Log()).Log("msg", "embed post failed", "post_id", post.ID, "error", err)
+		return nil
+	}
+	return vecs[0]
+}
+
+// semanticMatch reports whether postVec is at least threshold-similar to
+// ruleVec. Missing vectors never match.
+func semanticMatch(postVec, ruleVec []float32, threshold float64) bool {
+	if postVec == nil || ruleVec == nil {
+		return false
+	}
+	score, err := llm.Cosine(postVec, ruleVec)
+	return err == nil && score >= SemanticThreshold(threshold)
+}
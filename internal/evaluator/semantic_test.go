@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	ctx "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	redditJson "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// fakeEmbedder maps text to a 2-d vector: texts mentioning "tour" point one
+// way, everything else the other.
+type fakeEmbedder struct {
+	model  string
+	inputs []string
+}
+
+func (f *fakeEmbedder) Model() string { return f.model }
+
+func (f *fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		f.inputs = append(f.inputs, t)
+		switch {
+		case strings.Contains(t, "tour"):
+			out[i] = []float32{1, 0.1}
+		case strings.Contains(t, "dates"):
+			out[i] = []float32{1, 0.6}
+		default:
+			out[i] = []float32{0, 1}
+		}
+	}
+	return out, nil
+}
+
+func TestEvaluate_SemanticRule(t *testing.T) {
+	store := &mockStore{rules: []*dbstore.Rule{
+		{ID: 3, Target: "posts announcing a new tour", TargetID: TargetSemantic, DiscordChannelID: 1},
+		{ID: 4, Target: "posts announcing a new tour", TargetID: TargetSemantic, Threshold: 0.99, DiscordChannelID: 1},
+	}}
+	em := &fakeEmbedder{model: "m1"}
+	e := NewRuleEvaluator(store, WithEmbedder(em))
+	c := ctx.New(context.Background())
+
+	posts := []*redditJson.RedditPost{
+		{ID: "p1", Subreddit: "golang", Title: "We're going on tour!"},
+		{ID: "p2", Subreddit: "golang", Title: "New dates added"},
+		{ID: "p3", Subreddit: "golang", Title: "Bass tabs?"},
+	}
+	results := make(chan *MatchingEvaluationResult, 8)
+	if err := e.Evaluate(c, posts, results); err != nil {
+		t.Fatal(err)
+	}
+	close(results)
+	var got []string
+	for r := range results {
+		got = append(got, fmt.Sprintf("%s/%d", r.Post.ID, r.RuleID))
+	}
+	// p1 matches both rules; p2 (cosine ≈ 0.93) only the default threshold.
+	if len(got) != 3 {
+		t.Fatalf("matches = %v, want p1 on rules 3 and 4, p2 on rule 3", got)
+	}
+	for _, m := range got {
+		if m == "p2/4" || strings.HasPrefix(m, "p3") {
+			t.Errorf("unexpected match %s", m)
+		}
+	}
+	// Each rule is embedded once per batch, each post once.
+	if len(em.inputs) != 5 {
+		t.Errorf("embedded %d texts, want 2 rules + 3 posts: %q", len(em.inputs), em.inputs)
+	}
+	if re := store.embeddings[3]; re.Model != "m1" || re.Source != "posts announcing a new tour" {
+		t.Errorf("stored embedding = %+v", re)
+	}
+}
+
+func TestRuleEmbedding_ReusesUntilSourceOrModelChanges(t *testing.T) {
+	store := &mockStore{}
+	em := &fakeEmbedder{model: "m1"}
+	c := context.Background()
+
+	for range 2 {
+		if _, err := RuleEmbedding(c, store, em, 1, "tour news"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(em.inputs) != 1 {
+		t.Fatalf("embedded %d times, want the stored vector reused", len(em.inputs))
+	}
+	if _, err := RuleEmbedding(c, store, em, 1, "tour dates"); err != nil {
+		t.Fatal(err)
+	}
+	em.model = "m2"
+	if _, err := RuleEmbedding(c, store, em, 1, "tour dates"); err != nil {
+		t.Fatal(err)
+	}
+	if len(em.inputs) != 3 {
+		t.Errorf("embedded %d times, want a re-embed after each change", len(em.inputs))
+	}
+}
+
+func TestPostEmbeddingText(t *testing.T) {
+	p := &redditJson.RedditPost{Title: "Title", Selftext: strings.Repeat("é", 3*maxEmbedRunes)}
+	got := PostEmbeddingText(p)
+	if !strings.HasPrefix(got, "Title\n\n") || len([]rune(got)) != maxEmbedRunes {
+		t.Errorf("text starts %q, %d runes", got[:10], len([]rune(got)))
+	}
+}
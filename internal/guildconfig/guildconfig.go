@@ -0,0 +1,548 @@
+// Package guildconfig reads and writes a guild's whole setup — its
+// channels, their prompt settings and their rules — as one YAML document,
+// and works out what applying such a document would change. JSON is valid
+// YAML, so a JSON document parses too.
+//
+// A document describes the guild completely: applying it creates the rules
+// it adds, updates the ones it changes and deletes every rule of the guild
+// it leaves out. A file rule is the same rule as a stored one when they
+// share channel, subreddit, match_on and value; everything else about a
+// rule is a setting that can change in place.
+//
+// The RULES_FILE a deployment mounts lists several guilds in the same
+// format (see ParseFile). Reconcile applies it with different ownership:
+// its rules become managed, and it deletes only managed rules.
+package guildconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/llm"
+	"github.com/meriley/reddit-spy/internal/quiethours"
+)
+
+// Version is the document format Export writes and Parse accepts.
+const Version = 1
+
+const (
+	// DefaultWindowHours matches the rules.window_hours schema default.
+	DefaultWindowHours = 72
+	maxWindowHours     = 720
+	maxSubredditLen    = 21
+)
+
+var subredditPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// MatchTargets lists the values a rule's match_on accepts.
+func MatchTargets() []string {
+	return []string{"author", "title", evaluator.TargetClassification, evaluator.TargetSemantic}
+}
+
+// Config is a guild's configuration document.
+type Config struct {
+	Version  int       `yaml:"version"`
+	Guild    string    `yaml:"guild"`
+	Channels []Channel `yaml:"channels"`
+}
+
+// Channel is one channel and the rules that post to it. Empty prompt
+// settings fall back to the defaults, as they do for /set_prompt.
+type Channel struct {
+	ID             string `yaml:"id"`
+	PromptTemplate string `yaml:"prompt_template,omitempty"`
+	Tone           string `yaml:"tone,omitempty"`
+	Language       string `yaml:"language,omitempty"`
+	Rules          []Rule `yaml:"rules,omitempty"`
+}
+
+// Rule mirrors /add_subreddit_listener's options plus the rule's own prompt
+// overrides, exclusion terms and quiet hours. A zero Mode or WindowHours
+// means the default. Pausing is operational state and isn't part of it.
+type Rule struct {
+	Subreddit      string   `yaml:"subreddit"`
+	MatchOn        string   `yaml:"match_on"`
+	Value          string   `yaml:"value"`
+	Exact          bool     `yaml:"exact,omitempty"`
+	Mode           string   `yaml:"mode,omitempty"`
+	WindowHours    int      `yaml:"window_hours,omitempty"`
+	Threshold      float64  `yaml:"threshold,omitempty"`
+	PromptTemplate string   `yaml:"prompt_template,omitempty"`
+	Tone           string   `yaml:"tone,omitempty"`
+	ExcludeTerms   []string `yaml:"exclude_terms,omitempty"`
+	QuietHours     string   `yaml:"quiet_hours,omitempty"`
+}
+
+// key identifies r within its channel.
+func (r Rule) key() string {
+	return r.Subreddit + "\x00" + r.MatchOn + "\x00" + r.Value
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("r/%s %s=%q", r.Subreddit, r.MatchOn, r.Value)
+}
+
+// Export builds guild's document from its stored channels, as
+// dbstore.Store.GetGuildConfig returns them.
+func Export(guild string, channels []dbstore.GuildChannel) *Config {
+	cfg := &Config{Version: Version, Guild: strings.ToLower(guild), Channels: []Channel{}}
+	for _, ch := range channels {
+		out := Channel{ID: ch.ExternalID, PromptTemplate: ch.PromptTemplate, Tone: ch.Tone, Language: ch.Language}
+		for _, r := range ch.Rules {
+			out.Rules = append(out.Rules, fromStore(r))
+		}
+		cfg.Channels = append(cfg.Channels, out)
+	}
+	return cfg
+}
+
+func fromStore(r dbstore.GuildRule) Rule {
+	return Rule{
+		Subreddit: r.Subreddit, MatchOn: r.TargetID, Value: r.Target, Exact: r.Exact,
+		Mode: r.Mode, WindowHours: r.WindowHours, Threshold: r.Threshold,
+		PromptTemplate: r.PromptTemplate, Tone: r.Tone, ExcludeTerms: r.ExcludeTerms, QuietHours: r.QuietHours,
+	}
+}
+
+// Marshal encodes cfg as YAML.
+func Marshal(cfg *Config) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse decodes a YAML or JSON document, rejecting unknown fields, and
+// validates it. The result is normalized: identifiers lowercased, defaults
+// filled in and languages spelled the way the store keeps them.
+func Parse(raw []byte) (*Config, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("config is empty")
+		}
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.normalize(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// File is a parsed rules file: the guilds whose rules it manages.
+type File struct {
+	Guilds []*Config
+}
+
+// ParseFile decodes a rules file. It lists guilds under `guilds`, each in
+// the document format without its own version; a single guild's document,
+// as /export_config writes it, is a rules file too. A file that lists no
+// guilds is valid and manages nothing.
+func ParseFile(raw []byte) (*File, error) {
+	var doc struct {
+		Config `yaml:",inline"`
+		Guilds []Config `yaml:"guilds"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("rules file is empty")
+		}
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	guilds := doc.Guilds
+	if doc.Guild != "" || len(doc.Channels) > 0 {
+		if len(guilds) > 0 {
+			return nil, errors.New("rules file has both guild and guilds")
+		}
+		guilds = []Config{doc.Config}
+	} else if doc.Version != Version {
+		return nil, fmt.Errorf("unsupported rules file version %d (want %d)", doc.Version, Version)
+	}
+
+	f := &File{}
+	seenGuild, seenChannel := map[string]bool{}, map[string]string{}
+	for i := range guilds {
+		g := &guilds[i]
+		if g.Version == 0 {
+			g.Version = doc.Version
+		}
+		if err := g.normalize(); err != nil {
+			return nil, fmt.Errorf("guild %d: %w", i+1, err)
+		}
+		if seenGuild[g.Guild] {
+			return nil, fmt.Errorf("guild %s is listed twice", g.Guild)
+		}
+		seenGuild[g.Guild] = true
+		for _, ch := range g.Channels {
+			if other, ok := seenChannel[ch.ID]; ok {
+				return nil, fmt.Errorf("channel %s is listed under guilds %s and %s", ch.ID, other, g.Guild)
+			}
+			seenChannel[ch.ID] = g.Guild
+		}
+		f.Guilds = append(f.Guilds, g)
+	}
+	return f, nil
+}
+
+// normalize validates cfg in place and fills in its defaults.
+func (cfg *Config) normalize() error {
+	if cfg.Version != Version {
+		return fmt.Errorf("unsupported config version %d (want %d)", cfg.Version, Version)
+	}
+	cfg.Guild = strings.ToLower(strings.TrimSpace(cfg.Guild))
+	if cfg.Guild == "" {
+		return errors.New("config has no guild")
+	}
+	seen := map[string]bool{}
+	for i := range cfg.Channels {
+		ch := &cfg.Channels[i]
+		ch.ID = strings.ToLower(strings.TrimSpace(ch.ID))
+		if ch.ID == "" {
+			return fmt.Errorf("channel %d has no id", i+1)
+		}
+		if seen[ch.ID] {
+			return fmt.Errorf("channel %s is listed twice", ch.ID)
+		}
+		seen[ch.ID] = true
+		if err := normalizePrompt(&ch.Tone); err != nil {
+			return fmt.Errorf("channel %s: %w", ch.ID, err)
+		}
+		if ch.Language != "" {
+			lang, err := llm.ParseLanguage(ch.Language)
+			if err != nil {
+				return fmt.Errorf("channel %s: %w", ch.ID, err)
+			}
+			ch.Language = lang
+		}
+		keys := map[string]bool{}
+		for j := range ch.Rules {
+			r := &ch.Rules[j]
+			if err := r.normalize(); err != nil {
+				return fmt.Errorf("channel %s, rule %d: %w", ch.ID, j+1, err)
+			}
+			if keys[r.key()] {
+				return fmt.Errorf("channel %s lists %s twice", ch.ID, r)
+			}
+			keys[r.key()] = true
+		}
+	}
+	return nil
+}
+
+func (r *Rule) normalize() error {
+	r.Subreddit = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(r.Subreddit), "r/"))
+	if !subredditPattern.MatchString(r.Subreddit) || len(r.Subreddit) > maxSubredditLen {
+		return fmt.Errorf("invalid subreddit %q", r.Subreddit)
+	}
+	r.MatchOn = strings.ToLower(strings.TrimSpace(r.MatchOn))
+	known := false
+	for _, t := range MatchTargets() {
+		known = known || t == r.MatchOn
+	}
+	if !known {
+		return fmt.Errorf("match_on must be one of %s, got %q", strings.Join(MatchTargets(), ", "), r.MatchOn)
+	}
+	r.Value = strings.ToLower(strings.TrimSpace(r.Value))
+	if r.Value == "" {
+		return errors.New("value cannot be empty")
+	}
+	if r.Mode == "" {
+		r.Mode = dbstore.ModeNarrative
+	}
+	if !dbstore.IsValidMode(r.Mode) {
+		return fmt.Errorf("unknown mode %q", r.Mode)
+	}
+	if r.WindowHours == 0 {
+		r.WindowHours = DefaultWindowHours
+	}
+	if r.WindowHours < 1 || r.WindowHours > maxWindowHours {
+		return fmt.Errorf("window_hours must be between 1 and %d", maxWindowHours)
+	}
+	if r.Threshold < 0 || r.Threshold > 1 {
+		return errors.New("threshold must be between 0 and 1")
+	}
+	var terms []string
+	for _, t := range r.ExcludeTerms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	r.ExcludeTerms = terms
+	if r.QuietHours = strings.TrimSpace(r.QuietHours); r.QuietHours != "" {
+		w, err := quiethours.Parse(r.QuietHours)
+		if err != nil {
+			return err
+		}
+		r.QuietHours = w.String()
+	}
+	return normalizePrompt(&r.Tone)
+}
+
+// normalizePrompt checks a tone override, which must name a preset.
+func normalizePrompt(tone *string) error {
+	*tone = strings.ToLower(strings.TrimSpace(*tone))
+	if *tone != "" && !llm.IsTonePreset(*tone) {
+		return fmt.Errorf("unknown tone %q (one of %s)", *tone, strings.Join(llm.ToneNames(), ", "))
+	}
+	return nil
+}
+
+// Store is the persistence Diff and Plan.Apply need.
+type Store interface {
+	GetGuildConfig(ctx context.Context, serverID string) ([]dbstore.GuildChannel, error)
+	ApplyGuildConfig(ctx context.Context, serverID string, channels []dbstore.GuildChannel) error
+}
+
+// Plan is what applying a document to a guild would do.
+type Plan struct {
+	Guild string
+	// Changes describes each difference from the stored config, one line
+	// each, in document order; empty when the document matches.
+	Changes []string
+	// Created lists the rules the plan adds, for callers that vet them
+	// further before applying.
+	Created []Rule
+	// Subreddits lists every subreddit the document's rules watch.
+	Subreddits []string
+	// Conflicts lists the changes to managed rules, which only the rules
+	// file may make. Apply refuses a plan that has any.
+	Conflicts []string
+
+	channels []dbstore.GuildChannel
+}
+
+// Diff compares cfg with what store holds for cfg.Guild.
+func Diff(ctx context.Context, store Store, cfg *Config) (*Plan, error) {
+	current, err := store.GetGuildConfig(ctx, cfg.Guild)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load guild config: %w", err)
+	}
+	return plan(cfg, current, false), nil
+}
+
+// plan matches cfg's rules with current's and records every difference.
+// A managed plan is the rules file's: it adopts the stored rules it
+// matches, and of those it doesn't, deletes only the managed ones.
+func plan(cfg *Config, current []dbstore.GuildChannel, managed bool) *Plan {
+	p := &Plan{Guild: cfg.Guild}
+	stored := map[string]dbstore.GuildChannel{}
+	for _, ch := range current {
+		stored[ch.ExternalID] = ch
+	}
+	subreddits := map[string]bool{}
+	listed := map[string]bool{}
+
+	for _, ch := range cfg.Channels {
+		listed[ch.ID] = true
+		old, known := stored[ch.ID]
+		if !known {
+			p.change("+ channel %s", ch.ID)
+		}
+		p.setting(ch.ID, "prompt_template", old.PromptTemplate, ch.PromptTemplate)
+		p.setting(ch.ID, "tone", old.Tone, ch.Tone)
+		p.setting(ch.ID, "language", old.Language, ch.Language)
+
+		byKey := map[string]dbstore.GuildRule{}
+		for _, r := range old.Rules {
+			byKey[fromStore(r).key()] = r
+		}
+		out := dbstore.GuildChannel{
+			ExternalID: ch.ID, PromptTemplate: ch.PromptTemplate, Tone: ch.Tone, Language: ch.Language,
+		}
+		for _, r := range ch.Rules {
+			subreddits[r.Subreddit] = true
+			gr := dbstore.GuildRule{
+				Subreddit: r.Subreddit, TargetID: r.MatchOn, Target: r.Value, Exact: r.Exact,
+				Mode: r.Mode, WindowHours: r.WindowHours, Threshold: r.Threshold,
+				PromptTemplate: r.PromptTemplate, Tone: r.Tone, ExcludeTerms: r.ExcludeTerms,
+				QuietHours: r.QuietHours,
+			}
+			if prev, ok := byKey[r.key()]; ok {
+				delete(byKey, r.key())
+				gr.ID = prev.ID
+				n := len(p.Changes)
+				p.ruleChanges(ch.ID, fromStore(prev), r)
+				if managed && !prev.Managed {
+					p.change("~ %s: %s: now managed by the rules file", ch.ID, r)
+				}
+				if !managed && prev.Managed && len(p.Changes) > n {
+					p.Conflicts = append(p.Conflicts, p.Changes[n:]...)
+				}
+			} else {
+				p.change("+ %s: %s", ch.ID, r)
+				p.Created = append(p.Created, r)
+			}
+			out.Rules = append(out.Rules, gr)
+		}
+		for _, r := range old.Rules {
+			if _, dropped := byKey[fromStore(r).key()]; dropped {
+				p.drop(ch.ID, r, managed)
+			}
+		}
+		p.channels = append(p.channels, out)
+	}
+	// A stored channel the document leaves out keeps its settings but
+	// loses its rules, which is what ApplyGuildConfig does with it.
+	for _, ch := range current {
+		if listed[ch.ExternalID] {
+			continue
+		}
+		for _, r := range ch.Rules {
+			p.drop(ch.ExternalID, r, managed)
+		}
+	}
+
+	for sr := range subreddits {
+		p.Subreddits = append(p.Subreddits, sr)
+	}
+	sort.Strings(p.Subreddits)
+	return p
+}
+
+// drop records the deletion of a stored rule the document leaves out.
+func (p *Plan) drop(channel string, r dbstore.GuildRule, managed bool) {
+	switch {
+	case managed && !r.Managed:
+		// The rules file never deletes a rule made by hand.
+	case !managed && r.Managed:
+		p.change("- %s: %s", channel, fromStore(r))
+		p.Conflicts = append(p.Conflicts, p.Changes[len(p.Changes)-1])
+	default:
+		p.change("- %s: %s", channel, fromStore(r))
+	}
+}
+
+func (p *Plan) change(format string, args ...any) {
+	p.Changes = append(p.Changes, fmt.Sprintf(format, args...))
+}
+
+func (p *Plan) setting(channel, name, old, new string) {
+	if old != new {
+		p.change("~ %s: %s %s → %s", channel, name, quote(old), quote(new))
+	}
+}
+
+func (p *Plan) ruleChanges(channel string, old, new Rule) {
+	var diffs []string
+	if old.Exact != new.Exact {
+		diffs = append(diffs, fmt.Sprintf("exact %t → %t", old.Exact, new.Exact))
+	}
+	if old.Mode != new.Mode {
+		diffs = append(diffs, fmt.Sprintf("mode %s → %s", old.Mode, new.Mode))
+	}
+	if old.WindowHours != new.WindowHours {
+		diffs = append(diffs, fmt.Sprintf("window_hours %d → %d", old.WindowHours, new.WindowHours))
+	}
+	if old.Threshold != new.Threshold {
+		diffs = append(diffs, fmt.Sprintf("threshold %g → %g", old.Threshold, new.Threshold))
+	}
+	if old.PromptTemplate != new.PromptTemplate {
+		diffs = append(diffs, fmt.Sprintf("prompt_template %s → %s", quote(old.PromptTemplate), quote(new.PromptTemplate)))
+	}
+	if old.Tone != new.Tone {
+		diffs = append(diffs, fmt.Sprintf("tone %s → %s", quote(old.Tone), quote(new.Tone)))
+	}
+	if !slices.Equal(old.ExcludeTerms, new.ExcludeTerms) {
+		diffs = append(diffs, fmt.Sprintf("exclude_terms [%s] → [%s]",
+			strings.Join(old.ExcludeTerms, ", "), strings.Join(new.ExcludeTerms, ", ")))
+	}
+	if old.QuietHours != new.QuietHours {
+		diffs = append(diffs, fmt.Sprintf("quiet_hours %s → %s", quietHours(old.QuietHours), quietHours(new.QuietHours)))
+	}
+	if len(diffs) > 0 {
+		p.change("~ %s: %s: %s", channel, new, strings.Join(diffs, ", "))
+	}
+}
+
+// quietHours shows a rule's quiet hours in a change line.
+func quietHours(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// quote shows a setting in a change line; long templates are cut short.
+func quote(s string) string {
+	if s == "" {
+		return "(default)"
+	}
+	const max = 40
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max]) + "…"
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// ReconcileStore is the persistence Reconcile needs.
+type ReconcileStore interface {
+	GetGuildConfig(ctx context.Context, serverID string) ([]dbstore.GuildChannel, error)
+	ReconcileManagedRules(ctx context.Context, guilds []dbstore.ManagedGuild) (int64, error)
+}
+
+// Reconcile makes the managed rules in store match f in one transaction.
+// It returns a plan per guild in f, describing what changed there, and how
+// many managed rules were deleted in all, including from guilds f no
+// longer lists.
+func Reconcile(ctx context.Context, store ReconcileStore, f *File) ([]*Plan, int64, error) {
+	plans := make([]*Plan, 0, len(f.Guilds))
+	guilds := make([]dbstore.ManagedGuild, 0, len(f.Guilds))
+	for _, cfg := range f.Guilds {
+		current, err := store.GetGuildConfig(ctx, cfg.Guild)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load guild %s: %w", cfg.Guild, err)
+		}
+		p := plan(cfg, current, true)
+		plans = append(plans, p)
+		guilds = append(guilds, dbstore.ManagedGuild{ServerID: p.Guild, Channels: p.channels})
+	}
+	deleted, err := store.ReconcileManagedRules(ctx, guilds)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plans, deleted, nil
+}
+
+// Apply writes the plan in one transaction. The store refuses it if a rule
+// the plan updates has been deleted since Diff read it.
+func (p *Plan) Apply(ctx context.Context, store Store) error {
+	if len(p.Conflicts) > 0 {
+		return fmt.Errorf("%d changes touch rules managed by the rules file, starting with %q", len(p.Conflicts), p.Conflicts[0])
+	}
+	return store.ApplyGuildConfig(ctx, p.Guild, p.channels)
+}
+
+// Equal reports whether p and o would make the same changes.
+func (p *Plan) Equal(o *Plan) bool {
+	if p.Guild != o.Guild || len(p.Changes) != len(o.Changes) {
+		return false
+	}
+	for i := range p.Changes {
+		if p.Changes[i] != o.Changes[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,261 @@
+package guildconfig
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// seed gives guild "g1" channel "c1" with a title rule and an author rule.
+func seed(t *testing.T) *dbstore.MemStore {
+	t.Helper()
+	ctx := context.Background()
+	mem := dbstore.NewMemStore()
+	if err := mem.ApplyGuildConfig(ctx, "g1", []dbstore.GuildChannel{{
+		ExternalID: "c1", Tone: "terse",
+		Rules: []dbstore.GuildRule{
+			{Subreddit: "metalcore", TargetID: "title", Target: "tour"},
+			{Subreddit: "metalcore", TargetID: "author", Target: "someone", Mode: dbstore.ModeMusic},
+		},
+	}}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	return mem
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	mem := seed(t)
+	stored, err := mem.GetGuildConfig(ctx, "g1")
+	if err != nil {
+		t.Fatalf("GetGuildConfig: %v", err)
+	}
+	if err := mem.UpdateRuleExcludeTerms(ctx, stored[0].Rules[0].ID, []string{"merch"}); err != nil {
+		t.Fatalf("UpdateRuleExcludeTerms: %v", err)
+	}
+	if err := mem.UpdateRuleQuietHours(ctx, stored[0].Rules[0].ID, "22:00-07:00"); err != nil {
+		t.Fatalf("UpdateRuleQuietHours: %v", err)
+	}
+	if stored, err = mem.GetGuildConfig(ctx, "g1"); err != nil {
+		t.Fatalf("GetGuildConfig: %v", err)
+	}
+	raw, err := Marshal(Export("G1", stored))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, want := range []string{"version: 1", "guild: g1", "match_on: author", "mode: music", "window_hours: 72", "exclude_terms:", "quiet_hours: 22:00-07:00"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("export lacks %q:\n%s", want, raw)
+		}
+	}
+
+	cfg, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	p, err := Diff(ctx, mem, cfg)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(p.Changes) != 0 || len(p.Created) != 0 {
+		t.Errorf("round trip changes = %q", p.Changes)
+	}
+	if !reflect.DeepEqual(p.Subreddits, []string{"metalcore"}) {
+		t.Errorf("subreddits = %q", p.Subreddits)
+	}
+}
+
+func TestDiffAndApply(t *testing.T) {
+	ctx := context.Background()
+	mem := seed(t)
+	// JSON is YAML too. The title rule changes mode, the author rule goes,
+	// and a second channel gets a new rule.
+	cfg, err := Parse([]byte(`{
+		"version": 1, "guild": "G1",
+		"channels": [
+			{"id": "C1", "tone": "terse", "rules": [
+				{"subreddit": "r/Metalcore", "match_on": "title", "value": "Tour", "mode": "music", "window_hours": 24,
+				 "exclude_terms": ["Merch", " giveaway ", "merch"], "quiet_hours": "23:00-7:00"}
+			]},
+			{"id": "c2", "language": "de", "rules": [
+				{"subreddit": "poppunkers", "match_on": "semantic", "value": "reunion shows", "threshold": 0.7}
+			]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	p, err := Diff(ctx, mem, cfg)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []string{
+		`~ c1: r/metalcore title="tour": mode narrative → music, window_hours 72 → 24, exclude_terms [] → [merch, giveaway], quiet_hours (none) → 23:00-07:00`,
+		`- c1: r/metalcore author="someone"`,
+		`+ channel c2`,
+		`~ c2: language (default) → "German"`,
+		`+ c2: r/poppunkers semantic="reunion shows"`,
+	}
+	if !reflect.DeepEqual(p.Changes, want) {
+		t.Errorf("changes =\n%s\nwant\n%s", strings.Join(p.Changes, "\n"), strings.Join(want, "\n"))
+	}
+	if len(p.Created) != 1 || p.Created[0].Subreddit != "poppunkers" {
+		t.Errorf("created = %+v", p.Created)
+	}
+
+	if err := p.Apply(ctx, mem); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	again, err := Diff(ctx, mem, cfg)
+	if err != nil {
+		t.Fatalf("Diff after apply: %v", err)
+	}
+	if len(again.Changes) != 0 {
+		t.Errorf("changes after apply = %q", again.Changes)
+	}
+	if again.Equal(p) {
+		t.Error("an applied plan still equals the pending one")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"empty":          ``,
+		"version":        "version: 2\nguild: g\n",
+		"no guild":       "version: 1\n",
+		"unknown field":  "version: 1\nguild: g\nchanels: []\n",
+		"no channel id":  "version: 1\nguild: g\nchannels: [{}]\n",
+		"twice":          "version: 1\nguild: g\nchannels: [{id: a}, {id: A}]\n",
+		"subreddit":      "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: 'no spaces', match_on: title, value: x}]}]\n",
+		"match_on":       "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: body, value: x}]}]\n",
+		"value":          "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title}]}]\n",
+		"mode":           "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title, value: x, mode: loud}]}]\n",
+		"window":         "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title, value: x, window_hours: 721}]}]\n",
+		"threshold":      "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title, value: x, threshold: 2}]}]\n",
+		"quiet hours":    "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title, value: x, quiet_hours: '22:00'}]}]\n",
+		"tone":           "version: 1\nguild: g\nchannels: [{id: a, tone: grumpy}]\n",
+		"language":       "version: 1\nguild: g\nchannels: [{id: a, language: klingonese}]\n",
+		"duplicate rule": "version: 1\nguild: g\nchannels: [{id: a, rules: [{subreddit: s, match_on: title, value: x}, {subreddit: S, match_on: title, value: X, exact: true}]}]\n",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: Parse accepted %q", name, doc)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	f, err := ParseFile([]byte(`
+version: 1
+guilds:
+  - guild: G1
+    channels:
+      - id: c1
+        rules: [{subreddit: metalcore, match_on: title, value: tour}]
+  - guild: g2
+    channels: [{id: c2}]
+`))
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if len(f.Guilds) != 2 || f.Guilds[0].Guild != "g1" || f.Guilds[0].Channels[0].Rules[0].Mode != "narrative" {
+		t.Errorf("file = %+v", f.Guilds)
+	}
+
+	// An export is a one-guild rules file.
+	if f, err := ParseFile([]byte("version: 1\nguild: g1\nchannels: [{id: c1}]\n")); err != nil || len(f.Guilds) != 1 {
+		t.Errorf("single guild = %+v, %v", f, err)
+	}
+	if f, err := ParseFile([]byte("version: 1\nguilds: []\n")); err != nil || len(f.Guilds) != 0 {
+		t.Errorf("no guilds = %+v, %v", f, err)
+	}
+
+	for name, doc := range map[string]string{
+		"empty":          ``,
+		"version":        "guilds: []\n",
+		"both":           "version: 1\nguild: g1\nguilds: [{guild: g2}]\n",
+		"guild twice":    "version: 1\nguilds: [{guild: g1}, {guild: G1}]\n",
+		"shared channel": "version: 1\nguilds: [{guild: g1, channels: [{id: c}]}, {guild: g2, channels: [{id: c}]}]\n",
+		"bad rule":       "version: 1\nguilds: [{guild: g1, channels: [{id: c, rules: [{subreddit: s, match_on: body, value: x}]}]}]\n",
+	} {
+		if _, err := ParseFile([]byte(doc)); err == nil {
+			t.Errorf("%s: ParseFile accepted %q", name, doc)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	mem := seed(t)
+	// The file adopts the title rule and adds one; the author rule was made
+	// by hand and stays.
+	f, err := ParseFile([]byte(`
+version: 1
+guilds:
+  - guild: g1
+    channels:
+      - id: c1
+        tone: terse
+        rules:
+          - {subreddit: metalcore, match_on: title, value: tour}
+          - {subreddit: metalcore, match_on: title, value: split, mode: music}
+`))
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	plans, deleted, err := Reconcile(ctx, mem, f)
+	if err != nil || deleted != 0 || len(plans) != 1 {
+		t.Fatalf("Reconcile = %v, %d, %v", plans, deleted, err)
+	}
+	want := []string{
+		`~ c1: r/metalcore title="tour": now managed by the rules file`,
+		`+ c1: r/metalcore title="split"`,
+	}
+	if !reflect.DeepEqual(plans[0].Changes, want) {
+		t.Errorf("changes = %q", plans[0].Changes)
+	}
+	stored, _ := mem.GetGuildConfig(ctx, "g1")
+	if rules := stored[0].Rules; len(rules) != 3 || !rules[0].Managed || rules[1].Managed || !rules[2].Managed {
+		t.Fatalf("rules after reconcile = %+v", rules)
+	}
+
+	// An import may keep managed rules as they are but not change or drop
+	// them.
+	cfg, err := Parse([]byte(`
+version: 1
+guild: g1
+channels:
+  - id: c1
+    tone: terse
+    rules:
+      - {subreddit: metalcore, match_on: title, value: tour, mode: music}
+      - {subreddit: metalcore, match_on: author, value: someone, mode: music, window_hours: 12}
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	p, err := Diff(ctx, mem, cfg)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	wantConflicts := []string{
+		`~ c1: r/metalcore title="tour": mode narrative → music`,
+		`- c1: r/metalcore title="split"`,
+	}
+	if !reflect.DeepEqual(p.Conflicts, wantConflicts) {
+		t.Errorf("conflicts = %q", p.Conflicts)
+	}
+	if err := p.Apply(ctx, mem); err == nil {
+		t.Error("Apply changed managed rules")
+	}
+
+	// Dropping the guild from the file deletes what it managed.
+	if _, deleted, err := Reconcile(ctx, mem, &File{}); err != nil || deleted != 2 {
+		t.Errorf("empty Reconcile deleted %d, %v", deleted, err)
+	}
+	if stored, _ := mem.GetGuildConfig(ctx, "g1"); len(stored[0].Rules) != 1 || stored[0].Rules[0].Target != "someone" {
+		t.Errorf("rules after dropping the guild = %+v", stored[0].Rules)
+	}
+}
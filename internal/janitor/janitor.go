@@ -0,0 +1,267 @@
+// Package janitor trims tables that would otherwise grow forever: closed
+// digests, old notifications and the posts they referenced, and lookup
+// cache rows nobody has refreshed in a while. It deletes in small batches
+// so a sweep never holds a long lock against the live bot.
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+const (
+	EnvInterval               = "JANITOR_INTERVAL"
+	EnvBatchSize              = "JANITOR_BATCH_SIZE"
+	EnvDigestDays             = "RETENTION_DIGEST_DAYS"
+	EnvNotificationMarginDays = "RETENTION_NOTIFICATION_MARGIN_DAYS"
+	EnvCacheDays              = "RETENTION_CACHE_DAYS"
+
+	DefaultInterval               = time.Hour
+	DefaultBatchSize              = 500
+	DefaultDigestDays             = 30
+	DefaultNotificationMarginDays = 7
+	// DefaultCacheDays outlasts the 30-day Last.fm/Piped/Qobuz TTL, so a
+	// row is only pruned after nothing has looked it up for a full TTL.
+	DefaultCacheDays = 60
+	// DefaultWindowHours matches the rules.window_hours schema default.
+	DefaultWindowHours = 72
+)
+
+// Config is the janitor's schedule and retention. A zero DigestDays or
+// CacheDays keeps those tables forever; a zero Interval disables the
+// janitor entirely.
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+	// DigestDays is how long a digest is kept after its window closed.
+	DigestDays int
+	// NotificationMarginDays is how long a notification outlives the
+	// longest rule window; until then it still suppresses a repeat match.
+	NotificationMarginDays int
+	// CacheDays is how long a Last.fm, Piped, Qobuz or article cache row is
+	// kept after it was last fetched.
+	CacheDays int
+	// DefaultWindowHours stands in for rules whose window_hours is 0.
+	DefaultWindowHours int
+}
+
+// ConfigFromEnv reads Config from the process environment, starting from
+// the defaults. Returns an error if a variable is malformed.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Interval:               DefaultInterval,
+		BatchSize:              DefaultBatchSize,
+		DigestDays:             DefaultDigestDays,
+		NotificationMarginDays: DefaultNotificationMarginDays,
+		CacheDays:              DefaultCacheDays,
+		DefaultWindowHours:     DefaultWindowHours,
+	}
+	if raw := os.Getenv(EnvInterval); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a non-negative duration", EnvInterval, raw)
+		}
+		cfg.Interval = d
+	}
+	if raw := os.Getenv(EnvBatchSize); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a positive integer", EnvBatchSize, raw)
+		}
+		cfg.BatchSize = n
+	}
+	for _, v := range []struct {
+		env string
+		dst *int
+	}{
+		{EnvDigestDays, &cfg.DigestDays},
+		{EnvNotificationMarginDays, &cfg.NotificationMarginDays},
+		{EnvCacheDays, &cfg.CacheDays},
+	} {
+		if raw := os.Getenv(v.env); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("invalid %s=%q: must be a non-negative integer", v.env, raw)
+			}
+			*v.dst = n
+		}
+	}
+	return cfg, nil
+}
+
+// Store is the persistence the janitor needs: the rules, to find the
+// longest window, and batched deletes.
+type Store interface {
+	GetSubreddits(ctx context.Context) ([]*dbstore.Subreddit, error)
+	GetRules(ctx context.Context, subreddit int) ([]*dbstore.Rule, error)
+	PruneRows(ctx context.Context, table string, cutoff time.Time, limit int) (int64, error)
+}
+
+// Stats is a snapshot of what the janitor has deleted since process start.
+type Stats struct {
+	Pruned  map[string]int64 // rows deleted, by dbstore.Prune* table
+	Sweeps  int64
+	LastRun time.Time // zero before the first sweep finishes
+	LastErr string    // the last sweep's error, "" if it succeeded
+}
+
+// Janitor prunes expired rows on a schedule. Safe for concurrent use.
+type Janitor struct {
+	store Store
+	cfg   Config
+	// now stands in for time.Now in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	pruned  map[string]int64
+	sweeps  int64
+	lastRun time.Time
+	lastErr string
+}
+
+// New returns a janitor over store. A BatchSize or DefaultWindowHours <= 0
+// uses the default.
+func New(store Store, cfg Config) *Janitor {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.DefaultWindowHours <= 0 {
+		cfg.DefaultWindowHours = DefaultWindowHours
+	}
+	return &Janitor{store: store, cfg: cfg, now: time.Now, pruned: map[string]int64{}}
+}
+
+// Run sweeps once straight away, then every Interval until ctx is done.
+func (j *Janitor) Run(ctx ctxpkg.Ctx) {
+	if j.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := j.Sweep(ctx); err != nil && ctx.Err() == nil {
+			_ = level.Error(ctx.Log()).Log("msg", "retention sweep failed", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep prunes every table once, batch by batch, and records the counts.
+// A table's error stops the sweep; what was already deleted still counts.
+func (j *Janitor) Sweep(ctx context.Context) error {
+	cutoffs, err := j.cutoffs(ctx)
+	if err == nil {
+		for _, table := range dbstore.PruneTables() {
+			cutoff, ok := cutoffs[table]
+			if !ok {
+				continue
+			}
+			if err = j.prune(ctx, table, cutoff); err != nil {
+				break
+			}
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sweeps++
+	j.lastRun = j.now()
+	j.lastErr = ""
+	if err != nil {
+		j.lastErr = err.Error()
+	}
+	return err
+}
+
+// prune deletes table's rows older than cutoff a batch at a time, until a
+// batch comes back short.
+func (j *Janitor) prune(ctx context.Context, table string, cutoff time.Time) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := j.store.PruneRows(ctx, table, cutoff, j.cfg.BatchSize)
+		if n > 0 {
+			j.mu.Lock()
+			j.pruned[table] += n
+			j.mu.Unlock()
+		}
+		if err != nil {
+			return err
+		}
+		if n < int64(j.cfg.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// cutoffs maps each table to prune to the age its rows expire at. Digests
+// and notifications are measured from the end of the longest rule window,
+// so nothing a live digest or dedup check still reads is ever touched.
+func (j *Janitor) cutoffs(ctx context.Context) (map[string]time.Time, error) {
+	window, err := j.longestWindow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := j.now()
+	closed := now.Add(-window)
+	out := map[string]time.Time{}
+	if j.cfg.DigestDays > 0 {
+		out[dbstore.PruneDigests] = closed.AddDate(0, 0, -j.cfg.DigestDays)
+	}
+	notified := closed.AddDate(0, 0, -j.cfg.NotificationMarginDays)
+	out[dbstore.PruneNotifications] = notified
+	out[dbstore.PruneJobs] = notified
+	out[dbstore.PrunePosts] = notified
+	if j.cfg.CacheDays > 0 {
+		stale := now.AddDate(0, 0, -j.cfg.CacheDays)
+		for _, table := range []string{dbstore.PruneLastfmCache, dbstore.PrunePipedCache, dbstore.PruneQobuzCache, dbstore.PruneArticleCache} {
+			out[table] = stale
+		}
+	}
+	return out, nil
+}
+
+// longestWindow is the longest digest window of any rule, and at least
+// DefaultWindowHours.
+func (j *Janitor) longestWindow(ctx context.Context) (time.Duration, error) {
+	subreddits, err := j.store.GetSubreddits(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list subreddits: %w", err)
+	}
+	hours := j.cfg.DefaultWindowHours
+	for _, sr := range subreddits {
+		rules, err := j.store.GetRules(ctx, sr.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list rules for subreddit %d: %w", sr.ID, err)
+		}
+		for _, r := range rules {
+			hours = max(hours, r.WindowHours)
+		}
+	}
+	return time.Duration(hours) * time.Hour, nil
+}
+
+// Stats returns a snapshot of the prune counters.
+func (j *Janitor) Stats() Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pruned := make(map[string]int64, len(j.pruned))
+	for table, n := range j.pruned {
+		pruned[table] = n
+	}
+	return Stats{Pruned: pruned, Sweeps: j.sweeps, LastRun: j.lastRun, LastErr: j.lastErr}
+}
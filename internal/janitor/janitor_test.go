@@ -0,0 +1,156 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// countingStore records how many batches each table took.
+type countingStore struct {
+	*dbstore.MemStore
+	batches map[string]int
+}
+
+func (s *countingStore) PruneRows(ctx context.Context, table string, cutoff time.Time, limit int) (int64, error) {
+	s.batches[table]++
+	return s.MemStore.PruneRows(ctx, table, cutoff, limit)
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := now
+	mem := dbstore.NewMemStore()
+	mem.Now = func() time.Time { return clock }
+	store := &countingStore{MemStore: mem, batches: map[string]int{}}
+
+	server, _ := mem.InsertDiscordServer(ctx, "s1")
+	channel, _ := mem.InsertDiscordChannel(ctx, "c1", server.ID)
+	sr, _ := mem.InsertSubreddit(ctx, "metalcore")
+	// A 10-day window outlasts the 72h default, so every cutoff moves back.
+	rule, err := mem.InsertRule(ctx, dbstore.Rule{
+		Target: "title", TargetID: "tour", WindowHours: 240, DiscordChannelID: channel.ID, SubredditID: sr.ID,
+	})
+	if err != nil {
+		t.Fatalf("InsertRule: %v", err)
+	}
+
+	// Digests close 10 days after window_start and are kept 30 days more.
+	for _, d := range []struct {
+		msg string
+		age int
+	}{{"old", 41}, {"kept", 39}} {
+		if _, err := mem.UpsertRollingPost(ctx, dbstore.RollingPost{
+			ChannelID: channel.ID, SubredditID: sr.ID, DayLocal: now,
+			WindowStart: now.AddDate(0, 0, -d.age), DiscordMessageIDs: []string{d.msg},
+		}); err != nil {
+			t.Fatalf("UpsertRollingPost: %v", err)
+		}
+	}
+
+	// Notifications outlive the window by 7 days; their posts go with them.
+	clock = now.AddDate(0, 0, -18)
+	oldPost, _ := mem.InsertPost(ctx, "old")
+	_, _ = mem.InsertNotification(ctx, oldPost.ID, channel.ID, rule.ID)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		_ = mem.UpsertLastfmListeners(ctx, key, 1)
+	}
+	clock = now.AddDate(0, 0, -16)
+	newPost, _ := mem.InsertPost(ctx, "new")
+	_, _ = mem.InsertNotification(ctx, newPost.ID, channel.ID, rule.ID)
+	_ = mem.UpsertLastfmListeners(ctx, "fresh", 1)
+	clock = now
+
+	j := New(store, Config{BatchSize: 2, DigestDays: 30, NotificationMarginDays: 7, CacheDays: 17})
+	j.now = func() time.Time { return now }
+	if err := j.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+
+	want := map[string]int64{
+		dbstore.PruneDigests:       1,
+		dbstore.PruneNotifications: 1,
+		dbstore.PrunePosts:         1,
+		dbstore.PruneLastfmCache:   5,
+	}
+	st := j.Stats()
+	for table, n := range want {
+		if st.Pruned[table] != n {
+			t.Errorf("pruned %s = %d, want %d", table, st.Pruned[table], n)
+		}
+	}
+	if st.Sweeps != 1 || !st.LastRun.Equal(now) || st.LastErr != "" {
+		t.Errorf("stats = %+v", st)
+	}
+	// Five stale rows in batches of two: 2, 2, then a short batch of 1.
+	if got := store.batches[dbstore.PruneLastfmCache]; got != 3 {
+		t.Errorf("lastfm batches = %d, want 3", got)
+	}
+
+	if rp, _ := mem.GetRollingPostByMessageID(ctx, "kept"); rp == nil {
+		t.Error("digest inside its retention was pruned")
+	}
+	if c, _ := mem.GetNotificationCount(ctx, newPost.ID, channel.ID, rule.ID); c != 1 {
+		t.Error("notification inside the window margin was pruned")
+	}
+	if _, _, ok, _ := mem.GetLastfmListeners(ctx, "fresh"); !ok {
+		t.Error("fresh cache row was pruned")
+	}
+
+	// A second sweep finds nothing new and the counters hold.
+	if err := j.Sweep(ctx); err != nil {
+		t.Fatalf("second Sweep: %v", err)
+	}
+	if st := j.Stats(); st.Sweeps != 2 || st.Pruned[dbstore.PruneLastfmCache] != 5 {
+		t.Errorf("stats after second sweep = %+v", st)
+	}
+}
+
+func TestSweep_ZeroRetentionKeepsTables(t *testing.T) {
+	ctx := context.Background()
+	mem := dbstore.NewMemStore()
+	mem.Now = func() time.Time { return time.Now().AddDate(-1, 0, 0) }
+	_ = mem.UpsertQobuzAlbum(ctx, "a b", "")
+
+	j := New(mem, Config{CacheDays: 0})
+	if err := j.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if _, _, ok, _ := mem.GetQobuzAlbum(ctx, "a b"); !ok {
+		t.Error("CacheDays=0 pruned a cache row")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if cfg.Interval != DefaultInterval || cfg.BatchSize != DefaultBatchSize || cfg.DigestDays != DefaultDigestDays ||
+		cfg.NotificationMarginDays != DefaultNotificationMarginDays || cfg.CacheDays != DefaultCacheDays {
+		t.Errorf("defaults = %+v", cfg)
+	}
+
+	t.Setenv(EnvInterval, "15m")
+	t.Setenv(EnvCacheDays, "0")
+	if cfg, err = ConfigFromEnv(); err != nil || cfg.Interval != 15*time.Minute || cfg.CacheDays != 0 {
+		t.Errorf("overrides = %+v, %v", cfg, err)
+	}
+
+	for env, raw := range map[string]string{
+		EnvInterval:               "soon",
+		EnvBatchSize:              "0",
+		EnvDigestDays:             "-1",
+		EnvNotificationMarginDays: "week",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, raw)
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Errorf("%s=%q accepted", env, raw)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	DefaultCacheTTL     = 24 * time.Hour
+	DefaultCacheMaxRows = 5000
+	// cachePruneEvery is how many writes pass between prune sweeps; the
+	// table may overshoot MaxRows by up to this many rows in between.
+	cachePruneEvery = 50
+)
+
+// CacheStore is the persistence the completion cache needs. The Postgres
+// store satisfies it (table llm_cache).
+type CacheStore interface {
+	GetLLMCompletion(ctx context.Context, key string) (content string, createdAt time.Time, ok bool, err error)
+	UpsertLLMCompletion(ctx context.Context, key, model, content string) error
+	PruneLLMCompletions(ctx context.Context, maxAge time.Duration, maxRows int) (int64, error)
+}
+
+type bypassCacheKey struct{}
+
+// WithoutCache marks ctx so completions made under it skip the cache on
+// read. The fresh response is still written back, so a bypassed call also
+// refreshes the entry.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	b, _ := ctx.Value(bypassCacheKey{}).(bool)
+	return b
+}
+
+// CacheStats is a snapshot of the cache counters since process start.
+type CacheStats struct {
+	Hits     int64
+	Misses   int64
+	Bypassed int64
+	Errors   int64
+}
+
+// CachedCompleter puts a persistent completion cache in front of a
+// ChatCompleter. Requests are keyed by a hash of everything that shapes the
+// answer — model, messages, sampling params, response format — so only a
+// byte-identical request is served from cache. Store errors degrade to a
+// cache miss; the cache never fails a completion. It deliberately doesn't
+// implement ChatStreamer: a hit can't be expressed as an
+// *openai.ChatCompletionStream, so Shaper.chat unwraps it to stream from the
+// inner client and consults lookup/remember itself.
+type CachedCompleter struct {
+	inner   ChatCompleter
+	store   CacheStore
+	ttl     time.Duration
+	maxRows int
+
+	hits, misses, bypassed, errors, writes atomic.Int64
+}
+
+// NewCachedCompleter wraps inner. ttl <= 0 uses DefaultCacheTTL; maxRows
+// <= 0 uses DefaultCacheMaxRows.
+func NewCachedCompleter(inner ChatCompleter, store CacheStore, ttl time.Duration, maxRows int) *CachedCompleter {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxRows <= 0 {
+		maxRows = DefaultCacheMaxRows
+	}
+	return &CachedCompleter{inner: inner, store: store, ttl: ttl, maxRows: maxRows}
+}
+
+// CreateChatCompletion implements ChatCompleter.
+func (c *CachedCompleter) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if content, ok := c.lookup(ctx, req); ok {
+		return openai.ChatCompletionResponse{
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content},
+				FinishReason: openai.FinishReasonStop,
+			}},
+		}, nil
+	}
+	resp, err := c.inner.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if len(resp.Choices) > 0 && resp.Choices[0].FinishReason != openai.FinishReasonLength {
+		// A truncated answer would be served truncated forever; only
+		// complete ones are worth replaying.
+		c.remember(ctx, req, resp.Choices[0].Message.Content)
+	}
+	return resp, nil
+}
+
+// Stats returns the hit/miss counters.
+func (c *CachedCompleter) Stats() CacheStats {
+	return CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Bypassed: c.bypassed.Load(),
+		Errors:   c.errors.Load(),
+	}
+}
+
+func (c *CachedCompleter) lookup(ctx context.Context, req openai.ChatCompletionRequest) (string, bool) {
+	if cacheBypassed(ctx) {
+		c.bypassed.Add(1)
+		return "", false
+	}
+	content, createdAt, ok, err := c.store.GetLLMCompletion(ctx, cacheKey(req))
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if err != nil || !ok || time.Since(createdAt) > c.ttl || content == "" {
+		c.misses.Add(1)
+		return "", false
+	}
+	c.hits.Add(1)
+	return content, true
+}
+
+func (c *CachedCompleter) remember(ctx context.Context, req openai.ChatCompletionRequest, content string) {
+	if content == "" {
+		return
+	}
+	if err := c.store.UpsertLLMCompletion(ctx, cacheKey(req), req.Model, content); err != nil {
+		c.errors.Add(1)
+		return
+	}
+	if c.writes.Add(1)%cachePruneEvery == 0 {
+		if _, err := c.store.PruneLLMCompletions(ctx, c.ttl, c.maxRows); err != nil {
+			c.errors.Add(1)
+		}
+	}
+}
+
+// cacheKey hashes the request with the transport-only fields cleared, so a
+// streamed and a blocking call for the same prompt share an entry.
+func cacheKey(req openai.ChatCompletionRequest) string {
+	req.Stream = false
+	req.StreamOptions = nil
+	raw, _ := json.Marshal(req)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
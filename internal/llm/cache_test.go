@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+type memCacheStore struct {
+	rows   map[string]string
+	at     map[string]time.Time
+	getErr error
+	prunes int
+}
+
+func newMemCacheStore() *memCacheStore {
+	return &memCacheStore{rows: map[string]string{}, at: map[string]time.Time{}}
+}
+
+func (m *memCacheStore) GetLLMCompletion(_ context.Context, key string) (string, time.Time, bool, error) {
+	if m.getErr != nil {
+		return "", time.Time{}, false, m.getErr
+	}
+	c, ok := m.rows[key]
+	return c, m.at[key], ok, nil
+}
+
+func (m *memCacheStore) UpsertLLMCompletion(_ context.Context, key, _, content string) error {
+	m.rows[key] = content
+	m.at[key] = time.Now()
+	return nil
+}
+
+func (m *memCacheStore) PruneLLMCompletions(_ context.Context, _ time.Duration, _ int) (int64, error) {
+	m.prunes++
+	return 0, nil
+}
+
+func narrativeReq(temp float32) openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:       "m",
+		Temperature: temp,
+		Messages:    []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+	}
+}
+
+func TestCachedCompleter_HitMissAndParams(t *testing.T) {
+	f := &fakeCompleter{response: "answer"}
+	store := newMemCacheStore()
+	cc := NewCachedCompleter(f, store, time.Hour, 0)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		resp, err := cc.CreateChatCompletion(ctx, narrativeReq(0.2))
+		if err != nil || resp.Choices[0].Message.Content != "answer" {
+			t.Fatalf("call %d = %+v, %v", i, resp, err)
+		}
+	}
+	if f.calls != 1 {
+		t.Errorf("inner calls = %d, want 1 (second served from cache)", f.calls)
+	}
+	// A different sampling param is a different request.
+	if _, err := cc.CreateChatCompletion(ctx, narrativeReq(0.7)); err != nil {
+		t.Fatal(err)
+	}
+	if f.calls != 2 {
+		t.Errorf("inner calls = %d, want 2 after changing temperature", f.calls)
+	}
+	if st := cc.Stats(); st.Hits != 1 || st.Misses != 2 {
+		t.Errorf("stats = %+v, want 1 hit / 2 misses", st)
+	}
+}
+
+func TestCachedCompleter_TTLAndBypass(t *testing.T) {
+	f := &fakeCompleter{response: "answer"}
+	store := newMemCacheStore()
+	cc := NewCachedCompleter(f, store, time.Hour, 0)
+	ctx := context.Background()
+
+	_, _ = cc.CreateChatCompletion(ctx, narrativeReq(0.2))
+	_, _ = cc.CreateChatCompletion(WithoutCache(ctx), narrativeReq(0.2))
+	if f.calls != 2 {
+		t.Errorf("bypassed call should reach the model; calls = %d", f.calls)
+	}
+	for k := range store.at {
+		store.at[k] = time.Now().Add(-2 * time.Hour)
+	}
+	_, _ = cc.CreateChatCompletion(ctx, narrativeReq(0.2))
+	if f.calls != 3 {
+		t.Errorf("expired entry should miss; calls = %d", f.calls)
+	}
+	if st := cc.Stats(); st.Bypassed != 1 || st.Hits != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestCachedCompleter_StoreErrorIsMiss(t *testing.T) {
+	f := &fakeCompleter{response: "answer"}
+	store := newMemCacheStore()
+	store.getErr = errors.New("db down")
+	cc := NewCachedCompleter(f, store, time.Hour, 0)
+	if _, err := cc.CreateChatCompletion(context.Background(), narrativeReq(0.2)); err != nil {
+		t.Fatalf("store error must not fail the completion: %v", err)
+	}
+	if st := cc.Stats(); st.Errors != 1 || st.Misses != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestCachedCompleter_PrunesPeriodically(t *testing.T) {
+	f := &fakeCompleter{response: "answer"}
+	store := newMemCacheStore()
+	cc := NewCachedCompleter(f, store, time.Hour, 10)
+	for i := 0; i < cachePruneEvery; i++ {
+		_, _ = cc.CreateChatCompletion(context.Background(), narrativeReq(float32(i)))
+	}
+	if store.prunes != 1 {
+		t.Errorf("prunes = %d, want 1 after %d writes", store.prunes, cachePruneEvery)
+	}
+}
+
+func TestShaper_StreamingUsesCache(t *testing.T) {
+	srv := sseServer(t, `{"title":"Drop day","summary":"Three singles landed."}`, 9)
+	defer srv.Close()
+	cfg := Config{BaseURL: srv.URL + "/v1", Model: "m", APIKey: "k", Stream: true}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewCachedCompleter(client, newMemCacheStore(), time.Hour, 0)
+	s := NewShaper(cc, cfg)
+
+	in := FreshInput{
+		Post:     &redditJSON.RedditPost{ID: "p", Title: "t", Subreddit: "Metalcore"},
+		Progress: func(Output) {},
+	}
+	first, err := s.ShapeFresh(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partials int
+	in.Progress = func(Output) { partials++ }
+	second, err := s.ShapeFresh(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first || cc.Stats().Hits != 1 {
+		t.Errorf("second call = %+v (hits %d), want cached %+v", second, cc.Stats().Hits, first)
+	}
+	if partials != 1 {
+		t.Errorf("a cache hit should render once via Progress; got %d", partials)
+	}
+}
+
+func TestConfigFromEnv_CacheDisabled(t *testing.T) {
+	t.Setenv(EnvBaseURL, "http://x/v1")
+	t.Setenv(EnvModel, "m")
+	t.Setenv(EnvCacheTTL, "0")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CacheTTL != 0 {
+		t.Errorf("CacheTTL = %v, want 0 (disabled)", cfg.CacheTTL)
+	}
+}
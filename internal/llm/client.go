@@ -22,6 +22,19 @@ const (
 	EnvTimeout      = "LLM_TIMEOUT"
 	EnvTone         = "LLM_TONE"
 	EnvContextLimit = "LLM_CONTEXT_LIMIT"
+	EnvStream       = "LLM_STREAM"
+	EnvBackends     = "LLM_BACKENDS"
+	EnvModelNarr    = "LLM_MODEL_NARRATIVE"
+	EnvModelMusic   = "LLM_MODEL_MUSIC"
+	EnvModelClass   = "LLM_MODEL_CLASSIFY"
+	EnvModelEmbed   = "LLM_MODEL_EMBEDDING"
+	EnvBreakerCool  = "LLM_BREAKER_COOLDOWN"
+	EnvCacheTTL     = "LLM_CACHE_TTL"
+	EnvCacheMaxRows = "LLM_CACHE_MAX_ROWS"
+	EnvTokenizer    = "LLM_TOKENIZER_PATH"
+	EnvRespFormat   = "LLM_RESPONSE_FORMAT"
+	EnvRepairs      = "LLM_REPAIR_ATTEMPTS"
+	EnvVision       = "LLM_VISION"
 
 	DefaultTimeout      = 30 * time.Second
 	DefaultContextLimit = 8192
@@ -36,21 +49,106 @@ type Config struct {
 	Timeout      time.Duration
 	Tone         string
 	ContextLimit int
+	// Stream reads completions as SSE so callers that pass a Progress hook
+	// can render partial output while a long generation is in flight.
+	Stream bool
+	// Backends is the ordered failover list. Empty means a single backend at
+	// BaseURL.
+	Backends []BackendConfig
+	// NarrativeModel, MusicModel and ClassifyModel override Model for
+	// their mode; empty falls back to Model.
+	NarrativeModel  string
+	MusicModel      string
+	ClassifyModel   string
+	BreakerCooldown time.Duration
+	// EmbeddingModel is the /embeddings model for semantic rules. It has no
+	// fallback — a chat model can't embed — so empty disables them.
+	EmbeddingModel string
+	// CacheTTL is how long a cached completion is served; 0 disables the
+	// completion cache. CacheMaxRows caps the llm_cache table.
+	CacheTTL     time.Duration
+	CacheMaxRows int
+	// TokenizerPath points at the model's HuggingFace tokenizer.json for
+	// exact prompt budgeting; empty keeps the chars-per-token heuristic.
+	TokenizerPath string
+	// ResponseFormat is ResponseFormatJSONObject (default) or
+	// ResponseFormatJSONSchema, which sends the expected schema so the
+	// server constrains decoding to it.
+	ResponseFormat string
+	// RepairAttempts bounds the round-trips that send a validation error
+	// back to the model; 0 fails on the first invalid reply.
+	RepairAttempts int
+	// Vision attaches an image post's image to narrative prompts:
+	// VisionOff (default), VisionURL or VisionInline. Only enable it when
+	// the narrative model accepts image input.
+	Vision string
+}
+
+// Task names the kind of shaping a completion is for, so each can be routed
+// to its own model.
+type Task string
+
+const (
+	TaskNarrative Task = "narrative"
+	TaskMusic     Task = "music"
+	TaskClassify  Task = "classify"
+)
+
+// ModelFor returns the model id to request for task.
+func (c Config) ModelFor(task Task) string {
+	switch task {
+	case TaskNarrative:
+		if c.NarrativeModel != "" {
+			return c.NarrativeModel
+		}
+	case TaskMusic:
+		if c.MusicModel != "" {
+			return c.MusicModel
+		}
+	case TaskClassify:
+		if c.ClassifyModel != "" {
+			return c.ClassifyModel
+		}
+	}
+	return c.Model
 }
 
 // ConfigFromEnv reads Config from the process environment. Returns an error
-// if the required BaseURL or Model are missing or LLM_TIMEOUT is malformed.
+// if neither LLM_BACKENDS nor LLM_BASE_URL is set, Model is missing, or a
+// numeric/duration variable is malformed. When LLM_BACKENDS is set, BaseURL
+// is its first entry.
 func ConfigFromEnv() (Config, error) {
 	cfg := Config{
-		BaseURL:      os.Getenv(EnvBaseURL),
-		Model:        os.Getenv(EnvModel),
-		APIKey:       os.Getenv(EnvAPIKey),
-		Tone:         os.Getenv(EnvTone),
-		Timeout:      DefaultTimeout,
-		ContextLimit: DefaultContextLimit,
+		BaseURL:         os.Getenv(EnvBaseURL),
+		Model:           os.Getenv(EnvModel),
+		APIKey:          os.Getenv(EnvAPIKey),
+		Tone:            os.Getenv(EnvTone),
+		NarrativeModel:  os.Getenv(EnvModelNarr),
+		MusicModel:      os.Getenv(EnvModelMusic),
+		ClassifyModel:   os.Getenv(EnvModelClass),
+		EmbeddingModel:  os.Getenv(EnvModelEmbed),
+		TokenizerPath:   os.Getenv(EnvTokenizer),
+		Timeout:         DefaultTimeout,
+		ContextLimit:    DefaultContextLimit,
+		BreakerCooldown: DefaultBreakerCooldown,
+		CacheTTL:        DefaultCacheTTL,
+		CacheMaxRows:    DefaultCacheMaxRows,
+		ResponseFormat:  ResponseFormatJSONObject,
+		RepairAttempts:  DefaultRepairAttempts,
+		Vision:          VisionOff,
+	}
+	if raw := os.Getenv(EnvBackends); raw != "" {
+		backends, err := parseBackends(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", EnvBackends, err)
+		}
+		cfg.Backends = backends
+		if len(backends) > 0 {
+			cfg.BaseURL = backends[0].BaseURL
+		}
 	}
 	if cfg.BaseURL == "" {
-		return cfg, fmt.Errorf("missing %s", EnvBaseURL)
+		return cfg, fmt.Errorf("missing %s or %s", EnvBackends, EnvBaseURL)
 	}
 	if cfg.Model == "" {
 		return cfg, fmt.Errorf("missing %s", EnvModel)
@@ -69,6 +167,53 @@ func ConfigFromEnv() (Config, error) {
 		}
 		cfg.ContextLimit = n
 	}
+	if raw := os.Getenv(EnvBreakerCool); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s=%q: %w", EnvBreakerCool, raw, err)
+		}
+		cfg.BreakerCooldown = d
+	}
+	if raw := os.Getenv(EnvCacheTTL); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a non-negative duration", EnvCacheTTL, raw)
+		}
+		cfg.CacheTTL = d
+	}
+	if raw := os.Getenv(EnvCacheMaxRows); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a positive integer", EnvCacheMaxRows, raw)
+		}
+		cfg.CacheMaxRows = n
+	}
+	if raw := os.Getenv(EnvRespFormat); raw != "" {
+		if raw != ResponseFormatJSONObject && raw != ResponseFormatJSONSchema {
+			return cfg, fmt.Errorf("invalid %s=%q: must be %s or %s", EnvRespFormat, raw, ResponseFormatJSONObject, ResponseFormatJSONSchema)
+		}
+		cfg.ResponseFormat = raw
+	}
+	if raw := os.Getenv(EnvRepairs); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a non-negative integer", EnvRepairs, raw)
+		}
+		cfg.RepairAttempts = n
+	}
+	if raw := os.Getenv(EnvVision); raw != "" {
+		if raw != VisionOff && raw != VisionURL && raw != VisionInline {
+			return cfg, fmt.Errorf("invalid %s=%q: must be %s, %s or %s", EnvVision, raw, VisionOff, VisionURL, VisionInline)
+		}
+		cfg.Vision = raw
+	}
+	if raw := os.Getenv(EnvStream); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s=%q: %w", EnvStream, raw, err)
+		}
+		cfg.Stream = b
+	}
 	if cfg.APIKey == "" {
 		// vLLM accepts any token by default; the SDK refuses an empty one.
 		cfg.APIKey = "EMPTY"
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingCreator is the subset of *openai.Client (and *Router) Embedder
+// needs.
+type EmbeddingCreator interface {
+	CreateEmbeddings(ctx context.Context, conv openai.EmbeddingRequestConverter) (openai.EmbeddingResponse, error)
+}
+
+// Embedder turns text into vectors via the /embeddings endpoint, for
+// semantic rules.
+type Embedder struct {
+	client EmbeddingCreator
+	model  string
+}
+
+// NewEmbedder returns an Embedder requesting model from client.
+func NewEmbedder(client EmbeddingCreator, model string) *Embedder {
+	return &Embedder{client: client, model: model}
+}
+
+// Model is the embedding model id. Stored vectors record it so a model
+// change re-embeds rather than comparing across vector spaces.
+func (e *Embedder) Model() string { return e.model }
+
+// Embed returns one vector per text, in order.
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(e.model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings: got %d vectors for %d inputs", len(resp.Data), len(texts))
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embeddings: vector index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings: no vector for input %d", i)
+		}
+	}
+	return out, nil
+}
+
+// errDimensionMismatch is returned by Cosine for vectors of different
+// lengths, which come from different models.
+var errDimensionMismatch = errors.New("embedding dimensions differ")
+
+// Cosine returns the cosine similarity of a and b, in [-1, 1]. A zero
+// vector has similarity 0 with everything.
+func Cosine(a, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, errDimensionMismatch
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb)), nil
+}
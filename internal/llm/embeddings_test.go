@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type fakeEmbeddings struct {
+	req  openai.EmbeddingRequest
+	resp openai.EmbeddingResponse
+	err  error
+}
+
+func (f *fakeEmbeddings) CreateEmbeddings(_ context.Context, conv openai.EmbeddingRequestConverter) (openai.EmbeddingResponse, error) {
+	f.req = conv.Convert()
+	return f.resp, f.err
+}
+
+func TestEmbedder_Embed(t *testing.T) {
+	f := &fakeEmbeddings{resp: openai.EmbeddingResponse{Data: []openai.Embedding{
+		{Index: 1, Embedding: []float32{0, 1}},
+		{Index: 0, Embedding: []float32{1, 0}},
+	}}}
+	e := NewEmbedder(f, "bge-small")
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Errorf("vectors = %v, want them ordered by index", vecs)
+	}
+	if f.req.Model != "bge-small" || len(f.req.Input.([]string)) != 2 {
+		t.Errorf("request = %+v", f.req)
+	}
+
+	f.resp.Data = f.resp.Data[:1]
+	if _, err := e.Embed(context.Background(), []string{"a", "b"}); err == nil {
+		t.Error("a short response should be an error")
+	}
+	f.err = errors.New("down")
+	if _, err := e.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("a transport error should be returned")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		got, err := Cosine(tt.a, tt.b)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cosine(%v, %v) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	if _, err := Cosine([]float32{1}, []float32{1, 2}); err == nil {
+		t.Error("mismatched dimensions should be an error")
+	}
+}
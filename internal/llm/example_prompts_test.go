@@ -38,15 +38,22 @@ func TestExamplePrompts(t *testing.T) {
 	fmt.Println(systemPrompt)
 
 	fmt.Println("\n====================== FRESH USER PROMPT ======================")
-	fmt.Println(promptFresh(FreshInput{
+	fresh, err := promptFresh(FreshInput{
 		Post:         post,
 		RuleID:       2,
 		RuleTargetID: "title",
 		RuleExact:    false,
-	}, "", SummaryCharBudget))
+		Comments: []Comment{
+			{Author: "breakdown_enjoyer", Body: "Continents never miss.\nGatekeeper is a banger.", Score: 14},
+		},
+	}, "", SummaryCharBudget, heuristicTokenizer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(fresh)
 
 	fmt.Println("\n====================== UPDATE USER PROMPT ======================")
-	fmt.Println(promptUpdate(UpdateInput{
+	update, err := promptUpdate(UpdateInput{
 		PriorTitle: "Metalcore fans got eleven fresh singles this week",
 		PriorSummary: "Irken Armada kicked the week off with Nail In The Coffin, " +
 			"while Electric Callboy went genre-tourist with Hypercharged (feat. Brawl Stars). " +
@@ -69,5 +76,9 @@ func TestExamplePrompts(t *testing.T) {
 		NewRuleID:       3,
 		NewRuleTargetID: "title",
 		NewRuleExact:    false,
-	}, "", SummaryCharBudget))
+	}, "", SummaryCharBudget, heuristicTokenizer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(update)
 }
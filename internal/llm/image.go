@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// VisionOff sends narrative prompts as text only.
+	VisionOff = "off"
+	// VisionURL attaches a post's image by URL; the inference server
+	// downloads it.
+	VisionURL = "url"
+	// VisionInline downloads the image and attaches it as a base64 data
+	// URL, for servers that can't reach Reddit's CDN.
+	VisionInline = "inline"
+
+	// maxImageBytes caps an inline download. Reddit's preview renditions
+	// are a few hundred KiB; anything bigger is a full-resolution original
+	// the model doesn't need.
+	maxImageBytes = 4 << 20
+)
+
+// errImageTooLarge is returned by FetchImage for images over maxImageBytes.
+var errImageTooLarge = fmt.Errorf("image exceeds %d bytes", maxImageBytes)
+
+// FetchImage downloads rawURL, at most maxImageBytes of it, and returns it
+// as a data: URL suitable for an image_url message part. Non-image
+// responses are an error.
+func FetchImage(ctx context.Context, client *http.Client, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch image: HTTP %d", resp.StatusCode)
+	}
+	mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mt, "image/") {
+		return "", fmt.Errorf("fetch image: content type %q is not an image", resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("fetch image: %w", err)
+	}
+	if len(body) > maxImageBytes {
+		return "", errImageTooLarge
+	}
+	return "data:" + mt + ";base64," + base64.StdEncoding.EncodeToString(body), nil
+}
+
+// imagePart returns the image_url part for rawURL according to
+// Config.Vision, or nil when vision is off, there's no image, or the inline
+// download failed — the caller then sends text only.
+func (s *Shaper) imagePart(ctx context.Context, rawURL string) *openai.ChatMessagePart {
+	if rawURL == "" {
+		return nil
+	}
+	switch s.cfg.Vision {
+	case VisionURL:
+	case VisionInline:
+		data, err := FetchImage(ctx, s.images, rawURL)
+		if err != nil {
+			return nil
+		}
+		rawURL = data
+	default:
+		return nil
+	}
+	return &openai.ChatMessagePart{
+		Type:     openai.ChatMessagePartTypeImageURL,
+		ImageURL: &openai.ChatMessageImageURL{URL: rawURL, Detail: openai.ImageURLDetailLow},
+	}
+}
+
+// visionRejected reports whether a request with an image attached failed
+// because the backend won't take images. Servers without a vision model
+// answer 400; any other failure would hit the text-only retry too.
+func visionRejected(err error) bool {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	return (errors.As(err, &apiErr) || errors.As(err, &reqErr)) && !isRetryable(err)
+}
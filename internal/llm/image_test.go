@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+	"github.com/meriley/reddit-spy/internal/safehttp"
+)
+
+func TestShapeFresh_VisionURL(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Vision: VisionURL})
+	in := FreshInput{Post: &redditJSON.RedditPost{Title: "look"}, Image: "https://i.redd.it/cat.jpg"}
+	if _, err := s.ShapeFresh(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	user := f.req.Messages[1]
+	if len(user.MultiContent) != 2 || user.MultiContent[1].ImageURL == nil || user.MultiContent[1].ImageURL.URL != "https://i.redd.it/cat.jpg" {
+		t.Fatalf("user message = %+v, want text + image parts", user)
+	}
+	if !strings.Contains(user.MultiContent[0].Text, "image is attached") {
+		t.Errorf("prompt should mention the attached image:\n%s", user.MultiContent[0].Text)
+	}
+
+	// Vision off keeps the plain text prompt.
+	s = NewShaper(f, Config{Model: "m"})
+	if _, err := s.ShapeFresh(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if user := f.req.Messages[1]; user.MultiContent != nil || strings.Contains(user.Content, "image is attached") {
+		t.Errorf("vision off should send text only; got %+v", user)
+	}
+}
+
+func TestShapeFresh_VisionInline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html")
+		} else {
+			w.Header().Set("Content-Type", "image/png")
+		}
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Vision: VisionInline})
+	s.images = safehttp.NewClient(0, func(netip.AddrPort) bool { return true })
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}, Image: srv.URL + "/a.png"}); err != nil {
+		t.Fatal(err)
+	}
+	parts := f.req.Messages[1].MultiContent
+	if len(parts) != 2 || parts[1].ImageURL.URL != "data:image/png;base64,cG5n" {
+		t.Fatalf("parts = %+v, want an inline data URL", parts)
+	}
+
+	// A failed download degrades to text only.
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}, Image: srv.URL + "/page"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.req.Messages[1].MultiContent != nil {
+		t.Error("a non-image response should not be attached")
+	}
+}
+
+func TestShapeFresh_VisionInlineSkipsInternalImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Vision: VisionInline})
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}, Image: srv.URL + "/a.png"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.req.Messages[1].MultiContent != nil {
+		t.Error("an image on a loopback address should not be fetched")
+	}
+}
+
+// rejectImagesCompleter answers 400 to any request carrying an image part,
+// like a server running a text-only model.
+type rejectImagesCompleter struct {
+	fakeCompleter
+	rejected int
+}
+
+func (r *rejectImagesCompleter) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	for _, m := range req.Messages {
+		if len(m.MultiContent) > 0 {
+			r.rejected++
+			return openai.ChatCompletionResponse{}, &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "image input not supported"}
+		}
+	}
+	return r.fakeCompleter.CreateChatCompletion(ctx, req)
+}
+
+func TestShapeUpdate_VisionRejectedFallsBackToText(t *testing.T) {
+	r := &rejectImagesCompleter{fakeCompleter: fakeCompleter{response: `{"title":"t","summary":"s"}`}}
+	s := NewShaper(r, Config{Model: "m", Vision: VisionURL, RepairAttempts: 1})
+	out, err := s.ShapeUpdate(context.Background(), UpdateInput{
+		NewPost:  &redditJSON.RedditPost{Title: "look"},
+		NewImage: "https://i.redd.it/cat.jpg",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title != "t" || r.rejected != 1 {
+		t.Fatalf("out = %+v, rejected = %d", out, r.rejected)
+	}
+	if strings.Contains(r.req.Messages[1].Content, "image is attached") {
+		t.Error("the text-only retry should not mention an image")
+	}
+}
+
+func TestConfigFromEnv_Vision(t *testing.T) {
+	t.Setenv(EnvBaseURL, "http://x/v1")
+	t.Setenv(EnvModel, "m")
+	cfg, err := ConfigFromEnv()
+	if err != nil || cfg.Vision != VisionOff {
+		t.Fatalf("default vision = %q, %v", cfg.Vision, err)
+	}
+	t.Setenv(EnvVision, "yes")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("unknown vision mode should be rejected")
+	}
+	t.Setenv(EnvVision, VisionInline)
+	if cfg, _ := ConfigFromEnv(); cfg.Vision != VisionInline {
+		t.Errorf("vision = %q", cfg.Vision)
+	}
+}
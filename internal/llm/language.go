@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// knownLanguages are the languages ParseLanguage recognizes by name. Codes
+// for any other BCP 47 language still parse.
+var knownLanguages = []language.Tag{
+	language.English, language.German, language.French, language.Spanish,
+	language.Portuguese, language.Italian, language.Dutch, language.Swedish,
+	language.Norwegian, language.Danish, language.Finnish, language.Polish,
+	language.Czech, language.Russian, language.Ukrainian, language.Turkish,
+	language.Greek, language.Hungarian, language.Romanian, language.Japanese,
+	language.Korean, language.Chinese, language.Indonesian, language.Vietnamese,
+	language.Thai, language.Hindi, language.Arabic, language.Hebrew,
+}
+
+// ParseLanguage normalizes a target language given as a code ("de",
+// "pt-BR") or a name in English or in the language itself ("German",
+// "Deutsch") to its English name, which is what prompts and
+// source_language comparisons use.
+func ParseLanguage(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty language")
+	}
+	for _, tag := range knownLanguages {
+		if strings.EqualFold(s, display.English.Languages().Name(tag)) || strings.EqualFold(s, display.Self.Name(tag)) {
+			return display.English.Languages().Name(tag), nil
+		}
+	}
+	if tag, err := language.Parse(s); err == nil {
+		base, _ := tag.Base()
+		if name := display.English.Languages().Name(base); name != "" {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown language %q (use a name like German or a code like de)", s)
+}
+
+// sameLanguage reports whether two language names or codes denote the same
+// base language. Names ParseLanguage doesn't know compare as plain strings.
+func sameLanguage(a, b string) bool {
+	na, errA := ParseLanguage(a)
+	nb, errB := ParseLanguage(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+	}
+	return na == nb
+}
+
+// narrativeLanguageDirective is appended to systemPrompt when a channel has
+// a target language.
+const narrativeLanguageDirective = `Write the title and summary in %[1]s, whatever language
+the source post, article and comments are in. Write naturally in %[1]s rather than
+translating the post line by line. Keep names of people, bands, releases, games
+and places as they are written in the source. Also return a "source_language"
+key: the English name of the language the source post is written in (e.g.
+"Japanese").`
+
+// musicLanguageDirective is appended to systemPromptMusic when a channel has
+// a target language. Entries have no prose to translate; the directive
+// exists so a target language never leaks into artist names or titles.
+const musicLanguageDirective = `The digest reader reads %s, but artist names and release titles
+must be copied exactly as written in the post — never translate or
+transliterate them.`
+
+// narrativeSystemPrompt returns the narrative system prompt for a target
+// language; empty keeps the built-in prompt unchanged. The directive goes
+// before the trailing /no_think so that stays the prompt's last word.
+func narrativeSystemPrompt(lang string) string {
+	if lang == "" {
+		return systemPrompt
+	}
+	return strings.TrimSuffix(systemPrompt, " /no_think") + "\n\n" +
+		fmt.Sprintf(narrativeLanguageDirective, lang) + " /no_think"
+}
+
+// musicSystemPrompt is narrativeSystemPrompt for music extraction.
+func musicSystemPrompt(lang string) string {
+	if lang == "" {
+		return systemPromptMusic
+	}
+	return systemPromptMusic + "\n\n" + fmt.Sprintf(musicLanguageDirective, lang)
+}
+
+// translatedNarrativeSchema is narrativeSchema plus the detected source
+// language, used when a target language is set.
+var translatedNarrativeSchema = &jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"title":           {Type: jsonschema.String, Description: "Single-line digest title"},
+		"summary":         {Type: jsonschema.String, Description: "Markdown digest body"},
+		"source_language": {Type: jsonschema.String, Description: "English name of the source post's language"},
+	},
+	Required:             []string{"title", "summary", "source_language"},
+	AdditionalProperties: false,
+}
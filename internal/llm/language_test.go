@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func TestParseLanguage(t *testing.T) {
+	cases := map[string]string{
+		"de":      "German",
+		"German":  "German",
+		"deutsch": "German",
+		"日本語":     "Japanese",
+		"pt-BR":   "Portuguese",
+		" ja ":    "Japanese",
+	}
+	for in, want := range cases {
+		got, err := ParseLanguage(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLanguage(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "Elvish", "xx-nope"} {
+		if _, err := ParseLanguage(bad); err == nil {
+			t.Errorf("ParseLanguage(%q) should fail", bad)
+		}
+	}
+}
+
+func TestShapeFresh_TargetLanguage(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"Neues Album","summary":"s","source_language":"Japanese"}`}
+	s := NewShaper(f, Config{Model: "m"})
+	in := FreshInput{Post: &redditJSON.RedditPost{Title: "新しいアルバム"}, Prompt: PromptOptions{Language: "German"}}
+
+	out, err := s.ShapeFresh(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.OriginalTitle != "新しいアルバム" || out.SourceLanguage != "Japanese" {
+		t.Errorf("out = %+v, want the original title kept", out)
+	}
+	sys := f.req.Messages[0].Content
+	if !strings.Contains(sys, "Write the title and summary in German") || !strings.HasSuffix(sys, "/no_think") {
+		t.Errorf("system prompt missing the language directive:\n%s", sys)
+	}
+
+	// A post already in the target language needs no original title.
+	f.response = `{"title":"t","summary":"s","source_language":"de"}`
+	if out, _ := s.ShapeFresh(context.Background(), in); out.OriginalTitle != "" {
+		t.Errorf("original title = %q, want none for a German post", out.OriginalTitle)
+	}
+
+	// No target language leaves the prompt and schema as they were.
+	in.Prompt.Language = ""
+	f.response = `{"title":"t","summary":"s"}`
+	if _, err := s.ShapeFresh(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if f.req.Messages[0].Content != systemPrompt {
+		t.Error("system prompt should be the built-in one without a language")
+	}
+}
+
+func TestShapeFresh_TargetLanguageRequiresSourceLanguage(t *testing.T) {
+	m := &multiCompleter{responses: []string{
+		`{"title":"t","summary":"s"}`,
+		`{"title":"t","summary":"s","source_language":"English"}`,
+	}}
+	s := NewShaper(m, Config{Model: "m", RepairAttempts: 1})
+	in := FreshInput{Post: &redditJSON.RedditPost{Title: "x"}, Prompt: PromptOptions{Language: "English"}}
+	if _, err := s.ShapeFresh(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if m.calls != 2 || !strings.Contains(m.reqs[1].Messages[3].Content, `"source_language"`) {
+		t.Errorf("calls = %d; a missing source_language should trigger a repair", m.calls)
+	}
+}
+
+func TestShapeMusic_TargetLanguageKeepsNames(t *testing.T) {
+	m := &multiCompleter{responses: []string{`{"entries":[]}`}}
+	s := NewShaper(m, Config{Model: "m"})
+	in := MusicInput{Post: &redditJSON.RedditPost{Selftext: "A - B"}, Prompt: PromptOptions{Language: "German"}}
+	if _, err := s.ShapeMusic(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if sys := m.reqs[0].Messages[0].Content; !strings.Contains(sys, "never translate") {
+		t.Errorf("music system prompt should forbid translating names:\n%s", sys)
+	}
+}
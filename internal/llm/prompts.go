@@ -2,7 +2,9 @@ package llm
 
 import (
 	"encoding/json"
-	"fmt"
+	"strings"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
 )
 
 // /no_think at the end of Qwen3 system prompts suppresses its <think>…</think>
@@ -26,83 +28,89 @@ Keep any "feat. X" inside the title, not the artist. Skip section headers,
 playlists, and commentary. If the input contains no releases, return an
 empty entries array. Never invent entries that aren't in the post body.`
 
-// promptFresh builds the user prompt for the first matching post of a
-// (subreddit, Phoenix-day) pair.
-func promptFresh(in FreshInput, tone string, charBudget int) string {
-	t := toneLine(tone)
-	return fmt.Sprintf(`%s
+// freshTemplate is the built-in user prompt for the first matching post of
+// a (subreddit, Phoenix-day) pair.
+const freshTemplate = `{{.Tone}}
 
-A reddit-spy rule just matched its first post of the day from r/%s.
+A reddit-spy rule just matched its first post of the day from r/{{.Post.Subreddit}}.
 
 Produce a JSON object with exactly two keys:
   "title":   a punchy one-line title (max 120 chars, no quotes)
-  "summary": a narrative body (max %d chars) that captures what the post is
+  "summary": a narrative body (max {{.CharBudget}} chars) that captures what the post is
              about for a reader who will not click through. Preserve concrete
              names (bands, games, people) when they appear in the source.
 
 Source post:
-  author:   u/%s
-  title:    %s
-  rule:     #%d matched on %s (%s)
-  selftext: %s
-
-Return ONLY the JSON object, nothing else.`,
-		t,
-		in.Post.Subreddit,
-		charBudget,
-		in.Post.Author,
-		quoteSingleLine(in.Post.Title),
-		in.RuleID, in.RuleTargetID, ruleMatchType(in.RuleExact),
-		clipForPrompt(in.Post.Selftext, 6000),
-	)
-}
+  author:   u/{{.Post.Author}}
+  title:    {{.Post.Title}}
+  rule:     #{{.Rule.ID}} matched on {{.Rule.TargetID}} ({{.Rule.MatchType}})
+  selftext: {{.Post.Selftext}}
+{{- if .Image}}
+
+The post's image is attached. Describe what it shows — that is what the post
+is about.
+{{- end}}
+{{- if .Article}}
+
+Linked article (excerpt):
+{{.Article}}
+{{- end}}
+{{- if .Comments}}
+
+Top comments — fold the gist of the discussion into the summary:
+{{- range .Comments}}
+  - u/{{.Author}} ({{.Score}} points): {{.Body}}
+{{- end}}
+{{- end}}
 
-// promptUpdate builds the user prompt for a later match that folds into an
-// existing rolling digest.
-func promptUpdate(in UpdateInput, tone string, charBudget int) string {
-	t := toneLine(tone)
-	return fmt.Sprintf(`%s
+Return ONLY the JSON object, nothing else.`
 
-A reddit-spy rule just matched another post from r/%s today. A running digest
+// updateTemplate is the built-in user prompt for a later match that folds
+// into an existing rolling digest.
+const updateTemplate = `{{.Tone}}
+
+A reddit-spy rule just matched another post from r/{{.Post.Subreddit}} today. A running digest
 already exists — rewrite it so the new post is woven into the narrative, not
 appended as a separate paragraph. Keep the overall tone consistent.
 
 Produce a JSON object with exactly two keys:
   "title":   an updated one-line title (max 120 chars, no quotes)
-  "summary": the rewritten narrative body (max %d chars).
+  "summary": the rewritten narrative body (max {{.CharBudget}} chars).
 
 Existing digest:
-  title:    %s
-  summary:  %s
-  posts so far: %d
+  title:    {{.Digest.Title}}
+  summary:  {{.Digest.Summary}}
+  posts so far: {{.Digest.PostCount}}
 
 New post:
-  author:   u/%s
-  title:    %s
-  rule:     #%d matched on %s (%s)
-  selftext: %s
-
-Return ONLY the JSON object, nothing else.`,
-		t,
-		in.NewPost.Subreddit,
-		charBudget,
-		quoteSingleLine(in.PriorTitle),
-		clipForPrompt(in.PriorSummary, charBudget),
-		in.PriorPostCount,
-		in.NewPost.Author,
-		quoteSingleLine(in.NewPost.Title),
-		in.NewRuleID, in.NewRuleTargetID, ruleMatchType(in.NewRuleExact),
-		clipForPrompt(in.NewPost.Selftext, 6000),
-	)
-}
+  author:   u/{{.Post.Author}}
+  title:    {{.Post.Title}}
+  rule:     #{{.Rule.ID}} matched on {{.Rule.TargetID}} ({{.Rule.MatchType}})
+  selftext: {{.Post.Selftext}}
+{{- if .Image}}
 
-// promptMusicExtract is the user prompt for the music-digest shaper. Passes
-// the already-known entries so the model can skip duplicates across days /
-// threads / subreddits. body is the post selftext for this call; the caller
-// owns sizing (via chunking or direct pass-through).
-func promptMusicExtract(in MusicInput, body string) string {
-	knownJSON, _ := json.Marshal(shrinkForSkipList(in.KnownEntries))
-	return fmt.Sprintf(`Extract music releases from the Reddit post below.
+The post's image is attached. Describe what it shows — that is what the post
+is about.
+{{- end}}
+{{- if .Article}}
+
+Linked article (excerpt):
+{{.Article}}
+{{- end}}
+{{- if .Comments}}
+
+Top comments — fold the gist of the discussion into the summary:
+{{- range .Comments}}
+  - u/{{.Author}} ({{.Score}} points): {{.Body}}
+{{- end}}
+{{- end}}
+
+Return ONLY the JSON object, nothing else.`
+
+// musicTemplate is the built-in user prompt for the music-digest shaper. It
+// passes the already-known entries so the model can skip duplicates across
+// days / threads / subreddits.
+const musicTemplate = `Extract music releases from the Reddit post below.
 
 Return a JSON object of the form:
   {"entries": [{"artist": "...", "title": "...", "kind": "single"|"album"|"ep"}, ...]}
@@ -111,24 +119,97 @@ Do NOT include any entry whose normalized (artist, title, kind) already
 appears in the skip list; case and whitespace do not matter, and any
 "(feat. ...)" fragment is ignored when comparing.
 
-Skip list (%d entries):
-%s
+Skip list ({{.SkipCount}} entries):
+{{.SkipList}}
 
 Source post:
-  author:    u/%s
-  subreddit: r/%s
-  title:     %s
+  author:    u/{{.Post.Author}}
+  subreddit: r/{{.Post.Subreddit}}
+  title:     {{.Post.Title}}
   body:
-%s
-
-Return ONLY the JSON object, nothing else.`,
-		len(in.KnownEntries),
-		string(knownJSON),
-		in.Post.Author,
-		in.Post.Subreddit,
-		quoteSingleLine(in.Post.Title),
-		body,
-	)
+{{.Post.Selftext}}
+
+Return ONLY the JSON object, nothing else.`
+
+// promptFresh renders the fresh-match prompt, using in.Prompt.Template
+// instead of the built-in when set.
+func promptFresh(in FreshInput, tone string, charBudget int, tok Tokenizer) (string, error) {
+	return renderPrompt(PromptFresh, in.Prompt.Template, PromptData{
+		Tone:       toneLine(tone),
+		CharBudget: charBudget,
+		Post:       promptPost(in.Post, clipForPrompt(tok, in.Post.Selftext, selftextTokenBudget)),
+		Rule:       promptRule(in.RuleID, in.RuleTargetID, in.RuleExact),
+		Comments:   promptComments(tok, in.Comments),
+		Article:    clipForPrompt(tok, strings.TrimSpace(in.Article), articleTokenBudget),
+		Image:      in.Image != "",
+	})
+}
+
+// promptUpdate renders the update prompt, using in.Prompt.Template instead
+// of the built-in when set.
+func promptUpdate(in UpdateInput, tone string, charBudget int, tok Tokenizer) (string, error) {
+	return renderPrompt(PromptUpdate, in.Prompt.Template, PromptData{
+		Tone:       toneLine(tone),
+		CharBudget: charBudget,
+		Post:       promptPost(in.NewPost, clipForPrompt(tok, in.NewPost.Selftext, selftextTokenBudget)),
+		Rule:       promptRule(in.NewRuleID, in.NewRuleTargetID, in.NewRuleExact),
+		Comments:   promptComments(tok, in.NewComments),
+		Article:    clipForPrompt(tok, strings.TrimSpace(in.NewArticle), articleTokenBudget),
+		Image:      in.NewImage != "",
+		Digest: PromptDigest{
+			Title:     quoteSingleLine(in.PriorTitle),
+			Summary:   clipRunes(in.PriorSummary, charBudget),
+			PostCount: in.PriorPostCount,
+		},
+	})
+}
+
+// promptMusicExtract renders the music prompt. body is the post selftext
+// for this call; the caller owns sizing (via chunking or direct
+// pass-through).
+func promptMusicExtract(in MusicInput, tone string, body string) (string, error) {
+	knownJSON, _ := json.Marshal(shrinkForSkipList(in.KnownEntries))
+	return renderPrompt(PromptMusic, in.Prompt.Template, PromptData{
+		Tone:      toneLine(tone),
+		Post:      promptPost(in.Post, body),
+		Rule:      promptRule(in.RuleID, in.RuleTargetID, in.RuleExact),
+		SkipList:  string(knownJSON),
+		SkipCount: len(in.KnownEntries),
+	})
+}
+
+func promptPost(p *redditJSON.RedditPost, selftext string) PromptPost {
+	return PromptPost{
+		Author:    p.Author,
+		Subreddit: p.Subreddit,
+		Title:     quoteSingleLine(p.Title),
+		Selftext:  selftext,
+		URL:       p.URL,
+	}
+}
+
+func promptRule(id int, targetID string, exact bool) PromptRule {
+	return PromptRule{ID: id, TargetID: targetID, Exact: exact, MatchType: ruleMatchType(exact)}
+}
+
+// promptComments flattens and clips comments in order until
+// commentsTokenBudget is spent.
+func promptComments(tok Tokenizer, comments []Comment) []PromptComment {
+	var out []PromptComment
+	spent := 0
+	for _, c := range comments {
+		body := clipForPrompt(tok, strings.Join(strings.Fields(c.Body), " "), commentTokenBudget)
+		if body == "" {
+			continue
+		}
+		cost := tok.Count(body)
+		if spent+cost > commentsTokenBudget {
+			break
+		}
+		spent += cost
+		out = append(out, PromptComment{Author: c.Author, Body: body, Score: c.Score})
+	}
+	return out
 }
 
 // shrinkForSkipList trims fields we don't need inside the prompt so the skip
@@ -141,17 +222,6 @@ func shrinkForSkipList(in []MusicEntry) []MusicEntry {
 	return out
 }
 
-func toneLine(tone string) string {
-	switch tone {
-	case "snarky":
-		return "TONE: dry and mildly snarky, but never mean-spirited. No emoji."
-	case "playful":
-		return "TONE: warm and playful. Sparing use of emoji is acceptable."
-	default:
-		return "TONE: neutral and informative. No emoji."
-	}
-}
-
 func ruleMatchType(exact bool) string {
 	if exact {
 		return "exact"
@@ -159,15 +229,28 @@ func ruleMatchType(exact bool) string {
 	return "partial"
 }
 
-// clipForPrompt caps a source string at maxRunes runes so we don't stuff a
+// selftextTokenBudget caps how much of a post body goes into a narrative
+// prompt — about 6000 characters of English at the heuristic rate.
+const selftextTokenBudget = 2000
+
+const (
+	// articleTokenBudget caps the linked-article excerpt in a narrative
+	// prompt.
+	articleTokenBudget = 1000
+	// commentTokenBudget caps one comment; commentsTokenBudget caps them all
+	// together, so a couple of essays can't crowd out the rest.
+	commentTokenBudget  = 150
+	commentsTokenBudget = 800
+)
+
+// clipForPrompt caps a source string at maxTokens tokens so we don't stuff a
 // multi-kilobyte selftext into the prompt. Returns the string unchanged if
 // it's already short enough.
-func clipForPrompt(s string, maxRunes int) string {
-	runes := []rune(s)
-	if len(runes) <= maxRunes {
+func clipForPrompt(tok Tokenizer, s string, maxTokens int) string {
+	if tok.Count(s) <= maxTokens {
 		return s
 	}
-	return string(runes[:maxRunes]) + "…[truncated]"
+	return tok.Truncate(s, maxTokens) + "…[truncated]"
 }
 
 // quoteSingleLine replaces newlines with spaces so a title never breaks the
@@ -0,0 +1,292 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultBreakerThreshold is how many consecutive failures open a
+	// backend's circuit.
+	DefaultBreakerThreshold = 3
+	// DefaultBreakerCooldown is how long an open circuit stays open before a
+	// single half-open probe request is let through.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrBreakerOpen is returned without a request when every backend serving
+// a model has an open circuit whose cooldown hasn't passed.
+var ErrBreakerOpen = errors.New("llm: circuit breaker open")
+
+// BackendConfig is one OpenAI-compatible endpoint in the failover list.
+// Models, when non-empty, restricts the backend to requests for those model
+// ids (e.g. a small pod that only serves the narrative model); empty means
+// it serves whatever the request asks for.
+type BackendConfig struct {
+	BaseURL string
+	Models  []string
+}
+
+// parseBackends reads LLM_BACKENDS: comma-separated entries, each a base URL
+// optionally followed by "|model|model…".
+func parseBackends(raw string) ([]BackendConfig, error) {
+	var out []BackendConfig
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		b := BackendConfig{BaseURL: strings.TrimSpace(parts[0])}
+		if b.BaseURL == "" {
+			return nil, fmt.Errorf("backend entry %q has no base URL", entry)
+		}
+		for _, m := range parts[1:] {
+			if m = strings.TrimSpace(m); m != "" {
+				b.Models = append(b.Models, m)
+			}
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// BackendStatus is a point-in-time health snapshot for /status.
+type BackendStatus struct {
+	BaseURL             string
+	Healthy             bool
+	ConsecutiveFailures int
+	OpenUntil           time.Time
+	LastError           string
+}
+
+type backend struct {
+	cfg    BackendConfig
+	client *openai.Client
+
+	failures  int
+	openUntil time.Time
+	probing   bool // a half-open probe is in flight
+	lastErr   string
+}
+
+func (b *backend) serves(model string) bool {
+	if len(b.cfg.Models) == 0 {
+		return true
+	}
+	for _, m := range b.cfg.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Router fans a chat completion out over an ordered list of backends: the
+// first healthy backend that serves the requested model wins, failures fall
+// through to the next one, and a backend that fails BreakerThreshold times in
+// a row is skipped until its cooldown elapses. It satisfies ChatCompleter and
+// ChatStreamer, so the Shaper is unaware there's more than one endpoint.
+type Router struct {
+	mu        sync.Mutex
+	backends  []*backend
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// NewRouter builds a Router over cfg.Backends (or the single cfg.BaseURL
+// when no list is configured). Each backend gets its own *openai.Client with
+// cfg's API key and timeout.
+func NewRouter(cfg Config) (*Router, error) {
+	list := cfg.Backends
+	if len(list) == 0 {
+		list = []BackendConfig{{BaseURL: cfg.BaseURL}}
+	}
+	r := &Router{
+		threshold: DefaultBreakerThreshold,
+		cooldown:  cfg.BreakerCooldown,
+		now:       time.Now,
+	}
+	if r.cooldown <= 0 {
+		r.cooldown = DefaultBreakerCooldown
+	}
+	for _, bc := range list {
+		if bc.BaseURL == "" {
+			return nil, errors.New("llm.NewRouter: backend BaseURL is required")
+		}
+		openaiCfg := openai.DefaultConfig(cfg.APIKey)
+		openaiCfg.BaseURL = bc.BaseURL
+		openaiCfg.HTTPClient = &http.Client{Timeout: cfg.Timeout}
+		r.backends = append(r.backends, &backend{cfg: bc, client: openai.NewClientWithConfig(openaiCfg)})
+	}
+	return r, nil
+}
+
+// CreateChatCompletion implements ChatCompleter with failover.
+func (r *Router) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	var resp openai.ChatCompletionResponse
+	err := r.try(ctx, req.Model, func(b *backend) error {
+		var err error
+		resp, err = b.client.CreateChatCompletion(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// CreateChatCompletionStream implements ChatStreamer. Failover only covers
+// opening the stream; an error mid-stream surfaces to the caller, which has
+// already rendered partial output from this backend.
+func (r *Router) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	var stream *openai.ChatCompletionStream
+	err := r.try(ctx, req.Model, func(b *backend) error {
+		var err error
+		stream, err = b.client.CreateChatCompletionStream(ctx, req)
+		return err
+	})
+	return stream, err
+}
+
+// CreateEmbeddings implements EmbeddingCreator with the same failover as
+// chat completions, so an embedding model can be listed on the backend that
+// serves it.
+func (r *Router) CreateEmbeddings(ctx context.Context, conv openai.EmbeddingRequestConverter) (openai.EmbeddingResponse, error) {
+	var resp openai.EmbeddingResponse
+	req := conv.Convert()
+	err := r.try(ctx, string(req.Model), func(b *backend) error {
+		var err error
+		resp, err = b.client.CreateEmbeddings(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// try runs call against each eligible backend in order until one succeeds.
+// Backends with an open circuit are skipped, even if every closed one
+// fails; when every backend for model is open, try fails with
+// ErrBreakerOpen until a cooldown passes and a probe is let through.
+func (r *Router) try(ctx context.Context, model string, call func(*backend) error) error {
+	served := false
+	var errs []error
+	for _, b := range r.backends {
+		if !b.serves(model) {
+			continue
+		}
+		served = true
+		probe, ok := r.acquire(b)
+		if !ok {
+			continue
+		}
+		err := call(b)
+		if err == nil {
+			r.recordSuccess(b)
+			return nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the backend.
+			r.release(b, probe)
+			return err
+		}
+		r.recordFailure(b, probe, err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.cfg.BaseURL, err))
+		if !isRetryable(err) {
+			break
+		}
+	}
+	switch {
+	case !served:
+		return fmt.Errorf("llm: no backend serves model %q", model)
+	case len(errs) == 0:
+		return fmt.Errorf("%w for model %q", ErrBreakerOpen, model)
+	}
+	return errors.Join(errs...)
+}
+
+// acquire reports whether b may be called now. A closed circuit always
+// may; a half-open one lets exactly one probe through, and probe reports
+// that this call is it. The caller settles a probe with recordSuccess,
+// recordFailure or release.
+func (r *Router) acquire(b *backend) (probe, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case b.failures < r.threshold:
+		return false, true
+	case r.now().After(b.openUntil) && !b.probing:
+		b.probing = true
+		return true, true
+	}
+	return false, false
+}
+
+func (r *Router) recordSuccess(b *backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.openUntil = time.Time{}
+	b.lastErr = ""
+}
+
+// release ends a half-open probe without judging the backend.
+func (r *Router) release(b *backend, probe bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+}
+
+func (r *Router) recordFailure(b *backend, probe bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b.failures++
+	if probe {
+		b.probing = false
+	}
+	b.lastErr = err.Error()
+	if b.failures >= r.threshold {
+		b.openUntil = r.now().Add(r.cooldown)
+	}
+}
+
+// isRetryable reports whether another backend might succeed where this one
+// failed. A 400 means the request itself is bad and would fail everywhere;
+// everything else (transport errors, 5xx, 404 model-not-loaded, 429) fails
+// over.
+func isRetryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusBadRequest {
+		return false
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode == http.StatusBadRequest {
+		return false
+	}
+	return true
+}
+
+// Status reports every backend's breaker state in configured order.
+func (r *Router) Status() []BackendStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	out := make([]BackendStatus, 0, len(r.backends))
+	for _, b := range r.backends {
+		out = append(out, BackendStatus{
+			BaseURL:             b.cfg.BaseURL,
+			Healthy:             b.failures < r.threshold || now.After(b.openUntil),
+			ConsecutiveFailures: b.failures,
+			OpenUntil:           b.openUntil,
+			LastError:           b.lastErr,
+		})
+	}
+	return out
+}
@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// backendServer answers chat completions with status (200 → a fixed JSON
+// body naming the server) and counts hits.
+func backendServer(t *testing.T, name string, status *atomic.Int32, hits *atomic.Int32, models *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if models != nil {
+			*models = append(*models, req.Model)
+		}
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, `{"error":{"message":"%s down"}}`, name)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"%s"}}]}`, name)
+	}))
+}
+
+func newTestRouter(t *testing.T, backends ...BackendConfig) (*Router, *time.Time) {
+	t.Helper()
+	r, err := NewRouter(Config{APIKey: "k", Backends: backends, BreakerCooldown: time.Minute})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	now := time.Date(2026, 4, 20, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func ask(r *Router, model string) (string, error) {
+	resp, err := r.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: model})
+	if err != nil {
+		return "", err
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func TestRouter_FailsOverAndTripsBreaker(t *testing.T) {
+	var aStatus, bStatus, aHits, bHits atomic.Int32
+	aStatus.Store(http.StatusServiceUnavailable)
+	bStatus.Store(http.StatusOK)
+	a := backendServer(t, "a", &aStatus, &aHits, nil)
+	defer a.Close()
+	b := backendServer(t, "b", &bStatus, &bHits, nil)
+	defer b.Close()
+
+	r, now := newTestRouter(t, BackendConfig{BaseURL: a.URL + "/v1"}, BackendConfig{BaseURL: b.URL + "/v1"})
+
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		got, err := ask(r, "m")
+		if err != nil || got != "b" {
+			t.Fatalf("call %d = %q, %v; want failover to b", i, got, err)
+		}
+	}
+	if st := r.Status(); st[0].Healthy || st[0].ConsecutiveFailures != DefaultBreakerThreshold {
+		t.Fatalf("backend a status = %+v, want tripped", st[0])
+	}
+
+	// Open circuit: a is skipped entirely.
+	if _, err := ask(r, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if aHits.Load() != DefaultBreakerThreshold {
+		t.Errorf("a hits = %d, want %d (skipped while open)", aHits.Load(), DefaultBreakerThreshold)
+	}
+
+	// After the cooldown a single probe goes to a; it recovers.
+	aStatus.Store(http.StatusOK)
+	*now = now.Add(2 * time.Minute)
+	if got, _ := ask(r, "m"); got != "a" {
+		t.Errorf("probe = %q, want a", got)
+	}
+	if st := r.Status(); !st[0].Healthy || st[0].ConsecutiveFailures != 0 {
+		t.Errorf("backend a status after probe = %+v, want healthy", st[0])
+	}
+}
+
+func TestRouter_AllOpenFailsFast(t *testing.T) {
+	var status, hits atomic.Int32
+	status.Store(http.StatusBadGateway)
+	a := backendServer(t, "a", &status, &hits, nil)
+	defer a.Close()
+	r, now := newTestRouter(t, BackendConfig{BaseURL: a.URL + "/v1"})
+
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		_, _ = ask(r, "m")
+	}
+	status.Store(http.StatusOK)
+	if _, err := ask(r, "m"); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("ask with every breaker open = %v; want ErrBreakerOpen", err)
+	}
+	if hits.Load() != DefaultBreakerThreshold {
+		t.Errorf("hits = %d, want %d (no request while open)", hits.Load(), DefaultBreakerThreshold)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if got, err := ask(r, "m"); err != nil || got != "a" {
+		t.Errorf("probe after cooldown = %q, %v; want a", got, err)
+	}
+}
+
+// A half-open backend behind one that answers is never called, so it must
+// not be left marked as probing.
+func TestRouter_UnusedProbeReleased(t *testing.T) {
+	var aStatus, bStatus, aHits, bHits atomic.Int32
+	aStatus.Store(http.StatusOK)
+	bStatus.Store(http.StatusServiceUnavailable)
+	a := backendServer(t, "a", &aStatus, &aHits, nil)
+	defer a.Close()
+	b := backendServer(t, "b", &bStatus, &bHits, nil)
+	defer b.Close()
+	r, now := newTestRouter(t,
+		BackendConfig{BaseURL: a.URL + "/v1", Models: []string{"fast"}},
+		BackendConfig{BaseURL: b.URL + "/v1"},
+	)
+
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		_, _ = ask(r, "large")
+	}
+	*now = now.Add(2 * time.Minute)
+	// b is half-open, but a answers first.
+	if got, err := ask(r, "fast"); err != nil || got != "a" {
+		t.Fatalf("fast = %q, %v; want a", got, err)
+	}
+	bStatus.Store(http.StatusOK)
+	if got, err := ask(r, "large"); err != nil || got != "b" {
+		t.Errorf("probe after an unused half-open slot = %q, %v; want b", got, err)
+	}
+	if st := r.Status(); !st[1].Healthy || st[1].ConsecutiveFailures != 0 {
+		t.Errorf("backend b status = %+v, want recovered", st[1])
+	}
+}
+
+func TestRouter_OpenSkippedWhenClosedFails(t *testing.T) {
+	var aStatus, bStatus, aHits, bHits atomic.Int32
+	aStatus.Store(http.StatusServiceUnavailable)
+	bStatus.Store(http.StatusOK)
+	a := backendServer(t, "a", &aStatus, &aHits, nil)
+	defer a.Close()
+	b := backendServer(t, "b", &bStatus, &bHits, nil)
+	defer b.Close()
+	r, _ := newTestRouter(t, BackendConfig{BaseURL: a.URL + "/v1"}, BackendConfig{BaseURL: b.URL + "/v1"})
+
+	for i := 0; i < DefaultBreakerThreshold; i++ {
+		_, _ = ask(r, "m")
+	}
+	// a is open; b is closed but now failing too. The failure surfaces
+	// without falling through to a.
+	bStatus.Store(http.StatusServiceUnavailable)
+	if _, err := ask(r, "m"); err == nil {
+		t.Fatal("expected b's failure to surface")
+	}
+	if aHits.Load() != DefaultBreakerThreshold {
+		t.Errorf("a hits = %d, want %d (open circuit skipped)", aHits.Load(), DefaultBreakerThreshold)
+	}
+}
+
+func TestRouter_BadRequestDoesNotFailOver(t *testing.T) {
+	var aStatus, bStatus, aHits, bHits atomic.Int32
+	aStatus.Store(http.StatusBadRequest)
+	bStatus.Store(http.StatusOK)
+	a := backendServer(t, "a", &aStatus, &aHits, nil)
+	defer a.Close()
+	b := backendServer(t, "b", &bStatus, &bHits, nil)
+	defer b.Close()
+	r, _ := newTestRouter(t, BackendConfig{BaseURL: a.URL + "/v1"}, BackendConfig{BaseURL: b.URL + "/v1"})
+
+	if _, err := ask(r, "m"); err == nil {
+		t.Fatal("expected the 400 to surface")
+	}
+	if bHits.Load() != 0 {
+		t.Errorf("b hits = %d, want 0", bHits.Load())
+	}
+}
+
+func TestRouter_RoutesByModel(t *testing.T) {
+	var status, smallHits, bigHits atomic.Int32
+	status.Store(http.StatusOK)
+	small := backendServer(t, "small", &status, &smallHits, nil)
+	defer small.Close()
+	big := backendServer(t, "big", &status, &bigHits, nil)
+	defer big.Close()
+	r, _ := newTestRouter(t,
+		BackendConfig{BaseURL: small.URL + "/v1", Models: []string{"fast"}},
+		BackendConfig{BaseURL: big.URL + "/v1", Models: []string{"large"}},
+	)
+
+	if got, _ := ask(r, "large"); got != "big" {
+		t.Errorf("large → %q, want big", got)
+	}
+	if got, _ := ask(r, "fast"); got != "small" {
+		t.Errorf("fast → %q, want small", got)
+	}
+	if _, err := ask(r, "other"); err == nil {
+		t.Error("expected an error for a model no backend serves")
+	}
+}
+
+func TestRouter_CallerCancelNotCounted(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+	r, _ := newTestRouter(t, BackendConfig{BaseURL: srv.URL + "/v1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: "m"}); err == nil {
+		t.Fatal("expected a context error")
+	}
+	if st := r.Status(); st[0].ConsecutiveFailures != 0 {
+		t.Errorf("failures = %d, want 0 after caller cancel", st[0].ConsecutiveFailures)
+	}
+}
+
+func TestShaper_UsesPerTaskModel(t *testing.T) {
+	var status, hits atomic.Int32
+	status.Store(http.StatusOK)
+	var models []string
+	srv := backendServer(t, "x", &status, &hits, &models)
+	defer srv.Close()
+	cfg := Config{APIKey: "k", BaseURL: srv.URL + "/v1", Model: "default", MusicModel: "large"}
+	r, err := NewRouter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewShaper(r, cfg)
+	post := &redditJSON.RedditPost{ID: "p", Title: "t", Selftext: "A - One"}
+	_, _ = s.ShapeFresh(context.Background(), FreshInput{Post: post})
+	_, _ = s.ShapeMusic(context.Background(), MusicInput{Post: post})
+	if len(models) < 2 || models[0] != "default" || models[1] != "large" {
+		t.Errorf("requested models = %v, want [default large …]", models)
+	}
+}
+
+func TestConfigFromEnv_Backends(t *testing.T) {
+	t.Setenv(EnvBaseURL, "")
+	t.Setenv(EnvModel, "m")
+	t.Setenv(EnvBackends, "http://a/v1|fast, http://b/v1|fast|large ,")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv: %v", err)
+	}
+	if len(cfg.Backends) != 2 || cfg.BaseURL != "http://a/v1" {
+		t.Fatalf("backends = %+v, base = %q", cfg.Backends, cfg.BaseURL)
+	}
+	if got := cfg.Backends[1].Models; len(got) != 2 || got[1] != "large" {
+		t.Errorf("second backend models = %v", got)
+	}
+	if cfg.ModelFor(TaskMusic) != "m" {
+		t.Errorf("music model fallback = %q, want m", cfg.ModelFor(TaskMusic))
+	}
+}
+
+var (
+	_ ChatCompleter = (*Router)(nil)
+	_ ChatStreamer  = (*Router)(nil)
+)
@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 
 	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+	"github.com/meriley/reddit-spy/internal/safehttp"
 )
 
 // Mode selects which prompt variant the shaper uses.
@@ -26,6 +30,21 @@ type FreshInput struct {
 	RuleID       int
 	RuleTargetID string
 	RuleExact    bool
+	// Prompt carries the rule's or channel's template and tone overrides.
+	Prompt PromptOptions
+	// Comments are the post's top comments and Article the readable text of
+	// the page a link post points at. Both are optional and clipped to the
+	// prompt's budgets.
+	Comments []Comment
+	Article  string
+	// Image is the URL of the post's image, for image posts. It's attached
+	// to the request only when Config.Vision is on.
+	Image string
+
+	// Progress, when set, receives partial output as the completion streams
+	// in. Only invoked when streaming is enabled (LLM_STREAM) and the client
+	// supports it; the return value of ShapeFresh remains authoritative.
+	Progress func(Output)
 }
 
 // UpdateInput is the payload for a subsequent match that edits an existing
@@ -38,12 +57,34 @@ type UpdateInput struct {
 	NewRuleID       int
 	NewRuleTargetID string
 	NewRuleExact    bool
+	Prompt          PromptOptions
+	// NewComments, NewArticle and NewImage mirror FreshInput's fields for
+	// NewPost.
+	NewComments []Comment
+	NewArticle  string
+	NewImage    string
+
+	// Progress mirrors FreshInput.Progress for the update prompt.
+	Progress func(Output)
+}
+
+// Comment is one top-level reply to a post, as shown to the model.
+type Comment struct {
+	Author string
+	Body   string
+	Score  int
 }
 
 // Output is what the shaper returns to the Discord layer.
 type Output struct {
 	Title   string
 	Summary string
+	// SourceLanguage is the language the model detected in the source post.
+	// Only asked for when PromptOptions.Language is set.
+	SourceLanguage string
+	// OriginalTitle is the post's own title, set when the digest was
+	// written in a language other than the post's.
+	OriginalTitle string
 }
 
 // Shaper turns Reddit matches into Discord-ready narratives via an
@@ -51,12 +92,47 @@ type Output struct {
 type Shaper struct {
 	client ChatCompleter
 	cfg    Config
+	tok    Tokenizer
+	// structured counts repair round-trips and validation failures per
+	// prompt kind; see StructuredStats.
+	structured map[PromptKind]*structuredCounters
+	// images downloads post images for Config.Vision == VisionInline. The
+	// URL can be any image link a Reddit user posted, so it only reaches
+	// public addresses.
+	images *http.Client
+}
+
+// ShaperOption configures optional Shaper behaviour.
+type ShaperOption func(*Shaper)
+
+// WithTokenizer sizes prompts with t instead of the chars-per-token
+// heuristic. A nil t keeps the heuristic.
+func WithTokenizer(t Tokenizer) ShaperOption {
+	return func(s *Shaper) {
+		if t != nil {
+			s.tok = t
+		}
+	}
 }
 
 // NewShaper returns a Shaper backed by the supplied ChatCompleter. The Config
 // controls the target model and tone.
-func NewShaper(client ChatCompleter, cfg Config) *Shaper {
-	return &Shaper{client: client, cfg: cfg}
+func NewShaper(client ChatCompleter, cfg Config, opts ...ShaperOption) *Shaper {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	s := &Shaper{
+		client:     client,
+		cfg:        cfg,
+		tok:        heuristicTokenizer{},
+		structured: newStructuredCounters(),
+		images:     safehttp.NewClient(timeout, nil),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // ShapeFresh produces a narrative for the first match of a rolling digest.
@@ -64,8 +140,13 @@ func (s *Shaper) ShapeFresh(ctx context.Context, in FreshInput) (Output, error)
 	if in.Post == nil {
 		return Output{}, errors.New("llm.ShapeFresh: Post is nil")
 	}
-	prompt := promptFresh(in, s.cfg.Tone, SummaryCharBudget)
-	return s.complete(ctx, prompt)
+	out, err := s.shapeNarrative(ctx, PromptFresh, in.Prompt.Language, s.imagePart(ctx, in.Image), func(withImage bool) (string, error) {
+		if !withImage {
+			in.Image = ""
+		}
+		return promptFresh(in, s.tone(in.Prompt), SummaryCharBudget, s.tok)
+	}, in.Progress)
+	return withOriginalTitle(out, in.Prompt.Language, in.Post), err
 }
 
 // ShapeUpdate rewrites a rolling digest to absorb one additional match.
@@ -73,55 +154,110 @@ func (s *Shaper) ShapeUpdate(ctx context.Context, in UpdateInput) (Output, error
 	if in.NewPost == nil {
 		return Output{}, errors.New("llm.ShapeUpdate: NewPost is nil")
 	}
-	prompt := promptUpdate(in, s.cfg.Tone, SummaryCharBudget)
-	return s.complete(ctx, prompt)
+	out, err := s.shapeNarrative(ctx, PromptUpdate, in.Prompt.Language, s.imagePart(ctx, in.NewImage), func(withImage bool) (string, error) {
+		if !withImage {
+			in.NewImage = ""
+		}
+		return promptUpdate(in, s.tone(in.Prompt), SummaryCharBudget, s.tok)
+	}, in.Progress)
+	return withOriginalTitle(out, in.Prompt.Language, in.NewPost), err
 }
 
-func (s *Shaper) complete(ctx context.Context, userPrompt string) (Output, error) {
-	req := openai.ChatCompletionRequest{
-		Model:       s.cfg.Model,
-		Temperature: 0.2,
-		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
-			{Role: openai.ChatMessageRoleUser, Content: userPrompt},
-		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		},
+// withOriginalTitle keeps post's own title alongside a digest written in a
+// different language than the post, so readers can still search for it.
+func withOriginalTitle(out Output, lang string, post *redditJSON.RedditPost) Output {
+	if lang != "" && out.SourceLanguage != "" && !sameLanguage(out.SourceLanguage, lang) {
+		out.OriginalTitle = quoteSingleLine(post.Title)
 	}
+	return out
+}
 
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+// shapeNarrative renders the prompt and completes it, with image attached
+// when there is one. A backend that rejects the image gets the text-only
+// prompt instead, so a non-vision model degrades to the title-and-selftext
+// narrative.
+func (s *Shaper) shapeNarrative(ctx context.Context, kind PromptKind, lang string, image *openai.ChatMessagePart,
+	render func(withImage bool) (string, error), progress func(Output)) (Output, error) {
+	if image != nil {
+		prompt, err := render(true)
+		if err != nil {
+			return Output{}, err
+		}
+		out, err := s.complete(ctx, kind, lang, prompt, image, progress)
+		if err == nil || !visionRejected(err) {
+			return out, err
+		}
+	}
+	prompt, err := render(false)
 	if err != nil {
-		return Output{}, fmt.Errorf("llm chat completion: %w", err)
+		return Output{}, err
+	}
+	return s.complete(ctx, kind, lang, prompt, nil, progress)
+}
+
+// tone picks the per-call tone preset over the global LLM_TONE.
+func (s *Shaper) tone(p PromptOptions) string {
+	if p.Tone != "" {
+		return p.Tone
+	}
+	return s.cfg.Tone
+}
+
+// complete sends one narrative prompt. A target language adds its directive
+// to the system prompt and source_language to the expected schema.
+func (s *Shaper) complete(ctx context.Context, kind PromptKind, lang, userPrompt string, image *openai.ChatMessagePart, progress func(Output)) (Output, error) {
+	schema := narrativeSchema
+	if lang != "" {
+		schema = translatedNarrativeSchema
+	}
+	user := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: userPrompt}
+	if image != nil {
+		user = openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: userPrompt},
+			*image,
+		}}
 	}
-	if len(resp.Choices) == 0 {
-		return Output{}, errors.New("llm returned no choices")
+	req := openai.ChatCompletionRequest{
+		Model:       s.cfg.ModelFor(TaskNarrative),
+		Temperature: 0.2,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: narrativeSystemPrompt(lang)},
+			user,
+		},
+		ResponseFormat: s.responseFormat("digest_narrative", schema),
 	}
 
-	return parseOutput(resp.Choices[0].Message.Content)
+	return completeStructured(ctx, s, kind, req, narrativeProgress(progress), func(raw string) (Output, error) {
+		return parseOutput(raw, schema)
+	})
 }
 
-// parseOutput extracts {title, summary} from the model's JSON response,
-// clips the summary to SummaryCharBudget runes, and normalizes whitespace in
-// the title.
-func parseOutput(raw string) (Output, error) {
+// parseOutput validates the model's JSON response against schema,
+// extracts {title, summary, source_language}, clips the summary to
+// SummaryCharBudget runes, and normalizes whitespace in the title. Errors
+// name the offending field so completeStructured can hand them back to the
+// model.
+func parseOutput(raw string, schema *jsonschema.Definition) (Output, error) {
 	// Defensive cleanup: ```json fences, <think>…</think> blocks from Qwen3.
 	raw = stripJSONFences(raw)
 
-	var payload struct {
-		Title   string `json:"title"`
-		Summary string `json:"summary"`
-	}
-	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+	if err := decodeAndValidate(raw, schema); err != nil {
 		return Output{}, fmt.Errorf("parse llm json: %w", err)
 	}
-	if payload.Title == "" || payload.Summary == "" {
-		return Output{}, errors.New("llm output missing title or summary")
+	var payload struct {
+		Title          string `json:"title"`
+		Summary        string `json:"summary"`
+		SourceLanguage string `json:"source_language"`
+	}
+	_ = json.Unmarshal([]byte(raw), &payload)
+	if strings.TrimSpace(payload.Title) == "" || strings.TrimSpace(payload.Summary) == "" {
+		return Output{}, errors.New(`llm output has an empty "title" or "summary"`)
 	}
 
 	return Output{
-		Title:   clipRunes(quoteSingleLine(payload.Title), 120),
-		Summary: clipRunes(payload.Summary, SummaryCharBudget),
+		Title:          clipRunes(quoteSingleLine(payload.Title), 120),
+		Summary:        clipRunes(payload.Summary, SummaryCharBudget),
+		SourceLanguage: strings.TrimSpace(payload.SourceLanguage),
 	}, nil
 }
 
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// Sentiments are the labels Classify assigns, in display order.
+var Sentiments = []string{"positive", "neutral", "negative", "mixed"}
+
+// DefaultTopics is the topic taxonomy when CLASSIFY_TOPICS isn't set. It
+// leans towards what game and music communities post about.
+var DefaultTopics = []string{
+	"announcement", "release", "bug", "crash", "performance", "feedback",
+	"question", "help", "discussion", "meme", "art", "news", "event",
+}
+
+// classifyTokenBudget caps the post body a classification sees; the title
+// and opening paragraphs carry the signal.
+const classifyTokenBudget = 600
+
+const systemPromptClassify = `You label Reddit posts for a moderation and
+alerting bot. You return JSON only, never prose. Judge the post itself, not
+the subreddit. /no_think`
+
+const classifyTemplate = `Classify the Reddit post below.
+
+Return a JSON object with exactly three keys:
+  "sentiment": one of %s — the author's overall attitude
+  "topics":    the topics from this list that apply, possibly none: %s
+  "toxic":     true if the post is abusive, hateful or harassing, else false
+
+Post:
+  subreddit: r/%s
+  title:     %s
+  body:
+%s
+
+Return ONLY the JSON object, nothing else.`
+
+// Classification is what Classify returns for one post.
+type Classification struct {
+	Sentiment string
+	Topics    []string
+	Toxic     bool
+}
+
+// classifySchema is the expected reply shape for a taxonomy.
+func classifySchema(topics []string) *jsonschema.Definition {
+	return &jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"sentiment": {Type: jsonschema.String, Enum: Sentiments},
+			"topics": {
+				Type:  jsonschema.Array,
+				Items: &jsonschema.Definition{Type: jsonschema.String, Enum: topics},
+			},
+			"toxic": {Type: jsonschema.Boolean},
+		},
+		Required:             []string{"sentiment", "topics", "toxic"},
+		AdditionalProperties: false,
+	}
+}
+
+// Classify tags post with a sentiment, the topics from topics that apply,
+// and a toxicity flag. An empty topics list uses DefaultTopics. Labels come
+// back lowercased.
+func (s *Shaper) Classify(ctx context.Context, post *redditJSON.RedditPost, topics []string) (Classification, error) {
+	if post == nil {
+		return Classification{}, errors.New("llm.Classify: post is nil")
+	}
+	if len(topics) == 0 {
+		topics = DefaultTopics
+	}
+	body := clipForPrompt(s.tok, strings.TrimSpace(post.Selftext), classifyTokenBudget)
+	if body == "" {
+		body = "(no text)"
+	}
+	prompt := fmt.Sprintf(classifyTemplate,
+		strings.Join(Sentiments, ", "), strings.Join(topics, ", "),
+		post.Subreddit, quoteSingleLine(post.Title), body)
+
+	schema := classifySchema(topics)
+	req := openai.ChatCompletionRequest{
+		Model:              s.cfg.ModelFor(TaskClassify),
+		Temperature:        0.1,
+		ChatTemplateKwargs: map[string]any{"enable_thinking": false},
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPromptClassify},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
+		},
+		ResponseFormat: s.responseFormat("post_classification", schema),
+	}
+	return completeStructured(ctx, s, PromptClassify, req, nil, func(raw string) (Classification, error) {
+		return parseClassification(raw, schema)
+	})
+}
+
+func parseClassification(raw string, schema *jsonschema.Definition) (Classification, error) {
+	raw = stripJSONFences(raw)
+	if err := decodeAndValidate(raw, schema); err != nil {
+		return Classification{}, fmt.Errorf("parse classification json: %w", err)
+	}
+	var payload struct {
+		Sentiment string   `json:"sentiment"`
+		Topics    []string `json:"topics"`
+		Toxic     bool     `json:"toxic"`
+	}
+	_ = json.Unmarshal([]byte(raw), &payload)
+	out := Classification{Sentiment: strings.ToLower(strings.TrimSpace(payload.Sentiment)), Toxic: payload.Toxic}
+	for _, t := range payload.Topics {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			out.Topics = append(out.Topics, t)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func TestClassify(t *testing.T) {
+	f := &fakeCompleter{response: "```json\n{\"sentiment\":\"Negative\",\"topics\":[\"Bug\",\"crash\"],\"toxic\":false}\n```"}
+	s := NewShaper(f, Config{Model: "m", ClassifyModel: "small"})
+	post := &redditJSON.RedditPost{Subreddit: "golang", Title: "it crashes", Selftext: "on startup"}
+
+	got, err := s.Classify(context.Background(), post, []string{"bug", "crash", "release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Classification{Sentiment: "negative", Topics: []string{"bug", "crash"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if f.req.Model != "small" {
+		t.Errorf("model = %q, want the classify model", f.req.Model)
+	}
+	if user := f.req.Messages[1].Content; !strings.Contains(user, "bug, crash, release") || !strings.Contains(user, "on startup") {
+		t.Errorf("prompt should list the taxonomy and the body:\n%s", user)
+	}
+}
+
+func TestClassify_RepairsOffTaxonomyTopic(t *testing.T) {
+	m := &multiCompleter{responses: []string{
+		`{"sentiment":"neutral","topics":["gossip"],"toxic":false}`,
+		`{"sentiment":"neutral","topics":[],"toxic":false}`,
+	}}
+	s := NewShaper(m, Config{Model: "m", RepairAttempts: 1})
+	got, err := s.Classify(context.Background(), &redditJSON.RedditPost{Title: "hi"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.calls != 2 || got.Sentiment != "neutral" || len(got.Topics) != 0 {
+		t.Errorf("calls = %d, got %+v", m.calls, got)
+	}
+	if st := s.StructuredStats()[PromptClassify]; st.Repaired != 1 {
+		t.Errorf("classify stats = %+v, want one repaired call", st)
+	}
+}
@@ -27,8 +27,9 @@ const (
 	// entries, raise LLM_TIMEOUT alongside this value.
 	musicMaxTokensCeiling = 6000
 
-	// charsPerToken is a conservative chars-per-token estimate for body sizing.
-	// Latin text typically runs 3–4 chars/token; 3 keeps estimates on the safe side.
+	// charsPerToken is the fallback tokenizer's chars-per-token estimate when
+	// LLM_TOKENIZER_PATH isn't set. Latin text typically runs 3–4 chars/token;
+	// 3 keeps estimates on the safe side.
 	charsPerToken = 3
 	// contextHeadroom reserves tokens for per-call model accounting overhead.
 	contextHeadroom = 200
@@ -69,6 +70,12 @@ type MusicInput struct {
 	RuleID       int
 	RuleTargetID string
 	RuleExact    bool
+	Prompt       PromptOptions
+
+	// Progress, when set, receives every entry extracted so far (across
+	// chunks) as the completion streams in. Only invoked when streaming is
+	// enabled; the entries ShapeMusic returns remain authoritative.
+	Progress func([]MusicEntry)
 }
 
 // ShapeMusic asks the LLM to pull `{artist, title, kind}` entries out of the
@@ -96,14 +103,19 @@ func (s *Shaper) ShapeMusic(ctx context.Context, in MusicInput) ([]MusicEntry, e
 			RuleID:       in.RuleID,
 			RuleTargetID: in.RuleTargetID,
 			RuleExact:    in.RuleExact,
+			Prompt:       in.Prompt,
+			Progress:     in.Progress,
+		}
+		chunk, rest, err := s.takeBodyChunk(chunkIn, remaining)
+		if err != nil {
+			return nil, err
 		}
-		chunk, rest := s.takeBodyChunk(chunkIn, remaining)
 
 		p := *in.Post
 		p.Selftext = chunk
 		chunkIn.Post = &p
 
-		entries, err := s.shapeMusicOnce(ctx, chunkIn)
+		entries, err := s.shapeMusicOnce(ctx, chunkIn, accumulated)
 		if err != nil {
 			return nil, err
 		}
@@ -123,70 +135,93 @@ func (s *Shaper) contextLimit() int {
 	return s.cfg.ContextLimit
 }
 
+// countTokens sums the token counts of separately-encoded prompt parts
+// (system and user messages are tokenized on their own).
+func (s *Shaper) countTokens(parts ...string) int {
+	n := 0
+	for _, p := range parts {
+		n += s.tok.Count(p)
+	}
+	return n
+}
+
 // takeBodyChunk returns the largest prefix of body (split at a line boundary)
 // that fits within the model's context window given the current skip list, and
 // the remainder as rest. When the body already fits, rest is empty.
-func (s *Shaper) takeBodyChunk(in MusicInput, body string) (chunk, rest string) {
-	emptyPrompt := promptMusicExtract(in, "")
-	fixedTokens := (len(systemPromptMusic) + len(emptyPrompt)) / charsPerToken
+func (s *Shaper) takeBodyChunk(in MusicInput, body string) (chunk, rest string, err error) {
+	emptyPrompt, err := promptMusicExtract(in, s.tone(in.Prompt), "")
+	if err != nil {
+		return "", "", err
+	}
+	fixedTokens := s.countTokens(musicSystemPrompt(in.Prompt.Language), emptyPrompt)
 	available := s.contextLimit() - contextHeadroom - fixedTokens
 	if available <= 0 {
 		// Skip list alone fills the context; pass the full body and let the
 		// per-call max_tokens clamp handle the output budget.
-		return body, ""
+		return body, "", nil
 	}
-	bodyBudget := available * charsPerToken
-	if len(body) <= bodyBudget {
-		return body, ""
+	if s.tok.Count(body) <= available {
+		return body, "", nil
 	}
 
+	// Lines are counted one at a time; tokens rarely span a newline, so the
+	// sum tracks the whole-chunk count closely without re-encoding it.
 	lines := strings.Split(body, "\n")
 	var cur strings.Builder
+	used := 0
 	for i, line := range lines {
 		lineWithNL := line + "\n"
-		if cur.Len()+len(lineWithNL) > bodyBudget && cur.Len() > 0 {
-			return strings.TrimRight(cur.String(), "\n"), strings.Join(lines[i:], "\n")
+		n := s.tok.Count(lineWithNL)
+		if used+n > available && cur.Len() > 0 {
+			return strings.TrimRight(cur.String(), "\n"), strings.Join(lines[i:], "\n"), nil
 		}
 		cur.WriteString(lineWithNL)
+		used += n
 	}
-	return strings.TrimRight(cur.String(), "\n"), ""
+	return strings.TrimRight(cur.String(), "\n"), "", nil
 }
 
 // shapeMusicOnce issues a single LLM call for the body in in.Post.Selftext.
 // It clamps max_tokens so the request fits within the model's context window.
-func (s *Shaper) shapeMusicOnce(ctx context.Context, in MusicInput) ([]MusicEntry, error) {
-	prompt := promptMusicExtract(in, in.Post.Selftext)
+// prior is what earlier chunks of the same post produced; it's only used to
+// give streaming progress callbacks the full picture.
+func (s *Shaper) shapeMusicOnce(ctx context.Context, in MusicInput, prior []MusicEntry) ([]MusicEntry, error) {
+	prompt, err := promptMusicExtract(in, s.tone(in.Prompt), in.Post.Selftext)
+	if err != nil {
+		return nil, err
+	}
 
 	predicted := predictMusicMaxTokens(in.Post.Selftext)
-	inputTokens := (len(systemPromptMusic) + len(prompt)) / charsPerToken
+	inputTokens := s.countTokens(musicSystemPrompt(in.Prompt.Language), prompt)
 	available := max(s.contextLimit()-inputTokens-contextHeadroom, musicMaxTokensFloor)
 	predicted = min(predicted, available)
 
 	req := openai.ChatCompletionRequest{
-		Model:              s.cfg.Model,
+		Model:              s.cfg.ModelFor(TaskMusic),
 		Temperature:        0.1,
 		MaxTokens:          predicted,
 		ChatTemplateKwargs: map[string]any{"enable_thinking": false},
 		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleSystem, Content: systemPromptMusic},
+			{Role: openai.ChatMessageRoleSystem, Content: musicSystemPrompt(in.Prompt.Language)},
 			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		},
+		ResponseFormat: s.responseFormat("music_entries", musicSchema),
 	}
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	entries, err := completeStructured(ctx, s, PromptMusic, req, musicProgress(in, prior), parseMusicOutput)
 	if err != nil {
-		return nil, fmt.Errorf("llm chat completion: %w", err)
+		return nil, err
 	}
-	if len(resp.Choices) == 0 {
-		return nil, errors.New("llm returned no choices")
-	}
-	raw := stripJSONFences(resp.Choices[0].Message.Content)
+	return normalizeMusicEntries(in, entries), nil
+}
+
+// parseMusicOutput extracts entries from the model's reply. The model must
+// return a JSON object: `{"entries": [...]}`. A few models return the bare
+// array, so accept both. When the response is truncated (token cap
+// mid-stream), attempt partial recovery from complete entries — a repair
+// round-trip would only hit the same cap.
+func parseMusicOutput(content string) ([]MusicEntry, error) {
+	raw := stripJSONFences(content)
 
-	// The model must return a JSON object: `{"entries": [...]}`. A few models
-	// return the bare array, so accept both. When the response is truncated
-	// (token cap mid-stream), attempt partial recovery from complete entries.
 	var obj struct {
 		Entries []MusicEntry `json:"entries"`
 	}
@@ -194,22 +229,30 @@ func (s *Shaper) shapeMusicOnce(ctx context.Context, in MusicInput) ([]MusicEntr
 		var arr []MusicEntry
 		if err2 := json.Unmarshal([]byte(raw), &arr); err2 != nil {
 			if entries, ok := recoverTruncatedEntries(raw); ok {
-				obj.Entries = entries
-			} else {
-				return nil, fmt.Errorf("parse llm music json: %w (raw=%.200s)", err, raw)
+				return entries, nil
 			}
-		} else {
-			obj.Entries = arr
+			return nil, fmt.Errorf("parse llm music json: %w (raw=%.200s)", err, raw)
 		}
+		raw = `{"entries":` + raw + `}`
+		obj.Entries = arr
+	}
+	if err := decodeAndValidate(raw, musicOutputSchema); err != nil {
+		return nil, fmt.Errorf("parse llm music json: %w", err)
 	}
+	return obj.Entries, nil
+}
 
+// normalizeMusicEntries trims and validates raw model entries, coerces kind
+// into the known set, stamps SourcePostID and drops anything whose dedupe key
+// is already in in.KnownEntries (or earlier in the same batch).
+func normalizeMusicEntries(in MusicInput, entries []MusicEntry) []MusicEntry {
 	known := make(map[string]struct{}, len(in.KnownEntries))
 	for _, e := range in.KnownEntries {
 		known[MusicDedupeKey(e)] = struct{}{}
 	}
 
-	out := make([]MusicEntry, 0, len(obj.Entries))
-	for _, e := range obj.Entries {
+	out := make([]MusicEntry, 0, len(entries))
+	for _, e := range entries {
 		e.Artist = strings.TrimSpace(e.Artist)
 		e.Title = strings.TrimSpace(e.Title)
 		e.Kind = strings.ToLower(strings.TrimSpace(e.Kind))
@@ -230,7 +273,7 @@ func (s *Shaper) shapeMusicOnce(ctx context.Context, in MusicInput) ([]MusicEntr
 		e.SourcePostID = in.Post.ID
 		out = append(out, e)
 	}
-	return out, nil
+	return out
 }
 
 // MusicDedupeKey returns the normalized identity of a music entry for dedupe
@@ -286,8 +329,9 @@ func predictMusicMaxTokens(body string) int {
 }
 
 // recoverTruncatedEntries salvages complete MusicEntry objects from a JSON
-// response that was cut off before the closing delimiter. Returns (entries,
-// true) when at least one entry was recovered; (nil, false) otherwise.
+// response that was cut off before the closing delimiter. The salvaged
+// entries are validated like a complete reply. Returns (entries, true) when
+// at least one entry was recovered; (nil, false) otherwise.
 func recoverTruncatedEntries(raw string) ([]MusicEntry, bool) {
 	lastBrace := strings.LastIndex(raw, "}")
 	if lastBrace < 0 {
@@ -299,11 +343,17 @@ func recoverTruncatedEntries(raw string) ([]MusicEntry, bool) {
 		Entries []MusicEntry `json:"entries"`
 	}
 	if err := json.Unmarshal([]byte(base+"]}"), &obj); err == nil && len(obj.Entries) > 0 {
+		if decodeAndValidate(base+"]}", musicOutputSchema) != nil {
+			return nil, false
+		}
 		return obj.Entries, true
 	}
 
 	var arr []MusicEntry
 	if err := json.Unmarshal([]byte(base+"]"), &arr); err == nil && len(arr) > 0 {
+		if decodeAndValidate(`{"entries":`+base+"]}", musicOutputSchema) != nil {
+			return nil, false
+		}
 		return arr, true
 	}
 
@@ -31,6 +31,17 @@ func (m *multiCompleter) CreateChatCompletion(_ context.Context, req openai.Chat
 	}, nil
 }
 
+// emptyMusicPrompt renders the built-in music prompt with no body, which is
+// the fixed part takeBodyChunk budgets around.
+func emptyMusicPrompt(t *testing.T, in MusicInput) string {
+	t.Helper()
+	p, err := promptMusicExtract(in, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
 func TestShapeMusic_SingleChunk(t *testing.T) {
 	f := &fakeCompleter{response: `{"entries":[{"artist":"Irken Armada","title":"Nail In The Coffin","kind":"single"}]}`}
 	s := NewShaper(f, Config{Model: "m"})
@@ -94,8 +105,8 @@ func TestShapeMusic_MultiChunkAccumulates(t *testing.T) {
 	// Derive the fixed overhead so we can set ContextLimit just high enough to
 	// fit one line per chunk, forcing the body to split across two calls.
 	baseIn := MusicInput{Post: &redditJSON.RedditPost{}}
-	emptyPrompt := promptMusicExtract(baseIn, "")
-	fixedTokens := (len(systemPromptMusic) + len(emptyPrompt)) / charsPerToken
+	emptyPrompt := emptyMusicPrompt(t, baseIn)
+	fixedTokens := heuristicTokenizer{}.Count(systemPromptMusic) + heuristicTokenizer{}.Count(emptyPrompt)
 
 	// bodyBudget = available * charsPerToken where available = limit - headroom - fixedTokens.
 	// Each line is ~25 chars; to fit exactly one line per call: bodyBudget = 25.
@@ -128,8 +139,8 @@ func TestShapeMusic_CrossChunkDedup(t *testing.T) {
 	// Chunk 2 model response echoes an entry already returned in chunk 1 —
 	// it must not appear twice in the final output.
 	baseIn := MusicInput{Post: &redditJSON.RedditPost{}}
-	emptyPrompt := promptMusicExtract(baseIn, "")
-	fixedTokens := (len(systemPromptMusic) + len(emptyPrompt)) / charsPerToken
+	emptyPrompt := emptyMusicPrompt(t, baseIn)
+	fixedTokens := heuristicTokenizer{}.Count(systemPromptMusic) + heuristicTokenizer{}.Count(emptyPrompt)
 	limit := fixedTokens + contextHeadroom + (25 / charsPerToken) + 1
 
 	mc := &multiCompleter{responses: []string{
@@ -155,7 +166,7 @@ func TestTakeBodyChunk_FitsInSingle(t *testing.T) {
 	s := NewShaper(&fakeCompleter{}, Config{Model: "m"})
 	in := MusicInput{Post: &redditJSON.RedditPost{}}
 	body := "Artist A - Song X\nArtist B - Song Y"
-	chunk, rest := s.takeBodyChunk(in, body)
+	chunk, rest, _ := s.takeBodyChunk(in, body)
 	if rest != "" {
 		t.Errorf("expected no rest for small body, got %q", rest)
 	}
@@ -169,8 +180,8 @@ func TestTakeBodyChunk_SplitsAtLineBoundary(t *testing.T) {
 	in := MusicInput{Post: &redditJSON.RedditPost{}}
 
 	// Compute the exact body budget for this input so we can straddle the boundary.
-	emptyPrompt := promptMusicExtract(in, "")
-	fixedTokens := (len(systemPromptMusic) + len(emptyPrompt)) / charsPerToken
+	emptyPrompt := emptyMusicPrompt(t, in)
+	fixedTokens := heuristicTokenizer{}.Count(systemPromptMusic) + heuristicTokenizer{}.Count(emptyPrompt)
 	available := DefaultContextLimit - contextHeadroom - fixedTokens
 	bodyBudget := available * charsPerToken
 
@@ -179,7 +190,7 @@ func TestTakeBodyChunk_SplitsAtLineBoundary(t *testing.T) {
 	line2 := "overflow_line"
 	body := line1 + "\n" + line2
 
-	chunk, rest := s.takeBodyChunk(in, body)
+	chunk, rest, _ := s.takeBodyChunk(in, body)
 	if chunk != line1 {
 		t.Errorf("chunk len = %d, want %d", len(chunk), len(line1))
 	}
@@ -190,7 +201,7 @@ func TestTakeBodyChunk_SplitsAtLineBoundary(t *testing.T) {
 
 func TestTakeBodyChunk_EmptyBody(t *testing.T) {
 	s := NewShaper(&fakeCompleter{}, Config{Model: "m"})
-	chunk, rest := s.takeBodyChunk(MusicInput{Post: &redditJSON.RedditPost{}}, "")
+	chunk, rest, _ := s.takeBodyChunk(MusicInput{Post: &redditJSON.RedditPost{}}, "")
 	if chunk != "" || rest != "" {
 		t.Errorf("empty body should give empty chunk and rest; got %q / %q", chunk, rest)
 	}
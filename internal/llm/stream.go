@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ChatStreamer is the SSE half of the OpenAI SDK surface. *openai.Client
+// satisfies it natively; a ChatCompleter that doesn't implement it simply
+// never streams.
+type ChatStreamer interface {
+	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error)
+}
+
+// chat issues one chat completion and returns the assistant content. When
+// streaming is enabled (Config.Stream), the client can stream, and the
+// caller asked for progress (onDelta != nil), the completion is read as an
+// SSE stream and onDelta sees the accumulated content after every chunk.
+// Either way the return value is the complete content, so parsing and
+// persistence only ever act on the finished response.
+func (s *Shaper) chat(ctx context.Context, req openai.ChatCompletionRequest, onDelta func(accumulated string)) (string, error) {
+	if onDelta != nil && s.cfg.Stream {
+		client, cache := s.client, (*CachedCompleter)(nil)
+		if cc, ok := client.(*CachedCompleter); ok {
+			client, cache = cc.inner, cc
+		}
+		if streamer, ok := client.(ChatStreamer); ok {
+			if cache != nil {
+				if content, hit := cache.lookup(ctx, req); hit {
+					onDelta(content)
+					return content, nil
+				}
+			}
+			content, finish, err := chatStream(ctx, streamer, req, onDelta)
+			if err == nil && cache != nil && finish != openai.FinishReasonLength {
+				cache.remember(ctx, req, content)
+			}
+			return content, err
+		}
+	}
+	resp, err := s.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("llm chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("llm returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func chatStream(ctx context.Context, streamer ChatStreamer, req openai.ChatCompletionRequest, onDelta func(string)) (string, openai.FinishReason, error) {
+	stream, err := streamer.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", "", fmt.Errorf("llm chat completion stream: %w", err)
+	}
+	defer stream.Close()
+
+	var (
+		acc    strings.Builder
+		finish openai.FinishReason
+	)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("llm chat completion stream: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if fr := chunk.Choices[0].FinishReason; fr != "" {
+			finish = fr
+		}
+		if chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		acc.WriteString(chunk.Choices[0].Delta.Content)
+		onDelta(acc.String())
+	}
+	if acc.Len() == 0 {
+		return "", finish, errors.New("llm stream returned no content")
+	}
+	return acc.String(), finish, nil
+}
+
+// narrativeProgress adapts a FreshInput/UpdateInput Progress callback to the
+// raw-delta hook chat() exposes. It re-parses the partial JSON on every delta
+// and forwards only when the summary has grown, so callers aren't woken for
+// whitespace or key tokens.
+func narrativeProgress(progress func(Output)) func(string) {
+	if progress == nil {
+		return nil
+	}
+	last := ""
+	return func(acc string) {
+		out, ok := partialOutput(acc)
+		if !ok || out.Summary == last {
+			return
+		}
+		last = out.Summary
+		progress(out)
+	}
+}
+
+// partialOutput extracts whatever of {title, summary} has streamed so far.
+// ok is false until at least part of the summary is available.
+func partialOutput(raw string) (Output, bool) {
+	raw = stripThinkBlock(raw)
+	if strings.HasPrefix(raw, "<think>") {
+		return Output{}, false
+	}
+	summary, ok := partialJSONString(raw, "summary")
+	if !ok || strings.TrimSpace(summary) == "" {
+		return Output{}, false
+	}
+	title, _ := partialJSONString(raw, "title")
+	return Output{
+		Title:   clipRunes(quoteSingleLine(title), 120),
+		Summary: clipRunes(summary, SummaryCharBudget),
+	}, true
+}
+
+// partialJSONString returns the (possibly still-open) string value of key in
+// a JSON object prefix. A trailing incomplete escape sequence is dropped
+// rather than decoded, so the result is always valid text.
+func partialJSONString(raw, key string) (string, bool) {
+	idx := strings.Index(raw, `"`+key+`"`)
+	if idx < 0 {
+		return "", false
+	}
+	rest := strings.TrimLeft(raw[idx+len(key)+2:], " \t\r\n")
+	if !strings.HasPrefix(rest, ":") {
+		return "", false
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\r\n")
+	if !strings.HasPrefix(rest, `"`) {
+		return "", false
+	}
+	rest = rest[1:]
+
+	end := len(rest)
+scan:
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			width := 2
+			if i+1 < len(rest) && rest[i+1] == 'u' {
+				width = 6
+			}
+			if i+width > len(rest) {
+				end = i
+				break scan
+			}
+			i += width - 1
+		case '"':
+			end = i
+			break scan
+		}
+	}
+
+	var out string
+	if err := json.Unmarshal([]byte(`"`+rest[:end]+`"`), &out); err != nil {
+		return "", false
+	}
+	return out, true
+}
+
+// musicProgress adapts a MusicInput Progress callback for one chunk call.
+// prior is the set of entries already extracted from earlier chunks; the
+// callback receives prior plus whatever complete entries this chunk has
+// produced so far, and only fires when that count grows.
+func musicProgress(in MusicInput, prior []MusicEntry) func(string) {
+	if in.Progress == nil {
+		return nil
+	}
+	lastCount := -1
+	return func(acc string) {
+		raw := stripJSONFences(acc)
+		var obj struct {
+			Entries []MusicEntry `json:"entries"`
+		}
+		var entries []MusicEntry
+		if err := json.Unmarshal([]byte(raw), &obj); err == nil {
+			entries = obj.Entries
+		} else if recovered, ok := recoverTruncatedEntries(raw); ok {
+			entries = recovered
+		} else {
+			return
+		}
+		fresh := normalizeMusicEntries(in, entries)
+		if len(fresh) == lastCount {
+			return
+		}
+		lastCount = len(fresh)
+		combined := make([]MusicEntry, 0, len(prior)+len(fresh))
+		combined = append(combined, prior...)
+		combined = append(combined, fresh...)
+		in.Progress(combined)
+	}
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+// sseServer replays content as an OpenAI-style chat.completion.chunk stream,
+// chunkSize bytes per event.
+func sseServer(t *testing.T, content string, chunkSize int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Errorf("expected a streaming request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, _ := w.(http.Flusher)
+		for i := 0; i < len(content); i += chunkSize {
+			end := min(i+chunkSize, len(content))
+			delta, _ := json.Marshal(content[i:end])
+			fmt.Fprintf(w, "data: {\"id\":\"c\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%s}}]}\n\n", delta)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func streamingShaper(t *testing.T, srv *httptest.Server) *Shaper {
+	t.Helper()
+	cfg := Config{BaseURL: srv.URL + "/v1", Model: "m", APIKey: "k", Stream: true}
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return NewShaper(client, cfg)
+}
+
+func TestShapeFresh_StreamsProgress(t *testing.T) {
+	srv := sseServer(t, `{"title":"Drop day","summary":"Three singles and an \"EP\" landed today."}`, 7)
+	defer srv.Close()
+
+	var partials []Output
+	out, err := streamingShaper(t, srv).ShapeFresh(context.Background(), FreshInput{
+		Post:     &redditJSON.RedditPost{ID: "p", Title: "t", Subreddit: "Metalcore"},
+		Progress: func(o Output) { partials = append(partials, o) },
+	})
+	if err != nil {
+		t.Fatalf("ShapeFresh: %v", err)
+	}
+	if out.Summary != `Three singles and an "EP" landed today.` {
+		t.Errorf("final summary = %q", out.Summary)
+	}
+	if len(partials) < 2 {
+		t.Fatalf("expected several progress callbacks, got %d", len(partials))
+	}
+	for i := 1; i < len(partials); i++ {
+		if !strings.HasPrefix(partials[i].Summary, partials[i-1].Summary) {
+			t.Errorf("partial %d %q does not extend %q", i, partials[i].Summary, partials[i-1].Summary)
+		}
+	}
+	if partials[0].Title != "Drop day" {
+		t.Errorf("partial title = %q", partials[0].Title)
+	}
+}
+
+func TestShapeMusic_StreamsEntries(t *testing.T) {
+	srv := sseServer(t, `{"entries":[{"artist":"A","title":"One","kind":"single"},{"artist":"B","title":"Two","kind":"ep"}]}`, 11)
+	defer srv.Close()
+
+	var counts []int
+	entries, err := streamingShaper(t, srv).ShapeMusic(context.Background(), MusicInput{
+		Post:         &redditJSON.RedditPost{ID: "p", Title: "t", Subreddit: "Metalcore", Selftext: "A - One\nB - Two"},
+		KnownEntries: []MusicEntry{{Artist: "Z", Title: "Old", Kind: "single"}},
+		Progress:     func(es []MusicEntry) { counts = append(counts, len(es)) },
+	})
+	if err != nil {
+		t.Fatalf("ShapeMusic: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+	if len(counts) != 2 || counts[0] != 1 || counts[1] != 2 {
+		t.Errorf("progress entry counts = %v, want [1 2]", counts)
+	}
+}
+
+func TestChat_NoProgressDoesNotStream(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Stream: true})
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{ID: "p"}}); err != nil {
+		t.Fatalf("ShapeFresh: %v", err)
+	}
+	if f.calls != 1 || f.req.Stream {
+		t.Errorf("expected one blocking call, got calls=%d stream=%v", f.calls, f.req.Stream)
+	}
+}
+
+func TestPartialJSONString(t *testing.T) {
+	cases := []struct {
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{`{"summary": "hello wor`, "hello wor", true},
+		{`{"summary":"done"}`, "done", true},
+		{`{"summary":"line\`, "line", true},
+		{`{"summary":"caf\u00`, "caf", true},
+		{`{"summary":"café ok`, "café ok", true},
+		{`{"title":"x"`, "", false},
+		{`{"summary":`, "", false},
+	}
+	for _, c := range cases {
+		got, ok := partialJSONString(c.raw, "summary")
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("partialJSONString(%q) = %q, %v; want %q, %v", c.raw, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+var _ ChatStreamer = (*openai.Client)(nil)
@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const (
+	// ResponseFormatJSONObject asks for any JSON object and leaves the shape
+	// to the prompt. Every OpenAI-compatible server supports it.
+	ResponseFormatJSONObject = "json_object"
+	// ResponseFormatJSONSchema sends the expected schema with the request so
+	// the server constrains decoding to it (vLLM guided decoding, OpenAI
+	// structured outputs).
+	ResponseFormatJSONSchema = "json_schema"
+
+	// DefaultRepairAttempts is how many times an invalid reply is sent back
+	// to the model with the validator error before the call fails.
+	DefaultRepairAttempts = 1
+
+	// repairEchoTokens caps how much of the invalid reply is echoed back in
+	// a repair round-trip; enough for the model to see its mistake without
+	// blowing the context window on a long music extraction.
+	repairEchoTokens = 500
+)
+
+// narrativeSchema is the {title, summary} shape ShapeFresh and ShapeUpdate
+// expect.
+var narrativeSchema = &jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"title":   {Type: jsonschema.String, Description: "Single-line digest title"},
+		"summary": {Type: jsonschema.String, Description: "Markdown digest body"},
+	},
+	Required:             []string{"title", "summary"},
+	AdditionalProperties: false,
+}
+
+// musicSchema is the {entries: [{artist, title, kind}]} shape ShapeMusic
+// asks the server for. Strict structured outputs need every property
+// required, so kind is required and pinned to the known values here.
+var musicSchema = &jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"entries": {
+			Type: jsonschema.Array,
+			Items: &jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"artist": {Type: jsonschema.String},
+					"title":  {Type: jsonschema.String},
+					"kind":   {Type: jsonschema.String, Enum: []string{"single", "album", "ep"}},
+				},
+				Required:             []string{"artist", "title", "kind"},
+				AdditionalProperties: false,
+			},
+		},
+	},
+	Required:             []string{"entries"},
+	AdditionalProperties: false,
+}
+
+// musicOutputSchema is what a music reply is validated against. It's looser
+// than musicSchema: a missing or unknown kind is coerced to "single" by
+// normalizeMusicEntries rather than costing a repair round-trip.
+var musicOutputSchema = &jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"entries": {
+			Type: jsonschema.Array,
+			Items: &jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"artist": {Type: jsonschema.String},
+					"title":  {Type: jsonschema.String},
+					"kind":   {Type: jsonschema.String},
+				},
+				Required: []string{"artist", "title"},
+			},
+		},
+	},
+	Required: []string{"entries"},
+}
+
+// responseFormat returns the response_format for a request expecting
+// schema. In json_object mode the schema is only enforced after the fact by
+// validateSchema.
+func (s *Shaper) responseFormat(name string, schema *jsonschema.Definition) *openai.ChatCompletionResponseFormat {
+	if s.cfg.ResponseFormat != ResponseFormatJSONSchema {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   name,
+			Schema: schema,
+			Strict: true,
+		},
+	}
+}
+
+// StructuredStats counts output validation outcomes for one prompt kind
+// since process start.
+type StructuredStats struct {
+	// Repairs is the number of repair round-trips sent.
+	Repairs int64
+	// Repaired is how many calls produced valid output after a repair.
+	Repaired int64
+	// Failures is how many calls gave up with invalid output.
+	Failures int64
+}
+
+type structuredCounters struct {
+	repairs, repaired, failures atomic.Int64
+}
+
+func newStructuredCounters() map[PromptKind]*structuredCounters {
+	m := make(map[PromptKind]*structuredCounters, len(StructuredKinds))
+	for _, k := range StructuredKinds {
+		m[k] = &structuredCounters{}
+	}
+	return m
+}
+
+// StructuredStats returns the repair and failure counters per prompt kind.
+func (s *Shaper) StructuredStats() map[PromptKind]StructuredStats {
+	out := make(map[PromptKind]StructuredStats, len(s.structured))
+	for k, c := range s.structured {
+		out[k] = StructuredStats{
+			Repairs:  c.repairs.Load(),
+			Repaired: c.repaired.Load(),
+			Failures: c.failures.Load(),
+		}
+	}
+	return out
+}
+
+// completeStructured runs req and parses the reply with parse. When parse
+// rejects it, the reply and the validator error go back to the model for up
+// to Config.RepairAttempts more round-trips before the last error is
+// returned. Transport errors are returned immediately: a repair can't fix
+// those, and the router has already failed over.
+func completeStructured[T any](ctx context.Context, s *Shaper, kind PromptKind, req openai.ChatCompletionRequest,
+	onDelta func(string), parse func(raw string) (T, error)) (T, error) {
+	counters := s.structured[kind]
+	var zero T
+	for attempt := 0; ; attempt++ {
+		content, err := s.chat(ctx, req, onDelta)
+		if err != nil {
+			return zero, err
+		}
+		out, perr := parse(content)
+		if perr == nil {
+			if attempt > 0 {
+				counters.repaired.Add(1)
+			}
+			return out, nil
+		}
+		if attempt >= s.cfg.RepairAttempts {
+			counters.failures.Add(1)
+			return zero, perr
+		}
+		counters.repairs.Add(1)
+		req.Messages = append(req.Messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: clipForPrompt(s.tok, content, repairEchoTokens)},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: repairPrompt(perr)},
+		)
+	}
+}
+
+func repairPrompt(err error) string {
+	return fmt.Sprintf("Your reply was rejected: %s. Reply again with only the corrected JSON object, no commentary.", err)
+}
+
+// decodeAndValidate unmarshals raw and checks it against schema, returning
+// an error that names the offending field so it can be fed back to the
+// model.
+func decodeAndValidate(raw string, schema *jsonschema.Definition) error {
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	return validateSchema(schema, v, "$")
+}
+
+// validateSchema is a small JSON-schema checker covering the subset the
+// shaper's schemas use: types, required properties, array items and string
+// enums. Extra properties are tolerated — they're harmless and only strict
+// servers reject them. Enum values compare case-insensitively since the
+// callers normalize case anyway.
+func validateSchema(def *jsonschema.Definition, v any, path string) error {
+	switch def.Type {
+	case jsonschema.Object:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object, got %s", path, jsonKind(v))
+		}
+		for _, name := range def.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s is missing required field %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(def.Properties))
+		for name := range def.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			val, ok := obj[name]
+			if !ok {
+				continue
+			}
+			prop := def.Properties[name]
+			if err := validateSchema(&prop, val, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case jsonschema.Array:
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array, got %s", path, jsonKind(v))
+		}
+		if def.Items != nil {
+			for i, item := range arr {
+				if err := validateSchema(def.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case jsonschema.String:
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string, got %s", path, jsonKind(v))
+		}
+		if len(def.Enum) > 0 {
+			for _, e := range def.Enum {
+				if strings.EqualFold(strings.TrimSpace(str), e) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s must be one of %s, got %q", path, strings.Join(def.Enum, ", "), str)
+		}
+	case jsonschema.Number, jsonschema.Integer:
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s must be a number, got %s", path, jsonKind(v))
+		}
+	case jsonschema.Boolean:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean, got %s", path, jsonKind(v))
+		}
+	}
+	return nil
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
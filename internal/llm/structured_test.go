@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func TestValidateSchema(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"entries":[{"artist":"A","title":"B","kind":"Album"}]}`, ""},
+		{"extra property tolerated", `{"entries":[],"note":"x"}`, ""},
+		{"missing entries", `{"items":[]}`, `missing required field "entries"`},
+		{"entries not array", `{"entries":{}}`, "$.entries must be an array, got an object"},
+		{"unknown kind left to normalize", `{"entries":[{"artist":"A","title":"B","kind":"mixtape"}]}`, ""},
+		{"kind optional", `{"entries":[{"artist":"A","title":"B"}]}`, ""},
+		{"missing title", `{"entries":[{"artist":"A","kind":"ep"}]}`, `$.entries[0] is missing required field "title"`},
+		{"artist wrong type", `{"entries":[{"artist":1,"title":"B","kind":"ep"}]}`, "$.entries[0].artist must be a string, got a number"},
+		{"not json", `{"entries":`, "not valid JSON"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := decodeAndValidate(tc.raw, musicOutputSchema)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestShapeMusic_CoercesUnknownKindWithoutRepair(t *testing.T) {
+	m := &multiCompleter{responses: []string{
+		`{"entries":[{"artist":"A","title":"B","kind":"mixtape"},{"artist":"C","title":"D"}]}`,
+	}}
+	s := NewShaper(m, Config{Model: "m", RepairAttempts: 1})
+
+	out, err := s.ShapeMusic(context.Background(), MusicInput{Post: &redditJSON.RedditPost{Selftext: "A - B\nC - D"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.calls != 1 {
+		t.Errorf("calls = %d, want 1 (no repair)", m.calls)
+	}
+	if len(out) != 2 || out[0].Kind != "single" || out[1].Kind != "single" {
+		t.Errorf("entries = %+v, want both coerced to single", out)
+	}
+}
+
+func TestRecoverTruncatedEntries_Validates(t *testing.T) {
+	if _, ok := recoverTruncatedEntries(`{"entries":[{"artist":"A","title":"B"},{"artist":"C","ti`); !ok {
+		t.Error("complete entries before the cut should be recovered")
+	}
+	if _, ok := recoverTruncatedEntries(`{"entries":[{"artist":"A"},{"artist":"C","ti`); ok {
+		t.Error("an entry missing its title should fail validation like a complete reply")
+	}
+}
+
+func TestShapeFresh_RepairsInvalidOutput(t *testing.T) {
+	m := &multiCompleter{responses: []string{
+		`{"headline":"x","summary":"s"}`,
+		`{"title":"Fixed","summary":"s"}`,
+	}}
+	s := NewShaper(m, Config{Model: "m", RepairAttempts: 1})
+
+	out, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Title != "Fixed" {
+		t.Errorf("title = %q, want the repaired reply", out.Title)
+	}
+	if m.calls != 2 {
+		t.Fatalf("calls = %d, want 2", m.calls)
+	}
+	msgs := m.reqs[1].Messages
+	if len(msgs) != 4 || msgs[2].Role != openai.ChatMessageRoleAssistant || msgs[2].Content != `{"headline":"x","summary":"s"}` {
+		t.Fatalf("repair request should echo the invalid reply; got %+v", msgs)
+	}
+	if !strings.Contains(msgs[3].Content, `missing required field "title"`) {
+		t.Errorf("repair prompt should carry the validator error; got %q", msgs[3].Content)
+	}
+
+	st := s.StructuredStats()[PromptFresh]
+	if st != (StructuredStats{Repairs: 1, Repaired: 1}) {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestShapeMusic_RepairBoundedThenFails(t *testing.T) {
+	m := &multiCompleter{responses: []string{`{}`, `{}`, `{}`}}
+	s := NewShaper(m, Config{Model: "m", RepairAttempts: 1})
+
+	_, err := s.ShapeMusic(context.Background(), MusicInput{Post: &redditJSON.RedditPost{Selftext: "A - B"}})
+	if err == nil || !strings.Contains(err.Error(), `"entries"`) {
+		t.Fatalf("err = %v, want a missing-entries validation error", err)
+	}
+	if m.calls != 2 {
+		t.Errorf("calls = %d, want 1 + 1 repair", m.calls)
+	}
+	if st := s.StructuredStats()[PromptMusic]; st != (StructuredStats{Repairs: 1, Failures: 1}) {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestResponseFormat_JSONSchema(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", ResponseFormat: ResponseFormatJSONSchema})
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}}); err != nil {
+		t.Fatal(err)
+	}
+	rf := f.req.ResponseFormat
+	if rf == nil || rf.Type != openai.ChatCompletionResponseFormatTypeJSONSchema || rf.JSONSchema == nil || rf.JSONSchema.Schema != narrativeSchema {
+		t.Fatalf("response_format = %+v, want the narrative json_schema", rf)
+	}
+
+	s = NewShaper(f, Config{Model: "m"})
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}}); err != nil {
+		t.Fatal(err)
+	}
+	if f.req.ResponseFormat.Type != openai.ChatCompletionResponseFormatTypeJSONObject {
+		t.Errorf("default response_format = %q, want json_object", f.req.ResponseFormat.Type)
+	}
+}
+
+func TestConfigFromEnv_ResponseFormat(t *testing.T) {
+	t.Setenv(EnvBaseURL, "http://x/v1")
+	t.Setenv(EnvModel, "m")
+	t.Setenv(EnvRespFormat, "xml")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("unknown response format should be rejected")
+	}
+	t.Setenv(EnvRespFormat, ResponseFormatJSONSchema)
+	t.Setenv(EnvRepairs, "0")
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ResponseFormat != ResponseFormatJSONSchema || cfg.RepairAttempts != 0 {
+		t.Errorf("cfg = %q/%d", cfg.ResponseFormat, cfg.RepairAttempts)
+	}
+}
@@ -0,0 +1,215 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// PromptKind names one of the user prompts a template can replace.
+type PromptKind string
+
+const (
+	PromptFresh  PromptKind = "fresh"
+	PromptUpdate PromptKind = "update"
+	PromptMusic  PromptKind = "music"
+	// PromptClassify is the post classification prompt. It has no
+	// user-editable template, so it isn't in PromptKinds.
+	PromptClassify PromptKind = "classify"
+)
+
+// PromptKinds lists every kind in display order.
+var PromptKinds = []PromptKind{PromptFresh, PromptUpdate, PromptMusic}
+
+// StructuredKinds lists every kind whose output is validated, in display
+// order.
+var StructuredKinds = []PromptKind{PromptFresh, PromptUpdate, PromptMusic, PromptClassify}
+
+// ParsePromptKind validates a kind name.
+func ParsePromptKind(s string) (PromptKind, error) {
+	for _, k := range PromptKinds {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown prompt kind %q (want fresh, update or music)", s)
+}
+
+// PromptOptions overrides the built-in prompt for one shaping call. The
+// zero value uses the built-in template and Config.Tone.
+type PromptOptions struct {
+	// Template is a text/template body for the call's kind, already checked
+	// with ValidateTemplate.
+	Template string
+	// Tone is a tone preset name.
+	Tone string
+	// Language is the English name of the language to write the digest in
+	// (see ParseLanguage); empty leaves it to the model.
+	Language string
+}
+
+// PromptData is what a prompt template executes against. Built-in and
+// operator templates see the same fields; Digest is only filled for update
+// prompts and SkipList/SkipCount only for music.
+type PromptData struct {
+	// Tone is the directive line for the selected tone preset.
+	Tone string
+	// CharBudget is the maximum summary length in characters.
+	CharBudget int
+	Post       PromptPost
+	Rule       PromptRule
+	Digest     PromptDigest
+	// Comments are the post's top comments and Article the linked page's
+	// text, both clipped; empty when unavailable. Narrative prompts only.
+	Comments []PromptComment
+	Article  string
+	// Image reports that the post's image is attached to the request.
+	// Narrative prompts only.
+	Image bool
+	// SkipList is the JSON array of entries the music digest already has.
+	SkipList  string
+	SkipCount int
+}
+
+// PromptPost is the matched post. Title is flattened to one line; Selftext
+// is clipped to the prompt's token budget (for music, the current chunk).
+type PromptPost struct {
+	Author    string
+	Subreddit string
+	Title     string
+	Selftext  string
+	URL       string
+}
+
+// PromptComment is one top comment. Body is flattened to one line and
+// clipped.
+type PromptComment struct {
+	Author string
+	Body   string
+	Score  int
+}
+
+// PromptRule is the rule that matched. MatchType is "exact" or "partial".
+type PromptRule struct {
+	ID        int
+	TargetID  string
+	Exact     bool
+	MatchType string
+}
+
+// PromptDigest is the running digest an update prompt rewrites.
+type PromptDigest struct {
+	Title     string
+	Summary   string
+	PostCount int
+}
+
+var builtinTemplates = map[PromptKind]*template.Template{
+	PromptFresh:  template.Must(parsePromptTemplate(PromptFresh, freshTemplate)),
+	PromptUpdate: template.Must(parsePromptTemplate(PromptUpdate, updateTemplate)),
+	PromptMusic:  template.Must(parsePromptTemplate(PromptMusic, musicTemplate)),
+}
+
+func parsePromptTemplate(kind PromptKind, body string) (*template.Template, error) {
+	return template.New(string(kind)).Option("missingkey=error").Parse(body)
+}
+
+// renderPrompt executes custom, or the built-in template for kind when
+// custom is empty.
+func renderPrompt(kind PromptKind, custom string, data PromptData) (string, error) {
+	tmpl := builtinTemplates[kind]
+	if custom != "" {
+		var err error
+		if tmpl, err = parsePromptTemplate(kind, custom); err != nil {
+			return "", fmt.Errorf("parse %s prompt template: %w", kind, err)
+		}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render %s prompt template: %w", kind, err)
+	}
+	return b.String(), nil
+}
+
+// templateRequires lists strings a rendered prompt must contain so the
+// model is still asked for the JSON shape the parser expects.
+var templateRequires = map[PromptKind][]string{
+	PromptFresh:  {`"title"`, `"summary"`},
+	PromptUpdate: {`"title"`, `"summary"`},
+	PromptMusic:  {`"entries"`},
+}
+
+// ValidateTemplate parses body and executes it against sample data for
+// kind, so unknown fields and syntax errors surface when the template is
+// saved rather than on the next match.
+func ValidateTemplate(kind PromptKind, body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("template is empty")
+	}
+	sample := PromptData{
+		Tone:       toneLine(""),
+		CharBudget: SummaryCharBudget,
+		Post: PromptPost{
+			Author:    "example_user",
+			Subreddit: "Metalcore",
+			Title:     "Weekly Release Thread",
+			Selftext:  "Artist - Song (Single)",
+			URL:       "https://www.reddit.com/r/Metalcore/comments/abc123/",
+		},
+		Rule:   PromptRule{ID: 1, TargetID: "title", MatchType: "partial"},
+		Digest: PromptDigest{Title: "Prior title", Summary: "Prior summary.", PostCount: 1},
+	}
+	if kind == PromptMusic {
+		sample.SkipList, sample.SkipCount = "[]", 0
+	} else {
+		sample.Comments = []PromptComment{{Author: "commenter", Body: "Great track.", Score: 12}}
+		sample.Article = "The band announced a new album today."
+		sample.Image = true
+	}
+	out, err := renderPrompt(kind, body, sample)
+	if err != nil {
+		return err
+	}
+	for _, want := range templateRequires[kind] {
+		if !strings.Contains(out, want) {
+			return fmt.Errorf("a %s template must ask for the %s key", kind, want)
+		}
+	}
+	return nil
+}
+
+// tonePresets maps a preset name to the directive line prompts open with.
+var tonePresets = map[string]string{
+	"neutral": "TONE: neutral and informative. No emoji.",
+	"snarky":  "TONE: dry and mildly snarky, but never mean-spirited. No emoji.",
+	"playful": "TONE: warm and playful. Sparing use of emoji is acceptable.",
+	"terse":   "TONE: terse and factual; short sentences, no filler. No emoji.",
+	"hype":    "TONE: upbeat and enthusiastic, like a friend sharing good news. Sparing use of emoji is acceptable.",
+}
+
+// ToneNames returns the tone preset names, sorted.
+func ToneNames() []string {
+	names := make([]string, 0, len(tonePresets))
+	for n := range tonePresets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsTonePreset reports whether name is a known tone preset.
+func IsTonePreset(name string) bool {
+	_, ok := tonePresets[name]
+	return ok
+}
+
+// toneLine returns the directive for tone; unknown or empty names are
+// neutral.
+func toneLine(tone string) string {
+	if line, ok := tonePresets[tone]; ok {
+		return line
+	}
+	return tonePresets["neutral"]
+}
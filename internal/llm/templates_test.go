@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	cases := []struct {
+		name    string
+		kind    PromptKind
+		body    string
+		wantErr string
+	}{
+		{"built-in fresh", PromptFresh, freshTemplate, ""},
+		{"built-in update", PromptUpdate, updateTemplate, ""},
+		{"built-in music", PromptMusic, musicTemplate, ""},
+		{"custom", PromptFresh, `{{.Tone}} Summarize r/{{.Post.Subreddit}} as JSON with "title" and "summary": {{.Post.Selftext}}`, ""},
+		{"empty", PromptFresh, "  ", "empty"},
+		{"syntax", PromptFresh, `{{.Post.Title`, "parse"},
+		{"unknown field", PromptFresh, `"title" "summary" {{.Post.Flair}}`, "Flair"},
+		{"missing keys", PromptUpdate, `Rewrite {{.Digest.Summary}}`, `"title"`},
+		{"music missing entries", PromptMusic, `List releases in {{.Post.Selftext}}`, `"entries"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTemplate(tc.kind, tc.body)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestShapeFresh_CustomTemplateAndTone(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Tone: "snarky"})
+
+	_, err := s.ShapeFresh(context.Background(), FreshInput{
+		Post:   &redditJSON.RedditPost{Subreddit: "Metalcore", Title: "two\nlines"},
+		RuleID: 7,
+		Prompt: PromptOptions{
+			Template: `{{.Tone}} | r/{{.Post.Subreddit}} | {{.Post.Title}} | #{{.Rule.ID}} | "title" "summary"`,
+			Tone:     "terse",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.req.Messages[1].Content
+	want := tonePresets["terse"] + ` | r/Metalcore | two lines | #7 | "title" "summary"`
+	if got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+}
+
+func TestShaper_ToneFallsBackToConfig(t *testing.T) {
+	f := &fakeCompleter{response: `{"title":"t","summary":"s"}`}
+	s := NewShaper(f, Config{Model: "m", Tone: "playful"})
+	if _, err := s.ShapeFresh(context.Background(), FreshInput{Post: &redditJSON.RedditPost{}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(f.req.Messages[1].Content, tonePresets["playful"]) {
+		t.Errorf("prompt should open with the LLM_TONE preset; got %q", f.req.Messages[1].Content[:60])
+	}
+}
+
+func TestPromptFresh_CommentsAndArticle(t *testing.T) {
+	long := strings.Repeat("word ", 400)
+	var comments []Comment
+	for i := 0; i < 20; i++ {
+		comments = append(comments, Comment{Author: "u", Body: long, Score: i})
+	}
+	got, err := promptFresh(FreshInput{
+		Post:     &redditJSON.RedditPost{Subreddit: "news"},
+		Comments: append([]Comment{{Author: "first", Body: "multi\n\nline  reply", Score: 3}}, comments...),
+		Article:  "  Article body.  ",
+	}, "", SummaryCharBudget, wordTokenizer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "Linked article (excerpt):\nArticle body.\n") {
+		t.Errorf("article missing or untrimmed:\n%s", got)
+	}
+	if !strings.Contains(got, "  - u/first (3 points): multi line reply\n") {
+		t.Errorf("first comment should be flattened onto one line:\n%s", got)
+	}
+	// Each long comment clips to 150 words; after the 3-word first one, five
+	// fit in the 800-word total.
+	if n := strings.Count(got, "  - u/u "); n != 5 {
+		t.Errorf("rendered %d long comments, want 5", n)
+	}
+
+	plain, err := promptFresh(FreshInput{Post: &redditJSON.RedditPost{}}, "", SummaryCharBudget, wordTokenizer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain, "Top comments") || strings.Contains(plain, "Linked article") {
+		t.Errorf("empty comments/article should render nothing:\n%s", plain)
+	}
+}
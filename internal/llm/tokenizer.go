@@ -0,0 +1,428 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts prompt tokens for context budgeting. Implementations only
+// need to be accurate enough to size a request — the server does the real
+// encoding.
+type Tokenizer interface {
+	// Count returns how many tokens s encodes to.
+	Count(s string) int
+	// Truncate returns the longest prefix of s that encodes to at most n
+	// tokens.
+	Truncate(s string, n int) string
+}
+
+// heuristicTokenizer is the fallback when no tokenizer.json is configured:
+// charsPerToken characters (runes) per token. Conservative for English and
+// low for CJK and emoji-heavy text, where a character is often a token or
+// more.
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) Count(s string) int { return utf8.RuneCountInString(s) / charsPerToken }
+
+func (heuristicTokenizer) Truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	limit := n * charsPerToken
+	for i := range s {
+		if limit == 0 {
+			return s[:i]
+		}
+		limit--
+	}
+	return s
+}
+
+// tokenizerFile is the subset of a HuggingFace tokenizer.json we read.
+type tokenizerFile struct {
+	PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+	Decoder      json.RawMessage `json:"decoder"`
+	Model        struct {
+		Type         string          `json:"type"`
+		Vocab        map[string]int  `json:"vocab"`
+		Merges       json.RawMessage `json:"merges"`
+		ByteFallback bool            `json:"byte_fallback"`
+		IgnoreMerges bool            `json:"ignore_merges"`
+	} `json:"model"`
+}
+
+// bpeTokenizer is a BPE encoder built from a tokenizer.json. It handles the
+// two layouts open-weight chat models ship: byte-level (GPT-2, Llama 3,
+// Qwen, Mistral Nemo) and SentencePiece-style "▁" metaspace with byte
+// fallback (Llama 2, Mistral 7B). Pre-tokenization follows the GPT-2 split
+// rules rather than each model's exact regex, and normalizers and added
+// tokens are ignored, so counts can drift by a few percent — well inside
+// contextHeadroom.
+type bpeTokenizer struct {
+	vocab        map[string]struct{}
+	ranks        map[[2]string]int
+	byteLevel    bool
+	byteFallback bool
+	ignoreMerges bool
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// bpeCacheMax bounds the per-word count cache; it's cleared when full.
+const bpeCacheMax = 50000
+
+// LoadTokenizer reads a HuggingFace tokenizer.json. Only BPE models are
+// supported; WordPiece and Unigram files return an error and the caller
+// should keep the heuristic.
+func LoadTokenizer(path string) (Tokenizer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tokenizer: %w", err)
+	}
+	var f tokenizerFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parse tokenizer %s: %w", path, err)
+	}
+	if f.Model.Type != "BPE" && !(f.Model.Type == "" && f.Model.Vocab != nil) {
+		return nil, fmt.Errorf("tokenizer %s: unsupported model type %q (only BPE)", path, f.Model.Type)
+	}
+	if len(f.Model.Vocab) == 0 {
+		return nil, fmt.Errorf("tokenizer %s: empty vocab", path)
+	}
+	merges, err := parseMerges(f.Model.Merges)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer %s: %w", path, err)
+	}
+
+	t := &bpeTokenizer{
+		vocab:        make(map[string]struct{}, len(f.Model.Vocab)),
+		ranks:        make(map[[2]string]int, len(merges)),
+		byteLevel:    mentionsType(f.PreTokenizer, "ByteLevel") || mentionsType(f.Decoder, "ByteLevel"),
+		byteFallback: f.Model.ByteFallback,
+		ignoreMerges: f.Model.IgnoreMerges,
+		cache:        map[string]int{},
+	}
+	for tok := range f.Model.Vocab {
+		t.vocab[tok] = struct{}{}
+	}
+	for i, m := range merges {
+		if _, dup := t.ranks[m]; !dup {
+			t.ranks[m] = i
+		}
+	}
+	return t, nil
+}
+
+// parseMerges accepts both merge encodings: the classic "a b" strings and
+// the [["a","b"], ...] pairs newer tokenizers versions write.
+func parseMerges(raw json.RawMessage) ([][2]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var asStrings []string
+	if err := json.Unmarshal(raw, &asStrings); err == nil {
+		out := make([][2]string, 0, len(asStrings))
+		for _, m := range asStrings {
+			a, b, ok := strings.Cut(m, " ")
+			if !ok {
+				return nil, fmt.Errorf("malformed merge %q", m)
+			}
+			out = append(out, [2]string{a, b})
+		}
+		return out, nil
+	}
+	var asPairs [][]string
+	if err := json.Unmarshal(raw, &asPairs); err != nil {
+		return nil, errors.New("merges are neither strings nor pairs")
+	}
+	out := make([][2]string, 0, len(asPairs))
+	for _, p := range asPairs {
+		if len(p) != 2 {
+			return nil, fmt.Errorf("malformed merge %q", p)
+		}
+		out = append(out, [2]string{p[0], p[1]})
+	}
+	return out, nil
+}
+
+// mentionsType reports whether a pre_tokenizer/decoder block, or any block
+// nested in a Sequence, has the given "type".
+func mentionsType(raw json.RawMessage, typ string) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	var block struct {
+		Type          string            `json:"type"`
+		PreTokenizers []json.RawMessage `json:"pretokenizers"`
+		Decoders      []json.RawMessage `json:"decoders"`
+	}
+	if json.Unmarshal(raw, &block) != nil {
+		return false
+	}
+	if block.Type == typ {
+		return true
+	}
+	for _, sub := range append(block.PreTokenizers, block.Decoders...) {
+		if mentionsType(sub, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *bpeTokenizer) Count(s string) int {
+	n := 0
+	for _, w := range t.words(s) {
+		n += t.countWord(w)
+	}
+	return n
+}
+
+func (t *bpeTokenizer) Truncate(s string, n int) string {
+	used, end := 0, 0
+	for _, w := range t.words(s) {
+		c := t.countWord(w)
+		if used+c > n {
+			// Pre-tokenization leaves an unspaced CJK run as one word, so
+			// stopping here could keep nothing of a long Japanese post.
+			// Fill the rest of the budget from the word a rune at a time.
+			if !t.byteLevel && end == 0 && w[0] != ' ' {
+				used += t.countRune(' ') // the "▁" encodeWord prepends
+			}
+			for _, r := range w {
+				rc := t.countRune(r)
+				if used+rc > n {
+					break
+				}
+				used += rc
+				end += utf8.RuneLen(r)
+			}
+			break
+		}
+		used += c
+		end += len(w)
+	}
+	return s[:end]
+}
+
+// countRune is what r costs encoded on its own. Merges only ever lower a
+// count, so a prefix priced rune by rune never runs over.
+func (t *bpeTokenizer) countRune(r rune) int {
+	sym := string(r)
+	if t.byteLevel {
+		sym = ""
+		for _, b := range []byte(string(r)) {
+			sym += string(byteToRune[b])
+		}
+	} else if r == ' ' {
+		sym = "▁"
+	}
+	if _, ok := t.vocab[sym]; ok {
+		return 1
+	}
+	if t.byteLevel || t.byteFallback {
+		return utf8.RuneLen(r)
+	}
+	return 1
+}
+
+// words pre-tokenizes s into pieces BPE runs on independently. The pieces
+// concatenate back to s, which is what lets Truncate cut on a boundary.
+func (t *bpeTokenizer) words(s string) []string {
+	if t.byteLevel {
+		return splitGPT2(s)
+	}
+	return splitMetaspace(s)
+}
+
+func (t *bpeTokenizer) countWord(w string) int {
+	t.mu.Lock()
+	if n, ok := t.cache[w]; ok {
+		t.mu.Unlock()
+		return n
+	}
+	t.mu.Unlock()
+
+	n := t.encodeWord(w)
+
+	t.mu.Lock()
+	if len(t.cache) >= bpeCacheMax {
+		t.cache = map[string]int{}
+	}
+	t.cache[w] = n
+	t.mu.Unlock()
+	return n
+}
+
+// encodeWord runs the BPE merges over one pre-tokenized piece and returns
+// its token count.
+func (t *bpeTokenizer) encodeWord(w string) int {
+	var symbols []string
+	if t.byteLevel {
+		for i := 0; i < len(w); i++ {
+			symbols = append(symbols, string(byteToRune[w[i]]))
+		}
+	} else {
+		if w == "" {
+			return 0
+		}
+		if w[0] != ' ' {
+			// Only the first piece lacks a leading space; SentencePiece
+			// prepends one so it also starts with "▁".
+			w = " " + w
+		}
+		for _, r := range strings.ReplaceAll(w, " ", "▁") {
+			symbols = append(symbols, string(r))
+		}
+	}
+	if t.ignoreMerges {
+		if _, ok := t.vocab[strings.Join(symbols, "")]; ok {
+			return 1
+		}
+	}
+
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(symbols); i++ {
+			if r, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (best < 0 || r < bestRank) {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		symbols[best] += symbols[best+1]
+		symbols = append(symbols[:best+1], symbols[best+2:]...)
+	}
+
+	n := 0
+	for _, sym := range symbols {
+		switch _, known := t.vocab[sym]; {
+		case known:
+			n++
+		case t.byteFallback:
+			n += len(sym) // one <0xNN> token per UTF-8 byte
+		default:
+			n++ // unk
+		}
+	}
+	return n
+}
+
+// byteToRune is GPT-2's bytes_to_unicode table: printable bytes map to
+// themselves, the rest to code points from U+0100 up, so every byte has a
+// visible stand-in in the vocab.
+var byteToRune = func() [256]rune {
+	var table [256]rune
+	next := rune(256)
+	for b := 0; b < 256; b++ {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			table[b] = rune(b)
+		} else {
+			table[b] = next
+			next++
+		}
+	}
+	return table
+}()
+
+// splitGPT2 splits s the way the GPT-2 pre-tokenizer regex does:
+// contractions, an optional leading space plus a run of letters, digits, or
+// other symbols, and whitespace runs that leave their last space to the
+// following word.
+func splitGPT2(s string) []string {
+	var out []string
+	for i := 0; i < len(s); {
+		if s[i] == '\'' {
+			if n := contractionLen(s[i:]); n > 0 {
+				out = append(out, s[i:i+n])
+				i += n
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		start := i
+		if r == ' ' && i+size < len(s) {
+			if next, _ := utf8.DecodeRuneInString(s[i+size:]); !unicode.IsSpace(next) {
+				i += size
+				r, size = utf8.DecodeRuneInString(s[i:])
+			}
+		}
+		if unicode.IsSpace(r) {
+			end := i
+			var last int
+			for end < len(s) {
+				rr, sz := utf8.DecodeRuneInString(s[end:])
+				if !unicode.IsSpace(rr) {
+					break
+				}
+				last = end
+				end += sz
+			}
+			// \s+(?!\S): a run followed by a word gives up its last
+			// character, which then leads that word or stands alone.
+			if end < len(s) && last > i {
+				end = last
+			}
+			out = append(out, s[start:end])
+			i = end
+			continue
+		}
+		class := runeClass(r)
+		i += size
+		for i < len(s) {
+			rr, sz := utf8.DecodeRuneInString(s[i:])
+			if unicode.IsSpace(rr) || runeClass(rr) != class {
+				break
+			}
+			i += sz
+		}
+		out = append(out, s[start:i])
+	}
+	return out
+}
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r):
+		return 1
+	case unicode.IsNumber(r):
+		return 2
+	default:
+		return 3
+	}
+}
+
+func contractionLen(s string) int {
+	for _, c := range []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"} {
+		if len(s) >= len(c) && strings.EqualFold(s[:len(c)], c) {
+			return len(c)
+		}
+	}
+	return 0
+}
+
+// splitMetaspace cuts s before every space, matching SentencePiece's
+// "▁word" pieces. Merges never cross a "▁" boundary in practice, and the
+// split keeps BPE quadratic in word length rather than post length.
+func splitMetaspace(s string) []string {
+	var out []string
+	start := 0
+	for i := 1; i < len(s); i++ {
+		if s[i] == ' ' && s[i-1] != ' ' {
+			out = append(out, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		out = append(out, s[start:])
+	}
+	return out
+}
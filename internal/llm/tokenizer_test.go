@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	redditJSON "github.com/meriley/reddit-spy/internal/redditJSON"
+)
+
+func writeTokenizer(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const byteLevelTokenizer = `{
+  "pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false},
+  "model": {
+    "type": "BPE",
+    "vocab": {"a": 0, "b": 1, "c": 2, "Ġ": 3, "ab": 4, "abc": 5, "Ġabc": 6},
+    "merges": %s
+  }
+}`
+
+func TestLoadTokenizer_ByteLevel(t *testing.T) {
+	for name, merges := range map[string]string{
+		"strings": `["a b", "ab c", "Ġ abc"]`,
+		"pairs":   `[["a", "b"], ["ab", "c"], ["Ġ", "abc"]]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			tok, err := LoadTokenizer(writeTokenizer(t, strings.Replace(byteLevelTokenizer, "%s", merges, 1)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tok.Count("abc abc"); got != 2 {
+				t.Errorf(`Count("abc abc") = %d, want 2`, got)
+			}
+			// "x" isn't in the vocab and there's no byte fallback: Ġ + unk.
+			if got := tok.Count("abc x"); got != 3 {
+				t.Errorf(`Count("abc x") = %d, want 3`, got)
+			}
+			if got := tok.Truncate("abc abc abc", 2); got != "abc abc" {
+				t.Errorf("Truncate = %q, want %q", got, "abc abc")
+			}
+			if got := clipForPrompt(tok, "abc abc abc", 2); got != "abc abc…[truncated]" {
+				t.Errorf("clipForPrompt = %q", got)
+			}
+		})
+	}
+}
+
+func TestLoadTokenizer_MetaspaceByteFallback(t *testing.T) {
+	tok, err := LoadTokenizer(writeTokenizer(t, `{
+  "pre_tokenizer": null,
+  "model": {
+    "type": "BPE",
+    "byte_fallback": true,
+    "vocab": {"▁": 0, "h": 1, "i": 2, "▁h": 3, "▁hi": 4},
+    "merges": ["▁ h", "▁h i"]
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tok.Count("hi hi"); got != 2 {
+		t.Errorf(`Count("hi hi") = %d, want 2`, got)
+	}
+	// "é" falls back to its two UTF-8 bytes.
+	if got := tok.Count("hé"); got != 3 {
+		t.Errorf(`Count("hé") = %d, want 3`, got)
+	}
+}
+
+func TestLoadTokenizer_Rejects(t *testing.T) {
+	if _, err := LoadTokenizer(writeTokenizer(t, `{"model": {"type": "WordPiece", "vocab": {"a": 0}}}`)); err == nil {
+		t.Error("WordPiece tokenizer should be rejected")
+	}
+	if _, err := LoadTokenizer(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file should error")
+	}
+}
+
+func TestSplitGPT2(t *testing.T) {
+	got := splitGPT2("Hello  world\n\nit's 42!")
+	want := []string{"Hello", " ", " world", "\n", "\n", "it", "'s", " 42", "!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitGPT2 = %q, want %q", got, want)
+	}
+}
+
+func TestHeuristicTokenizer_CountsRunes(t *testing.T) {
+	// Each "é" is two bytes but one character; a token is three of them.
+	if got := (heuristicTokenizer{}).Truncate("éééé", 1); got != "ééé" {
+		t.Errorf("Truncate = %q, want %q", got, "ééé")
+	}
+	if got := (heuristicTokenizer{}).Count("éééééé"); got != 2 {
+		t.Errorf("Count = %d, want 2", got)
+	}
+}
+
+func TestTruncate_UnspacedCJK(t *testing.T) {
+	long := strings.Repeat("日本語のテキスト", 2000)
+	for name, body := range map[string]string{
+		"byte level": strings.Replace(byteLevelTokenizer, "%s", `["a b", "ab c", "Ġ abc"]`, 1),
+		"metaspace": `{"model": {"type": "BPE", "byte_fallback": true,
+			"vocab": {"▁": 0, "日": 1}, "merges": []}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			tok, err := LoadTokenizer(writeTokenizer(t, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tok.Truncate(long, 100)
+			if got == "" || !strings.HasPrefix(long, got) || !utf8.ValidString(got) {
+				t.Fatalf("Truncate = %q, want a non-empty prefix", got)
+			}
+			if n := tok.Count(got); n > 100 || n < 90 {
+				t.Errorf("Count(Truncate(s, 100)) = %d, want close to 100", n)
+			}
+		})
+	}
+}
+
+// wordTokenizer counts whitespace-separated words, so budgets in tests are
+// easy to reason about.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(s string) int { return len(strings.Fields(s)) }
+
+func (wordTokenizer) Truncate(s string, n int) string {
+	return strings.Join(strings.Fields(s)[:min(n, len(strings.Fields(s)))], " ")
+}
+
+func TestTakeBodyChunk_UsesTokenizer(t *testing.T) {
+	in := MusicInput{Post: &redditJSON.RedditPost{}}
+	fixed := wordTokenizer{}.Count(systemPromptMusic) + wordTokenizer{}.Count(emptyMusicPrompt(t, in))
+	s := NewShaper(&fakeCompleter{}, Config{Model: "m", ContextLimit: fixed + contextHeadroom + 4},
+		WithTokenizer(wordTokenizer{}))
+
+	// Six words against a four-token budget: only the first line fits, even
+	// though the whole body is far under the heuristic's byte budget.
+	chunk, rest, _ := s.takeBodyChunk(in, "a b c\nd e f")
+	if chunk != "a b c" || rest != "d e f" {
+		t.Errorf("chunk/rest = %q / %q, want %q / %q", chunk, rest, "a b c", "d e f")
+	}
+}
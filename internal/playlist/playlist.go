@@ -0,0 +1,154 @@
+// Package playlist renders a music digest's releases into portable playlist
+// formats: extended M3U, XSPF (https://xspf.org/spec) and JSPF, the JSON
+// flavour of XSPF that ListenBrainz imports. Rendering is pure — callers
+// supply the tracks and decide how to ship the bytes.
+package playlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Track is one playable entry. Locations are tried in order by players that
+// support multiple sources (XSPF/JSPF); M3U only carries the first.
+type Track struct {
+	Artist    string
+	Title     string
+	Locations []string
+}
+
+// Playlist is the format-neutral input to every renderer.
+type Playlist struct {
+	Title      string
+	Annotation string
+	Created    time.Time
+	Tracks     []Track
+}
+
+// M3U renders an extended M3U playlist. Tracks without a location are kept
+// as comments so the file still documents the full release list, but players
+// skip them.
+func M3U(p Playlist) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	if p.Title != "" {
+		fmt.Fprintf(&b, "#PLAYLIST:%s\n", singleLine(p.Title))
+	}
+	for _, t := range p.Tracks {
+		label := trackLabel(t)
+		if len(t.Locations) == 0 {
+			fmt.Fprintf(&b, "# no link: %s\n", label)
+			continue
+		}
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", label, t.Locations[0])
+	}
+	return b.Bytes()
+}
+
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Version    string      `xml:"version,attr"`
+	Xmlns      string      `xml:"xmlns,attr"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Date       string      `xml:"date,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations []string `xml:"location"`
+	Creator   string   `xml:"creator,omitempty"`
+	Title     string   `xml:"title,omitempty"`
+}
+
+// XSPF renders an XSPF 1 document. Every track is included, linked or not.
+func XSPF(p Playlist) ([]byte, error) {
+	doc := xspfPlaylist{
+		Version:    "1",
+		Xmlns:      "http://xspf.org/ns/0/",
+		Title:      singleLine(p.Title),
+		Annotation: p.Annotation,
+		Date:       formatDate(p.Created),
+		Tracks:     make([]xspfTrack, 0, len(p.Tracks)),
+	}
+	for _, t := range p.Tracks {
+		doc.Tracks = append(doc.Tracks, xspfTrack{
+			Locations: t.Locations,
+			Creator:   t.Artist,
+			Title:     t.Title,
+		})
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal xspf: %w", err)
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+type jspfDoc struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title      string      `json:"title"`
+	Annotation string      `json:"annotation,omitempty"`
+	Date       string      `json:"date,omitempty"`
+	Track      []jspfTrack `json:"track"`
+}
+
+type jspfTrack struct {
+	Title    string   `json:"title,omitempty"`
+	Creator  string   `json:"creator,omitempty"`
+	Location []string `json:"location,omitempty"`
+}
+
+// JSPF renders the ListenBrainz-compatible JSON form. Links go into
+// location only: ListenBrainz reads identifier as a MusicBrainz recording
+// URI, and without an MBID it matches tracks by creator and title.
+func JSPF(p Playlist) ([]byte, error) {
+	doc := jspfDoc{Playlist: jspfPlaylist{
+		Title:      singleLine(p.Title),
+		Annotation: p.Annotation,
+		Date:       formatDate(p.Created),
+		Track:      make([]jspfTrack, 0, len(p.Tracks)),
+	}}
+	for _, t := range p.Tracks {
+		doc.Playlist.Track = append(doc.Playlist.Track, jspfTrack{
+			Title:    t.Title,
+			Creator:  t.Artist,
+			Location: t.Locations,
+		})
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal jspf: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+func trackLabel(t Track) string {
+	switch {
+	case t.Artist != "" && t.Title != "":
+		return singleLine(t.Artist + " - " + t.Title)
+	case t.Artist != "":
+		return singleLine(t.Artist)
+	default:
+		return singleLine(t.Title)
+	}
+}
+
+// singleLine keeps user-supplied strings from breaking line-oriented M3U.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,78 @@
+package playlist
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func samplePlaylist() Playlist {
+	return Playlist{
+		Title:   "r/Metalcore\nreleases",
+		Created: time.Date(2026, 4, 16, 14, 0, 0, 0, time.UTC),
+		Tracks: []Track{
+			{Artist: "Wage War", Title: "It Calls Me", Locations: []string{"https://music.youtube.com/watch?v=a", "https://www.qobuz.com/us-en/album/x"}},
+			{Artist: "Thrown", Title: "Split"},
+		},
+	}
+}
+
+func TestM3U(t *testing.T) {
+	got := string(M3U(samplePlaylist()))
+	want := "#EXTM3U\n" +
+		"#PLAYLIST:r/Metalcore releases\n" +
+		"#EXTINF:-1,Wage War - It Calls Me\nhttps://music.youtube.com/watch?v=a\n" +
+		"# no link: Thrown - Split\n"
+	if got != want {
+		t.Errorf("M3U =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXSPF(t *testing.T) {
+	out, err := XSPF(samplePlaylist())
+	if err != nil {
+		t.Fatalf("XSPF: %v", err)
+	}
+	var doc xspfPlaylist
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("round-trip: %v\n%s", err, out)
+	}
+	if len(doc.Tracks) != 2 {
+		t.Fatalf("tracks = %d, want 2", len(doc.Tracks))
+	}
+	if len(doc.Tracks[0].Locations) != 2 || doc.Tracks[0].Creator != "Wage War" {
+		t.Errorf("first track = %+v", doc.Tracks[0])
+	}
+	if len(doc.Tracks[1].Locations) != 0 {
+		t.Errorf("unlinked track should have no location: %+v", doc.Tracks[1])
+	}
+	if !strings.HasPrefix(string(out), "<?xml") {
+		t.Errorf("missing XML header")
+	}
+}
+
+func TestJSPF(t *testing.T) {
+	out, err := JSPF(samplePlaylist())
+	if err != nil {
+		t.Fatalf("JSPF: %v", err)
+	}
+	var doc jspfDoc
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("round-trip: %v", err)
+	}
+	if doc.Playlist.Title != "r/Metalcore releases" {
+		t.Errorf("title = %q", doc.Playlist.Title)
+	}
+	if doc.Playlist.Date != "2026-04-16T14:00:00Z" {
+		t.Errorf("date = %q", doc.Playlist.Date)
+	}
+	if len(doc.Playlist.Track) != 2 || doc.Playlist.Track[0].Location[0] != "https://music.youtube.com/watch?v=a" {
+		t.Errorf("tracks = %+v", doc.Playlist.Track)
+	}
+	// identifier is for MusicBrainz URIs; streaming links must stay out.
+	if strings.Contains(string(out), `"identifier"`) {
+		t.Errorf("JSPF should not set identifier:\n%s", out)
+	}
+}
@@ -0,0 +1,81 @@
+// Package quiethours parses a rule's quiet hours, a daily "HH:MM-HH:MM"
+// span during which its matches are held back, and works out when a span
+// ends. A span whose end is before its start runs past midnight.
+package quiethours
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a daily span in minutes after midnight, From inclusive and To
+// exclusive. The zero Window is not valid; Parse never returns it.
+type Window struct {
+	From, To int
+}
+
+// Parse reads "HH:MM-HH:MM" (a single-digit hour is fine). The two times
+// must differ: there's no way to ask for a span covering the whole day.
+func Parse(s string) (Window, error) {
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(startStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return Window{}, fmt.Errorf("quiet hours %q: %w", s, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("quiet hours %q: start and end are the same", s)
+	}
+	return Window{From: start, To: end}, nil
+}
+
+// parseClock reads "HH:MM" as minutes after midnight.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || len(m) != 2 || len(h) == 0 || len(h) > 2 {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("%q has an hour outside 0–23", s)
+	}
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("%q has a minute outside 00–59", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// String is the canonical "HH:MM-HH:MM" form, which is what rules store.
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+// Contains reports whether t's wall-clock time, in t's location, falls in
+// the window.
+func (w Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.From < w.To {
+		return m >= w.From && m < w.To
+	}
+	return m >= w.From || m < w.To
+}
+
+// End is the first moment at or after t, in t's location, when the window
+// closes. For a t inside the window that's the end of its span.
+func (w Window) End(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	end := time.Date(y, mo, d, w.To/60, w.To%60, 0, 0, t.Location())
+	if end.Before(t) {
+		end = time.Date(y, mo, d+1, w.To/60, w.To%60, 0, 0, t.Location())
+	}
+	return end
+}
@@ -0,0 +1,60 @@
+package quiethours
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"22:00-07:00":   "22:00-07:00",
+		" 9:30 - 17:05": "09:30-17:05",
+		"00:00-23:59":   "00:00-23:59",
+	}
+	for in, want := range cases {
+		w, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if got := w.String(); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "22:00", "22:00-22:00", "24:00-07:00", "22:60-07:00", "22-07", "22:0-07:00", "ab:cd-07:00"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q): want error", in)
+		}
+	}
+}
+
+func TestContainsAndEnd(t *testing.T) {
+	loc := time.FixedZone("MST", -7*3600)
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, loc) }
+
+	overnight, _ := Parse("22:00-07:00")
+	daytime, _ := Parse("09:00-17:00")
+	cases := []struct {
+		name string
+		w    Window
+		t    time.Time
+		in   bool
+		end  time.Time
+	}{
+		{"overnight evening", overnight, at(10, 23, 15), true, at(11, 7, 0)},
+		{"overnight morning", overnight, at(11, 6, 59), true, at(11, 7, 0)},
+		{"overnight start", overnight, at(10, 22, 0), true, at(11, 7, 0)},
+		{"overnight end", overnight, at(11, 7, 0), false, at(11, 7, 0)},
+		{"overnight afternoon", overnight, at(10, 15, 0), false, at(11, 7, 0)},
+		{"daytime", daytime, at(10, 12, 0), true, at(10, 17, 0)},
+		{"daytime evening", daytime, at(10, 18, 0), false, at(11, 17, 0)},
+	}
+	for _, tc := range cases {
+		if got := tc.w.Contains(tc.t); got != tc.in {
+			t.Errorf("%s: Contains = %v, want %v", tc.name, got, tc.in)
+		}
+		if got := tc.w.End(tc.t); !got.Equal(tc.end) {
+			t.Errorf("%s: End = %v, want %v", tc.name, got, tc.end)
+		}
+	}
+}
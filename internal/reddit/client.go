@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log/slog"
 	"net/http"
@@ -66,6 +67,65 @@ type Post struct {
 	Score       int     `json:"score"`
 	NumComments int     `json:"num_comments"`
 	CreatedUTC  float64 `json:"created_utc"`
+	// PostHint is Reddit's guess at the post type: "image", "link",
+	// "hosted:video", "self" and so on. Often empty on older posts.
+	PostHint string   `json:"post_hint"`
+	Preview  *Preview `json:"preview,omitempty"`
+	// CrosspostParent is the fullname ("t3_…") of the post this one was
+	// crossposted from; empty for original posts.
+	CrosspostParent string `json:"crosspost_parent"`
+}
+
+// Preview holds the renditions Reddit generates for image and link posts.
+type Preview struct {
+	Images []PreviewSet `json:"images"`
+}
+
+// PreviewSet is one preview image: the full-size source plus the
+// downscaled resolutions, narrowest first.
+type PreviewSet struct {
+	Source      PreviewImage   `json:"source"`
+	Resolutions []PreviewImage `json:"resolutions"`
+}
+
+// PreviewImage is one rendition of a preview image.
+type PreviewImage struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// maxPreviewWidth is the widest rendition ImageURL prefers over the
+// full-size source.
+const maxPreviewWidth = 1080
+
+// ImageURL returns the widest rendition of the first preview image up to
+// maxPreviewWidth, or the source when there's no smaller one. Listings
+// fetched without raw_json=1 HTML-escape the URL, so it's unescaped here.
+// Returns "" for a nil or empty preview.
+func (p *Preview) ImageURL() string {
+	if p == nil || len(p.Images) == 0 {
+		return ""
+	}
+	img := p.Images[0]
+	best := img.Source
+	if best.Width > maxPreviewWidth {
+		for _, r := range img.Resolutions {
+			if r.Width <= maxPreviewWidth && (best.Width > maxPreviewWidth || r.Width > best.Width) {
+				best = r
+			}
+		}
+	}
+	return html.UnescapeString(best.URL)
+}
+
+// Comment mirrors the fields of a Reddit comment (kind t1) the bot uses.
+type Comment struct {
+	Author   string `json:"author"`
+	ID       string `json:"id"`
+	Body     string `json:"body"`
+	Score    int    `json:"score"`
+	Stickied bool   `json:"stickied"`
 }
 
 type listingChild struct {
@@ -658,3 +718,48 @@ func (c *SpoofClient) GetSubredditPosts(ctx context.Context, subreddit, after st
 
 	return posts, listing.Data.After, nil
 }
+
+// GetPostComments fetches up to limit top-level comments on a post, sorted
+// by top. Stickied comments (usually moderator boilerplate) and deleted or
+// removed ones are skipped, so fewer than limit may come back.
+func (c *SpoofClient) GetPostComments(ctx context.Context, postID string, limit int) ([]*Comment, error) {
+	params := url.Values{}
+	params.Set("sort", "top")
+	params.Set("depth", "1")
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("raw_json", "1")
+
+	body, err := c.doRequest(ctx, "/comments/"+postID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The comments endpoint returns [post_listing, comments_listing].
+	var listings []listingResponse
+	if err := json.Unmarshal(body, &listings); err != nil {
+		return nil, fmt.Errorf("decode comments listing: %w", err)
+	}
+	if len(listings) < 2 {
+		return nil, fmt.Errorf("%w: no comments listing for post %s", ErrNotFound, postID)
+	}
+
+	comments := make([]*Comment, 0, limit)
+	for _, child := range listings[1].Data.Children {
+		if child.Kind != "t1" {
+			continue
+		}
+		var cm Comment
+		if err := json.Unmarshal(child.Data, &cm); err != nil {
+			slog.Warn("failed to decode comment", "error", err)
+			continue
+		}
+		if cm.Stickied || cm.Body == "[deleted]" || cm.Body == "[removed]" {
+			continue
+		}
+		comments = append(comments, &cm)
+		if len(comments) == limit {
+			break
+		}
+	}
+	return comments, nil
+}
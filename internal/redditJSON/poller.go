@@ -45,17 +45,20 @@ func (r *Poller) Start(c chan []*RedditPost) {
 				result := make([]*RedditPost, 0, len(posts))
 				for _, p := range posts {
 					result = append(result, &RedditPost{
-						Author:      p.Author,
-						ID:          p.ID,
-						Permalink:   p.Permalink,
-						Selftext:    p.Selftext,
-						Subreddit:   p.Subreddit,
-						Thumbnail:   p.Thumbnail,
-						Title:       p.Title,
-						URL:         p.URL,
-						Score:       p.Score,
-						NumComments: p.NumComments,
-						CreatedUTC:  p.CreatedUTC,
+						Author:          p.Author,
+						ID:              p.ID,
+						Permalink:       p.Permalink,
+						Selftext:        p.Selftext,
+						Subreddit:       p.Subreddit,
+						Thumbnail:       p.Thumbnail,
+						Title:           p.Title,
+						URL:             p.URL,
+						Score:           p.Score,
+						NumComments:     p.NumComments,
+						CreatedUTC:      p.CreatedUTC,
+						PostHint:        p.PostHint,
+						Preview:         p.Preview,
+						CrosspostParent: p.CrosspostParent,
 					})
 				}
 				c <- result
@@ -73,16 +76,19 @@ func (r *Poller) Stop() {
 
 type (
 	RedditPost struct {
-		Author      string  `json:"author"`
-		ID          string  `json:"id"`
-		Permalink   string  `json:"permalink"`
-		Selftext    string  `json:"selftext"`
-		Subreddit   string  `json:"subreddit"`
-		Thumbnail   string  `json:"thumbnail"`
-		Title       string  `json:"title"`
-		URL         string  `json:"URL"`
-		Score       int     `json:"score"`
-		NumComments int     `json:"num_comments"`
-		CreatedUTC  float64 `json:"created_utc"`
+		Author          string          `json:"author"`
+		ID              string          `json:"id"`
+		Permalink       string          `json:"permalink"`
+		Selftext        string          `json:"selftext"`
+		Subreddit       string          `json:"subreddit"`
+		Thumbnail       string          `json:"thumbnail"`
+		Title           string          `json:"title"`
+		URL             string          `json:"URL"`
+		Score           int             `json:"score"`
+		NumComments     int             `json:"num_comments"`
+		CreatedUTC      float64         `json:"created_utc"`
+		PostHint        string          `json:"post_hint"`
+		Preview         *reddit.Preview `json:"preview,omitempty"`
+		CrosspostParent string          `json:"crosspost_parent"`
 	}
 )
@@ -0,0 +1,146 @@
+// Package rulesfile keeps the database's managed rules in step with a
+// declarative rules file, typically a ConfigMap mounted into the pod. It
+// reconciles once at startup and again whenever the file's content
+// changes. The file's format and what reconciling does are guildconfig's.
+package rulesfile
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	"github.com/meriley/reddit-spy/internal/guildconfig"
+)
+
+const (
+	EnvPath     = "RULES_FILE"
+	EnvInterval = "RULES_FILE_INTERVAL"
+
+	// DefaultInterval is how often the file is re-read. A ConfigMap update
+	// reaches the pod's mount within a minute or so anyway.
+	DefaultInterval = 30 * time.Second
+)
+
+// Config is where the rules file lives and how often it is checked. An
+// empty Path disables the reconciler; a zero Interval reconciles at
+// startup only.
+type Config struct {
+	Path     string
+	Interval time.Duration
+}
+
+// ConfigFromEnv reads Config from the process environment. Returns an
+// error if RULES_FILE_INTERVAL is malformed.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Path: os.Getenv(EnvPath), Interval: DefaultInterval}
+	if raw := os.Getenv(EnvInterval); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid %s=%q: must be a non-negative duration", EnvInterval, raw)
+		}
+		cfg.Interval = d
+	}
+	return cfg, nil
+}
+
+// Reconciler applies the rules file when its content changes. Safe for
+// concurrent use.
+type Reconciler struct {
+	store guildconfig.ReconcileStore
+	cfg   Config
+	// onApply is told every subreddit the file's rules watch after each
+	// reconcile, so pollers can be started for new ones.
+	onApply func(ctx context.Context, subreddits []string)
+
+	mu sync.Mutex
+	// seen is the content last reconciled, or last rejected as invalid;
+	// the same content isn't tried again.
+	seen [sha256.Size]byte
+	read bool
+}
+
+// New returns a reconciler for the file cfg names. onApply may be nil.
+func New(store guildconfig.ReconcileStore, cfg Config, onApply func(ctx context.Context, subreddits []string)) *Reconciler {
+	return &Reconciler{store: store, cfg: cfg, onApply: onApply}
+}
+
+// Reconcile reads the file and, unless its content is what was last
+// handled, makes the managed rules match it. It reports whether anything
+// was applied. An invalid file is not retried until it changes; a store
+// error is retried on the next call.
+func (r *Reconciler) Reconcile(ctx ctxpkg.Ctx) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	raw, err := os.ReadFile(r.cfg.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	if r.read && sum == r.seen {
+		return false, nil
+	}
+	f, err := guildconfig.ParseFile(raw)
+	if err != nil {
+		r.seen, r.read = sum, true
+		return false, fmt.Errorf("%s: %w", r.cfg.Path, err)
+	}
+	plans, deleted, err := guildconfig.Reconcile(ctx, r.store, f)
+	if err != nil {
+		return false, fmt.Errorf("failed to reconcile rules: %w", err)
+	}
+	r.seen, r.read = sum, true
+
+	changes := 0
+	subreddits := map[string]bool{}
+	for _, p := range plans {
+		for _, line := range p.Changes {
+			_ = level.Info(ctx.Log()).Log("msg", "rules file change", "guild", p.Guild, "change", line)
+		}
+		changes += len(p.Changes)
+		for _, sr := range p.Subreddits {
+			subreddits[sr] = true
+		}
+	}
+	_ = level.Info(ctx.Log()).Log("msg", "rules file reconciled", "path", r.cfg.Path,
+		"guilds", len(plans), "changes", changes, "deleted", deleted)
+
+	if r.onApply != nil {
+		names := make([]string, 0, len(subreddits))
+		for sr := range subreddits {
+			names = append(names, sr)
+		}
+		sort.Strings(names)
+		r.onApply(ctx, names)
+	}
+	return true, nil
+}
+
+// Run re-reads the file every Interval until ctx is done. The startup
+// reconcile is the caller's, so a bad file can stop the bot before it
+// starts polling.
+func (r *Reconciler) Run(ctx ctxpkg.Ctx) {
+	if r.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := r.Reconcile(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				_ = level.Error(ctx.Log()).Log("msg", "rules file reconcile failed", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
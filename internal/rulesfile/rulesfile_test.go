@@ -0,0 +1,98 @@
+package rulesfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+// countingStore records how many reconciles reached the store.
+type countingStore struct {
+	*dbstore.MemStore
+	calls int
+}
+
+func (s *countingStore) ReconcileManagedRules(ctx context.Context, guilds []dbstore.ManagedGuild) (int64, error) {
+	s.calls++
+	return s.MemStore.ReconcileManagedRules(ctx, guilds)
+}
+
+const rulesV1 = `
+version: 1
+guilds:
+  - guild: g1
+    channels:
+      - id: c1
+        rules:
+          - {subreddit: metalcore, match_on: title, value: tour}
+`
+
+func TestReconcile(t *testing.T) {
+	ctx := ctxpkg.New(context.Background())
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	write := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := &countingStore{MemStore: dbstore.NewMemStore()}
+	var polled [][]string
+	r := New(store, Config{Path: path}, func(_ context.Context, names []string) { polled = append(polled, names) })
+
+	write(rulesV1)
+	if applied, err := r.Reconcile(ctx); err != nil || !applied {
+		t.Fatalf("first Reconcile = %t, %v", applied, err)
+	}
+	rules, _ := store.GetRulesByChannel(ctx, "c1")
+	if len(rules) != 1 || !rules[0].Managed {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	// Unchanged content isn't reapplied.
+	if applied, err := r.Reconcile(ctx); err != nil || applied || store.calls != 1 {
+		t.Errorf("unchanged Reconcile = %t, %v (%d store calls)", applied, err, store.calls)
+	}
+
+	// An invalid file is reported once and leaves the rules alone.
+	write("version: 1\nguilds: [{guild: g1, channels: [{id: c1, rules: [{subreddit: s}]}]}]\n")
+	if _, err := r.Reconcile(ctx); err == nil {
+		t.Error("invalid file accepted")
+	}
+	if _, err := r.Reconcile(ctx); err != nil {
+		t.Errorf("invalid file retried: %v", err)
+	}
+
+	write(rulesV1 + "          - {subreddit: poppunkers, match_on: title, value: reunion}\n")
+	if applied, err := r.Reconcile(ctx); err != nil || !applied {
+		t.Fatalf("changed Reconcile = %t, %v", applied, err)
+	}
+	if rules, _ := store.GetRulesByChannel(ctx, "c1"); len(rules) != 2 {
+		t.Errorf("rules after change = %+v", rules)
+	}
+	want := [][]string{{"metalcore"}, {"metalcore", "poppunkers"}}
+	if !reflect.DeepEqual(polled, want) {
+		t.Errorf("onApply got %q, want %q", polled, want)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvPath, "")
+	if cfg, err := ConfigFromEnv(); err != nil || cfg.Path != "" || cfg.Interval != DefaultInterval {
+		t.Errorf("defaults = %+v, %v", cfg, err)
+	}
+	t.Setenv(EnvPath, "/etc/reddit-spy/rules.yaml")
+	t.Setenv(EnvInterval, "0")
+	if cfg, err := ConfigFromEnv(); err != nil || cfg.Path != "/etc/reddit-spy/rules.yaml" || cfg.Interval != 0 {
+		t.Errorf("overrides = %+v, %v", cfg, err)
+	}
+	t.Setenv(EnvInterval, "-1s")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("negative interval accepted")
+	}
+}
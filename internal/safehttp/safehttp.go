@@ -0,0 +1,57 @@
+// Package safehttp builds HTTP clients for fetching URLs that Reddit users
+// supply. Every connection is checked after DNS resolution, so a link (or
+// any redirect it leads to) can't reach loopback, private, link-local or
+// cloud-metadata addresses inside the cluster.
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlocked is returned, wrapped, when a connection would reach an address
+// the client doesn't allow.
+var ErrBlocked = errors.New("safehttp: address is not public")
+
+// sharedAddress is the carrier-grade NAT range (RFC 6598), which
+// netip.Addr.IsPrivate doesn't cover.
+var sharedAddress = netip.MustParsePrefix("100.64.0.0/10")
+
+// Public reports whether ap is a globally routable unicast address.
+func Public(ap netip.AddrPort) bool {
+	a := ap.Addr().Unmap()
+	return a.IsValid() && a.IsGlobalUnicast() && !a.IsPrivate() && !sharedAddress.Contains(a)
+}
+
+// NewClient returns an http.Client whose connections may only reach
+// addresses allow reports true for; a nil allow means Public. Proxy
+// settings from the environment are ignored, since the check would only
+// see the proxy's address.
+func NewClient(timeout time.Duration, allow func(netip.AddrPort) bool) *http.Client {
+	if allow == nil {
+		allow = Public
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlocked, address)
+			}
+			if !allow(ap) {
+				return fmt.Errorf("%w: %s", ErrBlocked, ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
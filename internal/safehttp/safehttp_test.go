@@ -0,0 +1,45 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34:443":     true,
+		"[2606:4700::1111]:443": true,
+		"127.0.0.1:80":          false,
+		"[::1]:80":              false,
+		"10.0.0.5:80":           false,
+		"172.16.3.4:80":         false,
+		"192.168.1.1:80":        false,
+		"169.254.169.254:80":    false,
+		"100.64.0.1:80":         false,
+		"0.0.0.0:80":            false,
+		"[fd00:ec2::254]:80":    false,
+		"[fe80::1]:80":          false,
+		"[::ffff:127.0.0.1]:80": false,
+		"224.0.0.1:80":          false,
+	}
+	for in, want := range cases {
+		if got := Public(netip.MustParseAddrPort(in)); got != want {
+			t.Errorf("Public(%s) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestNewClient_BlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+	_, err := NewClient(0, nil).Do(req)
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+}
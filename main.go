@@ -6,25 +6,42 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/log/level"
 	"github.com/joho/godotenv"
 
+	"github.com/meriley/reddit-spy/internal/article"
 	ctxpkg "github.com/meriley/reddit-spy/internal/context"
 	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	"github.com/meriley/reddit-spy/internal/discord"
 	"github.com/meriley/reddit-spy/internal/evaluator"
+	"github.com/meriley/reddit-spy/internal/janitor"
 	"github.com/meriley/reddit-spy/internal/lastfm"
 	"github.com/meriley/reddit-spy/internal/llm"
 	"github.com/meriley/reddit-spy/internal/piped"
 	"github.com/meriley/reddit-spy/internal/qobuz"
+	"github.com/meriley/reddit-spy/internal/rulesfile"
 	"github.com/meriley/reddit-spy/redditDiscordBot"
 )
 
 var version = "dev"
 
+// llmJobPollInterval is how often the job worker checks llm_jobs for due
+// retries and held matches.
+const llmJobPollInterval = 30 * time.Second
+
+// pollerSyncInterval is how often the main loop re-checks which subreddits
+// have active rules, so a snoozed rule's poller restarts when it wakes.
+const pollerSyncInterval = time.Minute
+
+// defaultCommentCount is how many top comments a narrative prompt sees when
+// DIGEST_COMMENT_COUNT isn't set.
+const defaultCommentCount = 5
+
 func main() {
 	baseCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -35,21 +52,35 @@ func main() {
 
 	appCtx := ctxpkg.New(baseCtx)
 
+	// `reddit-spy migrate status|up|down [n]` manages the schema and exits
+	// instead of starting the bot.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(appCtx, os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+	// `reddit-spy config export|apply` reads or replaces a guild's rules.
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(appCtx, os.Stdout, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "config:", err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+
 	_ = level.Info(appCtx.Log()).Log("msg", "starting reddit-spy", "version", version)
 
-	store, err := dbstore.New(appCtx)
+	store, err := dbstore.Open(appCtx)
 	if err != nil {
 		panic(fmt.Errorf("failed to create db: %w", err))
 	}
 	defer store.Close()
 
-	if err := dbstore.Bootstrap(
-		appCtx,
-		store.Pool,
-		os.Getenv(dbstore.EnvPostgresUser),
-		os.Getenv(dbstore.EnvPostgresPassword),
-		os.Getenv(dbstore.EnvPostgresDatabase),
-	); err != nil {
+	if err := store.Bootstrap(appCtx); err != nil {
 		panic(fmt.Errorf("failed to bootstrap db: %w", err))
 	}
 
@@ -58,18 +89,67 @@ func main() {
 		panic(fmt.Errorf("failed to create bot: %w", err))
 	}
 
-	discordOpts := []discord.Option{}
-	if shaper := newShaper(appCtx); shaper != nil {
-		discordOpts = append(discordOpts, discord.WithShaper(shaper))
+	// Declarative rules: RULES_FILE is reconciled before the pollers start,
+	// so a broken file stops the rollout, and again whenever it changes.
+	var rulesFile *rulesfile.Reconciler
+	if cfg, err := rulesfile.ConfigFromEnv(); err != nil {
+		panic(err)
+	} else if cfg.Path != "" {
+		rulesFile = rulesfile.New(store, cfg, func(context.Context, []string) {
+			if err := bot.SyncPollers(appCtx); err != nil {
+				_ = level.Error(appCtx.Log()).Log("msg", "failed to sync pollers after rules file change", "error", err)
+			}
+		})
+		if _, err := rulesFile.Reconcile(appCtx); err != nil {
+			panic(fmt.Errorf("failed to apply %s: %w", rulesfile.EnvPath, err))
+		}
+		_ = level.Info(appCtx.Log()).Log("msg", "rules file enabled", "path", cfg.Path, "interval", cfg.Interval)
 	}
+
+	discordOpts := []discord.Option{}
+	llmOpts, shaper, embedder := newLLMOptions(appCtx, store)
+	discordOpts = append(discordOpts, llmOpts...)
+	// Classification rules match on LLM labels drawn from CLASSIFY_TOPICS.
+	topics := classifyTopics()
+	discordOpts = append(discordOpts, discord.WithClassifyTopics(topics))
+	defaultWindowHours := janitor.DefaultWindowHours
 	if raw := os.Getenv("DIGEST_DEFAULT_WINDOW_HOURS"); raw != "" {
 		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			defaultWindowHours = n
 			discordOpts = append(discordOpts, discord.WithDefaultWindowHours(n))
 			_ = level.Info(appCtx.Log()).Log("msg", "digest default window configured", "hours", n)
 		} else {
 			_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed DIGEST_DEFAULT_WINDOW_HOURS", "raw", raw)
 		}
 	}
+	// Progressive-edit cadence for streamed LLM output (LLM_STREAM=true).
+	if raw := os.Getenv("DIGEST_STREAM_EDIT_INTERVAL"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			discordOpts = append(discordOpts, discord.WithStreamEditInterval(d))
+		} else {
+			_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed DIGEST_STREAM_EDIT_INTERVAL", "raw", raw)
+		}
+	}
+	// Crossposts and copies of a post already in a narrative digest fold
+	// into its item when they arrive within DUPLICATE_WINDOW (default 48h,
+	// 0 disables).
+	if raw := os.Getenv("DUPLICATE_WINDOW"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d >= 0 {
+			discordOpts = append(discordOpts, discord.WithDuplicateWindow(d))
+		} else {
+			_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed DUPLICATE_WINDOW", "raw", raw)
+		}
+	}
+	// A 👎 or "not relevant" vote on a digest adds a rule's suggested
+	// exclusion terms once a word is in FEEDBACK_AUTO_EXCLUDE disliked posts
+	// (unset or 0: only /rule_stats suggests them).
+	if raw := os.Getenv("FEEDBACK_AUTO_EXCLUDE"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			discordOpts = append(discordOpts, discord.WithAutoExclude(n))
+		} else {
+			_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed FEEDBACK_AUTO_EXCLUDE", "raw", raw)
+		}
+	}
 	// Music-mode popularity sort: keyless Last.fm artist-page scrape with a
 	// Postgres-backed cache. Always on — failures are soft and the digest
 	// falls back to source order.
@@ -89,27 +169,99 @@ func main() {
 		_ = level.Info(appCtx.Log()).Log("msg", "qobuz enabled")
 	}
 
+	// Narrative prompts see each post's top comments (DIGEST_COMMENT_COUNT,
+	// default 5, 0 disables) and, for link posts, the linked article's text
+	// unless ARTICLE_FETCH_DISABLED is set.
+	commentCount := defaultCommentCount
+	if raw := os.Getenv("DIGEST_COMMENT_COUNT"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			commentCount = n
+		} else {
+			_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed DIGEST_COMMENT_COUNT", "raw", raw)
+		}
+	}
+	discordOpts = append(discordOpts, discord.WithComments(bot.Reddit, commentCount))
+	if os.Getenv("ARTICLE_FETCH_DISABLED") == "" {
+		discordOpts = append(discordOpts, discord.WithArticles(article.New(article.DefaultTimeout)))
+	}
+	// Retention: closed digests, old notifications and posts, and stale
+	// lookup-cache rows are pruned in batches every JANITOR_INTERVAL.
+	var sweeper *janitor.Janitor
+	if cfg, err := janitor.ConfigFromEnv(); err != nil {
+		_ = level.Warn(appCtx.Log()).Log("msg", "retention janitor disabled", "reason", err.Error())
+	} else if cfg.Interval > 0 {
+		cfg.DefaultWindowHours = defaultWindowHours
+		sweeper = janitor.New(store, cfg)
+		discordOpts = append(discordOpts, discord.WithJanitor(sweeper))
+		_ = level.Info(appCtx.Log()).Log("msg", "retention janitor enabled",
+			"interval", cfg.Interval, "batch_size", cfg.BatchSize, "digest_days", cfg.DigestDays,
+			"notification_margin_days", cfg.NotificationMarginDays, "cache_days", cfg.CacheDays)
+	}
+
 	discordClient, err := discord.New(appCtx, bot, discordOpts...)
 	if err != nil {
 		panic(fmt.Errorf("failed to create discord client: %w", err))
 	}
 	defer discordClient.Close()
 
-	subreddits, err := bot.Store.GetSubreddits(appCtx)
-	if err != nil {
-		panic(fmt.Errorf("failed to get subreddits: %w", err))
-	}
-	for _, sr := range subreddits {
-		_ = level.Info(appCtx.Log()).Log("msg", "starting poller", "subreddit", sr.ExternalID)
+	// Only subreddits with an active rule are polled; paused and snoozed
+	// rules don't keep a poller running.
+	if err := bot.SyncPollers(appCtx); err != nil {
+		panic(fmt.Errorf("failed to start pollers: %w", err))
 	}
 
-	for _, subreddit := range subreddits {
-		bot.AddSubredditPoller(appCtx, subreddit)
+	var evalOpts []evaluator.Option
+	if shaper != nil {
+		perMinute := evaluator.DefaultClassifyPerMinute
+		if raw := os.Getenv("CLASSIFY_RATE_PER_MINUTE"); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+				perMinute = n
+			} else {
+				_ = level.Warn(appCtx.Log()).Log("msg", "ignoring malformed CLASSIFY_RATE_PER_MINUTE", "raw", raw)
+			}
+		}
+		evalOpts = append(evalOpts, evaluator.WithClassifier(shaper, topics, perMinute))
+	}
+	if embedder != nil {
+		evalOpts = append(evalOpts, evaluator.WithEmbedder(embedder))
 	}
+	evaluate := evaluator.NewRuleEvaluator(store, evalOpts...)
 
-	evaluate := evaluator.NewRuleEvaluator(store)
+	pollerTicker := time.NewTicker(pollerSyncInterval)
+	defer pollerTicker.Stop()
 
 	var wg sync.WaitGroup
+	if sweeper != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sweeper.Run(appCtx)
+		}()
+	}
+	if rulesFile != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rulesFile.Run(appCtx)
+		}()
+	}
+	// Deferred LLM work (llm_jobs) gets its own goroutine: a job can make
+	// several slow LLM calls, and live matches shouldn't wait on them.
+	// SendMessage and the jobs share a per-channel lock instead.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		jobTicker := time.NewTicker(llmJobPollInterval)
+		defer jobTicker.Stop()
+		for {
+			select {
+			case <-jobTicker.C:
+				discordClient.RetryDueJobs(appCtx)
+			case <-appCtx.Done():
+				return
+			}
+		}
+	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -123,6 +275,10 @@ func main() {
 				if err := discordClient.SendMessage(appCtx, result); err != nil {
 					_ = level.Error(appCtx.Log()).Log("msg", "send message failed", "error", err)
 				}
+			case <-pollerTicker.C:
+				if err := bot.SyncPollers(appCtx); err != nil {
+					_ = level.Warn(appCtx.Log()).Log("msg", "failed to sync pollers", "error", err)
+				}
 			case <-appCtx.Done():
 				_ = level.Info(appCtx.Log()).Log("msg", "shutting down")
 				bot.Stop()
@@ -134,20 +290,72 @@ func main() {
 	wg.Wait()
 }
 
-// newShaper builds an LLM shaper from env vars. Returns nil (not an error) if
-// LLM_BASE_URL / LLM_MODEL aren't configured — reddit-spy degrades to the
-// raw-selftext behaviour rather than refusing to start.
-func newShaper(ctx ctxpkg.Ctx) *llm.Shaper {
+// newLLMOptions builds the LLM shaper from env vars — routed over the
+// configured backends, behind the Postgres completion cache unless
+// LLM_CACHE_TTL=0 — and returns the discord options that attach it along
+// with the shaper itself, which also classifies posts, and the embedder for
+// semantic rules (nil unless LLM_MODEL_EMBEDDING is set). Returns nils (not
+// an error) if LLM_BACKENDS / LLM_BASE_URL / LLM_MODEL aren't configured —
+// reddit-spy degrades to the raw-selftext behaviour and classification and
+// semantic rules never match, rather than refusing to start.
+func newLLMOptions(ctx ctxpkg.Ctx, store dbstore.Backend) ([]discord.Option, *llm.Shaper, *llm.Embedder) {
 	cfg, err := llm.ConfigFromEnv()
 	if err != nil {
 		_ = level.Warn(ctx.Log()).Log("msg", "llm disabled", "reason", err.Error())
-		return nil
+		return nil, nil, nil
 	}
-	client, err := llm.NewClient(cfg)
+	router, err := llm.NewRouter(cfg)
 	if err != nil {
 		_ = level.Warn(ctx.Log()).Log("msg", "llm client init failed; disabling llm", "error", err)
-		return nil
+		return nil, nil, nil
+	}
+	opts := []discord.Option{discord.WithLLMRouter(router)}
+	var client llm.ChatCompleter = router
+	if cfg.CacheTTL > 0 {
+		cache := llm.NewCachedCompleter(router, store, cfg.CacheTTL, cfg.CacheMaxRows)
+		client = cache
+		opts = append(opts, discord.WithLLMCache(cache))
+	}
+	var tok llm.Tokenizer
+	if cfg.TokenizerPath != "" {
+		if tok, err = llm.LoadTokenizer(cfg.TokenizerPath); err != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "tokenizer load failed; using chars-per-token heuristic", "path", cfg.TokenizerPath, "error", err)
+		}
+	}
+	_ = level.Info(ctx.Log()).Log("msg", "llm enabled",
+		"backends", len(router.Status()), "base_url", cfg.BaseURL,
+		"narrative_model", cfg.ModelFor(llm.TaskNarrative), "music_model", cfg.ModelFor(llm.TaskMusic),
+		"classify_model", cfg.ModelFor(llm.TaskClassify),
+		"timeout", cfg.Timeout, "stream", cfg.Stream, "cache_ttl", cfg.CacheTTL, "tokenizer", tok != nil,
+		"response_format", cfg.ResponseFormat, "repair_attempts", cfg.RepairAttempts,
+		"vision", cfg.Vision)
+	shaper := llm.NewShaper(client, cfg, llm.WithTokenizer(tok))
+	opts = append(opts, discord.WithShaper(shaper))
+
+	var embedder *llm.Embedder
+	if cfg.EmbeddingModel != "" {
+		embedder = llm.NewEmbedder(router, cfg.EmbeddingModel)
+		opts = append(opts, discord.WithEmbedder(embedder))
+		storage, err := store.EmbeddingStorage(ctx)
+		if err != nil {
+			_ = level.Warn(ctx.Log()).Log("msg", "embedding storage check failed", "error", err)
+		}
+		_ = level.Info(ctx.Log()).Log("msg", "semantic rules enabled", "embedding_model", cfg.EmbeddingModel, "storage", storage)
+	}
+	return opts, shaper, embedder
+}
+
+// classifyTopics returns the CLASSIFY_TOPICS taxonomy, lowercased, or
+// llm.DefaultTopics when it's unset.
+func classifyTopics() []string {
+	var topics []string
+	for _, t := range strings.Split(os.Getenv("CLASSIFY_TOPICS"), ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	if len(topics) == 0 {
+		return llm.DefaultTopics
 	}
-	_ = level.Info(ctx.Log()).Log("msg", "llm enabled", "base_url", cfg.BaseURL, "model", cfg.Model, "timeout", cfg.Timeout)
-	return llm.NewShaper(client, cfg)
+	return topics
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	ctxpkg "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
+)
+
+const migrateUsage = "usage: reddit-spy migrate status|up|down [n]"
+
+// runMigrate implements the migrate subcommand. up applies pending
+// migrations; down reverts the newest n (default 1); status lists them
+// all. The server applies pending migrations on start anyway, so up is for
+// migrating ahead of a rollout and down for rolling one back.
+func runMigrate(ctx ctxpkg.Ctx, out io.Writer, args []string) error {
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[0] != "down") {
+		return errors.New(migrateUsage)
+	}
+	steps := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("down takes a positive number of migrations, got %q", args[1])
+		}
+		steps = n
+	}
+
+	store, err := dbstore.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create db: %w", err)
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := store.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED\tDOWN")
+		for _, st := range states {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.UTC().Format("2006-01-02 15:04:05Z")
+			}
+			down := "yes"
+			if st.Down == "" {
+				down = "no"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, applied, down)
+		}
+		return tw.Flush()
+	case "up":
+		ran, err := store.MigrateUp(ctx)
+		for _, m := range ran {
+			fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintln(out, "nothing to apply")
+		}
+		return err
+	case "down":
+		reverted, err := store.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "nothing to revert")
+		}
+		return err
+	}
+	return errors.New(migrateUsage)
+}
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-kit/log/level"
+
 	ctx "github.com/meriley/reddit-spy/internal/context"
 	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	"github.com/meriley/reddit-spy/internal/reddit"
@@ -39,7 +41,11 @@ func (b *RedditDiscordBot) AddSubredditPoller(
 ) *redditJSON.Poller {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	return b.addSubredditPollerLocked(c, subreddit)
+}
 
+// addSubredditPollerLocked is AddSubredditPoller for callers holding b.mu.
+func (b *RedditDiscordBot) addSubredditPollerLocked(c ctx.Ctx, subreddit *dbstore.Subreddit) *redditJSON.Poller {
 	if poller, found := b.pollers[subreddit.ID]; found {
 		return poller
 	}
@@ -54,6 +60,51 @@ func (b *RedditDiscordBot) AddSubredditPoller(
 	return poller
 }
 
+// RemoveSubredditPoller stops the subreddit's poller, if it has one.
+func (b *RedditDiscordBot) RemoveSubredditPoller(subredditID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if poller, found := b.pollers[subredditID]; found {
+		poller.Stop()
+		delete(b.pollers, subredditID)
+	}
+}
+
+// SyncPollers polls exactly the subreddits that have a rule neither paused
+// nor snoozed: it starts the missing pollers and stops the rest. Rule
+// commands call it after a change, and main calls it periodically so a
+// snooze running out restarts its subreddit's poller.
+func (b *RedditDiscordBot) SyncPollers(c ctx.Ctx) error {
+	// The lock covers the read too: a rule created meanwhile either shows
+	// up in active or has its poller added after this returns, never
+	// started in between and then stopped here.
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	active, err := b.Store.GetActiveSubreddits(c)
+	if err != nil {
+		return fmt.Errorf("failed to get active subreddits: %w", err)
+	}
+	want := make(map[int]bool, len(active))
+	for _, sr := range active {
+		want[sr.ID] = true
+		if _, running := b.pollers[sr.ID]; !running {
+			_ = level.Info(c.Log()).Log("msg", "starting poller", "subreddit", sr.ExternalID)
+			b.addSubredditPollerLocked(c, sr)
+		}
+	}
+
+	for id, poller := range b.pollers {
+		if !want[id] {
+			_ = level.Info(c.Log()).Log("msg", "stopping poller; subreddit has no active rules", "subreddit_id", id)
+			poller.Stop()
+			delete(b.pollers, id)
+		}
+	}
+	return nil
+}
+
 func (b *RedditDiscordBot) Stop() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -2,8 +2,12 @@ package redditDiscordBot
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
+	ctx "github.com/meriley/reddit-spy/internal/context"
+	dbstore "github.com/meriley/reddit-spy/internal/dbstore"
 	"github.com/meriley/reddit-spy/internal/reddit"
 	"github.com/meriley/reddit-spy/internal/redditJSON"
 )
@@ -28,3 +32,112 @@ func TestPollerCount(t *testing.T) {
 		t.Errorf("PollerCount() = %d, want 0", got)
 	}
 }
+
+func TestSyncPollers(t *testing.T) {
+	c := ctx.New(context.Background())
+	store := dbstore.NewMemStore()
+	bot := &RedditDiscordBot{
+		Store:                 store,
+		Reddit:                reddit.NewSpoofClient(reddit.SpoofConfig{}),
+		pollers:               make(map[int]*redditJSON.Poller),
+		PollerResponseChannel: make(chan []*redditJSON.RedditPost, 1),
+	}
+	t.Cleanup(bot.Stop)
+
+	server, _ := store.InsertDiscordServer(c, "g1")
+	ch, _ := store.InsertDiscordChannel(c, "c1", server.ID)
+	var rules []*dbstore.Rule
+	for _, name := range []string{"metalcore", "poppunkers"} {
+		sr, _ := store.InsertSubreddit(c, name)
+		r, err := store.InsertRule(c, dbstore.Rule{Target: "title", TargetID: "tour", DiscordChannelID: ch.ID, SubredditID: sr.ID})
+		if err != nil {
+			t.Fatalf("InsertRule: %v", err)
+		}
+		rules = append(rules, r)
+	}
+	// A subreddit left without rules isn't polled.
+	if _, err := store.InsertSubreddit(c, "empty"); err != nil {
+		t.Fatalf("InsertSubreddit: %v", err)
+	}
+
+	check := func(want int) {
+		t.Helper()
+		if err := bot.SyncPollers(c); err != nil {
+			t.Fatalf("SyncPollers: %v", err)
+		}
+		if got := bot.PollerCount(); got != want {
+			t.Errorf("PollerCount() = %d, want %d", got, want)
+		}
+	}
+	check(2)
+
+	if err := store.UpdateRulePause(c, rules[0].ID, true, time.Time{}); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	if err := store.UpdateRulePause(c, rules[1].ID, false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	check(0)
+
+	if err := store.UpdateRulePause(c, rules[0].ID, false, time.Time{}); err != nil {
+		t.Fatalf("UpdateRulePause: %v", err)
+	}
+	check(1)
+	if _, ok := bot.pollers[rules[0].SubredditID]; !ok {
+		t.Errorf("pollers = %v, want the resumed rule's subreddit", bot.pollers)
+	}
+
+	bot.RemoveSubredditPoller(rules[0].SubredditID)
+	if got := bot.PollerCount(); got != 0 {
+		t.Errorf("PollerCount() after remove = %d, want 0", got)
+	}
+}
+
+// racingStore runs race once, in the background, right after
+// GetActiveSubreddits has read its (soon stale) answer.
+type racingStore struct {
+	dbstore.Store
+	race func()
+	once sync.Once
+	done sync.WaitGroup
+}
+
+func (s *racingStore) GetActiveSubreddits(c context.Context) ([]*dbstore.Subreddit, error) {
+	active, err := s.Store.GetActiveSubreddits(c)
+	s.once.Do(func() {
+		s.done.Add(1)
+		go func() {
+			defer s.done.Done()
+			s.race()
+		}()
+		time.Sleep(20 * time.Millisecond)
+	})
+	return active, err
+}
+
+func TestSyncPollers_KeepsPollerAddedMidSync(t *testing.T) {
+	c := ctx.New(context.Background())
+	mem := dbstore.NewMemStore()
+	store := &racingStore{Store: mem}
+	bot := &RedditDiscordBot{
+		ctx:                   c,
+		Store:                 store,
+		Reddit:                reddit.NewSpoofClient(reddit.SpoofConfig{}),
+		pollers:               make(map[int]*redditJSON.Poller),
+		PollerResponseChannel: make(chan []*redditJSON.RedditPost, 1),
+	}
+	t.Cleanup(bot.Stop)
+	store.race = func() {
+		if err := bot.CreateRule(c, "g1", "c1", "metalcore", dbstore.Rule{Target: "title", TargetID: "tour"}); err != nil {
+			t.Errorf("CreateRule: %v", err)
+		}
+	}
+
+	if err := bot.SyncPollers(c); err != nil {
+		t.Fatalf("SyncPollers: %v", err)
+	}
+	store.done.Wait()
+	if got := bot.PollerCount(); got != 1 {
+		t.Errorf("PollerCount() = %d, want the new rule's poller to survive the sync", got)
+	}
+}